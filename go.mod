@@ -13,6 +13,7 @@ require (
 	github.com/stretchr/testify v1.8.2
 	gitlab.com/elixxir/client/v4 v4.6.4-0.20230727201043-1a3c489f0023
 	gitlab.com/elixxir/crypto v0.0.7-0.20230614183801-387e0cb8e76f
+	gitlab.com/elixxir/ekv v0.3.1-0.20230620180825-838848b00f19
 	gitlab.com/elixxir/primitives v0.0.3-0.20230613193928-8cf8bdd777ef
 	gitlab.com/elixxir/wasm-utils v0.0.0-20230615222914-185dd3a6fa08
 	gitlab.com/xx_network/crypto v0.0.5-0.20230214003943-8a09396e95dd
@@ -72,7 +73,6 @@ require (
 	github.com/zeebo/blake3 v0.2.3 // indirect
 	gitlab.com/elixxir/bloomfilter v0.0.0-20230322223210-fa84f6842de8 // indirect
 	gitlab.com/elixxir/comms v0.0.4-0.20230718154315-08043221466a // indirect
-	gitlab.com/elixxir/ekv v0.3.1-0.20230620180825-838848b00f19 // indirect
 	gitlab.com/xx_network/comms v0.0.4-0.20230214180029-5387fb85736d // indirect
 	gitlab.com/xx_network/ring v0.0.3-0.20220902183151-a7d3b15bc981 // indirect
 	gitlab.com/yawning/bsaes.git v0.0.0-20190805113838-0a714cd429ec // indirect
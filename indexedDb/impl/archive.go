@@ -0,0 +1,1050 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/xx_network/crypto/csprng"
+)
+
+// archiveVersion is the version of the archive format produced by
+// ExportDatabase. An archive is a stream of frames encrypted with a key derived
+// from a password (see ExportDatabase).
+const archiveVersion = 2
+
+// archiveMagic is written at the start of every archive.
+var archiveMagic = []byte("xxdkArchive\n")
+
+// Sizes and limits of the archive format.
+const (
+	// archiveTimeout is the timeout for each operation on a database while it
+	// is exported or imported. It is longer than DefaultTimeout because whole
+	// pages of rows are read or written at once.
+	archiveTimeout = time.Minute
+
+	// archiveSaltLen is the length of the salt used to derive the archive key.
+	archiveSaltLen = 16
+
+	// archiveRecordSize is the size, in bytes, of the keys and values after
+	// which a page of rows is ended early. Together with archiveRecordRows, it
+	// bounds the memory used to export or import a page.
+	archiveRecordSize = 1 << 20
+
+	// maxArchiveFrameSize is the largest frame accepted on import. A single
+	// row larger than archiveRecordSize makes a larger frame, so this is
+	// much larger than archiveRecordSize.
+	maxArchiveFrameSize = 64 << 20
+
+	// maxArchiveKdfMemory and maxArchiveKdfTime bound the Argon2 parameters
+	// accepted from an archive header so that a crafted archive cannot use
+	// all the memory or time of the importing client.
+	maxArchiveKdfMemory = 1 << 20 // 1 GiB
+	maxArchiveKdfTime   = 16
+
+	// frameLenSize is the size of the big-endian length prefix of a frame.
+	frameLenSize = 4
+
+	// frameFinal is the flag byte of the last sealed frame of an archive.
+	frameFinal = 1
+)
+
+// archiveRecordRows is the maximum number of rows exported in a single record.
+// It is a variable so that tests can export many records from a small
+// database.
+var archiveRecordRows = 500
+
+// Error messages.
+const (
+	databaseExistsErr       = "database %q already exists"
+	unsupportedArchiveErr   = "unsupported archive version %d; newest is %d"
+	rowCountMismatchErr     = "object store %q has %d values; expected %d"
+	duplicateObjectStoreErr = "archive contains object store %q twice"
+	unknownObjectStoreErr   = "archive has rows for unknown object store %q"
+	frameTooLargeErr        = "archive frame of %d bytes exceeds limit of %d"
+	invalidKdfParamsErr     = "archive key derivation parameters %+v are invalid"
+	decryptFrameErr         = "failed to decrypt archive frame %d; the " +
+		"password is wrong or the archive was modified"
+)
+
+var (
+	// errDatabaseNotExist is returned when exporting a database that does not
+	// exist.
+	errDatabaseNotExist = errors.New("database does not exist")
+
+	// errArchiveIncomplete is returned when an archive ends before its final
+	// frame.
+	errArchiveIncomplete = errors.New("archive is incomplete")
+
+	// errImportAborted is returned by ArchiveImporter after Abort is called.
+	errImportAborted = errors.New("import aborted")
+
+	// errNotArchive is returned when importing data that does not start with
+	// archiveMagic.
+	errNotArchive = errors.New("data is not a database archive")
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// Archive Format                                                             //
+////////////////////////////////////////////////////////////////////////////////
+
+// archiveHeader is the first frame of an archive. It is not encrypted, but its
+// hash is authenticated with every sealed frame, so it cannot be modified.
+type archiveHeader struct {
+	// Version is the version of the archive format.
+	Version int `json:"version"`
+
+	// Salt and KDF are the parameters used to derive the archive key from the
+	// password with Argon2id.
+	Salt []byte     `json:"salt"`
+	KDF  archiveKDF `json:"kdf"`
+}
+
+// archiveKDF contains the Argon2id cost parameters of an archive key.
+type archiveKDF struct {
+	Time    uint32 `json:"time"`    // Number of passes over the memory
+	Memory  uint32 `json:"memory"`  // Amount of memory used in KiB
+	Threads uint8  `json:"threads"` // Number of threads used
+}
+
+// defaultArchiveKDF returns the parameters used to derive the key of new
+// archives. They match the parameters used for the internal password.
+func defaultArchiveKDF() archiveKDF {
+	return archiveKDF{Time: 1, Memory: 64 * 1024, Threads: 4}
+}
+
+// archiveRecord is the plaintext of a sealed frame. Exactly one field is set.
+// The first record is a Database, followed by any number of Rows, and the last
+// record, and only the last, is an End.
+type archiveRecord struct {
+	Database *archiveDatabase `json:"database,omitempty"`
+	Rows     *archiveRows     `json:"rows,omitempty"`
+	End      *archiveEnd      `json:"end,omitempty"`
+}
+
+// archiveDatabase contains the name, version, and schema of the database.
+type archiveDatabase struct {
+	Name    string          `json:"name"`
+	Version uint            `json:"version"`
+	Created time.Time       `json:"created"`
+	Stores  []archiveSchema `json:"stores"`
+}
+
+// archiveSchema contains the schema of an object store.
+type archiveSchema struct {
+	Name          string          `json:"name"`
+	KeyPath       json.RawMessage `json:"keyPath"`
+	AutoIncrement bool            `json:"autoIncrement"`
+	Indexes       []archiveIndex  `json:"indexes"`
+}
+
+// archiveIndex contains the schema of an index on an object store.
+type archiveIndex struct {
+	Name       string          `json:"name"`
+	KeyPath    json.RawMessage `json:"keyPath"`
+	Unique     bool            `json:"unique"`
+	MultiEntry bool            `json:"multiEntry"`
+}
+
+// archiveRows contains a page of rows of an object store.
+type archiveRows struct {
+	Store string       `json:"store"`
+	Rows  []archiveRow `json:"rows"`
+}
+
+// archiveRow contains a single value in an object store and its primary key.
+type archiveRow struct {
+	Key   json.RawMessage `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// archiveEnd is the last record of an archive. It contains the number of rows
+// exported from each object store.
+type archiveEnd struct {
+	Rows map[string]int `json:"rows"`
+}
+
+// archiveSealer encrypts and decrypts the frames of an archive with
+// XChaCha20-Poly1305. The additional data of each frame is the hash of the
+// header, the index of the frame, and whether it is the final frame, so frames
+// cannot be moved between archives, reordered, dropped, or truncated from the
+// end without failing to decrypt.
+type archiveSealer struct {
+	aead       cipher.AEAD
+	headerHash [sha256.Size]byte
+	index      uint64
+}
+
+// newArchiveSealer derives the archive key from the password using the
+// parameters in the header.
+func newArchiveSealer(
+	password []byte, header archiveHeader, headerData []byte) (
+	*archiveSealer, error) {
+	kdf := header.KDF
+	if len(header.Salt) != archiveSaltLen || kdf.Time == 0 ||
+		kdf.Time > maxArchiveKdfTime || kdf.Memory == 0 ||
+		kdf.Memory > maxArchiveKdfMemory || kdf.Threads == 0 {
+		return nil, errors.Errorf(invalidKdfParamsErr, kdf)
+	}
+
+	key := argon2.IDKey(password, header.Salt,
+		kdf.Time, kdf.Memory, kdf.Threads, chacha20poly1305.KeySize)
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialise archive cipher")
+	}
+
+	return &archiveSealer{
+		aead:       aead,
+		headerHash: sha256.Sum256(headerData),
+	}, nil
+}
+
+// additionalData returns the additional data of the frame at the index.
+func (as *archiveSealer) additionalData(index uint64, flag byte) []byte {
+	ad := make([]byte, 0, sha256.Size+8+1)
+	ad = append(ad, as.headerHash[:]...)
+	ad = binary.BigEndian.AppendUint64(ad, index)
+	return append(ad, flag)
+}
+
+// seal encrypts the record into the payload of the next frame. The payload is
+// the flag byte, the nonce, and the ciphertext.
+func (as *archiveSealer) seal(
+	record archiveRecord, final bool, rng io.Reader) ([]byte, error) {
+	plaintext, err := json.Marshal(record)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal archive record")
+	}
+
+	var flag byte
+	if final {
+		flag = frameFinal
+	}
+	payload := make([]byte, 1+as.aead.NonceSize(),
+		1+as.aead.NonceSize()+len(plaintext)+as.aead.Overhead())
+	payload[0] = flag
+	if _, err = io.ReadFull(rng, payload[1:]); err != nil {
+		return nil, errors.Wrap(err, "failed to generate nonce")
+	}
+
+	payload = as.aead.Seal(payload, payload[1:], plaintext,
+		as.additionalData(as.index, flag))
+	as.index++
+	return payload, nil
+}
+
+// open decrypts the payload of the next frame. Returns the record and whether
+// it is the final frame.
+func (as *archiveSealer) open(payload []byte) (*archiveRecord, bool, error) {
+	if len(payload) < 1+as.aead.NonceSize() || payload[0] > frameFinal {
+		return nil, false, errors.Errorf(decryptFrameErr, as.index)
+	}
+	flag, nonce := payload[0], payload[1:1+as.aead.NonceSize()]
+	plaintext, err := as.aead.Open(nil, nonce,
+		payload[1+as.aead.NonceSize():], as.additionalData(as.index, flag))
+	if err != nil {
+		return nil, false, errors.Errorf(decryptFrameErr, as.index)
+	}
+	as.index++
+
+	var record archiveRecord
+	if err = json.Unmarshal(plaintext, &record); err != nil {
+		return nil, false, errors.Wrap(err, "failed to unmarshal archive record")
+	}
+	return &record, flag == frameFinal, nil
+}
+
+// writeFrame writes the length-prefixed payload.
+func writeFrame(w io.Writer, payload []byte) error {
+	frame := make([]byte, frameLenSize, frameLenSize+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	_, err := w.Write(append(frame, payload...))
+	return err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Export                                                                     //
+////////////////////////////////////////////////////////////////////////////////
+
+// ExportDatabase writes the schema and every value of each object store in the
+// database to w as a versioned archive encrypted with a key derived from the
+// password. The salt and cost parameters of the key are stored in the archive,
+// so it can be imported on any client that knows the password.
+//
+// The archive is written in frames of up to archiveRecordRows rows, and each
+// page of rows is read in its own transaction, so the database is never held
+// in memory as a whole. Values written to the database during the export may
+// or may not be included.
+//
+// Values are exported exactly as they are stored, so any values encrypted by
+// an event model remain encrypted with the database cipher. Since the salt of
+// that cipher is not in the archive, those values can only be read where the
+// cipher can be recreated, i.e., by the same cMix identity and storage.
+func ExportDatabase(databaseName string, password []byte, w io.Writer) error {
+	if len(password) == 0 {
+		return errors.New("an archive cannot be created without a password")
+	}
+
+	db, err := openExistingDatabase(databaseName)
+	if err != nil {
+		return err
+	}
+	defer closeDatabase(db)
+
+	version, err := db.Version()
+	if err != nil {
+		return errors.Wrap(err, "failed to get database version")
+	}
+	storeNames, err := db.ObjectStoreNames()
+	if err != nil {
+		return errors.Wrap(err, "failed to get object store names")
+	}
+
+	database := &archiveDatabase{
+		Name:    databaseName,
+		Version: version,
+		Created: time.Now(),
+		Stores:  make([]archiveSchema, 0, len(storeNames)),
+	}
+	for _, storeName := range storeNames {
+		schema, err := exportSchema(db, storeName)
+		if err != nil {
+			return errors.WithMessagef(
+				err, "failed to export schema of object store %q", storeName)
+		}
+		database.Stores = append(database.Stores, schema)
+	}
+
+	rng := csprng.NewSystemRNG()
+	sealer, err := writeArchiveHeader(w, password, rng)
+	if err != nil {
+		return err
+	}
+	writeRecord := func(record archiveRecord, final bool) error {
+		payload, err := sealer.seal(record, final, rng)
+		if err != nil {
+			return err
+		}
+		return errors.Wrap(writeFrame(w, payload), "failed to write archive")
+	}
+
+	if err = writeRecord(archiveRecord{Database: database}, false); err != nil {
+		return err
+	}
+
+	end := &archiveEnd{Rows: make(map[string]int, len(storeNames))}
+	for _, storeName := range storeNames {
+		after := js.Undefined()
+		for {
+			var rows []archiveRow
+			rows, after, err = exportRowsPage(db, storeName, after)
+			if err != nil {
+				return errors.WithMessagef(
+					err, "failed to export object store %q", storeName)
+			} else if len(rows) == 0 {
+				break
+			}
+
+			err = writeRecord(archiveRecord{Rows: &archiveRows{
+				Store: storeName,
+				Rows:  rows,
+			}}, false)
+			if err != nil {
+				return err
+			}
+			end.Rows[storeName] += len(rows)
+		}
+	}
+
+	if err = writeRecord(archiveRecord{End: end}, true); err != nil {
+		return err
+	}
+
+	jww.INFO.Printf("[ARCHIVE] Exported %d object stores of database %s",
+		len(database.Stores), databaseName)
+	return nil
+}
+
+// writeArchiveHeader writes the magic bytes and the header frame with a new
+// salt and returns the sealer for the rest of the archive.
+func writeArchiveHeader(
+	w io.Writer, password []byte, rng io.Reader) (*archiveSealer, error) {
+	header := archiveHeader{
+		Version: archiveVersion,
+		Salt:    make([]byte, archiveSaltLen),
+		KDF:     defaultArchiveKDF(),
+	}
+	if _, err := io.ReadFull(rng, header.Salt); err != nil {
+		return nil, errors.Wrap(err, "failed to generate salt")
+	}
+	headerData, err := json.Marshal(header)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal archive header")
+	}
+
+	sealer, err := newArchiveSealer(password, header, headerData)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = w.Write(archiveMagic); err != nil {
+		return nil, errors.Wrap(err, "failed to write archive")
+	} else if err = writeFrame(w, headerData); err != nil {
+		return nil, errors.Wrap(err, "failed to write archive")
+	}
+	return sealer, nil
+}
+
+// exportSchema reads the schema of the object store.
+func exportSchema(db *idb.Database, storeName string) (archiveSchema, error) {
+	txn, err := newTransaction(db, idb.TransactionReadOnly, storeName)
+	if err != nil {
+		return archiveSchema{}, errors.Errorf(
+			"Unable to create Transaction: %+v", err)
+	}
+	store, err := txn.ObjectStore(storeName)
+	if err != nil {
+		return archiveSchema{}, errors.Errorf(
+			"Unable to get ObjectStore: %+v", err)
+	}
+
+	schema := archiveSchema{Name: storeName}
+	keyPath, err := store.KeyPath()
+	if err != nil {
+		return archiveSchema{}, errors.Errorf(
+			"Unable to get KeyPath: %+v", err)
+	}
+	schema.KeyPath = json.RawMessage(utils.JsToJson(keyPath))
+	if schema.AutoIncrement, err = store.AutoIncrement(); err != nil {
+		return archiveSchema{}, errors.Errorf(
+			"Unable to get AutoIncrement: %+v", err)
+	}
+	indexNames, err := store.IndexNames()
+	if err != nil {
+		return archiveSchema{}, errors.Errorf(
+			"Unable to get IndexNames: %+v", err)
+	}
+	for _, indexName := range indexNames {
+		index, err := exportIndex(store, indexName)
+		if err != nil {
+			return archiveSchema{}, err
+		}
+		schema.Indexes = append(schema.Indexes, index)
+	}
+
+	return schema, nil
+}
+
+// exportRowsPage reads up to archiveRecordRows rows of the object store with a
+// primary key after the given key, or from the start if it is undefined. The
+// page also ends once the rows exceed archiveRecordSize. Returns the rows and
+// the primary key of the last row.
+func exportRowsPage(db *idb.Database, storeName string, after js.Value) (
+	[]archiveRow, js.Value, error) {
+	txn, err := newTransaction(db, idb.TransactionReadOnly, storeName)
+	if err != nil {
+		return nil, after, errors.Errorf(
+			"Unable to create Transaction: %+v", err)
+	}
+	store, err := txn.ObjectStore(storeName)
+	if err != nil {
+		return nil, after, errors.Errorf("Unable to get ObjectStore: %+v", err)
+	}
+
+	var cursorRequest *idb.CursorWithValueRequest
+	if after.IsUndefined() {
+		cursorRequest, err = store.OpenCursor(idb.CursorNext)
+	} else {
+		var keyRange *idb.KeyRange
+		keyRange, err = idb.NewKeyRangeLowerBound(after, true)
+		if err != nil {
+			return nil, after, errors.Errorf(
+				"Unable to create KeyRange: %+v", err)
+		}
+		cursorRequest, err = store.OpenCursorRange(keyRange, idb.CursorNext)
+	}
+	if err != nil {
+		return nil, after, errors.Errorf("Unable to open Cursor: %+v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), archiveTimeout)
+	defer cancel()
+	var rows []archiveRow
+	var size int
+	last := after
+	err = cursorRequest.Iter(ctx, func(cursor *idb.CursorWithValue) error {
+		key, err := cursor.PrimaryKey()
+		if err != nil {
+			return err
+		}
+		value, err := cursor.Value()
+		if err != nil {
+			return err
+		}
+		row := archiveRow{
+			Key:   json.RawMessage(utils.JsToJson(key)),
+			Value: json.RawMessage(utils.JsToJson(value)),
+		}
+		rows = append(rows, row)
+		size += len(row.Key) + len(row.Value)
+		last = key
+
+		if len(rows) >= archiveRecordRows || size >= archiveRecordSize {
+			return idb.ErrCursorStopIter
+		}
+		return nil
+	})
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return nil, after, errors.Errorf("Unable to read rows: %+v", err)
+	}
+
+	return rows, last, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Import                                                                     //
+////////////////////////////////////////////////////////////////////////////////
+
+// ArchiveImporter recreates the database in an archive created by
+// ExportDatabase as the archive is written to it in chunks of any size.
+//
+// Each frame is authenticated before it is written to the database, and every
+// page of rows is written in its own transaction. If the archive is invalid,
+// the import is aborted, or the archive ends before its final frame, the new
+// database is deleted. An error is returned if a database with the same name
+// already exists.
+//
+// The database is created with the version it had when it was exported. If
+// that version is older than the current version, the schema and values are
+// migrated by the event model the next time the database is opened.
+//
+// The name of the database is registered before the database is created, so
+// that a database left behind by an import that is interrupted (e.g., by
+// closing the page) can still be found and deleted.
+//
+// ArchiveImporter is safe for concurrent use, but chunks must be written in
+// order.
+type ArchiveImporter struct {
+	password []byte
+
+	// register is called with the name of the database before it is created.
+	register func(databaseName string) error
+
+	// magicRead is true once archiveMagic has been read.
+	magicRead bool
+
+	// buf contains the bytes written that do not yet make a whole frame.
+	buf []byte
+
+	// sealer is set once the header frame has been read.
+	sealer *archiveSealer
+
+	db      *idb.Database
+	name    string
+	schemas map[string]archiveSchema
+	counts  map[string]int
+
+	// done is true once the final record has been applied.
+	done bool
+
+	// err is the first error encountered. Once set, all further calls fail.
+	err error
+
+	mux sync.Mutex
+}
+
+// NewArchiveImporter returns a new importer that decrypts the archive with
+// the password. register is called with the name of the database before it is
+// created and before any values are written to it (e.g., to track it so that
+// it is deleted on purge). The import fails if register returns an error.
+func NewArchiveImporter(password []byte,
+	register func(databaseName string) error) *ArchiveImporter {
+	return &ArchiveImporter{password: password, register: register}
+}
+
+// Write imports the next chunk of the archive. Every complete frame in the
+// chunk is decrypted and written to the database before Write returns. Once
+// Write returns an error, the import has failed.
+func (ai *ArchiveImporter) Write(p []byte) (int, error) {
+	ai.mux.Lock()
+	defer ai.mux.Unlock()
+
+	if ai.err != nil {
+		return 0, ai.err
+	}
+
+	ai.buf = append(ai.buf, p...)
+	if err := ai.readFrames(); err != nil {
+		ai.fail(err)
+		return 0, ai.err
+	}
+	return len(p), nil
+}
+
+// Close finishes the import and returns the name of the imported database.
+// Returns an error if the archive is incomplete or the import failed.
+func (ai *ArchiveImporter) Close() (string, error) {
+	ai.mux.Lock()
+	defer ai.mux.Unlock()
+
+	if ai.err != nil {
+		return "", ai.err
+	}
+
+	if !ai.done || len(ai.buf) > 0 {
+		ai.fail(errArchiveIncomplete)
+		return "", ai.err
+	}
+
+	closeDatabase(ai.db)
+	ai.db = nil
+	ai.err = errors.New("import already closed")
+
+	jww.INFO.Printf("[ARCHIVE] Imported %d object stores into database %s",
+		len(ai.schemas), ai.name)
+	return ai.name, nil
+}
+
+// Abort stops the import and deletes the partially imported database.
+func (ai *ArchiveImporter) Abort() {
+	ai.mux.Lock()
+	defer ai.mux.Unlock()
+
+	if ai.err == nil {
+		ai.fail(errImportAborted)
+	}
+}
+
+// fail records the error and deletes the database if it was created by this
+// import.
+func (ai *ArchiveImporter) fail(err error) {
+	ai.err = err
+	ai.buf = nil
+	if ai.db == nil {
+		return
+	}
+
+	closeDatabase(ai.db)
+	ai.db = nil
+	if deleteErr := deleteDatabase(ai.name); deleteErr != nil {
+		jww.ERROR.Printf("[ARCHIVE] Failed to delete database %s after "+
+			"failed import: %+v", ai.name, deleteErr)
+	}
+}
+
+// readFrames checks that the archive starts with archiveMagic and then reads
+// and applies every complete frame in the buffer. Data that does not start with
+// archiveMagic is rejected as soon as the first byte that differs is written.
+func (ai *ArchiveImporter) readFrames() error {
+	if !ai.magicRead {
+		n := len(ai.buf)
+		if n > len(archiveMagic) {
+			n = len(archiveMagic)
+		}
+		if !bytes.Equal(ai.buf[:n], archiveMagic[:n]) {
+			return errNotArchive
+		} else if n < len(archiveMagic) {
+			return nil
+		}
+		ai.magicRead = true
+		ai.buf = ai.buf[len(archiveMagic):]
+	}
+
+	for len(ai.buf) >= frameLenSize {
+		size := binary.BigEndian.Uint32(ai.buf)
+		if size > maxArchiveFrameSize {
+			return errors.Errorf(frameTooLargeErr, size, maxArchiveFrameSize)
+		} else if len(ai.buf) < frameLenSize+int(size) {
+			return nil
+		}
+		payload := ai.buf[frameLenSize : frameLenSize+int(size)]
+		ai.buf = ai.buf[frameLenSize+int(size):]
+
+		if err := ai.readFrame(payload); err != nil {
+			return err
+		}
+	}
+
+	// Release the memory of frames already read
+	ai.buf = append([]byte(nil), ai.buf...)
+	return nil
+}
+
+// readFrame reads the header frame or decrypts and applies a sealed frame.
+func (ai *ArchiveImporter) readFrame(payload []byte) error {
+	if ai.sealer == nil {
+		var header archiveHeader
+		if err := json.Unmarshal(payload, &header); err != nil {
+			return errors.Wrap(err, "failed to unmarshal archive header")
+		} else if header.Version != archiveVersion {
+			return errors.Errorf(
+				unsupportedArchiveErr, header.Version, archiveVersion)
+		}
+
+		sealer, err := newArchiveSealer(ai.password, header, payload)
+		if err != nil {
+			return err
+		}
+		ai.sealer = sealer
+		return nil
+	} else if ai.done {
+		return errors.New("archive has data after its final frame")
+	}
+
+	record, final, err := ai.sealer.open(payload)
+	if err != nil {
+		return err
+	}
+	return ai.apply(*record, final)
+}
+
+// apply writes the record to the database. The database is created by the
+// first record, and the final record verifies that every row was imported.
+func (ai *ArchiveImporter) apply(record archiveRecord, final bool) error {
+	if (record.End != nil) != final {
+		return errors.New("archive end does not match its final frame")
+	}
+
+	switch {
+	case record.Database != nil && ai.db == nil && ai.schemas == nil:
+		return ai.createDatabase(record.Database)
+	case ai.db == nil:
+		return errors.New("archive does not start with a database")
+	case record.Rows != nil:
+		schema, exists := ai.schemas[record.Rows.Store]
+		if !exists {
+			return errors.Errorf(unknownObjectStoreErr, record.Rows.Store)
+		}
+		if err := importRows(ai.db, schema, record.Rows.Rows); err != nil {
+			return errors.WithMessagef(
+				err, "failed to write object store %q", schema.Name)
+		}
+		ai.counts[schema.Name] += len(record.Rows.Rows)
+		return nil
+	case record.End != nil:
+		if err := ai.verify(record.End); err != nil {
+			return err
+		}
+		ai.done = true
+		return nil
+	default:
+		return errors.New("archive contains an invalid record")
+	}
+}
+
+// createDatabase creates the database with the schema in the record. Returns
+// an error if the database already exists.
+func (ai *ArchiveImporter) createDatabase(database *archiveDatabase) error {
+	if database.Name == "" {
+		return errors.New("archive has no database name")
+	} else if database.Version == 0 {
+		return errors.New("archive has no database version")
+	}
+
+	ai.schemas = make(map[string]archiveSchema, len(database.Stores))
+	ai.counts = make(map[string]int, len(database.Stores))
+	for _, schema := range database.Stores {
+		if _, exists := ai.schemas[schema.Name]; exists {
+			return errors.Errorf(duplicateObjectStoreErr, schema.Name)
+		}
+		ai.schemas[schema.Name] = schema
+	}
+
+	exists, err := databaseExists(database.Name)
+	if err != nil {
+		return err
+	} else if exists {
+		return errors.Errorf(databaseExistsErr, database.Name)
+	}
+
+	if err = ai.register(database.Name); err != nil {
+		return errors.WithMessage(err, "failed to register database")
+	}
+
+	db, err := createDatabaseSchema(database)
+	if err != nil {
+		return errors.WithMessage(err, "failed to create database")
+	}
+	ai.db, ai.name = db, database.Name
+	return nil
+}
+
+// verify returns an error if the number of rows imported into, or stored in,
+// any object store does not match the number exported.
+func (ai *ArchiveImporter) verify(end *archiveEnd) error {
+	for storeName := range ai.schemas {
+		expected := end.Rows[storeName]
+		if ai.counts[storeName] != expected {
+			return errors.Errorf(rowCountMismatchErr,
+				storeName, ai.counts[storeName], expected)
+		}
+
+		count, err := Count(ai.db, storeName)
+		if err != nil {
+			return err
+		} else if count != expected {
+			return errors.Errorf(
+				rowCountMismatchErr, storeName, count, expected)
+		}
+	}
+	for storeName := range end.Rows {
+		if _, exists := ai.schemas[storeName]; !exists {
+			return errors.Errorf(unknownObjectStoreErr, storeName)
+		}
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Database Helpers                                                           //
+////////////////////////////////////////////////////////////////////////////////
+
+// exportIndex reads the schema of the index.
+func exportIndex(
+	store *idb.ObjectStore, indexName string) (archiveIndex, error) {
+	index, err := store.Index(indexName)
+	if err != nil {
+		return archiveIndex{}, errors.Errorf(
+			"Unable to get Index %q: %+v", indexName, err)
+	}
+
+	ai := archiveIndex{Name: indexName}
+	keyPath, err := index.KeyPath()
+	if err != nil {
+		return archiveIndex{}, errors.Errorf(
+			"Unable to get KeyPath of Index %q: %+v", indexName, err)
+	}
+	ai.KeyPath = json.RawMessage(utils.JsToJson(keyPath))
+	if ai.Unique, err = index.Unique(); err != nil {
+		return archiveIndex{}, errors.Errorf(
+			"Unable to get Unique of Index %q: %+v", indexName, err)
+	}
+	if ai.MultiEntry, err = index.MultiEntry(); err != nil {
+		return archiveIndex{}, errors.Errorf(
+			"Unable to get MultiEntry of Index %q: %+v", indexName, err)
+	}
+	return ai, nil
+}
+
+// createDatabaseSchema creates the database in the archive with its version,
+// object stores, and indexes.
+func createDatabaseSchema(database *archiveDatabase) (*idb.Database, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), archiveTimeout)
+	defer cancel()
+	openRequest, err := idb.Global().Open(ctx, database.Name,
+		database.Version, func(db *idb.Database, _, _ uint) error {
+			for _, schema := range database.Stores {
+				if err := createObjectStore(db, schema); err != nil {
+					return errors.WithMessagef(err,
+						"failed to create object store %q", schema.Name)
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := openRequest.Await(ctx)
+	if err != nil {
+		return nil, err
+	} else if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return db, nil
+}
+
+// createObjectStore creates the object store and its indexes from the archived
+// schema. Must be called from within an upgrade.
+func createObjectStore(db *idb.Database, schema archiveSchema) error {
+	keyPath, err := jsonToValue(schema.KeyPath)
+	if err != nil {
+		return err
+	}
+	store, err := db.CreateObjectStore(schema.Name, idb.ObjectStoreOptions{
+		KeyPath:       keyPath,
+		AutoIncrement: schema.AutoIncrement,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, ai := range schema.Indexes {
+		keyPath, err = jsonToValue(ai.KeyPath)
+		if err != nil {
+			return err
+		}
+		_, err = store.CreateIndex(ai.Name, keyPath, idb.IndexOptions{
+			Unique:     ai.Unique,
+			MultiEntry: ai.MultiEntry,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create index %q", ai.Name)
+		}
+	}
+	return nil
+}
+
+// importRows writes the rows to the object store in a single transaction.
+func importRows(
+	db *idb.Database, schema archiveSchema, rows []archiveRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	txn, err := newTransaction(db, idb.TransactionReadWrite, schema.Name)
+	if err != nil {
+		return errors.Errorf("Unable to create Transaction: %+v", err)
+	}
+
+	// All requests are made before waiting on the transaction so that it is
+	// committed as a whole or not at all
+	if err = putRows(txn, schema, rows); err != nil {
+		if abortErr := txn.Abort(); abortErr != nil {
+			jww.ERROR.Printf("[ARCHIVE] Failed to abort import "+
+				"transaction: %+v", abortErr)
+		}
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), archiveTimeout)
+	defer cancel()
+	if err = txn.Await(ctx); err != nil {
+		return errors.Errorf("Unable to commit Transaction: %+v", err)
+	}
+	return nil
+}
+
+// putRows adds a put request for every row to the transaction.
+func putRows(
+	txn *idb.Transaction, schema archiveSchema, rows []archiveRow) error {
+	store, err := txn.ObjectStore(schema.Name)
+	if err != nil {
+		return errors.Errorf("Unable to get ObjectStore: %+v", err)
+	}
+	inlineKeys := string(schema.KeyPath) != "null"
+
+	for i, row := range rows {
+		value, err := jsonToValue(row.Value)
+		if err != nil {
+			return errors.WithMessagef(err, "invalid value %d", i)
+		}
+
+		if inlineKeys {
+			_, err = store.Put(value)
+		} else {
+			var key js.Value
+			if key, err = jsonToValue(row.Key); err != nil {
+				return errors.WithMessagef(err, "invalid key %d", i)
+			}
+			_, err = store.PutKey(key, value)
+		}
+		if err != nil {
+			return errors.Errorf("Unable to Put value %d: %+v", i, err)
+		}
+	}
+	return nil
+}
+
+// openExistingDatabase opens the database at its current version. Returns an
+// error if the database does not exist.
+func openExistingDatabase(databaseName string) (*idb.Database, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), archiveTimeout)
+	defer cancel()
+
+	var created bool
+	openRequest, err := idb.Global().Open(ctx, databaseName, 0,
+		func(_ *idb.Database, oldVersion, _ uint) error {
+			created = oldVersion == 0
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	db, err := openRequest.Await(ctx)
+	if err != nil {
+		return nil, err
+	} else if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// Opening a database that does not exist creates it, so it is deleted
+	if created {
+		closeDatabase(db)
+		if err = deleteDatabase(databaseName); err != nil {
+			jww.ERROR.Printf("[ARCHIVE] Failed to delete empty database %s: "+
+				"%+v", databaseName, err)
+		}
+		return nil, errors.WithMessage(errDatabaseNotExist, databaseName)
+	}
+
+	return db, nil
+}
+
+// databaseExists returns true if the database exists.
+func databaseExists(databaseName string) (bool, error) {
+	db, err := openExistingDatabase(databaseName)
+	if err != nil {
+		if errors.Is(err, errDatabaseNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	closeDatabase(db)
+	return true, nil
+}
+
+// closeDatabase closes the database and logs any error.
+func closeDatabase(db *idb.Database) {
+	if err := db.Close(); err != nil {
+		jww.ERROR.Printf("[ARCHIVE] Failed to close database: %+v", err)
+	}
+}
+
+// deleteDatabase deletes the database. The database must not have any open
+// connections.
+func deleteDatabase(databaseName string) error {
+	deleteRequest, err := idb.Global().DeleteDatabase(databaseName)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), archiveTimeout)
+	defer cancel()
+	return deleteRequest.Await(ctx)
+}
+
+// jsonToValue converts any JSON value into a js.Value.
+func jsonToValue(data []byte) (js.Value, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return js.Undefined(), err
+	}
+	return js.ValueOf(v), nil
+}
@@ -0,0 +1,462 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"syscall/js"
+	"testing"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/pkg/errors"
+
+	"gitlab.com/xx_network/crypto/csprng"
+)
+
+// newArchiveTestDB creates a database with an auto-incremented object store
+// with an index and an object store with out-of-line keys, and fills both with
+// numValues values.
+func newArchiveTestDB(
+	name string, numValues int, t *testing.T) *idb.Database {
+	ctx, cancel := NewContext()
+	defer cancel()
+	openRequest, err := idb.Global().Open(ctx, name, 3,
+		func(db *idb.Database, _ uint, _ uint) error {
+			messages, err := db.CreateObjectStore("messages",
+				idb.ObjectStoreOptions{
+					KeyPath:       js.ValueOf("id"),
+					AutoIncrement: true,
+				})
+			if err != nil {
+				return err
+			}
+			_, err = messages.CreateIndex("channel",
+				js.ValueOf("channel"), idb.IndexOptions{Unique: false})
+			if err != nil {
+				return err
+			}
+
+			_, err = db.CreateObjectStore("state", idb.ObjectStoreOptions{})
+			return err
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openRequest.Await(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < numValues; i++ {
+		_, err = Put(db, "messages", js.ValueOf(map[string]any{
+			"channel": "channel" + strconv.Itoa(i%3),
+			"text":    "message " + strconv.Itoa(i),
+		}))
+		if err != nil {
+			t.Fatalf("Failed to put message %d: %+v", i, err)
+		}
+
+		txn, err := db.Transaction(idb.TransactionReadWrite, "state")
+		if err != nil {
+			t.Fatal(err)
+		}
+		store, err := txn.ObjectStore("state")
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := store.PutKey(js.ValueOf("key"+strconv.Itoa(i)),
+			js.ValueOf(map[string]any{"value": i}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = SendRequest(req); err != nil {
+			t.Fatalf("Failed to put state %d: %+v", i, err)
+		}
+	}
+
+	return db
+}
+
+// dumpArchiveTestDB returns the values of every object store in the test
+// database.
+func dumpArchiveTestDB(db *idb.Database, t *testing.T) map[string][]string {
+	values := make(map[string][]string)
+	for _, storeName := range []string{"messages", "state"} {
+		dump, err := Dump(db, storeName)
+		if err != nil {
+			t.Fatalf("Failed to dump %s: %+v", storeName, err)
+		}
+		values[storeName] = dump
+	}
+	return values
+}
+
+// exportTestArchive exports the database with the password and returns the
+// archive.
+func exportTestArchive(name string, password []byte, t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := ExportDatabase(name, password, &buf); err != nil {
+		t.Fatalf("Failed to export database: %+v", err)
+	}
+	return buf.Bytes()
+}
+
+// importTestArchive writes the archive to a new ArchiveImporter in chunks of
+// the given size and closes it.
+func importTestArchive(
+	archiveData, password []byte, chunkSize int) (string, error) {
+	ai := NewArchiveImporter(password, registerTestArchive)
+	for start := 0; start < len(archiveData); start += chunkSize {
+		end := start + chunkSize
+		if end > len(archiveData) {
+			end = len(archiveData)
+		}
+		if _, err := ai.Write(archiveData[start:end]); err != nil {
+			return "", err
+		}
+	}
+	return ai.Close()
+}
+
+// registerTestArchive is the register function of ArchiveImporter used in
+// tests. It does nothing.
+func registerTestArchive(string) error { return nil }
+
+// splitTestArchive splits a version 2 archive into its header frame and sealed
+// frames, each with its length prefix.
+func splitTestArchive(archiveData []byte, t *testing.T) [][]byte {
+	if !bytes.HasPrefix(archiveData, archiveMagic) {
+		t.Fatalf("Archive does not start with magic bytes.")
+	}
+	archiveData = archiveData[len(archiveMagic):]
+
+	var frames [][]byte
+	for len(archiveData) > 0 {
+		size := frameLenSize + int(binary.BigEndian.Uint32(archiveData))
+		frames = append(frames, archiveData[:size])
+		archiveData = archiveData[size:]
+	}
+	return frames
+}
+
+// joinTestArchive joins the frames of a version 2 archive.
+func joinTestArchive(frames [][]byte) []byte {
+	return bytes.Join(append([][]byte{archiveMagic}, frames...), nil)
+}
+
+// setArchiveRecordRows sets archiveRecordRows for the duration of the test.
+func setArchiveRecordRows(n int, t *testing.T) {
+	original := archiveRecordRows
+	archiveRecordRows = n
+	t.Cleanup(func() { archiveRecordRows = original })
+}
+
+// assertDatabaseNotExist fails the test if the database exists.
+func assertDatabaseNotExist(name string, t *testing.T) {
+	exists, err := databaseExists(name)
+	if err != nil {
+		t.Fatalf("Failed to check if database exists: %+v", err)
+	} else if exists {
+		t.Errorf("Database %s exists after failed import.", name)
+	}
+}
+
+// Tests that a database exported with ExportDatabase over several frames,
+// deleted, and then written to an ArchiveImporter in small chunks has the same
+// version, schema, and values.
+func TestExportDatabase_ArchiveImporter(t *testing.T) {
+	const name = "TestExportDatabase_ArchiveImporter"
+	setArchiveRecordRows(4, t)
+	password := []byte("archivePassword")
+	db := newArchiveTestDB(name, 25, t)
+	expected := dumpArchiveTestDB(db, t)
+	closeDatabase(db)
+
+	archiveData := exportTestArchive(name, password, t)
+	if frames := splitTestArchive(archiveData, t); len(frames) != 17 {
+		t.Errorf("Unexpected number of frames.\nexpected: %d\nreceived: %d",
+			17, len(frames))
+	}
+	if err := deleteDatabase(name); err != nil {
+		t.Fatalf("Failed to delete database: %+v", err)
+	}
+
+	importedName, err := importTestArchive(archiveData, password, 7)
+	if err != nil {
+		t.Fatalf("Failed to import database: %+v", err)
+	} else if importedName != name {
+		t.Errorf("Unexpected database name.\nexpected: %s\nreceived: %s",
+			name, importedName)
+	}
+
+	db, err = openExistingDatabase(name)
+	if err != nil {
+		t.Fatalf("Failed to open imported database: %+v", err)
+	}
+	defer closeDatabase(db)
+
+	if version, _ := db.Version(); version != 3 {
+		t.Errorf("Unexpected database version.\nexpected: %d\nreceived: %d",
+			3, version)
+	}
+	if received := dumpArchiveTestDB(db, t); !reflect.DeepEqual(
+		expected, received) {
+		t.Errorf("Unexpected values.\nexpected: %v\nreceived: %v",
+			expected, received)
+	}
+
+	if _, err = GetIndex(db, "messages", "channel",
+		js.ValueOf("channel1")); err != nil {
+		t.Errorf("Failed to get value by index: %+v", err)
+	}
+}
+
+// Error path: Tests that ArchiveImporter returns an error when the database
+// already exists and does not delete the existing database.
+func TestArchiveImporter_DatabaseExistsError(t *testing.T) {
+	const name = "TestArchiveImporter_DatabaseExistsError"
+	password := []byte("archivePassword")
+	closeDatabase(newArchiveTestDB(name, 5, t))
+
+	archiveData := exportTestArchive(name, password, t)
+	_, err := importTestArchive(archiveData, password, len(archiveData))
+	if err == nil {
+		t.Errorf("Did not get error when importing existing database.")
+	}
+
+	if exists, err := databaseExists(name); err != nil || !exists {
+		t.Errorf("Existing database deleted by failed import: %v", err)
+	}
+}
+
+// Error path: Tests that ExportDatabase returns an error for a database that
+// does not exist and does not leave behind an empty database.
+func TestExportDatabase_DatabaseNotExistError(t *testing.T) {
+	const name = "TestExportDatabase_DatabaseNotExistError"
+
+	for i := 0; i < 2; i++ {
+		var buf bytes.Buffer
+		err := ExportDatabase(name, []byte("archivePassword"), &buf)
+		if err == nil {
+			t.Errorf("Did not get error when exporting a database that does "+
+				"not exist (%d).", i)
+		}
+	}
+}
+
+// Error path: Tests that ArchiveImporter returns an error when the archive
+// cannot be decrypted with the password.
+func TestArchiveImporter_WrongPasswordError(t *testing.T) {
+	const name = "TestArchiveImporter_WrongPasswordError"
+	db := newArchiveTestDB(name, 5, t)
+	closeDatabase(db)
+
+	archiveData := exportTestArchive(name, []byte("archivePassword"), t)
+	if err := deleteDatabase(name); err != nil {
+		t.Fatalf("Failed to delete database: %+v", err)
+	}
+
+	_, err := importTestArchive(
+		archiveData, []byte("wrongPassword"), len(archiveData))
+	if err == nil {
+		t.Errorf("Did not get error when importing with the wrong password.")
+	}
+	assertDatabaseNotExist(name, t)
+}
+
+// Error path: Tests that ArchiveImporter.Close returns an error and deletes
+// the partially imported database when the final frame is missing.
+func TestArchiveImporter_Close_TruncatedError(t *testing.T) {
+	const name = "TestArchiveImporter_Close_TruncatedError"
+	setArchiveRecordRows(4, t)
+	password := []byte("archivePassword")
+	closeDatabase(newArchiveTestDB(name, 10, t))
+
+	frames := splitTestArchive(exportTestArchive(name, password, t), t)
+	if err := deleteDatabase(name); err != nil {
+		t.Fatalf("Failed to delete database: %+v", err)
+	}
+
+	truncated := joinTestArchive(frames[:len(frames)-1])
+	_, err := importTestArchive(truncated, password, len(truncated))
+	if !errors.Is(err, errArchiveIncomplete) {
+		t.Errorf("Unexpected error for truncated archive."+
+			"\nexpected: %v\nreceived: %+v", errArchiveIncomplete, err)
+	}
+	assertDatabaseNotExist(name, t)
+}
+
+// Error path: Tests that ArchiveImporter returns an error when two frames of
+// the archive are swapped.
+func TestArchiveImporter_Write_ReorderedError(t *testing.T) {
+	const name = "TestArchiveImporter_Write_ReorderedError"
+	setArchiveRecordRows(4, t)
+	password := []byte("archivePassword")
+	closeDatabase(newArchiveTestDB(name, 10, t))
+
+	frames := splitTestArchive(exportTestArchive(name, password, t), t)
+	if err := deleteDatabase(name); err != nil {
+		t.Fatalf("Failed to delete database: %+v", err)
+	}
+
+	frames[2], frames[3] = frames[3], frames[2]
+	reordered := joinTestArchive(frames)
+	if _, err := importTestArchive(
+		reordered, password, len(reordered)); err == nil {
+		t.Errorf("Did not get error for reordered frames.")
+	}
+	assertDatabaseNotExist(name, t)
+}
+
+// Error path: Tests that ArchiveImporter deletes the partially imported
+// database when aborted.
+func TestArchiveImporter_Abort(t *testing.T) {
+	const name = "TestArchiveImporter_Abort"
+	password := []byte("archivePassword")
+	closeDatabase(newArchiveTestDB(name, 5, t))
+
+	frames := splitTestArchive(exportTestArchive(name, password, t), t)
+	if err := deleteDatabase(name); err != nil {
+		t.Fatalf("Failed to delete database: %+v", err)
+	}
+
+	ai := NewArchiveImporter(password, registerTestArchive)
+	if _, err := ai.Write(joinTestArchive(frames[:3])); err != nil {
+		t.Fatalf("Failed to write archive: %+v", err)
+	}
+	ai.Abort()
+
+	if _, err := ai.Close(); !errors.Is(err, errImportAborted) {
+		t.Errorf("Unexpected error after abort.\nexpected: %v\nreceived: %+v",
+			errImportAborted, err)
+	}
+	assertDatabaseNotExist(name, t)
+}
+
+// Error path: Tests that ArchiveImporter.Write rejects data that does not
+// start with the archive magic bytes as soon as a byte differs, without
+// buffering the rest of the data.
+func TestArchiveImporter_Write_NotArchiveError(t *testing.T) {
+	ai := NewArchiveImporter([]byte("password"), registerTestArchive)
+	if _, err := ai.Write(archiveMagic[:4]); err != nil {
+		t.Fatalf("Failed to write start of magic bytes: %+v", err)
+	}
+
+	_, err := ai.Write([]byte(`{"version":1,"data":""}`))
+	if !errors.Is(err, errNotArchive) {
+		t.Errorf("Unexpected error.\nexpected: %v\nreceived: %+v",
+			errNotArchive, err)
+	}
+	if len(ai.buf) != 0 {
+		t.Errorf("Data kept after it was rejected: %d bytes", len(ai.buf))
+	}
+}
+
+// Error path: Tests that archiveSealer.open returns an error for a frame
+// opened at a different index or with its final flag changed.
+func Test_archiveSealer_open_ModifiedFrameError(t *testing.T) {
+	rng := csprng.NewSystemRNG()
+	header := archiveHeader{
+		Version: archiveVersion,
+		Salt:    make([]byte, archiveSaltLen),
+		KDF:     archiveKDF{Time: 1, Memory: 1024, Threads: 1},
+	}
+	headerData, _ := json.Marshal(header)
+	newSealer := func() *archiveSealer {
+		as, err := newArchiveSealer([]byte("password"), header, headerData)
+		if err != nil {
+			t.Fatalf("Failed to create sealer: %+v", err)
+		}
+		return as
+	}
+
+	sealer := newSealer()
+	record := archiveRecord{End: &archiveEnd{Rows: map[string]int{"a": 1}}}
+	first, err := sealer.seal(record, false, rng)
+	if err != nil {
+		t.Fatalf("Failed to seal record: %+v", err)
+	}
+	second, err := sealer.seal(record, false, rng)
+	if err != nil {
+		t.Fatalf("Failed to seal record: %+v", err)
+	}
+
+	opener := newSealer()
+	received, final, err := opener.open(first)
+	if err != nil {
+		t.Fatalf("Failed to open record: %+v", err)
+	} else if final || !reflect.DeepEqual(record, *received) {
+		t.Errorf("Unexpected record.\nexpected: %+v\nreceived: %+v",
+			record, *received)
+	}
+
+	if _, _, err = newSealer().open(second); err == nil {
+		t.Errorf("Did not get error for frame opened at the wrong index.")
+	}
+
+	second[0] = frameFinal
+	if _, _, err = opener.open(second); err == nil {
+		t.Errorf("Did not get error for frame with a modified final flag.")
+	}
+}
+
+// Tests that ArchiveImporter registers the database before any of its values
+// are written, so it is registered even if the import fails partway.
+func TestArchiveImporter_Register(t *testing.T) {
+	const name = "TestArchiveImporter_Register"
+	setArchiveRecordRows(5, t)
+	db := newArchiveTestDB(name, 25, t)
+	closeDatabase(db)
+
+	password := []byte("password")
+	frames := splitTestArchive(exportTestArchive(name, password, t), t)
+	if err := deleteDatabase(name); err != nil {
+		t.Fatalf("Failed to delete database: %+v", err)
+	}
+
+	var registered []string
+	ai := NewArchiveImporter(password, func(databaseName string) error {
+		exists, err := databaseExists(databaseName)
+		if err != nil {
+			return err
+		} else if exists {
+			t.Errorf("Database %s created before it was registered.",
+				databaseName)
+		}
+		registered = append(registered, databaseName)
+		return nil
+	})
+
+	// Write the header, database, and first page of rows and then abort
+	if _, err := ai.Write(joinTestArchive(frames[:3])); err != nil {
+		t.Fatalf("Failed to write archive: %+v", err)
+	}
+	ai.Abort()
+
+	if !reflect.DeepEqual([]string{name}, registered) {
+		t.Errorf("Unexpected registered databases."+
+			"\nexpected: %q\nreceived: %q", []string{name}, registered)
+	}
+	assertDatabaseNotExist(name, t)
+
+	// The import fails without creating the database if it cannot be
+	// registered
+	registerErr := errors.New("register error")
+	ai = NewArchiveImporter(password, func(string) error { return registerErr })
+	if _, err := ai.Write(joinTestArchive(frames)); !errors.Is(err, registerErr) {
+		t.Errorf("Unexpected error.\nexpected: %v\nreceived: %+v",
+			registerErr, err)
+	}
+	assertDatabaseNotExist(name, t)
+}
@@ -0,0 +1,202 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+// This file contains the IndexedDB implementation of [model.Backend], which
+// runs the storage-agnostic event models on IndexedDB.
+
+package impl
+
+import (
+	"encoding/json"
+	"strings"
+	"syscall/js"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/pkg/errors"
+
+	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/model"
+)
+
+// errConstraint is the name of the DOMException thrown when a put breaks the
+// uniqueness of a key.
+const errConstraint = "ConstraintError"
+
+// backend is a [model.Backend] on an IndexedDB database.
+type backend struct {
+	db *idb.Database
+}
+
+// NewBackend returns a [model.Backend] that stores values in the database. The
+// database must have been created with the object stores of the
+// [model.Schema] (see CreateSchema).
+func NewBackend(db *idb.Database) model.Backend {
+	return &backend{db: db}
+}
+
+// CreateSchema creates the object stores and indexes of the [model.Schema] in
+// the database. It must be called from the upgrade function of Open.
+func CreateSchema(db *idb.Database, schema model.Schema) error {
+	for _, s := range schema.Stores {
+		store, err := db.CreateObjectStore(s.Name, idb.ObjectStoreOptions{
+			KeyPath:       js.ValueOf(s.KeyPath),
+			AutoIncrement: s.AutoIncrement,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create object store %q", s.Name)
+		}
+		for _, index := range s.Indexes {
+			_, err = store.CreateIndex(index.Name, js.ValueOf(index.KeyPath),
+				idb.IndexOptions{Unique: index.Unique, MultiEntry: false})
+			if err != nil {
+				return errors.Wrapf(err, "failed to create index %q of %q",
+					index.Name, s.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Transact runs fn inside a single read-write transaction spanning the given
+// object stores (see Transact).
+func (b *backend) Transact(
+	storeNames []string, fn func(tx model.Tx) error) error {
+	return Transact(b.db, storeNames, func(txn *Transaction) error {
+		return fn(&backendTx{txn})
+	})
+}
+
+// backendTx is the [model.Tx] of a backend.
+type backendTx struct {
+	txn *Transaction
+}
+
+// Get returns the JSON of the value with the primary key.
+func (tx *backendTx) Get(storeName string, key any) ([]byte, error) {
+	k, err := keyToJS(key)
+	if err != nil {
+		return nil, err
+	}
+	value, err := tx.txn.Get(storeName, k)
+	if err != nil {
+		return nil, modelError(err)
+	}
+	return []byte(utils.JsToJson(value)), nil
+}
+
+// GetIndex returns the JSON of the first value with the key in the index.
+func (tx *backendTx) GetIndex(
+	storeName, indexName string, key any) ([]byte, error) {
+	k, err := keyToJS(key)
+	if err != nil {
+		return nil, err
+	}
+	value, err := tx.txn.GetIndex(storeName, indexName, k)
+	if err != nil {
+		return nil, modelError(err)
+	}
+	return []byte(utils.JsToJson(value)), nil
+}
+
+// GetAll returns the JSON of every value in ascending order of primary key.
+func (tx *backendTx) GetAll(storeName string) ([][]byte, error) {
+	values, err := tx.txn.GetAll(storeName)
+	if err != nil {
+		return nil, err
+	}
+	return valuesToJSON(values), nil
+}
+
+// GetAllIndex returns the JSON of every value with the key in the index.
+func (tx *backendTx) GetAllIndex(
+	storeName, indexName string, key any) ([][]byte, error) {
+	k, err := keyToJS(key)
+	if err != nil {
+		return nil, err
+	}
+	values, err := tx.txn.GetAllIndex(storeName, indexName, k)
+	if err != nil {
+		return nil, err
+	}
+	return valuesToJSON(values), nil
+}
+
+// Put inserts or replaces the JSON value. Returns the JSON of its primary key.
+func (tx *backendTx) Put(
+	storeName string, value []byte) (json.RawMessage, error) {
+	obj, err := utils.JsonToJS(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "value is not a JSON object")
+	}
+	key, err := tx.txn.Put(storeName, obj)
+	if err != nil {
+		return nil, modelError(err)
+	}
+	return json.RawMessage(utils.JsToJson(key)), nil
+}
+
+// Delete removes the value with the primary key.
+func (tx *backendTx) Delete(storeName string, key any) error {
+	k, err := keyToJS(key)
+	if err != nil {
+		return err
+	}
+	return tx.txn.Delete(storeName, k)
+}
+
+// DeleteAllIndex removes every value with the key in the index.
+func (tx *backendTx) DeleteAllIndex(
+	storeName, indexName string, key any) (int, error) {
+	k, err := keyToJS(key)
+	if err != nil {
+		return 0, err
+	}
+	return tx.txn.DeleteAllIndex(storeName, indexName, k)
+}
+
+// keyToJS converts the key into the Javascript value it is stored as, which
+// is the value of its JSON.
+func keyToJS(key any) (js.Value, error) {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return js.Undefined(), errors.Wrap(err, "failed to marshal key")
+	}
+	var v any
+	if err = json.Unmarshal(data, &v); err != nil {
+		return js.Undefined(), errors.Wrap(err, "failed to unmarshal key")
+	}
+	switch v.(type) {
+	case string, float64:
+		return js.ValueOf(v), nil
+	default:
+		return js.Undefined(), errors.Errorf("invalid key %s", data)
+	}
+}
+
+// valuesToJSON returns the JSON of each value.
+func valuesToJSON(values []js.Value) [][]byte {
+	data := make([][]byte, len(values))
+	for i, value := range values {
+		data[i] = []byte(utils.JsToJson(value))
+	}
+	return data
+}
+
+// modelError wraps the error in the matching [model] error, if there is one,
+// so that it can be identified with errors.Is.
+func modelError(err error) error {
+	switch {
+	case strings.Contains(err.Error(), ErrDoesNotExist):
+		return errors.Wrap(model.ErrNotFound, err.Error())
+	case strings.Contains(err.Error(), errConstraint):
+		return errors.Wrap(model.ErrConstraint, err.Error())
+	default:
+		return err
+	}
+}
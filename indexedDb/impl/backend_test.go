@@ -0,0 +1,47 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"testing"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/model"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/model/modeltest"
+)
+
+// newTestBackend opens a new database named after the test with the object
+// stores of the schema and returns its model.Backend.
+func newTestBackend(t *testing.T, schema model.Schema) model.Backend {
+	db, err := Open(t.Name(), 1, func(db *idb.Database, _, _ uint) error {
+		return CreateSchema(db, schema)
+	})
+	if err != nil {
+		t.Fatalf("Failed to open database: %+v", err)
+	}
+	return NewBackend(db)
+}
+
+// Tests that the IndexedDB backend passes the backend tests.
+func TestBackend(t *testing.T) {
+	modeltest.RunBackendTests(t, newTestBackend)
+}
+
+// Tests that the channels event model passes its tests on the IndexedDB
+// backend.
+func TestBackend_Channels(t *testing.T) {
+	modeltest.RunChannelsTests(t, newTestBackend)
+}
+
+// Tests that the DM event model passes its tests on the IndexedDB backend.
+func TestBackend_DM(t *testing.T) {
+	modeltest.RunDMTests(t, newTestBackend)
+}
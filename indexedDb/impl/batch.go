@@ -0,0 +1,199 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+const (
+	// DefaultBatchWindow is the default time a WriteBatcher waits for more
+	// writes after the first write of a batch is added.
+	DefaultBatchWindow = 10 * time.Millisecond
+
+	// DefaultMaxBatchSize is the default maximum number of values written by a
+	// WriteBatcher in a single transaction.
+	DefaultMaxBatchSize = 500
+)
+
+// BatchedWrite is a single value waiting to be written by a WriteBatcher.
+type BatchedWrite struct {
+	// Value is the object put into the object store.
+	Value js.Value
+
+	// Fallback writes the value on its own. It is called instead of writing
+	// the value in a batch when the batch transaction fails (e.g., on a
+	// constraint error). It returns the primary key of the stored object.
+	Fallback func() (js.Value, error)
+
+	// Related, if set, returns the values written to the related object stores
+	// of the WriteBatcher in the same transaction as the value, given the
+	// primary key of the stored object. An error aborts the whole batch.
+	Related func(key js.Value) ([]RelatedValue, error)
+
+	// Done is called once the value is written with the primary key of the
+	// stored object or the error that occurred.
+	Done func(key js.Value, err error)
+}
+
+// RelatedValue is a value written to another object store in the same
+// transaction as a BatchedWrite.
+type RelatedValue struct {
+	ObjectStoreName string
+	Value           js.Value
+}
+
+// WriteBatcher coalesces the values written to an [idb.ObjectStore] within a
+// short window into a single multi-row transaction. When a transaction fails,
+// each value in the batch is written on its own using its fallback so that one
+// bad value does not fail the others.
+//
+// If the WriteBatcher has related object stores, the transaction also spans
+// them so that the values returned by BatchedWrite.Related are committed
+// together with the values they belong to.
+//
+// Within a batch, Done callbacks are called sequentially in the order the
+// writes were added.
+type WriteBatcher struct {
+	db                *idb.Database
+	objectStoreName   string
+	relatedStoreNames []string
+	window            time.Duration
+	maxSize           int
+
+	pending []*BatchedWrite
+	timer   *time.Timer
+	mux     sync.Mutex
+}
+
+// NewWriteBatcher returns a new WriteBatcher for the object store. A batch is
+// written once window has passed since its first write was added or once it
+// reaches maxSize writes, whichever comes first. Values returned by
+// BatchedWrite.Related may only be written to the relatedStoreNames.
+func NewWriteBatcher(db *idb.Database, objectStoreName string,
+	window time.Duration, maxSize int,
+	relatedStoreNames ...string) *WriteBatcher {
+	return &WriteBatcher{
+		db:                db,
+		objectStoreName:   objectStoreName,
+		relatedStoreNames: relatedStoreNames,
+		window:            window,
+		maxSize:           maxSize,
+	}
+}
+
+// Add adds the write to the current batch. It returns immediately; the Done
+// callback of the write is called once the batch is written.
+func (wb *WriteBatcher) Add(write *BatchedWrite) {
+	wb.mux.Lock()
+	defer wb.mux.Unlock()
+
+	wb.pending = append(wb.pending, write)
+	if len(wb.pending) >= wb.maxSize {
+		go wb.write(wb.takePending())
+	} else if wb.timer == nil {
+		wb.timer = time.AfterFunc(wb.window, wb.Flush)
+	}
+}
+
+// Flush writes all pending writes immediately. It blocks until every Done
+// callback has been called.
+func (wb *WriteBatcher) Flush() {
+	wb.mux.Lock()
+	batch := wb.takePending()
+	wb.mux.Unlock()
+
+	wb.write(batch)
+}
+
+// takePending removes and returns the pending writes and stops the timer.
+//
+// This function is not thread safe. It must be called under the lock.
+func (wb *WriteBatcher) takePending() []*BatchedWrite {
+	if wb.timer != nil {
+		wb.timer.Stop()
+		wb.timer = nil
+	}
+	batch := wb.pending
+	wb.pending = nil
+	return batch
+}
+
+// write puts every value in the batch in a single transaction. If the
+// transaction fails, each value is written on its own with its fallback.
+func (wb *WriteBatcher) write(batch []*BatchedWrite) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var keys []js.Value
+	var err error
+	if len(wb.relatedStoreNames) == 0 {
+		values := make([]js.Value, len(batch))
+		for i, w := range batch {
+			values[i] = w.Value
+		}
+		keys, err = PutBatch(wb.db, wb.objectStoreName, values)
+	} else {
+		keys, err = wb.putRelated(batch)
+	}
+	if err != nil {
+		jww.WARN.Printf("Failed to write batch of %d values to %s; falling "+
+			"back to writing each value on its own: %+v",
+			len(batch), wb.objectStoreName, err)
+		for _, w := range batch {
+			w.Done(w.Fallback())
+		}
+		return
+	}
+
+	for i, w := range batch {
+		w.Done(keys[i], nil)
+	}
+}
+
+// putRelated puts every value in the batch and its related values in a single
+// transaction spanning the object store and its related object stores.
+func (wb *WriteBatcher) putRelated(batch []*BatchedWrite) ([]js.Value, error) {
+	objectStoreNames := append(
+		[]string{wb.objectStoreName}, wb.relatedStoreNames...)
+
+	var keys []js.Value
+	err := Transact(wb.db, objectStoreNames, func(txn *Transaction) error {
+		keys = make([]js.Value, len(batch))
+		for i, w := range batch {
+			key, err := txn.Put(wb.objectStoreName, w.Value)
+			if err != nil {
+				return err
+			}
+			keys[i] = key
+
+			if w.Related == nil {
+				continue
+			}
+			related, err := w.Related(key)
+			if err != nil {
+				return err
+			}
+			for _, rv := range related {
+				if _, err = txn.Put(rv.ObjectStoreName, rv.Value); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return keys, err
+}
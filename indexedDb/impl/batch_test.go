@@ -0,0 +1,224 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"strconv"
+	"sync"
+	"syscall/js"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/pkg/errors"
+)
+
+// newBatchTestDB creates a database with an auto-incremented object store with
+// a unique index on "name" and an auto-incremented related object store.
+func newBatchTestDB(name string, t *testing.T) *idb.Database {
+	ctx, cancel := NewContext()
+	defer cancel()
+	openRequest, err := idb.Global().Open(ctx, name, 1,
+		func(db *idb.Database, _ uint, _ uint) error {
+			store, err := db.CreateObjectStore("messages",
+				idb.ObjectStoreOptions{
+					KeyPath:       js.ValueOf("id"),
+					AutoIncrement: true,
+				})
+			if err != nil {
+				return err
+			}
+			_, err = store.CreateIndex("name",
+				js.ValueOf("name"), idb.IndexOptions{Unique: true})
+			if err != nil {
+				return err
+			}
+			_, err = db.CreateObjectStore("related",
+				idb.ObjectStoreOptions{AutoIncrement: true})
+			return err
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openRequest.Await(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// addBatchTestWrites adds a write for each name to the WriteBatcher
+// concurrently and returns the keys and errors passed to Done, in the order of
+// the names. The fallback of each write puts the value on its own.
+func addBatchTestWrites(db *idb.Database, wb *WriteBatcher,
+	names []string) ([]js.Value, []error) {
+	keys := make([]js.Value, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		value := js.ValueOf(map[string]any{"name": name})
+		i := i
+		wb.Add(&BatchedWrite{
+			Value: value,
+			Fallback: func() (js.Value, error) {
+				return Put(db, "messages", value)
+			},
+			Done: func(key js.Value, err error) {
+				keys[i], errs[i] = key, err
+				wg.Done()
+			},
+		})
+	}
+	wg.Wait()
+	return keys, errs
+}
+
+// Tests that WriteBatcher writes all values added within the window and calls
+// Done with the primary key of each stored value.
+func TestWriteBatcher_Add(t *testing.T) {
+	db := newBatchTestDB("TestWriteBatcher_Add", t)
+	wb := NewWriteBatcher(db, "messages", 50*time.Millisecond, 100)
+
+	names := make([]string, 25)
+	for i := range names {
+		names[i] = "name" + strconv.Itoa(i)
+	}
+	keys, errs := addBatchTestWrites(db, wb, names)
+
+	for i, key := range keys {
+		if errs[i] != nil {
+			t.Errorf("Failed to write value %d: %+v", i, errs[i])
+			continue
+		}
+		value, err := Get(db, "messages", key)
+		if err != nil {
+			t.Errorf("Failed to get value %d: %+v", i, err)
+		} else if name := value.Get("name").String(); name != names[i] {
+			t.Errorf("Unexpected value for key %d.\nexpected: %s\nreceived: %s",
+				i, names[i], name)
+		}
+	}
+
+	if count, err := Count(db, "messages"); err != nil {
+		t.Fatalf("Failed to count values: %+v", err)
+	} else if count != len(names) {
+		t.Errorf("Unexpected number of values.\nexpected: %d\nreceived: %d",
+			len(names), count)
+	}
+}
+
+// Tests that WriteBatcher writes a batch once it reaches the max size without
+// waiting for the window to pass.
+func TestWriteBatcher_Add_MaxSize(t *testing.T) {
+	db := newBatchTestDB("TestWriteBatcher_Add_MaxSize", t)
+	wb := NewWriteBatcher(db, "messages", time.Hour, 5)
+
+	done := make(chan struct{})
+	go func() {
+		addBatchTestWrites(db, wb, []string{"a", "b", "c", "d", "e"})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for full batch to be written.")
+	}
+}
+
+// Tests that when a batch fails on a constraint error, WriteBatcher falls back
+// to writing each value on its own so that only the invalid value fails.
+func TestWriteBatcher_Add_ConstraintFallback(t *testing.T) {
+	db := newBatchTestDB("TestWriteBatcher_Add_ConstraintFallback", t)
+	if _, err := Put(db, "messages",
+		js.ValueOf(map[string]any{"name": "b"})); err != nil {
+		t.Fatalf("Failed to put existing value: %+v", err)
+	}
+	wb := NewWriteBatcher(db, "messages", 50*time.Millisecond, 100)
+
+	_, errs := addBatchTestWrites(db, wb, []string{"a", "b", "c"})
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf("Failed to write valid values: %v, %v", errs[0], errs[2])
+	}
+	if errs[1] == nil {
+		t.Errorf("Did not get error for value violating unique index.")
+	}
+
+	if count, err := Count(db, "messages"); err != nil {
+		t.Fatalf("Failed to count values: %+v", err)
+	} else if count != 3 {
+		t.Errorf("Unexpected number of values.\nexpected: %d\nreceived: %d",
+			3, count)
+	}
+}
+
+// Tests that WriteBatcher writes the related values of each write in the same
+// transaction and that an error from Related aborts the batch, so that none of
+// its related values are stored.
+func TestWriteBatcher_Add_Related(t *testing.T) {
+	db := newBatchTestDB("TestWriteBatcher_Add_Related", t)
+	wb := NewWriteBatcher(
+		db, "messages", 50*time.Millisecond, 100, "related")
+
+	add := func(name string, fail bool, wg *sync.WaitGroup) {
+		value := js.ValueOf(map[string]any{"name": name})
+		wb.Add(&BatchedWrite{
+			Value: value,
+			Fallback: func() (js.Value, error) {
+				return Put(db, "messages", value)
+			},
+			Related: func(key js.Value) ([]RelatedValue, error) {
+				if fail {
+					return nil, errors.New("related error")
+				}
+				return []RelatedValue{{"related", key}}, nil
+			},
+			Done: func(key js.Value, err error) {
+				if err != nil {
+					t.Errorf("Failed to write %s: %+v", name, err)
+				}
+				wg.Done()
+			},
+		})
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	add("a", false, &wg)
+	add("b", false, &wg)
+	wg.Wait()
+
+	related, err := GetAll(db, "related")
+	if err != nil {
+		t.Fatalf("Failed to get related values: %+v", err)
+	} else if len(related) != 2 {
+		t.Errorf("Unexpected number of related values."+
+			"\nexpected: %d\nreceived: %d", 2, len(related))
+	}
+
+	wg.Add(2)
+	add("c", false, &wg)
+	add("d", true, &wg)
+	wg.Wait()
+
+	if related, err = GetAll(db, "related"); err != nil {
+		t.Fatalf("Failed to get related values: %+v", err)
+	} else if len(related) != 2 {
+		t.Errorf("Related values of aborted batch were stored."+
+			"\nexpected: %d\nreceived: %d", 2, len(related))
+	}
+	if count, err := Count(db, "messages"); err != nil {
+		t.Fatalf("Failed to count values: %+v", err)
+	} else if count != 4 {
+		t.Errorf("Unexpected number of values.\nexpected: %d\nreceived: %d",
+			4, count)
+	}
+}
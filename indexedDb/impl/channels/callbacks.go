@@ -11,6 +11,7 @@ package main
 
 import (
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -20,9 +21,9 @@ import (
 	"gitlab.com/elixxir/client/v4/cmix/rounds"
 	cryptoBroadcast "gitlab.com/elixxir/crypto/broadcast"
 	"gitlab.com/elixxir/crypto/fastRNG"
-	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
 	"gitlab.com/elixxir/crypto/message"
 	"gitlab.com/elixxir/wasm-utils/exception"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
 	wChannels "gitlab.com/elixxir/xxdk-wasm/indexedDb/worker/channels"
 	"gitlab.com/elixxir/xxdk-wasm/worker"
 	"gitlab.com/xx_network/crypto/csprng"
@@ -36,22 +37,72 @@ var zeroUUID = []byte{0, 0, 0, 0, 0, 0, 0, 0}
 type manager struct {
 	wtm   *worker.ThreadManager
 	model channels.EventModel
+
+	// order keeps the callbacks in the order their messages are received.
+	// Concurrent callbacks hold a read lock and all others the write lock.
+	order sync.RWMutex
 }
 
 // registerCallbacks registers all the reception callbacks to manage messages
 // from the main thread for the channels.EventModel.
 func (m *manager) registerCallbacks() {
-	m.wtm.RegisterCallback(wChannels.NewWASMEventModelTag, m.newWASMEventModelCB)
-	m.wtm.RegisterCallback(wChannels.JoinChannelTag, m.joinChannelCB)
-	m.wtm.RegisterCallback(wChannels.LeaveChannelTag, m.leaveChannelCB)
-	m.wtm.RegisterCallback(wChannels.ReceiveMessageTag, m.receiveMessageCB)
-	m.wtm.RegisterCallback(wChannels.ReceiveReplyTag, m.receiveReplyCB)
-	m.wtm.RegisterCallback(wChannels.ReceiveReactionTag, m.receiveReactionCB)
-	m.wtm.RegisterCallback(wChannels.UpdateFromUUIDTag, m.updateFromUuidCB)
-	m.wtm.RegisterCallback(wChannels.UpdateFromMessageIDTag, m.updateFromMessageIdCB)
-	m.wtm.RegisterCallback(wChannels.GetMessageTag, m.getMessageCB)
-	m.wtm.RegisterCallback(wChannels.DeleteMessageTag, m.deleteMessageCB)
-	m.wtm.RegisterCallback(wChannels.MuteUserTag, m.muteUserCB)
+	m.register(wChannels.NewWASMEventModelTag, m.newWASMEventModelCB)
+	m.register(wChannels.JoinChannelTag, m.joinChannelCB)
+	m.register(wChannels.LeaveChannelTag, m.leaveChannelCB)
+	m.registerConcurrent(wChannels.ReceiveMessageTag, m.receiveMessageCB)
+	m.registerConcurrent(wChannels.ReceiveReplyTag, m.receiveReplyCB)
+	m.registerConcurrent(wChannels.ReceiveReactionTag, m.receiveReactionCB)
+	m.register(wChannels.UpdateFromUUIDTag, m.updateFromUuidCB)
+	m.register(wChannels.UpdateFromMessageIDTag, m.updateFromMessageIdCB)
+	m.register(wChannels.GetMessageTag, m.getMessageCB)
+	m.register(wChannels.DeleteMessageTag, m.deleteMessageCB)
+	m.register(wChannels.MuteUserTag, m.muteUserCB)
+	m.register(wChannels.RotateKeyTag, m.rotateKeyCB)
+	m.register(wChannels.ExportHistoryTag, m.exportHistoryCB)
+	m.register(wChannels.CheckDatabaseTag, m.checkDatabaseCB)
+	m.register(wChannels.GetStorageUsageTag, m.getStorageUsageCB)
+	m.register(wChannels.GetChannelsTag, m.getChannelsCB)
+	m.register(wChannels.UpdateChannelMetadataTag, m.updateChannelMetadataCB)
+	m.register(wChannels.GetMessagesBySenderTag, m.getMessagesBySenderCB)
+	m.register(wChannels.HideMessagesBySenderTag, m.hideMessagesBySenderCB)
+	m.register(wChannels.ReceiveEditTag, m.receiveEditCB)
+	m.register(wChannels.SaveDraftTag, m.saveDraftCB)
+	m.register(wChannels.GetDraftTag, m.getDraftCB)
+	m.register(wChannels.ClearDraftTag, m.clearDraftCB)
+	m.register(wChannels.SearchMessagesTag, m.searchMessagesCB)
+}
+
+// register registers the callback so that it runs only once every callback
+// for an earlier message has returned and before any callback for a later
+// message starts.
+func (m *manager) register(tag worker.Tag, cb worker.ReceiverCallback) {
+	m.wtm.RegisterCallback(tag, func(message []byte, reply func([]byte)) {
+		m.order.Lock()
+		defer m.order.Unlock()
+		cb(message, reply)
+	})
+}
+
+// registerConcurrent registers a receive callback that runs in its own
+// goroutine. This allows the worker to receive further messages while the
+// callback waits on the write batch so that their inserts are coalesced into
+// one transaction.
+//
+// Concurrent callbacks only overlap each other. They start once every earlier
+// callback registered with register has returned, and later ones wait for them
+// to return, so an update or delete is never applied before the insert it
+// refers to.
+func (m *manager) registerConcurrent(
+	tag worker.Tag, cb worker.ReceiverCallback) {
+	m.wtm.RegisterCallback(tag, func(message []byte, reply func([]byte)) {
+		// The lock is acquired before starting the goroutine so that messages
+		// are ordered by when they were received rather than by scheduling
+		m.order.RLock()
+		go func() {
+			defer m.order.RUnlock()
+			cb(message, reply)
+		}()
+	})
 }
 
 // newWASMEventModelCB is the callback for NewWASMEventModel. Returns an empty
@@ -67,7 +118,7 @@ func (m *manager) newWASMEventModelCB(message []byte, reply func(message []byte)
 
 	// Create new encryption cipher
 	rng := fastRNG.NewStreamGenerator(12, 1024, csprng.NewSystemRNG)
-	encryption, err := idbCrypto.NewCipherFromJSON(
+	encryption, err := impl.NewCipherFromJSON(
 		[]byte(msg.EncryptionJSON), rng.GetStream())
 	if err != nil {
 		reply([]byte(errors.Wrap(err,
@@ -75,8 +126,10 @@ func (m *manager) newWASMEventModelCB(message []byte, reply func(message []byte)
 		return
 	}
 
-	m.model, err = NewWASMEventModel(
-		msg.DatabaseName, encryption, m.eventUpdateCallback)
+	msg.StorageParams.Apply()
+
+	m.model, err = NewWASMEventModel(msg.DatabaseName, encryption,
+		msg.ExtendedEncryption, m.eventUpdateCallback)
 	if err != nil {
 		reply([]byte(err.Error()))
 		return
@@ -371,3 +424,441 @@ func (m *manager) muteUserCB(message []byte, _ func([]byte)) {
 	}
 	m.model.MuteUser(msg.ChannelID, msg.PubKey, msg.Unmute)
 }
+
+// rotateKeyCB is the callback for wasmModel.RotateKey. Returns an empty slice
+// on success or an error message on failure.
+func (m *manager) rotateKeyCB(message []byte, reply func(message []byte)) {
+	rng := fastRNG.NewStreamGenerator(12, 1024, csprng.NewSystemRNG)
+	encryption, err := impl.NewCipherFromJSON(message, rng.GetStream())
+	if err != nil {
+		reply([]byte(errors.Wrap(err,
+			"failed to JSON unmarshal Cipher from main thread").Error()))
+		return
+	}
+
+	kr, ok := encryption.(*impl.KeyRing)
+	if !ok {
+		reply([]byte(errors.Errorf(
+			"cannot rotate key of cipher %T", encryption).Error()))
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		reply([]byte(errors.Errorf(
+			"cannot rotate key of event model %T", m.model).Error()))
+		return
+	}
+
+	model.rotateKey(kr)
+	reply(nil)
+}
+
+// exportHistoryCB is the callback for wasmModel.ExportHistory. Returns JSON
+// marshalled wChannels.ExportHistoryReply. If an error occurs, then Error will be
+// set with the error message. Otherwise, Data will be set.
+func (m *manager) exportHistoryCB(message []byte, reply func(message []byte)) {
+	var replyMsg wChannels.ExportHistoryReply
+	defer func() {
+		if replyMessage, err := json.Marshal(replyMsg); err != nil {
+			exception.Throwf("[CH] Failed to JSON marshal %T for "+
+				"ExportHistory: %+v", replyMsg, err)
+		} else {
+			reply(replyMessage)
+		}
+	}()
+
+	var msg wChannels.ExportHistoryMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		replyMsg.Error = errors.Errorf("failed to JSON unmarshal %T from "+
+			"main thread: %+v", msg, err).Error()
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		replyMsg.Error = errors.Errorf(
+			"cannot export history of event model %T", m.model).Error()
+		return
+	}
+
+	data, err := model.ExportHistory(msg.ChannelID, msg.Start, msg.End,
+		impl.HistoryFormat(msg.Format))
+	if err != nil {
+		replyMsg.Error = err.Error()
+	} else {
+		replyMsg.Data = data
+	}
+}
+
+// checkDatabaseCB is the callback for wasmModel.CheckDatabase. Returns JSON
+// marshalled wChannels.CheckDatabaseReply. If an error occurs, then Error will be
+// set with the error message. Otherwise, Report will be set.
+func (m *manager) checkDatabaseCB(message []byte, reply func(message []byte)) {
+	var replyMsg wChannels.CheckDatabaseReply
+	defer func() {
+		if replyMessage, err := json.Marshal(replyMsg); err != nil {
+			exception.Throwf("[CH] Failed to JSON marshal %T for "+
+				"CheckDatabase: %+v", replyMsg, err)
+		} else {
+			reply(replyMessage)
+		}
+	}()
+
+	var msg wChannels.CheckDatabaseMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		replyMsg.Error = errors.Errorf("failed to JSON unmarshal %T from "+
+			"main thread: %+v", msg, err).Error()
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		replyMsg.Error = errors.Errorf(
+			"cannot check database of event model %T", m.model).Error()
+		return
+	}
+
+	report, err := model.CheckDatabase(msg.Repair)
+	if err != nil {
+		replyMsg.Error = err.Error()
+		return
+	}
+	if replyMsg.Report, err = json.Marshal(report); err != nil {
+		replyMsg.Error = errors.Errorf(
+			"failed to JSON marshal report: %+v", err).Error()
+	}
+}
+
+// getStorageUsageCB is the callback for wasmModel.GetStorageUsage. Returns JSON
+// marshalled wChannels.GetStorageUsageReply. If an error occurs, then Error will be
+// set with the error message. Otherwise, Usage will be set.
+func (m *manager) getStorageUsageCB(_ []byte, reply func(message []byte)) {
+	var replyMsg wChannels.GetStorageUsageReply
+	defer func() {
+		if replyMessage, err := json.Marshal(replyMsg); err != nil {
+			exception.Throwf("[CH] Failed to JSON marshal %T for "+
+				"GetStorageUsage: %+v", replyMsg, err)
+		} else {
+			reply(replyMessage)
+		}
+	}()
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		replyMsg.Error = errors.Errorf(
+			"cannot get storage usage of event model %T", m.model).Error()
+		return
+	}
+
+	usage, err := model.GetStorageUsage()
+	if err != nil {
+		replyMsg.Error = err.Error()
+		return
+	}
+	if replyMsg.Usage, err = json.Marshal(usage); err != nil {
+		replyMsg.Error = errors.Errorf(
+			"failed to JSON marshal usage: %+v", err).Error()
+	}
+}
+
+// getChannelsCB is the callback for wasmModel.GetChannels. Returns JSON
+// marshalled wChannels.GetChannelsReply. If an error occurs, then Error will be
+// set with the error message. Otherwise, Channels will be set.
+func (m *manager) getChannelsCB(_ []byte, reply func(message []byte)) {
+	var replyMsg wChannels.GetChannelsReply
+	defer func() {
+		if replyMessage, err := json.Marshal(replyMsg); err != nil {
+			exception.Throwf("[CH] Failed to JSON marshal %T for "+
+				"GetChannels: %+v", replyMsg, err)
+		} else {
+			reply(replyMessage)
+		}
+	}()
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		replyMsg.Error = errors.Errorf(
+			"cannot get channels of event model %T", m.model).Error()
+		return
+	}
+
+	channelList, err := model.GetChannels()
+	if err != nil {
+		replyMsg.Error = err.Error()
+		return
+	}
+	if replyMsg.Channels, err = json.Marshal(channelList); err != nil {
+		replyMsg.Error = errors.Errorf(
+			"failed to JSON marshal channels: %+v", err).Error()
+	}
+}
+
+// updateChannelMetadataCB is the callback for wasmModel.UpdateChannelMetadata.
+// Returns an empty slice on success or an error message on failure.
+func (m *manager) updateChannelMetadataCB(
+	message []byte, reply func(message []byte)) {
+	var msg wChannels.UpdateChannelMetadataMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		reply([]byte(errors.Errorf("failed to JSON unmarshal %T from main "+
+			"thread: %+v", msg, err).Error()))
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		reply([]byte(errors.Errorf(
+			"cannot update channel of event model %T", m.model).Error()))
+		return
+	}
+
+	err := model.UpdateChannelMetadata(
+		msg.ChannelID, msg.IsAdmin, msg.NotificationLevel)
+	if err != nil {
+		reply([]byte(err.Error()))
+		return
+	}
+	reply(nil)
+}
+
+// getMessagesBySenderCB is the callback for wasmModel.GetMessagesBySender.
+// Returns JSON marshalled wChannels.GetMessagesBySenderReply. If an error
+// occurs, then Error will be set with the error message. Otherwise, Messages
+// will be set.
+func (m *manager) getMessagesBySenderCB(
+	message []byte, reply func(message []byte)) {
+	var replyMsg wChannels.GetMessagesBySenderReply
+	defer func() {
+		if replyMessage, err := json.Marshal(replyMsg); err != nil {
+			exception.Throwf("[CH] Failed to JSON marshal %T for "+
+				"GetMessagesBySender: %+v", replyMsg, err)
+		} else {
+			reply(replyMessage)
+		}
+	}()
+
+	var msg wChannels.GetMessagesBySenderMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		replyMsg.Error = errors.Errorf("failed to JSON unmarshal %T from "+
+			"main thread: %+v", msg, err).Error()
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		replyMsg.Error = errors.Errorf(
+			"cannot get messages by sender of event model %T", m.model).Error()
+		return
+	}
+
+	messages, err := model.GetMessagesBySender(msg.ChannelID, msg.PubKey)
+	if err != nil {
+		replyMsg.Error = err.Error()
+	} else {
+		replyMsg.Messages = messages
+	}
+}
+
+// hideMessagesBySenderCB is the callback for wasmModel.HideMessagesBySender.
+// Returns JSON marshalled wChannels.HideMessagesBySenderReply. If an error
+// occurs, then Error will be set with the error message. Otherwise, Count will
+// be set.
+func (m *manager) hideMessagesBySenderCB(
+	message []byte, reply func(message []byte)) {
+	var replyMsg wChannels.HideMessagesBySenderReply
+	defer func() {
+		if replyMessage, err := json.Marshal(replyMsg); err != nil {
+			exception.Throwf("[CH] Failed to JSON marshal %T for "+
+				"HideMessagesBySender: %+v", replyMsg, err)
+		} else {
+			reply(replyMessage)
+		}
+	}()
+
+	var msg wChannels.HideMessagesBySenderMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		replyMsg.Error = errors.Errorf("failed to JSON unmarshal %T from "+
+			"main thread: %+v", msg, err).Error()
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		replyMsg.Error = errors.Errorf(
+			"cannot hide messages of event model %T", m.model).Error()
+		return
+	}
+
+	count, err := model.HideMessagesBySender(msg.ChannelID, msg.PubKey, msg.Hide)
+	if err != nil {
+		replyMsg.Error = err.Error()
+	} else {
+		replyMsg.Count = count
+	}
+}
+
+// receiveEditCB is the callback for wasmModel.ReceiveEdit. Returns JSON
+// marshalled wChannels.ReceiveEditReply. If an error occurs, then Error will be
+// set with the error message. Otherwise, UUID will be set.
+func (m *manager) receiveEditCB(message []byte, reply func(message []byte)) {
+	var replyMsg wChannels.ReceiveEditReply
+	defer func() {
+		if replyMessage, err := json.Marshal(replyMsg); err != nil {
+			exception.Throwf("[CH] Failed to JSON marshal %T for "+
+				"ReceiveEdit: %+v", replyMsg, err)
+		} else {
+			reply(replyMessage)
+		}
+	}()
+
+	var msg wChannels.ReceiveEditMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		replyMsg.Error = errors.Errorf("failed to JSON unmarshal %T from "+
+			"main thread: %+v", msg, err).Error()
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		replyMsg.Error = errors.Errorf(
+			"cannot edit messages of event model %T", m.model).Error()
+		return
+	}
+
+	uuid, err := model.ReceiveEdit(
+		msg.ChannelID, msg.EditID, msg.PubKey, msg.Content, msg.Timestamp)
+	if err != nil {
+		replyMsg.Error = err.Error()
+	} else {
+		replyMsg.UUID = uuid
+	}
+}
+
+// saveDraftCB is the callback for wasmModel.SaveDraft. Returns an empty slice
+// on success or an error message on failure.
+func (m *manager) saveDraftCB(message []byte, reply func(message []byte)) {
+	var msg wChannels.SaveDraftMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		reply([]byte(errors.Errorf("failed to JSON unmarshal %T from main "+
+			"thread: %+v", msg, err).Error()))
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		reply([]byte(errors.Errorf(
+			"cannot save drafts in event model %T", m.model).Error()))
+		return
+	}
+
+	err := model.SaveDraft(msg.ChannelID, msg.Text, msg.ReplyTo)
+	if err != nil {
+		reply([]byte(err.Error()))
+		return
+	}
+	reply(nil)
+}
+
+// getDraftCB is the callback for wasmModel.GetDraft. Returns JSON marshalled
+// wChannels.GetDraftReply. If an error occurs, then Error will be set with the
+// error message. Otherwise, Draft will be set if there is a draft.
+func (m *manager) getDraftCB(message []byte, reply func(message []byte)) {
+	var replyMsg wChannels.GetDraftReply
+	defer func() {
+		if replyMessage, err := json.Marshal(replyMsg); err != nil {
+			exception.Throwf("[CH] Failed to JSON marshal %T for "+
+				"GetDraft: %+v", replyMsg, err)
+		} else {
+			reply(replyMessage)
+		}
+	}()
+
+	channelID, err := id.Unmarshal(message)
+	if err != nil {
+		replyMsg.Error = errors.Errorf("failed to unmarshal channel ID from "+
+			"main thread: %+v", err).Error()
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		replyMsg.Error = errors.Errorf(
+			"cannot get drafts of event model %T", m.model).Error()
+		return
+	}
+
+	draft, err := model.GetDraft(channelID)
+	if err != nil {
+		replyMsg.Error = err.Error()
+		return
+	} else if draft == nil {
+		return
+	}
+	if replyMsg.Draft, err = json.Marshal(draft); err != nil {
+		replyMsg.Error = errors.Errorf(
+			"failed to JSON marshal draft: %+v", err).Error()
+	}
+}
+
+// clearDraftCB is the callback for wasmModel.ClearDraft. Returns an empty
+// slice on success or an error message on failure.
+func (m *manager) clearDraftCB(message []byte, reply func(message []byte)) {
+	var msg wChannels.ClearDraftMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		reply([]byte(errors.Errorf("failed to JSON unmarshal %T from main "+
+			"thread: %+v", msg, err).Error()))
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		reply([]byte(errors.Errorf(
+			"cannot clear drafts in event model %T", m.model).Error()))
+		return
+	}
+
+	if err := model.ClearDraft(msg.ChannelID, msg.SavedBefore); err != nil {
+		reply([]byte(err.Error()))
+		return
+	}
+	reply(nil)
+}
+
+// searchMessagesCB is the callback for wasmModel.SearchMessages. Returns JSON
+// marshalled wChannels.SearchMessagesReply. If an error occurs, then Error
+// will be set with the error message. Otherwise, Results will be set.
+func (m *manager) searchMessagesCB(message []byte, reply func(message []byte)) {
+	var replyMsg wChannels.SearchMessagesReply
+	defer func() {
+		if replyMessage, err := json.Marshal(replyMsg); err != nil {
+			exception.Throwf("[CH] Failed to JSON marshal %T for "+
+				"SearchMessages: %+v", replyMsg, err)
+		} else {
+			reply(replyMessage)
+		}
+	}()
+
+	var msg wChannels.SearchMessagesMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		replyMsg.Error = errors.Errorf("failed to JSON unmarshal %T from "+
+			"main thread: %+v", msg, err).Error()
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		replyMsg.Error = errors.Errorf(
+			"cannot search messages of event model %T", m.model).Error()
+		return
+	}
+
+	results, err := model.SearchMessages(msg.Query, msg.ChannelID, msg.Limit)
+	if err != nil {
+		replyMsg.Error = err.Error()
+		return
+	}
+	if replyMsg.Results, err = json.Marshal(results); err != nil {
+		replyMsg.Error = errors.Errorf(
+			"failed to JSON marshal search results: %+v", err).Error()
+	}
+}
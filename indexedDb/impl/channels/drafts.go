@@ -0,0 +1,132 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"syscall/js"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/elixxir/crypto/message"
+	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// SaveDraft stores the unsent text of the channel and the message it replies
+// to, if any, replacing any existing draft. The text is encrypted with the
+// database cipher. Saving empty text that is not a reply deletes the draft.
+func (w *wasmModel) SaveDraft(
+	channelID *id.ID, text string, replyTo *message.ID) error {
+	parentErr := errors.New("failed to SaveDraft")
+
+	if text == "" && replyTo == nil {
+		return w.ClearDraft(channelID, time.Now())
+	}
+
+	draft := &Draft{
+		ID:        channelID.Marshal(),
+		Text:      text,
+		Timestamp: time.Now(),
+	}
+	if replyTo != nil {
+		draft.ReplyTo = replyTo.Marshal()
+	}
+	if w.cipher != nil {
+		var err error
+		draft.Text, err = w.cipher.Encrypt([]byte(text))
+		if err != nil {
+			return errors.WithMessage(parentErr, err.Error())
+		}
+	}
+
+	draftJson, err := json.Marshal(draft)
+	if err != nil {
+		return errors.WithMessagef(parentErr,
+			"Unable to marshal Draft: %+v", err)
+	}
+	draftObj, err := utils.JsonToJS(draftJson)
+	if err != nil {
+		return errors.WithMessagef(parentErr,
+			"Unable to marshal Draft: %+v", err)
+	}
+
+	if _, err = impl.Put(w.db, draftStoreName, draftObj); err != nil {
+		return errors.WithMessagef(parentErr, "Unable to put Draft: %+v", err)
+	}
+	return nil
+}
+
+// GetDraft returns the draft of the channel with its text decrypted or nil if
+// the channel has no draft.
+func (w *wasmModel) GetDraft(channelID *id.ID) (*Draft, error) {
+	parentErr := errors.New("failed to GetDraft")
+
+	draftObj, err := impl.Get(
+		w.db, draftStoreName, impl.EncodeBytes(channelID.Marshal()))
+	if err != nil {
+		if strings.Contains(err.Error(), impl.ErrDoesNotExist) {
+			return nil, nil
+		}
+		return nil, errors.WithMessage(parentErr, err.Error())
+	}
+
+	draft, err := valueToDraft(draftObj)
+	if err != nil {
+		return nil, errors.WithMessagef(parentErr,
+			"Unable to unmarshal Draft: %+v", err)
+	}
+	if w.cipher != nil && draft.Text != "" {
+		text, err := w.cipher.Decrypt(draft.Text)
+		if err != nil {
+			return nil, errors.WithMessage(parentErr, err.Error())
+		}
+		draft.Text = string(text)
+	}
+	return draft, nil
+}
+
+// ClearDraft deletes the draft of the channel if it was last saved before
+// savedBefore. A draft saved after it (e.g., text typed while a message was
+// being sent) is kept.
+func (w *wasmModel) ClearDraft(channelID *id.ID, savedBefore time.Time) error {
+	key := impl.EncodeBytes(channelID.Marshal())
+	err := impl.Transact(w.db, []string{draftStoreName},
+		func(txn *impl.Transaction) error {
+			draftObj, err := txn.Get(draftStoreName, key)
+			if err != nil {
+				if strings.Contains(err.Error(), impl.ErrDoesNotExist) {
+					return nil
+				}
+				return err
+			}
+			draft, err := valueToDraft(draftObj)
+			if err != nil {
+				return errors.Errorf("Unable to unmarshal Draft: %+v", err)
+			} else if !draft.Timestamp.Before(savedBefore) {
+				return nil
+			}
+			return txn.Delete(draftStoreName, key)
+		})
+	if err != nil {
+		return errors.WithMessage(err, "failed to ClearDraft")
+	}
+	return nil
+}
+
+// valueToDraft is a helper for converting js.Value to Draft.
+func valueToDraft(draftObj js.Value) (*Draft, error) {
+	resultDraft := &Draft{}
+	return resultDraft,
+		json.Unmarshal([]byte(utils.JsToJson(draftObj)), resultDraft)
+}
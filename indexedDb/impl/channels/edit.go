@@ -0,0 +1,122 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"time"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/client/v4/channels"
+	"gitlab.com/elixxir/crypto/message"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// ReceiveEdit applies the [impl.MessageEdit] in the content of the edit
+// message to the message it references. The new text replaces the text of the
+// message and is added to its edit history. Edits not sent by the original
+// sender of the message, edits of messages in other channels, and edits of
+// messages that have not been received are rejected with an error.
+//
+// Returns the UUID of the edited message. Receiving an edit already in the
+// history does not change the message.
+func (w *wasmModel) ReceiveEdit(channelID *id.ID, editID message.ID,
+	pubKey ed25519.PublicKey, content []byte, timestamp time.Time) (
+	uint64, error) {
+	parentErr := errors.New("failed to ReceiveEdit")
+
+	edit, err := impl.UnmarshalMessageEdit(content)
+	if err != nil {
+		return 0, errors.WithMessage(parentErr, err.Error())
+	}
+
+	text := edit.Text
+	if w.cipher != nil {
+		text, err = w.cipher.Encrypt([]byte(text))
+		if err != nil {
+			return 0, errors.WithMessage(parentErr, err.Error())
+		}
+	}
+
+	var uuid uint64
+	var changed bool
+	err = impl.Transact(w.db, []string{messageStoreName, searchStoreName},
+		func(txn *impl.Transaction) error {
+			msgObj, err := txn.GetIndex(messageStoreName,
+				messageStoreMessageIndex,
+				impl.EncodeBytes(edit.MessageID.Marshal()))
+			if err != nil {
+				return err
+			}
+			msg, err := valueToMessage(msgObj)
+			if err != nil {
+				return errors.WithMessage(err, "Failed to marshal Message")
+			}
+			if err = w.decryptMessageMetadata(msg); err != nil {
+				return err
+			}
+			uuid = msg.ID
+
+			if !bytes.Equal(msg.ChannelID, channelID.Marshal()) {
+				return errors.New("edited message is in another channel")
+			}
+			if err = edit.CheckEditAuthor(pubKey, msg.Pubkey); err != nil {
+				return err
+			}
+
+			original := impl.EditRecord{
+				MessageID: msg.MessageID,
+				Text:      msg.Text,
+				Timestamp: msg.Timestamp,
+			}
+			msg.EditHistory, changed = impl.AddEdit(msg.EditHistory, original,
+				impl.EditRecord{
+					MessageID: editID.Marshal(),
+					Text:      text,
+					Timestamp: timestamp,
+				})
+			if !changed {
+				return nil
+			}
+			latest := msg.EditHistory[len(msg.EditHistory)-1]
+			msg.Text = latest.Text
+
+			messageObj, err := w.newMessageObject(msg)
+			if err != nil {
+				return err
+			}
+			if _, err = txn.Put(messageStoreName, messageObj); err != nil {
+				return err
+			}
+
+			// The search tokens only change if this edit is now the latest
+			// version of the text
+			if !bytes.Equal(latest.MessageID, editID.Marshal()) ||
+				!isSearchable(channels.MessageType(msg.Type)) {
+				return nil
+			}
+			return w.reindexMessage(txn, msg.ID, msg.ChannelID, edit.Text)
+		})
+	if err != nil {
+		return 0, errors.WithMessage(parentErr, err.Error())
+	} else if !changed {
+		return uuid, nil
+	}
+
+	jww.DEBUG.Printf("[CH] Edited message %s with %s", edit.MessageID, editID)
+	if err = w.messageUpdated(uuid, channelID.Marshal()); err != nil {
+		return 0, errors.WithMessage(parentErr, err.Error())
+	}
+	return uuid, nil
+}
@@ -0,0 +1,250 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+)
+
+// enableExtendedEncryption turns on extended encryption for the model. When
+// enabled, the nickname, public key, and DM token of each message are
+// encrypted into Message.Metadata and the public key is replaced with a keyed
+// blind index in Message.PubkeyIndex so that messages can still be looked up by
+// sender.
+//
+// All existing messages that are not yet encrypted are migrated in place.
+// Returns an error if the model has no cipher.
+func (w *wasmModel) enableExtendedEncryption() error {
+	if w.cipher == nil {
+		return errors.New("extended encryption requires a cipher")
+	}
+	w.extendedEncryption = true
+
+	numEncrypted, err := impl.UpdateAll(w.db, messageStoreName,
+		func(msgObj js.Value) (js.Value, bool, error) {
+			msg, err := valueToMessage(msgObj)
+			if err != nil || msg.Metadata != nil {
+				return js.Undefined(), false, err
+			}
+
+			encryptedMsg, err := w.encryptMessageMetadata(msg)
+			if err != nil {
+				return js.Undefined(), false, err
+			}
+			return marshalToJS(encryptedMsg)
+		})
+	if err != nil {
+		return errors.WithMessage(
+			err, "failed to encrypt metadata of existing messages")
+	}
+
+	if numEncrypted > 0 {
+		jww.INFO.Printf(
+			"[CH] Encrypted metadata of %d existing messages", numEncrypted)
+	}
+	return nil
+}
+
+// pubkeyIndex returns the value used to look up messages by the sender's
+// public key. When extended encryption is enabled, this is the blind index
+// stored in Message.PubkeyIndex.
+func (w *wasmModel) pubkeyIndex(pubKey []byte) ([]byte, error) {
+	if !w.extendedEncryption {
+		return pubKey, nil
+	}
+	return impl.BlindIndex(w.cipher, pubKey)
+}
+
+// encryptMessageMetadata returns a copy of the Message with the metadata
+// encrypted. If extended encryption is disabled or the metadata is already
+// encrypted, the Message is returned unchanged.
+func (w *wasmModel) encryptMessageMetadata(msg *Message) (*Message, error) {
+	if !w.extendedEncryption || msg.Metadata != nil {
+		return msg, nil
+	}
+
+	metadataJson, err := json.Marshal(messageMetadata{
+		Nickname: msg.Nickname,
+		Pubkey:   msg.Pubkey,
+		DmToken:  msg.DmToken,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal message metadata")
+	}
+
+	encryptedMsg := *msg
+	encryptedMsg.Metadata, err = impl.EncryptChunked(w.cipher, metadataJson)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to encrypt message metadata")
+	}
+	encryptedMsg.PubkeyIndex, err = w.pubkeyIndex(msg.Pubkey)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get pubkey index")
+	}
+	encryptedMsg.Nickname = ""
+	encryptedMsg.Pubkey = nil
+	encryptedMsg.DmToken = 0
+	return &encryptedMsg, nil
+}
+
+// decryptMessageMetadata decrypts the metadata of the Message in place.
+// Messages without encrypted metadata are left unchanged.
+func (w *wasmModel) decryptMessageMetadata(msg *Message) error {
+	if msg.Metadata == nil {
+		return nil
+	} else if w.cipher == nil {
+		return errors.New("cannot decrypt message metadata without a cipher")
+	}
+
+	metadataJson, err := impl.DecryptChunked(w.cipher, msg.Metadata)
+	if err != nil {
+		return errors.WithMessage(err, "failed to decrypt message metadata")
+	}
+	var metadata messageMetadata
+	if err = json.Unmarshal(metadataJson, &metadata); err != nil {
+		return errors.Wrap(err, "failed to unmarshal message metadata")
+	}
+
+	msg.Nickname = metadata.Nickname
+	msg.Pubkey = metadata.Pubkey
+	msg.DmToken = metadata.DmToken
+	msg.Metadata = nil
+	msg.PubkeyIndex = nil
+	return nil
+}
+
+// rotateKey replaces the cipher of the model with the key ring and starts
+// re-encrypting all messages, files, and drafts with its current key in the
+// background. Progress is reported on the event callback with the event type
+// [impl.KeyRotationProgress].
+//
+// Blind indexes, including the tokens of the search index, are keyed with the
+// index key of the key ring (see [impl.KeyRing.IndexCipher]), so they remain
+// valid and lookups and searches find every message during and after the
+// rotation.
+func (w *wasmModel) rotateKey(kr *impl.KeyRing) {
+	w.cipher = kr
+	go impl.ReEncryptStores(w.db, kr,
+		[]string{messageStoreName, fileStoreName, draftStoreName},
+		w.reEncryptValue,
+		func(progress impl.KeyRotationProgressJSON) {
+			w.eventCallback(impl.KeyRotationProgress, progress)
+		})
+}
+
+// reEncryptValue is the [impl.ReEncryptFunc] for the channels database.
+func (w *wasmModel) reEncryptValue(
+	storeName string, value js.Value) (js.Value, bool, error) {
+	switch storeName {
+	case messageStoreName:
+		msg, err := valueToMessage(value)
+		if err != nil {
+			return js.Undefined(), false, err
+		}
+		msg, changed, err := w.reEncryptMessage(msg)
+		if err != nil || !changed {
+			return js.Undefined(), false, err
+		}
+		return marshalToJS(msg)
+	case fileStoreName:
+		file, err := valueToFile(value)
+		if err != nil {
+			return js.Undefined(), false, err
+		}
+		changed, err := w.reEncryptFile(file)
+		if err != nil || !changed {
+			return js.Undefined(), false, err
+		}
+		return marshalToJS(file)
+	case draftStoreName:
+		draft, err := valueToDraft(value)
+		if err != nil {
+			return js.Undefined(), false, err
+		}
+		text, changed, err := impl.ReEncrypt(w.cipher, draft.Text)
+		if err != nil || !changed {
+			return js.Undefined(), false, err
+		}
+		draft.Text = text
+		return marshalToJS(draft)
+	default:
+		return js.Undefined(), false,
+			errors.Errorf("unknown object store %q", storeName)
+	}
+}
+
+// reEncryptMessage re-encrypts the text, edit history, and metadata of the
+// Message with the current key, if they were encrypted with an older key. The
+// blind index of the sender is keyed with the index key of the key ring, so it
+// does not change. Returns true if the Message changed.
+func (w *wasmModel) reEncryptMessage(msg *Message) (*Message, bool, error) {
+	text, textChanged, err := impl.ReEncrypt(w.cipher, msg.Text)
+	if err != nil {
+		return nil, false, errors.WithMessage(err, "failed to re-encrypt text")
+	}
+	msg.Text = text
+
+	editsChanged, err := impl.ReEncryptEdits(w.cipher, msg.EditHistory)
+	if err != nil {
+		return nil, false, err
+	}
+	textChanged = textChanged || editsChanged
+
+	if msg.Metadata == nil || !w.extendedEncryption {
+		return msg, textChanged, nil
+	}
+
+	metadata, metadataChanged, err :=
+		impl.ReEncryptChunked(w.cipher, msg.Metadata)
+	if err != nil {
+		return nil, false, err
+	}
+	msg.Metadata = metadata
+	return msg, textChanged || metadataChanged, nil
+}
+
+// reEncryptFile re-encrypts the data and link of the File in place with the
+// current key, if they were encrypted with an older key. Returns true if the
+// File changed.
+func (w *wasmModel) reEncryptFile(file *File) (bool, error) {
+	if !file.Encrypted {
+		return false, nil
+	}
+
+	data, dataChanged, err := impl.ReEncryptChunked(w.cipher, file.Data)
+	if err != nil {
+		return false, errors.WithMessage(err, "failed to re-encrypt file data")
+	}
+	link, linkChanged, err := impl.ReEncryptChunked(w.cipher, file.Link)
+	if err != nil {
+		return false, errors.WithMessage(err, "failed to re-encrypt file link")
+	}
+
+	file.Data, file.Link = data, link
+	return dataChanged || linkChanged, nil
+}
+
+// marshalToJS JSON marshals the object into a js.Value for use as the return
+// value of an [impl.UpdateAll] update function.
+func marshalToJS(v any) (js.Value, bool, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return js.Undefined(), false, err
+	}
+	obj, err := utils.JsonToJS(data)
+	return obj, err == nil, err
+}
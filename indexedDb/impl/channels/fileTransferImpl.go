@@ -12,12 +12,14 @@ package main
 import (
 	"encoding/json"
 	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
 	"gitlab.com/elixxir/client/v4/channels"
 	cft "gitlab.com/elixxir/client/v4/channelsFileTransfer"
 	"gitlab.com/elixxir/crypto/fileTransfer"
 	"gitlab.com/elixxir/wasm-utils/utils"
 	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
 	"strings"
+	"syscall/js"
 	"time"
 )
 
@@ -55,44 +57,55 @@ func (w *wasmModel) UpdateFile(fileID fileTransfer.ID, fileLink,
 	fileData []byte, timestamp *time.Time, status *cft.Status) error {
 	parentErr := "[Channels indexedDB] failed to UpdateFile"
 
-	// Get the File as it currently exists in storage
-	fileObj, err := impl.Get(w.db, fileStoreName, impl.EncodeBytes(fileID.Marshal()))
+	// Get and update the File in one transaction so that no other change to
+	// the File is lost in between
+	err := impl.Transact(w.db, []string{fileStoreName},
+		func(txn *impl.Transaction) error {
+			// Get the File as it currently exists in storage
+			fileObj, err := txn.Get(
+				fileStoreName, impl.EncodeBytes(fileID.Marshal()))
+			if err != nil {
+				return err
+			}
+			currentFile, err := w.valueToDecryptedFile(fileObj)
+			if err != nil {
+				return err
+			}
+
+			// Update the fields if specified
+			if status != nil {
+				currentFile.Status = uint8(*status)
+			}
+			if timestamp != nil {
+				currentFile.Timestamp = *timestamp
+			}
+			if fileData != nil {
+				currentFile.Data = fileData
+			}
+			if fileLink != nil {
+				currentFile.Link = fileLink
+			}
+
+			fileObj, err = w.newFileObject(currentFile)
+			if err != nil {
+				return err
+			}
+			_, err = txn.Put(fileStoreName, fileObj)
+			return err
+		})
 	if err != nil {
 		if strings.Contains(err.Error(), impl.ErrDoesNotExist) {
 			return errors.WithMessage(channels.NoMessageErr, parentErr)
 		}
 		return errors.WithMessage(err, parentErr)
 	}
-	currentFile, err := valueToFile(fileObj)
-	if err != nil {
-		return errors.WithMessage(err, parentErr)
-	}
-
-	// Update the fields if specified
-	if status != nil {
-		currentFile.Status = uint8(*status)
-	}
-	if timestamp != nil {
-		currentFile.Timestamp = *timestamp
-	}
-	if fileData != nil {
-		currentFile.Data = fileData
-	}
-	if fileLink != nil {
-		currentFile.Link = fileLink
-	}
-
-	return w.upsertFile(currentFile)
+	return nil
 }
 
 // upsertFile is a helper function that will update an existing File
 // if File.Id is specified. Otherwise, it will perform an insert.
 func (w *wasmModel) upsertFile(newFile *File) error {
-	newFileJson, err := json.Marshal(&newFile)
-	if err != nil {
-		return err
-	}
-	fileObj, err := utils.JsonToJS(newFileJson)
+	fileObj, err := w.newFileObject(newFile)
 	if err != nil {
 		return err
 	}
@@ -101,6 +114,22 @@ func (w *wasmModel) upsertFile(newFile *File) error {
 	return err
 }
 
+// newFileObject converts the File to a Javascript object for storage.
+//
+// If a cipher is set, the file data and link are encrypted.
+func (w *wasmModel) newFileObject(file *File) (js.Value, error) {
+	file, err := w.encryptFile(file)
+	if err != nil {
+		return js.Undefined(), err
+	}
+
+	newFileJson, err := json.Marshal(&file)
+	if err != nil {
+		return js.Undefined(), err
+	}
+	return utils.JsonToJS(newFileJson)
+}
+
 // GetFile returns the ModelFile containing the file data and download link
 // for the given file ID.
 //
@@ -117,7 +146,7 @@ func (w *wasmModel) GetFile(fileID fileTransfer.ID) (
 		return cft.ModelFile{}, err
 	}
 
-	resultFile, err := valueToFile(fileObj)
+	resultFile, err := w.valueToDecryptedFile(fileObj)
 	if err != nil {
 		return cft.ModelFile{}, err
 	}
@@ -145,3 +174,108 @@ func (w *wasmModel) DeleteFile(fileID fileTransfer.ID) error {
 	}
 	return err
 }
+
+// encryptFile returns a copy of the File with the data and link encrypted with
+// the cipher. If no cipher is set or the File is already encrypted, it is
+// returned unchanged.
+func (w *wasmModel) encryptFile(file *File) (*File, error) {
+	if w.cipher == nil || file.Encrypted {
+		return file, nil
+	}
+
+	data, err := impl.EncryptChunked(w.cipher, file.Data)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to encrypt file data")
+	}
+	link, err := impl.EncryptChunked(w.cipher, file.Link)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to encrypt file link")
+	}
+
+	encryptedFile := *file
+	encryptedFile.Data = data
+	encryptedFile.Link = link
+	encryptedFile.Encrypted = true
+	return &encryptedFile, nil
+}
+
+// decryptFile decrypts the data and link of the File in place. Files that are
+// not encrypted are left unchanged.
+func (w *wasmModel) decryptFile(file *File) error {
+	if !file.Encrypted {
+		return nil
+	} else if w.cipher == nil {
+		return errors.New("cannot decrypt file without a cipher")
+	}
+
+	data, err := impl.DecryptChunked(w.cipher, file.Data)
+	if err != nil {
+		return errors.WithMessage(err, "failed to decrypt file data")
+	}
+	link, err := impl.DecryptChunked(w.cipher, file.Link)
+	if err != nil {
+		return errors.WithMessage(err, "failed to decrypt file link")
+	}
+
+	file.Data = data
+	file.Link = link
+	file.Encrypted = false
+	return nil
+}
+
+// valueToDecryptedFile is a helper for converting js.Value to a File and
+// decrypting it.
+func (w *wasmModel) valueToDecryptedFile(fileObj js.Value) (*File, error) {
+	file, err := valueToFile(fileObj)
+	if err != nil {
+		return nil, err
+	}
+
+	return file, w.decryptFile(file)
+}
+
+// filesEncryptedMigration is the name of the Migration stored once every file
+// in the database is encrypted.
+const filesEncryptedMigration = "encryptExistingFiles"
+
+// encryptExistingFiles encrypts, in place, all files that were stored in
+// plaintext before file encryption was added or while the database was opened
+// without a cipher.
+//
+// The files are only scanned if the filesEncryptedMigration has not finished.
+// Opening the database without a cipher clears the migration, since files
+// stored from then on are in plaintext.
+func (w *wasmModel) encryptExistingFiles() error {
+	if w.cipher == nil {
+		err := impl.Delete(
+			w.db, migrationStoreName, js.ValueOf(filesEncryptedMigration))
+		return errors.WithMessage(err, "failed to clear file migration")
+	}
+
+	done, err := w.migrationDone(filesEncryptedMigration)
+	if err != nil || done {
+		return err
+	}
+
+	numEncrypted, err := impl.UpdateAll(w.db, fileStoreName,
+		func(fileObj js.Value) (js.Value, bool, error) {
+			file, err := valueToFile(fileObj)
+			if err != nil || file.Encrypted {
+				return js.Undefined(), false, err
+			}
+
+			encryptedFile, err := w.encryptFile(file)
+			if err != nil {
+				return js.Undefined(), false, err
+			}
+			return marshalToJS(encryptedFile)
+		})
+	if err != nil {
+		return errors.WithMessage(err, "failed to encrypt existing files")
+	}
+
+	if numEncrypted > 0 {
+		jww.INFO.Printf("[CH] Encrypted %d existing files", numEncrypted)
+	}
+	return w.finishMigration(filesEncryptedMigration)
+}
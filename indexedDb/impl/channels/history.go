@@ -0,0 +1,151 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+	"time"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/client/v4/channels"
+	cft "gitlab.com/elixxir/client/v4/channelsFileTransfer"
+	"gitlab.com/elixxir/crypto/codename"
+	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// ExportHistory renders every message in the channel with a timestamp in the
+// date range in the given format. A zero start or end leaves that side of the
+// range unbounded. Hidden messages are not included.
+func (w *wasmModel) ExportHistory(channelID *id.ID, start, end time.Time,
+	format impl.HistoryFormat) ([]byte, error) {
+	channelIDStr := impl.EncodeBytes(channelID.Marshal())
+
+	title := channelID.String()
+	channelObj, err := impl.Get(w.db, channelStoreName, channelIDStr)
+	if err == nil {
+		var channel Channel
+		err = json.Unmarshal([]byte(utils.JsToJson(channelObj)), &channel)
+		if err == nil && channel.Name != "" {
+			title = channel.Name
+		}
+	}
+
+	msgObjs, err := impl.GetAllIndex(
+		w.db, messageStoreName, messageStoreChannelIndex, channelIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	history := impl.NewHistory(title, channelID.Marshal(), start, end)
+	var reactions []*Message
+	for _, msgObj := range msgObjs {
+		msg, err := w.decryptedMessage(msgObj)
+		if err != nil {
+			return nil, err
+		} else if msg.Hidden || !history.InRange(msg.Timestamp) {
+			continue
+		}
+
+		switch channels.MessageType(msg.Type) {
+		case channels.Text, channels.AdminText, channels.Invitation:
+			history.Add(historyMessage(msg))
+		case channels.FileTransfer:
+			historyMsg := historyMessage(msg)
+			historyMsg.Text, historyMsg.File = "", historyFile(msg.Text)
+			history.Add(historyMsg)
+		case channels.Reaction:
+			reactions = append(reactions, msg)
+		}
+	}
+
+	// Reactions are added once all the messages they react to are added
+	for _, msg := range reactions {
+		history.AddReaction(msg.ParentMessageID, impl.HistoryReaction{
+			Reaction:  msg.Text,
+			Nickname:  historyNickname(msg),
+			Timestamp: msg.Timestamp,
+		})
+	}
+
+	return history.Render(format)
+}
+
+// decryptedMessage converts the js.Value into a Message with its metadata and
+// text decrypted.
+func (w *wasmModel) decryptedMessage(msgObj js.Value) (*Message, error) {
+	msg, err := valueToMessage(msgObj)
+	if err != nil {
+		return nil, err
+	}
+	if err = w.decryptMessageMetadata(msg); err != nil {
+		return nil, err
+	}
+
+	if w.cipher != nil && msg.Text != "" {
+		text, err := w.cipher.Decrypt(msg.Text)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to decrypt message text")
+		}
+		msg.Text = string(text)
+	}
+	return msg, nil
+}
+
+// historyMessage converts the Message into an [impl.HistoryMessage].
+func historyMessage(msg *Message) impl.HistoryMessage {
+	historyMsg := impl.HistoryMessage{
+		MessageID: msg.MessageID,
+		Nickname:  historyNickname(msg),
+		Timestamp: msg.Timestamp,
+		Text:      msg.Text,
+		Pinned:    msg.Pinned,
+	}
+	if len(msg.ParentMessageID) > 0 {
+		historyMsg.ReplyTo = msg.ParentMessageID
+	}
+	return historyMsg
+}
+
+// historyNickname returns the nickname of the sender of the message. If the
+// sender has no nickname, their codename is returned instead.
+func historyNickname(msg *Message) string {
+	if msg.Nickname != "" {
+		return msg.Nickname
+	}
+	identity, err := codename.ConstructIdentity(msg.Pubkey, msg.CodesetVersion)
+	if err != nil {
+		jww.WARN.Printf(
+			"[CH] Failed to construct codename for history: %+v", err)
+		return ""
+	}
+	return identity.Codename
+}
+
+// historyFile returns the file referenced in the text of a file transfer
+// message. Returns nil if the text is not a valid [cft.FileInfo].
+func historyFile(text string) *impl.HistoryFile {
+	var fi cft.FileInfo
+	if err := json.Unmarshal([]byte(text), &fi); err != nil {
+		jww.WARN.Printf("[CH] Failed to unmarshal file info for history: "+
+			"%+v", err)
+		return nil
+	}
+	return &impl.HistoryFile{
+		FileID: fi.FileID.Marshal(),
+		Name:   fi.Name,
+		Type:   fi.Type,
+		Size:   fi.Size,
+	}
+}
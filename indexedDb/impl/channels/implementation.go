@@ -15,6 +15,7 @@ import (
 	"gitlab.com/elixxir/client/v4/bindings"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall/js"
 	"time"
 
@@ -29,47 +30,66 @@ import (
 	"gitlab.com/elixxir/crypto/message"
 	"gitlab.com/elixxir/wasm-utils/utils"
 	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+	channelsModel "gitlab.com/elixxir/xxdk-wasm/indexedDb/model/channels"
 	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/netTime"
 )
 
 // wasmModel implements [channels.EventModel] interface backed by IndexedDb.
 // NOTE: This model is NOT thread safe - it is the responsibility of the
-// caller to ensure that its methods are called sequentially.
+// caller to ensure that its methods are called sequentially. The only exception
+// is ReceiveMessage, ReceiveReply, and ReceiveReaction, which may be called
+// concurrently with each other, but not with any other method, so that their
+// inserts are coalesced by the batch.
 type wasmModel struct {
 	db            *idb.Database
 	cipher        idbCrypto.Cipher
 	eventCallback eventUpdate
+
+	// batch coalesces the inserts of received messages into multi-row
+	// transactions.
+	batch *impl.WriteBatcher
+
+	// extendedEncryption is true if message metadata is encrypted in addition
+	// to the message text.
+	extendedEncryption bool
+
+	// lastActivity caches Channel.LastActivity of each channel updated since
+	// the model was created (see touchChannel).
+	lastActivity    map[id.ID]time.Time
+	lastActivityMux sync.Mutex
 }
 
-// JoinChannel is called whenever a channel is joined locally.
+// JoinChannel is called whenever a channel is joined locally. If the channel
+// is already stored, its join time, admin status, last activity, and
+// notification level are kept.
 func (w *wasmModel) JoinChannel(channel *cryptoBroadcast.Channel) {
 	parentErr := errors.New("failed to JoinChannel")
 
-	// Build object
-	newChannel := Channel{
-		ID:          channel.ReceptionID.Marshal(),
-		Name:        channel.Name,
-		Description: channel.Description,
-	}
-
-	// Convert to jsObject
-	newChannelJson, err := json.Marshal(&newChannel)
-	if err != nil {
-		jww.ERROR.Printf("%+v", errors.WithMessagef(parentErr,
-			"Unable to marshal Channel: %+v", err))
-		return
-	}
-	channelObj, err := utils.JsonToJS(newChannelJson)
-	if err != nil {
-		jww.ERROR.Printf("%+v", errors.WithMessagef(parentErr,
-			"Unable to marshal Channel: %+v", err))
-		return
-	}
+	privacyLevel := uint8(channel.Level)
+	channelID := channel.ReceptionID.Marshal()
+	err := impl.Transact(w.db, []string{channelStoreName},
+		func(txn *impl.Transaction) error {
+			// Build object, keeping the metadata of an existing channel
+			newChannel := &Channel{ID: channelID, JoinedAt: netTime.Now()}
+			channelObj, err := txn.Get(
+				channelStoreName, impl.EncodeBytes(channelID))
+			if err == nil {
+				if newChannel, err = valueToChannel(channelObj); err != nil {
+					return errors.Errorf(
+						"Unable to unmarshal existing Channel: %+v", err)
+				}
+			} else if !strings.Contains(err.Error(), impl.ErrDoesNotExist) {
+				return errors.Errorf("Unable to get Channel: %+v", err)
+			}
+			newChannel.Name = channel.Name
+			newChannel.Description = channel.Description
+			newChannel.PrivacyLevel = &privacyLevel
 
-	_, err = impl.Put(w.db, channelStoreName, channelObj)
+			return w.putChannel(txn, newChannel)
+		})
 	if err != nil {
-		jww.ERROR.Printf("%+v", errors.WithMessagef(parentErr,
-			"Unable to put Channel: %+v", err))
+		jww.ERROR.Printf("%+v", errors.WithMessage(parentErr, err.Error()))
 	}
 }
 
@@ -77,62 +97,53 @@ func (w *wasmModel) JoinChannel(channel *cryptoBroadcast.Channel) {
 func (w *wasmModel) LeaveChannel(channelID *id.ID) {
 	parentErr := errors.New("failed to LeaveChannel")
 
-	// Delete the channel from storage
-	err := impl.Delete(w.db, channelStoreName, js.ValueOf(channelID.String()))
-	if err != nil {
-		jww.ERROR.Printf("%+v", errors.WithMessagef(parentErr,
-			"Unable to delete Channel: %+v", err))
-		return
-	}
+	// Delete the channel, its messages, its draft, and its search tokens from
+	// storage in one transaction so that nothing is left behind without a
+	// channel
+	err := impl.Transact(w.db, []string{channelStoreName, messageStoreName,
+		draftStoreName, searchStoreName},
+		func(txn *impl.Transaction) error {
+			err := txn.Delete(channelStoreName, js.ValueOf(channelID.String()))
+			if err != nil {
+				return errors.Errorf("Unable to delete Channel: %+v", err)
+			}
 
-	// Clean up lingering data
-	err = w.deleteMsgByChannel(channelID)
+			err = txn.Delete(
+				draftStoreName, impl.EncodeBytes(channelID.Marshal()))
+			if err != nil {
+				return errors.Errorf("Unable to delete Draft: %+v", err)
+			}
+
+			// Clean up lingering data
+			err = w.deleteMsgByChannel(txn, channelID)
+			if err != nil {
+				return errors.Errorf(
+					"Deleting Channel's Message data failed: %+v", err)
+			}
+			return nil
+		})
 	if err != nil {
-		jww.ERROR.Printf("%+v", errors.WithMessagef(parentErr,
-			"Deleting Channel's Message data failed: %+v", err))
+		jww.ERROR.Printf("%+v", errors.WithMessage(parentErr, err.Error()))
 		return
 	}
+	w.forgetChannel(channelID)
 	jww.DEBUG.Printf("Successfully deleted channel: %s", channelID)
 }
 
 // deleteMsgByChannel is a private helper that uses messageStoreChannelIndex
-// to delete all Message with the given Channel ID.
-func (w *wasmModel) deleteMsgByChannel(channelID *id.ID) error {
-	parentErr := errors.New("failed to deleteMsgByChannel")
-
-	// Prepare the Transaction
-	txn, err := w.db.Transaction(idb.TransactionReadWrite, messageStoreName)
+// to delete all Message with the given Channel ID, and their SearchToken, in
+// the transaction.
+func (w *wasmModel) deleteMsgByChannel(
+	txn *impl.Transaction, channelID *id.ID) error {
+	_, err := txn.DeleteAllIndex(messageStoreName, messageStoreChannelIndex,
+		impl.EncodeBytes(channelID.Marshal()))
 	if err != nil {
-		return errors.WithMessagef(parentErr,
-			"Unable to create Transaction: %+v", err)
+		return errors.WithMessage(err, "failed to deleteMsgByChannel")
 	}
-	store, err := txn.ObjectStore(messageStoreName)
+	_, err = txn.DeleteAllIndex(searchStoreName, searchStoreChannelIndex,
+		impl.EncodeBytes(channelID.Marshal()))
 	if err != nil {
-		return errors.WithMessagef(parentErr,
-			"Unable to get ObjectStore: %+v", err)
-	}
-	index, err := store.Index(messageStoreChannelIndex)
-	if err != nil {
-		return errors.WithMessagef(parentErr,
-			"Unable to get Index: %+v", err)
-	}
-
-	// Set up the operation
-	keyRange, err := idb.NewKeyRangeOnly(impl.EncodeBytes(channelID.Marshal()))
-	cursorRequest, err := index.OpenCursorRange(keyRange, idb.CursorNext)
-	if err != nil {
-		return errors.WithMessagef(parentErr, "Unable to open Cursor: %+v", err)
-	}
-
-	// Perform the operation
-	err = impl.SendCursorRequest(cursorRequest,
-		func(cursor *idb.CursorWithValue) error {
-			_, err := cursor.Delete()
-			return err
-		})
-	if err != nil {
-		return errors.WithMessagef(parentErr,
-			"Unable to delete Message data: %+v", err)
+		return errors.WithMessage(err, "failed to deleteMsgByChannel")
 	}
 	return nil
 }
@@ -146,6 +157,7 @@ func (w *wasmModel) ReceiveMessage(channelID *id.ID, messageID message.ID,
 	codeset uint8, timestamp time.Time, lease time.Duration, round rounds.Round,
 	mType channels.MessageType, status channels.SentStatus, hidden bool) uint64 {
 	var err error
+	plaintext := text
 
 	// Handle encryption, if it is present
 	if w.cipher != nil {
@@ -163,13 +175,23 @@ func (w *wasmModel) ReceiveMessage(channelID *id.ID, messageID message.ID,
 		text, pubKey, dmToken, codeset, timestamp, lease, round.ID, mType,
 		false, hidden, status)
 
-	uuid, err := w.upsertMessage(msgToInsert)
+	var searchKeys []string
+	if isSearchable(mType) {
+		searchKeys, err = w.searchIndexKeys(plaintext)
+		if err != nil {
+			jww.ERROR.Printf("Failed to index Message for search: %+v", err)
+			return 0
+		}
+	}
+
+	uuid, err := w.insertMessage(msgToInsert, searchKeys)
 	if err != nil {
 		jww.ERROR.Printf("Failed to receive Message: %+v", err)
 		return 0
 	}
+	w.touchChannel(channelID, timestamp)
 
-	go w.eventCallback(bindings.MessageReceived, bindings.MessageReceivedJSON{
+	w.eventCallback(bindings.MessageReceived, bindings.MessageReceivedJSON{
 		UUID:      int64(uuid),
 		ChannelID: channelID,
 		Update:    false,
@@ -189,6 +211,7 @@ func (w *wasmModel) ReceiveReply(channelID *id.ID, messageID,
 	round rounds.Round, mType channels.MessageType, status channels.SentStatus,
 	hidden bool) uint64 {
 	var err error
+	plaintext := text
 
 	// Handle encryption, if it is present
 	if w.cipher != nil {
@@ -205,13 +228,23 @@ func (w *wasmModel) ReceiveReply(channelID *id.ID, messageID,
 		replyTo.Bytes(), nickname, text, pubKey, dmToken, codeset,
 		timestamp, lease, round.ID, mType, hidden, false, status)
 
-	uuid, err := w.upsertMessage(msgToInsert)
+	var searchKeys []string
+	if isSearchable(mType) {
+		searchKeys, err = w.searchIndexKeys(plaintext)
+		if err != nil {
+			jww.ERROR.Printf("Failed to index reply for search: %+v", err)
+			return 0
+		}
+	}
+
+	uuid, err := w.insertMessage(msgToInsert, searchKeys)
 	if err != nil {
 		jww.ERROR.Printf("Failed to receive reply: %+v", err)
 		return 0
 	}
+	w.touchChannel(channelID, timestamp)
 
-	go w.eventCallback(bindings.MessageReceived, bindings.MessageReceivedJSON{
+	w.eventCallback(bindings.MessageReceived, bindings.MessageReceivedJSON{
 		UUID:      int64(uuid),
 		ChannelID: channelID,
 		Update:    false,
@@ -247,13 +280,14 @@ func (w *wasmModel) ReceiveReaction(channelID *id.ID, messageID,
 		reaction, pubKey, dmToken, codeset, timestamp, lease, round.ID, mType,
 		false, hidden, status)
 
-	uuid, err := w.upsertMessage(msgToInsert)
+	uuid, err := w.insertMessage(msgToInsert, nil)
 	if err != nil {
 		jww.ERROR.Printf("Failed to receive reaction: %+v", err)
 		return 0
 	}
+	w.touchChannel(channelID, timestamp)
 
-	go w.eventCallback(bindings.MessageReceived, bindings.MessageReceivedJSON{
+	w.eventCallback(bindings.MessageReceived, bindings.MessageReceivedJSON{
 		UUID:      int64(uuid),
 		ChannelID: channelID,
 		Update:    false,
@@ -277,8 +311,26 @@ func (w *wasmModel) UpdateFromUUID(uuid uint64, messageID *message.ID,
 	// Convert messageID to the key generated by json.Marshal
 	key := js.ValueOf(uuid)
 
-	// Use the key to get the existing Message
-	msgObj, err := impl.Get(w.db, messageStoreName, key)
+	// Get and update the existing Message in one transaction so that no other
+	// change to the Message is lost in between
+	var channelID []byte
+	err := impl.Transact(w.db, []string{messageStoreName},
+		func(txn *impl.Transaction) error {
+			msgObj, err := txn.Get(messageStoreName, key)
+			if err != nil {
+				return err
+			}
+
+			currentMsg, err := valueToMessage(msgObj)
+			if err != nil {
+				return errors.WithMessage(err, "Failed to marshal Message")
+			}
+			channelID = currentMsg.ChannelID
+
+			_, err = w.updateMessage(txn, currentMsg, messageID, timestamp,
+				round, pinned, hidden, status)
+			return err
+		})
 	if err != nil {
 		if strings.Contains(err.Error(), impl.ErrDoesNotExist) {
 			return errors.WithMessage(channels.NoMessageErr, parentErr)
@@ -286,15 +338,7 @@ func (w *wasmModel) UpdateFromUUID(uuid uint64, messageID *message.ID,
 		return errors.WithMessage(err, parentErr)
 	}
 
-	currentMsg, err := valueToMessage(msgObj)
-	if err != nil {
-		return errors.WithMessagef(err,
-			"%s Failed to marshal Message", parentErr)
-	}
-
-	_, err = w.updateMessage(currentMsg, messageID, timestamp,
-		round, pinned, hidden, status)
-	if err != nil {
+	if err = w.messageUpdated(uuid, channelID); err != nil {
 		return errors.WithMessage(err, parentErr)
 	}
 	return nil
@@ -317,8 +361,28 @@ func (w *wasmModel) UpdateFromMessageID(messageID message.ID,
 	status *channels.SentStatus) (uint64, error) {
 	parentErr := "failed to UpdateFromMessageID"
 
-	msgObj, err := impl.GetIndex(w.db, messageStoreName,
-		messageStoreMessageIndex, impl.EncodeBytes(messageID.Marshal()))
+	// Get and update the existing Message in one transaction so that no other
+	// change to the Message is lost in between
+	var uuid uint64
+	var channelID []byte
+	err := impl.Transact(w.db, []string{messageStoreName},
+		func(txn *impl.Transaction) error {
+			msgObj, err := txn.GetIndex(messageStoreName,
+				messageStoreMessageIndex, impl.EncodeBytes(messageID.Marshal()))
+			if err != nil {
+				return err
+			}
+
+			currentMsg, err := valueToMessage(msgObj)
+			if err != nil {
+				return errors.WithMessage(err, "Failed to marshal Message")
+			}
+			channelID = currentMsg.ChannelID
+
+			uuid, err = w.updateMessage(txn, currentMsg, &messageID, timestamp,
+				round, pinned, hidden, status)
+			return err
+		})
 	if err != nil {
 		if strings.Contains(err.Error(), impl.ErrDoesNotExist) {
 			return 0, errors.WithMessage(channels.NoMessageErr, parentErr)
@@ -326,15 +390,7 @@ func (w *wasmModel) UpdateFromMessageID(messageID message.ID,
 		return 0, errors.WithMessage(err, parentErr)
 	}
 
-	currentMsg, err := valueToMessage(msgObj)
-	if err != nil {
-		return 0, errors.WithMessagef(err,
-			"%s Failed to marshal Message", parentErr)
-	}
-
-	uuid, err := w.updateMessage(currentMsg, &messageID, timestamp,
-		round, pinned, hidden, status)
-	if err != nil {
+	if err = w.messageUpdated(uuid, channelID); err != nil {
 		return 0, errors.WithMessage(err, parentErr)
 	}
 	return uuid, nil
@@ -371,69 +427,129 @@ func buildMessage(channelID, messageID, parentID []byte, nickname,
 	}
 }
 
-// updateMessage is a helper for updating a stored message.
-func (w *wasmModel) updateMessage(currentMsg *Message, messageID *message.ID,
-	timestamp *time.Time, round *rounds.Round, pinned, hidden *bool,
-	status *channels.SentStatus) (uint64, error) {
+// updateMessage is a helper for updating a stored message in the transaction.
+// Returns the UUID of the message.
+func (w *wasmModel) updateMessage(txn *impl.Transaction, currentMsg *Message,
+	messageID *message.ID, timestamp *time.Time, round *rounds.Round, pinned,
+	hidden *bool, status *channels.SentStatus) (uint64, error) {
+	channelsModel.MessageUpdate{
+		MessageID: messageID,
+		Timestamp: timestamp,
+		Round:     round,
+		Pinned:    pinned,
+		Hidden:    hidden,
+		Status:    status,
+	}.Apply(&currentMsg.MessageID, &currentMsg.Timestamp, &currentMsg.Round,
+		&currentMsg.Pinned, &currentMsg.Hidden, &currentMsg.Status)
 
-	if status != nil {
-		currentMsg.Status = uint8(*status)
+	// Store the updated Message
+	messageObj, err := w.newMessageObject(currentMsg)
+	if err != nil {
+		return 0, err
 	}
-	if messageID != nil {
-		currentMsg.MessageID = messageID.Bytes()
+	msgIdObj, err := txn.Put(messageStoreName, messageObj)
+	if err != nil {
+		return 0, err
 	}
+	return uint64(msgIdObj.Int()), nil
+}
 
-	if round != nil {
-		currentMsg.Round = uint64(round.ID)
+// messageUpdated notifies the event callback that the message with the UUID
+// in the channel was updated.
+func (w *wasmModel) messageUpdated(uuid uint64, channelIDBytes []byte) error {
+	channelID, err := id.Unmarshal(channelIDBytes)
+	if err != nil {
+		return err
 	}
 
-	if timestamp != nil {
-		currentMsg.Timestamp = *timestamp
-	}
+	go w.eventCallback(bindings.MessageReceived, bindings.MessageReceivedJSON{
+		UUID:      int64(uuid),
+		ChannelID: channelID,
+		Update:    true,
+	})
+	return nil
+}
 
-	if pinned != nil {
-		currentMsg.Pinned = *pinned
+// insertMessage adds the new Message to the write batch and blocks until the
+// batch is written. Concurrent calls are coalesced into a single transaction.
+// The search tokens with the keys are written in the same transaction as the
+// Message. If the batch fails, the Message and its tokens are written on their
+// own with upsertIndexedMessage.
+func (w *wasmModel) insertMessage(
+	msg *Message, searchKeys []string) (uint64, error) {
+	messageObj, err := w.newMessageObject(msg)
+	if err != nil {
+		return 0, err
 	}
 
-	if hidden != nil {
-		currentMsg.Hidden = *hidden
+	type result struct {
+		uuid uint64
+		err  error
+	}
+	resultChan := make(chan result, 1)
+	w.batch.Add(&impl.BatchedWrite{
+		Value: messageObj,
+		Fallback: func() (js.Value, error) {
+			uuid, err := w.upsertIndexedMessage(msg, searchKeys)
+			return js.ValueOf(uuid), err
+		},
+		Related: relatedSearchTokens(msg.ChannelID, searchKeys),
+		Done: func(key js.Value, err error) {
+			if err != nil {
+				resultChan <- result{0, err}
+			} else {
+				resultChan <- result{uint64(key.Int()), nil}
+			}
+		},
+	})
+
+	r := <-resultChan
+	if r.err == nil {
+		jww.DEBUG.Printf("Successfully stored message %d", r.uuid)
 	}
+	return r.uuid, r.err
+}
 
-	// Store the updated Message
-	uuid, err := w.upsertMessage(currentMsg)
+// newMessageObject encrypts the metadata of the Message, if extended
+// encryption is enabled, and converts it to a Javascript object for storage.
+func (w *wasmModel) newMessageObject(msg *Message) (js.Value, error) {
+	encryptedMsg, err := w.encryptMessageMetadata(msg)
 	if err != nil {
-		return 0, err
+		return js.Undefined(), err
 	}
 
-	channelID, err := id.Unmarshal(currentMsg.ChannelID)
+	newMessageJson, err := json.Marshal(encryptedMsg)
 	if err != nil {
-		return 0, err
+		return js.Undefined(), errors.Errorf(
+			"Unable to marshal Message: %+v", err)
 	}
-
-	go w.eventCallback(bindings.MessageReceived, bindings.MessageReceivedJSON{
-		UUID:      int64(uuid),
-		ChannelID: channelID,
-		Update:    true,
-	})
-
-	return uuid, nil
+	messageObj, err := utils.JsonToJS(newMessageJson)
+	if err != nil {
+		return js.Undefined(), errors.Errorf(
+			"Unable to marshal Message: %+v", err)
+	}
+	return messageObj, nil
 }
 
 // upsertMessage is a helper function that will update an existing record
 // if Message.ID is specified. Otherwise, it will perform an insert.
 func (w *wasmModel) upsertMessage(msg *Message) (uint64, error) {
-	// Convert to jsObject
-	newMessageJson, err := json.Marshal(msg)
-	if err != nil {
-		return 0, errors.Errorf("Unable to marshal Message: %+v", err)
-	}
-	messageObj, err := utils.JsonToJS(newMessageJson)
+	return w.upsertIndexedMessage(msg, nil)
+}
+
+// upsertIndexedMessage is upsertMessage for a Message with search tokens. The
+// tokens with the keys are written in the same transaction as the Message.
+func (w *wasmModel) upsertIndexedMessage(
+	msg *Message, searchKeys []string) (uint64, error) {
+	// Convert to jsObject, encrypting the metadata if extended encryption is
+	// enabled
+	messageObj, err := w.newMessageObject(msg)
 	if err != nil {
-		return 0, errors.Errorf("Unable to marshal Message: %+v", err)
+		return 0, err
 	}
 
 	// Store message to database
-	msgIdObj, err := impl.Put(w.db, messageStoreName, messageObj)
+	msgIdObj, err := w.putMessage(messageObj, msg.ChannelID, searchKeys)
 	if err != nil {
 		// Do not error out when this message already exists inside
 		// the DB. Instead, set the ID and re-attempt as an update.
@@ -442,21 +558,17 @@ func (w *wasmModel) upsertMessage(msg *Message) (uint64, error) {
 			if inErr == nil {
 				jww.WARN.Printf("upsertMessage duplicate: %+v",
 					err)
-				rnd := &rounds.Round{ID: id.Round(msg.Round)}
-				status := (*channels.SentStatus)(&msg.Status)
-				return w.UpdateFromMessageID(msgID,
-					&msg.Timestamp,
-					rnd,
-					&msg.Pinned,
-					&msg.Hidden,
-					status)
+				u := channelsModel.DuplicateUpdate(msg.Timestamp,
+					msg.Round, msg.Pinned, msg.Hidden, msg.Status)
+				return w.UpdateFromMessageID(msgID, u.Timestamp, u.Round,
+					u.Pinned, u.Hidden, u.Status)
 			}
 			// Add this to the main putMessage error
 			err = errors.Wrapf(err, "bad msg ID: %+v",
 				inErr)
 		}
 		return 0, errors.Errorf("Unable to put Message: %+v\n%s",
-			err, newMessageJson)
+			err, utils.JsToJson(messageObj))
 	}
 
 	uuid := msgIdObj.Int()
@@ -464,6 +576,38 @@ func (w *wasmModel) upsertMessage(msg *Message) (uint64, error) {
 	return uint64(uuid), nil
 }
 
+// putMessage puts the message object into the message store and, in the same
+// transaction, the search tokens with the keys. Returns the primary key of the
+// stored message.
+func (w *wasmModel) putMessage(messageObj js.Value, channelID []byte,
+	searchKeys []string) (js.Value, error) {
+	related := relatedSearchTokens(channelID, searchKeys)
+	if related == nil {
+		return impl.Put(w.db, messageStoreName, messageObj)
+	}
+
+	var key js.Value
+	err := impl.Transact(w.db, []string{messageStoreName, searchStoreName},
+		func(txn *impl.Transaction) error {
+			var err error
+			if key, err = txn.Put(messageStoreName, messageObj); err != nil {
+				return err
+			}
+			tokens, err := related(key)
+			if err != nil {
+				return err
+			}
+			for _, token := range tokens {
+				_, err = txn.Put(token.ObjectStoreName, token.Value)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	return key, err
+}
+
 // GetMessage returns the message with the given [channel.MessageID].
 func (w *wasmModel) GetMessage(
 	messageID message.ID) (channels.ModelMessage, error) {
@@ -479,6 +623,21 @@ func (w *wasmModel) GetMessage(
 	if err != nil {
 		return channels.ModelMessage{}, err
 	}
+	return w.toModelMessage(lookupResult)
+}
+
+// toModelMessage decrypts the metadata of the Message and converts it to a
+// [channels.ModelMessage].
+func (w *wasmModel) toModelMessage(
+	lookupResult *Message) (channels.ModelMessage, error) {
+	if err := w.decryptMessageMetadata(lookupResult); err != nil {
+		return channels.ModelMessage{}, err
+	}
+
+	messageID, err := message.UnmarshalID(lookupResult.MessageID)
+	if err != nil {
+		return channels.ModelMessage{}, err
+	}
 
 	var channelId *id.ID
 	if lookupResult.ChannelID != nil {
@@ -524,12 +683,29 @@ func (w *wasmModel) GetMessage(
 	}, nil
 }
 
-// DeleteMessage removes a message with the given messageID from storage.
+// DeleteMessage removes a message with the given messageID, and its search
+// tokens, from storage.
 func (w *wasmModel) DeleteMessage(messageID message.ID) error {
-	err := impl.DeleteIndex(w.db, messageStoreName,
-		messageStoreMessageIndex, pkeyName, impl.EncodeBytes(messageID.Marshal()))
+	parentErr := errors.Errorf(
+		"failed to DeleteIndex %s/%s", messageStoreName, messageID)
+
+	err := impl.Transact(w.db, []string{messageStoreName, searchStoreName},
+		func(txn *impl.Transaction) error {
+			msgObj, err := txn.GetIndex(messageStoreName,
+				messageStoreMessageIndex, impl.EncodeBytes(messageID.Marshal()))
+			if err != nil {
+				return err
+			}
+			uuid := msgObj.Get(pkeyName)
+			if err = txn.Delete(messageStoreName, uuid); err != nil {
+				return err
+			}
+			_, err = txn.DeleteAllIndex(
+				searchStoreName, searchStoreMessageIndex, uuid)
+			return err
+		})
 	if err != nil {
-		return err
+		return errors.WithMessagef(parentErr, "%+v", err)
 	}
 
 	go w.eventCallback(bindings.MessageDeleted, bindings.MessageDeletedJSON{
@@ -539,10 +715,17 @@ func (w *wasmModel) DeleteMessage(messageID message.ID) error {
 	return nil
 }
 
-// MuteUser is called whenever a user is muted or unmuted.
+// MuteUser is called whenever a user is muted or unmuted. The messages of a
+// muted user are hidden and shown again when they are unmuted.
 func (w *wasmModel) MuteUser(
 	channelID *id.ID, pubKey ed25519.PublicKey, unmute bool) {
 
+	// Hide the messages of a muted user and show them again on unmute
+	_, err := w.HideMessagesBySender(channelID, pubKey, !unmute)
+	if err != nil {
+		jww.ERROR.Printf("%+v", err)
+	}
+
 	go w.eventCallback(bindings.UserMuted, bindings.UserMutedJSON{
 		ChannelID: channelID,
 		PubKey:    pubKey,
@@ -556,6 +739,13 @@ func valueToMessage(msgObj js.Value) (*Message, error) {
 	return resultMsg, json.Unmarshal([]byte(utils.JsToJson(msgObj)), resultMsg)
 }
 
+// valueToChannel is a helper for converting js.Value to Channel.
+func valueToChannel(channelObj js.Value) (*Channel, error) {
+	resultChannel := &Channel{}
+	return resultChannel,
+		json.Unmarshal([]byte(utils.JsToJson(channelObj)), resultChannel)
+}
+
 // valueToFile is a helper for converting js.Value to File.
 func valueToFile(fileObj js.Value) (*File, error) {
 	resultFile := &File{}
@@ -11,11 +11,14 @@ package main
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"strconv"
+	"syscall/js"
 	"testing"
 	"time"
 
@@ -31,6 +34,7 @@ import (
 	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
 	"gitlab.com/elixxir/crypto/message"
 	"gitlab.com/elixxir/wasm-utils/storage"
+	"gitlab.com/elixxir/wasm-utils/utils"
 	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
 	"gitlab.com/xx_network/crypto/csprng"
 	"gitlab.com/xx_network/primitives/id"
@@ -116,6 +120,168 @@ func TestWasmModel_ReceiveFile(t *testing.T) {
 	}
 }
 
+// Tests that file data and links are stored encrypted when a cipher is set and
+// that files stored in plaintext are encrypted when the model is reopened.
+func TestWasmModel_ReceiveFile_Encrypted(t *testing.T) {
+	testString := "TestWasmModel_ReceiveFile_Encrypted"
+	cipher, err := idbCrypto.NewCipher(
+		[]byte(testString), []byte("testSalt"), 32, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+
+	// Store a plaintext file before encryption is enabled
+	m, err := newWASMModel(testString, nil, dummyEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldData := bytes.Repeat([]byte("old file data "), 20)
+	oldId := fileTransfer.NewID(oldData)
+	err = m.ReceiveFile(oldId, []byte("oldLink"), oldData, time.Now(),
+		cft.Complete)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.db.Close()
+
+	// Reopening with a cipher should encrypt the existing file
+	m, err = newWASMModel(testString, cipher, dummyEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newData := bytes.Repeat([]byte("new file data "), 20)
+	newId := fileTransfer.NewID(newData)
+	err = m.ReceiveFile(newId, []byte("newLink"), newData, time.Now(),
+		cft.Complete)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for fId, data := range map[fileTransfer.ID][]byte{
+		oldId: oldData, newId: newData} {
+		fileObj, err := impl.Get(m.db, fileStoreName,
+			impl.EncodeBytes(fId.Marshal()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rawFile, err := valueToFile(fileObj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rawFile.Encrypted || bytes.Contains(rawFile.Data, data[:14]) {
+			t.Errorf("File %s stored unencrypted: %s", fId, rawFile.Data)
+		}
+
+		storedFile, err := m.GetFile(fId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(storedFile.Data, data) {
+			t.Errorf("Unexpected file data.\nexpected: %q\nreceived: %q",
+				data, storedFile.Data)
+		}
+	}
+
+	// Updating an encrypted file should keep the link
+	updatedData := []byte("updated")
+	err = m.UpdateFile(oldId, nil, updatedData, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updatedFile, err := m.GetFile(oldId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(updatedFile.Link, []byte("oldLink")) ||
+		!bytes.Equal(updatedFile.Data, updatedData) {
+		t.Errorf("Unexpected updated file: %+v", updatedFile)
+	}
+
+	// Opening without the cipher must not return the encrypted file
+	m.db.Close()
+	m, err = newWASMModel(testString, nil, dummyEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.GetFile(newId); err == nil {
+		t.Errorf("Got encrypted file without a cipher.")
+	}
+}
+
+// Tests that wasmModel.encryptExistingFiles only scans the files once it has
+// finished, until the database is opened without a cipher.
+func TestWasmModel_encryptExistingFiles_Once(t *testing.T) {
+	testString := "TestWasmModel_encryptExistingFiles_Once"
+	cipher, err := idbCrypto.NewCipher(
+		[]byte(testString), []byte("testSalt"), 32, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+
+	m, err := newWASMModel(testString, cipher, dummyEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done, err := m.migrationDone(filesEncryptedMigration); err != nil {
+		t.Fatal(err)
+	} else if !done {
+		t.Errorf("Migration not recorded after files were encrypted.")
+	}
+
+	// Store a plaintext file directly so that only the migration can encrypt
+	// it
+	data := []byte("plaintext file data")
+	fId := fileTransfer.NewID(data)
+	fileJson, err := json.Marshal(File{Id: fId.Marshal(), Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileObj, err := utils.JsonToJS(fileJson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = impl.Put(m.db, fileStoreName, fileObj); err != nil {
+		t.Fatal(err)
+	}
+
+	isEncrypted := func() bool {
+		fileObj, err := impl.Get(
+			m.db, fileStoreName, impl.EncodeBytes(fId.Marshal()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rawFile, err := valueToFile(fileObj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rawFile.Encrypted
+	}
+
+	// Reopening with the cipher must not scan the files again
+	m.db.Close()
+	if m, err = newWASMModel(testString, cipher, dummyEU); err != nil {
+		t.Fatal(err)
+	}
+	if isEncrypted() {
+		t.Errorf("Files scanned again after the migration finished.")
+	}
+
+	// Opening without a cipher clears the migration, so the file is encrypted
+	// the next time the database is opened with the cipher
+	m.db.Close()
+	if m, err = newWASMModel(testString, nil, dummyEU); err != nil {
+		t.Fatal(err)
+	}
+	m.db.Close()
+	if m, err = newWASMModel(testString, cipher, dummyEU); err != nil {
+		t.Fatal(err)
+	}
+	if !isEncrypted() {
+		t.Errorf("File not encrypted after the migration was cleared.")
+	}
+}
+
 // Happy path, insert message and look it up
 func TestWasmModel_GetMessage(t *testing.T) {
 	cipher, err := idbCrypto.NewCipher(
@@ -158,6 +324,106 @@ func TestWasmModel_GetMessage(t *testing.T) {
 	}
 }
 
+// Tests that enabling extended encryption encrypts the metadata of existing
+// messages, stores the blind index of the public key, and that GetMessage
+// returns the decrypted metadata.
+func TestWasmModel_enableExtendedEncryption(t *testing.T) {
+	testString := "TestWasmModel_enableExtendedEncryption"
+	cipher, err := idbCrypto.NewCipher(
+		[]byte(testString), []byte("testSalt"), 32, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+	m, err := newWASMModel(testString, cipher, dummyEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	channelID := id.NewIdFromString(testString, id.User, t).Marshal()
+	nickname, pubKey, dmToken := "nickname", []byte{8, 6, 7, 5}, uint32(309)
+	var msgIDs []message.ID
+	for i := 0; i < 4; i++ {
+		// Enable extended encryption after half the messages are stored
+		if i == 2 {
+			if err = m.enableExtendedEncryption(); err != nil {
+				t.Fatalf("Failed to enable extended encryption: %+v", err)
+			}
+		}
+
+		msgID := message.DeriveChannelMessageID(
+			&id.ID{1}, uint64(i), []byte(testString))
+		testMsg := buildMessage(channelID, msgID.Bytes(), nil, nickname,
+			testString, pubKey, dmToken, 0, netTime.Now(), time.Second, 0, 0,
+			false, false, channels.Sent)
+		if _, err = m.upsertMessage(testMsg); err != nil {
+			t.Fatal(err)
+		}
+		msgIDs = append(msgIDs, msgID)
+	}
+
+	pubkeyIndex, err := impl.BlindIndex(cipher, pubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, msgID := range msgIDs {
+		msgObj, err := impl.GetIndex(m.db, messageStoreName,
+			messageStoreMessageIndex, impl.EncodeBytes(msgID.Marshal()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rawMsg, err := valueToMessage(msgObj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rawMsg.Nickname != "" || rawMsg.Pubkey != nil ||
+			rawMsg.DmToken != 0 || rawMsg.Metadata == nil {
+			t.Errorf("Metadata of message #%d stored unencrypted: %+v",
+				i, rawMsg)
+		}
+		if !bytes.Equal(rawMsg.PubkeyIndex, pubkeyIndex) {
+			t.Errorf("Unexpected pubkey index for message #%d."+
+				"\nexpected: %v\nreceived: %v",
+				i, pubkeyIndex, rawMsg.PubkeyIndex)
+		}
+
+		msg, err := m.GetMessage(msgID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Nickname != nickname || !bytes.Equal(msg.PubKey, pubKey) {
+			t.Errorf("Unexpected metadata for message #%d: %+v", i, msg)
+		}
+	}
+
+	// Updating a message must keep its metadata encrypted
+	pinned := true
+	if _, err = m.UpdateFromMessageID(
+		msgIDs[0], nil, nil, &pinned, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := m.GetMessage(msgIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !msg.Pinned || msg.Nickname != nickname {
+		t.Errorf("Unexpected updated message: %+v", msg)
+	}
+}
+
+// Error path: Tests that enableExtendedEncryption returns an error when the
+// model has no cipher.
+func TestWasmModel_enableExtendedEncryption_NoCipher(t *testing.T) {
+	m, err := newWASMModel(
+		"TestWasmModel_enableExtendedEncryption_NoCipher", nil, dummyEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = m.enableExtendedEncryption(); err == nil {
+		t.Errorf("No error enabling extended encryption without a cipher.")
+	}
+}
+
 // Happy path, insert message and delete it
 func TestWasmModel_DeleteMessage(t *testing.T) {
 	storage.GetLocalStorage().Clear()
@@ -477,7 +743,10 @@ func Test_wasmModel_deleteMsgByChannel(t *testing.T) {
 			}
 
 			// Do delete
-			err = eventModel.deleteMsgByChannel(deleteChannel)
+			err = impl.Transact(eventModel.db, []string{messageStoreName},
+				func(txn *impl.Transaction) error {
+					return eventModel.deleteMsgByChannel(txn, deleteChannel)
+				})
 			if err != nil {
 				t.Error(err)
 			}
@@ -646,3 +915,737 @@ func TestUpsertMessageDuplicates(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, channels.Delivered, modelMsg.Status)
 }
+
+// Tests that wasmModel.CheckDatabase reports messages in a channel that does
+// not exist, only moves them to the quarantine store when repairing, and that
+// the database is healthy after the repair.
+func Test_wasmModel_CheckDatabase(t *testing.T) {
+	storage.GetLocalStorage().Clear()
+	testString := "Test_wasmModel_CheckDatabase"
+	eventModel, err := newWASMModel(testString, nil, dummyEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	joined := &cryptoBroadcast.Channel{
+		ReceptionID: id.NewIdFromString("joined", id.Generic, t),
+		Name:        "joined",
+	}
+	eventModel.JoinChannel(joined)
+	notJoined := id.NewIdFromString("notJoined", id.Generic, t)
+
+	// Store three messages in the joined channel and two in the other
+	for i := 0; i < 5; i++ {
+		thisChannel := joined.ReceptionID
+		if i >= 3 {
+			thisChannel = notJoined
+		}
+		testStr := testString + strconv.Itoa(i)
+		testMsgId := message.DeriveChannelMessageID(
+			&id.ID{byte(i)}, 0, []byte(testStr))
+		eventModel.ReceiveMessage(thisChannel, testMsgId, testStr, testStr,
+			[]byte{8, 6, 7, 5}, 0, 0, netTime.Now(), time.Second,
+			rounds.Round{ID: id.Round(0)}, 0, channels.Sent, false)
+	}
+
+	for _, repair := range []bool{false, true} {
+		report, err := eventModel.CheckDatabase(repair)
+		if err != nil {
+			t.Fatalf("Failed to check database (repair %t): %+v", repair, err)
+		}
+
+		expectedAction := impl.NoAction
+		if repair {
+			expectedAction = impl.Quarantined
+		}
+		if report.Healthy || len(report.Issues) != 2 {
+			t.Fatalf("Expected 2 issues (repair %t): %+v", repair, report)
+		}
+		for _, issue := range report.Issues {
+			if issue.Problem != impl.MissingReference ||
+				issue.Action != expectedAction {
+				t.Errorf("Unexpected issue (repair %t): %+v", repair, issue)
+			}
+		}
+		if report.Checked[messageStoreName] != 5 {
+			t.Errorf("Unexpected number of messages checked (repair %t)."+
+				"\nexpected: %d\nreceived: %d",
+				repair, 5, report.Checked[messageStoreName])
+		}
+	}
+
+	for storeName, expected := range map[string]int{
+		messageStoreName: 3, impl.QuarantineStoreName: 2} {
+		if count, err := impl.Count(eventModel.db, storeName); err != nil {
+			t.Fatal(err)
+		} else if count != expected {
+			t.Errorf("Unexpected number of values in %s."+
+				"\nexpected: %d\nreceived: %d", storeName, expected, count)
+		}
+	}
+
+	report, err := eventModel.CheckDatabase(false)
+	if err != nil {
+		t.Fatal(err)
+	} else if !report.Healthy {
+		t.Errorf("Database not healthy after repair: %+v", report)
+	}
+}
+
+// Tests that wasmModel.CheckDatabase moves the drafts of a channel that does
+// not exist and the search tokens of a moved message or with the wrong channel
+// to the quarantine store, and keeps those of valid messages.
+func Test_wasmModel_CheckDatabase_DraftsAndSearchIndex(t *testing.T) {
+	storage.GetLocalStorage().Clear()
+	testString := "Test_wasmModel_CheckDatabase_DraftsAndSearchIndex"
+	m, err := newWASMModel(testString, nil, dummyEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	joined := id.NewIdFromString("joined", id.Generic, t)
+	m.JoinChannel(&cryptoBroadcast.Channel{ReceptionID: joined, Name: "joined"})
+	notJoined := id.NewIdFromString("notJoined", id.Generic, t)
+
+	author, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+	text := "Hello world"
+	var uuids []uint64
+	for _, channelID := range []*id.ID{joined, notJoined} {
+		msgID := message.DeriveChannelMessageID(channelID, 0, []byte(text))
+		uuids = append(uuids, m.ReceiveMessage(channelID, msgID, "nick", text,
+			author, 0, 0, netTime.Now(), time.Second, rounds.Round{ID: 1},
+			channels.Text, channels.Delivered, false))
+	}
+	require.NoError(t, m.SaveDraft(joined, "draft", nil))
+	require.NoError(t, m.SaveDraft(notJoined, "draft", nil))
+
+	// Add a token of the valid message that is indexed in the wrong channel
+	tokenObjs, err := searchTokenObjects(
+		uuids[0], notJoined.Marshal(), []string{"mismatched"})
+	require.NoError(t, err)
+	_, err = impl.Put(m.db, searchStoreName, tokenObjs[0])
+	require.NoError(t, err)
+
+	numTokens := len(impl.SearchTokens(text))
+	report, err := m.CheckDatabase(true)
+	require.NoError(t, err)
+
+	problems := make(map[string]map[impl.IntegrityProblem]int)
+	for _, issue := range report.Issues {
+		require.Equal(t, impl.Quarantined, issue.Action, issue)
+		if problems[issue.Store] == nil {
+			problems[issue.Store] = make(map[impl.IntegrityProblem]int)
+		}
+		problems[issue.Store][issue.Problem]++
+	}
+	require.Equal(t, map[string]map[impl.IntegrityProblem]int{
+		messageStoreName: {impl.MissingReference: 1},
+		draftStoreName:   {impl.MissingReference: 1},
+		searchStoreName: {
+			impl.MissingReference: numTokens, impl.IndexMismatch: 1},
+	}, problems)
+
+	tokenObjs, err = impl.GetAll(m.db, searchStoreName)
+	require.NoError(t, err)
+	require.Len(t, tokenObjs, numTokens)
+	for _, tokenObj := range tokenObjs {
+		token, err := valueToSearchToken(tokenObj)
+		require.NoError(t, err)
+		require.Equal(t, uuids[0], token.MessageID)
+	}
+
+	report, err = m.CheckDatabase(false)
+	require.NoError(t, err)
+	require.True(t, report.Healthy, report)
+}
+
+// Tests that wasmModel.GetStorageUsage counts the rows of every object store
+// and groups messages by channel.
+func Test_wasmModel_GetStorageUsage(t *testing.T) {
+	storage.GetLocalStorage().Clear()
+	testString := "Test_wasmModel_GetStorageUsage"
+	eventModel, err := newWASMModel(testString, nil, dummyEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	channelIDs := []*id.ID{
+		id.NewIdFromString("channel1", id.Generic, t),
+		id.NewIdFromString("channel2", id.Generic, t),
+	}
+	for _, channelID := range channelIDs {
+		eventModel.JoinChannel(&cryptoBroadcast.Channel{
+			ReceptionID: channelID, Name: channelID.String()})
+	}
+
+	// Store three messages in the first channel and one in the second
+	for i := 0; i < 4; i++ {
+		thisChannel := channelIDs[0]
+		if i >= 3 {
+			thisChannel = channelIDs[1]
+		}
+		testStr := testString + strconv.Itoa(i)
+		testMsgId := message.DeriveChannelMessageID(
+			&id.ID{byte(i)}, 0, []byte(testStr))
+		eventModel.ReceiveMessage(thisChannel, testMsgId, testStr, testStr,
+			[]byte{8, 6, 7, 5}, 0, 0, netTime.Now(), time.Second,
+			rounds.Round{ID: id.Round(0)}, 0, channels.Sent, false)
+	}
+
+	usage, err := eventModel.GetStorageUsage()
+	if err != nil {
+		t.Fatalf("Failed to get storage usage: %+v", err)
+	}
+
+	if usage.DatabaseName != testString {
+		t.Errorf("Unexpected database name.\nexpected: %s\nreceived: %s",
+			testString, usage.DatabaseName)
+	}
+	for storeName, expected := range map[string]int{channelStoreName: 2,
+		messageStoreName: 4, fileStoreName: 0, impl.QuarantineStoreName: 0} {
+		if rows := usage.Stores[storeName].Rows; rows != expected {
+			t.Errorf("Unexpected number of rows in %s."+
+				"\nexpected: %d\nreceived: %d", storeName, expected, rows)
+		}
+	}
+	if usage.Total.Rows != 6 || usage.Total.Bytes == 0 {
+		t.Errorf("Unexpected total usage: %+v", usage.Total)
+	}
+
+	for i, expected := range []int{3, 1} {
+		group := base64.StdEncoding.EncodeToString(channelIDs[i].Marshal())
+		if rows := usage.Groups[group].Rows; rows != expected {
+			t.Errorf("Unexpected number of messages in channel %s."+
+				"\nexpected: %d\nreceived: %d", channelIDs[i], expected, rows)
+		}
+	}
+}
+
+// Tests that wasmModel.GetChannels returns channels sorted by the timestamp of
+// their newest message and that JoinChannel and UpdateChannelMetadata keep the
+// metadata of the channel.
+func Test_wasmModel_GetChannels(t *testing.T) {
+	storage.GetLocalStorage().Clear()
+	testString := "Test_wasmModel_GetChannels"
+	eventModel, err := newWASMModel(testString, nil, dummyEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	channelList := make([]*cryptoBroadcast.Channel, 3)
+	for i := range channelList {
+		channelList[i] = &cryptoBroadcast.Channel{
+			ReceptionID: id.NewIdFromString(strconv.Itoa(i), id.Generic, t),
+			Name:        "channel" + strconv.Itoa(i),
+			Level:       cryptoBroadcast.Private,
+		}
+		eventModel.JoinChannel(channelList[i])
+	}
+
+	// Receive messages so that the newest is in channel 1, then 0, then 2
+	start := netTime.Now()
+	for i, channelIdx := range []int{2, 0, 1, 0, 1} {
+		testStr := testString + strconv.Itoa(i)
+		testMsgId := message.DeriveChannelMessageID(
+			&id.ID{byte(i)}, 0, []byte(testStr))
+		eventModel.ReceiveMessage(channelList[channelIdx].ReceptionID,
+			testMsgId, testStr, testStr, []byte{8, 6, 7, 5}, 0, 0,
+			start.Add(time.Duration(i)*time.Minute), time.Second,
+			rounds.Round{ID: id.Round(0)}, 0, channels.Sent, false)
+	}
+
+	// Rejoining and updating the channel must not lose its last activity
+	isAdmin, level := true, channels.NotifyPing
+	err = eventModel.UpdateChannelMetadata(
+		channelList[0].ReceptionID, &isAdmin, &level)
+	if err != nil {
+		t.Fatalf("Failed to update channel metadata: %+v", err)
+	}
+	eventModel.JoinChannel(channelList[0])
+
+	received, err := eventModel.GetChannels()
+	if err != nil {
+		t.Fatalf("Failed to get channels: %+v", err)
+	}
+
+	expectedOrder := []int{1, 0, 2}
+	if len(received) != len(expectedOrder) {
+		t.Fatalf("Unexpected number of channels.\nexpected: %d\nreceived: %d",
+			len(expectedOrder), len(received))
+	}
+	for i, channelIdx := range expectedOrder {
+		if !bytes.Equal(received[i].ID, channelList[channelIdx].ReceptionID[:]) {
+			t.Errorf("Unexpected channel at index %d.\nexpected: %s"+
+				"\nreceived: %s", i, channelList[channelIdx].Name,
+				received[i].Name)
+		}
+		if received[i].PrivacyLevel == nil ||
+			*received[i].PrivacyLevel != uint8(cryptoBroadcast.Private) {
+			t.Errorf("Unexpected privacy level for %s: %v",
+				received[i].Name, received[i].PrivacyLevel)
+		}
+		if received[i].JoinedAt.IsZero() {
+			t.Errorf("No join time for %s", received[i].Name)
+		}
+	}
+
+	if !received[1].IsAdmin ||
+		received[1].NotificationLevel != uint8(channels.NotifyPing) {
+		t.Errorf("Channel metadata not updated: %+v", received[1])
+	}
+	expectedActivity := start.Add(3 * time.Minute)
+	if !received[1].LastActivity.Equal(expectedActivity) {
+		t.Errorf("Unexpected last activity.\nexpected: %s\nreceived: %s",
+			expectedActivity, received[1].LastActivity)
+	}
+}
+
+// Tests that muting a user hides their messages in the channel, that
+// wasmModel.GetMessagesBySender returns them, and that unmuting only shows the
+// messages hidden by the mute. Tested with plaintext and encrypted metadata.
+func Test_wasmModel_MuteUser_HideMessagesBySender(t *testing.T) {
+	testString := "Test_wasmModel_MuteUser_HideMessagesBySender"
+	cipher, err := idbCrypto.NewCipher(
+		[]byte(testString), []byte("testSalt"), 32, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+
+	for _, extended := range []bool{false, true} {
+		t.Run(fmt.Sprintf("extended=%t", extended), func(t *testing.T) {
+			storage.GetLocalStorage().Clear()
+			var c idbCrypto.Cipher
+			if extended {
+				c = cipher
+			}
+			m, err := newWASMModel(
+				fmt.Sprintf("%s%t", testString, extended), c, dummyEU)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if extended {
+				if err = m.enableExtendedEncryption(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			channelID := id.NewIdFromString(testString, id.User, t)
+			muted, other := ed25519.PublicKey{1, 2, 3}, ed25519.PublicKey{4, 5, 6}
+			uuids := make([]uint64, 4)
+			for i := range uuids {
+				pubKey := muted
+				if i == 3 {
+					pubKey = other
+				}
+				testStr := testString + strconv.Itoa(i)
+				uuids[i] = m.ReceiveMessage(channelID,
+					message.DeriveChannelMessageID(channelID, 0, []byte(testStr)),
+					testStr, testStr, pubKey, 0, 0, netTime.Now(), time.Second,
+					rounds.Round{ID: id.Round(0)}, 0, channels.Sent, false)
+			}
+
+			// Hide the first message before muting so that it stays hidden
+			hidden := true
+			err = m.UpdateFromUUID(uuids[0], nil, nil, nil, nil, &hidden, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m.MuteUser(channelID, muted, false)
+			received, err := m.GetMessagesBySender(channelID, muted)
+			if err != nil {
+				t.Fatalf("Failed to get messages by sender: %+v", err)
+			} else if len(received) != 3 {
+				t.Fatalf("Unexpected number of messages."+
+					"\nexpected: %d\nreceived: %d", 3, len(received))
+			}
+			for _, msg := range received {
+				if !msg.Hidden || !bytes.Equal(msg.PubKey, muted) {
+					t.Errorf("Message not hidden after mute: %+v", msg)
+				}
+			}
+
+			m.MuteUser(channelID, muted, true)
+			for i, expected := range []bool{true, false, false, false} {
+				msg, err := m.GetMessage(message.DeriveChannelMessageID(
+					channelID, 0, []byte(testString+strconv.Itoa(i))))
+				if err != nil {
+					t.Fatal(err)
+				} else if msg.Hidden != expected {
+					t.Errorf("Unexpected hidden for message %d after unmute."+
+						"\nexpected: %t\nreceived: %t", i, expected, msg.Hidden)
+				}
+			}
+
+			// Showing the messages again must not change any
+			count, err := m.HideMessagesBySender(channelID, muted, false)
+			if err != nil {
+				t.Fatal(err)
+			} else if count != 0 {
+				t.Errorf("Unexpected number of messages changed."+
+					"\nexpected: %d\nreceived: %d", 0, count)
+			}
+		})
+	}
+}
+
+// Tests that wasmModel.ReceiveEdit replaces the text of the message with the
+// latest edit, records every version in the edit history, and rejects edits
+// from anyone other than the original sender.
+func Test_wasmModel_ReceiveEdit(t *testing.T) {
+	testString := "Test_wasmModel_ReceiveEdit"
+	cipher, err := idbCrypto.NewCipher(
+		[]byte(testString), []byte("testSalt"), 32, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+
+	for _, c := range []idbCrypto.Cipher{nil, cipher} {
+		t.Run(fmt.Sprintf("cipher=%t", c != nil), func(t *testing.T) {
+			storage.GetLocalStorage().Clear()
+			m, err := newWASMModel(
+				fmt.Sprintf("%s%t", testString, c != nil), c, dummyEU)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			author, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+			other, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+			channelID := id.NewIdFromString(testString, id.User, t)
+			msgID := message.DeriveChannelMessageID(
+				channelID, 0, []byte(testString))
+			now := netTime.Now()
+			m.ReceiveMessage(channelID, msgID, "nick", "original", author, 0, 0,
+				now, time.Second, rounds.Round{ID: id.Round(0)}, 0,
+				channels.Sent, false)
+
+			edit := func(n int, sender, authorKey ed25519.PublicKey,
+				text string, ts time.Time) error {
+				content, err := json.Marshal(impl.MessageEdit{
+					MessageID: msgID, AuthorKey: authorKey, Text: text})
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = m.ReceiveEdit(channelID, message.ID{byte(n)}, sender,
+					content, ts)
+				return err
+			}
+
+			if err = edit(1, other, other, "other", now); err == nil {
+				t.Error("Edit from another user accepted.")
+			}
+			if err = edit(2, other, author, "forged", now); err == nil {
+				t.Error("Edit with a forged author key accepted.")
+			}
+
+			// Receive the edits out of order; the latest must win
+			err = edit(3, author, author, "second", now.Add(2*time.Minute))
+			if err != nil {
+				t.Fatalf("Failed to receive edit: %+v", err)
+			}
+			err = edit(4, author, author, "first", now.Add(time.Minute))
+			if err != nil {
+				t.Fatalf("Failed to receive edit: %+v", err)
+			}
+
+			msgObj, err := impl.GetIndex(m.db, messageStoreName,
+				messageStoreMessageIndex, impl.EncodeBytes(msgID.Marshal()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := valueToMessage(msgObj)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decrypt := func(text string) string {
+				if c == nil {
+					return text
+				}
+				plaintext, err := c.Decrypt(text)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return string(plaintext)
+			}
+			if text := decrypt(msg.Text); text != "second" {
+				t.Errorf("Unexpected text.\nexpected: %q\nreceived: %q",
+					"second", text)
+			}
+			expected := []string{"original", "first", "second"}
+			if len(msg.EditHistory) != len(expected) {
+				t.Fatalf("Unexpected history length.\nexpected: %d\nreceived: %d",
+					len(expected), len(msg.EditHistory))
+			}
+			for i, record := range msg.EditHistory {
+				if text := decrypt(record.Text); text != expected[i] {
+					t.Errorf("Unexpected text of version %d."+
+						"\nexpected: %q\nreceived: %q", i, expected[i], text)
+				}
+			}
+		})
+	}
+}
+
+// Tests that a draft survives saving and loading with and without a cipher,
+// that ClearDraft keeps drafts saved after the cutoff, and that saving empty
+// text deletes the draft.
+func Test_wasmModel_Drafts(t *testing.T) {
+	testString := "Test_wasmModel_Drafts"
+	cipher, err := idbCrypto.NewCipher(
+		[]byte(testString), []byte("testSalt"), 32, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+
+	for _, c := range []idbCrypto.Cipher{nil, cipher} {
+		t.Run(fmt.Sprintf("cipher=%t", c != nil), func(t *testing.T) {
+			storage.GetLocalStorage().Clear()
+			m, err := newWASMModel(
+				fmt.Sprintf("%s%t", testString, c != nil), c, dummyEU)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			channelID := id.NewIdFromString(testString, id.User, t)
+			if draft, err := m.GetDraft(channelID); err != nil {
+				t.Fatal(err)
+			} else if draft != nil {
+				t.Errorf("Unexpected draft before saving: %+v", draft)
+			}
+
+			replyTo := message.ID{7}
+			if err = m.SaveDraft(channelID, "Hello, wor", &replyTo); err != nil {
+				t.Fatalf("Failed to save draft: %+v", err)
+			}
+			draft, err := m.GetDraft(channelID)
+			if err != nil {
+				t.Fatal(err)
+			} else if draft == nil {
+				t.Fatal("Draft not found.")
+			}
+			if draft.Text != "Hello, wor" {
+				t.Errorf("Unexpected text.\nexpected: %q\nreceived: %q",
+					"Hello, wor", draft.Text)
+			}
+			if !bytes.Equal(draft.ReplyTo, replyTo.Marshal()) {
+				t.Errorf("Unexpected reply to.\nexpected: %v\nreceived: %v",
+					replyTo.Marshal(), draft.ReplyTo)
+			}
+
+			// A draft saved after the message was sent is kept
+			err = m.ClearDraft(channelID, draft.Timestamp.Add(-time.Second))
+			if err != nil {
+				t.Fatalf("Failed to clear draft: %+v", err)
+			}
+			if draft, err = m.GetDraft(channelID); err != nil {
+				t.Fatal(err)
+			} else if draft == nil {
+				t.Error("Draft saved after the cutoff was cleared.")
+			}
+
+			err = m.ClearDraft(channelID, netTime.Now().Add(time.Second))
+			if err != nil {
+				t.Fatalf("Failed to clear draft: %+v", err)
+			}
+			if draft, err = m.GetDraft(channelID); err != nil {
+				t.Fatal(err)
+			} else if draft != nil {
+				t.Errorf("Draft not cleared: %+v", draft)
+			}
+
+			if err = m.SaveDraft(channelID, "Hello", nil); err != nil {
+				t.Fatalf("Failed to save draft: %+v", err)
+			}
+			if err = m.SaveDraft(channelID, "", nil); err != nil {
+				t.Fatalf("Failed to save empty draft: %+v", err)
+			}
+			if draft, err = m.GetDraft(channelID); err != nil {
+				t.Fatal(err)
+			} else if draft != nil {
+				t.Errorf("Saving empty text did not delete draft: %+v", draft)
+			}
+		})
+	}
+}
+
+// Tests that SearchMessages finds messages by word prefix in every channel or
+// one channel, returns their decrypted text with highlights, skips hidden
+// messages, and follows edits, deletions, and leaving a channel, with and
+// without a cipher.
+func Test_wasmModel_SearchMessages(t *testing.T) {
+	testString := "Test_wasmModel_SearchMessages"
+	cipher, err := idbCrypto.NewCipher(
+		[]byte(testString), []byte("testSalt"), 32, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+
+	for _, c := range []idbCrypto.Cipher{nil, cipher} {
+		t.Run(fmt.Sprintf("cipher=%t", c != nil), func(t *testing.T) {
+			storage.GetLocalStorage().Clear()
+			m, err := newWASMModel(
+				fmt.Sprintf("%s%t", testString, c != nil), c, dummyEU)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			author, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+			channel1 := id.NewIdFromString(testString+"1", id.User, t)
+			channel2 := id.NewIdFromString(testString+"2", id.User, t)
+			now := netTime.Now()
+			receive := func(channelID *id.ID, text string, n int,
+				hidden bool) message.ID {
+				msgID := message.DeriveChannelMessageID(
+					channelID, 0, []byte(text))
+				m.ReceiveMessage(channelID, msgID, "nick", text, author, 0, 0,
+					now.Add(time.Duration(n)*time.Second), time.Second,
+					rounds.Round{ID: id.Round(n)}, channels.Text,
+					channels.Delivered, hidden)
+				return msgID
+			}
+			helloWorld := receive(channel1, "Hello world", 0, false)
+			goodbyeWorld := receive(channel1, "Goodbye world", 1, false)
+			receive(channel1, "Hello from a hidden message", 2, true)
+			receive(channel2, "Hello there", 3, false)
+
+			search := func(query string, channelID *id.ID, limit int) []string {
+				results, err := m.SearchMessages(query, channelID, limit)
+				if err != nil {
+					t.Fatalf("Failed to search for %q: %+v", query, err)
+				}
+				texts := make([]string, len(results))
+				for i, result := range results {
+					texts[i] = string(result.Content)
+				}
+				return texts
+			}
+			check := func(query string, channelID *id.ID, limit int,
+				expected ...string) {
+				texts := search(query, channelID, limit)
+				if fmt.Sprint(texts) != fmt.Sprint(expected) {
+					t.Errorf("Unexpected results for %q."+
+						"\nexpected: %q\nreceived: %q", query, expected, texts)
+				}
+			}
+
+			check("HEL", nil, 0, "Hello there", "Hello world")
+			check("hel", channel1, 0, "Hello world")
+			check("hel wor", nil, 0, "Hello world")
+			check("world", nil, 1, "Goodbye world")
+			check("hello moon", nil, 0)
+
+			results, err := m.SearchMessages("hel wor", nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := []impl.Highlight{{0, 3}, {6, 9}}
+			if fmt.Sprint(results[0].Highlights) != fmt.Sprint(expected) {
+				t.Errorf("Unexpected highlights.\nexpected: %v\nreceived: %v",
+					expected, results[0].Highlights)
+			}
+
+			if _, err = m.SearchMessages("a", nil, 0); err == nil {
+				t.Error("Searching for a query without words did not fail.")
+			}
+
+			// With a cipher, the index must not contain plaintext tokens
+			tokenObjs, err := impl.GetAll(m.db, searchStoreName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var plaintextFound bool
+			for _, tokenObj := range tokenObjs {
+				if tokenObj.Get(searchStoreToken).String() == "hell" {
+					plaintextFound = true
+				}
+			}
+			if plaintextFound != (c == nil) {
+				t.Errorf("Plaintext token in index: %t", plaintextFound)
+			}
+
+			// Edited text replaces the old text in the index
+			content, err := json.Marshal(impl.MessageEdit{MessageID: goodbyeWorld,
+				AuthorKey: author, Text: "Goodbye moon"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = m.ReceiveEdit(channel1, message.ID{1}, author, content,
+				now.Add(time.Minute))
+			if err != nil {
+				t.Fatalf("Failed to receive edit: %+v", err)
+			}
+			check("world", nil, 0, "Hello world")
+			check("moo", nil, 0, "Goodbye moon")
+
+			// The index is rebuilt from the messages
+			if err = m.rebuildSearchIndex(); err != nil {
+				t.Fatalf("Failed to rebuild search index: %+v", err)
+			}
+			check("moo", nil, 0, "Goodbye moon")
+
+			if err = m.DeleteMessage(helloWorld); err != nil {
+				t.Fatalf("Failed to delete message: %+v", err)
+			}
+			check("hel", nil, 0, "Hello there")
+
+			m.LeaveChannel(channel2)
+			check("hel", nil, 0)
+			tokenObjs, err = impl.GetAllIndex(m.db, searchStoreName,
+				searchStoreChannelIndex, impl.EncodeBytes(channel2.Marshal()))
+			if err != nil {
+				t.Fatal(err)
+			} else if len(tokenObjs) != 0 {
+				t.Errorf("%d search tokens left after leaving the channel.",
+					len(tokenObjs))
+			}
+		})
+	}
+}
+
+// Tests that ReceiveMessage stores the search tokens of a message with it and
+// that receiving the message again, which fails the batch and falls back to
+// updating the existing message, does not store any other tokens.
+func Test_wasmModel_ReceiveMessage_SearchTokens(t *testing.T) {
+	testString := "Test_wasmModel_ReceiveMessage_SearchTokens"
+	storage.GetLocalStorage().Clear()
+	m, err := newWASMModel(testString, nil, dummyEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	author, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+	channelID := id.NewIdFromString(testString, id.User, t)
+	text := "Hello searchable world"
+	msgID := message.DeriveChannelMessageID(channelID, 0, []byte(text))
+	receive := func() uint64 {
+		return m.ReceiveMessage(channelID, msgID, "nick", text, author, 0, 0,
+			netTime.Now(), time.Second, rounds.Round{ID: 1}, channels.Text,
+			channels.Delivered, false)
+	}
+
+	uuid := receive()
+	if uuid == 0 {
+		t.Fatal("Failed to receive message.")
+	}
+	expected := len(impl.SearchTokens(text))
+	tokenObjs, err := impl.GetAllIndex(
+		m.db, searchStoreName, searchStoreMessageIndex, js.ValueOf(uuid))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(tokenObjs) != expected {
+		t.Errorf("Unexpected number of search tokens for message."+
+			"\nexpected: %d\nreceived: %d", expected, len(tokenObjs))
+	}
+
+	if duplicateUUID := receive(); duplicateUUID != uuid {
+		t.Errorf("Unexpected UUID for duplicate message."+
+			"\nexpected: %d\nreceived: %d", uuid, duplicateUUID)
+	}
+	if count, err := impl.Count(m.db, searchStoreName); err != nil {
+		t.Fatal(err)
+	} else if count != expected {
+		t.Errorf("Unexpected number of search tokens after duplicate."+
+			"\nexpected: %d\nreceived: %d", expected, count)
+	}
+}
@@ -11,6 +11,7 @@ package main
 
 import (
 	"syscall/js"
+	"time"
 
 	"github.com/hack-pad/go-indexeddb/idb"
 	jww "github.com/spf13/jwalterweatherman"
@@ -18,11 +19,12 @@ import (
 	"gitlab.com/elixxir/client/v4/channels"
 	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
 	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+	"gitlab.com/xx_network/primitives/id"
 )
 
 // currentVersion is the current version of the IndexedDb runtime. Used for
 // migration purposes.
-const currentVersion uint = 1
+const currentVersion uint = 8
 
 // eventUpdate takes an event type and JSON object from
 // bindings/channelsCallbacks.go.
@@ -31,18 +33,37 @@ type eventUpdate func(eventType int64, jsonMarshallable any)
 // NewWASMEventModel returns a [channels.EventModel] backed by a wasmModel.
 // The name should be a base64 encoding of the users public key. Returns the
 // EventModel based on IndexedDb and the database name as reported by IndexedDb.
+//
+// If extendedEncryption is true, message metadata is also encrypted (see
+// wasmModel.enableExtendedEncryption).
 func NewWASMEventModel(databaseName string, encryption idbCrypto.Cipher,
-	eventCallback eventUpdate) (channels.EventModel, error) {
-	return newWASMModel(databaseName, encryption, eventCallback)
+	extendedEncryption bool, eventCallback eventUpdate) (channels.EventModel, error) {
+	model, err := newWASMModel(databaseName, encryption, eventCallback)
+	if err != nil {
+		return nil, err
+	}
+
+	if extendedEncryption {
+		if err = model.enableExtendedEncryption(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Warn the UI before the storage quota is reached
+	if storageWarningThreshold > 0 {
+		impl.NewStorageMonitor(storageWarningThreshold, eventCallback).
+			Start(storageCheckInterval)
+	}
+
+	return model, nil
 }
 
 // newWASMModel creates the given [idb.Database] and returns a wasmModel.
 func newWASMModel(databaseName string, encryption idbCrypto.Cipher,
 	eventCallback eventUpdate) (*wasmModel, error) {
 	// Attempt to open database object
-	ctx, cancel := impl.NewContext()
-	defer cancel()
-	openRequest, err := idb.Global().Open(ctx, databaseName, currentVersion,
+	var migrateChannels, buildSearchIndex, encryptFiles bool
+	db, err := impl.Open(databaseName, currentVersion,
 		func(db *idb.Database, oldVersion, newVersion uint) error {
 			if oldVersion == newVersion {
 				jww.INFO.Printf("IndexDb version for %s is current: v%d",
@@ -61,26 +82,102 @@ func newWASMModel(databaseName string, encryption idbCrypto.Cipher,
 				oldVersion = 1
 			}
 
-			// if oldVersion == 1 && newVersion >= 2 { v2Upgrade(), oldVersion = 2 }
+			if oldVersion == 1 && newVersion >= 2 {
+				err := v2Upgrade(db)
+				if err != nil {
+					return err
+				}
+				oldVersion = 2
+			}
+
+			if oldVersion == 2 && newVersion >= 3 {
+				// The metadata of channels joined before v3 is filled in by
+				// wasmModel.migrateChannels once the database is open
+				migrateChannels = true
+				oldVersion = 3
+			}
+
+			if oldVersion == 3 && newVersion >= 4 {
+				err := v4Upgrade(db)
+				if err != nil {
+					return err
+				}
+				oldVersion = 4
+			}
+
+			if oldVersion == 4 && newVersion >= 5 {
+				err := v5Upgrade(db)
+				if err != nil {
+					return err
+				}
+				oldVersion = 5
+			}
+
+			if oldVersion == 5 && newVersion >= 6 {
+				err := v6Upgrade(db)
+				if err != nil {
+					return err
+				}
+				// The messages stored before v6 are indexed by
+				// wasmModel.rebuildSearchIndex once the database is open
+				buildSearchIndex = true
+				oldVersion = 6
+			}
+
+			if oldVersion == 6 && newVersion >= 7 {
+				// The files stored in plaintext before v7 are encrypted by
+				// wasmModel.encryptExistingFiles once the database is open
+				encryptFiles = true
+				oldVersion = 7
+			}
+
+			if oldVersion == 7 && newVersion >= 8 {
+				// The search index built before v8 has a token for every
+				// prefix of each word. It is rebuilt with the bucketed
+				// prefixes by wasmModel.rebuildSearchIndex once the database
+				// is open.
+				buildSearchIndex = true
+				oldVersion = 8
+			}
+
+			// if oldVersion == 8 && newVersion >= 9 { v9Upgrade(), oldVersion = 9 }
 			return nil
 		})
 	if err != nil {
 		return nil, err
 	}
 
-	// Wait for database open to finish
-	db, err := openRequest.Await(ctx)
-	if err != nil {
-		return nil, err
-	} else if ctx.Err() != nil {
-		return nil, ctx.Err()
-	}
+	// Send quota and connection events to the UI
+	impl.Watch(db, eventCallback)
 
 	wrapper := &wasmModel{
 		db:            db,
 		cipher:        encryption,
 		eventCallback: eventCallback,
+		batch: impl.NewWriteBatcher(db, messageStoreName,
+			impl.DefaultBatchWindow, impl.DefaultMaxBatchSize,
+			searchStoreName),
+		lastActivity: make(map[id.ID]time.Time),
+	}
+
+	if encryptFiles {
+		if err = wrapper.encryptExistingFiles(); err != nil {
+			return nil, err
+		}
+	}
+
+	if migrateChannels {
+		if err = wrapper.migrateChannels(); err != nil {
+			return nil, err
+		}
+	}
+
+	if buildSearchIndex {
+		if err = wrapper.rebuildSearchIndex(); err != nil {
+			return nil, err
+		}
 	}
+
 	return wrapper, nil
 }
 
@@ -146,3 +243,87 @@ func v1Upgrade(db *idb.Database) error {
 	})
 	return err
 }
+
+// v2Upgrade performs the v1 -> v2 database upgrade, adding the object store
+// that bad values are moved to by wasmModel.CheckDatabase.
+//
+// This can never be changed without permanently breaking backwards
+// compatibility.
+func v2Upgrade(db *idb.Database) error {
+	return impl.CreateQuarantineStore(db)
+}
+
+// v4Upgrade performs the v3 -> v4 database upgrade, adding the indexes used to
+// look up the messages of a sender in a channel.
+//
+// This can never be changed without permanently breaking backwards
+// compatibility.
+func v4Upgrade(db *idb.Database) error {
+	indexOpts := idb.IndexOptions{
+		Unique:     false,
+		MultiEntry: false,
+	}
+	err := impl.CreateIndex(db, messageStoreName, messageStoreSenderIndex,
+		js.ValueOf([]any{messageStoreChannel, messageStorePubkey}), indexOpts)
+	if err != nil {
+		return err
+	}
+	return impl.CreateIndex(db, messageStoreName, messageStoreBlindSenderIndex,
+		js.ValueOf([]any{messageStoreChannel, messageStoreBlindKey}), indexOpts)
+}
+
+// v5Upgrade performs the v4 -> v5 database upgrade, adding the object store
+// for the draft of each channel.
+//
+// This can never be changed without permanently breaking backwards
+// compatibility.
+func v5Upgrade(db *idb.Database) error {
+	_, err := db.CreateObjectStore(draftStoreName, idb.ObjectStoreOptions{
+		KeyPath:       js.ValueOf(pkeyName),
+		AutoIncrement: false,
+	})
+	return err
+}
+
+// v6Upgrade performs the v5 -> v6 database upgrade, adding the object store
+// for the full-text search index of message text.
+//
+// This can never be changed without permanently breaking backwards
+// compatibility.
+func v6Upgrade(db *idb.Database) error {
+	indexOpts := idb.IndexOptions{
+		Unique:     false,
+		MultiEntry: false,
+	}
+
+	searchStore, err := db.CreateObjectStore(searchStoreName,
+		idb.ObjectStoreOptions{
+			KeyPath: js.ValueOf(
+				[]any{searchStoreToken, searchStoreMessage}),
+			AutoIncrement: false,
+		})
+	if err != nil {
+		return err
+	}
+	_, err = searchStore.CreateIndex(searchStoreMessageIndex,
+		js.ValueOf(searchStoreMessage), indexOpts)
+	if err != nil {
+		return err
+	}
+	_, err = searchStore.CreateIndex(searchStoreChannelIndex,
+		js.ValueOf(searchStoreChannel), indexOpts)
+	return err
+}
+
+// v7Upgrade performs the v6 -> v7 database upgrade, adding the object store
+// that records which one-time migrations have finished.
+//
+// This can never be changed without permanently breaking backwards
+// compatibility.
+func v7Upgrade(db *idb.Database) error {
+	_, err := db.CreateObjectStore(migrationStoreName, idb.ObjectStoreOptions{
+		KeyPath:       js.ValueOf(pkeyName),
+		AutoIncrement: false,
+	})
+	return err
+}
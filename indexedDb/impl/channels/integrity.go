@@ -0,0 +1,179 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strconv"
+	"syscall/js"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+)
+
+// CheckDatabase validates every channel, file, message, draft, and search
+// token in the database. Each value must unmarshal into its type, have its
+// required and indexed fields set, and decrypt with the cipher. Each message
+// must belong to a channel in the database, have a unique message ID, and have
+// the blind index of its sender if its metadata is encrypted. Each draft must
+// belong to a channel and each search token to a message in the same channel.
+//
+// If repair is true, bad values are moved to the quarantine store. Because
+// channels are checked first, the messages and drafts of a bad channel are
+// also moved, as are the search tokens of a bad message.
+func (w *wasmModel) CheckDatabase(repair bool) (*impl.IntegrityReport, error) {
+	databaseName, err := w.db.Name()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get database name")
+	}
+	c := impl.NewIntegrityChecker(w.db, databaseName, repair)
+
+	// Check channels and save the IDs of the valid ones
+	channels := make(map[string]bool)
+	_, err = c.CheckStore(channelStoreName, pkeyName,
+		func(value js.Value) (impl.IntegrityProblem, string) {
+			channel, err := valueToChannel(value)
+			if err != nil {
+				return impl.InvalidJSON, err.Error()
+			} else if len(channel.ID) == 0 {
+				return impl.MissingField, "channel has no ID"
+			}
+			channels[base64.StdEncoding.EncodeToString(channel.ID)] = true
+			return "", ""
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.CheckStore(fileStoreName, pkeyName,
+		func(value js.Value) (impl.IntegrityProblem, string) {
+			file, err := valueToFile(value)
+			if err != nil {
+				return impl.InvalidJSON, err.Error()
+			} else if len(file.Id) == 0 {
+				return impl.MissingField, "file has no ID"
+			} else if err = w.decryptFile(file); err != nil {
+				return impl.Undecryptable, err.Error()
+			}
+			return "", ""
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	// Check messages and save the channel of the valid ones by UUID
+	messageIDs := make(map[string]bool)
+	messages := make(map[uint64]string)
+	_, err = c.CheckStore(messageStoreName, pkeyName,
+		func(value js.Value) (impl.IntegrityProblem, string) {
+			stored, err := valueToMessage(value)
+			if err != nil {
+				return impl.InvalidJSON, err.Error()
+			}
+			msg, err := w.decryptedMessage(value)
+			if err != nil {
+				return impl.Undecryptable, err.Error()
+			}
+
+			messageID := base64.StdEncoding.EncodeToString(msg.MessageID)
+			channelID := base64.StdEncoding.EncodeToString(msg.ChannelID)
+			switch {
+			case len(msg.MessageID) == 0:
+				return impl.MissingField, "message has no message ID"
+			case len(msg.ChannelID) == 0:
+				return impl.MissingField, "message has no channel ID"
+			case msg.Timestamp.IsZero():
+				return impl.MissingField, "message has no timestamp"
+			case !channels[channelID]:
+				return impl.MissingReference,
+					"channel " + channelID + " does not exist"
+			case messageIDs[messageID]:
+				return impl.Duplicate,
+					"message ID " + messageID + " is already stored"
+			}
+
+			// The sender index of a message with encrypted metadata is the
+			// blind index of the public key
+			if stored.Metadata != nil {
+				pubkeyIndex, err := impl.BlindIndex(w.cipher, msg.Pubkey)
+				if err != nil {
+					return impl.Undecryptable, err.Error()
+				} else if !bytes.Equal(stored.PubkeyIndex, pubkeyIndex) {
+					return impl.IndexMismatch, "pubkey index does not " +
+						"match the public key of the sender"
+				}
+			}
+
+			messageIDs[messageID] = true
+			messages[msg.ID] = channelID
+			return "", ""
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.CheckStore(draftStoreName, pkeyName,
+		func(value js.Value) (impl.IntegrityProblem, string) {
+			draft, err := valueToDraft(value)
+			if err != nil {
+				return impl.InvalidJSON, err.Error()
+			}
+
+			channelID := base64.StdEncoding.EncodeToString(draft.ID)
+			switch {
+			case len(draft.ID) == 0:
+				return impl.MissingField, "draft has no channel ID"
+			case !channels[channelID]:
+				return impl.MissingReference,
+					"channel " + channelID + " does not exist"
+			}
+
+			if w.cipher != nil && draft.Text != "" {
+				if _, err = w.cipher.Decrypt(draft.Text); err != nil {
+					return impl.Undecryptable, errors.WithMessage(
+						err, "failed to decrypt draft text").Error()
+				}
+			}
+			return "", ""
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.CheckCompoundStore(searchStoreName,
+		[]string{searchStoreToken, searchStoreMessage},
+		func(value js.Value) (impl.IntegrityProblem, string) {
+			token, err := valueToSearchToken(value)
+			if err != nil {
+				return impl.InvalidJSON, err.Error()
+			}
+
+			channelID, exists := messages[token.MessageID]
+			switch {
+			case token.Token == "":
+				return impl.MissingField, "search token has no token"
+			case !exists:
+				return impl.MissingReference, "message " +
+					strconv.FormatUint(token.MessageID, 10) + " does not exist"
+			case base64.StdEncoding.EncodeToString(token.ChannelID) != channelID:
+				return impl.IndexMismatch, "channel ID does not match the " +
+					"channel of message " +
+					strconv.FormatUint(token.MessageID, 10)
+			}
+			return "", ""
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Report(), nil
+}
@@ -13,11 +13,13 @@ import (
 	"fmt"
 	"os"
 	"syscall/js"
+	"time"
 
 	"github.com/spf13/cobra"
 	jww "github.com/spf13/jwalterweatherman"
 
 	"gitlab.com/elixxir/wasm-utils/exception"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
 	"gitlab.com/elixxir/xxdk-wasm/logging"
 	"gitlab.com/elixxir/xxdk-wasm/worker"
 )
@@ -51,6 +53,12 @@ var channelsCmd = &cobra.Command{
 
 		jww.INFO.Printf("xxDK channels web worker version: v%s", SEMVER)
 
+		// Configure the timeout and retries of storage operations
+		impl.SetTimeout(dbTimeout)
+		retryPolicy := impl.DefaultRetryPolicy()
+		retryPolicy.MaxAttempts = dbMaxAttempts
+		impl.SetRetryPolicy(retryPolicy)
+
 		jww.INFO.Print("[WW] Starting xxDK WebAssembly Channels Database Worker.")
 		tm, err := worker.NewThreadManager("ChannelsIndexedDbWorker", true)
 		if err != nil {
@@ -88,6 +96,11 @@ var channelsCmd = &cobra.Command{
 var (
 	logLevel       jww.Threshold
 	threadLogLevel jww.Threshold
+	dbTimeout      time.Duration
+	dbMaxAttempts  int
+
+	storageWarningThreshold float64
+	storageCheckInterval    time.Duration
 )
 
 func init() {
@@ -102,4 +115,17 @@ func init() {
 		"The log level when outputting to the worker file buffer. "+
 			"0 = TRACE, 1 = DEBUG, 2 = INFO, 3 = WARN, 4 = ERROR, "+
 			"5 = CRITICAL, 6 = FATAL, -1 = disabled.")
+	channelsCmd.Flags().DurationVar(&dbTimeout, "dbTimeout", impl.DefaultTimeout,
+		"The timeout for each IndexedDb operation.")
+	channelsCmd.Flags().IntVar(&dbMaxAttempts, "dbMaxAttempts",
+		impl.DefaultRetryPolicy().MaxAttempts,
+		"The maximum number of times an IndexedDb operation is attempted "+
+			"when it fails with a transient error. 1 = no retries.")
+	channelsCmd.Flags().Float64Var(&storageWarningThreshold,
+		"storageWarningThreshold", impl.DefaultStorageWarningThreshold,
+		"The fraction of the storage quota that, once used, sends a storage "+
+			"warning event to the UI. 0 = disabled.")
+	channelsCmd.Flags().DurationVar(&storageCheckInterval,
+		"storageCheckInterval", impl.DefaultStorageCheckInterval,
+		"The time between checks of the storage usage.")
 }
@@ -0,0 +1,231 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"syscall/js"
+	"time"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/client/v4/channels"
+	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// GetChannels returns every joined channel sorted by last activity, newest
+// first. Channels with the same last activity are sorted by name.
+func (w *wasmModel) GetChannels() ([]*Channel, error) {
+	channelObjs, err := impl.GetAll(w.db, channelStoreName)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get channels")
+	}
+
+	result := make([]*Channel, 0, len(channelObjs))
+	for _, channelObj := range channelObjs {
+		channel, err := valueToChannel(channelObj)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to unmarshal channel")
+		}
+		result = append(result, channel)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if !result[i].LastActivity.Equal(result[j].LastActivity) {
+			return result[i].LastActivity.After(result[j].LastActivity)
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// UpdateChannelMetadata sets the admin status and notification level of the
+// channel. Nil values are left unchanged. Returns an error containing
+// [impl.ErrDoesNotExist] if the channel has not been joined.
+func (w *wasmModel) UpdateChannelMetadata(channelID *id.ID, isAdmin *bool,
+	level *channels.NotificationLevel) error {
+	return w.updateChannel(channelID, func(channel *Channel) bool {
+		changed := false
+		if isAdmin != nil && channel.IsAdmin != *isAdmin {
+			channel.IsAdmin = *isAdmin
+			changed = true
+		}
+		if level != nil && channel.NotificationLevel != uint8(*level) {
+			channel.NotificationLevel = uint8(*level)
+			changed = true
+		}
+		return changed
+	})
+}
+
+// touchChannel sets the last activity of the channel to the timestamp if it is
+// newer. The last activity of each channel is cached so that the channel is
+// only written when a newer message arrives.
+func (w *wasmModel) touchChannel(channelID *id.ID, timestamp time.Time) {
+	w.lastActivityMux.Lock()
+	defer w.lastActivityMux.Unlock()
+
+	if last, exists := w.lastActivity[*channelID]; exists &&
+		!timestamp.After(last) {
+		return
+	}
+
+	err := w.updateChannel(channelID, func(channel *Channel) bool {
+		if !timestamp.After(channel.LastActivity) {
+			w.lastActivity[*channelID] = channel.LastActivity
+			return false
+		}
+		channel.LastActivity = timestamp
+		return true
+	})
+	if err != nil {
+		// Messages may arrive for a channel that has already been left
+		if !strings.Contains(err.Error(), impl.ErrDoesNotExist) {
+			jww.ERROR.Printf("[CH] Failed to update last activity of "+
+				"channel %s: %+v", channelID, err)
+		}
+		return
+	}
+	if timestamp.After(w.lastActivity[*channelID]) {
+		w.lastActivity[*channelID] = timestamp
+	}
+}
+
+// forgetChannel removes the channel from the last activity cache.
+func (w *wasmModel) forgetChannel(channelID *id.ID) {
+	w.lastActivityMux.Lock()
+	defer w.lastActivityMux.Unlock()
+	delete(w.lastActivity, *channelID)
+}
+
+// updateChannel gets the channel, calls update on it, and, if update returns
+// true, stores it in a single transaction.
+func (w *wasmModel) updateChannel(
+	channelID *id.ID, update func(channel *Channel) bool) error {
+	return impl.Transact(w.db, []string{channelStoreName},
+		func(txn *impl.Transaction) error {
+			channelObj, err := txn.Get(
+				channelStoreName, impl.EncodeBytes(channelID.Marshal()))
+			if err != nil {
+				return err
+			}
+			channel, err := valueToChannel(channelObj)
+			if err != nil {
+				return errors.Errorf("Unable to unmarshal Channel: %+v", err)
+			}
+
+			if !update(channel) {
+				return nil
+			}
+			return w.putChannel(txn, channel)
+		})
+}
+
+// putChannel stores the Channel in the transaction.
+func (w *wasmModel) putChannel(txn *impl.Transaction, channel *Channel) error {
+	channelJson, err := json.Marshal(channel)
+	if err != nil {
+		return errors.Errorf("Unable to marshal Channel: %+v", err)
+	}
+	channelObj, err := utils.JsonToJS(channelJson)
+	if err != nil {
+		return errors.Errorf("Unable to marshal Channel: %+v", err)
+	}
+
+	if _, err = txn.Put(channelStoreName, channelObj); err != nil {
+		return errors.Errorf("Unable to put Channel: %+v", err)
+	}
+	return nil
+}
+
+// migrateChannels fills in the join time and last activity of channels joined
+// before they were recorded from the timestamps of their oldest and newest
+// messages.
+func (w *wasmModel) migrateChannels() error {
+	type activity struct{ first, last time.Time }
+	activities := make(map[string]*activity)
+	err := impl.NewQuery(messageStoreName).Iter(w.db, func(value js.Value) error {
+		msg, err := valueToMessage(value)
+		if err != nil {
+			return err
+		}
+		key := base64.StdEncoding.EncodeToString(msg.ChannelID)
+		if a, exists := activities[key]; !exists {
+			activities[key] = &activity{msg.Timestamp, msg.Timestamp}
+		} else if msg.Timestamp.Before(a.first) {
+			a.first = msg.Timestamp
+		} else if msg.Timestamp.After(a.last) {
+			a.last = msg.Timestamp
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.WithMessage(err, "failed to migrate channels")
+	}
+
+	numMigrated, err := impl.UpdateAll(w.db, channelStoreName,
+		func(channelObj js.Value) (js.Value, bool, error) {
+			channel, err := valueToChannel(channelObj)
+			if err != nil {
+				return js.Undefined(), false, err
+			}
+			a, exists := activities[base64.StdEncoding.EncodeToString(channel.ID)]
+			if !exists || !channel.LastActivity.IsZero() {
+				return js.Undefined(), false, nil
+			}
+
+			channel.JoinedAt = a.first
+			channel.LastActivity = a.last
+			return marshalToJS(channel)
+		})
+	if err != nil {
+		return errors.WithMessage(err, "failed to migrate channels")
+	}
+
+	if numMigrated > 0 {
+		jww.INFO.Printf("[CH] Migrated metadata of %d channels", numMigrated)
+	}
+	return nil
+}
+
+// migrationDone returns true if the Migration with the given name has finished.
+func (w *wasmModel) migrationDone(name string) (bool, error) {
+	_, err := impl.Get(w.db, migrationStoreName, js.ValueOf(name))
+	if err != nil {
+		if strings.Contains(err.Error(), impl.ErrDoesNotExist) {
+			return false, nil
+		}
+		return false, errors.WithMessagef(
+			err, "failed to get migration %q", name)
+	}
+	return true, nil
+}
+
+// finishMigration records that the Migration with the given name has finished.
+func (w *wasmModel) finishMigration(name string) error {
+	migrationJson, err := json.Marshal(Migration{ID: name})
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal migration %q", name)
+	}
+	migrationObj, err := utils.JsonToJS(migrationJson)
+	if err != nil {
+		return err
+	}
+	if _, err = impl.Put(w.db, migrationStoreName, migrationObj); err != nil {
+		return errors.WithMessagef(err, "failed to store migration %q", name)
+	}
+	return nil
+}
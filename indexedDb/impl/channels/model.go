@@ -11,6 +11,8 @@ package main
 
 import (
 	"time"
+
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
 )
 
 const (
@@ -21,6 +23,10 @@ const (
 	messageStoreName = "messages"
 	channelStoreName = "channels"
 	fileStoreName    = "files"
+	draftStoreName   = "drafts"
+	searchStoreName  = "search_index"
+
+	migrationStoreName = "migrations"
 
 	// Message index names.
 	messageStoreMessageIndex   = "message_id_index"
@@ -29,12 +35,30 @@ const (
 	messageStoreTimestampIndex = "timestamp_index"
 	messageStorePinnedIndex    = "pinned_index"
 
+	// messageStoreSenderIndex and messageStoreBlindSenderIndex index messages
+	// by channel and sender. Messages with plaintext metadata are in the first
+	// and messages with encrypted metadata are in the second, keyed by the
+	// blind index of the public key.
+	messageStoreSenderIndex      = "sender_index"
+	messageStoreBlindSenderIndex = "blind_sender_index"
+
 	// Message keyPath names (must match json struct tags).
 	messageStoreMessage   = "message_id"
 	messageStoreChannel   = "channel_id"
 	messageStoreParent    = "parent_message_id"
 	messageStoreTimestamp = "timestamp"
 	messageStorePinned    = "pinned"
+	messageStorePubkey    = "pubkey"
+	messageStoreBlindKey  = "pubkey_index"
+
+	// SearchToken index names.
+	searchStoreMessageIndex = "search_message_index"
+	searchStoreChannelIndex = "search_channel_index"
+
+	// SearchToken keyPath names (must match json struct tags).
+	searchStoreToken   = "token"
+	searchStoreMessage = "message"
+	searchStoreChannel = "channel_id"
 )
 
 // Message defines the IndexedDb representation of a single Message.
@@ -64,6 +88,31 @@ type Message struct {
 	Pubkey         []byte `json:"pubkey"`
 	DmToken        uint32 `json:"dm_token"`
 	CodesetVersion uint8  `json:"codeset_version"`
+
+	// Metadata is the encrypted messageMetadata when extended encryption is
+	// enabled. Nickname, Pubkey, and DmToken are left empty.
+	Metadata []byte `json:"metadata,omitempty"`
+
+	// PubkeyIndex is the keyed blind index of the Pubkey when extended
+	// encryption is enabled.
+	PubkeyIndex []byte `json:"pubkey_index,omitempty"`
+
+	// HiddenByMute is true if the message was hidden because its sender was
+	// muted. Only these messages are shown again when the sender is unmuted.
+	HiddenByMute bool `json:"hidden_by_mute,omitempty"`
+
+	// EditHistory contains every version of the text, oldest first, if the
+	// message was edited. Text is always the latest version.
+	EditHistory []impl.EditRecord `json:"edit_history,omitempty"`
+}
+
+// messageMetadata contains the fields of a Message that identify the sender.
+// It is JSON marshalled and encrypted into Message.Metadata when extended
+// encryption is enabled.
+type messageMetadata struct {
+	Nickname string `json:"nickname"`
+	Pubkey   []byte `json:"pubkey"`
+	DmToken  uint32 `json:"dm_token"`
 }
 
 // Channel defines the IndexedDb representation of a single Channel.
@@ -73,6 +122,26 @@ type Channel struct {
 	ID          []byte `json:"id"` // Matches pkeyName
 	Name        string `json:"name"`
 	Description string `json:"description"`
+
+	// PrivacyLevel is the [cryptoBroadcast.PrivacyLevel] of the channel. It is
+	// nil for channels joined before it was recorded.
+	PrivacyLevel *uint8 `json:"privacy_level"`
+
+	// JoinedAt is the time the channel was joined. For channels joined before
+	// it was recorded, it is the timestamp of the oldest message in the
+	// channel, if any.
+	JoinedAt time.Time `json:"joined_at"`
+
+	// IsAdmin is true if the user holds the private key of the channel.
+	IsAdmin bool `json:"is_admin"`
+
+	// LastActivity is the timestamp of the newest message, reply, or reaction
+	// in the channel.
+	LastActivity time.Time `json:"last_activity"`
+
+	// NotificationLevel is the [channels.NotificationLevel] of the channel. It
+	// is zero until set from the channels manager.
+	NotificationLevel uint8 `json:"notification_level"`
 }
 
 // File defines the IndexedDb representation of a single File.
@@ -91,4 +160,46 @@ type File struct {
 
 	// Status of the file in the event model.
 	Status uint8 `json:"status"`
+
+	// Encrypted is true if Data and Link are encrypted with the database
+	// cipher. Each is stored as the JSON of the encrypted chunks returned by
+	// [impl.EncryptChunked].
+	Encrypted bool `json:"encrypted"`
+}
+
+// Draft defines the IndexedDb representation of the unsent text of a Channel.
+//
+// A Channel has at most one Draft.
+type Draft struct {
+	ID []byte `json:"id"` // Matches pkeyName; the channel ID
+
+	// Text is encrypted with the database cipher, if there is one.
+	Text string `json:"text"`
+
+	// ReplyTo is the message ID of the message being replied to, if any.
+	ReplyTo []byte `json:"reply_to,omitempty"`
+
+	// Timestamp is the last time the draft was saved.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// SearchToken defines the IndexedDb representation of a single token in the
+// full-text search index. The primary key is the pair of Token and MessageID.
+//
+// A Message has one SearchToken for each token of its text (see
+// [impl.SearchTokens]).
+type SearchToken struct {
+	// Token is the normalized token or, if there is a cipher, its blind index
+	// (see [impl.SearchIndexKey]).
+	Token string `json:"token"`
+
+	MessageID uint64 `json:"message"`    // Index; the UUID of the Message
+	ChannelID []byte `json:"channel_id"` // Index
+}
+
+// Migration defines the IndexedDb representation of a one-time migration of
+// the values already in the database. It is stored once the migration has
+// finished so that it is not run on every open.
+type Migration struct {
+	ID string `json:"id"` // Matches pkeyName; the name of the migration
 }
@@ -0,0 +1,314 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"syscall/js"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/client/v4/channels"
+	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// SearchResult is a message that matched a search and the ranges of the
+// matched words in its text.
+type SearchResult struct {
+	channels.ModelMessage
+
+	// Highlights are the ranges of the matched words in the text of the
+	// message in UTF-16 code units (see [impl.Highlight]).
+	Highlights []impl.Highlight `json:"highlights"`
+}
+
+// SearchMessages returns the messages with text that contains a word starting
+// with every term of the query, newest first. If channelID is not nil, only
+// messages in that channel are returned. At most limit results are returned;
+// a limit of zero means no limit. Hidden messages are never returned.
+//
+// The text of each result is decrypted and the ranges of the matched words are
+// returned with it.
+func (w *wasmModel) SearchMessages(
+	query string, channelID *id.ID, limit int) ([]SearchResult, error) {
+	parentErr := errors.New("failed to SearchMessages")
+
+	terms := impl.SearchTerms(query)
+	if len(terms) == 0 {
+		return nil, errors.WithMessagef(parentErr, "query has no words of "+
+			"at least %d characters", impl.MinSearchTermLength)
+	}
+
+	uuids, err := w.searchIndex(terms)
+	if err != nil {
+		return nil, errors.WithMessage(parentErr, err.Error())
+	}
+
+	// Message UUIDs increase as messages are stored, so check the newest
+	// candidates first
+	sort.Slice(uuids, func(i, j int) bool { return uuids[i] > uuids[j] })
+
+	results := make([]SearchResult, 0)
+	for _, uuid := range uuids {
+		if limit > 0 && len(results) >= limit {
+			break
+		}
+
+		msgObj, err := impl.Get(w.db, messageStoreName, js.ValueOf(uuid))
+		if err != nil {
+			// The tokens of deleted messages may linger until the index is
+			// rebuilt
+			if strings.Contains(err.Error(), impl.ErrDoesNotExist) {
+				continue
+			}
+			return nil, errors.WithMessage(parentErr, err.Error())
+		}
+		msg, err := w.decryptedMessage(msgObj)
+		if err != nil {
+			return nil, errors.WithMessage(parentErr, err.Error())
+		} else if msg.Hidden || (channelID != nil &&
+			!bytes.Equal(msg.ChannelID, channelID.Marshal())) {
+			continue
+		}
+
+		// Confirm the hit against the current text, since the index may hold
+		// the tokens of an older version of an edited message
+		highlights := impl.SearchHighlights(msg.Text, terms)
+		if highlights == nil {
+			continue
+		}
+
+		modelMsg, err := w.toModelMessage(msg)
+		if err != nil {
+			return nil, errors.WithMessage(parentErr, err.Error())
+		}
+		results = append(results, SearchResult{modelMsg, highlights})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Timestamp.After(results[j].Timestamp)
+	})
+	return results, nil
+}
+
+// searchIndex returns the UUID of every message with a token matching each of
+// the terms.
+func (w *wasmModel) searchIndex(terms []string) ([]uint64, error) {
+	var found map[uint64]bool
+	for _, term := range terms {
+		key, err := impl.SearchIndexKey(w.cipher, impl.SearchLookupToken(term))
+		if err != nil {
+			return nil, err
+		}
+
+		// Every primary key [key, uuid] sorts after [key] and before
+		// [key, []] since arrays sort after numbers
+		keys, err := impl.NewQuery(searchStoreName).
+			Bound(js.ValueOf([]any{key}), js.ValueOf([]any{key, []any{}}),
+				false, false).
+			GetAllKeys(w.db)
+		if err != nil {
+			return nil, err
+		}
+
+		matches := make(map[uint64]bool, len(keys))
+		for _, k := range keys {
+			uuid := uint64(k.Index(1).Int())
+			if found == nil || found[uuid] {
+				matches[uuid] = true
+			}
+		}
+		found = matches
+		if len(found) == 0 {
+			break
+		}
+	}
+
+	uuids := make([]uint64, 0, len(found))
+	for uuid := range found {
+		uuids = append(uuids, uuid)
+	}
+	return uuids, nil
+}
+
+// isSearchable returns true if messages of the type have text that is added
+// to the search index.
+func isSearchable(mType channels.MessageType) bool {
+	return mType == channels.Text || mType == channels.AdminText
+}
+
+// searchIndexKeys returns the keys stored in the search index for the tokens
+// of the plaintext (see [impl.SearchIndexKey]).
+func (w *wasmModel) searchIndexKeys(text string) ([]string, error) {
+	tokens := impl.SearchTokens(text)
+	keys := make([]string, len(tokens))
+	for i, token := range tokens {
+		key, err := impl.SearchIndexKey(w.cipher, token)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
+
+// newSearchTokens returns the SearchToken objects for the plaintext of the
+// message with the UUID.
+func (w *wasmModel) newSearchTokens(
+	uuid uint64, channelID []byte, text string) ([]js.Value, error) {
+	keys, err := w.searchIndexKeys(text)
+	if err != nil {
+		return nil, err
+	}
+	return searchTokenObjects(uuid, channelID, keys)
+}
+
+// searchTokenObjects returns the SearchToken objects with the keys for the
+// message with the UUID.
+func searchTokenObjects(
+	uuid uint64, channelID []byte, keys []string) ([]js.Value, error) {
+	tokenObjs := make([]js.Value, len(keys))
+	for i, key := range keys {
+		tokenObj, _, err := marshalToJS(&SearchToken{
+			Token:     key,
+			MessageID: uuid,
+			ChannelID: channelID,
+		})
+		if err != nil {
+			return nil, errors.Errorf("Unable to marshal SearchToken: %+v", err)
+		}
+		tokenObjs[i] = tokenObj
+	}
+	return tokenObjs, nil
+}
+
+// relatedSearchTokens returns a function that returns the SearchToken objects
+// with the keys for the message stored with the primary key, so that they can
+// be written in the same transaction as the message. Returns nil if there are
+// no keys.
+func relatedSearchTokens(channelID []byte,
+	keys []string) func(key js.Value) ([]impl.RelatedValue, error) {
+	if len(keys) == 0 {
+		return nil
+	}
+	return func(key js.Value) ([]impl.RelatedValue, error) {
+		tokenObjs, err := searchTokenObjects(uint64(key.Int()), channelID, keys)
+		if err != nil {
+			return nil, err
+		}
+		related := make([]impl.RelatedValue, len(tokenObjs))
+		for i, tokenObj := range tokenObjs {
+			related[i] = impl.RelatedValue{
+				ObjectStoreName: searchStoreName,
+				Value:           tokenObj,
+			}
+		}
+		return related, nil
+	}
+}
+
+// reindexMessage replaces the tokens of the message with the UUID in the
+// search index with those of its new plaintext in the transaction.
+func (w *wasmModel) reindexMessage(txn *impl.Transaction, uuid uint64,
+	channelID []byte, text string) error {
+	_, err := txn.DeleteAllIndex(
+		searchStoreName, searchStoreMessageIndex, js.ValueOf(uuid))
+	if err != nil {
+		return err
+	}
+
+	tokenObjs, err := w.newSearchTokens(uuid, channelID, text)
+	if err != nil {
+		return err
+	}
+	for _, tokenObj := range tokenObjs {
+		if _, err = txn.Put(searchStoreName, tokenObj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildSearchIndex replaces the search index with the tokens of every
+// searchable message in the database. It is used to index the messages stored
+// before the index was added and to replace the tokens of every prefix stored
+// before the prefixes were bucketed.
+func (w *wasmModel) rebuildSearchIndex() error {
+	parentErr := errors.New("failed to rebuild search index")
+
+	// Read the messages first, since the values of an iteration cannot be
+	// written in the same transaction
+	var msgs []*Message
+	err := impl.NewQuery(messageStoreName).Iter(w.db, func(value js.Value) error {
+		msg, err := valueToMessage(value)
+		if err != nil {
+			return err
+		}
+		if isSearchable(channels.MessageType(msg.Type)) {
+			msgs = append(msgs, msg)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.WithMessage(parentErr, err.Error())
+	}
+
+	var tokenObjs []js.Value
+	for _, msg := range msgs {
+		text := msg.Text
+		if w.cipher != nil && text != "" {
+			plaintext, err := w.cipher.Decrypt(text)
+			if err != nil {
+				jww.WARN.Printf("[CH] Skipping message %d in search index: "+
+					"failed to decrypt text: %+v", msg.ID, err)
+				continue
+			}
+			text = string(plaintext)
+		}
+
+		msgTokens, err := w.newSearchTokens(msg.ID, msg.ChannelID, text)
+		if err != nil {
+			return errors.WithMessage(parentErr, err.Error())
+		}
+		tokenObjs = append(tokenObjs, msgTokens...)
+	}
+
+	err = impl.Transact(w.db, []string{searchStoreName},
+		func(txn *impl.Transaction) error {
+			if err := txn.Clear(searchStoreName); err != nil {
+				return err
+			}
+			for _, tokenObj := range tokenObjs {
+				if _, err := txn.Put(searchStoreName, tokenObj); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return errors.WithMessage(parentErr, err.Error())
+	}
+
+	jww.INFO.Printf("[CH] Indexed %d messages for search", len(msgs))
+	return nil
+}
+
+// valueToSearchToken is a helper for converting js.Value to SearchToken.
+func valueToSearchToken(tokenObj js.Value) (*SearchToken, error) {
+	resultToken := &SearchToken{}
+	return resultToken,
+		json.Unmarshal([]byte(utils.JsToJson(tokenObj)), resultToken)
+}
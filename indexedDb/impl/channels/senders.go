@@ -0,0 +1,178 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"syscall/js"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/client/v4/channels"
+	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// GetMessagesBySender returns every message sent by the public key in the
+// channel, oldest first.
+func (w *wasmModel) GetMessagesBySender(channelID *id.ID,
+	pubKey ed25519.PublicKey) ([]channels.ModelMessage, error) {
+	parentErr := errors.New("failed to GetMessagesBySender")
+
+	queries, err := w.senderQueries(channelID, pubKey)
+	if err != nil {
+		return nil, errors.WithMessage(parentErr, err.Error())
+	}
+
+	// A message is only in one of the indexes, but deduplicate in case it was
+	// re-encrypted between the queries
+	found := make(map[uint64]bool)
+	result := make([]channels.ModelMessage, 0)
+	for _, q := range queries {
+		msgObjs, err := q.GetAll(w.db)
+		if err != nil {
+			return nil, errors.WithMessage(parentErr, err.Error())
+		}
+		for _, msgObj := range msgObjs {
+			msg, err := valueToMessage(msgObj)
+			if err != nil {
+				return nil, errors.WithMessage(parentErr, err.Error())
+			} else if found[msg.ID] {
+				continue
+			}
+			found[msg.ID] = true
+
+			modelMsg, err := w.toModelMessage(msg)
+			if err != nil {
+				return nil, errors.WithMessage(parentErr, err.Error())
+			}
+			result = append(result, modelMsg)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+	return result, nil
+}
+
+// HideMessagesBySender hides every message sent by the public key in the
+// channel if hide is true. If hide is false, only the messages previously
+// hidden by HideMessagesBySender are shown again, so messages hidden for other
+// reasons stay hidden. A MessageReceived update event is sent for each changed
+// message. Returns the number of messages changed.
+func (w *wasmModel) HideMessagesBySender(channelID *id.ID,
+	pubKey ed25519.PublicKey, hide bool) (int, error) {
+	parentErr := errors.New("failed to HideMessagesBySender")
+
+	queries, err := w.senderQueries(channelID, pubKey)
+	if err != nil {
+		return 0, errors.WithMessage(parentErr, err.Error())
+	}
+	var keys []js.Value
+	for _, q := range queries {
+		queryKeys, err := q.GetAllKeys(w.db)
+		if err != nil {
+			return 0, errors.WithMessage(parentErr, err.Error())
+		}
+		keys = append(keys, queryKeys...)
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	// Update every message in one transaction so that either all or none of
+	// the messages are hidden
+	var changed []uint64
+	err = impl.Transact(w.db, []string{messageStoreName},
+		func(txn *impl.Transaction) error {
+			changed = changed[:0]
+			for _, key := range keys {
+				msgObj, err := txn.Get(messageStoreName, key)
+				if err != nil {
+					return err
+				}
+				msg, err := valueToMessage(msgObj)
+				if err != nil {
+					return err
+				}
+
+				if hide && !msg.Hidden {
+					msg.Hidden, msg.HiddenByMute = true, true
+				} else if !hide && msg.HiddenByMute {
+					msg.Hidden, msg.HiddenByMute = false, false
+				} else {
+					continue
+				}
+
+				msgJson, err := json.Marshal(msg)
+				if err != nil {
+					return errors.Errorf("Unable to marshal Message: %+v", err)
+				}
+				msgObj, err = utils.JsonToJS(msgJson)
+				if err != nil {
+					return errors.Errorf("Unable to marshal Message: %+v", err)
+				}
+				if _, err = txn.Put(messageStoreName, msgObj); err != nil {
+					return err
+				}
+				changed = append(changed, msg.ID)
+			}
+			return nil
+		})
+	if err != nil {
+		return 0, errors.WithMessage(parentErr, err.Error())
+	}
+
+	channelIDBytes := channelID.Marshal()
+	for _, uuid := range changed {
+		if err = w.messageUpdated(uuid, channelIDBytes); err != nil {
+			jww.ERROR.Printf("[CH] Failed to send update for message %d: %+v",
+				uuid, err)
+		}
+	}
+
+	jww.DEBUG.Printf("[CH] Set hidden to %t on %d messages from %X in "+
+		"channel %s", hide, len(changed), pubKey, channelID)
+	return len(changed), nil
+}
+
+// senderQueries returns the queries for the messages of the sender in the
+// channel. Messages with plaintext metadata are found by public key and, if
+// the model has a cipher, messages with encrypted metadata are found by the
+// blind index of the public key.
+func (w *wasmModel) senderQueries(
+	channelID *id.ID, pubKey ed25519.PublicKey) ([]impl.Query, error) {
+	channelIDStr := base64.StdEncoding.EncodeToString(channelID.Marshal())
+	queries := []impl.Query{
+		impl.NewQuery(messageStoreName).
+			Index(messageStoreSenderIndex).
+			Only(js.ValueOf([]any{
+				channelIDStr, base64.StdEncoding.EncodeToString(pubKey)})),
+	}
+
+	if w.cipher != nil {
+		blindIndex, err := impl.BlindIndex(w.cipher, pubKey)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to get pubkey index")
+		}
+		queries = append(queries, impl.NewQuery(messageStoreName).
+			Index(messageStoreBlindSenderIndex).
+			Only(js.ValueOf([]any{
+				channelIDStr, base64.StdEncoding.EncodeToString(blindIndex)})))
+	}
+
+	return queries, nil
+}
@@ -11,14 +11,15 @@ package main
 
 import (
 	"encoding/json"
+	"sync"
 
 	"github.com/pkg/errors"
 	jww "github.com/spf13/jwalterweatherman"
 
 	"gitlab.com/elixxir/client/v4/dm"
 	"gitlab.com/elixxir/crypto/fastRNG"
-	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
 	"gitlab.com/elixxir/wasm-utils/exception"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
 	wDm "gitlab.com/elixxir/xxdk-wasm/indexedDb/worker/dm"
 	"gitlab.com/elixxir/xxdk-wasm/worker"
 	"gitlab.com/xx_network/crypto/csprng"
@@ -31,20 +32,68 @@ var zeroUUID = []byte{0, 0, 0, 0, 0, 0, 0, 0}
 type manager struct {
 	wtm   *worker.ThreadManager
 	model dm.EventModel
+
+	// order keeps the callbacks in the order their messages are received.
+	// Concurrent callbacks hold a read lock and all others the write lock.
+	order sync.RWMutex
 }
 
 // registerCallbacks registers all the reception callbacks to manage messages
 // from the main thread for the channels.EventModel.
 func (m *manager) registerCallbacks() {
-	m.wtm.RegisterCallback(wDm.NewWASMEventModelTag, m.newWASMEventModelCB)
-	m.wtm.RegisterCallback(wDm.ReceiveTag, m.receiveCB)
-	m.wtm.RegisterCallback(wDm.ReceiveTextTag, m.receiveTextCB)
-	m.wtm.RegisterCallback(wDm.ReceiveReplyTag, m.receiveReplyCB)
-	m.wtm.RegisterCallback(wDm.ReceiveReactionTag, m.receiveReactionCB)
-	m.wtm.RegisterCallback(wDm.UpdateSentStatusTag, m.updateSentStatusCB)
-	m.wtm.RegisterCallback(wDm.DeleteMessageTag, m.deleteMessageCB)
-	m.wtm.RegisterCallback(wDm.GetConversationTag, m.getConversationCB)
-	m.wtm.RegisterCallback(wDm.GetConversationsTag, m.getConversationsCB)
+	m.register(wDm.NewWASMEventModelTag, m.newWASMEventModelCB)
+	m.registerConcurrent(wDm.ReceiveTag, m.receiveCB)
+	m.registerConcurrent(wDm.ReceiveTextTag, m.receiveTextCB)
+	m.registerConcurrent(wDm.ReceiveReplyTag, m.receiveReplyCB)
+	m.registerConcurrent(wDm.ReceiveReactionTag, m.receiveReactionCB)
+	m.register(wDm.UpdateSentStatusTag, m.updateSentStatusCB)
+	m.register(wDm.DeleteMessageTag, m.deleteMessageCB)
+	m.register(wDm.GetConversationTag, m.getConversationCB)
+	m.register(wDm.GetConversationsTag, m.getConversationsCB)
+	m.register(wDm.RotateKeyTag, m.rotateKeyCB)
+	m.register(wDm.ExportHistoryTag, m.exportHistoryCB)
+	m.register(wDm.CheckDatabaseTag, m.checkDatabaseCB)
+	m.register(wDm.GetStorageUsageTag, m.getStorageUsageCB)
+	m.register(wDm.GetMessagesTag, m.getMessagesCB)
+	m.register(wDm.ListConversationsTag, m.listConversationsCB)
+	m.register(wDm.MarkConversationReadTag, m.markConversationReadCB)
+	m.register(wDm.SetConversationStateTag, m.setConversationStateCB)
+	m.register(wDm.SaveDraftTag, m.saveDraftCB)
+	m.register(wDm.GetDraftTag, m.getDraftCB)
+	m.register(wDm.ClearDraftTag, m.clearDraftCB)
+}
+
+// register registers the callback so that it runs only once every callback
+// for an earlier message has returned and before any callback for a later
+// message starts.
+func (m *manager) register(tag worker.Tag, cb worker.ReceiverCallback) {
+	m.wtm.RegisterCallback(tag, func(message []byte, reply func([]byte)) {
+		m.order.Lock()
+		defer m.order.Unlock()
+		cb(message, reply)
+	})
+}
+
+// registerConcurrent registers a receive callback that runs in its own
+// goroutine. This allows the worker to receive further messages while the
+// callback waits on the write batch so that their inserts are coalesced into
+// one transaction.
+//
+// Concurrent callbacks only overlap each other. They start once every earlier
+// callback registered with register has returned, and later ones wait for them
+// to return, so an update or delete is never applied before the insert it
+// refers to.
+func (m *manager) registerConcurrent(
+	tag worker.Tag, cb worker.ReceiverCallback) {
+	m.wtm.RegisterCallback(tag, func(message []byte, reply func([]byte)) {
+		// The lock is acquired before starting the goroutine so that messages
+		// are ordered by when they were received rather than by scheduling
+		m.order.RLock()
+		go func() {
+			defer m.order.RUnlock()
+			cb(message, reply)
+		}()
+	})
 }
 
 // newWASMEventModelCB is the callback for NewWASMEventModel. Returns an empty
@@ -60,7 +109,7 @@ func (m *manager) newWASMEventModelCB(message []byte, reply func(message []byte)
 
 	// Create new encryption cipher
 	rng := fastRNG.NewStreamGenerator(12, 1024, csprng.NewSystemRNG)
-	encryption, err := idbCrypto.NewCipherFromJSON(
+	encryption, err := impl.NewCipherFromJSON(
 		[]byte(msg.EncryptionJSON), rng.GetStream())
 	if err != nil {
 		reply([]byte(errors.Wrap(err,
@@ -68,8 +117,10 @@ func (m *manager) newWASMEventModelCB(message []byte, reply func(message []byte)
 		return
 	}
 
-	m.model, err = NewWASMEventModel(
-		msg.DatabaseName, encryption, m.eventUpdateCallback)
+	msg.StorageParams.Apply()
+
+	m.model, err = NewWASMEventModel(msg.DatabaseName, encryption,
+		msg.ExtendedEncryption, m.eventUpdateCallback)
 	if err != nil {
 		reply([]byte(err.Error()))
 		return
@@ -265,3 +316,351 @@ func (m *manager) getConversationsCB(_ []byte, reply func(message []byte)) {
 	}
 	reply(replyMessage)
 }
+
+// rotateKeyCB is the callback for wasmModel.RotateKey. Returns an empty slice
+// on success or an error message on failure.
+func (m *manager) rotateKeyCB(message []byte, reply func(message []byte)) {
+	rng := fastRNG.NewStreamGenerator(12, 1024, csprng.NewSystemRNG)
+	encryption, err := impl.NewCipherFromJSON(message, rng.GetStream())
+	if err != nil {
+		reply([]byte(errors.Wrap(err,
+			"failed to JSON unmarshal Cipher from main thread").Error()))
+		return
+	}
+
+	kr, ok := encryption.(*impl.KeyRing)
+	if !ok {
+		reply([]byte(errors.Errorf(
+			"cannot rotate key of cipher %T", encryption).Error()))
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		reply([]byte(errors.Errorf(
+			"cannot rotate key of event model %T", m.model).Error()))
+		return
+	}
+
+	model.rotateKey(kr)
+	reply(nil)
+}
+
+// exportHistoryCB is the callback for wasmModel.ExportHistory. Returns JSON
+// marshalled wDm.ExportHistoryReply. If an error occurs, then Error will be
+// set with the error message. Otherwise, Data will be set.
+func (m *manager) exportHistoryCB(message []byte, reply func(message []byte)) {
+	var replyMsg wDm.ExportHistoryReply
+	defer func() {
+		if replyMessage, err := json.Marshal(replyMsg); err != nil {
+			exception.Throwf("[DM] Failed to JSON marshal %T for "+
+				"ExportHistory: %+v", replyMsg, err)
+		} else {
+			reply(replyMessage)
+		}
+	}()
+
+	var msg wDm.ExportHistoryMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		replyMsg.Error = errors.Errorf("failed to JSON unmarshal %T from "+
+			"main thread: %+v", msg, err).Error()
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		replyMsg.Error = errors.Errorf(
+			"cannot export history of event model %T", m.model).Error()
+		return
+	}
+
+	data, err := model.ExportHistory(msg.PartnerPubKey, msg.Start, msg.End,
+		impl.HistoryFormat(msg.Format))
+	if err != nil {
+		replyMsg.Error = err.Error()
+	} else {
+		replyMsg.Data = data
+	}
+}
+
+// checkDatabaseCB is the callback for wasmModel.CheckDatabase. Returns JSON
+// marshalled wDm.CheckDatabaseReply. If an error occurs, then Error will be
+// set with the error message. Otherwise, Report will be set.
+func (m *manager) checkDatabaseCB(message []byte, reply func(message []byte)) {
+	var replyMsg wDm.CheckDatabaseReply
+	defer func() {
+		if replyMessage, err := json.Marshal(replyMsg); err != nil {
+			exception.Throwf("[DM] Failed to JSON marshal %T for "+
+				"CheckDatabase: %+v", replyMsg, err)
+		} else {
+			reply(replyMessage)
+		}
+	}()
+
+	var msg wDm.CheckDatabaseMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		replyMsg.Error = errors.Errorf("failed to JSON unmarshal %T from "+
+			"main thread: %+v", msg, err).Error()
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		replyMsg.Error = errors.Errorf(
+			"cannot check database of event model %T", m.model).Error()
+		return
+	}
+
+	report, err := model.CheckDatabase(msg.Repair)
+	if err != nil {
+		replyMsg.Error = err.Error()
+		return
+	}
+	if replyMsg.Report, err = json.Marshal(report); err != nil {
+		replyMsg.Error = errors.Errorf(
+			"failed to JSON marshal report: %+v", err).Error()
+	}
+}
+
+// getStorageUsageCB is the callback for wasmModel.GetStorageUsage. Returns JSON
+// marshalled wDm.GetStorageUsageReply. If an error occurs, then Error will be
+// set with the error message. Otherwise, Usage will be set.
+func (m *manager) getStorageUsageCB(_ []byte, reply func(message []byte)) {
+	var replyMsg wDm.GetStorageUsageReply
+	defer func() {
+		if replyMessage, err := json.Marshal(replyMsg); err != nil {
+			exception.Throwf("[DM] Failed to JSON marshal %T for "+
+				"GetStorageUsage: %+v", replyMsg, err)
+		} else {
+			reply(replyMessage)
+		}
+	}()
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		replyMsg.Error = errors.Errorf(
+			"cannot get storage usage of event model %T", m.model).Error()
+		return
+	}
+
+	usage, err := model.GetStorageUsage()
+	if err != nil {
+		replyMsg.Error = err.Error()
+		return
+	}
+	if replyMsg.Usage, err = json.Marshal(usage); err != nil {
+		replyMsg.Error = errors.Errorf(
+			"failed to JSON marshal usage: %+v", err).Error()
+	}
+}
+
+// getMessagesCB is the callback for wasmModel.GetMessages. Returns JSON
+// marshalled wDm.GetMessagesReply. If an error occurs, then Error will be set
+// with the error message. Otherwise, Page will be set.
+func (m *manager) getMessagesCB(message []byte, reply func(message []byte)) {
+	var replyMsg wDm.GetMessagesReply
+	defer func() {
+		if replyMessage, err := json.Marshal(replyMsg); err != nil {
+			exception.Throwf("[DM] Failed to JSON marshal %T for "+
+				"GetMessages: %+v", replyMsg, err)
+		} else {
+			reply(replyMessage)
+		}
+	}()
+
+	var msg wDm.GetMessagesMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		replyMsg.Error = errors.Errorf("failed to JSON unmarshal %T from "+
+			"main thread: %+v", msg, err).Error()
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		replyMsg.Error = errors.Errorf(
+			"cannot get messages of event model %T", m.model).Error()
+		return
+	}
+
+	page, err := model.GetMessages(msg.PartnerKey, msg.Cursor, msg.Limit)
+	if err != nil {
+		replyMsg.Error = err.Error()
+		return
+	}
+	if replyMsg.Page, err = json.Marshal(page); err != nil {
+		replyMsg.Error = errors.Errorf(
+			"failed to JSON marshal page: %+v", err).Error()
+	}
+}
+
+// listConversationsCB is the callback for wasmModel.ListConversations.
+// Returns JSON marshalled wDm.ListConversationsReply. If an error occurs, then
+// Error will be set with the error message. Otherwise, Conversations will be
+// set.
+func (m *manager) listConversationsCB(
+	message []byte, reply func(message []byte)) {
+	var replyMsg wDm.ListConversationsReply
+	defer func() {
+		if replyMessage, err := json.Marshal(replyMsg); err != nil {
+			exception.Throwf("[DM] Failed to JSON marshal %T for "+
+				"ListConversations: %+v", replyMsg, err)
+		} else {
+			reply(replyMessage)
+		}
+	}()
+
+	var msg wDm.ListConversationsMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		replyMsg.Error = errors.Errorf("failed to JSON unmarshal %T from "+
+			"main thread: %+v", msg, err).Error()
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		replyMsg.Error = errors.Errorf(
+			"cannot list conversations of event model %T", m.model).Error()
+		return
+	}
+
+	convos, err :=
+		model.ListConversations(msg.SortBy, msg.Blocked, msg.Archived)
+	if err != nil {
+		replyMsg.Error = err.Error()
+		return
+	}
+	if replyMsg.Conversations, err = json.Marshal(convos); err != nil {
+		replyMsg.Error = errors.Errorf(
+			"failed to JSON marshal conversations: %+v", err).Error()
+	}
+}
+
+// markConversationReadCB is the callback for wasmModel.MarkConversationRead.
+// Returns an empty slice on success or an error message on failure.
+func (m *manager) markConversationReadCB(
+	message []byte, reply func(message []byte)) {
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		reply([]byte(errors.Errorf(
+			"cannot mark conversations read in event model %T",
+			m.model).Error()))
+		return
+	}
+
+	if err := model.MarkConversationRead(message); err != nil {
+		reply([]byte(err.Error()))
+		return
+	}
+	reply(nil)
+}
+
+// setConversationStateCB is the callback for wasmModel.SetConversationState.
+// Returns an empty slice on success or an error message on failure.
+func (m *manager) setConversationStateCB(
+	message []byte, reply func(message []byte)) {
+	var msg wDm.SetConversationStateMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		reply([]byte(errors.Errorf("failed to JSON unmarshal %T from main "+
+			"thread: %+v", msg, err).Error()))
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		reply([]byte(errors.Errorf(
+			"cannot set conversation state in event model %T",
+			m.model).Error()))
+		return
+	}
+
+	err := model.SetConversationState(
+		msg.PartnerKey, msg.Archived, msg.Pinned, msg.Muted)
+	if err != nil {
+		reply([]byte(err.Error()))
+		return
+	}
+	reply(nil)
+}
+
+// saveDraftCB is the callback for wasmModel.SaveDraft. Returns an empty slice
+// on success or an error message on failure.
+func (m *manager) saveDraftCB(message []byte, reply func(message []byte)) {
+	var msg wDm.SaveDraftMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		reply([]byte(errors.Errorf("failed to JSON unmarshal %T from main "+
+			"thread: %+v", msg, err).Error()))
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		reply([]byte(errors.Errorf(
+			"cannot save drafts in event model %T", m.model).Error()))
+		return
+	}
+
+	err := model.SaveDraft(msg.PartnerKey, msg.Text, msg.ReplyTo)
+	if err != nil {
+		reply([]byte(err.Error()))
+		return
+	}
+	reply(nil)
+}
+
+// getDraftCB is the callback for wasmModel.GetDraft. Returns JSON marshalled
+// wDm.GetDraftReply. If an error occurs, then Error will be set with the
+// error message. Otherwise, Draft will be set if there is a draft.
+func (m *manager) getDraftCB(message []byte, reply func(message []byte)) {
+	var replyMsg wDm.GetDraftReply
+	defer func() {
+		if replyMessage, err := json.Marshal(replyMsg); err != nil {
+			exception.Throwf("[DM] Failed to JSON marshal %T for "+
+				"GetDraft: %+v", replyMsg, err)
+		} else {
+			reply(replyMessage)
+		}
+	}()
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		replyMsg.Error = errors.Errorf(
+			"cannot get drafts of event model %T", m.model).Error()
+		return
+	}
+
+	draft, err := model.GetDraft(message)
+	if err != nil {
+		replyMsg.Error = err.Error()
+		return
+	} else if draft == nil {
+		return
+	}
+	if replyMsg.Draft, err = json.Marshal(draft); err != nil {
+		replyMsg.Error = errors.Errorf(
+			"failed to JSON marshal draft: %+v", err).Error()
+	}
+}
+
+// clearDraftCB is the callback for wasmModel.ClearDraft. Returns an empty
+// slice on success or an error message on failure.
+func (m *manager) clearDraftCB(message []byte, reply func(message []byte)) {
+	var msg wDm.ClearDraftMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		reply([]byte(errors.Errorf("failed to JSON unmarshal %T from main "+
+			"thread: %+v", msg, err).Error()))
+		return
+	}
+
+	model, ok := m.model.(*wasmModel)
+	if !ok {
+		reply([]byte(errors.Errorf(
+			"cannot clear drafts in event model %T", m.model).Error()))
+		return
+	}
+
+	if err := model.ClearDraft(msg.PartnerKey, msg.SavedBefore); err != nil {
+		reply([]byte(err.Error()))
+		return
+	}
+	reply(nil)
+}
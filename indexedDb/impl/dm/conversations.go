@@ -0,0 +1,460 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"sort"
+	"strings"
+	"syscall/js"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/client/v4/dm"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+	"gitlab.com/xx_network/primitives/netTime"
+)
+
+// previewLength is the maximum number of characters of the text of the newest
+// message stored as the preview of a Conversation.
+const previewLength = 64
+
+// The orders ListConversations can sort conversations in.
+const (
+	// sortByLastMessage sorts conversations by the time of their newest
+	// message, newest first. It is the default.
+	sortByLastMessage = "lastMessage"
+
+	// sortByNickname sorts conversations alphabetically by nickname.
+	sortByNickname = "nickname"
+
+	// sortByUnread sorts conversations by their number of unread messages,
+	// most first, and then by the time of their newest message.
+	sortByUnread = "unread"
+)
+
+// ConversationInfo is a conversation with a summary of its activity, as
+// returned by ListConversations.
+//
+// Example JSON:
+//
+//	{
+//	  "pub_key": "z8VYHxDJG6b+bJQ3u1Ok0RGprg+z0a9U2aU6T8ZqZPA=",
+//	  "nickname": "Alice",
+//	  "token": 4231817746,
+//	  "codeset_version": 0,
+//	  "blocked_timestamp": null,
+//	  "last_message_timestamp": "2023-07-21T11:42:09.512-07:00",
+//	  "last_message_preview": "Hello, World!",
+//	  "last_sender_pub_key": "z8VYHxDJG6b+bJQ3u1Ok0RGprg+z0a9U2aU6T8ZqZPA=",
+//	  "unread_count": 2,
+//	  "archived_timestamp": null,
+//	  "pinned_timestamp": "2023-07-20T09:12:44.108-07:00",
+//	  "muted": false
+//	}
+type ConversationInfo struct {
+	dm.ModelConversation
+
+	// LastMessageTimestamp is nil if the conversation has no messages.
+	LastMessageTimestamp *time.Time `json:"last_message_timestamp"`
+	LastMessagePreview   string     `json:"last_message_preview"`
+	LastSenderPubKey     []byte     `json:"last_sender_pub_key"`
+	UnreadCount          uint32     `json:"unread_count"`
+	ArchivedTimestamp    *time.Time `json:"archived_timestamp"`
+	PinnedTimestamp      *time.Time `json:"pinned_timestamp"`
+	Muted                bool       `json:"muted"`
+}
+
+// conversationActivity is the change a new message makes to the summary of
+// its Conversation.
+type conversationActivity struct {
+	timestamp    time.Time
+	preview      string
+	senderPubKey []byte
+	unread       bool
+}
+
+// newConversationActivity returns the activity of a new message with the
+// plaintext text. Only text messages and replies are shown in the summary, so
+// nil is returned for all other types. The preview is encrypted if the model
+// has a cipher.
+func (w *wasmModel) newConversationActivity(text string, partnerKey,
+	senderKey ed25519.PublicKey, timestamp time.Time, mType dm.MessageType) (
+	*conversationActivity, error) {
+	if mType != dm.TextType && mType != dm.ReplyType {
+		return nil, nil
+	}
+
+	preview := truncatePreview(text)
+	if w.cipher != nil {
+		var err error
+		preview, err = w.cipher.Encrypt([]byte(preview))
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to encrypt preview")
+		}
+	}
+
+	return &conversationActivity{
+		timestamp:    timestamp,
+		preview:      preview,
+		senderPubKey: senderKey,
+		unread:       bytes.Equal(partnerKey, senderKey),
+	}, nil
+}
+
+// apply adds the activity to the Conversation. Messages from the partner are
+// counted as unread. The newest message is kept as the last message, so an
+// older message received late does not replace it.
+func (a *conversationActivity) apply(convo *Conversation) {
+	if a.unread {
+		convo.UnreadCount++
+	}
+	if !a.timestamp.Before(convo.LastMessageTimestamp) {
+		convo.LastMessageTimestamp = a.timestamp
+		convo.LastMessagePreview = a.preview
+		convo.LastSenderPubKey = a.senderPubKey
+	}
+}
+
+// isLastMessage returns true if the decrypted Message is the last message shown
+// in the summary of the decrypted Conversation.
+func isLastMessage(convo *Conversation, msg *Message) bool {
+	mType := dm.MessageType(msg.Type)
+	return (mType == dm.TextType || mType == dm.ReplyType) &&
+		msg.Timestamp.Equal(convo.LastMessageTimestamp) &&
+		bytes.Equal(msg.SenderPubKey, convo.LastSenderPubKey)
+}
+
+// truncatePreview returns the text shortened to at most previewLength
+// characters without splitting a character.
+func truncatePreview(text string) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= previewLength {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:previewLength-1]) + "…"
+}
+
+// ListConversations returns every conversation with a summary of its activity
+// and its last message preview decrypted. Pinned conversations are first.
+// Conversations are then sorted by sortBy, which is "lastMessage" (the default
+// when empty), "nickname", or "unread".
+//
+// If blocked or archived are not nil, only conversations with a blocked or
+// archived state equal to them are returned.
+func (w *wasmModel) ListConversations(
+	sortBy string, blocked, archived *bool) ([]ConversationInfo, error) {
+	parentErr := errors.New("[DM indexedDB] failed to ListConversations")
+
+	var less func(a, b *ConversationInfo) bool
+	switch sortBy {
+	case "", sortByLastMessage:
+		less = lastMessageLess
+	case sortByNickname:
+		less = func(a, b *ConversationInfo) bool {
+			nickA, nickB :=
+				strings.ToLower(a.Nickname), strings.ToLower(b.Nickname)
+			if nickA != nickB {
+				// Conversations without a nickname are sorted last
+				return nickB == "" || (nickA != "" && nickA < nickB)
+			}
+			return bytes.Compare(a.Pubkey, b.Pubkey) < 0
+		}
+	case sortByUnread:
+		less = func(a, b *ConversationInfo) bool {
+			if a.UnreadCount != b.UnreadCount {
+				return a.UnreadCount > b.UnreadCount
+			}
+			return lastMessageLess(a, b)
+		}
+	default:
+		return nil, errors.WithMessagef(parentErr,
+			"unknown sort order %q", sortBy)
+	}
+
+	convoObjs, err := impl.GetAll(w.db, conversationStoreName)
+	if err != nil {
+		return nil, errors.WithMessage(parentErr, err.Error())
+	}
+
+	result := make([]ConversationInfo, 0, len(convoObjs))
+	for _, convoObj := range convoObjs {
+		convo, err := valueToConversation(convoObj)
+		if err != nil {
+			return nil, errors.WithMessagef(parentErr,
+				"Unable to unmarshal Conversation: %+v", err)
+		}
+		if blocked != nil && (convo.BlockedTimestamp != nil) != *blocked {
+			continue
+		} else if archived != nil &&
+			(convo.ArchivedTimestamp != nil) != *archived {
+			continue
+		}
+
+		info, err := w.toConversationInfo(convo)
+		if err != nil {
+			return nil, errors.WithMessage(parentErr, err.Error())
+		}
+		result = append(result, info)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		pinnedI, pinnedJ :=
+			result[i].PinnedTimestamp != nil, result[j].PinnedTimestamp != nil
+		if pinnedI != pinnedJ {
+			return pinnedI
+		}
+		return less(&result[i], &result[j])
+	})
+	return result, nil
+}
+
+// lastMessageLess sorts conversations by the time of their newest message,
+// newest first, with conversations without messages last.
+func lastMessageLess(a, b *ConversationInfo) bool {
+	if a.LastMessageTimestamp == nil || b.LastMessageTimestamp == nil {
+		return a.LastMessageTimestamp != nil
+	} else if !a.LastMessageTimestamp.Equal(*b.LastMessageTimestamp) {
+		return a.LastMessageTimestamp.After(*b.LastMessageTimestamp)
+	}
+	return bytes.Compare(a.Pubkey, b.Pubkey) < 0
+}
+
+// toConversationInfo decrypts the Conversation and converts it to a
+// ConversationInfo.
+func (w *wasmModel) toConversationInfo(
+	convo *Conversation) (ConversationInfo, error) {
+	if err := w.decryptConversationMetadata(convo); err != nil {
+		return ConversationInfo{}, err
+	}
+
+	preview := convo.LastMessagePreview
+	if w.cipher != nil && preview != "" {
+		plaintext, err := w.cipher.Decrypt(preview)
+		if err != nil {
+			return ConversationInfo{}, errors.WithMessage(
+				err, "failed to decrypt preview")
+		}
+		preview = string(plaintext)
+	}
+
+	info := ConversationInfo{
+		ModelConversation: dm.ModelConversation{
+			Pubkey:           convo.Pubkey,
+			Nickname:         convo.Nickname,
+			Token:            convo.Token,
+			CodesetVersion:   convo.CodesetVersion,
+			BlockedTimestamp: convo.BlockedTimestamp,
+		},
+		LastMessagePreview: preview,
+		LastSenderPubKey:   convo.LastSenderPubKey,
+		UnreadCount:        convo.UnreadCount,
+		ArchivedTimestamp:  convo.ArchivedTimestamp,
+		PinnedTimestamp:    convo.PinnedTimestamp,
+		Muted:              convo.Muted,
+	}
+	if !convo.LastMessageTimestamp.IsZero() {
+		info.LastMessageTimestamp = &convo.LastMessageTimestamp
+	}
+	return info, nil
+}
+
+// MarkConversationRead sets the number of unread messages in the conversation
+// with the partner to zero. Returns an error containing [impl.ErrDoesNotExist]
+// if there is no conversation with the partner.
+func (w *wasmModel) MarkConversationRead(partnerKey ed25519.PublicKey) error {
+	err := w.updateConversation(partnerKey, func(convo *Conversation) bool {
+		if convo.UnreadCount == 0 {
+			return false
+		}
+		convo.UnreadCount = 0
+		return true
+	})
+	if err != nil {
+		return errors.WithMessage(err,
+			"[DM indexedDB] failed to MarkConversationRead")
+	}
+	return nil
+}
+
+// SetConversationState archives, pins, or mutes the conversation with the
+// partner, or undoes it. Nil values are left unchanged. If the state changes,
+// a [impl.ConversationStateUpdate] event is sent. Returns an error containing
+// [impl.ErrDoesNotExist] if there is no conversation with the partner.
+func (w *wasmModel) SetConversationState(
+	partnerKey ed25519.PublicKey, archived, pinned, muted *bool) error {
+	var state impl.ConversationStateUpdateJSON
+	changed := false
+	err := w.updateConversation(partnerKey, func(convo *Conversation) bool {
+		changed = false
+		now := netTime.Now()
+		if archived != nil && (convo.ArchivedTimestamp != nil) != *archived {
+			convo.ArchivedTimestamp = nil
+			if *archived {
+				convo.ArchivedTimestamp = &now
+			}
+			changed = true
+		}
+		if pinned != nil && (convo.PinnedTimestamp != nil) != *pinned {
+			convo.PinnedTimestamp = nil
+			if *pinned {
+				convo.PinnedTimestamp = &now
+			}
+			changed = true
+		}
+		if muted != nil && convo.Muted != *muted {
+			convo.Muted = *muted
+			changed = true
+		}
+
+		state = impl.ConversationStateUpdateJSON{
+			PubKey:   partnerKey,
+			Archived: convo.ArchivedTimestamp != nil,
+			Pinned:   convo.PinnedTimestamp != nil,
+			Muted:    convo.Muted,
+		}
+		return changed
+	})
+	if err != nil {
+		return errors.WithMessage(err,
+			"[DM indexedDB] failed to SetConversationState")
+	} else if !changed {
+		return nil
+	}
+
+	jww.DEBUG.Printf("[DM indexedDB] Set state of conversation with %X to "+
+		"%+v", partnerKey, state)
+	go w.eventCallback(impl.ConversationStateUpdate, state)
+	return nil
+}
+
+// updateConversation gets the Conversation with the partner, calls update on
+// it, and, if update returns true, stores it in a single transaction so that
+// no other change to the Conversation is lost in between.
+func (w *wasmModel) updateConversation(partnerKey ed25519.PublicKey,
+	update func(convo *Conversation) bool) error {
+	return impl.Transact(w.db, []string{conversationStoreName},
+		func(txn *impl.Transaction) error {
+			convo, err := w.getConversationTxn(txn, partnerKey)
+			if err != nil {
+				return err
+			} else if !update(convo) {
+				return nil
+			}
+			return w.putConversation(txn, convo)
+		})
+}
+
+// getConversationTxn returns the decrypted Conversation with the partner in
+// the transaction.
+func (w *wasmModel) getConversationTxn(
+	txn *impl.Transaction, partnerKey []byte) (*Conversation, error) {
+	convoObj, err := txn.Get(conversationStoreName, impl.EncodeBytes(partnerKey))
+	if err != nil {
+		return nil, err
+	}
+	convo, err := valueToConversation(convoObj)
+	if err != nil {
+		return nil, errors.Errorf("Unable to unmarshal Conversation: %+v", err)
+	}
+	return convo, w.decryptConversationMetadata(convo)
+}
+
+// putConversation stores the Conversation in the transaction.
+func (w *wasmModel) putConversation(
+	txn *impl.Transaction, convo *Conversation) error {
+	convoObj, err := w.newConversationObject(convo)
+	if err != nil {
+		return err
+	}
+	if _, err = txn.Put(conversationStoreName, convoObj); err != nil {
+		return errors.Errorf("Unable to put Conversation: %+v", err)
+	}
+	return nil
+}
+
+// migrateConversations fills in the newest message of conversations started
+// before it was recorded. Existing messages are not counted as unread.
+func (w *wasmModel) migrateConversations() error {
+	newest := make(map[string]*Message)
+	err := impl.NewQuery(messageStoreName).Iter(w.db, func(value js.Value) error {
+		msg, err := valueToMessage(value)
+		if err != nil {
+			return err
+		}
+		mType := dm.MessageType(msg.Type)
+		if mType != dm.TextType && mType != dm.ReplyType {
+			return nil
+		}
+		key := base64.StdEncoding.EncodeToString(msg.ConversationPubKey)
+		if last, exists := newest[key]; !exists ||
+			!msg.Timestamp.Before(last.Timestamp) {
+			newest[key] = msg
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.WithMessage(err, "failed to migrate conversations")
+	}
+
+	numMigrated, err := impl.UpdateAll(w.db, conversationStoreName,
+		func(convoObj js.Value) (js.Value, bool, error) {
+			convo, err := valueToConversation(convoObj)
+			if err != nil {
+				return js.Undefined(), false, err
+			}
+			msg, exists := newest[base64.StdEncoding.EncodeToString(convo.Pubkey)]
+			if !exists || !convo.LastMessageTimestamp.IsZero() {
+				return js.Undefined(), false, nil
+			}
+
+			if err = w.decryptConversationMetadata(convo); err != nil {
+				return js.Undefined(), false, err
+			} else if err = w.decryptMessageMetadata(msg); err != nil {
+				return js.Undefined(), false, err
+			}
+			text := msg.Text
+			if w.cipher != nil && text != "" {
+				plaintext, err := w.cipher.Decrypt(text)
+				if err != nil {
+					return js.Undefined(), false, err
+				}
+				text = string(plaintext)
+			}
+			activity, err := w.newConversationActivity(text, convo.Pubkey,
+				msg.SenderPubKey, msg.Timestamp, dm.MessageType(msg.Type))
+			if err != nil {
+				return js.Undefined(), false, err
+			}
+			activity.unread = false
+			activity.apply(convo)
+
+			encryptedConvo, err := w.encryptConversationMetadata(convo)
+			if err != nil {
+				return js.Undefined(), false, err
+			}
+			return marshalToJS(encryptedConvo)
+		})
+	if err != nil {
+		return errors.WithMessage(err, "failed to migrate conversations")
+	}
+
+	if numMigrated > 0 {
+		jww.INFO.Printf(
+			"[DM indexedDB] Migrated activity of %d conversations", numMigrated)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"time"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/client/v4/bindings"
+	"gitlab.com/elixxir/crypto/message"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+)
+
+// receiveEdit applies the [impl.MessageEdit] in the text of the edit message
+// to the message it references. The new text replaces the text of the message
+// and is added to its edit history. Edits not sent by the original sender of
+// the message, edits of messages in other conversations, and edits of messages
+// that have not been received are rejected with an error.
+//
+// If the edited message is the last message of the conversation, its preview
+// is updated in the same transaction.
+//
+// Returns the UUID of the edited message. Receiving an edit already in the
+// history does not change the message.
+func (w *wasmModel) receiveEdit(editID message.ID, text []byte,
+	partnerKey, senderKey ed25519.PublicKey, timestamp time.Time) (
+	uint64, error) {
+	edit, err := impl.UnmarshalMessageEdit(text)
+	if err != nil {
+		return 0, err
+	}
+
+	newText, preview := edit.Text, truncatePreview(edit.Text)
+	if w.cipher != nil {
+		newText, err = w.cipher.Encrypt([]byte(newText))
+		if err != nil {
+			return 0, err
+		}
+		preview, err = w.cipher.Encrypt([]byte(preview))
+		if err != nil {
+			return 0, errors.WithMessage(err, "failed to encrypt preview")
+		}
+	}
+
+	var uuid uint64
+	var changed, lastMessage bool
+	err = impl.Transact(w.db, []string{messageStoreName, conversationStoreName},
+		func(txn *impl.Transaction) error {
+			lastMessage = false
+			msgObj, err := txn.GetIndex(messageStoreName,
+				messageStoreMessageIndex,
+				impl.EncodeBytes(edit.MessageID.Marshal()))
+			if err != nil {
+				return err
+			}
+			msg, err := valueToMessage(msgObj)
+			if err != nil {
+				return err
+			}
+			if err = w.decryptMessageMetadata(msg); err != nil {
+				return err
+			}
+			uuid = msg.ID
+
+			if !bytes.Equal(msg.ConversationPubKey, partnerKey) {
+				return errors.New("edited message is in another conversation")
+			}
+			err = edit.CheckEditAuthor(senderKey, msg.SenderPubKey)
+			if err != nil {
+				return err
+			}
+
+			original := impl.EditRecord{
+				MessageID: msg.MessageID,
+				Text:      msg.Text,
+				Timestamp: msg.Timestamp,
+			}
+			msg.EditHistory, changed = impl.AddEdit(msg.EditHistory, original,
+				impl.EditRecord{
+					MessageID: editID.Marshal(),
+					Text:      newText,
+					Timestamp: timestamp,
+				})
+			if !changed {
+				return nil
+			}
+			msg.Text = msg.EditHistory[len(msg.EditHistory)-1].Text
+
+			messageObj, err := w.newMessageObject(msg)
+			if err != nil {
+				return err
+			}
+			if _, err = txn.Put(messageStoreName, messageObj); err != nil {
+				return err
+			}
+
+			// Show the new text in the conversation list if the edited
+			// message is the last message of the conversation
+			convo, err := w.getConversationTxn(txn, msg.ConversationPubKey)
+			if err != nil {
+				return err
+			} else if lastMessage = isLastMessage(convo, msg); !lastMessage {
+				return nil
+			}
+			convo.LastMessagePreview = preview
+			return w.putConversation(txn, convo)
+		})
+	if err != nil || !changed {
+		return uuid, err
+	}
+
+	jww.DEBUG.Printf("[DM indexedDB] Edited message %s with %s",
+		edit.MessageID, editID)
+	go w.eventCallback(bindings.DmMessageReceived, bindings.DmMessageReceivedJSON{
+		UUID:               uuid,
+		PubKey:             partnerKey,
+		MessageUpdate:      true,
+		ConversationUpdate: lastMessage,
+	})
+	return uuid, nil
+}
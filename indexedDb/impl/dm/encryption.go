@@ -0,0 +1,311 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+)
+
+// enableExtendedEncryption turns on extended encryption for the model. When
+// enabled, the sender public key of each message is encrypted into
+// Message.Metadata and replaced with a keyed blind index so that the sender
+// index can still be queried. The nickname and DM token of each Conversation
+// are encrypted into Conversation.Metadata.
+//
+// The public key of the partner is not covered; it remains in plaintext as the
+// key of each Conversation and Draft and in the conversation index of each
+// Message.
+//
+// All existing messages and conversations that are not yet encrypted are
+// migrated in place, as are conversations encrypted before their token was. Returns an error if the model has no cipher.
+func (w *wasmModel) enableExtendedEncryption() error {
+	if w.cipher == nil {
+		return errors.New("extended encryption requires a cipher")
+	}
+	w.extendedEncryption = true
+
+	numMessages, err := impl.UpdateAll(w.db, messageStoreName,
+		func(msgObj js.Value) (js.Value, bool, error) {
+			msg, err := valueToMessage(msgObj)
+			if err != nil || msg.Metadata != nil {
+				return js.Undefined(), false, err
+			}
+
+			encryptedMsg, err := w.encryptMessageMetadata(msg)
+			if err != nil {
+				return js.Undefined(), false, err
+			}
+			return marshalToJS(encryptedMsg)
+		})
+	if err != nil {
+		return errors.WithMessage(
+			err, "failed to encrypt metadata of existing messages")
+	}
+
+	numConvos, err := impl.UpdateAll(w.db, conversationStoreName,
+		func(convoObj js.Value) (js.Value, bool, error) {
+			convo, err := valueToConversation(convoObj)
+			if err != nil || (convo.Metadata != nil && convo.Token == 0) {
+				return js.Undefined(), false, err
+			}
+
+			// Re-encrypt conversations whose token is still in plaintext
+			if err = w.decryptConversationMetadata(convo); err != nil {
+				return js.Undefined(), false, err
+			}
+			encryptedConvo, err := w.encryptConversationMetadata(convo)
+			if err != nil {
+				return js.Undefined(), false, err
+			}
+			return marshalToJS(encryptedConvo)
+		})
+	if err != nil {
+		return errors.WithMessage(
+			err, "failed to encrypt metadata of existing conversations")
+	}
+
+	if numMessages > 0 || numConvos > 0 {
+		jww.INFO.Printf("[DM] Encrypted metadata of %d existing messages and "+
+			"%d existing conversations", numMessages, numConvos)
+	}
+	return nil
+}
+
+// senderKeyIndex returns the value stored in the sender index for the public
+// key. When extended encryption is enabled, this is a keyed blind index.
+func (w *wasmModel) senderKeyIndex(senderKey []byte) ([]byte, error) {
+	if !w.extendedEncryption {
+		return senderKey, nil
+	}
+	return impl.BlindIndex(w.cipher, senderKey)
+}
+
+// encryptMessageMetadata returns a copy of the Message with the metadata
+// encrypted. If extended encryption is disabled or the metadata is already
+// encrypted, the Message is returned unchanged.
+func (w *wasmModel) encryptMessageMetadata(msg *Message) (*Message, error) {
+	if !w.extendedEncryption || msg.Metadata != nil {
+		return msg, nil
+	}
+
+	metadataJson, err := json.Marshal(messageMetadata{
+		SenderPubKey: msg.SenderPubKey,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal message metadata")
+	}
+
+	encryptedMsg := *msg
+	encryptedMsg.Metadata, err = impl.EncryptChunked(w.cipher, metadataJson)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to encrypt message metadata")
+	}
+	encryptedMsg.SenderPubKey, err = w.senderKeyIndex(msg.SenderPubKey)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get sender key index")
+	}
+	return &encryptedMsg, nil
+}
+
+// decryptMessageMetadata decrypts the metadata of the Message in place.
+// Messages without encrypted metadata are left unchanged.
+func (w *wasmModel) decryptMessageMetadata(msg *Message) error {
+	if msg.Metadata == nil {
+		return nil
+	} else if w.cipher == nil {
+		return errors.New("cannot decrypt message metadata without a cipher")
+	}
+
+	metadataJson, err := impl.DecryptChunked(w.cipher, msg.Metadata)
+	if err != nil {
+		return errors.WithMessage(err, "failed to decrypt message metadata")
+	}
+	var metadata messageMetadata
+	if err = json.Unmarshal(metadataJson, &metadata); err != nil {
+		return errors.Wrap(err, "failed to unmarshal message metadata")
+	}
+
+	msg.SenderPubKey = metadata.SenderPubKey
+	msg.Metadata = nil
+	return nil
+}
+
+// encryptConversationMetadata returns a copy of the Conversation with the
+// metadata encrypted. If extended encryption is disabled or the metadata is
+// already encrypted, the Conversation is returned unchanged.
+func (w *wasmModel) encryptConversationMetadata(
+	convo *Conversation) (*Conversation, error) {
+	if !w.extendedEncryption || convo.Metadata != nil {
+		return convo, nil
+	}
+
+	metadataJson, err := json.Marshal(conversationMetadata{
+		Nickname:         convo.Nickname,
+		Token:            &convo.Token,
+		LastSenderPubKey: convo.LastSenderPubKey,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal conversation metadata")
+	}
+
+	encryptedConvo := *convo
+	encryptedConvo.Metadata, err = impl.EncryptChunked(w.cipher, metadataJson)
+	if err != nil {
+		return nil, errors.WithMessage(
+			err, "failed to encrypt conversation metadata")
+	}
+	encryptedConvo.Nickname = ""
+	encryptedConvo.Token = 0
+	encryptedConvo.LastSenderPubKey = nil
+	return &encryptedConvo, nil
+}
+
+// decryptConversationMetadata decrypts the metadata of the Conversation in
+// place. Conversations without encrypted metadata are left unchanged.
+func (w *wasmModel) decryptConversationMetadata(convo *Conversation) error {
+	if convo.Metadata == nil {
+		return nil
+	} else if w.cipher == nil {
+		return errors.New(
+			"cannot decrypt conversation metadata without a cipher")
+	}
+
+	metadataJson, err := impl.DecryptChunked(w.cipher, convo.Metadata)
+	if err != nil {
+		return errors.WithMessage(
+			err, "failed to decrypt conversation metadata")
+	}
+	var metadata conversationMetadata
+	if err = json.Unmarshal(metadataJson, &metadata); err != nil {
+		return errors.Wrap(err, "failed to unmarshal conversation metadata")
+	}
+
+	convo.Nickname = metadata.Nickname
+	if metadata.Token != nil {
+		convo.Token = *metadata.Token
+	}
+	convo.LastSenderPubKey = metadata.LastSenderPubKey
+	convo.Metadata = nil
+	return nil
+}
+
+// rotateKey replaces the cipher of the model with the key ring and starts
+// re-encrypting all messages, conversations, and drafts with its current key
+// in the background. Progress is reported on the event callback with the event
+// type [impl.KeyRotationProgress].
+//
+// Blind indexes are keyed with the index key of the key ring (see
+// [impl.KeyRing.IndexCipher]), so messages can still be looked up by sender
+// during and after the rotation.
+func (w *wasmModel) rotateKey(kr *impl.KeyRing) {
+	w.cipher = kr
+	go impl.ReEncryptStores(w.db, kr,
+		[]string{messageStoreName, conversationStoreName, draftStoreName},
+		w.reEncryptValue,
+		func(progress impl.KeyRotationProgressJSON) {
+			w.eventCallback(impl.KeyRotationProgress, progress)
+		})
+}
+
+// reEncryptValue is the [impl.ReEncryptFunc] for the DM database.
+func (w *wasmModel) reEncryptValue(
+	storeName string, value js.Value) (js.Value, bool, error) {
+	switch storeName {
+	case messageStoreName:
+		msg, err := valueToMessage(value)
+		if err != nil {
+			return js.Undefined(), false, err
+		}
+		msg, changed, err := w.reEncryptMessage(msg)
+		if err != nil || !changed {
+			return js.Undefined(), false, err
+		}
+		return marshalToJS(msg)
+	case conversationStoreName:
+		convo, err := valueToConversation(value)
+		if err != nil {
+			return js.Undefined(), false, err
+		}
+		metadata, metadataChanged, err :=
+			impl.ReEncryptChunked(w.cipher, convo.Metadata)
+		if err != nil {
+			return js.Undefined(), false, err
+		}
+		preview, previewChanged, err :=
+			impl.ReEncrypt(w.cipher, convo.LastMessagePreview)
+		if err != nil || !(metadataChanged || previewChanged) {
+			return js.Undefined(), false, err
+		}
+		convo.Metadata, convo.LastMessagePreview = metadata, preview
+		return marshalToJS(convo)
+	case draftStoreName:
+		draft, err := valueToDraft(value)
+		if err != nil {
+			return js.Undefined(), false, err
+		}
+		text, changed, err := impl.ReEncrypt(w.cipher, draft.Text)
+		if err != nil || !changed {
+			return js.Undefined(), false, err
+		}
+		draft.Text = text
+		return marshalToJS(draft)
+	default:
+		return js.Undefined(), false,
+			errors.Errorf("unknown object store %q", storeName)
+	}
+}
+
+// reEncryptMessage re-encrypts the text, edit history, and metadata of the
+// Message with the current key, if they were encrypted with an older key. The
+// blind index of the sender is keyed with the index key of the key ring, so it
+// does not change. Returns true if the Message changed.
+func (w *wasmModel) reEncryptMessage(msg *Message) (*Message, bool, error) {
+	text, textChanged, err := impl.ReEncrypt(w.cipher, msg.Text)
+	if err != nil {
+		return nil, false, errors.WithMessage(err, "failed to re-encrypt text")
+	}
+	msg.Text = text
+
+	editsChanged, err := impl.ReEncryptEdits(w.cipher, msg.EditHistory)
+	if err != nil {
+		return nil, false, err
+	}
+	textChanged = textChanged || editsChanged
+
+	if msg.Metadata == nil || !w.extendedEncryption {
+		return msg, textChanged, nil
+	}
+
+	metadata, metadataChanged, err :=
+		impl.ReEncryptChunked(w.cipher, msg.Metadata)
+	if err != nil {
+		return nil, false, err
+	}
+	msg.Metadata = metadata
+	return msg, textChanged || metadataChanged, nil
+}
+
+// marshalToJS JSON marshals the object into a js.Value for use as the return
+// value of an [impl.UpdateAll] update function.
+func marshalToJS(v any) (js.Value, bool, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return js.Undefined(), false, err
+	}
+	obj, err := utils.JsonToJS(data)
+	return obj, err == nil, err
+}
@@ -0,0 +1,112 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"time"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/client/v4/dm"
+	"gitlab.com/elixxir/crypto/codename"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+)
+
+// ExportHistory renders every message in the conversation with the partner
+// with a timestamp in the date range in the given format. A zero start or end
+// leaves that side of the range unbounded.
+func (w *wasmModel) ExportHistory(partnerPubKey ed25519.PublicKey, start,
+	end time.Time, format impl.HistoryFormat) ([]byte, error) {
+	convo, err := w.getConversation(partnerPubKey)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get conversation")
+	}
+
+	msgObjs, err := impl.GetAllIndex(w.db, messageStoreName,
+		messageStoreConversationIndex, impl.EncodeBytes(partnerPubKey))
+	if err != nil {
+		return nil, err
+	}
+
+	title := convo.Nickname
+	if title == "" {
+		title = historyNickname(convo, partnerPubKey, convo.CodesetVersion)
+	}
+	history := impl.NewHistory(title, partnerPubKey, start, end)
+	var reactions []*Message
+	for _, msgObj := range msgObjs {
+		msg, err := valueToMessage(msgObj)
+		if err != nil {
+			return nil, err
+		} else if !history.InRange(msg.Timestamp) {
+			continue
+		} else if err = w.decryptMessageMetadata(msg); err != nil {
+			return nil, err
+		}
+
+		if w.cipher != nil && msg.Text != "" {
+			text, err := w.cipher.Decrypt(msg.Text)
+			if err != nil {
+				return nil, errors.WithMessage(
+					err, "failed to decrypt message text")
+			}
+			msg.Text = string(text)
+		}
+
+		switch dm.MessageType(msg.Type) {
+		case dm.TextType, dm.ReplyType:
+			historyMsg := impl.HistoryMessage{
+				MessageID: msg.MessageID,
+				Nickname: historyNickname(
+					convo, msg.SenderPubKey, msg.CodesetVersion),
+				Timestamp: msg.Timestamp,
+				Text:      msg.Text,
+			}
+			if len(msg.ParentMessageID) > 0 {
+				historyMsg.ReplyTo = msg.ParentMessageID
+			}
+			history.Add(historyMsg)
+		case dm.ReactionType:
+			reactions = append(reactions, msg)
+		}
+	}
+
+	// Reactions are added once all the messages they react to are added
+	for _, msg := range reactions {
+		history.AddReaction(msg.ParentMessageID, impl.HistoryReaction{
+			Reaction: msg.Text,
+			Nickname: historyNickname(
+				convo, msg.SenderPubKey, msg.CodesetVersion),
+			Timestamp: msg.Timestamp,
+		})
+	}
+
+	return history.Render(format)
+}
+
+// historyNickname returns the nickname of the sender of a message in the
+// conversation. Only the nickname of the partner is known, so the codename is
+// returned for all other senders or if the partner has no nickname.
+func historyNickname(
+	convo *Conversation, senderPubKey []byte, codeset uint8) string {
+	if bytes.Equal(convo.Pubkey, senderPubKey) && convo.Nickname != "" {
+		return convo.Nickname
+	}
+	identity, err := codename.ConstructIdentity(senderPubKey, codeset)
+	if err != nil {
+		jww.WARN.Printf(
+			"[DM] Failed to construct codename for history: %+v", err)
+		return ""
+	}
+	return identity.Codename
+}
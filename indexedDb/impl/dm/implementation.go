@@ -28,17 +28,39 @@ import (
 	"gitlab.com/elixxir/crypto/message"
 	"gitlab.com/elixxir/wasm-utils/utils"
 	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+	dmModel "gitlab.com/elixxir/xxdk-wasm/indexedDb/model/dm"
 	"gitlab.com/xx_network/primitives/id"
 	"gitlab.com/xx_network/primitives/netTime"
 )
 
 // wasmModel implements dm.EventModel interface backed by IndexedDb.
 // NOTE: This model is NOT thread safe - it is the responsibility of the
-// caller to ensure that its methods are called sequentially.
+// caller to ensure that its methods are called sequentially. The only exception
+// is Receive, ReceiveText, ReceiveReply, and ReceiveReaction, which may be
+// called concurrently with each other, but not with any other method, so that
+// their inserts are coalesced by the batch.
 type wasmModel struct {
 	db            *idb.Database
 	cipher        idbCrypto.Cipher
 	eventCallback eventUpdate
+
+	// batch coalesces the inserts of received messages into multi-row
+	// transactions.
+	batch *impl.WriteBatcher
+
+	// extendedEncryption is true if message and conversation metadata is
+	// encrypted in addition to the message text.
+	extendedEncryption bool
+
+	// conversationsNeedMigration is true if the database was upgraded from
+	// before v4 and the activity of its conversations has not been filled in
+	// by migrateConversations.
+	conversationsNeedMigration bool
+
+	// messagesNeedMigration is true if the database was upgraded from before
+	// v5 and the Message.TimestampKey of its messages has not been filled in
+	// by migrateMessages.
+	messagesNeedMigration bool
 }
 
 // upsertConversation is used for joining or updating a Conversation.
@@ -48,32 +70,102 @@ func (w *wasmModel) upsertConversation(nickname string,
 	parentErr := errors.New("[DM indexedDB] failed to upsertConversation")
 
 	// Build object
-	newConvo := Conversation{
+	convoObj, err := w.newConversationObject(&Conversation{
 		Pubkey:           pubKey,
 		Nickname:         nickname,
 		Token:            partnerToken,
 		CodesetVersion:   codeset,
 		BlockedTimestamp: blockedTimestamp,
+	})
+	if err != nil {
+		return errors.WithMessage(parentErr, err.Error())
 	}
 
-	// Convert to jsObject
-	newConvoJson, err := json.Marshal(&newConvo)
+	_, err = impl.Put(w.db, conversationStoreName, convoObj)
 	if err != nil {
 		return errors.WithMessagef(parentErr,
+			"Unable to put Conversation: %+v", err)
+	}
+	return nil
+}
+
+// newConversationObject encrypts the metadata of the Conversation, if extended
+// encryption is enabled, and converts it to a Javascript object for storage.
+func (w *wasmModel) newConversationObject(
+	convo *Conversation) (js.Value, error) {
+	encryptedConvo, err := w.encryptConversationMetadata(convo)
+	if err != nil {
+		return js.Undefined(), err
+	}
+
+	newConvoJson, err := json.Marshal(encryptedConvo)
+	if err != nil {
+		return js.Undefined(), errors.Errorf(
 			"Unable to marshal Conversation: %+v", err)
 	}
 	convoObj, err := utils.JsonToJS(newConvoJson)
 	if err != nil {
-		return errors.WithMessagef(parentErr,
+		return js.Undefined(), errors.Errorf(
 			"Unable to marshal Conversation: %+v", err)
 	}
+	return convoObj, nil
+}
 
-	_, err = impl.Put(w.db, conversationStoreName, convoObj)
+// upsertConversationAndMessage stores the Conversation with the partner and
+// inserts the new Message in a single transaction so that either both are
+// stored or neither is. If the Conversation is already stored, only its
+// nickname, token, and codeset are replaced with those of convo so that no
+// other change to it is lost. If convo is nil, the stored Conversation is left
+// unchanged apart from the activity. The activity, if not nil, is applied to
+// the Conversation. Returns the UUID of the Message.
+func (w *wasmModel) upsertConversationAndMessage(partnerKey ed25519.PublicKey,
+	convo *Conversation, msg *Message, activity *conversationActivity) (
+	uint64, error) {
+	parentErr := errors.New(
+		"[DM indexedDB] failed to upsertConversationAndMessage")
+
+	messageObj, err := w.newMessageObject(msg)
 	if err != nil {
-		return errors.WithMessagef(parentErr,
-			"Unable to put Conversation: %+v", err)
+		return 0, errors.WithMessage(parentErr, err.Error())
+	}
+
+	var uuid uint64
+	err = impl.Transact(w.db,
+		[]string{conversationStoreName, messageStoreName},
+		func(txn *impl.Transaction) error {
+			newConvo := convo
+			stored, err := w.getConversationTxn(txn, partnerKey)
+			if err == nil {
+				if convo != nil {
+					stored.Nickname = convo.Nickname
+					stored.Token = convo.Token
+					stored.CodesetVersion = convo.CodesetVersion
+				}
+				newConvo = stored
+			} else if convo == nil ||
+				!strings.Contains(err.Error(), impl.ErrDoesNotExist) {
+				return err
+			}
+			if activity != nil {
+				activity.apply(newConvo)
+			}
+
+			if err = w.putConversation(txn, newConvo); err != nil {
+				return err
+			}
+			msgIdObj, err := txn.Put(messageStoreName, messageObj)
+			if err != nil {
+				return errors.Errorf("Unable to put Message: %+v", err)
+			}
+			uuid = uint64(msgIdObj.Int())
+			return nil
+		})
+	if err != nil {
+		return 0, errors.WithMessage(parentErr, err.Error())
 	}
-	return nil
+
+	jww.DEBUG.Printf("[DM indexedDB] Successfully stored message %d", uuid)
+	return uuid, nil
 }
 
 // buildMessage is a private helper that converts typical dm.EventModel inputs
@@ -105,6 +197,17 @@ func (w *wasmModel) Receive(messageID message.ID, nickname string, text []byte,
 	parentErr := "[DM indexedDB] failed to Receive"
 	jww.TRACE.Printf("[DM indexedDB] Receive(%s)", messageID)
 
+	// Edits change an existing message instead of being stored. Zero is
+	// returned so that the sent status of an edit does not overwrite the
+	// edited message.
+	if mType == impl.EditMessageType {
+		if _, err := w.receiveEdit(messageID, text, partnerKey, senderKey,
+			timestamp); err != nil {
+			jww.ERROR.Printf("%+v", errors.WithMessagef(err, parentErr))
+		}
+		return 0
+	}
+
 	uuid, err := w.receiveWrapper(messageID, nil, nickname, string(text),
 		partnerKey, senderKey, dmToken, codeset, timestamp, round, mType, status)
 	if err != nil {
@@ -168,35 +271,52 @@ func (w *wasmModel) UpdateSentStatus(uuid uint64, messageID message.ID,
 	jww.TRACE.Printf(
 		"[DM indexedDB] UpdateSentStatus(%d, %s, ...)", uuid, messageID)
 
+	// Messages that are not stored, such as edits, have a UUID of zero
+	if uuid == 0 {
+		return
+	}
+
 	// Convert messageID to the key generated by json.Marshal
 	key := js.ValueOf(uuid)
 
-	// Use the key to get the existing Message
-	currentMsg, err := impl.Get(w.db, messageStoreName, key)
-	if err != nil {
-		jww.ERROR.Printf("%+v", errors.WithMessagef(parentErr,
-			"Unable to get message: %+v", err))
-		return
-	}
+	// Get and update the existing Message in one transaction so that no other
+	// change to the Message is lost in between
+	var newMessage *Message
+	err := impl.Transact(w.db, []string{messageStoreName},
+		func(txn *impl.Transaction) error {
+			// Use the key to get the existing Message
+			currentMsg, err := txn.Get(messageStoreName, key)
+			if err != nil {
+				return errors.Errorf("Unable to get message: %+v", err)
+			}
 
-	// Extract the existing Message and update the Status
-	newMessage, err := valueToMessage(currentMsg)
+			// Extract the existing Message and update the Status
+			newMessage, err = valueToMessage(currentMsg)
+			if err != nil {
+				return err
+			}
 
-	newMessage.Status = uint8(status)
-	if !messageID.Equals(message.ID{}) {
-		newMessage.MessageID = messageID.Bytes()
-	}
+			newMessage.Status = uint8(status)
+			if !messageID.Equals(message.ID{}) {
+				newMessage.MessageID = messageID.Bytes()
+			}
 
-	if round.ID != 0 {
-		newMessage.Round = uint64(round.ID)
-	}
+			if round.ID != 0 {
+				newMessage.Round = uint64(round.ID)
+			}
 
-	if !timestamp.Equal(time.Time{}) {
-		newMessage.Timestamp = timestamp
-	}
+			if !timestamp.Equal(time.Time{}) {
+				newMessage.Timestamp = timestamp
+			}
 
-	// Store the updated Message
-	_, err = w.upsertMessage(newMessage)
+			// Store the updated Message
+			messageObj, err := w.newMessageObject(newMessage)
+			if err != nil {
+				return err
+			}
+			_, err = txn.Put(messageStoreName, messageObj)
+			return err
+		})
 	if err != nil {
 		jww.ERROR.Printf("%+v", errors.Wrap(parentErr, err.Error()))
 		return
@@ -242,36 +362,18 @@ func (w *wasmModel) receiveWrapper(messageID message.ID, parentID *message.ID, n
 		jww.DEBUG.Printf(
 			"[DM indexedDB] Conversation with %s already joined", nickname)
 
-		// Update Conversation if nickname was altered
-		isFromPartner := bytes.Equal(result.Pubkey, senderKey)
-		nicknameChanged := result.Nickname != nickname
-		if isFromPartner && nicknameChanged {
-			jww.DEBUG.Printf(
-				"[DM indexedDB] Updating from nickname %s to %s",
-				result.Nickname, nickname)
-			convoToUpdate = result
-			convoToUpdate.Nickname = nickname
-		}
-
-		// Fix conversation if dmToken is altered
-		dmTokenChanged := result.Token != partnerToken
-		if isFromPartner && dmTokenChanged {
-			jww.WARN.Printf(
-				"[DM indexedDB] Updating from dmToken %d to %d",
-				result.Token, partnerToken)
+		newNickname, newToken, changed := dmModel.PartnerUpdate(result.Pubkey,
+			senderKey, result.Nickname, result.Token, nickname, partnerToken)
+		if changed {
 			convoToUpdate = result
-			convoToUpdate.Token = partnerToken
+			convoToUpdate.Nickname, convoToUpdate.Token = newNickname, newToken
 		}
 	}
 
-	// Update the conversation in storage, if needed
-	conversationUpdated := convoToUpdate != nil
-	if conversationUpdated {
-		err = w.upsertConversation(convoToUpdate.Nickname, convoToUpdate.Pubkey,
-			convoToUpdate.Token, convoToUpdate.CodesetVersion, convoToUpdate.BlockedTimestamp)
-		if err != nil {
-			return 0, err
-		}
+	activity, err := w.newConversationActivity(
+		data, partnerKey, senderKey, timestamp, mType)
+	if err != nil {
+		return 0, err
 	}
 
 	// Handle encryption, if it is present
@@ -290,14 +392,25 @@ func (w *wasmModel) receiveWrapper(messageID message.ID, parentID *message.ID, n
 	msgToInsert := buildMessage(messageID.Bytes(), parentIdBytes, data,
 		partnerKey, senderKey, timestamp, round.ID, mType, codeset, status)
 
-	uuid, err := w.upsertMessage(msgToInsert)
+	// Store the message and, if needed, update the conversation and its
+	// activity. Both are written in one transaction so that neither is stored
+	// without the other. Messages that do not change the conversation are
+	// batched.
+	var uuid uint64
+	conversationUpdated := convoToUpdate != nil || activity != nil
+	if conversationUpdated {
+		uuid, err = w.upsertConversationAndMessage(
+			partnerKey, convoToUpdate, msgToInsert, activity)
+	} else {
+		uuid, err = w.insertMessage(msgToInsert)
+	}
 	if err != nil {
 		return 0, err
 	}
 
 	jww.TRACE.Printf("[DM indexedDB] Calling ReceiveMessageCB(%v, %v, f, %t)",
 		uuid, partnerKey, conversationUpdated)
-	go w.eventCallback(bindings.DmMessageReceived, bindings.DmMessageReceivedJSON{
+	w.eventCallback(bindings.DmMessageReceived, bindings.DmMessageReceivedJSON{
 		UUID:               uuid,
 		PubKey:             partnerKey,
 		MessageUpdate:      false,
@@ -306,24 +419,81 @@ func (w *wasmModel) receiveWrapper(messageID message.ID, parentID *message.ID, n
 	return uuid, nil
 }
 
-// upsertMessage is a helper function that will update an existing record
-// if Message.ID is specified. Otherwise, it will perform an insert.
-func (w *wasmModel) upsertMessage(msg *Message) (uint64, error) {
-	// Convert to jsObject
-	newMessageJson, err := json.Marshal(msg)
+// insertMessage adds the new Message to the write batch and blocks until the
+// batch is written. Concurrent calls are coalesced into a single transaction.
+// If the batch fails, the Message is written on its own with upsertMessage.
+func (w *wasmModel) insertMessage(msg *Message) (uint64, error) {
+	messageObj, err := w.newMessageObject(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	type result struct {
+		uuid uint64
+		err  error
+	}
+	resultChan := make(chan result, 1)
+	w.batch.Add(&impl.BatchedWrite{
+		Value: messageObj,
+		Fallback: func() (js.Value, error) {
+			uuid, err := w.upsertMessage(msg)
+			return js.ValueOf(uuid), err
+		},
+		Done: func(key js.Value, err error) {
+			if err != nil {
+				resultChan <- result{0, err}
+			} else {
+				resultChan <- result{uint64(key.Int()), nil}
+			}
+		},
+	})
+
+	r := <-resultChan
+	if r.err == nil {
+		jww.DEBUG.Printf(
+			"[DM indexedDB] Successfully stored message %d", r.uuid)
+	}
+	return r.uuid, r.err
+}
+
+// newMessageObject sets the Message.TimestampKey, encrypts the metadata of the
+// Message, if extended encryption is enabled, and converts it to a Javascript
+// object for storage.
+func (w *wasmModel) newMessageObject(msg *Message) (js.Value, error) {
+	msg.TimestampKey = timestampKey(msg.Timestamp)
+	encryptedMsg, err := w.encryptMessageMetadata(msg)
+	if err != nil {
+		return js.Undefined(), err
+	}
+
+	newMessageJson, err := json.Marshal(encryptedMsg)
 	if err != nil {
-		return 0, errors.Errorf("Unable to marshal Message: %+v", err)
+		return js.Undefined(), errors.Errorf(
+			"Unable to marshal Message: %+v", err)
 	}
 	messageObj, err := utils.JsonToJS(newMessageJson)
 	if err != nil {
-		return 0, errors.Errorf("Unable to marshal Message: %+v", err)
+		return js.Undefined(), errors.Errorf(
+			"Unable to marshal Message: %+v", err)
+	}
+	return messageObj, nil
+}
+
+// upsertMessage is a helper function that will update an existing record
+// if Message.ID is specified. Otherwise, it will perform an insert.
+func (w *wasmModel) upsertMessage(msg *Message) (uint64, error) {
+	// Convert to jsObject, encrypting the metadata if extended encryption is
+	// enabled
+	messageObj, err := w.newMessageObject(msg)
+	if err != nil {
+		return 0, err
 	}
 
 	// Store message to database
 	msgIdObj, err := impl.Put(w.db, messageStoreName, messageObj)
 	if err != nil {
 		return 0, errors.Errorf("Unable to put Message: %+v\n%s",
-			err, newMessageJson)
+			err, utils.JsToJson(messageObj))
 	}
 
 	uuid := msgIdObj.Int()
@@ -353,20 +523,36 @@ func (w *wasmModel) UnblockSender(senderPubKey ed25519.PublicKey) {
 
 // setBlocked is a helper for blocking/unblocking a given Conversation.
 func (w *wasmModel) setBlocked(senderPubKey ed25519.PublicKey, isBlocked bool) error {
-	// Get current Conversation and set blocked accordingly
-	resultConvo, err := w.getConversation(senderPubKey)
-	if err != nil {
-		return err
-	}
+	// Get current Conversation and set blocked accordingly in one transaction
+	// so that no other change to the Conversation is lost in between
+	return impl.Transact(w.db, []string{conversationStoreName},
+		func(txn *impl.Transaction) error {
+			convoObj, err := txn.Get(
+				conversationStoreName, impl.EncodeBytes(senderPubKey))
+			if err != nil {
+				return err
+			}
+			resultConvo, err := valueToConversation(convoObj)
+			if err != nil {
+				return err
+			}
+			if err = w.decryptConversationMetadata(resultConvo); err != nil {
+				return err
+			}
 
-	var timeBlocked *time.Time
-	if isBlocked {
-		blockUser := netTime.Now()
-		timeBlocked = &blockUser
-	}
+			resultConvo.BlockedTimestamp = nil
+			if isBlocked {
+				blockUser := netTime.Now()
+				resultConvo.BlockedTimestamp = &blockUser
+			}
 
-	return w.upsertConversation(resultConvo.Nickname, resultConvo.Pubkey,
-		resultConvo.Token, resultConvo.CodesetVersion, timeBlocked)
+			convoObj, err = w.newConversationObject(resultConvo)
+			if err != nil {
+				return err
+			}
+			_, err = txn.Put(conversationStoreName, convoObj)
+			return err
+		})
 }
 
 // DeleteMessage deletes the message with the given message.ID belonging to
@@ -391,6 +577,10 @@ func (w *wasmModel) DeleteMessage(messageID message.ID, senderPubKey ed25519.Pub
 		jww.ERROR.Printf("%s: %+v", parentErr, err)
 		return false
 	}
+	if err = w.decryptMessageMetadata(msgObj); err != nil {
+		jww.ERROR.Printf("%s: %+v", parentErr, err)
+		return false
+	}
 
 	// Ensure the public keys match
 	if !bytes.Equal(msgObj.SenderPubKey, senderPubKey) {
@@ -437,12 +627,11 @@ func (w *wasmModel) getConversation(senderPubKey ed25519.PublicKey) (*Conversati
 		return nil, err
 	}
 
-	resultConvo := &Conversation{}
-	err = json.Unmarshal([]byte(utils.JsToJson(resultObj)), resultConvo)
+	resultConvo, err := valueToConversation(resultObj)
 	if err != nil {
 		return nil, err
 	}
-	return resultConvo, nil
+	return resultConvo, w.decryptConversationMetadata(resultConvo)
 }
 
 // GetConversations returns any conversations held by the model (receiver).
@@ -457,8 +646,10 @@ func (w *wasmModel) GetConversations() []dm.ModelConversation {
 
 	conversations := make([]dm.ModelConversation, len(results))
 	for i := range results {
-		resultConvo := &Conversation{}
-		err = json.Unmarshal([]byte(utils.JsToJson(results[i])), resultConvo)
+		resultConvo, err := valueToConversation(results[i])
+		if err == nil {
+			err = w.decryptConversationMetadata(resultConvo)
+		}
 		if err != nil {
 			jww.ERROR.Printf("%+v", errors.WithMessage(err, parentErr))
 			return nil
@@ -479,3 +670,10 @@ func valueToMessage(msgObj js.Value) (*Message, error) {
 	resultMsg := &Message{}
 	return resultMsg, json.Unmarshal([]byte(utils.JsToJson(msgObj)), resultMsg)
 }
+
+// valueToConversation is a helper for converting js.Value to Conversation.
+func valueToConversation(convoObj js.Value) (*Conversation, error) {
+	resultConvo := &Conversation{}
+	return resultConvo,
+		json.Unmarshal([]byte(utils.JsToJson(convoObj)), resultConvo)
+}
@@ -17,14 +17,18 @@ import (
 	"github.com/stretchr/testify/require"
 	"gitlab.com/elixxir/client/v4/cmix/rounds"
 	"gitlab.com/elixxir/client/v4/dm"
+	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
 	"gitlab.com/elixxir/crypto/message"
 	"gitlab.com/elixxir/wasm-utils/utils"
 	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+	"gitlab.com/xx_network/crypto/csprng"
 	"gitlab.com/xx_network/primitives/id"
 	"os"
+	"strings"
 	"syscall/js"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	jww "github.com/spf13/jwalterweatherman"
 )
@@ -190,3 +194,393 @@ func TestWasmModel_DeleteMessage(t *testing.T) {
 	// Correct pub key, should have deleted
 	require.True(t, m.DeleteMessage(testMsgId, testBytes))
 }
+
+// Tests that enabling extended encryption encrypts the metadata of existing
+// messages and conversations, that the sender index contains the blind index,
+// and that the metadata can still be read.
+func TestWasmModel_enableExtendedEncryption(t *testing.T) {
+	testString := "TestWasmModel_enableExtendedEncryption"
+	cipher, err := idbCrypto.NewCipher(
+		[]byte(testString), []byte("testSalt"), 32, csprng.NewSystemRNG())
+	require.NoError(t, err)
+	m, err := newWASMModel(testString, cipher, dummyEU)
+	require.NoError(t, err)
+
+	nickname := "partnerNickname"
+	partnerKey := ed25519.PublicKey("partnerPubKey")
+	oldMsgId := message.DeriveChannelMessageID(&id.ID{1}, 1, []byte("old"))
+	oldUuid := m.Receive(oldMsgId, nickname, []byte("old"), partnerKey,
+		partnerKey, 5, 0, time.Now(), rounds.Round{ID: 1}, dm.TextType,
+		dm.Received)
+	require.NotZero(t, oldUuid)
+
+	require.NoError(t, m.enableExtendedEncryption())
+
+	newMsgId := message.DeriveChannelMessageID(&id.ID{1}, 2, []byte("new"))
+	newUuid := m.Receive(newMsgId, nickname, []byte("new"), partnerKey,
+		partnerKey, 5, 0, time.Now(), rounds.Round{ID: 2}, dm.TextType,
+		dm.Received)
+	require.NotZero(t, newUuid)
+
+	// Check the stored values
+	senderIndex, err := impl.BlindIndex(cipher, partnerKey)
+	require.NoError(t, err)
+	for _, uuid := range []uint64{oldUuid, newUuid} {
+		msgObj, err := impl.Get(m.db, messageStoreName, js.ValueOf(uuid))
+		require.NoError(t, err)
+		msg, err := valueToMessage(msgObj)
+		require.NoError(t, err)
+		require.Equal(t, senderIndex, msg.SenderPubKey)
+		require.NotNil(t, msg.Metadata)
+	}
+	convoObj, err := impl.Get(m.db, conversationStoreName,
+		impl.EncodeBytes(partnerKey))
+	require.NoError(t, err)
+	convo, err := valueToConversation(convoObj)
+	require.NoError(t, err)
+	require.Empty(t, convo.Nickname)
+	require.Zero(t, convo.Token)
+	require.NotNil(t, convo.Metadata)
+
+	// Check that the metadata is decrypted on read
+	modelConvo := m.GetConversation(partnerKey)
+	require.NotNil(t, modelConvo)
+	require.Equal(t, nickname, modelConvo.Nickname)
+	require.EqualValues(t, 5, modelConvo.Token)
+	convos := m.GetConversations()
+	require.Len(t, convos, 1)
+	require.Equal(t, nickname, convos[0].Nickname)
+
+	// Blocking rewrites the conversation and must keep it encrypted
+	m.BlockSender(partnerKey)
+	modelConvo = m.GetConversation(partnerKey)
+	require.NotNil(t, modelConvo.BlockedTimestamp)
+	require.Equal(t, nickname, modelConvo.Nickname)
+
+	require.False(t, m.DeleteMessage(newMsgId, ed25519.PublicKey("uwu")))
+	require.True(t, m.DeleteMessage(newMsgId, partnerKey))
+}
+
+// Tests that an edit received with Receive replaces the text of the message
+// and records it in the edit history, that edits from the partner of messages
+// sent by the user are rejected, and that only an edit of the last message
+// changes the conversation preview.
+func TestWasmModel_Receive_Edit(t *testing.T) {
+	m, err := newWASMModel("TestWasmModel_Receive_Edit", nil, dummyEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	me, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+	partner, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+	msgID := message.DeriveChannelMessageID(&id.ID{1}, 0, []byte("original"))
+	now := time.Now()
+	uuid := m.Receive(msgID, "nick", []byte("original"), partner, me, 0, 0,
+		now, rounds.Round{}, dm.TextType, dm.Sent)
+
+	receiveEdit := func(n int, sender ed25519.PublicKey, text string) {
+		content, err := json.Marshal(impl.MessageEdit{
+			MessageID: msgID, AuthorKey: sender, Text: text})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Receive(message.ID{byte(n)}, "nick", content, partner, sender, 0,
+			0, now.Add(time.Duration(n)*time.Minute), rounds.Round{},
+			impl.EditMessageType, dm.Received) != 0 {
+			t.Error("Edit was stored as a message.")
+		}
+	}
+	receiveEdit(1, partner, "not mine")
+	receiveEdit(2, me, "edited")
+
+	msgObj, err := impl.Get(m.db, messageStoreName, js.ValueOf(uuid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := valueToMessage(msgObj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Text != "edited" {
+		t.Errorf("Unexpected text.\nexpected: %q\nreceived: %q",
+			"edited", msg.Text)
+	}
+	if len(msg.EditHistory) != 2 || msg.EditHistory[0].Text != "original" {
+		t.Errorf("Unexpected edit history: %+v", msg.EditHistory)
+	}
+
+	// The preview only follows edits of the last message
+	preview := func() string {
+		convoObj, err := impl.Get(
+			m.db, conversationStoreName, impl.EncodeBytes(partner))
+		require.NoError(t, err)
+		convo, err := valueToConversation(convoObj)
+		require.NoError(t, err)
+		return convo.LastMessagePreview
+	}
+	require.Equal(t, "edited", preview())
+	m.Receive(message.ID{100}, "nick", []byte("newer"), partner, partner, 0, 0,
+		now.Add(time.Hour), rounds.Round{}, dm.TextType, dm.Received)
+	receiveEdit(3, me, "edited again")
+	require.Equal(t, "newer", preview())
+}
+
+// Tests that a draft survives saving and loading, that ClearDraft keeps drafts
+// saved after the cutoff, and that saving empty text deletes the draft.
+func TestWasmModel_Drafts(t *testing.T) {
+	m, err := newWASMModel("TestWasmModel_Drafts", nil, dummyEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partner, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+	replyTo := message.ID{7}
+	require.NoError(t, m.SaveDraft(partner, "Hello, wor", &replyTo))
+
+	draft, err := m.GetDraft(partner)
+	require.NoError(t, err)
+	require.NotNil(t, draft)
+	require.Equal(t, "Hello, wor", draft.Text)
+	require.Equal(t, replyTo.Marshal(), draft.ReplyTo)
+
+	// A draft saved after the message was sent is kept
+	require.NoError(t, m.ClearDraft(partner, draft.Timestamp.Add(-time.Second)))
+	draft, err = m.GetDraft(partner)
+	require.NoError(t, err)
+	require.NotNil(t, draft)
+
+	require.NoError(t, m.ClearDraft(partner, time.Now().Add(time.Second)))
+	draft, err = m.GetDraft(partner)
+	require.NoError(t, err)
+	require.Nil(t, draft)
+
+	require.NoError(t, m.SaveDraft(partner, "Hello", nil))
+	require.NoError(t, m.SaveDraft(partner, "", nil))
+	draft, err = m.GetDraft(partner)
+	require.NoError(t, err)
+	require.Nil(t, draft)
+}
+
+// Tests that wasmModel.GetMessages pages through the messages of only the
+// conversation, newest first, regardless of the order they were received in.
+func TestWasmModel_GetMessages(t *testing.T) {
+	m, err := newWASMModel("TestWasmModel_GetMessages", nil, dummyEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partner, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+	other, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+	now := time.Now()
+
+	// Receive the messages out of order, with two at the same time and in
+	// different time zones
+	zone := time.FixedZone("", -7*60*60)
+	offsets := []int{2, 0, 4, 1, 3, 3}
+	for i, offset := range offsets {
+		timestamp := now.Add(time.Duration(offset) * time.Minute)
+		if i%2 == 0 {
+			timestamp = timestamp.In(zone)
+		}
+		m.Receive(message.ID{byte(i + 1)}, "nick", []byte(fmt.Sprint(i)),
+			partner, partner, 0, 0, timestamp, rounds.Round{}, dm.TextType,
+			dm.Received)
+	}
+	m.Receive(message.ID{100}, "nick", []byte("other"), other, other, 0, 0,
+		now.Add(time.Hour), rounds.Round{}, dm.TextType, dm.Received)
+
+	// Messages at the same time are returned newest received first
+	expected := []string{"2", "5", "4", "0", "3", "1"}
+	var received []string
+	var cursor []byte
+	for pages := 0; ; pages++ {
+		if pages > len(expected) {
+			t.Fatal("Paging did not end.")
+		}
+		page, err := m.GetMessages(partner, cursor, 4)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page.Messages), 4)
+		for _, msg := range page.Messages {
+			require.Equal(t, dm.Received, msg.Status)
+			received = append(received, msg.Text)
+		}
+		if cursor = page.NextCursor; len(cursor) == 0 {
+			break
+		}
+	}
+	require.Equal(t, expected, received)
+
+	page, err := m.GetMessages(partner, nil, 0)
+	require.NoError(t, err)
+	require.Len(t, page.Messages, len(expected))
+	require.Empty(t, page.NextCursor)
+
+	_, err = m.GetMessages(partner, []byte("invalid"), 1)
+	require.Error(t, err)
+}
+
+// Tests that timestampKey sorts timestamps chronologically regardless of their
+// time zone and precision.
+func Test_timestampKey(t *testing.T) {
+	base := time.Date(2023, 7, 21, 18, 42, 9, 0, time.UTC)
+	timestamps := []time.Time{
+		base,
+		base.Add(500 * time.Millisecond).In(time.FixedZone("", -7*60*60)),
+		base.Add(512 * time.Millisecond),
+		base.Add(time.Hour).In(time.FixedZone("", 9*60*60)),
+	}
+
+	for i := 1; i < len(timestamps); i++ {
+		require.Less(t, timestampKey(timestamps[i-1]),
+			timestampKey(timestamps[i]), "timestamp %d", i)
+	}
+	require.Equal(t, timestampKey(base), timestampKey(base.In(time.Local)))
+}
+
+// Tests that received messages update the activity of their conversation and
+// that wasmModel.ListConversations sorts and filters conversations.
+func TestWasmModel_ListConversations(t *testing.T) {
+	m, err := newWASMModel("TestWasmModel_ListConversations", nil, dummyEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	me, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+	alice, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+	bob, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+	now := time.Now()
+
+	m.ReceiveText(message.ID{1}, "alice", "Hi", alice, alice, 0, 0, now,
+		rounds.Round{}, dm.Received)
+	m.ReceiveText(message.ID{2}, "alice", "How are you?", alice, alice, 0, 0,
+		now.Add(time.Minute), rounds.Round{}, dm.Received)
+	m.ReceiveText(message.ID{3}, "me", "Hello Bob", bob, me, 0, 0,
+		now.Add(2*time.Minute), rounds.Round{}, dm.Sent)
+
+	// Older messages and reactions do not replace the newest message
+	m.ReceiveText(message.ID{4}, "alice", "Late", alice, alice, 0, 0,
+		now.Add(-time.Minute), rounds.Round{}, dm.Received)
+	m.ReceiveReaction(message.ID{5}, message.ID{3}, "bob", "👍", bob, bob,
+		0, 0, now.Add(3*time.Minute), rounds.Round{}, dm.Received)
+
+	convos, err := m.ListConversations("", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, convos, 2)
+	require.Equal(t, []byte(bob), convos[0].Pubkey)
+	require.Equal(t, "Hello Bob", convos[0].LastMessagePreview)
+	require.Equal(t, []byte(me), convos[0].LastSenderPubKey)
+	require.Zero(t, convos[0].UnreadCount)
+	require.Equal(t, []byte(alice), convos[1].Pubkey)
+	require.Equal(t, "How are you?", convos[1].LastMessagePreview)
+	require.Equal(t, uint32(3), convos[1].UnreadCount)
+
+	convos, err = m.ListConversations(sortByUnread, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []byte(alice), convos[0].Pubkey)
+
+	require.NoError(t, m.MarkConversationRead(alice))
+	convos, err = m.ListConversations(sortByUnread, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []byte(bob), convos[0].Pubkey)
+	require.Zero(t, convos[1].UnreadCount)
+
+	require.NoError(t, m.setBlocked(bob, true))
+	blocked, notArchived := true, false
+	convos, err = m.ListConversations(sortByNickname, &blocked, &notArchived)
+	require.NoError(t, err)
+	require.Len(t, convos, 1)
+	require.Equal(t, []byte(bob), convos[0].Pubkey)
+
+	_, err = m.ListConversations("invalid", nil, nil)
+	require.Error(t, err)
+}
+
+// Error path: Tests that wasmModel.upsertConversationAndMessage stores
+// neither the message nor the activity when the conversation does not exist.
+func TestWasmModel_upsertConversationAndMessage_NoConversation(t *testing.T) {
+	m, err := newWASMModel(
+		"TestWasmModel_upsertConversationAndMessage_NoConversation", nil,
+		dummyEU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partner, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+	msg := buildMessage(message.ID{1}.Bytes(), nil, "Hi", partner, partner,
+		time.Now(), 0, dm.TextType, 0, dm.Received)
+	activity, err := m.newConversationActivity(
+		"Hi", partner, partner, time.Now(), dm.TextType)
+	require.NoError(t, err)
+
+	_, err = m.upsertConversationAndMessage(partner, nil, msg, activity)
+	require.Error(t, err)
+
+	count, err := impl.Count(m.db, messageStoreName)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+// Tests that truncatePreview shortens long text without splitting characters.
+func Test_truncatePreview(t *testing.T) {
+	require.Equal(t, "Hello", truncatePreview("  Hello\n"))
+
+	long := truncatePreview(strings.Repeat("é", previewLength*2))
+	require.Equal(t, previewLength, utf8.RuneCountInString(long))
+	require.True(t, utf8.ValidString(long))
+}
+
+// Tests that wasmModel.SetConversationState archives, pins, and mutes a
+// conversation, sends an event only on change, and that pinned conversations
+// are listed first.
+func TestWasmModel_SetConversationState(t *testing.T) {
+	events := make(chan impl.ConversationStateUpdateJSON, 10)
+	m, err := newWASMModel("TestWasmModel_SetConversationState", nil,
+		func(eventType int64, data any) {
+			if eventType == impl.ConversationStateUpdate {
+				events <- data.(impl.ConversationStateUpdateJSON)
+			}
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+	bob, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+	now := time.Now()
+	m.ReceiveText(message.ID{1}, "alice", "Hi", alice, alice, 0, 0, now,
+		rounds.Round{}, dm.Received)
+	m.ReceiveText(message.ID{2}, "bob", "Hi", bob, bob, 0, 0,
+		now.Add(time.Minute), rounds.Round{}, dm.Received)
+
+	yes, no := true, false
+	require.NoError(t, m.SetConversationState(alice, &yes, &yes, &yes))
+	select {
+	case state := <-events:
+		require.Equal(t, impl.ConversationStateUpdateJSON{
+			PubKey: alice, Archived: true, Pinned: true, Muted: true}, state)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for state update event.")
+	}
+
+	// Setting the same state again does not send an event
+	require.NoError(t, m.SetConversationState(alice, nil, &yes, nil))
+	select {
+	case state := <-events:
+		t.Errorf("Unexpected event for unchanged state: %+v", state)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	convos, err := m.ListConversations("", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []byte(alice), convos[0].Pubkey)
+	require.NotNil(t, convos[0].PinnedTimestamp)
+	require.True(t, convos[0].Muted)
+
+	convos, err = m.ListConversations("", nil, &no)
+	require.NoError(t, err)
+	require.Len(t, convos, 1)
+	require.Equal(t, []byte(bob), convos[0].Pubkey)
+
+	unknown, _, _ := ed25519.GenerateKey(csprng.NewSystemRNG())
+	require.Error(t, m.SetConversationState(unknown, &yes, nil, nil))
+}
@@ -22,7 +22,7 @@ import (
 
 // currentVersion is the current version of the IndexedDb runtime. Used for
 // migration purposes.
-const currentVersion uint = 1
+const currentVersion uint = 5
 
 // eventUpdate takes an event type and JSON object from bindings/dm.go.
 type eventUpdate func(eventType int64, jsonMarshallable any)
@@ -30,18 +30,45 @@ type eventUpdate func(eventType int64, jsonMarshallable any)
 // NewWASMEventModel returns a [channels.EventModel] backed by a wasmModel.
 // The name should be a base64 encoding of the users public key. Returns the
 // EventModel based on IndexedDb and the database name as reported by IndexedDb.
+//
+// If extendedEncryption is true, message metadata is also encrypted (see
+// wasmModel.enableExtendedEncryption).
 func NewWASMEventModel(databaseName string, encryption idbCrypto.Cipher,
-	eventCallback eventUpdate) (dm.EventModel, error) {
-	return newWASMModel(databaseName, encryption, eventCallback)
+	extendedEncryption bool, eventCallback eventUpdate) (dm.EventModel, error) {
+	model, err := newWASMModel(databaseName, encryption, eventCallback)
+	if err != nil {
+		return nil, err
+	}
+
+	if extendedEncryption {
+		if err = model.enableExtendedEncryption(); err != nil {
+			return nil, err
+		}
+	}
+
+	if model.messagesNeedMigration {
+		if err = model.migrateMessages(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Conversations are migrated once extended encryption is known so that
+	// their metadata is stored encrypted
+	if model.conversationsNeedMigration {
+		if err = model.migrateConversations(); err != nil {
+			return nil, err
+		}
+	}
+
+	return model, nil
 }
 
 // newWASMModel creates the given [idb.Database] and returns a wasmModel.
 func newWASMModel(databaseName string, encryption idbCrypto.Cipher,
 	eventCallback eventUpdate) (*wasmModel, error) {
 	// Attempt to open database object
-	ctx, cancel := impl.NewContext()
-	defer cancel()
-	openRequest, err := idb.Global().Open(ctx, databaseName, currentVersion,
+	var migrateConversations, migrateMessages bool
+	db, err := impl.Open(databaseName, currentVersion,
 		func(db *idb.Database, oldVersion, newVersion uint) error {
 			if oldVersion == newVersion {
 				jww.INFO.Printf("IndexDb version for %s is current: v%d",
@@ -60,25 +87,58 @@ func newWASMModel(databaseName string, encryption idbCrypto.Cipher,
 				oldVersion = 1
 			}
 
-			// if oldVersion == 1 && newVersion >= 2 { v2Upgrade(), oldVersion = 2 }
+			if oldVersion == 1 && newVersion >= 2 {
+				err := v2Upgrade(db)
+				if err != nil {
+					return err
+				}
+				oldVersion = 2
+			}
+
+			if oldVersion == 2 && newVersion >= 3 {
+				err := v3Upgrade(db)
+				if err != nil {
+					return err
+				}
+				oldVersion = 3
+			}
+
+			if oldVersion == 3 && newVersion >= 4 {
+				// The activity of conversations started before v4 is filled in
+				// by wasmModel.migrateConversations once the database is open
+				migrateConversations = true
+				oldVersion = 4
+			}
+
+			if oldVersion == 4 && newVersion >= 5 {
+				err := v5Upgrade(db)
+				if err != nil {
+					return err
+				}
+				// The timestamp key of messages stored before v5 is filled in
+				// by wasmModel.migrateMessages once the database is open
+				migrateMessages = true
+				oldVersion = 5
+			}
+
+			// if oldVersion == 5 && newVersion >= 6 { v6Upgrade(), oldVersion = 6 }
 			return nil
 		})
 	if err != nil {
 		return nil, err
 	}
 
-	// Wait for database open to finish
-	db, err := openRequest.Await(ctx)
-	if err != nil {
-		return nil, err
-	} else if ctx.Err() != nil {
-		return nil, ctx.Err()
-	}
+	// Send quota and connection events to the UI
+	impl.Watch(db, eventCallback)
 
 	wrapper := &wasmModel{
 		db:            db,
 		cipher:        encryption,
 		eventCallback: eventCallback,
+		batch: impl.NewWriteBatcher(db, messageStoreName,
+			impl.DefaultBatchWindow, impl.DefaultMaxBatchSize),
+		conversationsNeedMigration: migrateConversations,
+		messagesNeedMigration:      migrateMessages,
 	}
 	return wrapper, nil
 }
@@ -134,3 +194,40 @@ func v1Upgrade(db *idb.Database) error {
 
 	return nil
 }
+
+// v2Upgrade performs the v1 -> v2 database upgrade, adding the object store
+// that bad values are moved to by wasmModel.CheckDatabase.
+//
+// This can never be changed without permanently breaking backwards
+// compatibility.
+func v2Upgrade(db *idb.Database) error {
+	return impl.CreateQuarantineStore(db)
+}
+
+// v3Upgrade performs the v2 -> v3 database upgrade, adding the object store
+// for the draft of each conversation.
+//
+// This can never be changed without permanently breaking backwards
+// compatibility.
+func v3Upgrade(db *idb.Database) error {
+	_, err := db.CreateObjectStore(draftStoreName, idb.ObjectStoreOptions{
+		KeyPath:       js.ValueOf(convoPkeyName),
+		AutoIncrement: false,
+	})
+	return err
+}
+
+// v5Upgrade performs the v4 -> v5 database upgrade, adding the index that
+// sorts the messages of each conversation by their timestamp.
+//
+// This can never be changed without permanently breaking backwards
+// compatibility.
+func v5Upgrade(db *idb.Database) error {
+	return impl.CreateIndex(db, messageStoreName,
+		messageStoreConversationTimestampIndex,
+		js.ValueOf([]any{messageStoreConversation, messageStoreTimestampKey}),
+		idb.IndexOptions{
+			Unique:     false,
+			MultiEntry: false,
+		})
+}
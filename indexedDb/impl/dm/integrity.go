@@ -0,0 +1,144 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"syscall/js"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+)
+
+// CheckDatabase validates every conversation, message, and draft in the
+// database. Each value must unmarshal into its type, have its required and
+// indexed fields set, and decrypt with the cipher. Each message must belong to
+// a conversation in the database, have a unique message ID, and have the index
+// keys derived from its timestamp and sender, and each draft must belong to a
+// conversation.
+//
+// If repair is true, bad values are moved to the quarantine store. Because
+// conversations are checked first, the messages and draft of a bad
+// conversation are also moved.
+func (w *wasmModel) CheckDatabase(repair bool) (*impl.IntegrityReport, error) {
+	databaseName, err := w.db.Name()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get database name")
+	}
+	c := impl.NewIntegrityChecker(w.db, databaseName, repair)
+
+	// Check conversations and save the public keys of the valid ones
+	conversations := make(map[string]bool)
+	_, err = c.CheckStore(conversationStoreName, convoPkeyName,
+		func(value js.Value) (impl.IntegrityProblem, string) {
+			convo, err := valueToConversation(value)
+			if err != nil {
+				return impl.InvalidJSON, err.Error()
+			} else if len(convo.Pubkey) == 0 {
+				return impl.MissingField, "conversation has no public key"
+			} else if err = w.decryptConversationMetadata(convo); err != nil {
+				return impl.Undecryptable, err.Error()
+			}
+			conversations[base64.StdEncoding.EncodeToString(convo.Pubkey)] = true
+			return "", ""
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	messageIDs := make(map[string]bool)
+	_, err = c.CheckStore(messageStoreName, msgPkeyName,
+		func(value js.Value) (impl.IntegrityProblem, string) {
+			msg, err := valueToMessage(value)
+			if err != nil {
+				return impl.InvalidJSON, err.Error()
+			}
+
+			// The sender index of a message with encrypted metadata is the
+			// blind index of the sender public key
+			senderIndex, blind := msg.SenderPubKey, msg.Metadata != nil
+			if err = w.decryptMessageMetadata(msg); err != nil {
+				return impl.Undecryptable, err.Error()
+			} else if w.cipher != nil && msg.Text != "" {
+				if _, err = w.cipher.Decrypt(msg.Text); err != nil {
+					return impl.Undecryptable, errors.WithMessage(
+						err, "failed to decrypt message text").Error()
+				}
+			}
+
+			messageID := base64.StdEncoding.EncodeToString(msg.MessageID)
+			partnerKey :=
+				base64.StdEncoding.EncodeToString(msg.ConversationPubKey)
+			switch {
+			case len(msg.MessageID) == 0:
+				return impl.MissingField, "message has no message ID"
+			case len(msg.ConversationPubKey) == 0:
+				return impl.MissingField, "message has no conversation"
+			case len(msg.SenderPubKey) == 0:
+				return impl.MissingField, "message has no sender"
+			case msg.TimestampKey != timestampKey(msg.Timestamp):
+				return impl.IndexMismatch,
+					"timestamp key does not match the timestamp"
+			case !conversations[partnerKey]:
+				return impl.MissingReference,
+					"conversation " + partnerKey + " does not exist"
+			case messageIDs[messageID]:
+				return impl.Duplicate,
+					"message ID " + messageID + " is already stored"
+			}
+
+			if blind {
+				expected, err := impl.BlindIndex(w.cipher, msg.SenderPubKey)
+				if err != nil {
+					return impl.Undecryptable, err.Error()
+				} else if !bytes.Equal(senderIndex, expected) {
+					return impl.IndexMismatch, "sender index does not match " +
+						"the public key of the sender"
+				}
+			}
+			messageIDs[messageID] = true
+			return "", ""
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.CheckStore(draftStoreName, convoPkeyName,
+		func(value js.Value) (impl.IntegrityProblem, string) {
+			draft, err := valueToDraft(value)
+			if err != nil {
+				return impl.InvalidJSON, err.Error()
+			}
+
+			partnerKey := base64.StdEncoding.EncodeToString(draft.Pubkey)
+			switch {
+			case len(draft.Pubkey) == 0:
+				return impl.MissingField, "draft has no conversation"
+			case !conversations[partnerKey]:
+				return impl.MissingReference,
+					"conversation " + partnerKey + " does not exist"
+			}
+
+			if w.cipher != nil && draft.Text != "" {
+				if _, err = w.cipher.Decrypt(draft.Text); err != nil {
+					return impl.Undecryptable, errors.WithMessage(
+						err, "failed to decrypt draft text").Error()
+				}
+			}
+			return "", ""
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Report(), nil
+}
@@ -13,11 +13,13 @@ import (
 	"fmt"
 	"os"
 	"syscall/js"
+	"time"
 
 	"github.com/spf13/cobra"
 	jww "github.com/spf13/jwalterweatherman"
 
 	"gitlab.com/elixxir/wasm-utils/exception"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
 	"gitlab.com/elixxir/xxdk-wasm/logging"
 	"gitlab.com/elixxir/xxdk-wasm/worker"
 )
@@ -52,6 +54,12 @@ var dmCmd = &cobra.Command{
 
 		jww.INFO.Printf("xxDK DM web worker version: v%s", SEMVER)
 
+		// Configure the timeout and retries of storage operations
+		impl.SetTimeout(dbTimeout)
+		retryPolicy := impl.DefaultRetryPolicy()
+		retryPolicy.MaxAttempts = dbMaxAttempts
+		impl.SetRetryPolicy(retryPolicy)
+
 		jww.INFO.Print("[WW] Starting xxDK WebAssembly DM Database Worker.")
 		tm, err := worker.NewThreadManager("DmIndexedDbWorker", true)
 		if err != nil {
@@ -89,6 +97,8 @@ var dmCmd = &cobra.Command{
 var (
 	logLevel       jww.Threshold
 	threadLogLevel jww.Threshold
+	dbTimeout      time.Duration
+	dbMaxAttempts  int
 )
 
 func init() {
@@ -103,4 +113,10 @@ func init() {
 		"The log level when outputting to the worker file buffer. "+
 			"0 = TRACE, 1 = DEBUG, 2 = INFO, 3 = WARN, 4 = ERROR, "+
 			"5 = CRITICAL, 6 = FATAL, -1 = disabled.")
+	dmCmd.Flags().DurationVar(&dbTimeout, "dbTimeout", impl.DefaultTimeout,
+		"The timeout for each IndexedDb operation.")
+	dmCmd.Flags().IntVar(&dbMaxAttempts, "dbMaxAttempts",
+		impl.DefaultRetryPolicy().MaxAttempts,
+		"The maximum number of times an IndexedDb operation is attempted "+
+			"when it fails with a transient error. 1 = no retries.")
 }
@@ -0,0 +1,207 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"syscall/js"
+	"time"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/client/v4/dm"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+)
+
+// MessagePage is one page of the message history of a conversation returned
+// by GetMessages.
+//
+// Example JSON:
+//
+//	{
+//	  "messages": [
+//	    {
+//	      "uuid": 42,
+//	      "messageID": "Tg6VB8UVL+jn3GlWIvCXASsNHinxbqXTr/FF6z6q9lU=",
+//	      "senderPubKey": "z8VYHxDJG6b+bJQ3u1Ok0RGprg+z0a9U2aU6T8ZqZPA=",
+//	      "timestamp": "2023-07-21T11:42:09.512-07:00",
+//	      "status": 2,
+//	      "type": 1,
+//	      "text": "Hello, World!",
+//	      "codesetVersion": 0,
+//	      "round": 1234,
+//	      "edited": false
+//	    }
+//	  ],
+//	  "nextCursor": "eyJ0aW1lc3RhbXAiOiIyMDIzLTA3LTIxVDExOjQyOjA5LjUxMi0wNzowMCIsInV1aWQiOjQyfQ=="
+//	}
+type MessagePage struct {
+	// Messages are sorted newest first.
+	Messages []PageMessage `json:"messages"`
+
+	// NextCursor is passed to GetMessages to get the next, older page. It is
+	// empty if there are no older messages.
+	NextCursor []byte `json:"nextCursor,omitempty"`
+}
+
+// PageMessage is a decrypted message in a MessagePage.
+type PageMessage struct {
+	UUID            uint64         `json:"uuid"`
+	MessageID       []byte         `json:"messageID"`
+	ParentMessageID []byte         `json:"parentMessageID,omitempty"`
+	SenderPubKey    []byte         `json:"senderPubKey"`
+	Timestamp       time.Time      `json:"timestamp"`
+	Status          dm.Status      `json:"status"`
+	Type            dm.MessageType `json:"type"`
+	Text            string         `json:"text"`
+	CodesetVersion  uint8          `json:"codesetVersion"`
+	Round           uint64         `json:"round"`
+	Edited          bool           `json:"edited"`
+}
+
+// messageCursor is the position of the last message of a MessagePage. It is
+// JSON marshalled into MessagePage.NextCursor.
+type messageCursor struct {
+	Timestamp time.Time `json:"timestamp"`
+	UUID      uint64    `json:"uuid"`
+}
+
+// before returns true if the message is sorted after the cursor (i.e., it is
+// older or has the same timestamp and a lower UUID).
+func (c *messageCursor) before(msg *Message) bool {
+	if msg.Timestamp.Equal(c.Timestamp) {
+		return msg.ID < c.UUID
+	}
+	return msg.Timestamp.Before(c.Timestamp)
+}
+
+// GetMessages returns a page of at most limit messages of the conversation
+// with the partner, newest first, with their text and sender decrypted. The
+// first page is returned if the cursor is empty; otherwise, the page starts
+// after the message the cursor was returned for. A limit of zero or less
+// returns every remaining message.
+func (w *wasmModel) GetMessages(partnerPubKey ed25519.PublicKey, cursor []byte,
+	limit int) (*MessagePage, error) {
+	parentErr := errors.New("[DM indexedDB] failed to GetMessages")
+
+	var c *messageCursor
+	if len(cursor) > 0 {
+		c = &messageCursor{}
+		if err := json.Unmarshal(cursor, c); err != nil {
+			return nil, errors.WithMessagef(parentErr,
+				"invalid cursor: %+v", err)
+		}
+	}
+
+	// Messages are stored in the order they are received, which is not always
+	// the order they were sent in, so they are read newest first from the
+	// timestamp index. The page starts at the timestamp of the cursor, where
+	// messages with the same timestamp are sorted by UUID.
+	conversation := impl.EncodeBytes(partnerPubKey)
+	lower := js.ValueOf([]any{conversation})
+	upper := js.ValueOf([]any{conversation, []any{}})
+	if c != nil {
+		upper = js.ValueOf([]any{conversation, timestampKey(c.Timestamp)})
+	}
+
+	var msgs []*Message
+	err := impl.NewQuery(messageStoreName).
+		Index(messageStoreConversationTimestampIndex).
+		Bound(lower, upper, false, false).
+		Direction(idb.CursorPrevious).
+		Iter(w.db, func(value js.Value) error {
+			msg, err := valueToMessage(value)
+			if err != nil {
+				return errors.Errorf("Unable to unmarshal Message: %+v", err)
+			} else if c != nil && !c.before(msg) {
+				return nil
+			}
+
+			// One message past the limit is read to know if there is a next
+			// page
+			msgs = append(msgs, msg)
+			if limit > 0 && len(msgs) > limit {
+				return idb.ErrCursorStopIter
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, errors.WithMessage(parentErr, err.Error())
+	}
+
+	page := &MessagePage{Messages: make([]PageMessage, 0, len(msgs))}
+	if limit > 0 && len(msgs) > limit {
+		last := msgs[limit-1]
+		page.NextCursor, err = json.Marshal(
+			messageCursor{Timestamp: last.Timestamp, UUID: last.ID})
+		if err != nil {
+			return nil, errors.WithMessagef(parentErr,
+				"Unable to marshal cursor: %+v", err)
+		}
+		msgs = msgs[:limit]
+	}
+
+	// Only the messages in the page are decrypted
+	for _, msg := range msgs {
+		if err = w.decryptMessageMetadata(msg); err != nil {
+			return nil, errors.WithMessage(parentErr, err.Error())
+		}
+		if w.cipher != nil && msg.Text != "" {
+			text, err := w.cipher.Decrypt(msg.Text)
+			if err != nil {
+				return nil, errors.WithMessagef(parentErr,
+					"Failed to decrypt message text: %+v", err)
+			}
+			msg.Text = string(text)
+		}
+
+		page.Messages = append(page.Messages, PageMessage{
+			UUID:            msg.ID,
+			MessageID:       msg.MessageID,
+			ParentMessageID: msg.ParentMessageID,
+			SenderPubKey:    msg.SenderPubKey,
+			Timestamp:       msg.Timestamp,
+			Status:          dm.Status(msg.Status),
+			Type:            dm.MessageType(msg.Type),
+			Text:            msg.Text,
+			CodesetVersion:  msg.CodesetVersion,
+			Round:           msg.Round,
+			Edited:          len(msg.EditHistory) > 0,
+		})
+	}
+
+	return page, nil
+}
+
+// migrateMessages fills in the Message.TimestampKey of messages stored before
+// it was recorded so that they are in the conversation timestamp index.
+func (w *wasmModel) migrateMessages() error {
+	numMigrated, err := impl.UpdateAll(w.db, messageStoreName,
+		func(msgObj js.Value) (js.Value, bool, error) {
+			msg, err := valueToMessage(msgObj)
+			if err != nil || msg.TimestampKey != "" {
+				return js.Undefined(), false, err
+			}
+			msg.TimestampKey = timestampKey(msg.Timestamp)
+			return marshalToJS(msg)
+		})
+	if err != nil {
+		return errors.WithMessage(err, "failed to migrate messages")
+	}
+
+	if numMigrated > 0 {
+		jww.INFO.Printf("[DM indexedDB] Migrated timestamp key of %d messages",
+			numMigrated)
+	}
+	return nil
+}
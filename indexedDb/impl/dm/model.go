@@ -11,6 +11,8 @@ package main
 
 import (
 	"time"
+
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
 )
 
 const (
@@ -21,16 +23,27 @@ const (
 	// Text representation of the names of the various [idb.ObjectStore].
 	messageStoreName      = "messages"
 	conversationStoreName = "conversations"
+	draftStoreName        = "drafts"
 
 	// Message index names.
 	messageStoreMessageIndex      = "message_id_index"
 	messageStoreConversationIndex = "conversation_pub_key_index"
 	messageStoreSenderIndex       = "sender_pub_key_index"
 
+	// messageStoreConversationTimestampIndex sorts the messages of each
+	// conversation by the time they were sent.
+	messageStoreConversationTimestampIndex = "conversation_timestamp_index"
+
 	// Message keyPath names (must match json struct tags).
 	messageStoreMessage      = "message_id"
 	messageStoreConversation = "conversation_pub_key"
 	messageStoreSender       = "sender_pub_key"
+	messageStoreTimestampKey = "timestamp_key"
+
+	// timestampKeyLayout is the layout of Message.TimestampKey. The time is
+	// always in UTC with a fixed number of digits so that keys sort in
+	// chronological order.
+	timestampKeyLayout = "2006-01-02T15:04:05.000000000Z"
 )
 
 // Message defines the IndexedDb representation of a single Message.
@@ -49,6 +62,32 @@ type Message struct {
 	Text               string    `json:"text"`
 	Type               uint16    `json:"type"`
 	Round              uint64    `json:"round"`
+
+	// TimestampKey is Timestamp formatted so that it sorts chronologically in
+	// messageStoreConversationTimestampIndex. It is set by
+	// wasmModel.newMessageObject.
+	TimestampKey string `json:"timestamp_key"`
+
+	// Metadata is the encrypted messageMetadata when extended encryption is
+	// enabled. SenderPubKey then contains the keyed blind index of the sender
+	// public key so that the index can still be queried.
+	Metadata []byte `json:"metadata,omitempty"`
+
+	// EditHistory contains every version of the text, oldest first, if the
+	// message was edited. Text is always the latest version.
+	EditHistory []impl.EditRecord `json:"edit_history,omitempty"`
+}
+
+// timestampKey returns the Message.TimestampKey of the timestamp.
+func timestampKey(timestamp time.Time) string {
+	return timestamp.UTC().Format(timestampKeyLayout)
+}
+
+// messageMetadata contains the fields of a Message that identify the sender.
+// It is JSON marshalled and encrypted into Message.Metadata when extended
+// encryption is enabled.
+type messageMetadata struct {
+	SenderPubKey []byte `json:"sender_pub_key"`
 }
 
 // Conversation defines the IndexedDb representation of a single
@@ -60,4 +99,66 @@ type Conversation struct {
 	Token            uint32     `json:"token"`
 	CodesetVersion   uint8      `json:"codeset_version"`
 	BlockedTimestamp *time.Time `json:"blocked_timestamp"`
+
+	// LastMessageTimestamp is the time the newest text message or reply in
+	// the conversation was sent. It is zero if there are none.
+	LastMessageTimestamp time.Time `json:"last_message_timestamp"`
+
+	// LastMessagePreview is the start of the text of the newest message. It is
+	// encrypted with the database cipher, if there is one.
+	LastMessagePreview string `json:"last_message_preview"`
+
+	// LastSenderPubKey is the public key of the sender of the newest message.
+	LastSenderPubKey []byte `json:"last_sender_pub_key"`
+
+	// UnreadCount is the number of messages received from the partner since
+	// the conversation was last marked as read.
+	UnreadCount uint32 `json:"unread_count"`
+
+	// ArchivedTimestamp is the time the conversation was archived or nil if
+	// it is not archived.
+	ArchivedTimestamp *time.Time `json:"archived_timestamp,omitempty"`
+
+	// PinnedTimestamp is the time the conversation was pinned to the top of
+	// the conversation list or nil if it is not pinned.
+	PinnedTimestamp *time.Time `json:"pinned_timestamp,omitempty"`
+
+	// Muted is true if notifications of the conversation are silenced locally
+	// without blocking the partner.
+	Muted bool `json:"muted,omitempty"`
+
+	// Metadata is the encrypted conversationMetadata when extended encryption
+	// is enabled. Nickname, Token, and LastSenderPubKey are left empty.
+	//
+	// Pubkey is not encrypted because it is the key of the conversation and
+	// of its messages and draft.
+	Metadata []byte `json:"metadata,omitempty"`
+}
+
+// conversationMetadata contains the fields of a Conversation that are
+// encrypted into Conversation.Metadata when extended encryption is enabled.
+//
+// Token is nil in metadata encrypted before the token was moved into it; the
+// token stored in the Conversation is then kept.
+type conversationMetadata struct {
+	Nickname         string  `json:"nickname"`
+	Token            *uint32 `json:"token,omitempty"`
+	LastSenderPubKey []byte  `json:"last_sender_pub_key,omitempty"`
+}
+
+// Draft defines the IndexedDb representation of the unsent text of a
+// Conversation.
+//
+// A Conversation has at most one Draft.
+type Draft struct {
+	Pubkey []byte `json:"pub_key"` // Matches convoPkeyName
+
+	// Text is encrypted with the database cipher, if there is one.
+	Text string `json:"text"`
+
+	// ReplyTo is the message ID of the message being replied to, if any.
+	ReplyTo []byte `json:"reply_to,omitempty"`
+
+	// Timestamp is the last time the draft was saved.
+	Timestamp time.Time `json:"timestamp"`
 }
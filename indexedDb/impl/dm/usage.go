@@ -0,0 +1,49 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+)
+
+// GetStorageUsage returns the number of rows and approximate size of every
+// object store in the database. Messages are also grouped by the base64
+// encoded public key of their conversation.
+func (w *wasmModel) GetStorageUsage() (*impl.DatabaseUsage, error) {
+	databaseName, err := w.db.Name()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get database name")
+	}
+	du := impl.NewDatabaseUsage(databaseName)
+
+	for _, storeName := range []string{conversationStoreName, draftStoreName,
+		impl.QuarantineStoreName} {
+		if err = du.MeasureStore(w.db, storeName, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	err = du.MeasureStore(w.db, messageStoreName, func(value js.Value) string {
+		convoPubKey := value.Get("conversation_pub_key")
+		if convoPubKey.Type() != js.TypeString {
+			return ""
+		}
+		return convoPubKey.String()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return du, nil
+}
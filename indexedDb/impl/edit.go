@@ -0,0 +1,125 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
+	"gitlab.com/elixxir/crypto/message"
+)
+
+// EditMessageType is the [channels.MessageType] and [dm.MessageType] of a
+// message that edits the text of an earlier message. Its payload is a JSON
+// marshalled MessageEdit.
+const EditMessageType = 41000
+
+// MessageEdit is the payload of an edit message. It replaces the text of the
+// message with the ID. An edit is only applied if both the author key and the
+// key of the sender of the edit match the sender of the original message.
+//
+// Example JSON:
+//
+//	{
+//	  "messageID": "Tg6VB8UVL+jn3GlWIvCXASsNHinxbqXTr/FF6z6q9lU=",
+//	  "authorKey": "z8VYHxDJG6b+bJQ3u1Ok0RGprg+z0a9U2aU6T8ZqZPA=",
+//	  "text": "Hello, World! (edited)"
+//	}
+type MessageEdit struct {
+	MessageID message.ID        `json:"messageID"`
+	AuthorKey ed25519.PublicKey `json:"authorKey"`
+	Text      string            `json:"text"`
+}
+
+// UnmarshalMessageEdit unmarshalls the payload of an edit message.
+func UnmarshalMessageEdit(data []byte) (*MessageEdit, error) {
+	var edit MessageEdit
+	if err := json.Unmarshal(data, &edit); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal message edit")
+	}
+	if len(edit.AuthorKey) != ed25519.PublicKeySize {
+		return nil, errors.Errorf("invalid author key length %d",
+			len(edit.AuthorKey))
+	}
+	return &edit, nil
+}
+
+// CheckEditAuthor returns an error unless the sender of the edit, the author
+// key in the edit, and the sender of the original message are the same key.
+func (me *MessageEdit) CheckEditAuthor(
+	editSender, originalSender ed25519.PublicKey) error {
+	if !bytes.Equal(me.AuthorKey, editSender) {
+		return errors.New("author key of edit does not match its sender")
+	} else if !bytes.Equal(me.AuthorKey, originalSender) {
+		return errors.New("only the sender of a message may edit it")
+	}
+	return nil
+}
+
+// EditRecord is one version of the text of an edited message.
+type EditRecord struct {
+	// MessageID is the ID of the message that set this version of the text;
+	// either the original message or an edit message.
+	MessageID []byte `json:"message_id"`
+
+	// Text is the text as stored; it is encrypted if the database is.
+	Text      string    `json:"text"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// AddEdit adds the edit to the history of a message. The first edit of a
+// message adds the original version to the start of the history. Edits are
+// ordered by timestamp so that the last record is always the latest text,
+// regardless of the order in which edits are received.
+//
+// Returns the new history and false if the edit is already in it.
+func AddEdit(history []EditRecord, original, edit EditRecord) (
+	[]EditRecord, bool) {
+	if len(history) == 0 {
+		history = []EditRecord{original}
+	}
+	for _, record := range history {
+		if bytes.Equal(record.MessageID, edit.MessageID) {
+			return history, false
+		}
+	}
+
+	history = append(history, edit)
+
+	// The original is always first, even if an edit has an earlier timestamp
+	// because of clock skew
+	edits := history[1:]
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].Timestamp.Before(edits[j].Timestamp)
+	})
+	return history, true
+}
+
+// ReEncryptEdits re-encrypts the text of each record in place with the current
+// key if the cipher is a KeyRing and the text was encrypted with an older key.
+// Returns true if any record changed.
+func ReEncryptEdits(cipher idbCrypto.Cipher, history []EditRecord) (
+	bool, error) {
+	changed := false
+	for i := range history {
+		text, textChanged, err := ReEncrypt(cipher, history[i].Text)
+		if err != nil {
+			return false, errors.WithMessage(err, "failed to re-encrypt edit")
+		}
+		history[i].Text = text
+		changed = changed || textChanged
+	}
+	return changed, nil
+}
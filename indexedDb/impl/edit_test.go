@@ -0,0 +1,87 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gitlab.com/elixxir/crypto/message"
+)
+
+// Tests that AddEdit keeps the original first, orders edits by timestamp
+// regardless of the order they are added in, and ignores duplicate edits.
+func TestAddEdit(t *testing.T) {
+	now := time.Now()
+	original := EditRecord{[]byte("original"), "v0", now}
+	first := EditRecord{[]byte("first"), "v1", now.Add(time.Minute)}
+	second := EditRecord{[]byte("second"), "v2", now.Add(2 * time.Minute)}
+
+	history, added := AddEdit(nil, original, second)
+	if !added {
+		t.Fatal("First edit not added.")
+	}
+	history, added = AddEdit(history, original, first)
+	if !added {
+		t.Fatal("Second edit not added.")
+	}
+	if history, added = AddEdit(history, original, first); added {
+		t.Error("Duplicate edit added.")
+	}
+
+	expected := []EditRecord{original, first, second}
+	if len(history) != len(expected) {
+		t.Fatalf("Unexpected history length.\nexpected: %d\nreceived: %d",
+			len(expected), len(history))
+	}
+	for i := range expected {
+		if history[i].Text != expected[i].Text {
+			t.Errorf("Unexpected record %d.\nexpected: %+v\nreceived: %+v",
+				i, expected[i], history[i])
+		}
+	}
+}
+
+// Tests that a MessageEdit survives marshalling and that
+// MessageEdit.CheckEditAuthor only accepts edits where the author key, the
+// edit sender, and the original sender are all the same.
+func TestMessageEdit_CheckEditAuthor(t *testing.T) {
+	author := ed25519.PublicKey(bytes.Repeat([]byte{1}, ed25519.PublicKeySize))
+	other := ed25519.PublicKey(bytes.Repeat([]byte{2}, ed25519.PublicKeySize))
+
+	data, err := json.Marshal(MessageEdit{
+		MessageID: message.ID{5}, AuthorKey: author, Text: "edited"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit, err := UnmarshalMessageEdit(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal edit: %+v", err)
+	} else if edit.MessageID != (message.ID{5}) || edit.Text != "edited" {
+		t.Errorf("Unexpected edit: %+v", edit)
+	}
+
+	if err = edit.CheckEditAuthor(author, author); err != nil {
+		t.Errorf("Edit by the original sender rejected: %+v", err)
+	}
+	if err = edit.CheckEditAuthor(other, author); err == nil {
+		t.Error("Edit sent by another user accepted.")
+	}
+	if err = edit.CheckEditAuthor(author, other); err == nil {
+		t.Error("Edit of another user's message accepted.")
+	}
+
+	if _, err = UnmarshalMessageEdit([]byte(`{"authorKey":"AQID"}`)); err == nil {
+		t.Error("Edit with invalid author key accepted.")
+	}
+}
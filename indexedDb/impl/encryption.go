@@ -0,0 +1,207 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+// This file contains helper functions for encrypting data with an
+// [idbCrypto.Cipher] that may be larger than its block size.
+
+package impl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
+)
+
+// blindIndexKeyInfo is used to derive the key used for blind indexes from the
+// cipher secret so that it is distinct from the encryption key.
+const blindIndexKeyInfo = "xxdkWasmBlindIndexKey"
+
+// cipherDisk is the JSON structure of an [idbCrypto.Cipher] as returned by
+// [idbCrypto.Cipher.MarshalJSON].
+type cipherDisk struct {
+	Secret    []byte `json:"secret"`
+	BlockSize int    `json:"blockSize"`
+}
+
+// unmarshalCipher returns the secret and block size of the cipher.
+//
+// If the cipher is a [KeyRing], the values of the current key are returned.
+func unmarshalCipher(cipher idbCrypto.Cipher) (cipherDisk, error) {
+	c, err := currentCipher(cipher)
+	if err != nil {
+		return cipherDisk{}, err
+	}
+	data, err := c.MarshalJSON()
+	if err != nil {
+		return cipherDisk{}, errors.Wrap(err, "failed to marshal cipher")
+	}
+
+	var disk cipherDisk
+	if err = json.Unmarshal(data, &disk); err != nil {
+		return cipherDisk{}, errors.Wrap(err, "failed to unmarshal cipher")
+	}
+
+	return disk, nil
+}
+
+// CipherBlockSize returns the maximum size of a plaintext that can be
+// encrypted by the cipher in a single call to [idbCrypto.Cipher.Encrypt].
+//
+// A [KeyRing] caches the block size of its current key. The block size of any
+// other cipher is read from its JSON on every call.
+func CipherBlockSize(cipher idbCrypto.Cipher) (int, error) {
+	if kr, ok := cipher.(*KeyRing); ok {
+		return kr.BlockSize()
+	}
+	return readBlockSize(cipher)
+}
+
+// readBlockSize reads the block size of the cipher from its JSON.
+func readBlockSize(cipher idbCrypto.Cipher) (int, error) {
+	disk, err := unmarshalCipher(cipher)
+	if err != nil {
+		return 0, err
+	} else if disk.BlockSize <= chunkHeaderLen {
+		return 0, errors.Errorf("invalid cipher block size %d", disk.BlockSize)
+	}
+
+	return disk.BlockSize, nil
+}
+
+// BlindIndex returns a keyed blind index of the data. It is an HMAC-SHA256 of
+// the data keyed with a key derived from the cipher secret, so equal values
+// produce equal indexes and can be queried without storing the value in the
+// clear.
+//
+// If the cipher is a [KeyRing], the key is derived from its index key (see
+// [KeyRing.IndexCipher]) rather than the current key, so indexes stored before
+// a key rotation still match.
+func BlindIndex(cipher idbCrypto.Cipher, data []byte) ([]byte, error) {
+	if kr, ok := cipher.(*KeyRing); ok {
+		var err error
+		if cipher, err = kr.IndexCipher(); err != nil {
+			return nil, err
+		}
+	}
+
+	disk, err := unmarshalCipher(cipher)
+	if err != nil {
+		return nil, err
+	} else if len(disk.Secret) == 0 {
+		return nil, errors.New("cipher has no secret")
+	}
+
+	keyMac := hmac.New(sha256.New, disk.Secret)
+	keyMac.Write([]byte(blindIndexKeyInfo))
+
+	mac := hmac.New(sha256.New, keyMac.Sum(nil))
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// chunkHeaderLen is the length of the header at the start of the plaintext of
+// each chunk encrypted by EncryptChunked. It contains the index of the chunk
+// and the total number of chunks, each as a big-endian uint32, so that chunks
+// cannot be reordered, dropped, or truncated without failing to decrypt.
+const chunkHeaderLen = 8
+
+// chunkedCiphertext is the JSON structure of the data returned by
+// EncryptChunked.
+type chunkedCiphertext struct {
+	Chunks []string `json:"chunks"`
+}
+
+// EncryptChunked encrypts the plaintext with the cipher. The plaintext is split
+// into chunks that fit in the block size of the cipher with their header, and
+// each chunk is encrypted separately. Returns the JSON of the ciphertexts.
+//
+// A nil plaintext returns nil so that unset values remain unset.
+func EncryptChunked(cipher idbCrypto.Cipher, plaintext []byte) ([]byte, error) {
+	if plaintext == nil {
+		return nil, nil
+	}
+
+	blockSize, err := CipherBlockSize(cipher)
+	if err != nil {
+		return nil, err
+	}
+	dataSize := blockSize - chunkHeaderLen
+
+	total := (len(plaintext) + dataSize - 1) / dataSize
+	if total == 0 {
+		total = 1
+	}
+	chunks := make([]string, total)
+	chunk := make([]byte, 0, blockSize)
+	for i := range chunks {
+		start, end := i*dataSize, (i+1)*dataSize
+		if end > len(plaintext) {
+			end = len(plaintext)
+		}
+
+		chunk = binary.BigEndian.AppendUint32(chunk[:0], uint32(i))
+		chunk = binary.BigEndian.AppendUint32(chunk, uint32(total))
+		chunk = append(chunk, plaintext[start:end]...)
+		if chunks[i], err = cipher.Encrypt(chunk); err != nil {
+			return nil, errors.Wrapf(err, "failed to encrypt chunk %d", i)
+		}
+	}
+
+	return json.Marshal(chunkedCiphertext{Chunks: chunks})
+}
+
+// DecryptChunked decrypts the JSON of the ciphertexts returned by
+// EncryptChunked and returns the joined plaintext. Returns an error if the
+// header of any chunk does not match its position.
+//
+// A nil ciphertext returns nil.
+func DecryptChunked(cipher idbCrypto.Cipher, ciphertext []byte) ([]byte, error) {
+	if ciphertext == nil {
+		return nil, nil
+	}
+
+	chunks, err := unmarshalChunks(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, 0)
+	for i, chunk := range chunks {
+		decrypted, err := cipher.Decrypt(chunk)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decrypt chunk %d", i)
+		}
+
+		if len(decrypted) < chunkHeaderLen ||
+			binary.BigEndian.Uint32(decrypted) != uint32(i) ||
+			binary.BigEndian.Uint32(decrypted[4:]) != uint32(len(chunks)) {
+			return nil, errors.Errorf("chunk %d of %d has an invalid "+
+				"header; the chunks were modified", i, len(chunks))
+		}
+		plaintext = append(plaintext, decrypted[chunkHeaderLen:]...)
+	}
+
+	return plaintext, nil
+}
+
+// unmarshalChunks returns the ciphertexts of each chunk.
+func unmarshalChunks(ciphertext []byte) ([]string, error) {
+	var cc chunkedCiphertext
+	if err := json.Unmarshal(ciphertext, &cc); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal encrypted chunks")
+	} else if len(cc.Chunks) == 0 {
+		return nil, errors.New("encrypted data has no chunks")
+	}
+	return cc.Chunks, nil
+}
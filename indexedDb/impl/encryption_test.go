@@ -0,0 +1,184 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
+	"gitlab.com/xx_network/crypto/csprng"
+)
+
+// Tests that data of various sizes encrypted with EncryptChunked is split into
+// the expected number of chunks and decrypted by DecryptChunked.
+func TestEncryptChunked_DecryptChunked(t *testing.T) {
+	const blockSize = 32
+	const dataSize = blockSize - chunkHeaderLen
+	cipher, err := idbCrypto.NewCipher(
+		[]byte("testPassword"), []byte("testSalt"), blockSize,
+		csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+
+	tests := map[int]int{
+		0:                1,
+		1:                1,
+		dataSize:         1,
+		dataSize + 1:     2,
+		10 * dataSize:    10,
+		10*dataSize + 17: 11,
+	}
+
+	for size, expectedChunks := range tests {
+		plaintext := make([]byte, size)
+		_, _ = csprng.NewSystemRNG().Read(plaintext)
+
+		ciphertext, err := EncryptChunked(cipher, plaintext)
+		if err != nil {
+			t.Fatalf("Failed to encrypt %d bytes: %+v", size, err)
+		}
+
+		var cc chunkedCiphertext
+		if err = json.Unmarshal(ciphertext, &cc); err != nil {
+			t.Fatalf("Failed to unmarshal chunks: %+v", err)
+		}
+		if len(cc.Chunks) != expectedChunks {
+			t.Errorf("Unexpected number of chunks for %d bytes."+
+				"\nexpected: %d\nreceived: %d",
+				size, expectedChunks, len(cc.Chunks))
+		}
+
+		decrypted, err := DecryptChunked(cipher, ciphertext)
+		if err != nil {
+			t.Fatalf("Failed to decrypt %d bytes: %+v", size, err)
+		}
+		if !bytes.Equal(plaintext, decrypted) {
+			t.Errorf("Decrypted data does not match original for %d bytes."+
+				"\nexpected: %v\nreceived: %v", size, plaintext, decrypted)
+		}
+	}
+}
+
+// Error path: Tests that DecryptChunked returns an error when the chunks
+// returned by EncryptChunked are reordered or truncated.
+func TestDecryptChunked_ModifiedChunksError(t *testing.T) {
+	cipher, err := idbCrypto.NewCipher(
+		[]byte("testPassword"), []byte("testSalt"), 32, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+
+	ciphertext, err := EncryptChunked(cipher, bytes.Repeat([]byte("a"), 100))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %+v", err)
+	}
+	var cc chunkedCiphertext
+	if err = json.Unmarshal(ciphertext, &cc); err != nil {
+		t.Fatalf("Failed to unmarshal chunks: %+v", err)
+	}
+
+	reordered := append([]string{}, cc.Chunks...)
+	reordered[0], reordered[1] = reordered[1], reordered[0]
+	for name, chunks := range map[string][]string{
+		"reordered": reordered,
+		"truncated": cc.Chunks[:len(cc.Chunks)-1],
+	} {
+		modified, _ := json.Marshal(chunkedCiphertext{Chunks: chunks})
+		if _, err = DecryptChunked(cipher, modified); err == nil {
+			t.Errorf("Did not get error for %s chunks.", name)
+		}
+	}
+}
+
+// Error path: Tests that DecryptChunked rejects a list of chunks without
+// headers, the format used before chunks were authenticated.
+func TestDecryptChunked_HeaderlessList(t *testing.T) {
+	cipher, err := idbCrypto.NewCipher(
+		[]byte("testPassword"), []byte("testSalt"), 32, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+
+	chunk, err := cipher.Encrypt([]byte("Hello, World!"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt chunk: %+v", err)
+	}
+	list, _ := json.Marshal([]string{chunk})
+
+	if _, err = DecryptChunked(cipher, list); err == nil {
+		t.Errorf("Did not get error for list of chunks without headers.")
+	}
+}
+
+// Tests that EncryptChunked and DecryptChunked return nil for nil input.
+func TestEncryptChunked_Nil(t *testing.T) {
+	cipher, err := idbCrypto.NewCipher(
+		[]byte("testPassword"), []byte("testSalt"), 32, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+
+	if ciphertext, err := EncryptChunked(cipher, nil); err != nil ||
+		ciphertext != nil {
+		t.Errorf("Unexpected result encrypting nil: %v, %+v", ciphertext, err)
+	}
+	if plaintext, err := DecryptChunked(cipher, nil); err != nil ||
+		plaintext != nil {
+		t.Errorf("Unexpected result decrypting nil: %v, %+v", plaintext, err)
+	}
+}
+
+// Tests that BlindIndex returns the same index for the same data and cipher and
+// different indexes for different data or ciphers.
+func TestBlindIndex(t *testing.T) {
+	cipher, err := idbCrypto.NewCipher(
+		[]byte("testPassword"), []byte("testSalt"), 32, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+	otherCipher, err := idbCrypto.NewCipher(
+		[]byte("otherPassword"), []byte("testSalt"), 32, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+
+	data := []byte("senderPubKey")
+	index, err := BlindIndex(cipher, data)
+	if err != nil {
+		t.Fatalf("Failed to get blind index: %+v", err)
+	}
+
+	index2, err := BlindIndex(cipher, data)
+	if err != nil {
+		t.Fatalf("Failed to get blind index: %+v", err)
+	}
+	if !bytes.Equal(index, index2) {
+		t.Errorf("Blind indexes of the same data do not match."+
+			"\nexpected: %v\nreceived: %v", index, index2)
+	}
+
+	otherData, err := BlindIndex(cipher, []byte("otherPubKey"))
+	if err != nil {
+		t.Fatalf("Failed to get blind index: %+v", err)
+	}
+	otherKey, err := BlindIndex(otherCipher, data)
+	if err != nil {
+		t.Fatalf("Failed to get blind index: %+v", err)
+	}
+	if bytes.Equal(index, otherData) || bytes.Equal(index, otherKey) {
+		t.Errorf("Blind index not unique to data and cipher.")
+	}
+	if bytes.Contains(index, data) {
+		t.Errorf("Blind index contains the data: %v", index)
+	}
+}
@@ -0,0 +1,164 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+// Event types sent on the EventUpdate callback of the channels and DM event
+// models in addition to those defined in the client bindings.
+const (
+	// KeyRotationProgress indicates the data is [KeyRotationProgressJSON].
+	KeyRotationProgress int64 = 100000
+
+	// QuotaExceeded indicates the data is [QuotaExceededJSON].
+	QuotaExceeded int64 = 100001
+
+	// DatabaseClosed indicates the data is [DatabaseClosedJSON].
+	DatabaseClosed int64 = 100002
+
+	// StorageWarning indicates the data is [StorageWarningJSON].
+	StorageWarning int64 = 100003
+
+	// ConversationStateUpdate indicates the data is
+	// [ConversationStateUpdateJSON].
+	ConversationStateUpdate int64 = 100004
+)
+
+// KeyRotationProgressJSON is returned on the EventUpdate callback with the
+// event type KeyRotationProgress while a database is re-encrypted with a new
+// key.
+//
+// Example JSON:
+//
+//	{
+//	  "databaseName": "AW7fj+J8Ku/6o2gs+ky6R6KTpaiYIJYDZkmS+ZqeXYED_speakeasy",
+//	  "keyVersion": 2,
+//	  "store": "messages",
+//	  "done": 500,
+//	  "total": 1204,
+//	  "complete": false
+//	}
+type KeyRotationProgressJSON struct {
+	// DatabaseName is the name of the database being re-encrypted.
+	DatabaseName string `json:"databaseName"`
+
+	// KeyVersion is the version of the key the database is re-encrypted with.
+	KeyVersion uint32 `json:"keyVersion"`
+
+	// Store is the name of the object store currently being re-encrypted.
+	Store string `json:"store"`
+
+	// Done is the number of values in the store that have been visited.
+	Done int `json:"done"`
+
+	// Total is the number of values in the store.
+	Total int `json:"total"`
+
+	// Complete is true once every store in the database has been re-encrypted.
+	Complete bool `json:"complete"`
+
+	// Error is set if re-encryption failed. It is resumed the next time the
+	// database is opened.
+	Error string `json:"error,omitempty"`
+}
+
+// QuotaExceededJSON is returned on the EventUpdate callback with the event type
+// QuotaExceeded when a write to a database fails because the storage quota of
+// the origin is exceeded. The UI should prompt the user to free up space; the
+// failed write is not retried.
+//
+// Example JSON:
+//
+//	{
+//	  "databaseName": "AW7fj+J8Ku/6o2gs+ky6R6KTpaiYIJYDZkmS+ZqeXYED_speakeasy",
+//	  "error": "failed to Put messages: QuotaExceededError: JavaScript error: The quota has been exceeded."
+//	}
+type QuotaExceededJSON struct {
+	// DatabaseName is the name of the database the write failed on.
+	DatabaseName string `json:"databaseName"`
+
+	// Error is the error returned by the failed write.
+	Error string `json:"error"`
+}
+
+// DatabaseClosedJSON is returned on the EventUpdate callback with the event
+// type DatabaseClosed when the connection to a database is closed because
+// another tab or worker opened it with a newer version. Every later operation
+// on the database fails, so the UI should ask the user to reload the page.
+//
+// Example JSON:
+//
+//	{
+//	  "databaseName": "AW7fj+J8Ku/6o2gs+ky6R6KTpaiYIJYDZkmS+ZqeXYED_speakeasy",
+//	  "oldVersion": 1,
+//	  "newVersion": 2
+//	}
+type DatabaseClosedJSON struct {
+	// DatabaseName is the name of the closed database.
+	DatabaseName string `json:"databaseName"`
+
+	// OldVersion is the version of the database on this connection.
+	OldVersion uint `json:"oldVersion"`
+
+	// NewVersion is the version the database is being upgraded to. It is zero
+	// if the database is being deleted.
+	NewVersion uint `json:"newVersion"`
+}
+
+// StorageWarningJSON is returned on the EventUpdate callback with the event
+// type StorageWarning when the storage used by the origin reaches the warning
+// threshold of its quota. It is sent again only after usage first drops below
+// the threshold. The UI should warn the user before writes start failing with
+// a QuotaExceeded event.
+//
+// Example JSON:
+//
+//	{
+//	  "usage": 1932735283,
+//	  "quota": 2147483648,
+//	  "persisted": false,
+//	  "used": 0.9,
+//	  "threshold": 0.8
+//	}
+type StorageWarningJSON struct {
+	// Usage is the number of bytes used by the origin.
+	Usage uint64 `json:"usage"`
+
+	// Quota is the number of bytes available to the origin.
+	Quota uint64 `json:"quota"`
+
+	// Persisted is true if the browser will not evict the storage.
+	Persisted bool `json:"persisted"`
+
+	// Used is the fraction of the quota used.
+	Used float64 `json:"used"`
+
+	// Threshold is the fraction of the quota that triggers the warning.
+	Threshold float64 `json:"threshold"`
+}
+
+// ConversationStateUpdateJSON is returned on the EventUpdate callback of the
+// DM event model with the event type ConversationStateUpdate when a
+// conversation is archived, pinned, or muted, or when one of those is undone.
+//
+// Example JSON:
+//
+//	{
+//	  "pubKey": "z8VYHxDJG6b+bJQ3u1Ok0RGprg+z0a9U2aU6T8ZqZPA=",
+//	  "archived": true,
+//	  "pinned": false,
+//	  "muted": true
+//	}
+type ConversationStateUpdateJSON struct {
+	// PubKey is the public key of the partner of the conversation.
+	PubKey []byte `json:"pubKey"`
+
+	Archived bool `json:"archived"`
+	Pinned   bool `json:"pinned"`
+	Muted    bool `json:"muted"`
+}
@@ -0,0 +1,331 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HistoryFormat is the format a History is rendered to.
+type HistoryFormat string
+
+// Formats that a History can be rendered to.
+const (
+	HistoryJSON     HistoryFormat = "json"
+	HistoryMarkdown HistoryFormat = "markdown"
+	HistoryHTML     HistoryFormat = "html"
+)
+
+// historyTimeFormat is the format of timestamps in Markdown and HTML.
+const historyTimeFormat = "2006-01-02 15:04:05 MST"
+
+// History is the human-readable history of a single channel or DM
+// conversation.
+type History struct {
+	// Title is the name of the channel or the nickname of the DM partner.
+	Title string `json:"title"`
+
+	// ID is the channel ID or the public key of the DM partner.
+	ID []byte `json:"id"`
+
+	// Start and End are the bounds of the exported date range, if any.
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+
+	// Exported is the time the history was exported.
+	Exported time.Time `json:"exported"`
+
+	// Messages are in order of their timestamp.
+	Messages []HistoryMessage `json:"messages"`
+}
+
+// HistoryMessage is a single message in a History.
+type HistoryMessage struct {
+	MessageID []byte    `json:"messageID"`
+	Nickname  string    `json:"nickname"`
+	Timestamp time.Time `json:"timestamp"`
+	Text      string    `json:"text,omitempty"`
+	Pinned    bool      `json:"pinned,omitempty"`
+
+	// ReplyTo is the message ID of the message being replied to.
+	ReplyTo []byte `json:"replyTo,omitempty"`
+
+	// File is the file referenced by a file transfer message.
+	File *HistoryFile `json:"file,omitempty"`
+
+	// Reactions are all reactions to this message.
+	Reactions []HistoryReaction `json:"reactions,omitempty"`
+}
+
+// HistoryReaction is a reaction to a HistoryMessage.
+type HistoryReaction struct {
+	Reaction  string    `json:"reaction"`
+	Nickname  string    `json:"nickname"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// HistoryFile is a reference to a file sent in a HistoryMessage. The file
+// contents are not included.
+type HistoryFile struct {
+	FileID []byte `json:"fileID"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Size   uint32 `json:"size"`
+}
+
+// NewHistory returns an empty History for the date range. A zero start or end
+// leaves that side of the range unbounded.
+func NewHistory(title string, id []byte, start, end time.Time) *History {
+	h := &History{
+		Title:    title,
+		ID:       id,
+		Exported: time.Now(),
+		Messages: []HistoryMessage{},
+	}
+	if !start.IsZero() {
+		h.Start = &start
+	}
+	if !end.IsZero() {
+		h.End = &end
+	}
+	return h
+}
+
+// InRange returns true if the timestamp is within the date range of the
+// History. The start is inclusive and the end is exclusive.
+func (h *History) InRange(timestamp time.Time) bool {
+	return (h.Start == nil || !timestamp.Before(*h.Start)) &&
+		(h.End == nil || timestamp.Before(*h.End))
+}
+
+// Add adds the message to the History.
+func (h *History) Add(msg HistoryMessage) {
+	h.Messages = append(h.Messages, msg)
+}
+
+// AddReaction adds the reaction to the message with the given ID. Returns false
+// if the message is not in the History.
+func (h *History) AddReaction(messageID []byte, reaction HistoryReaction) bool {
+	for i := range h.Messages {
+		if bytes.Equal(h.Messages[i].MessageID, messageID) {
+			h.Messages[i].Reactions =
+				append(h.Messages[i].Reactions, reaction)
+			return true
+		}
+	}
+	return false
+}
+
+// Render sorts the messages by timestamp and renders the History in the given
+// format.
+func (h *History) Render(format HistoryFormat) ([]byte, error) {
+	sort.SliceStable(h.Messages, func(i, j int) bool {
+		return h.Messages[i].Timestamp.Before(h.Messages[j].Timestamp)
+	})
+	for _, msg := range h.Messages {
+		sort.SliceStable(msg.Reactions, func(i, j int) bool {
+			return msg.Reactions[i].Timestamp.Before(msg.Reactions[j].Timestamp)
+		})
+	}
+
+	switch format {
+	case HistoryJSON:
+		return json.MarshalIndent(h, "", "  ")
+	case HistoryMarkdown:
+		return h.renderMarkdown(), nil
+	case HistoryHTML:
+		return h.renderHTML()
+	default:
+		return nil, errors.Errorf("unknown history format %q", format)
+	}
+}
+
+// replied returns the message with the given ID. Returns nil if the message is
+// not in the History.
+func (h *History) replied(messageID []byte) *HistoryMessage {
+	for i := range h.Messages {
+		if bytes.Equal(h.Messages[i].MessageID, messageID) {
+			return &h.Messages[i]
+		}
+	}
+	return nil
+}
+
+// renderMarkdown renders the History as Markdown.
+func (h *History) renderMarkdown() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", h.Title)
+	fmt.Fprintf(&buf, "_%s_\n\n", h.rangeDescription())
+
+	for _, msg := range h.Messages {
+		fmt.Fprintf(&buf, "---\n\n**%s** · %s", msg.Nickname,
+			msg.Timestamp.UTC().Format(historyTimeFormat))
+		if msg.Pinned {
+			buf.WriteString(" · 📌")
+		}
+		buf.WriteString("\n\n")
+
+		if msg.ReplyTo != nil {
+			fmt.Fprintf(&buf, "> %s\n\n", h.replyDescription(msg.ReplyTo))
+		}
+		if msg.Text != "" {
+			buf.WriteString(msg.Text)
+			buf.WriteString("\n\n")
+		}
+		if msg.File != nil {
+			fmt.Fprintf(&buf, "📎 %s\n\n", msg.File.description())
+		}
+		if len(msg.Reactions) > 0 {
+			fmt.Fprintf(&buf, "%s\n\n", reactionsDescription(msg.Reactions))
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// historyTemplate is the template for a standalone HTML History.
+var historyTemplate = template.Must(template.New("history").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body{font-family:sans-serif;max-width:48em;margin:2em auto;padding:0 1em;color:#222}
+.message{border-top:1px solid #ddd;padding:.75em 0}
+.nickname{font-weight:bold}
+.timestamp,.range{color:#777;font-size:.85em}
+.reply{border-left:3px solid #ccc;padding-left:.5em;color:#555;margin:.25em 0}
+.text{white-space:pre-wrap;margin:.25em 0}
+.file,.reactions{font-size:.9em;margin:.25em 0}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="range">{{.Range}}</p>
+{{range .Messages}}<div class="message">
+<div><span class="nickname">{{.Nickname}}</span> <span class="timestamp">{{.Timestamp}}</span>{{if .Pinned}} 📌{{end}}</div>
+{{if .Reply}}<div class="reply">{{.Reply}}</div>
+{{end}}{{if .Text}}<div class="text">{{.Text}}</div>
+{{end}}{{if .File}}<div class="file">📎 {{.File}}</div>
+{{end}}{{if .Reactions}}<div class="reactions">{{.Reactions}}</div>
+{{end}}</div>
+{{end}}</body>
+</html>
+`))
+
+// renderHTML renders the History as a standalone HTML document.
+func (h *History) renderHTML() ([]byte, error) {
+	type htmlMessage struct {
+		Nickname, Timestamp, Reply, Text, File, Reactions string
+		Pinned                                            bool
+	}
+	data := struct {
+		Title, Range string
+		Messages     []htmlMessage
+	}{
+		Title:    h.Title,
+		Range:    h.rangeDescription(),
+		Messages: make([]htmlMessage, len(h.Messages)),
+	}
+
+	for i, msg := range h.Messages {
+		data.Messages[i] = htmlMessage{
+			Nickname:  msg.Nickname,
+			Timestamp: msg.Timestamp.UTC().Format(historyTimeFormat),
+			Text:      msg.Text,
+			Pinned:    msg.Pinned,
+			Reactions: reactionsDescription(msg.Reactions),
+		}
+		if msg.ReplyTo != nil {
+			data.Messages[i].Reply = h.replyDescription(msg.ReplyTo)
+		}
+		if msg.File != nil {
+			data.Messages[i].File = msg.File.description()
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := historyTemplate.Execute(&buf, data); err != nil {
+		return nil, errors.Wrap(err, "failed to render HTML")
+	}
+	return buf.Bytes(), nil
+}
+
+// rangeDescription describes the date range and export time of the History.
+func (h *History) rangeDescription() string {
+	var s string
+	switch {
+	case h.Start != nil && h.End != nil:
+		s = fmt.Sprintf("Messages from %s to %s",
+			h.Start.UTC().Format(historyTimeFormat),
+			h.End.UTC().Format(historyTimeFormat))
+	case h.Start != nil:
+		s = fmt.Sprintf("Messages since %s",
+			h.Start.UTC().Format(historyTimeFormat))
+	case h.End != nil:
+		s = fmt.Sprintf("Messages before %s",
+			h.End.UTC().Format(historyTimeFormat))
+	default:
+		s = "All messages"
+	}
+	return fmt.Sprintf("%s. Exported %s.",
+		s, h.Exported.UTC().Format(historyTimeFormat))
+}
+
+// replyDescription describes the message being replied to. Only the first line
+// of its text is included.
+func (h *History) replyDescription(messageID []byte) string {
+	replied := h.replied(messageID)
+	if replied == nil {
+		return "Replying to a message not in this export"
+	}
+
+	text, _, _ := strings.Cut(replied.Text, "\n")
+	const maxLen = 80
+	if runes := []rune(text); len(runes) > maxLen {
+		text = string(runes[:maxLen]) + "…"
+	}
+	return fmt.Sprintf("Replying to %s: %s", replied.Nickname, text)
+}
+
+// reactionsDescription lists each reaction with the nicknames of everyone who
+// reacted with it.
+func reactionsDescription(reactions []HistoryReaction) string {
+	var order []string
+	nicknames := make(map[string][]string)
+	for _, r := range reactions {
+		if _, exists := nicknames[r.Reaction]; !exists {
+			order = append(order, r.Reaction)
+		}
+		nicknames[r.Reaction] = append(nicknames[r.Reaction], r.Nickname)
+	}
+
+	descriptions := make([]string, len(order))
+	for i, reaction := range order {
+		descriptions[i] = fmt.Sprintf(
+			"%s %s", reaction, strings.Join(nicknames[reaction], ", "))
+	}
+	return strings.Join(descriptions, " · ")
+}
+
+// description describes the file by its name, type, size, and ID.
+func (f *HistoryFile) description() string {
+	return fmt.Sprintf("%s (%s, %d bytes, ID %s)", f.Name, f.Type, f.Size,
+		base64.StdEncoding.EncodeToString(f.FileID))
+}
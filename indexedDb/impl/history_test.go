@@ -0,0 +1,188 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestHistory returns a History with a message, a reply to it containing
+// HTML, and a file, added out of order, with a reaction to the first message.
+func newTestHistory(t *testing.T) *History {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := NewHistory("General", []byte("channelID"), start, time.Time{})
+
+	h.Add(HistoryMessage{
+		MessageID: []byte("msg2"),
+		Nickname:  "Bob",
+		Timestamp: start.Add(2 * time.Minute),
+		Text:      "<b>Hi</b> Alice",
+		ReplyTo:   []byte("msg1"),
+	})
+	h.Add(HistoryMessage{
+		MessageID: []byte("msg1"),
+		Nickname:  "Alice",
+		Timestamp: start.Add(time.Minute),
+		Text:      "Hello, World!",
+		Pinned:    true,
+	})
+	h.Add(HistoryMessage{
+		MessageID: []byte("msg3"),
+		Nickname:  "Alice",
+		Timestamp: start.Add(3 * time.Minute),
+		File: &HistoryFile{
+			FileID: []byte("fileID"),
+			Name:   "photo.png",
+			Type:   "image/png",
+			Size:   2048,
+		},
+	})
+
+	if !h.AddReaction([]byte("msg1"), HistoryReaction{
+		Reaction:  "👍",
+		Nickname:  "Bob",
+		Timestamp: start.Add(4 * time.Minute),
+	}) {
+		t.Fatalf("Failed to add reaction to message in history.")
+	}
+
+	return h
+}
+
+// Tests that History.InRange includes the start and excludes the end of the
+// range and that a zero start or end leaves that side unbounded.
+func TestHistory_InRange(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	tests := []struct {
+		start, end time.Time
+		timestamp  time.Time
+		expected   bool
+	}{
+		{start, end, start, true},
+		{start, end, end.Add(-time.Nanosecond), true},
+		{start, end, end, false},
+		{start, end, start.Add(-time.Nanosecond), false},
+		{time.Time{}, end, start.AddDate(-10, 0, 0), true},
+		{start, time.Time{}, end.AddDate(10, 0, 0), true},
+		{time.Time{}, time.Time{}, start, true},
+	}
+
+	for i, tt := range tests {
+		h := NewHistory("", nil, tt.start, tt.end)
+		if inRange := h.InRange(tt.timestamp); inRange != tt.expected {
+			t.Errorf("Unexpected result for %s in [%s, %s) (%d)."+
+				"\nexpected: %t\nreceived: %t",
+				tt.timestamp, tt.start, tt.end, i, tt.expected, inRange)
+		}
+	}
+}
+
+// Error path: Tests that History.AddReaction returns false for a message that
+// is not in the History.
+func TestHistory_AddReaction_MissingMessage(t *testing.T) {
+	h := NewHistory("", nil, time.Time{}, time.Time{})
+	if h.AddReaction([]byte("msg"), HistoryReaction{Reaction: "👍"}) {
+		t.Errorf("Added reaction to a message not in the history.")
+	}
+}
+
+// Tests that History.Render with HistoryJSON sorts the messages by timestamp
+// and produces JSON that can be unmarshalled back into the History.
+func TestHistory_Render_JSON(t *testing.T) {
+	h := newTestHistory(t)
+	data, err := h.Render(HistoryJSON)
+	if err != nil {
+		t.Fatalf("Failed to render JSON: %+v", err)
+	}
+
+	var received History
+	if err = json.Unmarshal(data, &received); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %+v", err)
+	}
+
+	for i, expected := range []string{"msg1", "msg2", "msg3"} {
+		if string(received.Messages[i].MessageID) != expected {
+			t.Errorf("Unexpected message %d.\nexpected: %s\nreceived: %s",
+				i, expected, received.Messages[i].MessageID)
+		}
+	}
+	if len(received.Messages[0].Reactions) != 1 ||
+		received.Messages[0].Reactions[0].Reaction != "👍" {
+		t.Errorf("Unexpected reactions.\nexpected: %+v\nreceived: %+v",
+			h.Messages[0].Reactions, received.Messages[0].Reactions)
+	}
+	if received.End != nil {
+		t.Errorf("Unbounded end unexpectedly set: %s", received.End)
+	}
+}
+
+// Tests that History.Render with HistoryMarkdown includes the title, messages
+// in order, replies, reactions, and files.
+func TestHistory_Render_Markdown(t *testing.T) {
+	data, err := newTestHistory(t).Render(HistoryMarkdown)
+	if err != nil {
+		t.Fatalf("Failed to render Markdown: %+v", err)
+	}
+	md := string(data)
+
+	for _, expected := range []string{
+		"# General",
+		"**Alice** · 2023-01-01 00:01:00 UTC · 📌",
+		"> Replying to Alice: Hello, World!",
+		"👍 Bob",
+		"📎 photo.png (image/png, 2048 bytes",
+	} {
+		if !strings.Contains(md, expected) {
+			t.Errorf("Markdown does not contain %q:\n%s", expected, md)
+		}
+	}
+
+	if strings.Index(md, "Hello, World!") > strings.Index(md, "Hi</b> Alice") {
+		t.Errorf("Messages not in order of timestamp:\n%s", md)
+	}
+}
+
+// Tests that History.Render with HistoryHTML produces a standalone page with
+// the message text escaped.
+func TestHistory_Render_HTML(t *testing.T) {
+	data, err := newTestHistory(t).Render(HistoryHTML)
+	if err != nil {
+		t.Fatalf("Failed to render HTML: %+v", err)
+	}
+	page := string(data)
+
+	for _, expected := range []string{
+		"<!DOCTYPE html>",
+		"<title>General</title>",
+		"&lt;b&gt;Hi&lt;/b&gt; Alice",
+		"Replying to Alice: Hello, World!",
+		"photo.png",
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("HTML does not contain %q:\n%s", expected, page)
+		}
+	}
+	if strings.Contains(page, "<b>Hi</b>") {
+		t.Errorf("HTML contains unescaped message text:\n%s", page)
+	}
+}
+
+// Error path: Tests that History.Render returns an error for an unknown format.
+func TestHistory_Render_UnknownFormatError(t *testing.T) {
+	h := NewHistory("", nil, time.Time{}, time.Time{})
+	if _, err := h.Render("pdf"); err == nil {
+		t.Errorf("Did not get error for unknown format.")
+	}
+}
@@ -0,0 +1,284 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"encoding/json"
+	"syscall/js"
+	"time"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/wasm-utils/utils"
+)
+
+// QuarantineStoreName is the name of the object store that bad values are
+// moved to when a database is repaired.
+const QuarantineStoreName = "quarantine"
+
+// IntegrityProblem describes what is wrong with a value found by an integrity
+// check.
+type IntegrityProblem string
+
+// Problems found by an integrity check.
+const (
+	// InvalidJSON indicates the value cannot be unmarshalled into its type.
+	InvalidJSON IntegrityProblem = "invalidJSON"
+
+	// Undecryptable indicates an encrypted field of the value cannot be
+	// decrypted with the cipher of the database.
+	Undecryptable IntegrityProblem = "undecryptable"
+
+	// MissingField indicates a required or indexed field of the value is
+	// empty, so it is missing from the index and cannot be queried.
+	MissingField IntegrityProblem = "missingField"
+
+	// MissingReference indicates the value refers to a value in another object
+	// store that does not exist (e.g., a message in a channel that was left).
+	MissingReference IntegrityProblem = "missingReference"
+
+	// Duplicate indicates another value in the object store has the same
+	// unique identifier. The value with the lowest primary key is kept.
+	Duplicate IntegrityProblem = "duplicate"
+
+	// IndexMismatch indicates an indexed field of the value does not match
+	// the fields it is derived from (e.g., a blind index that is not the blind
+	// index of the encrypted field), so the value is not found where the
+	// index says it is.
+	IndexMismatch IntegrityProblem = "indexMismatch"
+)
+
+// IntegrityAction describes what was done about a bad value.
+type IntegrityAction string
+
+// Actions taken on a bad value.
+const (
+	// NoAction indicates the value was left as is because repair was not
+	// requested.
+	NoAction IntegrityAction = "none"
+
+	// Quarantined indicates the value was moved to the quarantine object store.
+	Quarantined IntegrityAction = "quarantined"
+
+	// RepairFailed indicates the value could not be moved. The error is
+	// appended to the detail of the issue.
+	RepairFailed IntegrityAction = "failed"
+)
+
+// IntegrityIssue is a single bad value found by an integrity check.
+type IntegrityIssue struct {
+	// Store is the name of the object store containing the value.
+	Store string `json:"store"`
+
+	// Key is the JSON of the primary key of the value.
+	Key string `json:"key"`
+
+	Problem IntegrityProblem `json:"problem"`
+	Detail  string           `json:"detail"`
+	Action  IntegrityAction  `json:"action"`
+}
+
+// IntegrityReport is the result of checking every value in a database.
+//
+// Example JSON:
+//
+//	{
+//	  "databaseName": "AW7fj+J8Ku/6o2gs+ky6R6KTpaiYIJYDZkmS+ZqeXYED_speakeasy",
+//	  "repair": true,
+//	  "checked": {"channels": 3, "files": 0, "messages": 1042},
+//	  "issues": [
+//	    {
+//	      "store": "messages",
+//	      "key": "17",
+//	      "problem": "missingReference",
+//	      "detail": "channel \"Aw5...\" does not exist",
+//	      "action": "quarantined"
+//	    }
+//	  ],
+//	  "healthy": false
+//	}
+type IntegrityReport struct {
+	DatabaseName string `json:"databaseName"`
+
+	// Repair is true if bad values were moved to the quarantine store.
+	Repair bool `json:"repair"`
+
+	// Checked is the number of values checked in each object store.
+	Checked map[string]int `json:"checked"`
+
+	Issues []IntegrityIssue `json:"issues"`
+
+	// Healthy is true if no issues were found.
+	Healthy bool `json:"healthy"`
+}
+
+// QuarantinedValue is stored in the quarantine object store for each value
+// removed by a repair. The value is kept as it was stored (i.e., still
+// encrypted) so that it can be inspected or recovered later.
+type QuarantinedValue struct {
+	ID        uint64           `json:"id,omitempty"` // Auto-incremented
+	Store     string           `json:"store"`
+	Key       string           `json:"key"`
+	Problem   IntegrityProblem `json:"problem"`
+	Detail    string           `json:"detail"`
+	Value     string           `json:"value"`
+	Timestamp time.Time        `json:"timestamp"`
+}
+
+// CreateQuarantineStore creates the quarantine object store. It must be called
+// during a database upgrade.
+func CreateQuarantineStore(db *idb.Database) error {
+	_, err := db.CreateObjectStore(QuarantineStoreName, idb.ObjectStoreOptions{
+		KeyPath:       js.ValueOf("id"),
+		AutoIncrement: true,
+	})
+	return err
+}
+
+// ValueCheck validates a single value of an object store. It returns the
+// problem found and a description of it or an empty problem if the value is
+// valid.
+type ValueCheck func(value js.Value) (problem IntegrityProblem, detail string)
+
+// IntegrityChecker checks the values of the object stores of a database and,
+// if repair is enabled, moves bad values to the quarantine store.
+type IntegrityChecker struct {
+	db     *idb.Database
+	report *IntegrityReport
+}
+
+// NewIntegrityChecker returns a new IntegrityChecker for the database. If
+// repair is true, the database must have a quarantine store.
+func NewIntegrityChecker(db *idb.Database, databaseName string,
+	repair bool) *IntegrityChecker {
+	return &IntegrityChecker{
+		db: db,
+		report: &IntegrityReport{
+			DatabaseName: databaseName,
+			Repair:       repair,
+			Checked:      make(map[string]int),
+			Issues:       make([]IntegrityIssue, 0),
+		},
+	}
+}
+
+// CheckStore calls check on every value in the object store in order of
+// primary key, reading the primary key from the field keyPath. Values are read
+// before any are repaired, so check may keep state across values (e.g., to
+// find duplicates). Returns the number of bad values found.
+func (c *IntegrityChecker) CheckStore(objectStoreName, keyPath string,
+	check ValueCheck) (int, error) {
+	return c.checkStore(objectStoreName, func(value js.Value) js.Value {
+		return value.Get(keyPath)
+	}, check)
+}
+
+// CheckCompoundStore is CheckStore for an object store with a compound primary
+// key, which is read from the fields of keyPath in order.
+func (c *IntegrityChecker) CheckCompoundStore(objectStoreName string,
+	keyPath []string, check ValueCheck) (int, error) {
+	return c.checkStore(objectStoreName, func(value js.Value) js.Value {
+		key := make([]any, len(keyPath))
+		for i, field := range keyPath {
+			key[i] = value.Get(field)
+		}
+		return js.ValueOf(key)
+	}, check)
+}
+
+// checkStore calls check on every value in the object store, reading the
+// primary key of bad values with keyOf.
+func (c *IntegrityChecker) checkStore(objectStoreName string,
+	keyOf func(value js.Value) js.Value, check ValueCheck) (int, error) {
+	type badValue struct {
+		key, value js.Value
+		issue      IntegrityIssue
+	}
+
+	var bad []badValue
+	var checked int
+	err := NewQuery(objectStoreName).Iter(c.db, func(value js.Value) error {
+		checked++
+		problem, detail := check(value)
+		if problem == "" {
+			return nil
+		}
+		key := keyOf(value)
+		bad = append(bad, badValue{key, value, IntegrityIssue{
+			Store:   objectStoreName,
+			Key:     utils.JsToJson(key),
+			Problem: problem,
+			Detail:  detail,
+			Action:  NoAction,
+		}})
+		return nil
+	})
+	if err != nil {
+		return 0, errors.WithMessagef(err,
+			"failed to check object store %s", objectStoreName)
+	}
+	c.report.Checked[objectStoreName] = checked
+
+	for _, b := range bad {
+		if c.report.Repair {
+			if err = c.quarantine(objectStoreName, b.key, b.value,
+				b.issue); err != nil {
+				jww.ERROR.Printf("Failed to quarantine value %s in %s: %+v",
+					b.issue.Key, objectStoreName, err)
+				b.issue.Action = RepairFailed
+				b.issue.Detail += ": " + err.Error()
+			} else {
+				b.issue.Action = Quarantined
+			}
+		}
+		jww.WARN.Printf("Integrity check of %s found %s value %s in %s: %s "+
+			"(%s)", c.report.DatabaseName, b.issue.Problem, b.issue.Key,
+			objectStoreName, b.issue.Detail, b.issue.Action)
+		c.report.Issues = append(c.report.Issues, b.issue)
+	}
+
+	return len(bad), nil
+}
+
+// quarantine moves the value from the object store to the quarantine store in
+// a single transaction.
+func (c *IntegrityChecker) quarantine(objectStoreName string, key,
+	value js.Value, issue IntegrityIssue) error {
+	quarantinedJson, err := json.Marshal(QuarantinedValue{
+		Store:     objectStoreName,
+		Key:       issue.Key,
+		Problem:   issue.Problem,
+		Detail:    issue.Detail,
+		Value:     utils.JsToJson(value),
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		return errors.Errorf("Unable to marshal QuarantinedValue: %+v", err)
+	}
+	quarantinedObj, err := utils.JsonToJS(quarantinedJson)
+	if err != nil {
+		return errors.Errorf("Unable to marshal QuarantinedValue: %+v", err)
+	}
+
+	return Transact(c.db, []string{objectStoreName, QuarantineStoreName},
+		func(txn *Transaction) error {
+			if _, err := txn.Put(QuarantineStoreName, quarantinedObj); err != nil {
+				return err
+			}
+			return txn.Delete(objectStoreName, key)
+		})
+}
+
+// Report returns the report of every object store checked so far.
+func (c *IntegrityChecker) Report() *IntegrityReport {
+	c.report.Healthy = len(c.report.Issues) == 0
+	return c.report
+}
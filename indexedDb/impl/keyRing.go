@@ -0,0 +1,444 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
+)
+
+// Each ciphertext produced by a KeyRing is prefixed with a header containing
+// the version of the key used to encrypt it:
+//
+//	k<version>:<ciphertext>
+//
+// Ciphertexts produced by an [idbCrypto.Cipher] are base32768 encoded and never
+// contain ASCII characters, so ciphertexts without a header are treated as
+// version 0, the key used before key rotation was introduced. Version 0
+// ciphertexts are never given a header so that they remain identical to those
+// produced by an [idbCrypto.Cipher].
+const (
+	keyVersionPrefix    = "k"
+	keyVersionSeparator = ":"
+)
+
+// Error messages.
+const (
+	unknownKeyVersionErr = "no key with version %d in key ring"
+	invalidKeyHeaderErr  = "invalid key version header %q"
+)
+
+// ErrKeyRingClosed is returned by every operation on a KeyRing after Close.
+var ErrKeyRingClosed = errors.New("key ring is closed")
+
+// KeyRing adheres to the [idbCrypto.Cipher] interface. It holds every version
+// of the database key so that ciphertexts encrypted with any of them can be
+// decrypted. New ciphertexts are always encrypted with the current version.
+type KeyRing struct {
+	current uint32
+	ciphers map[uint32]idbCrypto.Cipher
+	closed  bool
+
+	// blockSize is the block size of the current key. It is zero until it is
+	// read by BlockSize and reset whenever the current key changes.
+	blockSize int
+
+	mux sync.RWMutex
+}
+
+// keyRingDisk is the JSON representation of a KeyRing.
+type keyRingDisk struct {
+	Current uint32                     `json:"current"`
+	Keys    map[uint32]json.RawMessage `json:"keys"`
+}
+
+// NewKeyRing creates a KeyRing from the ciphers for each key version. Returns
+// an error if there is no cipher for the current version.
+func NewKeyRing(
+	current uint32, ciphers map[uint32]idbCrypto.Cipher) (*KeyRing, error) {
+	if _, exists := ciphers[current]; !exists {
+		return nil, errors.Errorf(unknownKeyVersionErr, current)
+	}
+
+	kr := &KeyRing{
+		current: current,
+		ciphers: make(map[uint32]idbCrypto.Cipher, len(ciphers)),
+	}
+	for version, c := range ciphers {
+		kr.ciphers[version] = c
+	}
+
+	return kr, nil
+}
+
+// NewCipherFromJSON reconstructs a cipher from JSON produced by either a
+// KeyRing or an [idbCrypto.Cipher]. Returns nil if the JSON is null.
+func NewCipherFromJSON(data []byte, csprng io.Reader) (idbCrypto.Cipher, error) {
+	if string(data) == "null" {
+		return nil, nil
+	}
+
+	var disk keyRingDisk
+	if err := json.Unmarshal(data, &disk); err != nil {
+		return nil, err
+	} else if disk.Keys == nil {
+		return idbCrypto.NewCipherFromJSON(data, csprng)
+	}
+
+	ciphers := make(map[uint32]idbCrypto.Cipher, len(disk.Keys))
+	for version, keyData := range disk.Keys {
+		c, err := idbCrypto.NewCipherFromJSON(keyData, csprng)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal key %d", version)
+		}
+		ciphers[version] = c
+	}
+
+	return NewKeyRing(disk.Current, ciphers)
+}
+
+// Current returns the version of the key used to encrypt new ciphertexts.
+func (kr *KeyRing) Current() uint32 {
+	kr.mux.RLock()
+	defer kr.mux.RUnlock()
+	return kr.current
+}
+
+// Next returns the version that the next cipher passed to Add will be given.
+func (kr *KeyRing) Next() uint32 {
+	kr.mux.RLock()
+	defer kr.mux.RUnlock()
+	return kr.next()
+}
+
+// Close drops the key ring's reference to every key so that it can no longer
+// encrypt or decrypt. All further operations return ErrKeyRingClosed.
+//
+// The underlying ciphers provide no way to wipe their secrets, so the memory of
+// the keys is only released once no other references to them remain.
+func (kr *KeyRing) Close() {
+	kr.mux.Lock()
+	defer kr.mux.Unlock()
+	kr.ciphers = nil
+	kr.closed = true
+}
+
+// Add adds the cipher as a new key version and makes it the current version.
+// Returns the new version.
+func (kr *KeyRing) Add(c idbCrypto.Cipher) uint32 {
+	kr.mux.Lock()
+	defer kr.mux.Unlock()
+
+	if kr.closed {
+		return kr.current
+	}
+	kr.current = kr.next()
+	kr.ciphers[kr.current] = c
+	kr.blockSize = 0
+	return kr.current
+}
+
+// next returns the version after the latest version in the key ring. The
+// caller must hold the lock.
+func (kr *KeyRing) next() uint32 {
+	var latest uint32
+	for version := range kr.ciphers {
+		if version > latest {
+			latest = version
+		}
+	}
+	return latest + 1
+}
+
+// Cipher returns the cipher for the given key version.
+func (kr *KeyRing) Cipher(version uint32) (idbCrypto.Cipher, error) {
+	kr.mux.RLock()
+	defer kr.mux.RUnlock()
+
+	if kr.closed {
+		return nil, ErrKeyRingClosed
+	}
+	c, exists := kr.ciphers[version]
+	if !exists {
+		return nil, errors.Errorf(unknownKeyVersionErr, version)
+	}
+	return c, nil
+}
+
+// IndexCipher returns the cipher of the oldest key version. Blind indexes are
+// keyed with it so that they do not change when a new key is added.
+//
+// Rotating the key therefore does not rotate the blind index key. Blind indexes
+// only reveal which stored values are equal, and keeping them stable means
+// that values indexed before a rotation can still be looked up without
+// re-indexing the whole database.
+func (kr *KeyRing) IndexCipher() (idbCrypto.Cipher, error) {
+	kr.mux.RLock()
+	defer kr.mux.RUnlock()
+
+	if kr.closed {
+		return nil, ErrKeyRingClosed
+	}
+	oldest, found := uint32(0), false
+	for version := range kr.ciphers {
+		if !found || version < oldest {
+			oldest, found = version, true
+		}
+	}
+	if !found {
+		return nil, errors.New("key ring has no keys")
+	}
+	return kr.ciphers[oldest], nil
+}
+
+// Ciphers returns the ciphers of all key versions, in order of version.
+func (kr *KeyRing) Ciphers() []idbCrypto.Cipher {
+	kr.mux.RLock()
+	defer kr.mux.RUnlock()
+
+	versions := make([]uint32, 0, len(kr.ciphers))
+	for version := range kr.ciphers {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	ciphers := make([]idbCrypto.Cipher, len(versions))
+	for i, version := range versions {
+		ciphers[i] = kr.ciphers[version]
+	}
+	return ciphers
+}
+
+// BlockSize returns the block size of the current key. It is read from the key
+// once and cached until the current key changes.
+func (kr *KeyRing) BlockSize() (int, error) {
+	kr.mux.RLock()
+	current, c, blockSize := kr.current, kr.ciphers[kr.current], kr.blockSize
+	closed := kr.closed
+	kr.mux.RUnlock()
+
+	if closed {
+		return 0, ErrKeyRingClosed
+	} else if blockSize > 0 {
+		return blockSize, nil
+	}
+
+	blockSize, err := readBlockSize(c)
+	if err != nil {
+		return 0, err
+	}
+
+	kr.mux.Lock()
+	if kr.current == current && !kr.closed {
+		kr.blockSize = blockSize
+	}
+	kr.mux.Unlock()
+	return blockSize, nil
+}
+
+// IsCurrent returns true if the ciphertext was encrypted with the current key
+// version.
+func (kr *KeyRing) IsCurrent(ciphertext string) bool {
+	version, _, err := KeyVersion(ciphertext)
+	return err == nil && version == kr.Current()
+}
+
+// Encrypt encrypts the plaintext with the current key and prefixes the
+// ciphertext with its key version header.
+func (kr *KeyRing) Encrypt(plaintext []byte) (string, error) {
+	kr.mux.RLock()
+	current, c, closed := kr.current, kr.ciphers[kr.current], kr.closed
+	kr.mux.RUnlock()
+
+	if closed {
+		return "", ErrKeyRingClosed
+	}
+	ciphertext, err := c.Encrypt(plaintext)
+	if err != nil {
+		return "", err
+	} else if current == 0 {
+		return ciphertext, nil
+	}
+
+	return keyVersionPrefix + strconv.FormatUint(uint64(current), 10) +
+		keyVersionSeparator + ciphertext, nil
+}
+
+// Decrypt decrypts the ciphertext with the key version in its header.
+// Ciphertexts without a header are decrypted with version 0.
+func (kr *KeyRing) Decrypt(ciphertext string) ([]byte, error) {
+	version, ciphertext, err := KeyVersion(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := kr.Cipher(version)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Decrypt(ciphertext)
+}
+
+// MarshalJSON marshals the KeyRing into valid JSON. This function adheres to
+// the [json.Marshaler] interface.
+func (kr *KeyRing) MarshalJSON() ([]byte, error) {
+	kr.mux.RLock()
+	defer kr.mux.RUnlock()
+
+	if kr.closed {
+		return nil, ErrKeyRingClosed
+	}
+	disk := keyRingDisk{
+		Current: kr.current,
+		Keys:    make(map[uint32]json.RawMessage, len(kr.ciphers)),
+	}
+	for version, c := range kr.ciphers {
+		data, err := c.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		disk.Keys[version] = data
+	}
+
+	return json.Marshal(disk)
+}
+
+// UnmarshalJSON unmarshalls JSON into the existing ciphers of the KeyRing. This
+// function adheres to the [json.Unmarshaler] interface.
+//
+// Note that this function cannot create ciphers for new key versions. Use
+// NewCipherFromJSON to properly reconstruct a KeyRing from JSON.
+func (kr *KeyRing) UnmarshalJSON(data []byte) error {
+	var disk keyRingDisk
+	if err := json.Unmarshal(data, &disk); err != nil {
+		return err
+	}
+
+	kr.mux.Lock()
+	defer kr.mux.Unlock()
+
+	if kr.closed {
+		return ErrKeyRingClosed
+	}
+	if _, exists := disk.Keys[disk.Current]; !exists {
+		return errors.Errorf(unknownKeyVersionErr, disk.Current)
+	}
+	for version, keyData := range disk.Keys {
+		c, exists := kr.ciphers[version]
+		if !exists {
+			return errors.Errorf(unknownKeyVersionErr, version)
+		}
+		if err := c.UnmarshalJSON(keyData); err != nil {
+			return err
+		}
+	}
+	kr.current = disk.Current
+	kr.blockSize = 0
+
+	return nil
+}
+
+// KeyVersion parses the key version header of the ciphertext. Returns the key
+// version and the ciphertext without the header. Ciphertexts without a header
+// are version 0.
+func KeyVersion(ciphertext string) (uint32, string, error) {
+	if !strings.HasPrefix(ciphertext, keyVersionPrefix) {
+		return 0, ciphertext, nil
+	}
+
+	header, body, found := strings.Cut(
+		strings.TrimPrefix(ciphertext, keyVersionPrefix), keyVersionSeparator)
+	if !found {
+		return 0, "", errors.Errorf(invalidKeyHeaderErr, ciphertext)
+	}
+
+	version, err := strconv.ParseUint(header, 10, 32)
+	if err != nil {
+		return 0, "", errors.Errorf(invalidKeyHeaderErr, header)
+	}
+
+	return uint32(version), body, nil
+}
+
+// ReEncrypt decrypts and re-encrypts the ciphertext with the current key if the
+// cipher is a KeyRing and the ciphertext was encrypted with an older key.
+// Returns true if the ciphertext was re-encrypted.
+func ReEncrypt(cipher idbCrypto.Cipher, ciphertext string) (string, bool, error) {
+	kr, ok := cipher.(*KeyRing)
+	if !ok || ciphertext == "" || kr.IsCurrent(ciphertext) {
+		return ciphertext, false, nil
+	}
+
+	plaintext, err := kr.Decrypt(ciphertext)
+	if err != nil {
+		return "", false, err
+	}
+
+	ciphertext, err = kr.Encrypt(plaintext)
+	if err != nil {
+		return "", false, err
+	}
+
+	return ciphertext, true, nil
+}
+
+// ReEncryptChunked re-encrypts data encrypted with EncryptChunked with the
+// current key if the cipher is a KeyRing and any chunk was encrypted with an
+// older key. Returns true if the data was re-encrypted.
+func ReEncryptChunked(
+	cipher idbCrypto.Cipher, ciphertext []byte) ([]byte, bool, error) {
+	if ciphertext == nil {
+		return nil, false, nil
+	}
+
+	chunks, err := unmarshalChunks(ciphertext)
+	if err != nil {
+		return nil, false, err
+	}
+
+	kr, ok := cipher.(*KeyRing)
+	if !ok {
+		return ciphertext, false, nil
+	}
+
+	var changed bool
+	for i := range chunks {
+		chunk, chunkChanged, err := ReEncrypt(kr, chunks[i])
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "failed to re-encrypt chunk %d", i)
+		}
+		chunks[i] = chunk
+		changed = changed || chunkChanged
+	}
+	if !changed {
+		return ciphertext, false, nil
+	}
+
+	ciphertext, err = json.Marshal(chunkedCiphertext{Chunks: chunks})
+	return ciphertext, err == nil, err
+}
+
+// currentCipher returns the cipher of the current key if the cipher is a
+// KeyRing. Otherwise, the cipher is returned unchanged.
+func currentCipher(cipher idbCrypto.Cipher) (idbCrypto.Cipher, error) {
+	if kr, ok := cipher.(*KeyRing); ok {
+		return kr.Cipher(kr.Current())
+	}
+	return cipher, nil
+}
@@ -0,0 +1,323 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
+	"gitlab.com/xx_network/crypto/csprng"
+)
+
+// newTestKeyRing returns a KeyRing with numKeys keys, where the last key is
+// the current key.
+func newTestKeyRing(numKeys int, t testing.TB) *KeyRing {
+	ciphers := make(map[uint32]idbCrypto.Cipher, numKeys)
+	for i := 0; i < numKeys; i++ {
+		c, err := idbCrypto.NewCipher([]byte("testPassword"),
+			[]byte("testSalt"+strconv.Itoa(i)), 256, csprng.NewSystemRNG())
+		if err != nil {
+			t.Fatalf("Failed to create cipher %d: %+v", i, err)
+		}
+		ciphers[uint32(i)] = c
+	}
+
+	kr, err := NewKeyRing(uint32(numKeys-1), ciphers)
+	if err != nil {
+		t.Fatalf("Failed to create KeyRing: %+v", err)
+	}
+	return kr
+}
+
+// Tests that ciphertexts encrypted with every key version of a KeyRing can
+// still be decrypted after new keys are added.
+func TestKeyRing_Encrypt_Decrypt(t *testing.T) {
+	kr := newTestKeyRing(1, t)
+	plaintext := []byte("Hello, World!")
+
+	ciphertexts := make(map[uint32]string)
+	for i := 0; i < 5; i++ {
+		ciphertext, err := kr.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("Failed to encrypt with key %d: %+v", kr.Current(), err)
+		}
+		ciphertexts[kr.Current()] = ciphertext
+
+		c, err := idbCrypto.NewCipher([]byte("testPassword"),
+			[]byte("newSalt"+strconv.Itoa(i)), 256, csprng.NewSystemRNG())
+		if err != nil {
+			t.Fatalf("Failed to create cipher: %+v", err)
+		}
+		if version := kr.Add(c); version != uint32(i+1) {
+			t.Errorf("Unexpected new key version.\nexpected: %d\nreceived: %d",
+				i+1, version)
+		}
+	}
+
+	for version, ciphertext := range ciphertexts {
+		decrypted, err := kr.Decrypt(ciphertext)
+		if err != nil {
+			t.Errorf("Failed to decrypt key %d ciphertext: %+v", version, err)
+		} else if !bytes.Equal(plaintext, decrypted) {
+			t.Errorf("Unexpected plaintext for key %d."+
+				"\nexpected: %q\nreceived: %q", version, plaintext, decrypted)
+		}
+	}
+}
+
+// Tests that a KeyRing with only key version 0 produces ciphertexts without a
+// header that can be decrypted by the underlying cipher.
+func TestKeyRing_Encrypt_Version0(t *testing.T) {
+	kr := newTestKeyRing(1, t)
+	plaintext := []byte("Hello, World!")
+
+	ciphertext, err := kr.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %+v", err)
+	}
+
+	c, err := kr.Cipher(0)
+	if err != nil {
+		t.Fatalf("Failed to get cipher: %+v", err)
+	}
+	decrypted, err := c.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Failed to decrypt with cipher: %+v", err)
+	} else if !bytes.Equal(plaintext, decrypted) {
+		t.Errorf("Unexpected plaintext.\nexpected: %q\nreceived: %q",
+			plaintext, decrypted)
+	}
+}
+
+// Tests that KeyVersion parses the key version from valid headers and returns
+// version 0 for ciphertexts without a header.
+func TestKeyVersion(t *testing.T) {
+	tests := []struct {
+		ciphertext, body string
+		version          uint32
+	}{
+		{"k1:abc", "abc", 1},
+		{"k4294967295:abc", "abc", 4294967295},
+		{"k12:", "", 12},
+		{"饇濠螃", "饇濠螃", 0},
+		{"", "", 0},
+	}
+
+	for i, tt := range tests {
+		version, body, err := KeyVersion(tt.ciphertext)
+		if err != nil {
+			t.Errorf("Failed to parse key version (%d): %+v", i, err)
+		} else if version != tt.version || body != tt.body {
+			t.Errorf("Unexpected key version and body (%d)."+
+				"\nexpected: %d %q\nreceived: %d %q",
+				i, tt.version, tt.body, version, body)
+		}
+	}
+}
+
+// Error path: Tests that KeyVersion returns an error for invalid headers.
+func TestKeyVersion_InvalidHeaderError(t *testing.T) {
+	for _, ciphertext := range []string{"k1abc", "kA:abc", "k-1:abc",
+		"k4294967296:abc"} {
+		if _, _, err := KeyVersion(ciphertext); err == nil {
+			t.Errorf("Did not get error for invalid header %q.", ciphertext)
+		}
+	}
+}
+
+// Tests that NewCipherFromJSON returns nil for null JSON, an idbCrypto.Cipher
+// for the JSON of an idbCrypto.Cipher, and a KeyRing for the JSON of a
+// KeyRing.
+func TestNewCipherFromJSON(t *testing.T) {
+	c, err := NewCipherFromJSON([]byte("null"), csprng.NewSystemRNG())
+	if err != nil || c != nil {
+		t.Errorf("Unexpected cipher for null JSON: %v, %v", c, err)
+	}
+
+	kr := newTestKeyRing(3, t)
+	legacy, _ := kr.Cipher(0)
+	legacyJSON, err := legacy.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Failed to marshal cipher: %+v", err)
+	}
+	c, err = NewCipherFromJSON(legacyJSON, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to unmarshal cipher: %+v", err)
+	} else if _, ok := c.(*KeyRing); ok {
+		t.Errorf("Cipher JSON unmarshalled into a KeyRing.")
+	}
+
+	krJSON, err := kr.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Failed to marshal KeyRing: %+v", err)
+	}
+	c, err = NewCipherFromJSON(krJSON, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to unmarshal KeyRing: %+v", err)
+	}
+	newKr, ok := c.(*KeyRing)
+	if !ok {
+		t.Fatalf("Unexpected cipher type.\nexpected: %T\nreceived: %T", kr, c)
+	} else if newKr.Current() != kr.Current() {
+		t.Errorf("Unexpected current key version.\nexpected: %d\nreceived: %d",
+			kr.Current(), newKr.Current())
+	}
+
+	plaintext := []byte("Hello, World!")
+	ciphertext, err := kr.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %+v", err)
+	}
+	decrypted, err := newKr.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %+v", err)
+	} else if !bytes.Equal(plaintext, decrypted) {
+		t.Errorf("Unexpected plaintext.\nexpected: %q\nreceived: %q",
+			plaintext, decrypted)
+	}
+}
+
+// Tests that ReEncrypt re-encrypts ciphertexts from older key versions with
+// the current key and leaves ciphertexts of the current key unchanged.
+func TestReEncrypt(t *testing.T) {
+	kr := newTestKeyRing(2, t)
+	plaintext := []byte("Hello, World!")
+
+	old, _ := kr.Cipher(0)
+	oldCiphertext, err := old.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %+v", err)
+	}
+
+	ciphertext, changed, err := ReEncrypt(kr, oldCiphertext)
+	if err != nil {
+		t.Fatalf("Failed to re-encrypt: %+v", err)
+	} else if !changed {
+		t.Errorf("Ciphertext of old key not re-encrypted.")
+	} else if !kr.IsCurrent(ciphertext) {
+		t.Errorf("Re-encrypted ciphertext not encrypted with current key: %q",
+			ciphertext)
+	}
+
+	decrypted, err := kr.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %+v", err)
+	} else if !bytes.Equal(plaintext, decrypted) {
+		t.Errorf("Unexpected plaintext.\nexpected: %q\nreceived: %q",
+			plaintext, decrypted)
+	}
+
+	newCiphertext, changed, err := ReEncrypt(kr, ciphertext)
+	if err != nil {
+		t.Fatalf("Failed to re-encrypt: %+v", err)
+	} else if changed || newCiphertext != ciphertext {
+		t.Errorf("Ciphertext of current key re-encrypted.")
+	}
+}
+
+// Tests that KeyRing.BlockSize returns the block size of the current key after
+// a key with a different block size is added.
+func TestKeyRing_BlockSize(t *testing.T) {
+	kr := newTestKeyRing(1, t)
+	if blockSize, err := kr.BlockSize(); err != nil || blockSize != 256 {
+		t.Errorf("Unexpected block size.\nexpected: %d\nreceived: %d (%v)",
+			256, blockSize, err)
+	}
+
+	c, err := idbCrypto.NewCipher([]byte("testPassword"),
+		[]byte("testSaltNew"), 512, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+	kr.Add(c)
+	if blockSize, err := kr.BlockSize(); err != nil || blockSize != 512 {
+		t.Errorf("Unexpected block size after Add."+
+			"\nexpected: %d\nreceived: %d (%v)", 512, blockSize, err)
+	}
+}
+
+// Tests that BlindIndex returns the same index for a KeyRing after a new key is
+// added and that the index matches that of the oldest key.
+func TestBlindIndex_KeyRing(t *testing.T) {
+	kr := newTestKeyRing(1, t)
+	data := []byte("senderPubKey")
+
+	index, err := BlindIndex(kr, data)
+	if err != nil {
+		t.Fatalf("Failed to get blind index: %+v", err)
+	}
+
+	c, err := idbCrypto.NewCipher([]byte("testPassword"),
+		[]byte("testSaltNew"), 256, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+	kr.Add(c)
+
+	rotatedIndex, err := BlindIndex(kr, data)
+	if err != nil {
+		t.Fatalf("Failed to get blind index: %+v", err)
+	} else if !bytes.Equal(index, rotatedIndex) {
+		t.Errorf("Blind index changed after key rotation."+
+			"\nexpected: %v\nreceived: %v", index, rotatedIndex)
+	}
+
+	oldest, _ := kr.Cipher(0)
+	oldestIndex, err := BlindIndex(oldest, data)
+	if err != nil {
+		t.Fatalf("Failed to get blind index: %+v", err)
+	} else if !bytes.Equal(index, oldestIndex) {
+		t.Errorf("Blind index not keyed with the oldest key."+
+			"\nexpected: %v\nreceived: %v", oldestIndex, index)
+	}
+}
+
+// Tests that ReEncryptChunked re-encrypts only data with chunks from older key
+// versions.
+func TestReEncryptChunked(t *testing.T) {
+	kr := newTestKeyRing(2, t)
+	plaintext := bytes.Repeat([]byte("Hello, World!"), 100)
+
+	old, _ := kr.Cipher(0)
+	oldCiphertext, err := EncryptChunked(old, plaintext)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %+v", err)
+	}
+
+	ciphertext, changed, err := ReEncryptChunked(kr, oldCiphertext)
+	if err != nil {
+		t.Fatalf("Failed to re-encrypt: %+v", err)
+	} else if !changed {
+		t.Errorf("Ciphertext of old key not re-encrypted.")
+	}
+
+	decrypted, err := DecryptChunked(kr, ciphertext)
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %+v", err)
+	} else if !bytes.Equal(plaintext, decrypted) {
+		t.Errorf("Unexpected plaintext.\nexpected: %q\nreceived: %q",
+			plaintext, decrypted)
+	}
+
+	_, changed, err = ReEncryptChunked(kr, ciphertext)
+	if err != nil {
+		t.Fatalf("Failed to re-encrypt: %+v", err)
+	} else if changed {
+		t.Errorf("Ciphertext of current key re-encrypted.")
+	}
+
+	ciphertext, changed, err = ReEncryptChunked(kr, nil)
+	if err != nil || changed || ciphertext != nil {
+		t.Errorf("Unexpected result for nil ciphertext: %q, %t, %v",
+			ciphertext, changed, err)
+	}
+}
@@ -0,0 +1,416 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"syscall/js"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/pkg/errors"
+)
+
+// keyRangeType is the kind of key range a Query is limited to.
+type keyRangeType uint8
+
+const (
+	noKeyRange keyRangeType = iota
+	onlyKeyRange
+	boundKeyRange
+	lowerKeyRange
+	upperKeyRange
+)
+
+// Query describes a read of an [idb.ObjectStore], optionally through one of its
+// indexes and limited to a key range. A Query is built by chaining its methods
+// on the value returned by NewQuery and is run with GetAll, GetAllKeys, Count,
+// or Iter. Each method returns a copy, so a Query can be reused as the base of
+// several others.
+//
+// Example:
+//
+//	msgs, err := NewQuery("messages").
+//		Index("timestamp_index").
+//		Bound(start, end, false, true).
+//		Direction(idb.CursorPrevious).
+//		Limit(50).
+//		GetAll(db)
+type Query struct {
+	objectStoreName string
+	indexName       string
+
+	rangeType            keyRangeType
+	lower, upper         js.Value
+	lowerOpen, upperOpen bool
+
+	direction idb.CursorDirection
+	offset    uint
+	limit     uint
+}
+
+// NewQuery returns a Query over every value in the given [idb.ObjectStore] in
+// ascending order of primary key.
+func NewQuery(objectStoreName string) Query {
+	return Query{objectStoreName: objectStoreName, direction: idb.CursorNext}
+}
+
+// Index returns a copy of the Query that reads through the given [idb.Index].
+// Key ranges and the direction then apply to the index key.
+func (q Query) Index(indexName string) Query {
+	q.indexName = indexName
+	return q
+}
+
+// Only returns a copy of the Query limited to values with the key.
+func (q Query) Only(key js.Value) Query {
+	q.rangeType, q.lower, q.upper = onlyKeyRange, key, key
+	return q
+}
+
+// Bound returns a copy of the Query limited to values with a key between lower
+// and upper. If lowerOpen or upperOpen is true, values with a key equal to that
+// bound are excluded.
+func (q Query) Bound(lower, upper js.Value, lowerOpen, upperOpen bool) Query {
+	q.rangeType, q.lower, q.upper = boundKeyRange, lower, upper
+	q.lowerOpen, q.upperOpen = lowerOpen, upperOpen
+	return q
+}
+
+// LowerBound returns a copy of the Query limited to values with a key greater
+// than lower, or equal to it if open is false.
+func (q Query) LowerBound(lower js.Value, open bool) Query {
+	q.rangeType, q.lower, q.lowerOpen = lowerKeyRange, lower, open
+	return q
+}
+
+// UpperBound returns a copy of the Query limited to values with a key less
+// than upper, or equal to it if open is false.
+func (q Query) UpperBound(upper js.Value, open bool) Query {
+	q.rangeType, q.upper, q.upperOpen = upperKeyRange, upper, open
+	return q
+}
+
+// Direction returns a copy of the Query that iterates in the given direction.
+func (q Query) Direction(direction idb.CursorDirection) Query {
+	q.direction = direction
+	return q
+}
+
+// Offset returns a copy of the Query that skips the first n matching values.
+func (q Query) Offset(n uint) Query {
+	q.offset = n
+	return q
+}
+
+// Limit returns a copy of the Query that returns at most n values. A limit of
+// zero means no limit.
+func (q Query) Limit(n uint) Query {
+	q.limit = n
+	return q
+}
+
+// GetAll returns every value matching the Query.
+func (q Query) GetAll(db *idb.Database) ([]js.Value, error) {
+	var result []js.Value
+	err := Retry(func() error {
+		result = make([]js.Value, 0)
+		return q.Iter(db, func(value js.Value) error {
+			result = append(result, value)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetAllKeys returns the primary key of every value matching the Query without
+// loading the values.
+func (q Query) GetAllKeys(db *idb.Database) ([]js.Value, error) {
+	var result []js.Value
+	err := Retry(func() (err error) {
+		result, err = q.getAllKeys(db)
+		return err
+	})
+	return result, err
+}
+
+// getAllKeys performs a single attempt of GetAllKeys.
+func (q Query) getAllKeys(db *idb.Database) ([]js.Value, error) {
+	parentErr := q.errorf("GetAllKeys")
+
+	source, err := q.open(db)
+	if err != nil {
+		return nil, errors.WithMessage(parentErr, err.Error())
+	}
+	keyRange, err := q.keyRange()
+	if err != nil {
+		return nil, errors.WithMessage(parentErr, err.Error())
+	}
+
+	// Set up the operation
+	var cursorRequest *idb.CursorRequest
+	switch s := source.(type) {
+	case *idb.Index:
+		if keyRange == nil {
+			cursorRequest, err = s.OpenKeyCursor(q.direction)
+		} else {
+			cursorRequest, err = s.OpenKeyCursorRange(keyRange, q.direction)
+		}
+	case *idb.ObjectStore:
+		if keyRange == nil {
+			cursorRequest, err = s.OpenKeyCursor(q.direction)
+		} else {
+			cursorRequest, err = s.OpenKeyCursorRange(keyRange, q.direction)
+		}
+	}
+	if err != nil {
+		return nil, errors.WithMessagef(parentErr,
+			"Unable to open Cursor: %+v", err)
+	}
+
+	// Perform the operation
+	result := make([]js.Value, 0)
+	it := q.newIterator()
+	err = SendKeyCursorRequest(cursorRequest, func(cursor *idb.Cursor) error {
+		return it.next(cursor, func() error {
+			key, err := cursor.PrimaryKey()
+			if err != nil {
+				return err
+			}
+			result = append(result, key)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, errors.WithMessage(parentErr, err.Error())
+	}
+	return result, nil
+}
+
+// Count returns the number of values matching the Query, after the offset and
+// limit are applied, without loading the values.
+func (q Query) Count(db *idb.Database) (int, error) {
+	var count int
+	err := Retry(func() (err error) {
+		count, err = q.count(db)
+		return err
+	})
+	return count, err
+}
+
+// count performs a single attempt of Count.
+func (q Query) count(db *idb.Database) (int, error) {
+	parentErr := q.errorf("Count")
+
+	source, err := q.open(db)
+	if err != nil {
+		return 0, errors.WithMessage(parentErr, err.Error())
+	}
+	keyRange, err := q.keyRange()
+	if err != nil {
+		return 0, errors.WithMessage(parentErr, err.Error())
+	}
+
+	// Set up the operation
+	var request *idb.UintRequest
+	switch s := source.(type) {
+	case *idb.Index:
+		if keyRange == nil {
+			request, err = s.Count()
+		} else {
+			request, err = s.CountRange(keyRange)
+		}
+	case *idb.ObjectStore:
+		if keyRange == nil {
+			request, err = s.Count()
+		} else {
+			request, err = s.CountRange(keyRange)
+		}
+	}
+	if err != nil {
+		return 0, errors.WithMessagef(parentErr,
+			"Unable to count values: %+v", err)
+	}
+
+	// Perform the operation
+	ctx, cancel := NewContext()
+	defer cancel()
+	count, err := request.Await(ctx)
+	if err != nil {
+		return 0, errors.WithMessagef(parentErr,
+			"Unable to count values: %+v", handleError(db, err))
+	}
+	return int(pageSize(count, q.offset, q.limit)), nil
+}
+
+// Iter calls fn with every value matching the Query in order. Iteration stops
+// early without error if fn returns [idb.ErrCursorStopIter]. Any other error
+// stops iteration and is returned. Unlike the other methods, Iter is not
+// retried on a transient error because fn may have already been called.
+//
+// The values are read in a single transaction, so fn must not wait on other
+// transactions, timers, or channels.
+func (q Query) Iter(db *idb.Database, fn func(value js.Value) error) error {
+	parentErr := q.errorf("Iter")
+
+	source, err := q.open(db)
+	if err != nil {
+		return errors.WithMessage(parentErr, err.Error())
+	}
+	keyRange, err := q.keyRange()
+	if err != nil {
+		return errors.WithMessage(parentErr, err.Error())
+	}
+
+	// Set up the operation
+	var cursorRequest *idb.CursorWithValueRequest
+	switch s := source.(type) {
+	case *idb.Index:
+		if keyRange == nil {
+			cursorRequest, err = s.OpenCursor(q.direction)
+		} else {
+			cursorRequest, err = s.OpenCursorRange(keyRange, q.direction)
+		}
+	case *idb.ObjectStore:
+		if keyRange == nil {
+			cursorRequest, err = s.OpenCursor(q.direction)
+		} else {
+			cursorRequest, err = s.OpenCursorRange(keyRange, q.direction)
+		}
+	}
+	if err != nil {
+		return errors.WithMessagef(parentErr,
+			"Unable to open Cursor: %+v", err)
+	}
+
+	// Perform the operation
+	it := q.newIterator()
+	err = SendCursorRequest(cursorRequest,
+		func(cursor *idb.CursorWithValue) error {
+			return it.next(cursor.Cursor, func() error {
+				value, err := cursor.Value()
+				if err != nil {
+					return err
+				}
+				return fn(value)
+			})
+		})
+	if err != nil {
+		return errors.WithMessage(parentErr, err.Error())
+	}
+	return nil
+}
+
+// open creates a read-only transaction on the object store of the Query and
+// returns the [idb.ObjectStore] or [idb.Index] to read from.
+func (q Query) open(db *idb.Database) (any, error) {
+	txn, err := newTransaction(db, idb.TransactionReadOnly, q.objectStoreName)
+	if err != nil {
+		return nil, errors.Errorf("Unable to create Transaction: %+v", err)
+	}
+	store, err := txn.ObjectStore(q.objectStoreName)
+	if err != nil {
+		return nil, errors.Errorf("Unable to get ObjectStore: %+v", err)
+	}
+	if q.indexName == "" {
+		return store, nil
+	}
+	idx, err := store.Index(q.indexName)
+	if err != nil {
+		return nil, errors.Errorf("Unable to get Index: %+v", err)
+	}
+	return idx, nil
+}
+
+// keyRange returns the [idb.KeyRange] the Query is limited to or nil if it is
+// not limited.
+func (q Query) keyRange() (keyRange *idb.KeyRange, err error) {
+	switch q.rangeType {
+	case onlyKeyRange:
+		keyRange, err = idb.NewKeyRangeOnly(q.lower)
+	case boundKeyRange:
+		keyRange, err = idb.NewKeyRangeBound(
+			q.lower, q.upper, q.lowerOpen, q.upperOpen)
+	case lowerKeyRange:
+		keyRange, err = idb.NewKeyRangeLowerBound(q.lower, q.lowerOpen)
+	case upperKeyRange:
+		keyRange, err = idb.NewKeyRangeUpperBound(q.upper, q.upperOpen)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Errorf("Unable to create KeyRange: %+v", err)
+	}
+	return keyRange, nil
+}
+
+// errorf returns the parent error for the named operation of the Query.
+func (q Query) errorf(op string) error {
+	if q.indexName == "" {
+		return errors.Errorf("failed to query %s: %s", q.objectStoreName, op)
+	}
+	return errors.Errorf("failed to query %s/%s: %s",
+		q.objectStoreName, q.indexName, op)
+}
+
+// queryIterator applies the offset and limit of a Query to a cursor.
+type queryIterator struct {
+	skip      uint
+	remaining uint
+	unlimited bool
+}
+
+// newIterator returns a new queryIterator for the offset and limit of the
+// Query.
+func (q Query) newIterator() *queryIterator {
+	return &queryIterator{
+		skip:      q.offset,
+		remaining: q.limit,
+		unlimited: q.limit == 0,
+	}
+}
+
+// next handles the current position of the cursor. On the first call, if there
+// is an offset, the cursor is advanced past it instead of calling deliver.
+// Otherwise, deliver is called and iteration stops once the limit is reached.
+func (it *queryIterator) next(cursor *idb.Cursor, deliver func() error) error {
+	if it.skip > 0 {
+		skip := it.skip
+		it.skip = 0
+		return cursor.Advance(skip)
+	}
+
+	if err := deliver(); err != nil {
+		return err
+	}
+
+	if !it.unlimited {
+		it.remaining--
+		if it.remaining == 0 {
+			return idb.ErrCursorStopIter
+		}
+	}
+	return nil
+}
+
+// pageSize returns the number of values left out of total after skipping
+// offset values and taking at most limit values. A limit of zero means no
+// limit.
+func pageSize(total, offset, limit uint) uint {
+	if offset >= total {
+		return 0
+	}
+	total -= offset
+	if limit != 0 && limit < total {
+		return limit
+	}
+	return total
+}
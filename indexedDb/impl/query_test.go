@@ -0,0 +1,219 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"reflect"
+	"syscall/js"
+	"testing"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+)
+
+// newQueryTestDB creates a database with an object store "values" keyed on
+// "id" with an index on "group". It is filled with the IDs 0 to 9, where even
+// IDs are in group "even" and odd IDs are in group "odd".
+func newQueryTestDB(name string, t *testing.T) *idb.Database {
+	ctx, cancel := NewContext()
+	defer cancel()
+	openRequest, err := idb.Global().Open(ctx, name, 1,
+		func(db *idb.Database, _ uint, _ uint) error {
+			store, err := db.CreateObjectStore("values",
+				idb.ObjectStoreOptions{KeyPath: js.ValueOf("id")})
+			if err != nil {
+				return err
+			}
+			_, err = store.CreateIndex("group",
+				js.ValueOf("group"), idb.IndexOptions{})
+			return err
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openRequest.Await(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		group := "even"
+		if i%2 == 1 {
+			group = "odd"
+		}
+		_, err = Put(db, "values",
+			js.ValueOf(map[string]any{"id": i, "group": group}))
+		if err != nil {
+			t.Fatalf("Failed to put value %d: %+v", i, err)
+		}
+	}
+	return db
+}
+
+// queryIDs returns the "id" of each value or, for keys, the key itself.
+func queryIDs(values []js.Value) []int {
+	ids := make([]int, len(values))
+	for i, v := range values {
+		if v.Type() == js.TypeObject {
+			v = v.Get("id")
+		}
+		ids[i] = v.Int()
+	}
+	return ids
+}
+
+// Tests that Query.GetAll returns the expected values for key ranges,
+// indexes, directions, offsets, and limits.
+func TestQuery_GetAll(t *testing.T) {
+	db := newQueryTestDB("TestQuery_GetAll", t)
+	q := NewQuery("values")
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected []int
+	}{
+		{"all", q, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"only", q.Only(js.ValueOf(3)), []int{3}},
+		{"bound", q.Bound(js.ValueOf(2), js.ValueOf(5), false, false),
+			[]int{2, 3, 4, 5}},
+		{"boundOpen", q.Bound(js.ValueOf(2), js.ValueOf(5), true, true),
+			[]int{3, 4}},
+		{"lower", q.LowerBound(js.ValueOf(7), false), []int{7, 8, 9}},
+		{"lowerOpen", q.LowerBound(js.ValueOf(7), true), []int{8, 9}},
+		{"upper", q.UpperBound(js.ValueOf(2), false), []int{0, 1, 2}},
+		{"upperOpen", q.UpperBound(js.ValueOf(2), true), []int{0, 1}},
+		{"reverse", q.Direction(idb.CursorPrevious).Limit(3), []int{9, 8, 7}},
+		{"offset", q.Offset(8), []int{8, 9}},
+		{"offsetPastEnd", q.Offset(20), []int{}},
+		{"limit", q.Limit(2), []int{0, 1}},
+		{"page", q.Offset(4).Limit(3), []int{4, 5, 6}},
+		{"index", q.Index("group").Only(js.ValueOf("odd")),
+			[]int{1, 3, 5, 7, 9}},
+		{"indexPage", q.Index("group").Only(js.ValueOf("even")).
+			Direction(idb.CursorPrevious).Offset(1).Limit(2), []int{6, 4}},
+	}
+
+	for _, tt := range tests {
+		values, err := tt.query.GetAll(db)
+		if err != nil {
+			t.Errorf("Failed to run query %q: %+v", tt.name, err)
+			continue
+		}
+		if ids := queryIDs(values); !reflect.DeepEqual(tt.expected, ids) {
+			t.Errorf("Unexpected values for query %q."+
+				"\nexpected: %v\nreceived: %v", tt.name, tt.expected, ids)
+		}
+	}
+}
+
+// Tests that Query.GetAllKeys returns the primary keys of the values, even
+// when querying through an index.
+func TestQuery_GetAllKeys(t *testing.T) {
+	db := newQueryTestDB("TestQuery_GetAllKeys", t)
+
+	keys, err := NewQuery("values").Index("group").Only(js.ValueOf("odd")).
+		Offset(1).Limit(3).GetAllKeys(db)
+	if err != nil {
+		t.Fatalf("Failed to get keys: %+v", err)
+	}
+
+	expected := []int{3, 5, 7}
+	if ids := queryIDs(keys); !reflect.DeepEqual(expected, ids) {
+		t.Errorf("Unexpected keys.\nexpected: %v\nreceived: %v", expected, ids)
+	}
+}
+
+// Tests that Query.Count returns the number of matching values with the offset
+// and limit applied.
+func TestQuery_Count(t *testing.T) {
+	db := newQueryTestDB("TestQuery_Count", t)
+	q := NewQuery("values")
+
+	tests := []struct {
+		query    Query
+		expected int
+	}{
+		{q, 10},
+		{q.Index("group").Only(js.ValueOf("even")), 5},
+		{q.LowerBound(js.ValueOf(5), true), 4},
+		{q.Offset(3).Limit(4), 4},
+		{q.Offset(8).Limit(4), 2},
+		{q.Offset(20), 0},
+	}
+
+	for i, tt := range tests {
+		count, err := tt.query.Count(db)
+		if err != nil {
+			t.Errorf("Failed to count (%d): %+v", i, err)
+		} else if count != tt.expected {
+			t.Errorf("Unexpected count (%d).\nexpected: %d\nreceived: %d",
+				i, tt.expected, count)
+		}
+	}
+}
+
+// Tests that Query.Iter stops without error when the function returns
+// idb.ErrCursorStopIter.
+func TestQuery_Iter_Stop(t *testing.T) {
+	db := newQueryTestDB("TestQuery_Iter_Stop", t)
+
+	var ids []int
+	err := NewQuery("values").Iter(db, func(value js.Value) error {
+		ids = append(ids, value.Get("id").Int())
+		if len(ids) == 3 {
+			return idb.ErrCursorStopIter
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to iterate: %+v", err)
+	}
+
+	expected := []int{0, 1, 2}
+	if !reflect.DeepEqual(expected, ids) {
+		t.Errorf("Unexpected values.\nexpected: %v\nreceived: %v",
+			expected, ids)
+	}
+}
+
+// Tests that each method of Query returns a copy and does not modify the Query
+// it is called on.
+func TestQuery_Copy(t *testing.T) {
+	q := NewQuery("values")
+	_ = q.Index("group").Only(js.ValueOf(1)).Offset(2).Limit(3).
+		Direction(idb.CursorPrevious)
+
+	if !reflect.DeepEqual(NewQuery("values"), q) {
+		t.Errorf("Query was modified.\nexpected: %+v\nreceived: %+v",
+			NewQuery("values"), q)
+	}
+}
+
+// Tests that pageSize returns the number of values left after the offset and
+// limit are applied.
+func Test_pageSize(t *testing.T) {
+	tests := []struct{ total, offset, limit, expected uint }{
+		{10, 0, 0, 10},
+		{10, 3, 0, 7},
+		{10, 3, 5, 5},
+		{10, 8, 5, 2},
+		{10, 10, 5, 0},
+		{10, 15, 0, 0},
+		{0, 0, 5, 0},
+	}
+
+	for _, tt := range tests {
+		if size := pageSize(tt.total, tt.offset, tt.limit); size != tt.expected {
+			t.Errorf("Unexpected size for total %d, offset %d, limit %d."+
+				"\nexpected: %d\nreceived: %d",
+				tt.total, tt.offset, tt.limit, tt.expected, size)
+		}
+	}
+}
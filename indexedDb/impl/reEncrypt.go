@@ -0,0 +1,99 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"syscall/js"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// reEncryptBatchSize is the number of values re-encrypted in each transaction.
+// Values are re-encrypted in small batches so that the object store is not
+// locked from other reads and writes for the duration of the re-encryption.
+const reEncryptBatchSize = 100
+
+// ReEncryptFunc re-encrypts a single value from the named object store with
+// the current key. It returns the new value and true if the value changed.
+type ReEncryptFunc func(objectStoreName string, value js.Value) (
+	newValue js.Value, changed bool, err error)
+
+// ReEncryptStores re-encrypts every value in each of the object stores with the
+// current key of the [KeyRing], one batch at a time. After each batch, the
+// progress is reported to the report function. Once every store is complete, a
+// final KeyRotationProgressJSON with Complete set is reported.
+//
+// Re-encryption is resumable; values that were already re-encrypted are
+// skipped by reEncrypt so that an interrupted re-encryption can be restarted
+// from the beginning.
+func ReEncryptStores(db *idb.Database, kr *KeyRing, objectStoreNames []string,
+	reEncrypt ReEncryptFunc, report func(progress KeyRotationProgressJSON)) {
+	databaseName, err := db.Name()
+	if err != nil {
+		jww.ERROR.Printf("Failed to get database name: %+v", err)
+	}
+	progress := KeyRotationProgressJSON{
+		DatabaseName: databaseName,
+		KeyVersion:   kr.Current(),
+	}
+
+	for _, storeName := range objectStoreNames {
+		progress.Store, progress.Done = storeName, 0
+		err = reEncryptStore(db, storeName, reEncrypt, &progress, report)
+		if err != nil {
+			jww.ERROR.Printf("[KEY ROTATION] Failed to re-encrypt %s/%s with "+
+				"key %d: %+v", databaseName, storeName, progress.KeyVersion, err)
+			progress.Error = err.Error()
+			report(progress)
+			return
+		}
+	}
+
+	jww.INFO.Printf("[KEY ROTATION] Re-encrypted %s with key %d",
+		databaseName, progress.KeyVersion)
+	progress.Store, progress.Done, progress.Total = "", 0, 0
+	progress.Complete = true
+	report(progress)
+}
+
+// reEncryptStore re-encrypts every value in the object store in batches,
+// reporting progress after each batch.
+func reEncryptStore(db *idb.Database, storeName string,
+	reEncrypt ReEncryptFunc, progress *KeyRotationProgressJSON,
+	report func(progress KeyRotationProgressJSON)) error {
+	total, err := Count(db, storeName)
+	if err != nil {
+		return err
+	}
+	progress.Total = total
+
+	last := js.Undefined()
+	for {
+		var numVisited int
+		last, _, err = UpdateBatch(db, storeName, last, reEncryptBatchSize,
+			func(value js.Value) (js.Value, bool, error) {
+				numVisited++
+				return reEncrypt(storeName, value)
+			})
+		if err != nil {
+			return errors.WithMessagef(err,
+				"failed after %d of %d values", progress.Done, total)
+		}
+
+		progress.Done += numVisited
+		report(*progress)
+
+		if last.IsUndefined() {
+			return nil
+		}
+	}
+}
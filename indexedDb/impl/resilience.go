@@ -0,0 +1,471 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall/js"
+	"time"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+const (
+	// DefaultTimeout is the default timeout for operations with the storage
+	// [context.Context].
+	DefaultTimeout = time.Second
+
+	// ErrQuotaExceeded is the name of the DOMException thrown when the storage
+	// quota of the origin is exceeded. It is included in the message of every
+	// error caused by it.
+	ErrQuotaExceeded = "QuotaExceededError"
+
+	// ErrDatabaseClosed is an error string for operations on a database whose
+	// connection was closed because another tab upgraded its schema.
+	ErrDatabaseClosed = "database connection is closed"
+
+	// quotaEventInterval is the minimum time between QuotaExceeded events for
+	// the same database so that a burst of failed writes sends one event.
+	quotaEventInterval = 30 * time.Second
+)
+
+// transientErrors are the names of the DOMExceptions that may succeed if the
+// operation is retried.
+//
+// Timeouts of the storage context are not included because the request may
+// still complete after the context is done, so retrying a write could apply it
+// twice. Neither is TransactionInactiveError, since requests made before the
+// transaction became inactive may already have been committed, and retrying
+// would insert auto-incremented values a second time.
+var transientErrors = []string{
+	"TimeoutError",
+	"UnknownError",
+}
+
+// timeout is the timeout for operations with the storage [context.Context]
+// stored as a [time.Duration].
+var timeout = func() *atomic.Int64 {
+	var t atomic.Int64
+	t.Store(int64(DefaultTimeout))
+	return &t
+}()
+
+// SetTimeout sets the timeout for each operation with the storage. A timeout
+// of zero or less restores DefaultTimeout.
+func SetTimeout(d time.Duration) {
+	if d <= 0 {
+		d = DefaultTimeout
+	}
+	timeout.Store(int64(d))
+}
+
+// Timeout returns the timeout for each operation with the storage.
+func Timeout() time.Duration {
+	return time.Duration(timeout.Load())
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Retry                                                                      //
+////////////////////////////////////////////////////////////////////////////////
+
+// RetryPolicy describes how operations that fail with a transient error are
+// retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times an operation is attempted,
+	// including the first. A value of one or less disables retries.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry. Each following retry
+	// waits twice as long as the one before.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the longest wait between two attempts.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy returns the default RetryPolicy.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 50 * time.Millisecond,
+		MaxBackoff:     time.Second,
+	}
+}
+
+var (
+	retryPolicy    = DefaultRetryPolicy()
+	retryPolicyMux sync.RWMutex
+)
+
+// SetRetryPolicy sets the RetryPolicy used by every operation with the
+// storage.
+func SetRetryPolicy(p RetryPolicy) {
+	retryPolicyMux.Lock()
+	defer retryPolicyMux.Unlock()
+	retryPolicy = p
+}
+
+// GetRetryPolicy returns the RetryPolicy used by every operation with the
+// storage.
+func GetRetryPolicy() RetryPolicy {
+	retryPolicyMux.RLock()
+	defer retryPolicyMux.RUnlock()
+	return retryPolicy
+}
+
+// StorageParams sets the timeout and retries of the storage operations of a
+// database worker. Fields that are zero keep the value the worker was started
+// with.
+//
+// Example JSON:
+//
+//	{
+//	  "TimeoutMS": 2000,
+//	  "MaxAttempts": 5
+//	}
+type StorageParams struct {
+	// TimeoutMS is the timeout, in milliseconds, of each storage operation.
+	TimeoutMS int64 `json:"TimeoutMS"`
+
+	// MaxAttempts is the maximum number of times an operation is attempted
+	// when it fails with a transient error. 1 disables retries.
+	MaxAttempts int `json:"MaxAttempts"`
+}
+
+// Apply sets the timeout and RetryPolicy of the storage to the non-zero
+// fields of the StorageParams.
+func (p StorageParams) Apply() {
+	if p.TimeoutMS > 0 {
+		SetTimeout(time.Duration(p.TimeoutMS) * time.Millisecond)
+	}
+	if p.MaxAttempts > 0 {
+		policy := GetRetryPolicy()
+		policy.MaxAttempts = p.MaxAttempts
+		SetRetryPolicy(policy)
+	}
+}
+
+// backoff returns the wait before the given retry, where the first retry is
+// one.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < retry && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// Retry calls fn until it succeeds, returns an error that is not transient, or
+// the RetryPolicy runs out of attempts. Returns the last error from fn.
+//
+// fn must be safe to call more than once.
+func Retry(fn func() error) error {
+	p := GetRetryPolicy()
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || !IsTransient(err) ||
+			attempt >= p.MaxAttempts {
+			return err
+		}
+
+		wait := p.backoff(attempt)
+		jww.DEBUG.Printf("Retrying storage operation in %s after transient "+
+			"error (attempt %d of %d): %+v", wait, attempt, p.MaxAttempts, err)
+		time.Sleep(wait)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Errors                                                                     //
+////////////////////////////////////////////////////////////////////////////////
+
+// DOMError is an error thrown by IndexedDB as a DOMException. Its message
+// includes the name of the exception so that it can still be identified once
+// the error is flattened into the message of another error.
+type DOMError struct {
+	Name string
+	Err  error
+}
+
+// Error returns the name and message of the DOMException.
+func (e *DOMError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying [js.Error].
+func (e *DOMError) Unwrap() error { return e.Err }
+
+// QuotaExceededError is returned when a write fails because the storage quota
+// of the origin is exceeded. Its message always contains ErrQuotaExceeded.
+type QuotaExceededError struct {
+	Err error
+}
+
+// Error returns the message of the underlying error prefixed with
+// ErrQuotaExceeded.
+func (e *QuotaExceededError) Error() string {
+	return ErrQuotaExceeded + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *QuotaExceededError) Unwrap() error { return e.Err }
+
+// IsQuotaExceeded returns true if the error was caused by the storage quota
+// being exceeded.
+func IsQuotaExceeded(err error) bool {
+	if err == nil {
+		return false
+	}
+	var quotaErr *QuotaExceededError
+	return errors.As(err, &quotaErr) ||
+		strings.Contains(err.Error(), ErrQuotaExceeded)
+}
+
+// IsTransient returns true if the error is one where retrying the operation
+// may succeed.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	errStr := err.Error()
+	for _, name := range transientErrors {
+		if strings.Contains(errStr, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyError converts a [js.Error] holding a DOMException into a
+// QuotaExceededError or DOMError. Any other error is returned unchanged.
+func classifyError(err error) error {
+	var jsErr js.Error
+	if err == nil || !errors.As(err, &jsErr) ||
+		jsErr.Value.Type() != js.TypeObject {
+		return err
+	}
+
+	name := jsErr.Value.Get("name")
+	if name.Type() != js.TypeString {
+		return err
+	}
+	switch name.String() {
+	case ErrQuotaExceeded:
+		return &QuotaExceededError{err}
+	default:
+		return &DOMError{Name: name.String(), Err: err}
+	}
+}
+
+// handleError classifies the error from an operation on the database and
+// notifies the watcher of the database if the quota was exceeded. If the
+// connection to the database was closed, the returned error says so.
+func handleError(db *idb.Database, err error) error {
+	if err == nil {
+		return nil
+	}
+	err = classifyError(err)
+
+	if db == nil {
+		return err
+	}
+	w, exists := getWatcher(db)
+	if !exists {
+		return err
+	}
+	if w.isClosed() {
+		return errors.Wrap(err, ErrDatabaseClosed)
+	} else if IsQuotaExceeded(err) {
+		w.quotaExceeded(err)
+	}
+	return err
+}
+
+// newTransaction creates a transaction on the database, reporting a closed
+// connection if it fails.
+func newTransaction(db *idb.Database, mode idb.TransactionMode,
+	objectStoreName string, objectStoreNames ...string) (
+	*idb.Transaction, error) {
+	txn, err := db.Transaction(mode, objectStoreName, objectStoreNames...)
+	if err != nil {
+		return nil, handleError(db, err)
+	}
+	return txn, nil
+}
+
+// requestDatabase returns the database the request was made on or nil if it is
+// not made in a transaction.
+func requestDatabase(request *idb.Request) *idb.Database {
+	txn, err := request.Transaction()
+	if err != nil {
+		return nil
+	}
+	db, _ := txn.Database()
+	return db
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Connection Events                                                          //
+////////////////////////////////////////////////////////////////////////////////
+
+// watchers maps each open *idb.Database to its *watcher.
+var watchers sync.Map
+
+// watcher tracks the state of a database connection opened by Open and sends
+// its events to the event callback.
+type watcher struct {
+	name          string
+	eventCallback func(eventType int64, jsonMarshallable any)
+	closed        atomic.Bool
+
+	lastQuotaEvent time.Time
+	mux            sync.Mutex
+}
+
+// getWatcher returns the watcher of the database, if it was opened with Open.
+func getWatcher(db *idb.Database) (*watcher, bool) {
+	w, exists := watchers.Load(db)
+	if !exists {
+		return nil, false
+	}
+	return w.(*watcher), true
+}
+
+// isClosed returns true if the connection was closed on a version change.
+func (w *watcher) isClosed() bool {
+	return w.closed.Load()
+}
+
+// sendEvent calls the event callback, if one is set.
+func (w *watcher) sendEvent(eventType int64, jsonMarshallable any) {
+	w.mux.Lock()
+	cb := w.eventCallback
+	w.mux.Unlock()
+	if cb != nil {
+		go cb(eventType, jsonMarshallable)
+	}
+}
+
+// quotaExceeded sends a QuotaExceeded event, unless one was sent within
+// quotaEventInterval.
+func (w *watcher) quotaExceeded(err error) {
+	w.mux.Lock()
+	if time.Since(w.lastQuotaEvent) < quotaEventInterval {
+		w.mux.Unlock()
+		return
+	}
+	w.lastQuotaEvent = time.Now()
+	w.mux.Unlock()
+
+	jww.ERROR.Printf("Storage quota exceeded for database %s: %+v",
+		w.name, err)
+	w.sendEvent(QuotaExceeded, QuotaExceededJSON{
+		DatabaseName: w.name,
+		Error:        err.Error(),
+	})
+}
+
+// Open opens the database with the given version, calling the upgrader if the
+// stored version is older. Operations on the returned database report quota
+// and closed connection errors to the event callback set with Watch.
+//
+// When another tab or worker opens the database with a newer version, the
+// connection is closed so that the upgrade is not blocked and a DatabaseClosed
+// event is sent. Every later operation fails with an error containing
+// ErrDatabaseClosed.
+func Open(databaseName string, version uint,
+	upgrader idb.Upgrader) (*idb.Database, error) {
+	ctx, cancel := NewContext()
+	defer cancel()
+
+	// The open request is only used by the upgrader once the upgradeneeded
+	// event fires, after Open returns the request
+	var openRequest *idb.OpenDBRequest
+	openRequest, err := idb.Global().Open(ctx, databaseName, version,
+		func(db *idb.Database, oldVersion, newVersion uint) error {
+			jsDB, err := openRequest.Request.Result()
+			if err != nil {
+				return err
+			}
+			upgrading.Store(db, jsDB)
+			defer upgrading.Delete(db)
+			return upgrader(db, oldVersion, newVersion)
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	// Wait for database open to finish
+	db, err := openRequest.Await(ctx)
+	if err != nil {
+		return nil, classifyError(err)
+	} else if ctx.Err() != nil {
+		return nil, errors.Errorf("timed out opening database %s; it may be "+
+			"blocked by an open connection in another tab: %+v",
+			databaseName, ctx.Err())
+	}
+
+	w := &watcher{name: databaseName}
+	watchers.Store(db, w)
+
+	// The idb package closes the connection on a version change; this listener
+	// records it and notifies the caller
+	jsDB, err := openRequest.Request.Result()
+	if err != nil {
+		return nil, err
+	}
+	jsDB.Call("addEventListener", "versionchange",
+		js.FuncOf(func(_ js.Value, args []js.Value) any {
+			var newVersion uint
+			if len(args) > 0 && args[0].Get("newVersion").Type() == js.TypeNumber {
+				newVersion = uint(args[0].Get("newVersion").Int())
+			}
+			jww.WARN.Printf("Database %s upgraded to v%d by another "+
+				"connection; closing connection", databaseName, newVersion)
+			jsDB.Call("close")
+			w.closed.Store(true)
+			w.sendEvent(DatabaseClosed, DatabaseClosedJSON{
+				DatabaseName: databaseName,
+				OldVersion:   version,
+				NewVersion:   newVersion,
+			})
+			return nil
+		}))
+
+	return db, nil
+}
+
+// Watch sets the callback that receives the QuotaExceeded and DatabaseClosed
+// events of a database opened with Open.
+func Watch(db *idb.Database,
+	eventCallback func(eventType int64, jsonMarshallable any)) {
+	w, exists := getWatcher(db)
+	if !exists {
+		jww.WARN.Printf("Cannot watch database not opened with impl.Open")
+		return
+	}
+	w.mux.Lock()
+	w.eventCallback = eventCallback
+	w.mux.Unlock()
+}
+
+// IsClosed returns true if the connection to the database was closed because
+// another connection upgraded it.
+func IsClosed(db *idb.Database) bool {
+	w, exists := getWatcher(db)
+	return exists && w.isClosed()
+}
@@ -0,0 +1,181 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"syscall/js"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// newDOMException returns a js.Error holding a new DOMException.
+func newDOMException(message, name string) error {
+	return js.Error{
+		Value: js.Global().Get("DOMException").New(message, name)}
+}
+
+// Tests that RetryPolicy.backoff doubles the wait on each retry up to the max.
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+	}
+
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for i, exp := range expected {
+		if wait := p.backoff(i + 1); wait != exp*time.Millisecond {
+			t.Errorf("Unexpected backoff for retry %d."+
+				"\nexpected: %s\nreceived: %s", i+1, exp*time.Millisecond, wait)
+		}
+	}
+}
+
+// Tests that Retry retries transient errors until fn succeeds.
+func TestRetry(t *testing.T) {
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 3, MaxBackoff: time.Millisecond})
+	defer SetRetryPolicy(DefaultRetryPolicy())
+
+	var attempts int
+	err := Retry(func() error {
+		attempts++
+		if attempts < 3 {
+			return classifyError(newDOMException("unknown", "UnknownError"))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Failed to retry: %+v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Unexpected number of attempts.\nexpected: %d\nreceived: %d",
+			3, attempts)
+	}
+}
+
+// Error path: Tests that Retry gives up once the RetryPolicy runs out of
+// attempts and does not retry errors that are not transient.
+func TestRetry_Error(t *testing.T) {
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 2, MaxBackoff: time.Millisecond})
+	defer SetRetryPolicy(DefaultRetryPolicy())
+
+	tests := []struct {
+		err      error
+		attempts int
+	}{
+		{classifyError(newDOMException("timeout", "TimeoutError")), 2},
+		{classifyError(newDOMException("full", ErrQuotaExceeded)), 1},
+		{classifyError(
+			newDOMException("inactive", "TransactionInactiveError")), 1},
+		{errors.New(ErrDoesNotExist), 1},
+	}
+
+	for i, tt := range tests {
+		var attempts int
+		err := Retry(func() error {
+			attempts++
+			return tt.err
+		})
+		if err != tt.err {
+			t.Errorf("Unexpected error (%d).\nexpected: %v\nreceived: %v",
+				i, tt.err, err)
+		}
+		if attempts != tt.attempts {
+			t.Errorf("Unexpected number of attempts (%d)."+
+				"\nexpected: %d\nreceived: %d", i, tt.attempts, attempts)
+		}
+	}
+}
+
+// Tests that classifyError returns a QuotaExceededError for a quota DOMException
+// and that IsQuotaExceeded still detects it once it is flattened into the
+// message of another error.
+func Test_classifyError_QuotaExceeded(t *testing.T) {
+	err := classifyError(newDOMException("The quota has been exceeded.",
+		ErrQuotaExceeded))
+
+	var quotaErr *QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		t.Errorf("Unexpected error type: %T", err)
+	}
+	if !IsQuotaExceeded(err) {
+		t.Errorf("Error not detected as quota exceeded: %v", err)
+	}
+	if !IsQuotaExceeded(errors.Errorf("failed to Put: %+v", err)) {
+		t.Errorf("Wrapped error not detected as quota exceeded: %v", err)
+	}
+	if IsTransient(err) {
+		t.Errorf("Quota exceeded error detected as transient: %v", err)
+	}
+}
+
+// Tests that classifyError includes the name of other DOMExceptions in the
+// message and returns errors that are not DOMExceptions unchanged.
+func Test_classifyError(t *testing.T) {
+	err := classifyError(newDOMException("unknown", "UnknownError"))
+	var domErr *DOMError
+	if !errors.As(err, &domErr) || domErr.Name != "UnknownError" {
+		t.Errorf("Unexpected error: %#v", err)
+	}
+	if !IsTransient(errors.Errorf("failed to Get: %+v", err)) {
+		t.Errorf("Wrapped error not detected as transient: %v", err)
+	}
+
+	plainErr := errors.New("plain error")
+	if classifyError(plainErr) != plainErr {
+		t.Errorf("Error that is not a DOMException was changed.")
+	}
+}
+
+// Tests that SetTimeout changes the timeout and that a timeout of zero restores
+// the default.
+func TestSetTimeout(t *testing.T) {
+	defer SetTimeout(DefaultTimeout)
+
+	SetTimeout(5 * time.Second)
+	if Timeout() != 5*time.Second {
+		t.Errorf("Unexpected timeout.\nexpected: %s\nreceived: %s",
+			5*time.Second, Timeout())
+	}
+
+	SetTimeout(0)
+	if Timeout() != DefaultTimeout {
+		t.Errorf("Unexpected timeout.\nexpected: %s\nreceived: %s",
+			DefaultTimeout, Timeout())
+	}
+}
+
+// Tests that StorageParams.Apply sets the timeout and max attempts and that
+// zero fields leave them unchanged.
+func TestStorageParams_Apply(t *testing.T) {
+	defer SetTimeout(DefaultTimeout)
+	defer SetRetryPolicy(DefaultRetryPolicy())
+
+	StorageParams{TimeoutMS: 2500, MaxAttempts: 7}.Apply()
+	if Timeout() != 2500*time.Millisecond {
+		t.Errorf("Unexpected timeout.\nexpected: %s\nreceived: %s",
+			2500*time.Millisecond, Timeout())
+	}
+	if p := GetRetryPolicy(); p.MaxAttempts != 7 ||
+		p.InitialBackoff != DefaultRetryPolicy().InitialBackoff {
+		t.Errorf("Unexpected retry policy: %+v", p)
+	}
+
+	StorageParams{}.Apply()
+	if Timeout() != 2500*time.Millisecond {
+		t.Errorf("Timeout changed by zero params: %s", Timeout())
+	}
+	if GetRetryPolicy().MaxAttempts != 7 {
+		t.Errorf("Max attempts changed by zero params: %d",
+			GetRetryPolicy().MaxAttempts)
+	}
+}
@@ -0,0 +1,195 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+// This file contains the tokenizer used to build the full-text search index of
+// message text and to highlight search hits.
+
+package impl
+
+import (
+	"encoding/base64"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
+)
+
+// MinSearchTermLength is the minimum number of characters in a search term.
+// Shorter words are not indexed and shorter query terms are ignored.
+const MinSearchTermLength = 2
+
+// searchTokenLengths are the lengths of the prefixes of a word that are
+// indexed, in ascending order. Indexing a few buckets instead of every prefix
+// caps the number of tokens of each word. A query term is looked up by its
+// longest indexed prefix, so hits must be confirmed with SearchHighlights.
+//
+// The blind tokens of an encrypted message do not reveal its words, and a
+// guessed prefix cannot be checked against them without the cipher. They do
+// reveal the number of distinct indexed prefixes in its text, which roughly
+// tracks the number and length of its words, and which messages share a
+// prefix of one of these lengths.
+var searchTokenLengths = []int{MinSearchTermLength, 3, 4, 6, 8, 12}
+
+// Highlight is the range of a search hit in the text of a message. Start and
+// End are offsets in UTF-16 code units, so they can be used directly with
+// JavaScript string methods, and End is exclusive.
+type Highlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// searchWord is a normalized word of a text and its offset in UTF-16 code
+// units.
+type searchWord struct {
+	normalized []rune
+	offset     int
+	lengths    []int // UTF-16 length of each rune
+}
+
+// searchWords splits the text into words. A word is a run of letters, digits,
+// and combining marks. Words are normalized by lower casing each rune, which
+// keeps the runes of the normalized word aligned with the original text.
+func searchWords(text string) []searchWord {
+	var words []searchWord
+	var current *searchWord
+	var offset int
+	for _, r := range text {
+		// Runes outside the basic multilingual plane are a surrogate pair
+		length := 1
+		if r > 0xFFFF {
+			length = 2
+		}
+
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) {
+			if current == nil {
+				words = append(words, searchWord{offset: offset})
+				current = &words[len(words)-1]
+			}
+			current.normalized = append(current.normalized, unicode.ToLower(r))
+			current.lengths = append(current.lengths, length)
+		} else {
+			current = nil
+		}
+		offset += length
+	}
+	return words
+}
+
+// SearchTerms normalizes the query into its list of unique search terms in the
+// order they appear. Terms shorter than MinSearchTermLength are dropped.
+func SearchTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, word := range searchWords(query) {
+		if len(word.normalized) < MinSearchTermLength {
+			continue
+		}
+		term := string(word.normalized)
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// SearchTokens returns the sorted list of unique tokens to index for the text.
+// Each word is indexed under its prefixes with the lengths in
+// searchTokenLengths so that a prefix query is an exact lookup of the token
+// returned by SearchLookupToken.
+func SearchTokens(text string) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	for _, word := range searchWords(text) {
+		for _, n := range searchTokenLengths {
+			if n > len(word.normalized) {
+				break
+			}
+			token := string(word.normalized[:n])
+			if !seen[token] {
+				seen[token] = true
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// SearchLookupToken returns the indexed token used to look up the term. The
+// term is truncated to the longest length in searchTokenLengths that is not
+// longer than it, so hits must be confirmed with SearchHighlights.
+func SearchLookupToken(term string) string {
+	runes := []rune(term)
+	n := len(runes)
+	for i := len(searchTokenLengths) - 1; i >= 0; i-- {
+		if searchTokenLengths[i] <= len(runes) {
+			n = searchTokenLengths[i]
+			break
+		}
+	}
+	return string(runes[:n])
+}
+
+// SearchIndexKey returns the key stored in the search index for the token. If
+// the cipher is nil, it is the token itself. Otherwise, it is the base 64
+// encoded [BlindIndex] of the token so that the index does not reveal the
+// words of encrypted messages.
+func SearchIndexKey(cipher idbCrypto.Cipher, token string) (string, error) {
+	if cipher == nil {
+		return token, nil
+	}
+	blindIndex, err := BlindIndex(cipher, []byte(token))
+	if err != nil {
+		return "", errors.WithMessage(err, "failed to blind search token")
+	}
+	return base64.StdEncoding.EncodeToString(blindIndex), nil
+}
+
+// SearchHighlights returns the range of every word in the text that starts
+// with one of the terms, in order. Each range covers the matched prefix of the
+// word; when several terms match a word, the longest is used. Returns nil
+// unless every term matches at least once.
+func SearchHighlights(text string, terms []string) []Highlight {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	matched := make(map[string]bool, len(terms))
+	var highlights []Highlight
+	for _, word := range searchWords(text) {
+		normalized := string(word.normalized)
+		best := -1
+		for _, term := range terms {
+			if strings.HasPrefix(normalized, term) {
+				matched[term] = true
+				if n := len([]rune(term)); n > best {
+					best = n
+				}
+			}
+		}
+		if best < 0 {
+			continue
+		}
+
+		end := word.offset
+		for _, length := range word.lengths[:best] {
+			end += length
+		}
+		highlights = append(highlights, Highlight{word.offset, end})
+	}
+
+	if len(matched) != len(terms) {
+		return nil
+	}
+	return highlights
+}
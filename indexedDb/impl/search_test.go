@@ -0,0 +1,116 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
+	"gitlab.com/xx_network/crypto/csprng"
+)
+
+// Tests that SearchTerms lower cases the query, splits it on punctuation and
+// whitespace, and drops duplicate and short terms.
+func TestSearchTerms(t *testing.T) {
+	terms := SearchTerms("Hello, WORLD! a hello-world Ünïcode")
+	expected := []string{"hello", "world", "ünïcode"}
+	if !reflect.DeepEqual(expected, terms) {
+		t.Errorf("Unexpected terms.\nexpected: %q\nreceived: %q",
+			expected, terms)
+	}
+}
+
+// Tests that SearchTokens returns the prefixes of each word with the indexed
+// lengths and that SearchLookupToken truncates a term to an indexed prefix.
+func TestSearchTokens(t *testing.T) {
+	tokens := SearchTokens("Cat cats")
+	expected := []string{"ca", "cat", "cats"}
+	if !reflect.DeepEqual(expected, tokens) {
+		t.Errorf("Unexpected tokens.\nexpected: %q\nreceived: %q",
+			expected, tokens)
+	}
+
+	long := "abcdefghijklmnopqrstuvwxyz"
+	tokens = SearchTokens(long)
+	expected = []string{"ab", "abc", "abcd", "abcdef", "abcdefgh",
+		"abcdefghijkl"}
+	if !reflect.DeepEqual(expected, tokens) {
+		t.Errorf("Unexpected tokens for long word.\nexpected: %q\nreceived: %q",
+			expected, tokens)
+	}
+
+	for term, expectedLookup := range map[string]string{
+		"ab":      "ab",
+		"abcde":   "abcd",
+		"abcdefg": "abcdef",
+		long:      "abcdefghijkl",
+	} {
+		lookup := SearchLookupToken(term)
+		if lookup != expectedLookup {
+			t.Errorf("Unexpected lookup token for %q."+
+				"\nexpected: %q\nreceived: %q", term, expectedLookup, lookup)
+		}
+		if i := sort.SearchStrings(tokens, lookup); i == len(tokens) ||
+			tokens[i] != lookup {
+			t.Errorf("Lookup token %q for %q is not indexed.", lookup, term)
+		}
+	}
+}
+
+// Tests that SearchHighlights returns the UTF-16 range of the matched prefix of
+// each word and returns nil unless every term matches.
+func TestSearchHighlights(t *testing.T) {
+	text := "😀 Hello there, HELP!"
+	highlights := SearchHighlights(text, []string{"hel", "help"})
+	expected := []Highlight{{3, 6}, {16, 20}}
+	if !reflect.DeepEqual(expected, highlights) {
+		t.Errorf("Unexpected highlights.\nexpected: %v\nreceived: %v",
+			expected, highlights)
+	}
+
+	highlights = SearchHighlights(text, []string{"hel", "world"})
+	if highlights != nil {
+		t.Errorf("Received highlights when a term does not match: %v",
+			highlights)
+	}
+}
+
+// Tests that SearchIndexKey returns the token without a cipher and a blind
+// token that does not contain the token with a cipher.
+func TestSearchIndexKey(t *testing.T) {
+	key, err := SearchIndexKey(nil, "hello")
+	if err != nil {
+		t.Fatalf("Failed to get key: %+v", err)
+	} else if key != "hello" {
+		t.Errorf("Unexpected key.\nexpected: %q\nreceived: %q", "hello", key)
+	}
+
+	cipher, err := idbCrypto.NewCipher(
+		[]byte("testPassword"), []byte("testSalt"), 1024, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+	blind, err := SearchIndexKey(cipher, "hello")
+	if err != nil {
+		t.Fatalf("Failed to get blind key: %+v", err)
+	} else if blind == "hello" {
+		t.Error("Blind key is the plaintext token.")
+	}
+
+	blind2, err := SearchIndexKey(cipher, "hello")
+	if err != nil {
+		t.Fatalf("Failed to get blind key: %+v", err)
+	} else if blind != blind2 {
+		t.Errorf("Blind key is not deterministic.\nfirst: %s\nsecond: %s",
+			blind, blind2)
+	}
+}
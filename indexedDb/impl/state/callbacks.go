@@ -32,6 +32,12 @@ func (m *manager) registerCallbacks() {
 	m.wtm.RegisterCallback(stateWorker.NewStateTag, m.newStateCB)
 	m.wtm.RegisterCallback(stateWorker.SetTag, m.setCB)
 	m.wtm.RegisterCallback(stateWorker.GetTag, m.getCB)
+	m.wtm.RegisterCallback(stateWorker.DeleteTag, m.deleteCB)
+	m.wtm.RegisterCallback(stateWorker.KeysTag, m.keysCB)
+	m.wtm.RegisterCallback(stateWorker.ClearTag, m.clearCB)
+	m.wtm.RegisterCallback(stateWorker.GetManyTag, m.getManyCB)
+	m.wtm.RegisterCallback(stateWorker.SetManyTag, m.setManyCB)
+	m.wtm.RegisterCallback(stateWorker.CompareAndSwapTag, m.compareAndSwapCB)
 }
 
 // newStateCB is the callback for NewState. Returns an empty
@@ -51,9 +57,26 @@ func (m *manager) newStateCB(message []byte, reply func(message []byte)) {
 		return
 	}
 
+	// Forward every change to the main thread, which calls its own watchers
+	m.model.Watch("", m.stateChangeCB)
+
 	reply(nil)
 }
 
+// stateChangeCB sends the changes to the main thread.
+func (m *manager) stateChangeCB(changes []impl.StateChange) {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		exception.Throwf("Could not JSON marshal %T for %s: %+v",
+			changes, stateWorker.StateChangeTag, err)
+	}
+	err = m.wtm.SendNoResponse(stateWorker.StateChangeTag, data)
+	if err != nil {
+		exception.Throwf("Could not send %s to main thread: %+v",
+			stateWorker.StateChangeTag, err)
+	}
+}
+
 // setCB is the callback for stateModel.Set.
 // Returns nil on error or the resulting byte data on success.
 func (m *manager) setCB(message []byte, reply func(message []byte)) {
@@ -82,7 +105,9 @@ func (m *manager) getCB(message []byte, reply func(message []byte)) {
 	msg := stateWorker.TransferMessage{
 		Key:   key,
 		Value: result,
-		Error: err.Error(),
+	}
+	if err != nil {
+		msg.Error = err.Error()
 	}
 
 	replyMessage, err := json.Marshal(msg)
@@ -92,3 +117,114 @@ func (m *manager) getCB(message []byte, reply func(message []byte)) {
 
 	reply(replyMessage)
 }
+
+// deleteCB is the callback for stateModel.Delete. Returns nil on success or an
+// error message on failure.
+func (m *manager) deleteCB(message []byte, reply func(message []byte)) {
+	err := m.model.Delete(string(message))
+	if err != nil {
+		reply([]byte(err.Error()))
+		return
+	}
+
+	reply(nil)
+}
+
+// keysCB is the callback for stateModel.Keys. Returns the JSON of a
+// [stateWorker.KeysReply].
+func (m *manager) keysCB(message []byte, reply func(message []byte)) {
+	keys, err := m.model.Keys(string(message))
+	msg := stateWorker.KeysReply{Keys: keys}
+	if err != nil {
+		msg.Error = err.Error()
+	}
+
+	replyMessage, err := json.Marshal(msg)
+	if err != nil {
+		exception.Throwf("Could not JSON marshal %T for Keys: %+v", msg, err)
+	}
+
+	reply(replyMessage)
+}
+
+// clearCB is the callback for stateModel.Clear. Returns nil on success or an
+// error message on failure.
+func (m *manager) clearCB(_ []byte, reply func(message []byte)) {
+	err := m.model.Clear()
+	if err != nil {
+		reply([]byte(err.Error()))
+		return
+	}
+
+	reply(nil)
+}
+
+// getManyCB is the callback for stateModel.GetMany. Returns the JSON of a
+// [stateWorker.GetManyReply].
+func (m *manager) getManyCB(message []byte, reply func(message []byte)) {
+	var keys []string
+	err := json.Unmarshal(message, &keys)
+	if err != nil {
+		reply([]byte(errors.Wrapf(err,
+			"failed to JSON unmarshal %T from main thread", keys).Error()))
+		return
+	}
+
+	values, err := m.model.GetMany(keys)
+	msg := stateWorker.GetManyReply{Values: values}
+	if err != nil {
+		msg.Error = err.Error()
+	}
+
+	replyMessage, err := json.Marshal(msg)
+	if err != nil {
+		exception.Throwf("Could not JSON marshal %T for GetMany: %+v", msg, err)
+	}
+
+	reply(replyMessage)
+}
+
+// setManyCB is the callback for stateModel.SetMany. Returns nil on success or
+// an error message on failure.
+func (m *manager) setManyCB(message []byte, reply func(message []byte)) {
+	var values map[string][]byte
+	err := json.Unmarshal(message, &values)
+	if err != nil {
+		reply([]byte(errors.Wrapf(err,
+			"failed to JSON unmarshal %T from main thread", values).Error()))
+		return
+	}
+
+	err = m.model.SetMany(values)
+	if err != nil {
+		reply([]byte(err.Error()))
+		return
+	}
+
+	reply(nil)
+}
+
+// compareAndSwapCB is the callback for stateModel.CompareAndSwap. Returns the
+// JSON of a [stateWorker.CompareAndSwapReply].
+func (m *manager) compareAndSwapCB(message []byte, reply func(message []byte)) {
+	var msg stateWorker.CompareAndSwapMessage
+	var replyMsg stateWorker.CompareAndSwapReply
+	err := json.Unmarshal(message, &msg)
+	if err != nil {
+		replyMsg.Error = errors.Wrapf(err,
+			"failed to JSON unmarshal %T from main thread", msg).Error()
+	} else {
+		replyMsg.Swapped, err = m.model.CompareAndSwap(msg.Key, msg.Old, msg.New)
+		if err != nil {
+			replyMsg.Error = err.Error()
+		}
+	}
+
+	replyMessage, err := json.Marshal(replyMsg)
+	if err != nil {
+		exception.Throwf(
+			"Could not JSON marshal %T for CompareAndSwap: %+v", replyMsg, err)
+	}
+
+	reply(replyMessage)
+}
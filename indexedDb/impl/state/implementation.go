@@ -10,12 +10,16 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"strings"
+	"syscall/js"
+
 	"github.com/hack-pad/go-indexeddb/idb"
 	"github.com/pkg/errors"
+
 	"gitlab.com/elixxir/wasm-utils/utils"
 	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
-	"syscall/js"
 )
 
 // stateModel implements [ClientState] interface backed by IndexedDb.
@@ -23,6 +27,11 @@ import (
 // caller to ensure that its methods are called sequentially.
 type stateModel struct {
 	db *idb.Database
+
+	// watchers are called with every change made by this model or, through
+	// the broadcaster, by the state worker of another tab
+	watchers    *impl.StateWatchers
+	broadcaster *changeBroadcaster
 }
 
 func (s *stateModel) Get(key string) ([]byte, error) {
@@ -31,16 +40,211 @@ func (s *stateModel) Get(key string) ([]byte, error) {
 		return nil, err
 	}
 
-	stateObj := &State{}
-	err = json.Unmarshal([]byte(utils.JsToJson(result)), stateObj)
+	return valueToState(result)
+}
+
+func (s *stateModel) Set(key string, value []byte) error {
+	stateObj, err := newStateObject(key, value)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return stateObj.Value, err
+	// Store State to database
+	_, err = impl.Put(s.db, stateStoreName, stateObj)
+	if err != nil {
+		return errors.Errorf("Unable to put State: %+v\n%s",
+			err, utils.JsToJson(stateObj))
+	}
+
+	s.changed([]impl.StateChange{{Key: key}})
+	return nil
 }
 
-func (s *stateModel) Set(key string, value []byte) error {
+// Delete deletes the State with the key.
+func (s *stateModel) Delete(key string) error {
+	err := impl.Delete(s.db, stateStoreName, js.ValueOf(key))
+	if err != nil {
+		return errors.Errorf("Unable to delete State: %+v", err)
+	}
+
+	s.changed([]impl.StateChange{{Key: key, Deleted: true}})
+	return nil
+}
+
+// Keys returns every key that starts with the prefix in ascending order.
+func (s *stateModel) Keys(prefix string) ([]string, error) {
+	// Keys with the prefix are contiguous in key order, so stop at the first
+	// key after the prefix
+	keys := make([]string, 0)
+	err := impl.NewQuery(stateStoreName).LowerBound(js.ValueOf(prefix), false).
+		Iter(s.db, func(value js.Value) error {
+			key := value.Get(pkeyName).String()
+			if !strings.HasPrefix(key, prefix) {
+				return idb.ErrCursorStopIter
+			}
+			keys = append(keys, key)
+			return nil
+		})
+	if err != nil {
+		return nil, errors.Errorf("Unable to get State keys: %+v", err)
+	}
+	return keys, nil
+}
+
+// Clear deletes every State.
+func (s *stateModel) Clear() error {
+	var changes []impl.StateChange
+	err := impl.Transact(s.db, []string{stateStoreName},
+		func(txn *impl.Transaction) error {
+			// Read the keys in the same transaction so that every deleted key
+			// is reported
+			stateObjs, err := txn.GetAll(stateStoreName)
+			if err != nil {
+				return err
+			}
+			changes = make([]impl.StateChange, len(stateObjs))
+			for i, stateObj := range stateObjs {
+				changes[i] = impl.StateChange{
+					Key:     stateObj.Get(pkeyName).String(),
+					Deleted: true,
+				}
+			}
+			return txn.Clear(stateStoreName)
+		})
+	if err != nil {
+		return errors.Errorf("Unable to clear States: %+v", err)
+	}
+
+	s.changed(changes)
+	return nil
+}
+
+// GetMany returns the values of the States with the keys read in one
+// transaction. Keys without a State are not in the returned map.
+func (s *stateModel) GetMany(keys []string) (map[string][]byte, error) {
+	var values map[string][]byte
+	err := impl.Transact(s.db, []string{stateStoreName},
+		func(txn *impl.Transaction) error {
+			// Reset on each attempt so that a retry does not keep values read
+			// by a failed one
+			values = make(map[string][]byte, len(keys))
+			for _, key := range keys {
+				value, exists, err := getState(txn, key)
+				if err != nil {
+					return err
+				} else if exists {
+					values[key] = value
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, errors.Errorf("Unable to get States: %+v", err)
+	}
+	return values, nil
+}
+
+// SetMany stores a State for each key and value in one transaction.
+func (s *stateModel) SetMany(values map[string][]byte) error {
+	var changes []impl.StateChange
+	err := impl.Transact(s.db, []string{stateStoreName},
+		func(txn *impl.Transaction) error {
+			// Reset on each attempt so that a retry does not report the keys
+			// put by a failed one twice
+			changes = make([]impl.StateChange, 0, len(values))
+			for key, value := range values {
+				stateObj, err := newStateObject(key, value)
+				if err != nil {
+					return err
+				}
+				if _, err = txn.Put(stateStoreName, stateObj); err != nil {
+					return err
+				}
+				changes = append(changes, impl.StateChange{Key: key})
+			}
+			return nil
+		})
+	if err != nil {
+		return errors.Errorf("Unable to put States: %+v", err)
+	}
+
+	s.changed(changes)
+	return nil
+}
+
+// CompareAndSwap replaces the value of the State with the key with newValue
+// if its current value equals oldValue. A nil oldValue matches a missing State
+// and a nil newValue deletes the State. The value is read and written in one
+// transaction so that no other change can be made in between.
+func (s *stateModel) CompareAndSwap(
+	key string, oldValue, newValue []byte) (bool, error) {
+	var swapped bool
+	err := impl.Transact(s.db, []string{stateStoreName},
+		func(txn *impl.Transaction) error {
+			value, exists, err := getState(txn, key)
+			if err != nil {
+				return err
+			} else if exists != (oldValue != nil) ||
+				!bytes.Equal(value, oldValue) {
+				swapped = false
+				return nil
+			}
+
+			if newValue == nil {
+				err = txn.Delete(stateStoreName, js.ValueOf(key))
+			} else {
+				var stateObj js.Value
+				if stateObj, err = newStateObject(key, newValue); err == nil {
+					_, err = txn.Put(stateStoreName, stateObj)
+				}
+			}
+			swapped = err == nil
+			return err
+		})
+	if err != nil {
+		return false, errors.Errorf("Unable to swap State: %+v", err)
+	}
+
+	if swapped {
+		s.changed([]impl.StateChange{{Key: key, Deleted: newValue == nil}})
+	}
+	return swapped, nil
+}
+
+// Watch calls cb with the changes to the keys that start with the prefix.
+func (s *stateModel) Watch(
+	prefix string, cb func(changes []impl.StateChange)) (stop func()) {
+	return s.watchers.Add(prefix, cb)
+}
+
+// changed notifies the watchers of this model and the state workers of other
+// tabs of the changes.
+func (s *stateModel) changed(changes []impl.StateChange) {
+	if len(changes) == 0 {
+		return
+	}
+	s.watchers.Notify(changes)
+	s.broadcaster.send(changes)
+}
+
+// getState returns the value of the State with the key in the transaction.
+// Returns false if there is none.
+func getState(txn *impl.Transaction, key string) ([]byte, bool, error) {
+	stateObj, err := txn.Get(stateStoreName, js.ValueOf(key))
+	if err != nil {
+		if strings.Contains(err.Error(), impl.ErrDoesNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	value, err := valueToState(stateObj)
+	return value, err == nil, err
+}
+
+// newStateObject returns the Javascript object of a State with the key and
+// value.
+func newStateObject(key string, value []byte) (js.Value, error) {
 	state := &State{
 		Id:    key,
 		Value: value,
@@ -49,18 +253,21 @@ func (s *stateModel) Set(key string, value []byte) error {
 	// Convert to jsObject
 	newStateJSON, err := json.Marshal(state)
 	if err != nil {
-		return errors.Errorf("Unable to marshal State: %+v", err)
+		return js.Undefined(), errors.Errorf("Unable to marshal State: %+v", err)
 	}
 	stateObj, err := utils.JsonToJS(newStateJSON)
 	if err != nil {
-		return errors.Errorf("Unable to marshal State: %+v", err)
+		return js.Undefined(), errors.Errorf("Unable to marshal State: %+v", err)
 	}
+	return stateObj, nil
+}
 
-	// Store State to database
-	_, err = impl.Put(s.db, stateStoreName, stateObj)
+// valueToState returns the value of the State in the Javascript object.
+func valueToState(stateObj js.Value) ([]byte, error) {
+	state := &State{}
+	err := json.Unmarshal([]byte(utils.JsToJson(stateObj)), state)
 	if err != nil {
-		return errors.Errorf("Unable to put State: %+v\n%s",
-			err, newStateJSON)
+		return nil, err
 	}
-	return nil
+	return state.Value, nil
 }
@@ -29,9 +29,7 @@ func NewState(databaseName string) (impl.WebState, error) {
 // newState creates the given [idb.Database] and returns a stateModel.
 func newState(databaseName string) (*stateModel, error) {
 	// Attempt to open database object
-	ctx, cancel := impl.NewContext()
-	defer cancel()
-	openRequest, err := idb.Global().Open(ctx, databaseName, currentVersion,
+	db, err := impl.Open(databaseName, currentVersion,
 		func(db *idb.Database, oldVersion, newVersion uint) error {
 			if oldVersion == newVersion {
 				jww.INFO.Printf("IndexDb version for %s is current: v%d",
@@ -57,15 +55,9 @@ func newState(databaseName string) (*stateModel, error) {
 		return nil, err
 	}
 
-	// Wait for database open to finish
-	db, err := openRequest.Await(ctx)
-	if err != nil {
-		return nil, err
-	} else if ctx.Err() != nil {
-		return nil, ctx.Err()
-	}
-
-	wrapper := &stateModel{db: db}
+	wrapper := &stateModel{db: db, watchers: impl.NewStateWatchers()}
+	wrapper.broadcaster =
+		newChangeBroadcaster(databaseName, wrapper.watchers.Notify)
 	return wrapper, nil
 }
 
@@ -13,11 +13,13 @@ import (
 	"fmt"
 	"os"
 	"syscall/js"
+	"time"
 
 	"github.com/spf13/cobra"
 	jww "github.com/spf13/jwalterweatherman"
 
 	"gitlab.com/elixxir/wasm-utils/exception"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
 	"gitlab.com/elixxir/xxdk-wasm/logging"
 	"gitlab.com/elixxir/xxdk-wasm/worker"
 )
@@ -51,6 +53,12 @@ var stateCmd = &cobra.Command{
 
 		jww.INFO.Printf("xxDK state web worker version: v%s", SEMVER)
 
+		// Configure the timeout and retries of storage operations
+		impl.SetTimeout(dbTimeout)
+		retryPolicy := impl.DefaultRetryPolicy()
+		retryPolicy.MaxAttempts = dbMaxAttempts
+		impl.SetRetryPolicy(retryPolicy)
+
 		jww.INFO.Print("[WW] Starting xxDK WebAssembly State Database Worker.")
 		tm, err := worker.NewThreadManager("DmIndexedDbWorker", true)
 		if err != nil {
@@ -88,6 +96,8 @@ var stateCmd = &cobra.Command{
 var (
 	logLevel       jww.Threshold
 	threadLogLevel jww.Threshold
+	dbTimeout      time.Duration
+	dbMaxAttempts  int
 )
 
 func init() {
@@ -102,4 +112,10 @@ func init() {
 		"The log level when outputting to the worker file buffer. "+
 			"0 = TRACE, 1 = DEBUG, 2 = INFO, 3 = WARN, 4 = ERROR, "+
 			"5 = CRITICAL, 6 = FATAL, -1 = disabled.")
+	stateCmd.Flags().DurationVar(&dbTimeout, "dbTimeout", impl.DefaultTimeout,
+		"The timeout for each IndexedDb operation.")
+	stateCmd.Flags().IntVar(&dbMaxAttempts, "dbMaxAttempts",
+		impl.DefaultRetryPolicy().MaxAttempts,
+		"The maximum number of times an IndexedDb operation is attempted "+
+			"when it fails with a transient error. 1 = no retries.")
 }
@@ -0,0 +1,80 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+)
+
+// broadcastChannelPrefix is prepended to the database name to get the name of
+// the BroadcastChannel that state workers of the same database share.
+const broadcastChannelPrefix = "xxdkStateChanges/"
+
+// changeBroadcaster shares the changes made to a state database with the state
+// workers of the same database in other tabs using a BroadcastChannel. A
+// BroadcastChannel does not receive its own messages, so changes are never
+// echoed back.
+type changeBroadcaster struct {
+	channel   js.Value
+	onMessage js.Func
+}
+
+// newChangeBroadcaster opens the BroadcastChannel of the database and calls
+// receive with the changes sent by other tabs. Returns nil if the browser does
+// not support BroadcastChannel.
+func newChangeBroadcaster(databaseName string,
+	receive func(changes []impl.StateChange)) *changeBroadcaster {
+	broadcastChannel := js.Global().Get("BroadcastChannel")
+	if broadcastChannel.IsUndefined() {
+		jww.WARN.Printf("[STATE] BroadcastChannel is not supported; changes "+
+			"to %s made in other tabs will not be watched", databaseName)
+		return nil
+	}
+
+	cb := &changeBroadcaster{
+		channel: broadcastChannel.New(broadcastChannelPrefix + databaseName),
+	}
+	cb.onMessage = js.FuncOf(func(_ js.Value, args []js.Value) any {
+		var changes []impl.StateChange
+		data := args[0].Get("data").String()
+		if err := json.Unmarshal([]byte(data), &changes); err != nil {
+			jww.ERROR.Printf("[STATE] Failed to JSON unmarshal %T from "+
+				"BroadcastChannel: %+v", changes, err)
+			return nil
+		}
+		receive(changes)
+		return nil
+	})
+	cb.channel.Set("onmessage", cb.onMessage)
+
+	return cb
+}
+
+// send posts the changes to the state workers in other tabs. Does nothing if
+// the broadcaster is nil.
+func (cb *changeBroadcaster) send(changes []impl.StateChange) {
+	if cb == nil {
+		return
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		jww.ERROR.Printf(
+			"[STATE] Failed to JSON marshal %T for BroadcastChannel: %+v",
+			changes, err)
+		return
+	}
+	cb.channel.Call("postMessage", string(data))
+}
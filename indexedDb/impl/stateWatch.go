@@ -0,0 +1,85 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"strings"
+	"sync"
+)
+
+// StateChange describes a change to the value of a key of a [WebState].
+type StateChange struct {
+	Key string `json:"key"`
+
+	// Deleted is true if the key was deleted and false if its value was set.
+	Deleted bool `json:"deleted"`
+}
+
+// StateWatchers keeps the callbacks registered with [WebState.Watch] and calls
+// them with the changes to the keys they watch. It is safe for concurrent use.
+type StateWatchers struct {
+	watchers map[int]stateWatcher
+	next     int
+	mux      sync.Mutex
+}
+
+// stateWatcher is a callback for the changes to keys that start with prefix.
+type stateWatcher struct {
+	prefix string
+	cb     func(changes []StateChange)
+}
+
+// NewStateWatchers returns an empty StateWatchers.
+func NewStateWatchers() *StateWatchers {
+	return &StateWatchers{watchers: make(map[int]stateWatcher)}
+}
+
+// Add registers the callback for the changes to keys that start with the
+// prefix. Returns a function that removes the callback.
+func (sw *StateWatchers) Add(
+	prefix string, cb func(changes []StateChange)) (stop func()) {
+	sw.mux.Lock()
+	defer sw.mux.Unlock()
+
+	id := sw.next
+	sw.next++
+	sw.watchers[id] = stateWatcher{prefix, cb}
+
+	return func() {
+		sw.mux.Lock()
+		defer sw.mux.Unlock()
+		delete(sw.watchers, id)
+	}
+}
+
+// Notify calls each callback with the changes to the keys it watches. A
+// callback is not called if none of the changes match its prefix.
+func (sw *StateWatchers) Notify(changes []StateChange) {
+	sw.mux.Lock()
+	watchers := make([]stateWatcher, 0, len(sw.watchers))
+	for _, w := range sw.watchers {
+		watchers = append(watchers, w)
+	}
+	sw.mux.Unlock()
+
+	// Callbacks are called without holding the lock so that they may add or
+	// remove watchers
+	for _, w := range watchers {
+		var matched []StateChange
+		for _, change := range changes {
+			if strings.HasPrefix(change.Key, w.prefix) {
+				matched = append(matched, change)
+			}
+		}
+		if len(matched) > 0 {
+			w.cb(matched)
+		}
+	}
+}
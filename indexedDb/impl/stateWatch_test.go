@@ -0,0 +1,62 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Tests that StateWatchers.Notify only calls each callback with the changes
+// that match its prefix and does not call callbacks with no matching changes.
+func TestStateWatchers_Notify(t *testing.T) {
+	sw := NewStateWatchers()
+
+	received := make(map[string][][]StateChange)
+	for _, prefix := range []string{"", "draft/", "theme"} {
+		prefix := prefix
+		sw.Add(prefix, func(changes []StateChange) {
+			received[prefix] = append(received[prefix], changes)
+		})
+	}
+
+	changes := []StateChange{
+		{Key: "draft/a"},
+		{Key: "other", Deleted: true},
+		{Key: "draft/b", Deleted: true},
+	}
+	sw.Notify(changes)
+
+	expected := map[string][][]StateChange{
+		"":       {changes},
+		"draft/": {{{Key: "draft/a"}, {Key: "draft/b", Deleted: true}}},
+	}
+	if !reflect.DeepEqual(expected, received) {
+		t.Errorf("Unexpected changes received.\nexpected: %+v\nreceived: %+v",
+			expected, received)
+	}
+}
+
+// Tests that a callback is no longer called after the function returned by
+// StateWatchers.Add is called.
+func TestStateWatchers_Add_Stop(t *testing.T) {
+	sw := NewStateWatchers()
+
+	var calls int
+	stop := sw.Add("", func([]StateChange) { calls++ })
+
+	sw.Notify([]StateChange{{Key: "a"}})
+	stop()
+	sw.Notify([]StateChange{{Key: "b"}})
+
+	if calls != 1 {
+		t.Errorf("Callback called %d times after stop (expected %d).", calls, 1)
+	}
+}
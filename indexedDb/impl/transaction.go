@@ -0,0 +1,327 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"syscall/js"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/wasm-utils/utils"
+)
+
+// Transaction is a read-write transaction spanning several object stores. It
+// is passed to the function run by Transact and must not be used after that
+// function returns.
+type Transaction struct {
+	txn *idb.Transaction
+}
+
+// Transact runs fn inside a single read-write transaction spanning the given
+// object stores. All writes made by fn are committed together once it returns.
+// If fn returns an error, the transaction is aborted and none of its writes are
+// committed. If the transaction fails with a transient error, fn is called
+// again in a new transaction, so it must be safe to call more than once.
+//
+// IndexedDB commits a transaction automatically once it has no pending
+// requests. Because of this, fn must only wait on operations of the
+// Transaction; it must not wait on other transactions, timers, or channels.
+func Transact(db *idb.Database, objectStoreNames []string,
+	fn func(txn *Transaction) error) error {
+	return Retry(func() error {
+		return transact(db, objectStoreNames, fn)
+	})
+}
+
+// transact performs a single attempt of Transact.
+func transact(db *idb.Database, objectStoreNames []string,
+	fn func(txn *Transaction) error) error {
+	if len(objectStoreNames) == 0 {
+		return errors.New("failed to Transact: no object stores")
+	}
+	parentErr := errors.Errorf("failed to Transact %v", objectStoreNames)
+
+	// Prepare the Transaction
+	txn, err := newTransaction(db, idb.TransactionReadWrite,
+		objectStoreNames[0], objectStoreNames[1:]...)
+	if err != nil {
+		return errors.WithMessagef(parentErr,
+			"Unable to create Transaction: %+v", err)
+	}
+
+	// Perform the operations
+	if err = fn(&Transaction{txn}); err != nil {
+		// A failed request may have already aborted the transaction
+		if abortErr := txn.Abort(); abortErr != nil {
+			jww.DEBUG.Printf("Failed to abort transaction on %v: %+v",
+				objectStoreNames, abortErr)
+		}
+		return err
+	}
+
+	ctx, cancel := NewContext()
+	defer cancel()
+	if err = txn.Await(ctx); err != nil {
+		return errors.WithMessagef(parentErr,
+			"Unable to commit Transaction: %+v", handleError(db, err))
+	}
+	return nil
+}
+
+// Get gets the value with the primary key from the given [idb.ObjectStore].
+func (t *Transaction) Get(
+	objectStoreName string, key js.Value) (js.Value, error) {
+	parentErr := errors.Errorf("failed to Get %s", objectStoreName)
+
+	store, err := t.txn.ObjectStore(objectStoreName)
+	if err != nil {
+		return js.Undefined(), errors.WithMessagef(parentErr,
+			"Unable to get ObjectStore: %+v", err)
+	}
+	getRequest, err := store.Get(key)
+	if err != nil {
+		return js.Undefined(), errors.WithMessagef(parentErr,
+			"Unable to Get from ObjectStore: %+v", err)
+	}
+
+	return awaitGetRequest(getRequest, parentErr)
+}
+
+// GetIndex gets the first value with the key in the given [idb.Index] of the
+// given [idb.ObjectStore].
+func (t *Transaction) GetIndex(
+	objectStoreName, indexName string, key js.Value) (js.Value, error) {
+	parentErr := errors.Errorf("failed to GetIndex %s/%s",
+		objectStoreName, indexName)
+
+	store, err := t.txn.ObjectStore(objectStoreName)
+	if err != nil {
+		return js.Undefined(), errors.WithMessagef(parentErr,
+			"Unable to get ObjectStore: %+v", err)
+	}
+	idx, err := store.Index(indexName)
+	if err != nil {
+		return js.Undefined(), errors.WithMessagef(parentErr,
+			"Unable to get Index: %+v", err)
+	}
+	getRequest, err := idx.Get(key)
+	if err != nil {
+		return js.Undefined(), errors.WithMessagef(parentErr,
+			"Unable to Get from ObjectStore: %+v", err)
+	}
+
+	return awaitGetRequest(getRequest, parentErr)
+}
+
+// GetAll gets every value in the given [idb.ObjectStore] in ascending order of
+// primary key.
+func (t *Transaction) GetAll(objectStoreName string) ([]js.Value, error) {
+	parentErr := errors.Errorf("failed to GetAll %s", objectStoreName)
+
+	store, err := t.txn.ObjectStore(objectStoreName)
+	if err != nil {
+		return nil, errors.WithMessagef(parentErr,
+			"Unable to get ObjectStore: %+v", err)
+	}
+	cursorRequest, err := store.OpenCursor(idb.CursorNext)
+	if err != nil {
+		return nil, errors.WithMessagef(parentErr,
+			"Unable to open Cursor: %+v", err)
+	}
+
+	values, err := collectValues(cursorRequest)
+	if err != nil {
+		return nil, errors.WithMessagef(parentErr,
+			"Unable to get values: %+v", err)
+	}
+	return values, nil
+}
+
+// GetAllIndex gets every value with the key in the given [idb.Index] of the
+// given [idb.ObjectStore] in ascending order of primary key.
+func (t *Transaction) GetAllIndex(
+	objectStoreName, indexName string, key js.Value) ([]js.Value, error) {
+	parentErr := errors.Errorf("failed to GetAllIndex %s/%s",
+		objectStoreName, indexName)
+
+	store, err := t.txn.ObjectStore(objectStoreName)
+	if err != nil {
+		return nil, errors.WithMessagef(parentErr,
+			"Unable to get ObjectStore: %+v", err)
+	}
+	idx, err := store.Index(indexName)
+	if err != nil {
+		return nil, errors.WithMessagef(parentErr,
+			"Unable to get Index: %+v", err)
+	}
+	keyRange, err := idb.NewKeyRangeOnly(key)
+	if err != nil {
+		return nil, errors.WithMessagef(parentErr,
+			"Unable to create KeyRange: %+v", err)
+	}
+	cursorRequest, err := idx.OpenCursorRange(keyRange, idb.CursorNext)
+	if err != nil {
+		return nil, errors.WithMessagef(parentErr,
+			"Unable to open Cursor: %+v", err)
+	}
+
+	values, err := collectValues(cursorRequest)
+	if err != nil {
+		return nil, errors.WithMessagef(parentErr,
+			"Unable to get values: %+v", err)
+	}
+	return values, nil
+}
+
+// collectValues returns the value at every position of the cursor.
+func collectValues(cursorRequest *idb.CursorWithValueRequest) (
+	[]js.Value, error) {
+	values := make([]js.Value, 0)
+	err := SendCursorRequest(cursorRequest,
+		func(cursor *idb.CursorWithValue) error {
+			value, err := cursor.Value()
+			if err != nil {
+				return err
+			}
+			values = append(values, value)
+			return nil
+		})
+	return values, err
+}
+
+// Put puts the value into the given [idb.ObjectStore]. Equivalent to insert if
+// not exists else update. Returns the primary key of the stored object.
+func (t *Transaction) Put(
+	objectStoreName string, value js.Value) (js.Value, error) {
+	parentErr := errors.Errorf("failed to Put %s", objectStoreName)
+
+	store, err := t.txn.ObjectStore(objectStoreName)
+	if err != nil {
+		return js.Undefined(), errors.WithMessagef(parentErr,
+			"Unable to get ObjectStore: %+v", err)
+	}
+	request, err := store.Put(value)
+	if err != nil {
+		return js.Undefined(), errors.WithMessagef(parentErr,
+			"Unable to Put: %+v", err)
+	}
+
+	resultObj, err := SendRequest(request)
+	if err != nil {
+		return js.Undefined(), errors.WithMessagef(parentErr,
+			"Putting value failed: %+v\n%s", err, utils.JsToJson(value))
+	}
+	return resultObj, nil
+}
+
+// Delete removes the value with the primary key from the given
+// [idb.ObjectStore].
+func (t *Transaction) Delete(objectStoreName string, key js.Value) error {
+	parentErr := errors.Errorf("failed to Delete %s", objectStoreName)
+
+	store, err := t.txn.ObjectStore(objectStoreName)
+	if err != nil {
+		return errors.WithMessagef(parentErr,
+			"Unable to get ObjectStore: %+v", err)
+	}
+	deleteRequest, err := store.Delete(key)
+	if err != nil {
+		return errors.WithMessagef(parentErr,
+			"Unable to Delete from ObjectStore: %+v", err)
+	}
+
+	if _, err = SendRequest(deleteRequest.Request); err != nil {
+		return errors.WithMessagef(parentErr,
+			"Unable to Delete from ObjectStore: %+v", err)
+	}
+	return nil
+}
+
+// Clear removes every value from the given [idb.ObjectStore].
+func (t *Transaction) Clear(objectStoreName string) error {
+	parentErr := errors.Errorf("failed to Clear %s", objectStoreName)
+
+	store, err := t.txn.ObjectStore(objectStoreName)
+	if err != nil {
+		return errors.WithMessagef(parentErr,
+			"Unable to get ObjectStore: %+v", err)
+	}
+	clearRequest, err := store.Clear()
+	if err != nil {
+		return errors.WithMessagef(parentErr,
+			"Unable to Clear ObjectStore: %+v", err)
+	}
+
+	if _, err = SendRequest(clearRequest.Request); err != nil {
+		return errors.WithMessagef(parentErr,
+			"Unable to Clear ObjectStore: %+v", err)
+	}
+	return nil
+}
+
+// DeleteAllIndex removes every value with the key in the given [idb.Index] of
+// the given [idb.ObjectStore]. Returns the number of values removed.
+func (t *Transaction) DeleteAllIndex(
+	objectStoreName, indexName string, key js.Value) (int, error) {
+	parentErr := errors.Errorf("failed to DeleteAllIndex %s/%s",
+		objectStoreName, indexName)
+
+	store, err := t.txn.ObjectStore(objectStoreName)
+	if err != nil {
+		return 0, errors.WithMessagef(parentErr,
+			"Unable to get ObjectStore: %+v", err)
+	}
+	idx, err := store.Index(indexName)
+	if err != nil {
+		return 0, errors.WithMessagef(parentErr,
+			"Unable to get Index: %+v", err)
+	}
+	keyRange, err := idb.NewKeyRangeOnly(key)
+	if err != nil {
+		return 0, errors.WithMessagef(parentErr,
+			"Unable to create KeyRange: %+v", err)
+	}
+	cursorRequest, err := idx.OpenCursorRange(keyRange, idb.CursorNext)
+	if err != nil {
+		return 0, errors.WithMessagef(parentErr,
+			"Unable to open Cursor: %+v", err)
+	}
+
+	var numDeleted int
+	err = SendCursorRequest(cursorRequest,
+		func(cursor *idb.CursorWithValue) error {
+			if _, err := cursor.Delete(); err != nil {
+				return err
+			}
+			numDeleted++
+			return nil
+		})
+	if err != nil {
+		return numDeleted, errors.WithMessagef(parentErr,
+			"Unable to delete values: %+v", err)
+	}
+	return numDeleted, nil
+}
+
+// awaitGetRequest waits for the result of the get request. Returns an error
+// containing ErrDoesNotExist if there is no value.
+func awaitGetRequest(
+	getRequest *idb.Request, parentErr error) (js.Value, error) {
+	resultObj, err := SendRequest(getRequest)
+	if err != nil {
+		return js.Undefined(), errors.WithMessagef(parentErr,
+			"Unable to get from ObjectStore: %+v", err)
+	} else if resultObj.IsUndefined() {
+		return js.Undefined(), errors.WithMessagef(parentErr,
+			"Unable to get from ObjectStore: %s", ErrDoesNotExist)
+	}
+	return resultObj, nil
+}
@@ -0,0 +1,185 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"strings"
+	"syscall/js"
+	"testing"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/pkg/errors"
+)
+
+// newTransactionTestDB creates a database with two object stores keyed on
+// "id".
+func newTransactionTestDB(name string, t *testing.T) *idb.Database {
+	ctx, cancel := NewContext()
+	defer cancel()
+	openRequest, err := idb.Global().Open(ctx, name, 1,
+		func(db *idb.Database, _ uint, _ uint) error {
+			for _, storeName := range []string{"a", "b"} {
+				_, err := db.CreateObjectStore(storeName,
+					idb.ObjectStoreOptions{KeyPath: js.ValueOf("id")})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openRequest.Await(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Tests that Transact commits the writes made to every object store once the
+// function returns.
+func TestTransact(t *testing.T) {
+	db := newTransactionTestDB("TestTransact", t)
+
+	err := Transact(db, []string{"a", "b"}, func(txn *Transaction) error {
+		if _, err := txn.Put("a",
+			js.ValueOf(map[string]any{"id": "1", "v": "a"})); err != nil {
+			return err
+		}
+		stored, err := txn.Get("a", js.ValueOf("1"))
+		if err != nil {
+			return err
+		}
+		_, err = txn.Put("b", js.ValueOf(
+			map[string]any{"id": "1", "v": stored.Get("v").String() + "b"}))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to Transact: %+v", err)
+	}
+
+	for storeName, expected := range map[string]string{"a": "a", "b": "ab"} {
+		value, err := Get(db, storeName, js.ValueOf("1"))
+		if err != nil {
+			t.Errorf("Failed to get value from %s: %+v", storeName, err)
+		} else if v := value.Get("v").String(); v != expected {
+			t.Errorf("Unexpected value in %s.\nexpected: %s\nreceived: %s",
+				storeName, expected, v)
+		}
+	}
+}
+
+// Error path: Tests that when the function returns an error, Transact returns
+// it and none of the writes are committed.
+func TestTransact_Abort(t *testing.T) {
+	db := newTransactionTestDB("TestTransact_Abort", t)
+
+	expectedErr := errors.New("test error")
+	err := Transact(db, []string{"a", "b"}, func(txn *Transaction) error {
+		if _, err := txn.Put("a",
+			js.ValueOf(map[string]any{"id": "1"})); err != nil {
+			return err
+		}
+		return expectedErr
+	})
+	if err != expectedErr {
+		t.Errorf("Unexpected error.\nexpected: %v\nreceived: %v",
+			expectedErr, err)
+	}
+
+	_, err = Get(db, "a", js.ValueOf("1"))
+	if err == nil || !strings.Contains(err.Error(), ErrDoesNotExist) {
+		t.Errorf("Value from aborted transaction was committed: %v", err)
+	}
+}
+
+// Tests that Transaction.DeleteAllIndex deletes only the values with the key.
+func TestTransaction_DeleteAllIndex(t *testing.T) {
+	ctx, cancel := NewContext()
+	defer cancel()
+	openRequest, err := idb.Global().Open(ctx, "TestTransaction_DeleteAllIndex",
+		1, func(db *idb.Database, _ uint, _ uint) error {
+			store, err := db.CreateObjectStore("a",
+				idb.ObjectStoreOptions{KeyPath: js.ValueOf("id")})
+			if err != nil {
+				return err
+			}
+			_, err = store.CreateIndex("group",
+				js.ValueOf("group"), idb.IndexOptions{})
+			return err
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openRequest.Await(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, group := range []string{"x", "y", "x", "x"} {
+		_, err = Put(db, "a", js.ValueOf(
+			map[string]any{"id": i, "group": group}))
+		if err != nil {
+			t.Fatalf("Failed to put value %d: %+v", i, err)
+		}
+	}
+
+	var numDeleted int
+	err = Transact(db, []string{"a"}, func(txn *Transaction) error {
+		numDeleted, err = txn.DeleteAllIndex("a", "group", js.ValueOf("x"))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to Transact: %+v", err)
+	}
+	if numDeleted != 3 {
+		t.Errorf("Unexpected number deleted.\nexpected: %d\nreceived: %d",
+			3, numDeleted)
+	}
+	if count, err := Count(db, "a"); err != nil {
+		t.Fatalf("Failed to count values: %+v", err)
+	} else if count != 1 {
+		t.Errorf("Unexpected number of values.\nexpected: %d\nreceived: %d",
+			1, count)
+	}
+}
+
+// Tests that Transaction.Clear removes every value from the object store and
+// leaves the other object stores unchanged.
+func TestTransaction_Clear(t *testing.T) {
+	db := newTransactionTestDB("TestTransaction_Clear", t)
+
+	for _, storeName := range []string{"a", "b"} {
+		for i := 0; i < 3; i++ {
+			_, err := Put(db, storeName, js.ValueOf(map[string]any{"id": i}))
+			if err != nil {
+				t.Fatalf("Failed to put value %d in %s: %+v", i, storeName, err)
+			}
+		}
+	}
+
+	err := Transact(db, []string{"a"}, func(txn *Transaction) error {
+		return txn.Clear("a")
+	})
+	if err != nil {
+		t.Fatalf("Failed to Clear: %+v", err)
+	}
+
+	for storeName, expected := range map[string]int{"a": 0, "b": 3} {
+		count, err := Count(db, storeName)
+		if err != nil {
+			t.Errorf("Failed to count %s: %+v", storeName, err)
+		} else if count != expected {
+			t.Errorf("Unexpected number of values in %s."+
+				"\nexpected: %d\nreceived: %d", storeName, expected, count)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"sync"
+	"syscall/js"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/pkg/errors"
+)
+
+// upgradeStoreName is the name of the temporary object store created to reach
+// the versionchange transaction of an upgrade.
+const upgradeStoreName = "_upgrade"
+
+// upgrading maps each database being upgraded by Open to its Javascript
+// IDBDatabase.
+var upgrading sync.Map
+
+// CreateIndex creates an index on an object store created by an earlier version
+// of the database. It must be called from the upgrader passed to Open.
+//
+// The [idb] package only exposes object stores created during the current
+// upgrade, so the object store is reached through the versionchange
+// transaction of a temporary object store, which is deleted immediately.
+func CreateIndex(db *idb.Database, objectStoreName, indexName string,
+	keyPath js.Value, options idb.IndexOptions) (err error) {
+	value, exists := upgrading.Load(db)
+	if !exists {
+		return errors.Errorf("cannot create index %s on %s outside of an "+
+			"upgrade of a database opened with impl.Open",
+			indexName, objectStoreName)
+	}
+	jsDB := value.(js.Value)
+
+	defer func() {
+		if r := recover(); r != nil {
+			if jsErr, ok := r.(js.Error); ok {
+				err = classifyError(jsErr)
+			} else {
+				err = errors.Errorf("%v", r)
+			}
+			err = errors.WithMessagef(err, "failed to create index %s on %s",
+				indexName, objectStoreName)
+		}
+	}()
+
+	txn := jsDB.Call("createObjectStore", upgradeStoreName).Get("transaction")
+	jsDB.Call("deleteObjectStore", upgradeStoreName)
+
+	txn.Call("objectStore", objectStoreName).Call("createIndex", indexName,
+		keyPath, map[string]any{
+			"unique":     options.Unique,
+			"multiEntry": options.MultiEntry,
+		})
+	return nil
+}
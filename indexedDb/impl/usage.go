@@ -0,0 +1,264 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/wasm-utils/utils"
+)
+
+const (
+	// DefaultStorageWarningThreshold is the default fraction of the storage
+	// quota that, once used, triggers a StorageWarning event.
+	DefaultStorageWarningThreshold = 0.8
+
+	// DefaultStorageCheckInterval is the default time between checks of the
+	// storage estimate by a StorageMonitor.
+	DefaultStorageCheckInterval = 5 * time.Minute
+)
+
+// Usage is the number of rows and their approximate size in bytes.
+type Usage struct {
+	Rows  int `json:"rows"`
+	Bytes int `json:"bytes"`
+}
+
+// add adds the value to the Usage.
+func (u *Usage) add(size int) {
+	u.Rows++
+	u.Bytes += size
+}
+
+// DatabaseUsage is the storage used by a database, in total, by each object
+// store, and by each group of values (e.g., the messages of each channel or
+// conversation).
+//
+// Sizes are the length of the JSON of each value as stored, so they
+// approximate, rather than match, the space used on disk.
+//
+// Example JSON:
+//
+//	{
+//	  "databaseName": "AW7fj+J8Ku/6o2gs+ky6R6KTpaiYIJYDZkmS+ZqeXYED_speakeasy",
+//	  "total": {"rows": 1047, "bytes": 1482113},
+//	  "stores": {
+//	    "channels": {"rows": 3, "bytes": 412},
+//	    "files": {"rows": 2, "bytes": 1200345},
+//	    "messages": {"rows": 1042, "bytes": 281356}
+//	  },
+//	  "groups": {
+//	    "ZUVtBkZ8RYPXdYbL8Df7ZyJPrTDdyCZLhPfrBW8vvhcD": {"rows": 1000, "bytes": 270120},
+//	    "Aw5Gq7X1n2uCk2EM/4WMcPfzRQk5uKs9FuMNfXnKOo0D": {"rows": 42, "bytes": 11236}
+//	  }
+//	}
+type DatabaseUsage struct {
+	DatabaseName string           `json:"databaseName"`
+	Total        Usage            `json:"total"`
+	Stores       map[string]Usage `json:"stores"`
+	Groups       map[string]Usage `json:"groups"`
+}
+
+// NewDatabaseUsage returns an empty DatabaseUsage for the database.
+func NewDatabaseUsage(databaseName string) *DatabaseUsage {
+	return &DatabaseUsage{
+		DatabaseName: databaseName,
+		Stores:       make(map[string]Usage),
+		Groups:       make(map[string]Usage),
+	}
+}
+
+// MeasureStore adds the rows and size of every value in the object store to
+// the DatabaseUsage. If groupBy is not nil, each value is also added to the
+// group it returns; values for which it returns an empty string are not
+// grouped.
+func (du *DatabaseUsage) MeasureStore(db *idb.Database, objectStoreName string,
+	groupBy func(value js.Value) string) error {
+	var store Usage
+	groups := make(map[string]Usage)
+	err := NewQuery(objectStoreName).Iter(db, func(value js.Value) error {
+		size := len(utils.JsToJson(value))
+		store.add(size)
+		if groupBy != nil {
+			if group := groupBy(value); group != "" {
+				u := groups[group]
+				u.add(size)
+				groups[group] = u
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.WithMessagef(err,
+			"failed to measure object store %s", objectStoreName)
+	}
+
+	du.Stores[objectStoreName] = store
+	du.Total.Rows += store.Rows
+	du.Total.Bytes += store.Bytes
+	for group, u := range groups {
+		g := du.Groups[group]
+		g.Rows += u.Rows
+		g.Bytes += u.Bytes
+		du.Groups[group] = g
+	}
+	return nil
+}
+
+// StorageEstimate is the storage used by the origin and the quota available to
+// it, as reported by navigator.storage.estimate().
+//
+// Example JSON:
+//
+//	{
+//	  "usage": 5242880,
+//	  "quota": 2147483648,
+//	  "persisted": false
+//	}
+type StorageEstimate struct {
+	Usage uint64 `json:"usage"`
+	Quota uint64 `json:"quota"`
+
+	// Persisted is true if the browser will not evict the storage of the origin
+	// under storage pressure. It is always false when not available (e.g., in
+	// browsers without navigator.storage.persisted).
+	Persisted bool `json:"persisted"`
+}
+
+// UsedFraction returns the fraction of the quota that is used or zero if the
+// quota is unknown.
+func (se StorageEstimate) UsedFraction() float64 {
+	if se.Quota == 0 {
+		return 0
+	}
+	return float64(se.Usage) / float64(se.Quota)
+}
+
+// EstimateStorage returns the storage used by the origin and its quota. It is
+// available on the main thread and in workers.
+func EstimateStorage() (StorageEstimate, error) {
+	storage := js.Global().Get("navigator").Get("storage")
+	if storage.IsUndefined() || storage.Get("estimate").IsUndefined() {
+		return StorageEstimate{},
+			errors.New("navigator.storage.estimate is not supported")
+	}
+
+	result, awaitErr := utils.Await(storage.Call("estimate"))
+	if awaitErr != nil {
+		return StorageEstimate{}, js.Error{Value: awaitErr[0]}
+	}
+
+	var se StorageEstimate
+	if usage := result[0].Get("usage"); usage.Type() == js.TypeNumber {
+		se.Usage = uint64(usage.Float())
+	}
+	if quota := result[0].Get("quota"); quota.Type() == js.TypeNumber {
+		se.Quota = uint64(quota.Float())
+	}
+
+	if !storage.Get("persisted").IsUndefined() {
+		persisted, awaitErr := utils.Await(storage.Call("persisted"))
+		if awaitErr == nil {
+			se.Persisted = persisted[0].Truthy()
+		}
+	}
+
+	return se, nil
+}
+
+// StorageMonitor periodically checks the storage estimate and sends a
+// StorageWarning event once the used fraction of the quota reaches the
+// threshold. It sends another only after usage first drops below the
+// threshold.
+type StorageMonitor struct {
+	threshold     float64
+	eventCallback func(eventType int64, jsonMarshallable any)
+
+	warned bool
+	mux    sync.Mutex
+}
+
+// NewStorageMonitor returns a new StorageMonitor that sends StorageWarning
+// events to the event callback.
+func NewStorageMonitor(threshold float64,
+	eventCallback func(eventType int64, jsonMarshallable any)) *StorageMonitor {
+	return &StorageMonitor{
+		threshold:     threshold,
+		eventCallback: eventCallback,
+	}
+}
+
+// Start checks the storage estimate every interval until the returned stop
+// function is called.
+func (sm *StorageMonitor) Start(interval time.Duration) (stop func()) {
+	quit := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := sm.Check(); err != nil {
+				jww.WARN.Printf("Failed to check storage usage; stopping "+
+					"storage monitor: %+v", err)
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(quit) }) }
+}
+
+// Check gets the storage estimate and sends a StorageWarning event if it
+// crossed the threshold.
+func (sm *StorageMonitor) Check() error {
+	se, err := EstimateStorage()
+	if err != nil {
+		return err
+	}
+	sm.update(se)
+	return nil
+}
+
+// update sends a StorageWarning event if the estimate crossed the threshold
+// since the last update.
+func (sm *StorageMonitor) update(se StorageEstimate) {
+	sm.mux.Lock()
+	defer sm.mux.Unlock()
+
+	used := se.UsedFraction()
+	if used < sm.threshold {
+		sm.warned = false
+		return
+	} else if sm.warned {
+		return
+	}
+	sm.warned = true
+
+	jww.WARN.Printf("Storage usage %d of %d bytes (%.0f%%) reached the "+
+		"warning threshold of %.0f%%", se.Usage, se.Quota, used*100,
+		sm.threshold*100)
+	go sm.eventCallback(StorageWarning, StorageWarningJSON{
+		Usage:     se.Usage,
+		Quota:     se.Quota,
+		Persisted: se.Persisted,
+		Used:      used,
+		Threshold: sm.threshold,
+	})
+}
@@ -0,0 +1,64 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package impl
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests that StorageEstimate.UsedFraction returns the fraction of the quota
+// used and zero when the quota is unknown.
+func TestStorageEstimate_UsedFraction(t *testing.T) {
+	tests := map[StorageEstimate]float64{
+		{Usage: 50, Quota: 200}:  0.25,
+		{Usage: 200, Quota: 200}: 1,
+		{Usage: 50, Quota: 0}:    0,
+	}
+
+	for se, expected := range tests {
+		if used := se.UsedFraction(); used != expected {
+			t.Errorf("Unexpected used fraction for %+v."+
+				"\nexpected: %f\nreceived: %f", se, expected, used)
+		}
+	}
+}
+
+// Tests that StorageMonitor.update sends a StorageWarning event only once when
+// usage crosses the threshold and again after it drops below it.
+func TestStorageMonitor_update(t *testing.T) {
+	events := make(chan StorageWarningJSON, 10)
+	sm := NewStorageMonitor(0.8, func(eventType int64, data any) {
+		if eventType != StorageWarning {
+			t.Errorf("Unexpected event type.\nexpected: %d\nreceived: %d",
+				StorageWarning, eventType)
+		}
+		events <- data.(StorageWarningJSON)
+	})
+
+	usages := []uint64{10, 85, 90, 50, 95}
+	expected := []bool{false, true, false, false, true}
+	for i, usage := range usages {
+		sm.update(StorageEstimate{Usage: usage, Quota: 100})
+
+		select {
+		case e := <-events:
+			if !expected[i] {
+				t.Errorf("Unexpected event for usage %d: %+v", usage, e)
+			} else if e.Usage != usage || e.Threshold != 0.8 {
+				t.Errorf("Unexpected event for usage %d: %+v", usage, e)
+			}
+		case <-time.After(50 * time.Millisecond):
+			if expected[i] {
+				t.Errorf("Timed out waiting for event for usage %d", usage)
+			}
+		}
+	}
+}
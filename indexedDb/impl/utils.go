@@ -20,14 +20,9 @@ import (
 	jww "github.com/spf13/jwalterweatherman"
 	"gitlab.com/elixxir/wasm-utils/utils"
 	"syscall/js"
-	"time"
 )
 
 const (
-	// dbTimeout is the global timeout for operations with the storage
-	// [context.Context].
-	dbTimeout = time.Second
-
 	// ErrDoesNotExist is an error string for got undefined on Get operations.
 	ErrDoesNotExist = "result is undefined"
 )
@@ -35,13 +30,46 @@ const (
 // WebState defines an interface for setting persistent state in a KV format
 // specifically for web-based implementations.
 type WebState interface {
+	// Get returns the value stored with the key.
 	Get(key string) ([]byte, error)
+
+	// Set stores the value with the key, replacing any existing value.
 	Set(key string, value []byte) error
+
+	// Delete removes the value stored with the key. It is not an error if
+	// there is none.
+	Delete(key string) error
+
+	// Keys returns every key that starts with the prefix in ascending order.
+	Keys(prefix string) ([]string, error)
+
+	// Clear removes every key and value.
+	Clear() error
+
+	// GetMany returns the values stored with the keys, read in one
+	// transaction. Keys without a value are not in the returned map.
+	GetMany(keys []string) (map[string][]byte, error)
+
+	// SetMany stores every value with its key in one transaction, so either
+	// all or none of them are stored.
+	SetMany(values map[string][]byte) error
+
+	// CompareAndSwap replaces the value stored with the key with newValue only
+	// if the current value equals oldValue. A nil oldValue matches a key
+	// without a value and a nil newValue deletes the key. Returns true if the
+	// value was swapped.
+	CompareAndSwap(key string, oldValue, newValue []byte) (bool, error)
+
+	// Watch calls cb with the changes to keys that start with the prefix,
+	// including changes made by other tabs. Returns a function that stops
+	// the callback.
+	Watch(prefix string, cb func(changes []StateChange)) (stop func())
 }
 
-// NewContext builds a context for indexedDb operations.
+// NewContext builds a context for indexedDb operations that times out after
+// the duration set with SetTimeout.
 func NewContext() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), dbTimeout)
+	return context.WithTimeout(context.Background(), Timeout())
 }
 
 // EncodeBytes returns the proper IndexedDb encoding for a byte slice into js.Value.
@@ -55,7 +83,7 @@ func SendRequest(request *idb.Request) (js.Value, error) {
 	defer cancel()
 	result, err := request.Await(ctx)
 	if err != nil {
-		return js.Undefined(), err
+		return js.Undefined(), handleError(requestDatabase(request), err)
 	} else if ctx.Err() != nil {
 		return js.Undefined(), ctx.Err()
 	}
@@ -71,16 +99,39 @@ func SendCursorRequest(cur *idb.CursorWithValueRequest,
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
-	return err
+	return handleError(requestDatabase(cur.Request), err)
+}
+
+// SendKeyCursorRequest is a wrapper for the cursorRequest.Iter() method of a
+// key cursor providing a timeout.
+func SendKeyCursorRequest(cur *idb.CursorRequest,
+	iterFunc func(cursor *idb.Cursor) error) error {
+	ctx, cancel := NewContext()
+	defer cancel()
+	err := cur.Iter(ctx, iterFunc)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return handleError(requestDatabase(cur.Request), err)
 }
 
 // Get is a generic helper for getting values from the given [idb.ObjectStore].
 // Only usable by primary key.
 func Get(db *idb.Database, objectStoreName string, key js.Value) (js.Value, error) {
+	var result js.Value
+	err := Retry(func() (err error) {
+		result, err = get(db, objectStoreName, key)
+		return err
+	})
+	return result, err
+}
+
+// get performs a single attempt of Get.
+func get(db *idb.Database, objectStoreName string, key js.Value) (js.Value, error) {
 	parentErr := errors.Errorf("failed to Get %s", objectStoreName)
 
 	// Prepare the Transaction
-	txn, err := db.Transaction(idb.TransactionReadOnly, objectStoreName)
+	txn, err := newTransaction(db, idb.TransactionReadOnly, objectStoreName)
 	if err != nil {
 		return js.Undefined(), errors.WithMessagef(parentErr,
 			"Unable to create Transaction: %+v", err)
@@ -116,52 +167,29 @@ func Get(db *idb.Database, objectStoreName string, key js.Value) (js.Value, erro
 
 // GetAll is a generic helper for getting all values from the given [idb.ObjectStore].
 func GetAll(db *idb.Database, objectStoreName string) ([]js.Value, error) {
-	parentErr := errors.Errorf("failed to GetAll %s", objectStoreName)
-
-	// Prepare the Transaction
-	txn, err := db.Transaction(idb.TransactionReadWrite, objectStoreName)
-	if err != nil {
-		return nil, errors.WithMessagef(parentErr,
-			"Unable to create Transaction: %+v", err)
-	}
-	store, err := txn.ObjectStore(objectStoreName)
-	if err != nil {
-		return nil, errors.WithMessagef(parentErr,
-			"Unable to get ObjectStore: %+v", err)
-	}
-
-	// Set up the operation
-	cursorRequest, err := store.OpenCursor(idb.CursorNext)
-	if err != nil {
-		return nil, errors.WithMessagef(parentErr, "Unable to open Cursor: %+v", err)
-	}
-	result := make([]js.Value, 0)
-
-	// Perform the operation
-	err = SendCursorRequest(cursorRequest,
-		func(cursor *idb.CursorWithValue) error {
-			row, err := cursor.Value()
-			if err != nil {
-				return err
-			}
-			result = append(result, row)
-			return nil
-		})
-	if err != nil {
-		return nil, errors.WithMessagef(parentErr, err.Error())
-	}
-	return result, nil
+	return NewQuery(objectStoreName).GetAll(db)
 }
 
 // GetIndex is a generic helper for getting values from the given
 // [idb.ObjectStore] using the given [idb.Index].
 func GetIndex(db *idb.Database, objectStoreName,
+	indexName string, key js.Value) (js.Value, error) {
+	var result js.Value
+	err := Retry(func() (err error) {
+		result, err = getIndex(db, objectStoreName, indexName, key)
+		return err
+	})
+	return result, err
+}
+
+// getIndex performs a single attempt of GetIndex.
+func getIndex(db *idb.Database, objectStoreName,
 	indexName string, key js.Value) (js.Value, error) {
 	parentErr := errors.Errorf("failed to GetIndex %s/%s",
 		objectStoreName, indexName)
 
 	// Prepare the Transaction
-	txn, err := db.Transaction(idb.TransactionReadOnly, objectStoreName)
+	txn, err := newTransaction(db, idb.TransactionReadOnly, objectStoreName)
 	if err != nil {
 		return js.Undefined(), errors.WithMessagef(parentErr,
 			"Unable to create Transaction: %+v", err)
@@ -200,12 +228,29 @@ func GetIndex(db *idb.Database, objectStoreName,
 	return resultObj, nil
 }
 
+// GetAllIndex is a generic helper for getting all values from the given
+// [idb.ObjectStore] with the given key in the given [idb.Index].
+func GetAllIndex(db *idb.Database, objectStoreName, indexName string,
+	key js.Value) ([]js.Value, error) {
+	return NewQuery(objectStoreName).Index(indexName).Only(key).GetAll(db)
+}
+
 // Put is a generic helper for putting values into the given [idb.ObjectStore].
 // Equivalent to insert if not exists else update. Returns the primary key of
 // the stored object as a js.Value.
 func Put(db *idb.Database, objectStoreName string, value js.Value) (js.Value, error) {
+	var result js.Value
+	err := Retry(func() (err error) {
+		result, err = put(db, objectStoreName, value)
+		return err
+	})
+	return result, err
+}
+
+// put performs a single attempt of Put.
+func put(db *idb.Database, objectStoreName string, value js.Value) (js.Value, error) {
 	// Prepare the Transaction
-	txn, err := db.Transaction(idb.TransactionReadWrite, objectStoreName)
+	txn, err := newTransaction(db, idb.TransactionReadWrite, objectStoreName)
 	if err != nil {
 		return js.Undefined(), errors.Errorf("Unable to create Transaction: %+v", err)
 	}
@@ -231,13 +276,86 @@ func Put(db *idb.Database, objectStoreName string, value js.Value) (js.Value, er
 	return resultObj, nil
 }
 
+// PutBatch is a generic helper for putting several values into the given
+// [idb.ObjectStore] within a single transaction. Either all values are stored
+// or, if any put fails (e.g., on a constraint error), none are. Returns the
+// primary keys of the stored objects in the same order as the values.
+func PutBatch(db *idb.Database, objectStoreName string,
+	values []js.Value) ([]js.Value, error) {
+	var keys []js.Value
+	err := Retry(func() (err error) {
+		keys, err = putBatch(db, objectStoreName, values)
+		return err
+	})
+	return keys, err
+}
+
+// putBatch performs a single attempt of PutBatch.
+func putBatch(db *idb.Database, objectStoreName string,
+	values []js.Value) ([]js.Value, error) {
+	parentErr := errors.Errorf("failed to PutBatch %s", objectStoreName)
+
+	// Prepare the Transaction
+	txn, err := newTransaction(db, idb.TransactionReadWrite, objectStoreName)
+	if err != nil {
+		return nil, errors.WithMessagef(parentErr,
+			"Unable to create Transaction: %+v", err)
+	}
+	store, err := txn.ObjectStore(objectStoreName)
+	if err != nil {
+		return nil, errors.WithMessagef(parentErr,
+			"Unable to get ObjectStore: %+v", err)
+	}
+
+	// Set up the operations. All requests are made before waiting on the
+	// transaction so that it is committed as a whole or not at all.
+	requests := make([]*idb.Request, len(values))
+	for i, value := range values {
+		requests[i], err = store.Put(value)
+		if err != nil {
+			if abortErr := txn.Abort(); abortErr != nil {
+				jww.ERROR.Printf("Failed to abort PutBatch transaction: %+v",
+					abortErr)
+			}
+			return nil, errors.WithMessagef(parentErr,
+				"Unable to Put value %d: %+v", i, err)
+		}
+	}
+
+	// Perform the operations
+	ctx, cancel := NewContext()
+	defer cancel()
+	if err = txn.Await(ctx); err != nil {
+		return nil, errors.WithMessagef(parentErr,
+			"Unable to commit Transaction: %+v", handleError(db, err))
+	}
+
+	keys := make([]js.Value, len(requests))
+	for i, request := range requests {
+		if keys[i], err = request.Result(); err != nil {
+			return nil, errors.WithMessagef(parentErr,
+				"Unable to get result of value %d: %+v", i, err)
+		}
+	}
+	jww.DEBUG.Printf("Successfully put %d values in %s",
+		len(values), objectStoreName)
+	return keys, nil
+}
+
 // Delete is a generic helper for removing values from the given
 // [idb.ObjectStore]. Only usable by primary key.
 func Delete(db *idb.Database, objectStoreName string, key js.Value) error {
+	return Retry(func() error {
+		return deleteValue(db, objectStoreName, key)
+	})
+}
+
+// deleteValue performs a single attempt of Delete.
+func deleteValue(db *idb.Database, objectStoreName string, key js.Value) error {
 	parentErr := errors.Errorf("failed to Delete %s", objectStoreName)
 
 	// Prepare the Transaction
-	txn, err := db.Transaction(idb.TransactionReadWrite, objectStoreName)
+	txn, err := newTransaction(db, idb.TransactionReadWrite, objectStoreName)
 	if err != nil {
 		return errors.WithMessagef(parentErr,
 			"Unable to create Transaction: %+v", err)
@@ -288,12 +406,147 @@ func DeleteIndex(db *idb.Database, objectStoreName,
 	return nil
 }
 
+// Count returns the number of values in the given [idb.ObjectStore].
+func Count(db *idb.Database, objectStoreName string) (int, error) {
+	var count int
+	err := Retry(func() (err error) {
+		count, err = countValues(db, objectStoreName)
+		return err
+	})
+	return count, err
+}
+
+// countValues performs a single attempt of Count.
+func countValues(db *idb.Database, objectStoreName string) (int, error) {
+	parentErr := errors.Errorf("failed to Count %s", objectStoreName)
+
+	txn, err := newTransaction(db, idb.TransactionReadOnly, objectStoreName)
+	if err != nil {
+		return 0, errors.WithMessagef(parentErr,
+			"Unable to create Transaction: %+v", err)
+	}
+	store, err := txn.ObjectStore(objectStoreName)
+	if err != nil {
+		return 0, errors.WithMessagef(parentErr,
+			"Unable to get ObjectStore: %+v", err)
+	}
+
+	// Perform the operation
+	countRequest, err := store.Count()
+	if err != nil {
+		return 0, errors.WithMessagef(parentErr,
+			"Unable to Count ObjectStore: %+v", err)
+	}
+	ctx, cancel := NewContext()
+	defer cancel()
+	count, err := countRequest.Await(ctx)
+	if err != nil {
+		return 0, errors.WithMessagef(parentErr,
+			"Unable to Count ObjectStore: %+v", handleError(db, err))
+	}
+	return int(count), nil
+}
+
+// UpdateAll is a generic helper for modifying every value in the given
+// [idb.ObjectStore] within a single transaction. The update function returns
+// the new value and true if the stored value should be replaced. Returns the
+// number of values replaced.
+func UpdateAll(db *idb.Database, objectStoreName string,
+	update func(value js.Value) (js.Value, bool, error)) (int, error) {
+	_, numUpdated, err := UpdateBatch(
+		db, objectStoreName, js.Undefined(), 0, update)
+	return numUpdated, err
+}
+
+// UpdateBatch is a generic helper for modifying up to limit values in the given
+// [idb.ObjectStore] within a single transaction, starting after the primary
+// key after. If after is undefined, it starts at the beginning of the store. If
+// limit is 0, all remaining values are visited.
+//
+// The update function returns the new value and true if the stored value
+// should be replaced. Returns the primary key of the last visited value, or
+// undefined if the end of the store was reached, and the number of values
+// replaced.
+func UpdateBatch(db *idb.Database, objectStoreName string, after js.Value,
+	limit int, update func(value js.Value) (js.Value, bool, error)) (
+	last js.Value, numUpdated int, err error) {
+	parentErr := errors.Errorf("failed to UpdateBatch %s", objectStoreName)
+
+	txn, err := newTransaction(db, idb.TransactionReadWrite, objectStoreName)
+	if err != nil {
+		return js.Undefined(), 0, errors.WithMessagef(parentErr,
+			"Unable to create Transaction: %+v", err)
+	}
+	store, err := txn.ObjectStore(objectStoreName)
+	if err != nil {
+		return js.Undefined(), 0, errors.WithMessagef(parentErr,
+			"Unable to get ObjectStore: %+v", err)
+	}
+
+	// Set up the operation
+	var cursorRequest *idb.CursorWithValueRequest
+	if after.IsUndefined() {
+		cursorRequest, err = store.OpenCursor(idb.CursorNext)
+	} else {
+		var keyRange *idb.KeyRange
+		keyRange, err = idb.NewKeyRangeLowerBound(after, true)
+		if err != nil {
+			return js.Undefined(), 0, errors.WithMessagef(parentErr,
+				"Unable to create KeyRange: %+v", err)
+		}
+		cursorRequest, err = store.OpenCursorRange(keyRange, idb.CursorNext)
+	}
+	if err != nil {
+		return js.Undefined(), 0, errors.WithMessagef(parentErr,
+			"Unable to open Cursor: %+v", err)
+	}
+
+	// Perform the operation
+	var numVisited int
+	last = js.Undefined()
+	err = SendCursorRequest(cursorRequest,
+		func(cursor *idb.CursorWithValue) error {
+			if limit > 0 && numVisited >= limit {
+				return idb.ErrCursorStopIter
+			}
+			numVisited++
+
+			value, err := cursor.Value()
+			if err != nil {
+				return err
+			}
+			newValue, changed, err := update(value)
+			if err != nil {
+				return err
+			}
+			if changed {
+				if _, err = cursor.Update(newValue); err != nil {
+					return err
+				}
+				numUpdated++
+			}
+
+			last, err = cursor.PrimaryKey()
+			return err
+		})
+	if err != nil {
+		return js.Undefined(), numUpdated, errors.WithMessagef(parentErr,
+			"Unable to update ObjectStore: %+v", err)
+	}
+
+	// The end of the store was reached if fewer values than the limit remained
+	if limit == 0 || numVisited < limit {
+		last = js.Undefined()
+	}
+	return last, numUpdated, nil
+}
+
 // Dump returns the given [idb.ObjectStore] contents to string slice for
 // testing and debugging purposes.
 func Dump(db *idb.Database, objectStoreName string) ([]string, error) {
 	parentErr := errors.Errorf("failed to Dump %s", objectStoreName)
 
-	txn, err := db.Transaction(idb.TransactionReadOnly, objectStoreName)
+	txn, err := newTransaction(db, idb.TransactionReadOnly, objectStoreName)
 	if err != nil {
 		return nil, errors.WithMessagef(parentErr,
 			"Unable to create Transaction: %+v", err)
@@ -0,0 +1,122 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// This file contains the storage interface of the storage-agnostic event
+// models. It mirrors the parts of IndexedDB used by the event models so that
+// the same model logic can run on IndexedDB in the browser or on any other
+// storage, such as the in-memory MemoryBackend.
+
+package model
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned when there is no value with the key.
+	ErrNotFound = errors.New("value does not exist")
+
+	// ErrConstraint is returned when a put would break the uniqueness of a
+	// primary key or unique index.
+	ErrConstraint = errors.New("constraint violated")
+)
+
+// Schema describes the object stores of a database.
+type Schema struct {
+	Stores []StoreSchema
+}
+
+// StoreSchema describes an object store. Values are JSON objects and KeyPath
+// is the name of the field holding the primary key of each value.
+type StoreSchema struct {
+	Name    string
+	KeyPath string
+
+	// AutoIncrement is true if a value put without its primary key is given
+	// the next integer key, starting at 1, which is set in its KeyPath field.
+	AutoIncrement bool
+
+	Indexes []IndexSchema
+}
+
+// IndexSchema describes an index of an object store on the field KeyPath.
+// Values without the field, or with a null field, are not in the index.
+type IndexSchema struct {
+	Name    string
+	KeyPath string
+	Unique  bool
+}
+
+// StoreNames returns the name of every object store in the Schema.
+func (s Schema) StoreNames() []string {
+	names := make([]string, len(s.Stores))
+	for i, store := range s.Stores {
+		names[i] = store.Name
+	}
+	return names
+}
+
+// Backend is a database of object stores described by a Schema.
+type Backend interface {
+	// Transact runs fn inside a single read-write transaction spanning the
+	// given object stores. All writes made by fn are committed together once
+	// it returns. If fn returns an error, none of its writes are committed and
+	// the error is returned.
+	Transact(storeNames []string, fn func(tx Tx) error) error
+}
+
+// Tx is a transaction of a Backend. It must not be used after the function
+// it was passed to returns. Once an operation returns an error, the function
+// must return an error too; as in IndexedDB, a failed request may abort the
+// transaction.
+//
+// Keys are any value that JSON marshals into a string or a number, and are
+// equal if their JSON is equal. For example, the key of a []byte field is the
+// []byte itself, since both are marshalled into base 64.
+type Tx interface {
+	// Get returns the JSON of the value with the primary key in the object
+	// store or ErrNotFound if there is none.
+	Get(storeName string, key any) ([]byte, error)
+
+	// GetIndex returns the JSON of the first value with the key in the index
+	// or ErrNotFound if there is none.
+	GetIndex(storeName, indexName string, key any) ([]byte, error)
+
+	// GetAll returns the JSON of every value in the object store in ascending
+	// order of primary key.
+	GetAll(storeName string) ([][]byte, error)
+
+	// GetAllIndex returns the JSON of every value with the key in the index in
+	// ascending order of primary key.
+	GetAllIndex(storeName, indexName string, key any) ([][]byte, error)
+
+	// Put inserts the JSON value into the object store or replaces the value
+	// with the same primary key. Returns the JSON of the primary key. Returns
+	// ErrConstraint if another value has the same key in a unique index.
+	Put(storeName string, value []byte) (json.RawMessage, error)
+
+	// Delete removes the value with the primary key from the object store. It
+	// is not an error if there is none.
+	Delete(storeName string, key any) error
+
+	// DeleteAllIndex removes every value with the key in the index. Returns
+	// the number of values removed.
+	DeleteAllIndex(storeName, indexName string, key any) (int, error)
+}
+
+// UintKey parses the JSON of an integer primary key, such as one returned by
+// Tx.Put for an AutoIncrement object store.
+func UintKey(key json.RawMessage) (uint64, error) {
+	n, err := strconv.ParseUint(string(key), 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid integer key %s", key)
+	}
+	return n, nil
+}
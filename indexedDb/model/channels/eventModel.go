@@ -0,0 +1,582 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Package channels contains a [channels.EventModel] that stores its data in
+// any [model.Backend]. It has the same logic as the IndexedDB event model so
+// that the logic can be run and tested without a browser.
+package channels
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/client/v4/bindings"
+	"gitlab.com/elixxir/client/v4/channels"
+	"gitlab.com/elixxir/client/v4/cmix/rounds"
+	cryptoBroadcast "gitlab.com/elixxir/crypto/broadcast"
+	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
+	"gitlab.com/elixxir/crypto/message"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/model"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// EventUpdate is called on every change to the event model with the type of
+// the event and its JSON marshallable data (see [bindings.MessageReceived]).
+type EventUpdate func(eventType int64, jsonMarshallable any)
+
+// EventModel implements [channels.EventModel] on a [model.Backend].
+type EventModel struct {
+	backend       model.Backend
+	cipher        idbCrypto.Cipher
+	eventCallback EventUpdate
+}
+
+// NewEventModel returns an EventModel that stores its data in the backend,
+// which must have the object stores of Schema. If the cipher is not nil, the
+// text of each message is encrypted with it.
+func NewEventModel(backend model.Backend, cipher idbCrypto.Cipher,
+	eventCallback EventUpdate) *EventModel {
+	return &EventModel{
+		backend:       backend,
+		cipher:        cipher,
+		eventCallback: eventCallback,
+	}
+}
+
+// JoinChannel is called whenever a channel is joined locally.
+func (em *EventModel) JoinChannel(channel *cryptoBroadcast.Channel) {
+	parentErr := errors.New("failed to JoinChannel")
+
+	newChannel := &Channel{
+		ID:          channel.ReceptionID.Marshal(),
+		Name:        channel.Name,
+		Description: channel.Description,
+	}
+	channelData, err := json.Marshal(newChannel)
+	if err != nil {
+		jww.ERROR.Printf("%+v", errors.WithMessagef(parentErr,
+			"Unable to marshal Channel: %+v", err))
+		return
+	}
+
+	err = em.backend.Transact([]string{channelStoreName},
+		func(tx model.Tx) error {
+			_, err := tx.Put(channelStoreName, channelData)
+			return err
+		})
+	if err != nil {
+		jww.ERROR.Printf("%+v", errors.WithMessagef(parentErr,
+			"Unable to put Channel: %+v", err))
+	}
+}
+
+// LeaveChannel is called whenever a channel is left locally. The channel and
+// its messages are deleted together.
+func (em *EventModel) LeaveChannel(channelID *id.ID) {
+	parentErr := errors.New("failed to LeaveChannel")
+
+	err := em.backend.Transact([]string{channelStoreName, messageStoreName},
+		func(tx model.Tx) error {
+			err := tx.Delete(channelStoreName, channelID.Marshal())
+			if err != nil {
+				return errors.Errorf("Unable to delete Channel: %+v", err)
+			}
+
+			_, err = tx.DeleteAllIndex(
+				messageStoreName, messageStoreChannelIndex, channelID.Marshal())
+			if err != nil {
+				return errors.Errorf(
+					"Deleting Channel's Message data failed: %+v", err)
+			}
+			return nil
+		})
+	if err != nil {
+		jww.ERROR.Printf("%+v", errors.WithMessage(parentErr, err.Error()))
+		return
+	}
+	jww.DEBUG.Printf("Successfully deleted channel: %s", channelID)
+}
+
+// ReceiveMessage is called whenever a message is received on a given channel.
+// It may be called multiple times on the same message; it is incumbent on the
+// user of the API to filter such calls by message ID.
+func (em *EventModel) ReceiveMessage(channelID *id.ID, messageID message.ID,
+	nickname, text string, pubKey ed25519.PublicKey, dmToken uint32,
+	codeset uint8, timestamp time.Time, lease time.Duration, round rounds.Round,
+	mType channels.MessageType, status channels.SentStatus, hidden bool) uint64 {
+	return em.receiveWrapper(channelID, messageID, nil, nickname, text,
+		pubKey, dmToken, codeset, timestamp, lease, round, mType, status,
+		hidden, "Message")
+}
+
+// ReceiveReply is called whenever a message is received that is a reply on a
+// given channel. It may be called multiple times on the same message; it is
+// incumbent on the user of the API to filter such calls by message ID.
+//
+// Messages may arrive out of order, so a reply, in theory, can arrive before
+// the initial message. As a result, it may be important to buffer replies.
+func (em *EventModel) ReceiveReply(channelID *id.ID, messageID,
+	replyTo message.ID, nickname, text string, pubKey ed25519.PublicKey,
+	dmToken uint32, codeset uint8, timestamp time.Time, lease time.Duration,
+	round rounds.Round, mType channels.MessageType, status channels.SentStatus,
+	hidden bool) uint64 {
+	return em.receiveWrapper(channelID, messageID, replyTo.Bytes(), nickname,
+		text, pubKey, dmToken, codeset, timestamp, lease, round, mType, status,
+		hidden, "Reply")
+}
+
+// ReceiveReaction is called whenever a reaction to a message is received on a
+// given channel. It may be called multiple times on the same reaction; it is
+// incumbent on the user of the API to filter such calls by message ID.
+//
+// Messages may arrive out of order, so a reply, in theory, can arrive before
+// the initial message. As a result, it may be important to buffer reactions.
+func (em *EventModel) ReceiveReaction(channelID *id.ID, messageID,
+	reactionTo message.ID, nickname, reaction string, pubKey ed25519.PublicKey,
+	dmToken uint32, codeset uint8, timestamp time.Time, lease time.Duration,
+	round rounds.Round, mType channels.MessageType, status channels.SentStatus,
+	hidden bool) uint64 {
+	return em.receiveWrapper(channelID, messageID, reactionTo.Bytes(),
+		nickname, reaction, pubKey, dmToken, codeset, timestamp, lease, round,
+		mType, status, hidden, "Reaction")
+}
+
+// receiveWrapper stores a received message, reply, or reaction and calls the
+// event callback. Returns the UUID of the message or zero on error.
+func (em *EventModel) receiveWrapper(channelID *id.ID, messageID message.ID,
+	parentID []byte, nickname, text string, pubKey ed25519.PublicKey,
+	dmToken uint32, codeset uint8, timestamp time.Time, lease time.Duration,
+	round rounds.Round, mType channels.MessageType, status channels.SentStatus,
+	hidden bool, kind string) uint64 {
+	var err error
+
+	// Handle encryption, if it is present
+	if em.cipher != nil {
+		text, err = em.cipher.Encrypt([]byte(text))
+		if err != nil {
+			jww.ERROR.Printf("Failed to encrypt %s: %+v", kind, err)
+			return 0
+		}
+	}
+
+	msgToInsert := buildMessage(channelID.Marshal(), messageID.Bytes(),
+		parentID, nickname, text, pubKey, dmToken, codeset, timestamp, lease,
+		round.ID, mType, false, hidden, status)
+
+	uuid, err := em.upsertMessage(msgToInsert)
+	if err != nil {
+		jww.ERROR.Printf("Failed to receive %s: %+v", kind, err)
+		return 0
+	}
+
+	em.eventCallback(bindings.MessageReceived, bindings.MessageReceivedJSON{
+		UUID:      int64(uuid),
+		ChannelID: channelID,
+		Update:    false,
+	})
+	return uuid
+}
+
+// UpdateFromUUID is called whenever a message at the UUID is modified.
+//
+// messageID, timestamp, round, pinned, hidden, and status are all nillable
+// and may be updated based upon the UUID at a later date. If a nil value is
+// passed, then make no update.
+//
+// Returns an error if the message cannot be updated. It must return
+// [channels.NoMessageErr] if the message does not exist.
+func (em *EventModel) UpdateFromUUID(uuid uint64, messageID *message.ID,
+	timestamp *time.Time, round *rounds.Round, pinned, hidden *bool,
+	status *channels.SentStatus) error {
+	parentErr := "failed to UpdateFromUUID"
+
+	// Get and update the existing Message in one transaction so that no other
+	// change to the Message is lost in between
+	var channelID []byte
+	err := em.backend.Transact([]string{messageStoreName},
+		func(tx model.Tx) error {
+			msgData, err := tx.Get(messageStoreName, uuid)
+			if err != nil {
+				return err
+			}
+			currentMsg, err := unmarshalMessage(msgData)
+			if err != nil {
+				return err
+			}
+			channelID = currentMsg.ChannelID
+
+			_, err = updateMessage(tx, currentMsg, messageID, timestamp,
+				round, pinned, hidden, status)
+			return err
+		})
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return errors.WithMessage(channels.NoMessageErr, parentErr)
+		}
+		return errors.WithMessage(err, parentErr)
+	}
+
+	if err = em.messageUpdated(uuid, channelID); err != nil {
+		return errors.WithMessage(err, parentErr)
+	}
+	return nil
+}
+
+// UpdateFromMessageID is called whenever a message with the message ID is
+// modified.
+//
+// The API needs to return the UUID of the modified message that can be
+// referenced at a later time.
+//
+// timestamp, round, pinned, hidden, and status are all nillable and may be
+// updated based upon the UUID at a later date. If a nil value is passed, then
+// make no update.
+//
+// Returns an error if the message cannot be updated. It must return
+// [channels.NoMessageErr] if the message does not exist.
+func (em *EventModel) UpdateFromMessageID(messageID message.ID,
+	timestamp *time.Time, round *rounds.Round, pinned, hidden *bool,
+	status *channels.SentStatus) (uint64, error) {
+	parentErr := "failed to UpdateFromMessageID"
+
+	// Get and update the existing Message in one transaction so that no other
+	// change to the Message is lost in between
+	var uuid uint64
+	var channelID []byte
+	err := em.backend.Transact([]string{messageStoreName},
+		func(tx model.Tx) error {
+			msgData, err := tx.GetIndex(messageStoreName,
+				messageStoreMessageIndex, messageID.Marshal())
+			if err != nil {
+				return err
+			}
+			currentMsg, err := unmarshalMessage(msgData)
+			if err != nil {
+				return err
+			}
+			channelID = currentMsg.ChannelID
+
+			uuid, err = updateMessage(tx, currentMsg, &messageID, timestamp,
+				round, pinned, hidden, status)
+			return err
+		})
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return 0, errors.WithMessage(channels.NoMessageErr, parentErr)
+		}
+		return 0, errors.WithMessage(err, parentErr)
+	}
+
+	if err = em.messageUpdated(uuid, channelID); err != nil {
+		return 0, errors.WithMessage(err, parentErr)
+	}
+	return uuid, nil
+}
+
+// messageUpdated calls the event callback for the update of the message with
+// the UUID.
+func (em *EventModel) messageUpdated(uuid uint64, channelIDBytes []byte) error {
+	channelID, err := id.Unmarshal(channelIDBytes)
+	if err != nil {
+		return err
+	}
+
+	em.eventCallback(bindings.MessageReceived, bindings.MessageReceivedJSON{
+		UUID:      int64(uuid),
+		ChannelID: channelID,
+		Update:    true,
+	})
+	return nil
+}
+
+// buildMessage is a private helper that converts typical [channels.EventModel]
+// inputs into a basic Message structure for insertion into storage.
+//
+// NOTE: ID is not set inside this function because we want to use the
+// autoincrement key by default. If you are trying to overwrite an existing
+// message, then you need to set it manually yourself.
+func buildMessage(channelID, messageID, parentID []byte, nickname,
+	text string, pubKey ed25519.PublicKey, dmToken uint32, codeset uint8,
+	timestamp time.Time, lease time.Duration, round id.Round,
+	mType channels.MessageType, pinned, hidden bool,
+	status channels.SentStatus) *Message {
+	return &Message{
+		MessageID:       messageID,
+		Nickname:        nickname,
+		ChannelID:       channelID,
+		ParentMessageID: parentID,
+		Timestamp:       timestamp,
+		Lease:           strconv.FormatInt(int64(lease), 10),
+		Status:          uint8(status),
+		Hidden:          hidden,
+		Pinned:          pinned,
+		Text:            text,
+		Type:            uint16(mType),
+		Round:           uint64(round),
+		// User Identity Info
+		Pubkey:         pubKey,
+		DmToken:        dmToken,
+		CodesetVersion: codeset,
+	}
+}
+
+// MessageUpdate is a change made to a stored message by UpdateFromUUID or
+// UpdateFromMessageID. Nil fields are left unchanged.
+//
+// It is shared with the IndexedDB event model so that both apply updates and
+// duplicate messages the same way.
+type MessageUpdate struct {
+	MessageID *message.ID
+	Timestamp *time.Time
+	Round     *rounds.Round
+	Pinned    *bool
+	Hidden    *bool
+	Status    *channels.SentStatus
+}
+
+// DuplicateUpdate returns the MessageUpdate made to the stored message when a
+// message with the same message ID is received again. The timestamp, round,
+// pinned, hidden, and status of the received message replace those stored.
+func DuplicateUpdate(timestamp time.Time, round uint64, pinned, hidden bool,
+	status uint8) MessageUpdate {
+	rnd := rounds.Round{ID: id.Round(round)}
+	sentStatus := channels.SentStatus(status)
+	return MessageUpdate{
+		Timestamp: &timestamp,
+		Round:     &rnd,
+		Pinned:    &pinned,
+		Hidden:    &hidden,
+		Status:    &sentStatus,
+	}
+}
+
+// Apply sets each non-nil change of the MessageUpdate in the fields of a
+// stored message.
+func (u MessageUpdate) Apply(messageID *[]byte, timestamp *time.Time,
+	round *uint64, pinned, hidden *bool, status *uint8) {
+	if u.Status != nil {
+		*status = uint8(*u.Status)
+	}
+	if u.MessageID != nil {
+		*messageID = u.MessageID.Bytes()
+	}
+	if u.Round != nil {
+		*round = uint64(u.Round.ID)
+	}
+	if u.Timestamp != nil {
+		*timestamp = *u.Timestamp
+	}
+	if u.Pinned != nil {
+		*pinned = *u.Pinned
+	}
+	if u.Hidden != nil {
+		*hidden = *u.Hidden
+	}
+}
+
+// updateMessage applies each of the non-nil changes to the Message and stores
+// it in the transaction. Returns the UUID of the Message.
+func updateMessage(tx model.Tx, currentMsg *Message, messageID *message.ID,
+	timestamp *time.Time, round *rounds.Round, pinned, hidden *bool,
+	status *channels.SentStatus) (uint64, error) {
+	MessageUpdate{messageID, timestamp, round, pinned, hidden, status}.Apply(
+		&currentMsg.MessageID, &currentMsg.Timestamp, &currentMsg.Round,
+		&currentMsg.Pinned, &currentMsg.Hidden, &currentMsg.Status)
+
+	// Store the updated Message
+	return putMessage(tx, currentMsg)
+}
+
+// upsertMessage stores the Message. If a Message with the same message ID
+// already exists, it is updated with the timestamp, round, pinned, hidden, and
+// status of the new Message instead. Returns the UUID of the stored Message.
+func (em *EventModel) upsertMessage(msg *Message) (uint64, error) {
+	var uuid uint64
+	err := em.backend.Transact([]string{messageStoreName},
+		func(tx model.Tx) error {
+			var err error
+			uuid, err = putMessage(tx, msg)
+			return err
+		})
+	if err == nil {
+		jww.DEBUG.Printf("Successfully stored message %d", uuid)
+		return uuid, nil
+	}
+
+	// Do not error out when this message already exists inside the DB.
+	// Instead, re-attempt as an update in a new transaction, since a failed
+	// put may abort the transaction it was made in.
+	if msg.ID != 0 || !errors.Is(err, model.ErrConstraint) {
+		// Always error out when not an insert attempt
+		return 0, errors.Errorf("Unable to put Message: %+v", err)
+	}
+	msgID, inErr := message.UnmarshalID(msg.MessageID)
+	if inErr != nil {
+		return 0, errors.Errorf(
+			"Unable to put Message: %+v: bad msg ID: %+v", err, inErr)
+	}
+
+	jww.WARN.Printf("upsertMessage duplicate: %+v", err)
+	u := DuplicateUpdate(
+		msg.Timestamp, msg.Round, msg.Pinned, msg.Hidden, msg.Status)
+	return em.UpdateFromMessageID(
+		msgID, u.Timestamp, u.Round, u.Pinned, u.Hidden, u.Status)
+}
+
+// putMessage stores the Message in the transaction. Returns the UUID of the
+// Message.
+func putMessage(tx model.Tx, msg *Message) (uint64, error) {
+	msgData, err := json.Marshal(msg)
+	if err != nil {
+		return 0, errors.Errorf("Unable to marshal Message: %+v", err)
+	}
+	key, err := tx.Put(messageStoreName, msgData)
+	if err != nil {
+		return 0, err
+	}
+	return model.UintKey(key)
+}
+
+// GetMessage returns the message with the given [channel.MessageID]. The
+// content of the message is decrypted.
+func (em *EventModel) GetMessage(
+	messageID message.ID) (channels.ModelMessage, error) {
+	var lookupResult *Message
+	err := em.backend.Transact([]string{messageStoreName},
+		func(tx model.Tx) error {
+			msgData, err := tx.GetIndex(messageStoreName,
+				messageStoreMessageIndex, messageID.Marshal())
+			if err != nil {
+				return err
+			}
+			lookupResult, err = unmarshalMessage(msgData)
+			return err
+		})
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return channels.ModelMessage{},
+				errors.WithMessage(channels.NoMessageErr, err.Error())
+		}
+		return channels.ModelMessage{}, err
+	}
+	return em.toModelMessage(lookupResult)
+}
+
+// toModelMessage converts the Message into a [channels.ModelMessage],
+// decrypting its text.
+func (em *EventModel) toModelMessage(
+	lookupResult *Message) (channels.ModelMessage, error) {
+	messageID, err := message.UnmarshalID(lookupResult.MessageID)
+	if err != nil {
+		return channels.ModelMessage{}, err
+	}
+
+	var channelId *id.ID
+	if lookupResult.ChannelID != nil {
+		channelId, err = id.Unmarshal(lookupResult.ChannelID)
+		if err != nil {
+			return channels.ModelMessage{}, err
+		}
+	}
+
+	var parentMsgId message.ID
+	if lookupResult.ParentMessageID != nil {
+		parentMsgId, err = message.UnmarshalID(lookupResult.ParentMessageID)
+		if err != nil {
+			return channels.ModelMessage{}, err
+		}
+	}
+
+	lease := time.Duration(0)
+	if len(lookupResult.Lease) > 0 {
+		leaseInt, err := strconv.ParseInt(lookupResult.Lease, 10, 64)
+		if err != nil {
+			return channels.ModelMessage{}, err
+		}
+		lease = time.Duration(leaseInt)
+	}
+
+	content := []byte(lookupResult.Text)
+	if em.cipher != nil && len(lookupResult.Text) > 0 {
+		content, err = em.cipher.Decrypt(lookupResult.Text)
+		if err != nil {
+			return channels.ModelMessage{},
+				errors.Errorf("Failed to decrypt Message: %+v", err)
+		}
+	}
+
+	return channels.ModelMessage{
+		UUID:            lookupResult.ID,
+		Nickname:        lookupResult.Nickname,
+		MessageID:       messageID,
+		ChannelID:       channelId,
+		ParentMessageID: parentMsgId,
+		Timestamp:       lookupResult.Timestamp,
+		Lease:           lease,
+		Status:          channels.SentStatus(lookupResult.Status),
+		Hidden:          lookupResult.Hidden,
+		Pinned:          lookupResult.Pinned,
+		Content:         content,
+		Type:            channels.MessageType(lookupResult.Type),
+		Round:           id.Round(lookupResult.Round),
+		PubKey:          lookupResult.Pubkey,
+		CodesetVersion:  lookupResult.CodesetVersion,
+		DmToken:         lookupResult.DmToken,
+	}, nil
+}
+
+// DeleteMessage removes a message with the given messageID from storage.
+func (em *EventModel) DeleteMessage(messageID message.ID) error {
+	parentErr := errors.Errorf(
+		"failed to DeleteIndex %s/%s", messageStoreName, messageID)
+
+	err := em.backend.Transact([]string{messageStoreName},
+		func(tx model.Tx) error {
+			msgData, err := tx.GetIndex(messageStoreName,
+				messageStoreMessageIndex, messageID.Marshal())
+			if err != nil {
+				return err
+			}
+			msg, err := unmarshalMessage(msgData)
+			if err != nil {
+				return err
+			}
+			return tx.Delete(messageStoreName, msg.ID)
+		})
+	if err != nil {
+		return errors.WithMessagef(parentErr, "%+v", err)
+	}
+
+	em.eventCallback(bindings.MessageDeleted, bindings.MessageDeletedJSON{
+		MessageID: messageID,
+	})
+	return nil
+}
+
+// MuteUser is called whenever a user is muted or unmuted.
+func (em *EventModel) MuteUser(
+	channelID *id.ID, pubKey ed25519.PublicKey, unmute bool) {
+	em.eventCallback(bindings.UserMuted, bindings.UserMutedJSON{
+		ChannelID: channelID,
+		PubKey:    pubKey,
+		Unmute:    unmute,
+	})
+}
+
+// unmarshalMessage returns the Message in the JSON.
+func unmarshalMessage(msgData []byte) (*Message, error) {
+	resultMsg := &Message{}
+	if err := json.Unmarshal(msgData, resultMsg); err != nil {
+		return nil, errors.Errorf("Unable to unmarshal Message: %+v", err)
+	}
+	return resultMsg, nil
+}
@@ -0,0 +1,88 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package channels
+
+import (
+	"time"
+
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/model"
+)
+
+const (
+	// Text representation of primary key value (keyPath).
+	pkeyName = "id"
+
+	// Text representation of the names of the various object stores.
+	messageStoreName = "messages"
+	channelStoreName = "channels"
+
+	// Message index names.
+	messageStoreMessageIndex = "message_id_index"
+	messageStoreChannelIndex = "channel_id_index"
+	messageStoreParentIndex  = "parent_message_id_index"
+
+	// Message keyPath names (must match json struct tags).
+	messageStoreMessage = "message_id"
+	messageStoreChannel = "channel_id"
+	messageStoreParent  = "parent_message_id"
+)
+
+// Schema is the [model.Schema] of the database of an EventModel. A
+// [model.Backend] passed to NewEventModel must have these object stores.
+var Schema = model.Schema{Stores: []model.StoreSchema{
+	{
+		Name:          messageStoreName,
+		KeyPath:       pkeyName,
+		AutoIncrement: true,
+		Indexes: []model.IndexSchema{
+			{Name: messageStoreMessageIndex, KeyPath: messageStoreMessage,
+				Unique: true},
+			{Name: messageStoreChannelIndex, KeyPath: messageStoreChannel},
+			{Name: messageStoreParentIndex, KeyPath: messageStoreParent},
+		},
+	},
+	{
+		Name:    channelStoreName,
+		KeyPath: pkeyName,
+	},
+}}
+
+// Message defines the stored representation of a single Message.
+//
+// A Message belongs to one Channel.
+//
+// A Message may belong to one Message (Parent).
+type Message struct {
+	ID              uint64    `json:"id,omitempty"` // Matches pkeyName
+	Nickname        string    `json:"nickname"`
+	MessageID       []byte    `json:"message_id"`        // Index
+	ChannelID       []byte    `json:"channel_id"`        // Index
+	ParentMessageID []byte    `json:"parent_message_id"` // Index
+	Timestamp       time.Time `json:"timestamp"`
+	Lease           string    `json:"lease_v2"`
+	Status          uint8     `json:"status"`
+	Hidden          bool      `json:"hidden"`
+	Pinned          bool      `json:"pinned"`
+	Text            string    `json:"text"`
+	Type            uint16    `json:"type"`
+	Round           uint64    `json:"round"`
+
+	// User cryptographic Identity struct -- could be pulled out
+	Pubkey         []byte `json:"pubkey"`
+	DmToken        uint32 `json:"dm_token"`
+	CodesetVersion uint8  `json:"codeset_version"`
+}
+
+// Channel defines the stored representation of a single Channel.
+//
+// A Channel has many Message.
+type Channel struct {
+	ID          []byte `json:"id"` // Matches pkeyName
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
@@ -0,0 +1,505 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Package dm contains a [dm.EventModel] that stores its data in any
+// [model.Backend]. It has the same logic as the IndexedDB event model so that
+// the logic can be run and tested without a browser.
+package dm
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/client/v4/bindings"
+	"gitlab.com/elixxir/client/v4/cmix/rounds"
+	"gitlab.com/elixxir/client/v4/dm"
+	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
+	"gitlab.com/elixxir/crypto/message"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/model"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/netTime"
+)
+
+// EventUpdate is called on every change to the event model with the type of
+// the event and its JSON marshallable data (see [bindings.DmMessageReceived]).
+type EventUpdate func(eventType int64, jsonMarshallable any)
+
+// EventModel implements [dm.EventModel] on a [model.Backend].
+type EventModel struct {
+	backend       model.Backend
+	cipher        idbCrypto.Cipher
+	eventCallback EventUpdate
+}
+
+// NewEventModel returns an EventModel that stores its data in the backend,
+// which must have the object stores of Schema. If the cipher is not nil, the
+// text of each message is encrypted with it.
+func NewEventModel(backend model.Backend, cipher idbCrypto.Cipher,
+	eventCallback EventUpdate) *EventModel {
+	return &EventModel{
+		backend:       backend,
+		cipher:        cipher,
+		eventCallback: eventCallback,
+	}
+}
+
+// Receive is called whenever a raw direct message is received. It may be
+// called multiple times on the same message; it is incumbent on the user of
+// the API to filter such calls by message ID.
+func (em *EventModel) Receive(messageID message.ID, nickname string,
+	text []byte, partnerKey, senderKey ed25519.PublicKey, dmToken uint32,
+	codeset uint8, timestamp time.Time, round rounds.Round, mType dm.MessageType,
+	status dm.Status) uint64 {
+	return em.receive("Receive", messageID, nil, nickname, string(text),
+		partnerKey, senderKey, dmToken, codeset, timestamp, round, mType,
+		status)
+}
+
+// ReceiveText is called whenever a direct message is received that is a text
+// type.
+func (em *EventModel) ReceiveText(messageID message.ID, nickname,
+	text string, partnerKey, senderKey ed25519.PublicKey, dmToken uint32,
+	codeset uint8, timestamp time.Time, round rounds.Round,
+	status dm.Status) uint64 {
+	return em.receive("ReceiveText", messageID, nil, nickname, text,
+		partnerKey, senderKey, dmToken, codeset, timestamp, round, dm.TextType,
+		status)
+}
+
+// ReceiveReply is called whenever a direct message is received that is a reply.
+func (em *EventModel) ReceiveReply(messageID, reactionTo message.ID, nickname,
+	text string, partnerKey, senderKey ed25519.PublicKey, dmToken uint32,
+	codeset uint8, timestamp time.Time, round rounds.Round,
+	status dm.Status) uint64 {
+	return em.receive("ReceiveReply", messageID, &reactionTo, nickname, text,
+		partnerKey, senderKey, dmToken, codeset, timestamp, round, dm.ReplyType,
+		status)
+}
+
+// ReceiveReaction is called whenever a reaction to a direct message is
+// received.
+func (em *EventModel) ReceiveReaction(messageID, reactionTo message.ID,
+	nickname, reaction string, partnerKey, senderKey ed25519.PublicKey,
+	dmToken uint32, codeset uint8, timestamp time.Time, round rounds.Round,
+	status dm.Status) uint64 {
+	return em.receive("ReceiveReaction", messageID, &reactionTo, nickname,
+		reaction, partnerKey, senderKey, dmToken, codeset, timestamp, round,
+		dm.ReactionType, status)
+}
+
+// receive calls receiveWrapper and logs any error. Returns the UUID of the
+// message or zero on error.
+func (em *EventModel) receive(method string, messageID message.ID,
+	parentID *message.ID, nickname, data string,
+	partnerKey, senderKey ed25519.PublicKey, partnerToken uint32, codeset uint8,
+	timestamp time.Time, round rounds.Round, mType dm.MessageType,
+	status dm.Status) uint64 {
+	jww.TRACE.Printf("[DM model] %s(%s)", method, messageID)
+
+	uuid, err := em.receiveWrapper(messageID, parentID, nickname, data,
+		partnerKey, senderKey, partnerToken, codeset, timestamp, round, mType,
+		status)
+	if err != nil {
+		jww.ERROR.Printf("%+v",
+			errors.WithMessagef(err, "[DM model] failed to %s", method))
+		return 0
+	}
+	return uuid
+}
+
+// UpdateSentStatus is called whenever the sent status of a message has
+// changed.
+func (em *EventModel) UpdateSentStatus(uuid uint64, messageID message.ID,
+	timestamp time.Time, round rounds.Round, status dm.Status) {
+	parentErr := errors.New("[DM model] failed to UpdateSentStatus")
+	jww.TRACE.Printf("[DM model] UpdateSentStatus(%d, %s, ...)",
+		uuid, messageID)
+
+	// Get and update the existing Message in one transaction so that no other
+	// change to the Message is lost in between
+	var newMessage *Message
+	err := em.backend.Transact([]string{messageStoreName},
+		func(tx model.Tx) error {
+			msgData, err := tx.Get(messageStoreName, uuid)
+			if err != nil {
+				return errors.Errorf("Unable to get message: %+v", err)
+			}
+			newMessage, err = unmarshalMessage(msgData)
+			if err != nil {
+				return err
+			}
+
+			newMessage.Status = uint8(status)
+			if !messageID.Equals(message.ID{}) {
+				newMessage.MessageID = messageID.Bytes()
+			}
+
+			if round.ID != 0 {
+				newMessage.Round = uint64(round.ID)
+			}
+
+			if !timestamp.Equal(time.Time{}) {
+				newMessage.Timestamp = timestamp
+			}
+
+			_, err = putMessage(tx, newMessage)
+			return err
+		})
+	if err != nil {
+		jww.ERROR.Printf("%+v", errors.Wrap(parentErr, err.Error()))
+		return
+	}
+
+	em.eventCallback(bindings.DmMessageReceived, bindings.DmMessageReceivedJSON{
+		UUID:               uuid,
+		PubKey:             newMessage.ConversationPubKey,
+		MessageUpdate:      true,
+		ConversationUpdate: false,
+	})
+}
+
+// receiveWrapper is a higher-level wrapper of upsertMessage. The Message is
+// stored in the same transaction as the Conversation, which is created if it
+// does not exist and updated if the partner changed their nickname or token.
+func (em *EventModel) receiveWrapper(messageID message.ID,
+	parentID *message.ID, nickname, data string,
+	partnerKey, senderKey ed25519.PublicKey, partnerToken uint32, codeset uint8,
+	timestamp time.Time, round rounds.Round, mType dm.MessageType,
+	status dm.Status) (uint64, error) {
+	var err error
+
+	// Handle encryption, if it is present
+	if em.cipher != nil {
+		data, err = em.cipher.Encrypt([]byte(data))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var parentIdBytes []byte
+	if parentID != nil {
+		parentIdBytes = parentID.Marshal()
+	}
+
+	msgToInsert := buildMessage(messageID.Bytes(), parentIdBytes, data,
+		partnerKey, senderKey, timestamp, round.ID, mType, codeset, status)
+
+	var uuid uint64
+	var conversationUpdated bool
+	err = em.backend.Transact([]string{conversationStoreName, messageStoreName},
+		func(tx model.Tx) error {
+			convoToUpdate, err := updatedConversation(
+				tx, nickname, partnerKey, senderKey, partnerToken, codeset)
+			if err != nil {
+				return err
+			}
+
+			// Keep track of whether a Conversation was altered
+			conversationUpdated = convoToUpdate != nil
+			if conversationUpdated {
+				if err = putConversation(tx, convoToUpdate); err != nil {
+					return err
+				}
+			}
+
+			uuid, err = putMessage(tx, msgToInsert)
+			if err != nil {
+				return errors.Errorf("Unable to put Message: %+v", err)
+			}
+			return nil
+		})
+	if err != nil {
+		return 0, err
+	}
+	jww.DEBUG.Printf("[DM model] Successfully stored message %d", uuid)
+
+	em.eventCallback(bindings.DmMessageReceived, bindings.DmMessageReceivedJSON{
+		UUID:               uuid,
+		PubKey:             partnerKey,
+		MessageUpdate:      false,
+		ConversationUpdate: conversationUpdated,
+	})
+	return uuid, nil
+}
+
+// updatedConversation returns the Conversation with the partner changed by a
+// message from the sender or nil if it is unchanged. A new Conversation is
+// returned if none exists. The nickname and token are only updated if the
+// message is from the partner.
+func updatedConversation(tx model.Tx, nickname string,
+	partnerKey, senderKey ed25519.PublicKey, partnerToken uint32,
+	codeset uint8) (*Conversation, error) {
+	result, err := getConversation(tx, partnerKey)
+	if err != nil {
+		if !errors.Is(err, model.ErrNotFound) {
+			return nil, err
+		}
+
+		// If there is no extant Conversation, create one.
+		jww.DEBUG.Printf("[DM model] Joining conversation with %s", nickname)
+		return &Conversation{
+			Pubkey:           partnerKey,
+			Nickname:         nickname,
+			Token:            partnerToken,
+			CodesetVersion:   codeset,
+			BlockedTimestamp: nil,
+		}, nil
+	}
+
+	newNickname, newToken, changed := PartnerUpdate(result.Pubkey, senderKey,
+		result.Nickname, result.Token, nickname, partnerToken)
+	if !changed {
+		return nil, nil
+	}
+	result.Nickname, result.Token = newNickname, newToken
+	return result, nil
+}
+
+// PartnerUpdate returns the nickname and DM token that the Conversation with
+// the partner has after a message from the sender with the nickname and token
+// is received, and whether either differs from the stored nickname and token.
+// Only messages from the partner change them.
+//
+// It is shared with the IndexedDB event model so that both update
+// conversations the same way.
+func PartnerUpdate(partnerKey, senderKey ed25519.PublicKey,
+	storedNickname string, storedToken uint32, nickname string,
+	partnerToken uint32) (string, uint32, bool) {
+	if !bytes.Equal(partnerKey, senderKey) {
+		return storedNickname, storedToken, false
+	}
+
+	changed := false
+	if storedNickname != nickname {
+		jww.DEBUG.Printf("[DM] Updating from nickname %s to %s",
+			storedNickname, nickname)
+		changed = true
+	}
+
+	// Fix conversation if dmToken is altered
+	if storedToken != partnerToken {
+		jww.WARN.Printf("[DM] Updating from dmToken %d to %d",
+			storedToken, partnerToken)
+		changed = true
+	}
+	return nickname, partnerToken, changed
+}
+
+// buildMessage is a private helper that converts typical [dm.EventModel]
+// inputs into a basic Message structure for insertion into storage.
+//
+// NOTE: ID is not set inside this function because we want to use the
+// autoincrement key by default. If you are trying to overwrite an existing
+// message, then you need to set it manually yourself.
+func buildMessage(messageID, parentID []byte, text string, partnerKey []byte,
+	senderKey ed25519.PublicKey, timestamp time.Time, round id.Round,
+	mType dm.MessageType, codeset uint8, status dm.Status) *Message {
+	return &Message{
+		MessageID:          messageID,
+		ConversationPubKey: partnerKey[:],
+		ParentMessageID:    parentID,
+		Timestamp:          timestamp,
+		SenderPubKey:       senderKey[:],
+		Status:             uint8(status),
+		CodesetVersion:     codeset,
+		Text:               text,
+		Type:               uint16(mType),
+		Round:              uint64(round),
+	}
+}
+
+// putMessage stores the Message in the transaction. Returns the UUID of the
+// Message.
+func putMessage(tx model.Tx, msg *Message) (uint64, error) {
+	msgData, err := json.Marshal(msg)
+	if err != nil {
+		return 0, errors.Errorf("Unable to marshal Message: %+v", err)
+	}
+	key, err := tx.Put(messageStoreName, msgData)
+	if err != nil {
+		return 0, err
+	}
+	return model.UintKey(key)
+}
+
+// putConversation stores the Conversation in the transaction.
+func putConversation(tx model.Tx, convo *Conversation) error {
+	convoData, err := json.Marshal(convo)
+	if err != nil {
+		return errors.Errorf("Unable to marshal Conversation: %+v", err)
+	}
+	if _, err = tx.Put(conversationStoreName, convoData); err != nil {
+		return errors.Errorf("Unable to put Conversation: %+v", err)
+	}
+	return nil
+}
+
+// BlockSender silences messages sent by the indicated sender
+// public key.
+func (em *EventModel) BlockSender(senderPubKey ed25519.PublicKey) {
+	parentErr := "failed to BlockSender"
+	err := em.setBlocked(senderPubKey, true)
+	if err != nil {
+		jww.ERROR.Printf("%+v", errors.WithMessage(err, parentErr))
+	}
+}
+
+// UnblockSender allows messages sent by the indicated sender
+// public key.
+func (em *EventModel) UnblockSender(senderPubKey ed25519.PublicKey) {
+	parentErr := "failed to UnblockSender"
+	err := em.setBlocked(senderPubKey, false)
+	if err != nil {
+		jww.ERROR.Printf("%+v", errors.WithMessage(err, parentErr))
+	}
+}
+
+// setBlocked is a helper for blocking/unblocking a given Conversation.
+func (em *EventModel) setBlocked(
+	senderPubKey ed25519.PublicKey, isBlocked bool) error {
+	// Get current Conversation and set blocked accordingly in one transaction
+	// so that no other change to the Conversation is lost in between
+	return em.backend.Transact([]string{conversationStoreName},
+		func(tx model.Tx) error {
+			resultConvo, err := getConversation(tx, senderPubKey)
+			if err != nil {
+				return err
+			}
+
+			resultConvo.BlockedTimestamp = nil
+			if isBlocked {
+				blockUser := netTime.Now()
+				resultConvo.BlockedTimestamp = &blockUser
+			}
+			return putConversation(tx, resultConvo)
+		})
+}
+
+// DeleteMessage deletes the message with the given message ID belonging to
+// the sender. If the message exists and belongs to the sender, then it is
+// deleted and DeleteMessage returns true. If it does not exist, it returns
+// false.
+func (em *EventModel) DeleteMessage(
+	messageID message.ID, senderPubKey ed25519.PublicKey) bool {
+	parentErr := "failed to DeleteMessage"
+
+	err := em.backend.Transact([]string{messageStoreName},
+		func(tx model.Tx) error {
+			msgData, err := tx.GetIndex(messageStoreName,
+				messageStoreMessageIndex, messageID.Marshal())
+			if err != nil {
+				return err
+			}
+			msg, err := unmarshalMessage(msgData)
+			if err != nil {
+				return err
+			}
+
+			// Ensure the public keys match
+			if !bytes.Equal(msg.SenderPubKey, senderPubKey) {
+				return errors.New("Public keys do not match")
+			}
+			return tx.Delete(messageStoreName, msg.ID)
+		})
+	if err != nil {
+		jww.ERROR.Printf("%s: %+v", parentErr, err)
+		return false
+	}
+
+	em.eventCallback(bindings.DmMessageDeleted, bindings.DmMessageDeletedJSON{
+		MessageID: messageID,
+	})
+	return true
+}
+
+// GetConversation returns the conversation held by the model (receiver).
+func (em *EventModel) GetConversation(
+	senderPubKey ed25519.PublicKey) *dm.ModelConversation {
+	parentErr := "failed to GetConversation"
+
+	var resultConvo *Conversation
+	err := em.backend.Transact([]string{conversationStoreName},
+		func(tx model.Tx) error {
+			var err error
+			resultConvo, err = getConversation(tx, senderPubKey)
+			return err
+		})
+	if err != nil {
+		jww.ERROR.Printf("%+v", errors.WithMessage(err, parentErr))
+		return nil
+	}
+	return toModelConversation(resultConvo)
+}
+
+// getConversation returns the Conversation with the public key in the
+// transaction.
+func getConversation(
+	tx model.Tx, senderPubKey ed25519.PublicKey) (*Conversation, error) {
+	convoData, err := tx.Get(conversationStoreName, []byte(senderPubKey))
+	if err != nil {
+		return nil, err
+	}
+
+	resultConvo := &Conversation{}
+	if err = json.Unmarshal(convoData, resultConvo); err != nil {
+		return nil, errors.Errorf("Unable to unmarshal Conversation: %+v", err)
+	}
+	return resultConvo, nil
+}
+
+// GetConversations returns any conversations held by the model (receiver).
+func (em *EventModel) GetConversations() []dm.ModelConversation {
+	parentErr := "failed to GetConversations"
+
+	var results [][]byte
+	err := em.backend.Transact([]string{conversationStoreName},
+		func(tx model.Tx) error {
+			var err error
+			results, err = tx.GetAll(conversationStoreName)
+			return err
+		})
+	if err != nil {
+		jww.ERROR.Printf("%+v", errors.WithMessage(err, parentErr))
+		return nil
+	}
+
+	conversations := make([]dm.ModelConversation, len(results))
+	for i := range results {
+		resultConvo := &Conversation{}
+		if err = json.Unmarshal(results[i], resultConvo); err != nil {
+			jww.ERROR.Printf("%+v", errors.WithMessage(err, parentErr))
+			return nil
+		}
+		conversations[i] = *toModelConversation(resultConvo)
+	}
+	return conversations
+}
+
+// toModelConversation converts the Conversation into a [dm.ModelConversation].
+func toModelConversation(convo *Conversation) *dm.ModelConversation {
+	return &dm.ModelConversation{
+		Pubkey:           convo.Pubkey,
+		Nickname:         convo.Nickname,
+		Token:            convo.Token,
+		CodesetVersion:   convo.CodesetVersion,
+		BlockedTimestamp: convo.BlockedTimestamp,
+	}
+}
+
+// unmarshalMessage returns the Message in the JSON.
+func unmarshalMessage(msgData []byte) (*Message, error) {
+	resultMsg := &Message{}
+	if err := json.Unmarshal(msgData, resultMsg); err != nil {
+		return nil, errors.Errorf("Unable to unmarshal Message: %+v", err)
+	}
+	return resultMsg, nil
+}
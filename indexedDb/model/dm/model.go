@@ -0,0 +1,84 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package dm
+
+import (
+	"time"
+
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/model"
+)
+
+const (
+	// Text representation of primary key value (keyPath).
+	msgPkeyName   = "id"
+	convoPkeyName = "pub_key"
+
+	// Text representation of the names of the various object stores.
+	messageStoreName      = "messages"
+	conversationStoreName = "conversations"
+
+	// Message index names.
+	messageStoreMessageIndex      = "message_id_index"
+	messageStoreConversationIndex = "conversation_pub_key_index"
+	messageStoreSenderIndex       = "sender_pub_key_index"
+
+	// Message keyPath names (must match json struct tags).
+	messageStoreMessage      = "message_id"
+	messageStoreConversation = "conversation_pub_key"
+	messageStoreSender       = "sender_pub_key"
+)
+
+// Schema is the [model.Schema] of the database of an EventModel. A
+// [model.Backend] passed to NewEventModel must have these object stores.
+var Schema = model.Schema{Stores: []model.StoreSchema{
+	{
+		Name:          messageStoreName,
+		KeyPath:       msgPkeyName,
+		AutoIncrement: true,
+		Indexes: []model.IndexSchema{
+			{Name: messageStoreMessageIndex, KeyPath: messageStoreMessage,
+				Unique: true},
+			{Name: messageStoreConversationIndex,
+				KeyPath: messageStoreConversation},
+			{Name: messageStoreSenderIndex, KeyPath: messageStoreSender},
+		},
+	},
+	{
+		Name:    conversationStoreName,
+		KeyPath: convoPkeyName,
+	},
+}}
+
+// Message defines the stored representation of a single Message.
+//
+// A Message belongs to one Conversation.
+// A Message may belong to one Message (Parent).
+type Message struct {
+	ID                 uint64    `json:"id,omitempty"`         // Matches msgPkeyName
+	MessageID          []byte    `json:"message_id"`           // Index
+	ConversationPubKey []byte    `json:"conversation_pub_key"` // Index
+	ParentMessageID    []byte    `json:"parent_message_id"`
+	Timestamp          time.Time `json:"timestamp"`
+	SenderPubKey       []byte    `json:"sender_pub_key"` // Index
+	CodesetVersion     uint8     `json:"codeset_version"`
+	Status             uint8     `json:"status"`
+	Text               string    `json:"text"`
+	Type               uint16    `json:"type"`
+	Round              uint64    `json:"round"`
+}
+
+// Conversation defines the stored representation of a single
+// message exchange between two recipients.
+// A Conversation has many Message.
+type Conversation struct {
+	Pubkey           []byte     `json:"pub_key"` // Matches convoPkeyName
+	Nickname         string     `json:"nickname"`
+	Token            uint32     `json:"token"`
+	CodesetVersion   uint8      `json:"codeset_version"`
+	BlockedTimestamp *time.Time `json:"blocked_timestamp"`
+}
@@ -0,0 +1,407 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// MemoryBackend is a Backend that keeps every value in memory. Transactions
+// are run one at a time. It is intended for tests and for running the event
+// models outside the browser.
+type MemoryBackend struct {
+	schemas map[string]StoreSchema
+	stores  map[string]*memoryStore
+	mux     sync.Mutex
+}
+
+// memoryStore is an object store of a MemoryBackend.
+type memoryStore struct {
+	// values are the JSON values keyed on the canonical JSON of their primary
+	// key.
+	values map[string][]byte
+
+	// lastKey is the last key generated for an AutoIncrement store.
+	lastKey uint64
+}
+
+// memoryUndo restores a value of a memoryStore when a transaction fails.
+type memoryUndo struct {
+	storeName string
+	key       string
+	value     []byte // nil if there was no value
+	lastKey   uint64
+}
+
+// NewMemoryBackend returns an empty MemoryBackend with the object stores in
+// the Schema.
+func NewMemoryBackend(schema Schema) *MemoryBackend {
+	mb := &MemoryBackend{
+		schemas: make(map[string]StoreSchema, len(schema.Stores)),
+		stores:  make(map[string]*memoryStore, len(schema.Stores)),
+	}
+	for _, store := range schema.Stores {
+		mb.schemas[store.Name] = store
+		mb.stores[store.Name] = &memoryStore{values: make(map[string][]byte)}
+	}
+	return mb
+}
+
+// Transact runs fn inside a single transaction spanning the given object
+// stores. If fn returns an error, every write it made is undone.
+func (mb *MemoryBackend) Transact(
+	storeNames []string, fn func(tx Tx) error) error {
+	if len(storeNames) == 0 {
+		return errors.New("failed to Transact: no object stores")
+	}
+
+	mb.mux.Lock()
+	defer mb.mux.Unlock()
+
+	tx := &memoryTx{mb: mb, scope: make(map[string]bool, len(storeNames))}
+	for _, storeName := range storeNames {
+		if _, exists := mb.stores[storeName]; !exists {
+			return errors.Errorf(
+				"failed to Transact: no object store %q", storeName)
+		}
+		tx.scope[storeName] = true
+	}
+
+	err := fn(tx)
+	tx.done = true
+	if err != nil {
+		tx.rollback()
+		return err
+	}
+	return nil
+}
+
+// memoryTx is a Tx of a MemoryBackend.
+type memoryTx struct {
+	mb    *MemoryBackend
+	scope map[string]bool
+	undo  []memoryUndo
+	done  bool
+}
+
+// store returns the schema and values of the object store if it is in the
+// scope of the transaction.
+func (tx *memoryTx) store(storeName string) (StoreSchema, *memoryStore, error) {
+	if tx.done {
+		return StoreSchema{}, nil, errors.New("transaction is finished")
+	} else if !tx.scope[storeName] {
+		return StoreSchema{}, nil, errors.Errorf(
+			"object store %q is not in the transaction", storeName)
+	}
+	return tx.mb.schemas[storeName], tx.mb.stores[storeName], nil
+}
+
+// index returns the schema of the index of the object store.
+func (tx *memoryTx) index(storeName, indexName string) (
+	IndexSchema, *memoryStore, error) {
+	schema, store, err := tx.store(storeName)
+	if err != nil {
+		return IndexSchema{}, nil, err
+	}
+	for _, index := range schema.Indexes {
+		if index.Name == indexName {
+			return index, store, nil
+		}
+	}
+	return IndexSchema{}, nil, errors.Errorf(
+		"object store %q has no index %q", storeName, indexName)
+}
+
+// Get returns the JSON of the value with the primary key.
+func (tx *memoryTx) Get(storeName string, key any) ([]byte, error) {
+	_, store, err := tx.store(storeName)
+	if err != nil {
+		return nil, err
+	}
+	k, err := marshalKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	value, exists := store.values[k]
+	if !exists {
+		return nil, errors.Wrapf(ErrNotFound, "no %s with key %s", storeName, k)
+	}
+	return copyBytes(value), nil
+}
+
+// GetIndex returns the JSON of the first value with the key in the index.
+func (tx *memoryTx) GetIndex(
+	storeName, indexName string, key any) ([]byte, error) {
+	values, err := tx.GetAllIndex(storeName, indexName, key)
+	if err != nil {
+		return nil, err
+	} else if len(values) == 0 {
+		return nil, errors.Wrapf(ErrNotFound, "no %s in %s with key %v",
+			storeName, indexName, key)
+	}
+	return values[0], nil
+}
+
+// GetAll returns the JSON of every value in ascending order of primary key.
+func (tx *memoryTx) GetAll(storeName string) ([][]byte, error) {
+	_, store, err := tx.store(storeName)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]byte, 0, len(store.values))
+	for _, k := range store.sortedKeys() {
+		values = append(values, copyBytes(store.values[k]))
+	}
+	return values, nil
+}
+
+// GetAllIndex returns the JSON of every value with the key in the index in
+// ascending order of primary key.
+func (tx *memoryTx) GetAllIndex(
+	storeName, indexName string, key any) ([][]byte, error) {
+	keys, store, err := tx.indexKeys(storeName, indexName, key)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]byte, len(keys))
+	for i, k := range keys {
+		values[i] = copyBytes(store.values[k])
+	}
+	return values, nil
+}
+
+// indexKeys returns the primary keys of the values with the key in the index
+// in ascending order.
+func (tx *memoryTx) indexKeys(storeName, indexName string, key any) (
+	[]string, *memoryStore, error) {
+	index, store, err := tx.index(storeName, indexName)
+	if err != nil {
+		return nil, nil, err
+	}
+	k, err := marshalKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var keys []string
+	for _, primaryKey := range store.sortedKeys() {
+		indexKey, exists, err := fieldKey(store.values[primaryKey], index.KeyPath)
+		if err != nil {
+			return nil, nil, err
+		} else if exists && indexKey == k {
+			keys = append(keys, primaryKey)
+		}
+	}
+	return keys, store, nil
+}
+
+// Put inserts or replaces the JSON value. Returns the JSON of its primary key.
+func (tx *memoryTx) Put(storeName string, value []byte) (json.RawMessage, error) {
+	schema, store, err := tx.store(storeName)
+	if err != nil {
+		return nil, err
+	}
+
+	k, exists, err := fieldKey(value, schema.KeyPath)
+	if err != nil {
+		return nil, err
+	}
+	lastKey := store.lastKey
+	if !exists {
+		if !schema.AutoIncrement {
+			return nil, errors.Errorf(
+				"value in %s has no key %q", storeName, schema.KeyPath)
+		}
+		k = strconv.FormatUint(lastKey+1, 10)
+		if value, err = setField(value, schema.KeyPath, k); err != nil {
+			return nil, err
+		}
+		store.lastKey++
+	} else if n, err := strconv.ParseUint(k, 10, 64); err == nil &&
+		schema.AutoIncrement && n > store.lastKey {
+		store.lastKey = n
+	}
+
+	// Check the unique indexes
+	for _, index := range schema.Indexes {
+		if !index.Unique {
+			continue
+		}
+		indexKey, exists, err := fieldKey(value, index.KeyPath)
+		if err != nil {
+			store.lastKey = lastKey
+			return nil, err
+		} else if !exists {
+			continue
+		}
+		for otherKey, other := range store.values {
+			otherIndexKey, otherExists, _ := fieldKey(other, index.KeyPath)
+			if otherKey != k && otherExists && otherIndexKey == indexKey {
+				store.lastKey = lastKey
+				return nil, errors.Wrapf(ErrConstraint,
+					"%s already has %s %s", storeName, index.Name, indexKey)
+			}
+		}
+	}
+
+	tx.undo = append(tx.undo,
+		memoryUndo{storeName, k, store.values[k], lastKey})
+	store.values[k] = copyBytes(value)
+	return json.RawMessage(k), nil
+}
+
+// Delete removes the value with the primary key.
+func (tx *memoryTx) Delete(storeName string, key any) error {
+	_, store, err := tx.store(storeName)
+	if err != nil {
+		return err
+	}
+	k, err := marshalKey(key)
+	if err != nil {
+		return err
+	}
+
+	tx.delete(storeName, store, k)
+	return nil
+}
+
+// DeleteAllIndex removes every value with the key in the index.
+func (tx *memoryTx) DeleteAllIndex(
+	storeName, indexName string, key any) (int, error) {
+	keys, store, err := tx.indexKeys(storeName, indexName, key)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, k := range keys {
+		tx.delete(storeName, store, k)
+	}
+	return len(keys), nil
+}
+
+// delete removes the value with the canonical key from the store.
+func (tx *memoryTx) delete(storeName string, store *memoryStore, k string) {
+	if value, exists := store.values[k]; exists {
+		tx.undo = append(tx.undo,
+			memoryUndo{storeName, k, value, store.lastKey})
+		delete(store.values, k)
+	}
+}
+
+// rollback undoes every write of the transaction, newest first.
+func (tx *memoryTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		u := tx.undo[i]
+		store := tx.mb.stores[u.storeName]
+		if u.value == nil {
+			delete(store.values, u.key)
+		} else {
+			store.values[u.key] = u.value
+		}
+		store.lastKey = u.lastKey
+	}
+	tx.undo = nil
+}
+
+// sortedKeys returns the keys of the store in ascending order. Numbers sort
+// before strings, as in IndexedDB.
+func (ms *memoryStore) sortedKeys() []string {
+	keys := make([]string, 0, len(ms.values))
+	for k := range ms.values {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, aErr := strconv.ParseFloat(keys[i], 64)
+		b, bErr := strconv.ParseFloat(keys[j], 64)
+		switch {
+		case aErr == nil && bErr == nil && a != b:
+			return a < b
+		case aErr == nil && bErr != nil:
+			return true
+		case aErr != nil && bErr == nil:
+			return false
+		default:
+			return keys[i] < keys[j]
+		}
+	})
+	return keys
+}
+
+// marshalKey returns the canonical JSON of the key.
+func marshalKey(key any) (string, error) {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal key")
+	}
+	k, valid, err := canonicalKey(data)
+	if err != nil {
+		return "", err
+	} else if !valid {
+		return "", errors.Errorf("invalid key %s", data)
+	}
+	return k, nil
+}
+
+// fieldKey returns the canonical JSON of the field of the JSON object. Returns
+// false if the field is missing or is not a valid key, such as a boolean, in
+// which case the value is left out of indexes on the field as in IndexedDB.
+func fieldKey(value []byte, field string) (string, bool, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(value, &fields); err != nil {
+		return "", false, errors.Wrap(err, "value is not a JSON object")
+	}
+	raw, exists := fields[field]
+	if !exists {
+		return "", false, nil
+	}
+	return canonicalKey(raw)
+}
+
+// canonicalKey returns the canonical JSON of the key so that equal keys have
+// equal JSON. Returns false if the key is not a string or a number.
+func canonicalKey(raw json.RawMessage) (string, bool, error) {
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return "", false, errors.Wrap(err, "invalid key")
+	}
+
+	switch v.(type) {
+	case string, json.Number:
+		data, err := json.Marshal(v)
+		return string(data), err == nil, err
+	default:
+		return "", false, nil
+	}
+}
+
+// setField returns the JSON object with the field set to the raw JSON.
+func setField(value []byte, field, raw string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(value, &fields); err != nil {
+		return nil, errors.Wrap(err, "value is not a JSON object")
+	}
+	fields[field] = json.RawMessage(raw)
+	return json.Marshal(fields)
+}
+
+// copyBytes returns a copy of the byte slice so that stored values cannot be
+// changed by the caller.
+func copyBytes(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
@@ -0,0 +1,283 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Package modeltest contains the test suites that every [model.Backend] must
+// pass, both for the Backend itself and for the event models running on it.
+// Each implementation runs them from its own tests so that the in-memory and
+// IndexedDB backends are held to the same behaviour.
+package modeltest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/model"
+)
+
+// NewBackend returns a new empty [model.Backend] with the object stores of the
+// schema.
+type NewBackend func(t *testing.T, schema model.Schema) model.Backend
+
+const (
+	itemStoreName  = "items"
+	itemNameIndex  = "name_index"
+	itemGroupIndex = "group_index"
+	blobStoreName  = "blobs"
+)
+
+// testSchema has an AutoIncrement object store with a unique and a non-unique
+// index and an object store with keys set by the caller.
+var testSchema = model.Schema{Stores: []model.StoreSchema{
+	{
+		Name:          itemStoreName,
+		KeyPath:       "id",
+		AutoIncrement: true,
+		Indexes: []model.IndexSchema{
+			{Name: itemNameIndex, KeyPath: "name", Unique: true},
+			{Name: itemGroupIndex, KeyPath: "group"},
+		},
+	},
+	{
+		Name:    blobStoreName,
+		KeyPath: "id",
+	},
+}}
+
+// item is a value of the items object store.
+type item struct {
+	ID    uint64 `json:"id,omitempty"`
+	Name  string `json:"name"`
+	Group string `json:"group,omitempty"`
+}
+
+// blob is a value of the blobs object store.
+type blob struct {
+	ID   []byte `json:"id"`
+	Data []byte `json:"data"`
+}
+
+// RunBackendTests runs the tests of every [model.Backend] on the backends
+// returned by newBackend.
+func RunBackendTests(t *testing.T, newBackend NewBackend) {
+	t.Run("AutoIncrement", func(t *testing.T) {
+		testAutoIncrement(t, newBackend(t, testSchema))
+	})
+	t.Run("Replace", func(t *testing.T) {
+		testReplace(t, newBackend(t, testSchema))
+	})
+	t.Run("UniqueConstraint", func(t *testing.T) {
+		testUniqueConstraint(t, newBackend(t, testSchema))
+	})
+	t.Run("Index", func(t *testing.T) {
+		testIndex(t, newBackend(t, testSchema))
+	})
+	t.Run("Delete", func(t *testing.T) {
+		testDelete(t, newBackend(t, testSchema))
+	})
+	t.Run("BytesKey", func(t *testing.T) {
+		testBytesKey(t, newBackend(t, testSchema))
+	})
+}
+
+// Tests that values put without a key are given increasing integer keys
+// starting at 1 that are set in the stored value.
+func testAutoIncrement(t *testing.T, b model.Backend) {
+	keys := putItems(t, b, item{Name: "a"}, item{Name: "b"})
+	require.Equal(t, []uint64{1, 2}, keys)
+
+	got := getItem(t, b, 2)
+	require.Equal(t, item{ID: 2, Name: "b"}, got)
+}
+
+// Tests that putting a value with an existing key replaces the value.
+func testReplace(t *testing.T, b model.Backend) {
+	putItems(t, b, item{Name: "a"})
+	keys := putItems(t, b, item{ID: 1, Name: "b"})
+	require.Equal(t, []uint64{1}, keys)
+
+	require.Equal(t, item{ID: 1, Name: "b"}, getItem(t, b, 1))
+	requireNotFound(t, b, func(tx model.Tx) error {
+		_, err := tx.GetIndex(itemStoreName, itemNameIndex, "a")
+		return err
+	})
+}
+
+// Tests that a put that breaks a unique index returns model.ErrConstraint and
+// that no write of the failed transaction is committed.
+func testUniqueConstraint(t *testing.T, b model.Backend) {
+	putItems(t, b, item{Name: "a"})
+
+	err := b.Transact([]string{itemStoreName}, func(tx model.Tx) error {
+		if _, err := tx.Put(itemStoreName, marshal(t, item{Name: "b"})); err != nil {
+			return err
+		}
+		_, err := tx.Put(itemStoreName, marshal(t, item{Name: "a"}))
+		return err
+	})
+	require.True(t, errors.Is(err, model.ErrConstraint),
+		"Unexpected error: %+v", err)
+
+	requireNotFound(t, b, func(tx model.Tx) error {
+		_, err := tx.GetIndex(itemStoreName, itemNameIndex, "b")
+		return err
+	})
+
+	// Replacing a value with its own unique key is not a violation
+	keys := putItems(t, b, item{ID: 1, Name: "a", Group: "x"})
+	require.Equal(t, []uint64{1}, keys)
+}
+
+// Tests that GetIndex and GetAllIndex return the values with the key in
+// ascending order of primary key and that missing values are
+// model.ErrNotFound.
+func testIndex(t *testing.T, b model.Backend) {
+	putItems(t, b, item{Name: "a", Group: "x"}, item{Name: "b", Group: "y"},
+		item{Name: "c", Group: "x"}, item{Name: "d"})
+
+	var byName []byte
+	var byGroup, all [][]byte
+	err := b.Transact([]string{itemStoreName}, func(tx model.Tx) error {
+		var err error
+		if byName, err = tx.GetIndex(itemStoreName, itemNameIndex, "c"); err != nil {
+			return err
+		}
+		byGroup, err = tx.GetAllIndex(itemStoreName, itemGroupIndex, "x")
+		if err != nil {
+			return err
+		}
+		all, err = tx.GetAll(itemStoreName)
+		return err
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, item{ID: 3, Name: "c", Group: "x"}, unmarshalItem(t, byName))
+	require.Len(t, byGroup, 2)
+	require.Equal(t, uint64(1), unmarshalItem(t, byGroup[0]).ID)
+	require.Equal(t, uint64(3), unmarshalItem(t, byGroup[1]).ID)
+	require.Len(t, all, 4)
+	for i, value := range all {
+		require.Equal(t, uint64(i+1), unmarshalItem(t, value).ID)
+	}
+
+	requireNotFound(t, b, func(tx model.Tx) error {
+		_, err := tx.Get(itemStoreName, 5)
+		return err
+	})
+	requireNotFound(t, b, func(tx model.Tx) error {
+		_, err := tx.GetIndex(itemStoreName, itemGroupIndex, "z")
+		return err
+	})
+}
+
+// Tests that Delete and DeleteAllIndex remove the values with the key and
+// that DeleteAllIndex returns the number of values removed.
+func testDelete(t *testing.T, b model.Backend) {
+	putItems(t, b, item{Name: "a", Group: "x"}, item{Name: "b", Group: "y"},
+		item{Name: "c", Group: "x"})
+
+	var n int
+	err := b.Transact([]string{itemStoreName}, func(tx model.Tx) error {
+		if err := tx.Delete(itemStoreName, 2); err != nil {
+			return err
+		}
+
+		// Deleting a missing value is not an error
+		if err := tx.Delete(itemStoreName, 2); err != nil {
+			return err
+		}
+
+		var err error
+		n, err = tx.DeleteAllIndex(itemStoreName, itemGroupIndex, "x")
+		return err
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	var all [][]byte
+	err = b.Transact([]string{itemStoreName}, func(tx model.Tx) error {
+		var err error
+		all, err = tx.GetAll(itemStoreName)
+		return err
+	})
+	require.NoError(t, err)
+	require.Empty(t, all)
+}
+
+// Tests that a []byte field can be used as a key by passing the []byte.
+func testBytesKey(t *testing.T, b model.Backend) {
+	key := []byte{0, 1, 2, 255}
+	expected := blob{ID: key, Data: []byte("data")}
+
+	var data []byte
+	err := b.Transact([]string{blobStoreName}, func(tx model.Tx) error {
+		if _, err := tx.Put(blobStoreName, marshal(t, expected)); err != nil {
+			return err
+		}
+		var err error
+		data, err = tx.Get(blobStoreName, key)
+		return err
+	})
+	require.NoError(t, err)
+
+	var got blob
+	require.NoError(t, json.Unmarshal(data, &got))
+	require.Equal(t, expected, got)
+}
+
+// putItems puts each item in one transaction and returns their keys.
+func putItems(t *testing.T, b model.Backend, items ...item) []uint64 {
+	keys := make([]uint64, len(items))
+	err := b.Transact([]string{itemStoreName}, func(tx model.Tx) error {
+		for i := range items {
+			key, err := tx.Put(itemStoreName, marshal(t, items[i]))
+			if err != nil {
+				return err
+			}
+			if keys[i], err = model.UintKey(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return keys
+}
+
+// getItem returns the item with the key.
+func getItem(t *testing.T, b model.Backend, key uint64) item {
+	var data []byte
+	err := b.Transact([]string{itemStoreName}, func(tx model.Tx) error {
+		var err error
+		data, err = tx.Get(itemStoreName, key)
+		return err
+	})
+	require.NoError(t, err)
+	return unmarshalItem(t, data)
+}
+
+// requireNotFound fails the test if the read does not return
+// model.ErrNotFound.
+func requireNotFound(t *testing.T, b model.Backend, read func(tx model.Tx) error) {
+	err := b.Transact([]string{itemStoreName}, read)
+	require.True(t, errors.Is(err, model.ErrNotFound),
+		"Unexpected error: %+v", err)
+}
+
+func marshal(t *testing.T, v any) []byte {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return data
+}
+
+func unmarshalItem(t *testing.T, data []byte) item {
+	var i item
+	require.NoError(t, json.Unmarshal(data, &i))
+	return i
+}
@@ -0,0 +1,196 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package modeltest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/elixxir/client/v4/bindings"
+	"gitlab.com/elixxir/client/v4/channels"
+	"gitlab.com/elixxir/client/v4/cmix/rounds"
+	cryptoBroadcast "gitlab.com/elixxir/crypto/broadcast"
+	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
+	"gitlab.com/elixxir/crypto/message"
+	modelChannels "gitlab.com/elixxir/xxdk-wasm/indexedDb/model/channels"
+	"gitlab.com/xx_network/crypto/csprng"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/netTime"
+)
+
+// testCiphers returns the ciphers every event model test is run with: none
+// and a new cipher.
+func testCiphers(t *testing.T) []idbCrypto.Cipher {
+	cipher, err := idbCrypto.NewCipher(
+		[]byte("testPassword"), []byte("testSalt"), 32, csprng.NewSystemRNG())
+	require.NoError(t, err)
+	return []idbCrypto.Cipher{nil, cipher}
+}
+
+// eventRecorder records the events of an event model.
+type eventRecorder struct {
+	types []int64
+	data  []any
+}
+
+func (er *eventRecorder) eventUpdate(eventType int64, jsonMarshallable any) {
+	er.types = append(er.types, eventType)
+	er.data = append(er.data, jsonMarshallable)
+}
+
+// RunChannelsTests runs the tests of the channels event model on the backends
+// returned by newBackend.
+func RunChannelsTests(t *testing.T, newBackend NewBackend) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, em *modelChannels.EventModel, er *eventRecorder)
+	}{
+		{"ReceiveMessage", testChannelsReceiveMessage},
+		{"DuplicateMessage", testChannelsDuplicateMessage},
+		{"UpdateFromUUID", testChannelsUpdateFromUUID},
+		{"DeleteMessage", testChannelsDeleteMessage},
+		{"LeaveChannel", testChannelsLeaveChannel},
+	}
+	for _, c := range testCiphers(t) {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s/cipher=%t", tt.name, c != nil),
+				func(t *testing.T) {
+					er := &eventRecorder{}
+					em := modelChannels.NewEventModel(
+						newBackend(t, modelChannels.Schema), c, er.eventUpdate)
+					tt.fn(t, em, er)
+				})
+		}
+	}
+}
+
+// receiveTestMessage receives a text message with the text in the channel and
+// returns its message ID and UUID.
+func receiveTestMessage(t *testing.T, em *modelChannels.EventModel,
+	channelID *id.ID, text string, status channels.SentStatus) (
+	message.ID, uint64) {
+	msgID := message.DeriveChannelMessageID(channelID, 0, []byte(text))
+	uuid := em.ReceiveMessage(channelID, msgID, "nickname", text,
+		[]byte("pubKey"), 5, 0, netTime.Now(), time.Hour,
+		rounds.Round{ID: 8}, channels.Text, status, false)
+	require.NotZero(t, uuid)
+	return msgID, uuid
+}
+
+// Tests that a received message can be retrieved with its decrypted content
+// and that the event callback is called.
+func testChannelsReceiveMessage(
+	t *testing.T, em *modelChannels.EventModel, er *eventRecorder) {
+	channelID := id.NewIdFromString("channel", id.User, t)
+	msgID, uuid := receiveTestMessage(t, em, channelID, "hello", channels.Sent)
+
+	msg, err := em.GetMessage(msgID)
+	require.NoError(t, err)
+	require.Equal(t, uuid, msg.UUID)
+	require.Equal(t, "hello", string(msg.Content))
+	require.Equal(t, "nickname", msg.Nickname)
+	require.Equal(t, channelID, msg.ChannelID)
+	require.Equal(t, time.Hour, msg.Lease)
+	require.Equal(t, uint32(5), msg.DmToken)
+
+	require.Equal(t, []int64{bindings.MessageReceived}, er.types)
+	require.Equal(t, bindings.MessageReceivedJSON{
+		UUID: int64(uuid), ChannelID: channelID, Update: false}, er.data[0])
+}
+
+// Tests that receiving a message with the ID of a stored message updates the
+// stored message instead of storing a second one.
+func testChannelsDuplicateMessage(
+	t *testing.T, em *modelChannels.EventModel, _ *eventRecorder) {
+	channelID := id.NewIdFromString("channel", id.User, t)
+	msgID, uuid := receiveTestMessage(t, em, channelID, "hello", channels.Sent)
+	dupMsgID, dupUUID :=
+		receiveTestMessage(t, em, channelID, "hello", channels.Delivered)
+	require.Equal(t, msgID, dupMsgID)
+	require.Equal(t, uuid, dupUUID)
+
+	msg, err := em.GetMessage(msgID)
+	require.NoError(t, err)
+	require.Equal(t, channels.Delivered, msg.Status)
+}
+
+// Tests that UpdateFromUUID only changes the given fields and returns
+// channels.NoMessageErr for a missing message.
+func testChannelsUpdateFromUUID(
+	t *testing.T, em *modelChannels.EventModel, er *eventRecorder) {
+	channelID := id.NewIdFromString("channel", id.User, t)
+	msgID, uuid := receiveTestMessage(t, em, channelID, "hello", channels.Sent)
+
+	pinned := true
+	require.NoError(t, em.UpdateFromUUID(uuid, nil, nil, nil, &pinned, nil, nil))
+
+	msg, err := em.GetMessage(msgID)
+	require.NoError(t, err)
+	require.True(t, msg.Pinned)
+	require.Equal(t, channels.Sent, msg.Status)
+	require.Equal(t, bindings.MessageReceivedJSON{
+		UUID: int64(uuid), ChannelID: channelID, Update: true},
+		er.data[len(er.data)-1])
+
+	err = em.UpdateFromUUID(uuid+1, nil, nil, nil, &pinned, nil, nil)
+	require.True(t, errors.Is(err, channels.NoMessageErr),
+		"Unexpected error: %+v", err)
+
+	missingID := message.DeriveChannelMessageID(channelID, 0, []byte("missing"))
+	_, err = em.UpdateFromMessageID(missingID, nil, nil, &pinned, nil, nil)
+	require.True(t, errors.Is(err, channels.NoMessageErr),
+		"Unexpected error: %+v", err)
+}
+
+// Tests that a deleted message can no longer be retrieved.
+func testChannelsDeleteMessage(
+	t *testing.T, em *modelChannels.EventModel, er *eventRecorder) {
+	channelID := id.NewIdFromString("channel", id.User, t)
+	msgID, _ := receiveTestMessage(t, em, channelID, "hello", channels.Sent)
+
+	require.NoError(t, em.DeleteMessage(msgID))
+	require.Equal(t, bindings.MessageDeletedJSON{MessageID: msgID},
+		er.data[len(er.data)-1])
+
+	_, err := em.GetMessage(msgID)
+	require.True(t, errors.Is(err, channels.NoMessageErr),
+		"Unexpected error: %+v", err)
+	require.Error(t, em.DeleteMessage(msgID))
+}
+
+// Tests that leaving a channel deletes its messages and no others.
+func testChannelsLeaveChannel(
+	t *testing.T, em *modelChannels.EventModel, _ *eventRecorder) {
+	leftChannel := &cryptoBroadcast.Channel{
+		ReceptionID: id.NewIdFromString("left", id.Generic, t),
+		Name:        "left",
+	}
+	keptChannel := &cryptoBroadcast.Channel{
+		ReceptionID: id.NewIdFromString("kept", id.Generic, t),
+		Name:        "kept",
+	}
+	em.JoinChannel(leftChannel)
+	em.JoinChannel(keptChannel)
+
+	leftMsgID, _ := receiveTestMessage(
+		t, em, leftChannel.ReceptionID, "left", channels.Sent)
+	keptMsgID, _ := receiveTestMessage(
+		t, em, keptChannel.ReceptionID, "kept", channels.Sent)
+
+	em.LeaveChannel(leftChannel.ReceptionID)
+
+	_, err := em.GetMessage(leftMsgID)
+	require.True(t, errors.Is(err, channels.NoMessageErr),
+		"Unexpected error: %+v", err)
+	_, err = em.GetMessage(keptMsgID)
+	require.NoError(t, err)
+}
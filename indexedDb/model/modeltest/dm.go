@@ -0,0 +1,163 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package modeltest
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/elixxir/client/v4/bindings"
+	"gitlab.com/elixxir/client/v4/cmix/rounds"
+	"gitlab.com/elixxir/client/v4/dm"
+	"gitlab.com/elixxir/crypto/message"
+	modelDm "gitlab.com/elixxir/xxdk-wasm/indexedDb/model/dm"
+	"gitlab.com/xx_network/crypto/csprng"
+	"gitlab.com/xx_network/primitives/netTime"
+)
+
+// RunDMTests runs the tests of the DM event model on the backends returned by
+// newBackend.
+func RunDMTests(t *testing.T, newBackend NewBackend) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, em *modelDm.EventModel, er *eventRecorder)
+	}{
+		{"NewConversation", testDMNewConversation},
+		{"ConversationUpdate", testDMConversationUpdate},
+		{"UpdateSentStatus", testDMUpdateSentStatus},
+		{"DeleteMessage", testDMDeleteMessage},
+		{"BlockSender", testDMBlockSender},
+	}
+	for _, c := range testCiphers(t) {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s/cipher=%t", tt.name, c != nil),
+				func(t *testing.T) {
+					er := &eventRecorder{}
+					em := modelDm.NewEventModel(
+						newBackend(t, modelDm.Schema), c, er.eventUpdate)
+					tt.fn(t, em, er)
+				})
+		}
+	}
+}
+
+// newTestKey returns a new random public key.
+func newTestKey(t *testing.T) ed25519.PublicKey {
+	pubKey, _, err := ed25519.GenerateKey(csprng.NewSystemRNG())
+	require.NoError(t, err)
+	return pubKey
+}
+
+// receiveTestDM receives a text message with the text in the conversation with
+// the partner and returns its message ID and UUID.
+func receiveTestDM(t *testing.T, em *modelDm.EventModel, nickname, text string,
+	partnerKey, senderKey ed25519.PublicKey, token uint32) (message.ID, uint64) {
+	var msgID message.ID
+	copy(msgID[:], text)
+	uuid := em.ReceiveText(msgID, nickname, text, partnerKey, senderKey, token,
+		0, netTime.Now(), rounds.Round{ID: 8}, dm.Received)
+	require.NotZero(t, uuid)
+	return msgID, uuid
+}
+
+// Tests that the first message with a partner creates the conversation.
+func testDMNewConversation(
+	t *testing.T, em *modelDm.EventModel, er *eventRecorder) {
+	partnerKey := newTestKey(t)
+	require.Nil(t, em.GetConversation(partnerKey))
+
+	_, uuid := receiveTestDM(
+		t, em, "partner", "hello", partnerKey, partnerKey, 5)
+
+	convo := em.GetConversation(partnerKey)
+	require.NotNil(t, convo)
+	require.Equal(t, "partner", convo.Nickname)
+	require.Equal(t, uint32(5), convo.Token)
+	require.Nil(t, convo.BlockedTimestamp)
+	require.Len(t, em.GetConversations(), 1)
+
+	require.Equal(t, []int64{bindings.DmMessageReceived}, er.types)
+	require.Equal(t, bindings.DmMessageReceivedJSON{
+		UUID:               uuid,
+		PubKey:             partnerKey,
+		MessageUpdate:      false,
+		ConversationUpdate: true,
+	}, er.data[0])
+}
+
+// Tests that the nickname and token of a conversation are only updated by
+// messages from the partner.
+func testDMConversationUpdate(
+	t *testing.T, em *modelDm.EventModel, er *eventRecorder) {
+	partnerKey, selfKey := newTestKey(t), newTestKey(t)
+	receiveTestDM(t, em, "partner", "hello", partnerKey, partnerKey, 5)
+
+	// A message sent by the user does not change the conversation
+	receiveTestDM(t, em, "self", "from self", partnerKey, selfKey, 6)
+	convo := em.GetConversation(partnerKey)
+	require.Equal(t, "partner", convo.Nickname)
+	require.Equal(t, uint32(5), convo.Token)
+	require.False(t, er.data[len(er.data)-1].(bindings.DmMessageReceivedJSON).
+		ConversationUpdate)
+
+	// A message from the partner with a new nickname and token does
+	receiveTestDM(t, em, "renamed", "from partner", partnerKey, partnerKey, 7)
+	convo = em.GetConversation(partnerKey)
+	require.Equal(t, "renamed", convo.Nickname)
+	require.Equal(t, uint32(7), convo.Token)
+	require.True(t, er.data[len(er.data)-1].(bindings.DmMessageReceivedJSON).
+		ConversationUpdate)
+	require.Len(t, em.GetConversations(), 1)
+}
+
+// Tests that UpdateSentStatus calls the event callback with a message update.
+func testDMUpdateSentStatus(
+	t *testing.T, em *modelDm.EventModel, er *eventRecorder) {
+	partnerKey := newTestKey(t)
+	msgID, uuid := receiveTestDM(
+		t, em, "partner", "hello", partnerKey, partnerKey, 5)
+
+	em.UpdateSentStatus(uuid, msgID, netTime.Now(), rounds.Round{ID: 9}, dm.Sent)
+	require.Equal(t, bindings.DmMessageReceivedJSON{
+		UUID:               uuid,
+		PubKey:             partnerKey,
+		MessageUpdate:      true,
+		ConversationUpdate: false,
+	}, er.data[len(er.data)-1])
+}
+
+// Tests that a message can only be deleted by its sender.
+func testDMDeleteMessage(
+	t *testing.T, em *modelDm.EventModel, er *eventRecorder) {
+	partnerKey := newTestKey(t)
+	msgID, _ := receiveTestDM(
+		t, em, "partner", "hello", partnerKey, partnerKey, 5)
+
+	require.False(t, em.DeleteMessage(msgID, newTestKey(t)))
+	require.True(t, em.DeleteMessage(msgID, partnerKey))
+	require.Equal(t, bindings.DmMessageDeletedJSON{MessageID: msgID},
+		er.data[len(er.data)-1])
+	require.False(t, em.DeleteMessage(msgID, partnerKey))
+}
+
+// Tests that BlockSender and UnblockSender set and clear the blocked
+// timestamp of the conversation.
+func testDMBlockSender(
+	t *testing.T, em *modelDm.EventModel, _ *eventRecorder) {
+	partnerKey := newTestKey(t)
+	receiveTestDM(t, em, "partner", "hello", partnerKey, partnerKey, 5)
+
+	em.BlockSender(partnerKey)
+	require.NotNil(t, em.GetConversation(partnerKey).BlockedTimestamp)
+
+	em.UnblockSender(partnerKey)
+	require.Nil(t, em.GetConversation(partnerKey).BlockedTimestamp)
+}
@@ -0,0 +1,35 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package modeltest
+
+import (
+	"testing"
+
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/model"
+)
+
+// newMemoryBackend returns a new model.MemoryBackend.
+func newMemoryBackend(_ *testing.T, schema model.Schema) model.Backend {
+	return model.NewMemoryBackend(schema)
+}
+
+// Tests that model.MemoryBackend passes the backend tests.
+func TestMemoryBackend(t *testing.T) {
+	RunBackendTests(t, newMemoryBackend)
+}
+
+// Tests that the channels event model passes its tests on a
+// model.MemoryBackend.
+func TestMemoryBackend_Channels(t *testing.T) {
+	RunChannelsTests(t, newMemoryBackend)
+}
+
+// Tests that the DM event model passes its tests on a model.MemoryBackend.
+func TestMemoryBackend_DM(t *testing.T) {
+	RunDMTests(t, newMemoryBackend)
+}
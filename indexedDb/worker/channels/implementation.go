@@ -20,6 +20,7 @@ import (
 	"gitlab.com/elixxir/client/v4/channels"
 	"gitlab.com/elixxir/client/v4/cmix/rounds"
 	cryptoBroadcast "gitlab.com/elixxir/crypto/broadcast"
+	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
 	"gitlab.com/elixxir/crypto/message"
 	"gitlab.com/elixxir/xxdk-wasm/worker"
 	"gitlab.com/xx_network/primitives/id"
@@ -432,3 +433,494 @@ func (w *wasmModel) MuteUser(
 		jww.FATAL.Panicf("[CH] Failed to send to %q: %+v", MuteUserTag, err)
 	}
 }
+
+// RotateKey sends the key ring to the worker, which replaces its cipher and
+// re-encrypts the database with the current key in the background. Progress is
+// reported on the EventUpdate callback with the event type
+// [impl.KeyRotationProgress].
+func (w *wasmModel) RotateKey(encryption idbCrypto.Cipher) error {
+	data, err := json.Marshal(encryption)
+	if err != nil {
+		return errors.Wrap(err, "[CH] Could not JSON marshal cipher")
+	}
+
+	response, err := w.wm.SendMessage(RotateKeyTag, data)
+	if err != nil {
+		return errors.Wrapf(err, "[CH] Failed to send to %q", RotateKeyTag)
+	} else if len(response) > 0 {
+		return errors.New(string(response))
+	}
+
+	return nil
+}
+
+// ExportHistoryMessage is JSON marshalled and sent to the worker for
+// [wasmModel.ExportHistory].
+type ExportHistoryMessage struct {
+	ChannelID *id.ID    `json:"channelID"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Format    string    `json:"format"`
+}
+
+// ExportHistoryReply is JSON marshalled and sent to the main thread in
+// response to [ExportHistoryMessage]. If an error occurs, then Error will be
+// set with the error message. Otherwise, Data will be set.
+type ExportHistoryReply struct {
+	Data  []byte `json:"data"`
+	Error string `json:"error"`
+}
+
+// ExportHistory renders the history of the channel in the given
+// format. Only messages with a timestamp between start (inclusive) and end
+// (exclusive) are included; a zero start or end leaves that side of the range
+// unbounded. The format is one of "json", "markdown", or "html".
+func (w *wasmModel) ExportHistory(channelID *id.ID, start,
+	end time.Time, format string) ([]byte, error) {
+	data, err := json.Marshal(ExportHistoryMessage{
+		ChannelID: channelID,
+		Start:     start,
+		End:       end,
+		Format:    format,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err,
+			"[CH] Could not JSON marshal payload for %q", ExportHistoryTag)
+	}
+
+	response, err := w.wm.SendMessage(ExportHistoryTag, data)
+	if err != nil {
+		return nil, errors.Wrapf(
+			err, "[CH] Failed to send to %q", ExportHistoryTag)
+	}
+
+	var reply ExportHistoryReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return nil, errors.Wrapf(err,
+			"[CH] Could not JSON unmarshal response to %q", ExportHistoryTag)
+	} else if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+
+	return reply.Data, nil
+}
+
+// CheckDatabaseMessage is JSON marshalled and sent to the worker for
+// [wasmModel.CheckDatabase].
+type CheckDatabaseMessage struct {
+	Repair bool `json:"repair"`
+}
+
+// CheckDatabaseReply is JSON marshalled and sent to the main thread in
+// response to [CheckDatabaseMessage]. If an error occurs, then Error will be
+// set with the error message. Otherwise, Report will be set.
+type CheckDatabaseReply struct {
+	Report []byte `json:"report"`
+	Error  string `json:"error"`
+}
+
+// CheckDatabase validates every value in the database and returns the JSON of
+// the report. If repair is true, bad values are moved to a quarantine store.
+func (w *wasmModel) CheckDatabase(repair bool) ([]byte, error) {
+	data, err := json.Marshal(CheckDatabaseMessage{Repair: repair})
+	if err != nil {
+		return nil, errors.Wrapf(err,
+			"[CH] Could not JSON marshal payload for %q", CheckDatabaseTag)
+	}
+
+	response, err := w.wm.SendMessage(CheckDatabaseTag, data)
+	if err != nil {
+		return nil, errors.Wrapf(
+			err, "[CH] Failed to send to %q", CheckDatabaseTag)
+	}
+
+	var reply CheckDatabaseReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return nil, errors.Wrapf(err,
+			"[CH] Could not JSON unmarshal response to %q", CheckDatabaseTag)
+	} else if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+
+	return reply.Report, nil
+}
+
+// GetStorageUsageReply is JSON marshalled and sent to the main thread in
+// response to [GetStorageUsageTag]. If an error occurs, then Error will be set
+// with the error message. Otherwise, Usage will be set.
+type GetStorageUsageReply struct {
+	Usage []byte `json:"usage"`
+	Error string `json:"error"`
+}
+
+// GetStorageUsage returns the JSON of the number of rows and approximate size
+// of each object store in the database and of the messages of each
+// channel.
+func (w *wasmModel) GetStorageUsage() ([]byte, error) {
+	response, err := w.wm.SendMessage(GetStorageUsageTag, nil)
+	if err != nil {
+		return nil, errors.Wrapf(
+			err, "[CH] Failed to send to %q", GetStorageUsageTag)
+	}
+
+	var reply GetStorageUsageReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return nil, errors.Wrapf(err,
+			"[CH] Could not JSON unmarshal response to %q", GetStorageUsageTag)
+	} else if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+
+	return reply.Usage, nil
+}
+
+// GetChannelsReply is JSON marshalled and sent to the main thread in response
+// to [GetChannelsTag]. If an error occurs, then Error will be set with the
+// error message. Otherwise, Channels will be set.
+type GetChannelsReply struct {
+	Channels []byte `json:"channels"`
+	Error    string `json:"error"`
+}
+
+// GetChannels returns the JSON of every joined channel with its metadata,
+// sorted by last activity, newest first.
+func (w *wasmModel) GetChannels() ([]byte, error) {
+	response, err := w.wm.SendMessage(GetChannelsTag, nil)
+	if err != nil {
+		return nil, errors.Wrapf(
+			err, "[CH] Failed to send to %q", GetChannelsTag)
+	}
+
+	var reply GetChannelsReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return nil, errors.Wrapf(err,
+			"[CH] Could not JSON unmarshal response to %q", GetChannelsTag)
+	} else if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+
+	return reply.Channels, nil
+}
+
+// UpdateChannelMetadataMessage is JSON marshalled and sent to the worker for
+// [wasmModel.UpdateChannelMetadata].
+type UpdateChannelMetadataMessage struct {
+	ChannelID         *id.ID                      `json:"channelID"`
+	IsAdmin           *bool                       `json:"isAdmin"`
+	NotificationLevel *channels.NotificationLevel `json:"notificationLevel"`
+}
+
+// UpdateChannelMetadata sets the admin status and notification level stored
+// for the channel. Nil values are left unchanged.
+func (w *wasmModel) UpdateChannelMetadata(channelID *id.ID, isAdmin *bool,
+	level *channels.NotificationLevel) error {
+	data, err := json.Marshal(UpdateChannelMetadataMessage{
+		ChannelID:         channelID,
+		IsAdmin:           isAdmin,
+		NotificationLevel: level,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "[CH] Could not JSON marshal payload for %q",
+			UpdateChannelMetadataTag)
+	}
+
+	response, err := w.wm.SendMessage(UpdateChannelMetadataTag, data)
+	if err != nil {
+		return errors.Wrapf(
+			err, "[CH] Failed to send to %q", UpdateChannelMetadataTag)
+	} else if len(response) > 0 {
+		return errors.New(string(response))
+	}
+
+	return nil
+}
+
+// GetMessagesBySenderMessage is JSON marshalled and sent to the worker for
+// [wasmModel.GetMessagesBySender].
+type GetMessagesBySenderMessage struct {
+	ChannelID *id.ID            `json:"channelID"`
+	PubKey    ed25519.PublicKey `json:"pubKey"`
+}
+
+// GetMessagesBySenderReply is JSON marshalled and sent to the main thread in
+// response to [GetMessagesBySenderMessage]. If an error occurs, then Error
+// will be set with the error message. Otherwise, Messages will be set.
+type GetMessagesBySenderReply struct {
+	Messages []channels.ModelMessage `json:"messages"`
+	Error    string                  `json:"error"`
+}
+
+// GetMessagesBySender returns every message sent by the public key in the
+// channel, oldest first.
+func (w *wasmModel) GetMessagesBySender(channelID *id.ID,
+	pubKey ed25519.PublicKey) ([]channels.ModelMessage, error) {
+	data, err := json.Marshal(GetMessagesBySenderMessage{
+		ChannelID: channelID,
+		PubKey:    pubKey,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "[CH] Could not JSON marshal payload "+
+			"for %q", GetMessagesBySenderTag)
+	}
+
+	response, err := w.wm.SendMessage(GetMessagesBySenderTag, data)
+	if err != nil {
+		return nil, errors.Wrapf(
+			err, "[CH] Failed to send to %q", GetMessagesBySenderTag)
+	}
+
+	var reply GetMessagesBySenderReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return nil, errors.Wrapf(err, "[CH] Could not JSON unmarshal "+
+			"response to %q", GetMessagesBySenderTag)
+	} else if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+
+	return reply.Messages, nil
+}
+
+// HideMessagesBySenderMessage is JSON marshalled and sent to the worker for
+// [wasmModel.HideMessagesBySender].
+type HideMessagesBySenderMessage struct {
+	ChannelID *id.ID            `json:"channelID"`
+	PubKey    ed25519.PublicKey `json:"pubKey"`
+	Hide      bool              `json:"hide"`
+}
+
+// HideMessagesBySenderReply is JSON marshalled and sent to the main thread in
+// response to [HideMessagesBySenderMessage]. If an error occurs, then Error
+// will be set with the error message. Otherwise, Count will be set.
+type HideMessagesBySenderReply struct {
+	Count int    `json:"count"`
+	Error string `json:"error"`
+}
+
+// HideMessagesBySender hides every message sent by the public key in the
+// channel or, if hide is false, shows the messages it previously hid. Returns
+// the number of messages changed.
+func (w *wasmModel) HideMessagesBySender(channelID *id.ID,
+	pubKey ed25519.PublicKey, hide bool) (int, error) {
+	data, err := json.Marshal(HideMessagesBySenderMessage{
+		ChannelID: channelID,
+		PubKey:    pubKey,
+		Hide:      hide,
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "[CH] Could not JSON marshal payload "+
+			"for %q", HideMessagesBySenderTag)
+	}
+
+	response, err := w.wm.SendMessage(HideMessagesBySenderTag, data)
+	if err != nil {
+		return 0, errors.Wrapf(
+			err, "[CH] Failed to send to %q", HideMessagesBySenderTag)
+	}
+
+	var reply HideMessagesBySenderReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return 0, errors.Wrapf(err, "[CH] Could not JSON unmarshal "+
+			"response to %q", HideMessagesBySenderTag)
+	} else if reply.Error != "" {
+		return 0, errors.New(reply.Error)
+	}
+
+	return reply.Count, nil
+}
+
+// ReceiveEditMessage is JSON marshalled and sent to the worker for
+// [wasmModel.ReceiveEdit].
+type ReceiveEditMessage struct {
+	ChannelID *id.ID            `json:"channelID"`
+	EditID    message.ID        `json:"editID"`
+	PubKey    ed25519.PublicKey `json:"pubKey"`
+	Content   []byte            `json:"content"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// ReceiveEditReply is JSON marshalled and sent to the main thread in response
+// to [ReceiveEditMessage]. If an error occurs, then Error will be set with the
+// error message. Otherwise, UUID will be set.
+type ReceiveEditReply struct {
+	UUID  uint64 `json:"uuid"`
+	Error string `json:"error"`
+}
+
+// ReceiveEdit applies the edit message to the message it references. Returns
+// the UUID of the edited message.
+func (w *wasmModel) ReceiveEdit(channelID *id.ID, editID message.ID,
+	pubKey ed25519.PublicKey, content []byte, timestamp time.Time) (
+	uint64, error) {
+	data, err := json.Marshal(ReceiveEditMessage{
+		ChannelID: channelID,
+		EditID:    editID,
+		PubKey:    pubKey,
+		Content:   content,
+		Timestamp: timestamp,
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "[CH] Could not JSON marshal payload "+
+			"for %q", ReceiveEditTag)
+	}
+
+	response, err := w.wm.SendMessage(ReceiveEditTag, data)
+	if err != nil {
+		return 0, errors.Wrapf(err, "[CH] Failed to send to %q", ReceiveEditTag)
+	}
+
+	var reply ReceiveEditReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return 0, errors.Wrapf(err, "[CH] Could not JSON unmarshal "+
+			"response to %q", ReceiveEditTag)
+	} else if reply.Error != "" {
+		return 0, errors.New(reply.Error)
+	}
+
+	return reply.UUID, nil
+}
+
+// SaveDraftMessage is JSON marshalled and sent to the worker for
+// [wasmModel.SaveDraft].
+type SaveDraftMessage struct {
+	ChannelID *id.ID      `json:"channelID"`
+	Text      string      `json:"text"`
+	ReplyTo   *message.ID `json:"replyTo"`
+}
+
+// SaveDraft stores the unsent text of the channel and the message it
+// replies to, if any.
+func (w *wasmModel) SaveDraft(channelID *id.ID, text string,
+	replyTo *message.ID) error {
+	data, err := json.Marshal(SaveDraftMessage{
+		ChannelID: channelID,
+		Text:      text,
+		ReplyTo:   replyTo,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "[CH] Could not JSON marshal payload for %q",
+			SaveDraftTag)
+	}
+
+	response, err := w.wm.SendMessage(SaveDraftTag, data)
+	if err != nil {
+		return errors.Wrapf(err, "[CH] Failed to send to %q", SaveDraftTag)
+	} else if len(response) > 0 {
+		return errors.New(string(response))
+	}
+
+	return nil
+}
+
+// GetDraftReply is JSON marshalled and sent to the main thread in response to
+// [GetDraftTag]. If an error occurs, then Error will be set with the error
+// message. Otherwise, Draft will be set to the JSON of the draft or be empty
+// if there is none.
+type GetDraftReply struct {
+	Draft []byte `json:"draft"`
+	Error string `json:"error"`
+}
+
+// GetDraft returns the JSON of the draft of the channel or nil if
+// there is none.
+func (w *wasmModel) GetDraft(channelID *id.ID) ([]byte, error) {
+	response, err := w.wm.SendMessage(GetDraftTag, channelID.Marshal())
+	if err != nil {
+		return nil, errors.Wrapf(err, "[CH] Failed to send to %q", GetDraftTag)
+	}
+
+	var reply GetDraftReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return nil, errors.Wrapf(err,
+			"[CH] Could not JSON unmarshal response to %q", GetDraftTag)
+	} else if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+
+	return reply.Draft, nil
+}
+
+// ClearDraftMessage is JSON marshalled and sent to the worker for
+// [wasmModel.ClearDraft].
+type ClearDraftMessage struct {
+	ChannelID   *id.ID    `json:"channelID"`
+	SavedBefore time.Time `json:"savedBefore"`
+}
+
+// ClearDraft deletes the draft of the channel if it was last saved
+// before savedBefore.
+func (w *wasmModel) ClearDraft(
+	channelID *id.ID, savedBefore time.Time) error {
+	data, err := json.Marshal(ClearDraftMessage{
+		ChannelID:   channelID,
+		SavedBefore: savedBefore,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "[CH] Could not JSON marshal payload for %q",
+			ClearDraftTag)
+	}
+
+	response, err := w.wm.SendMessage(ClearDraftTag, data)
+	if err != nil {
+		return errors.Wrapf(err, "[CH] Failed to send to %q", ClearDraftTag)
+	} else if len(response) > 0 {
+		return errors.New(string(response))
+	}
+
+	return nil
+}
+
+// SearchMessagesMessage is JSON marshalled and sent to the worker for
+// [wasmModel.SearchMessages].
+type SearchMessagesMessage struct {
+	Query     string `json:"query"`
+	ChannelID *id.ID `json:"channelID,omitempty"`
+	Limit     int    `json:"limit"`
+}
+
+// SearchMessagesReply is JSON marshalled and sent to the main thread in
+// response to [SearchMessagesMessage]. If an error occurs, then Error will be
+// set with the error message. Otherwise, Results will be set to the JSON of
+// the list of results.
+type SearchMessagesReply struct {
+	Results []byte `json:"results"`
+	Error   string `json:"error"`
+}
+
+// SearchMessages returns the JSON of the list of messages with text matching
+// every word of the query, newest first, and the ranges of the matched words
+// in their text. If channelID is not nil, only messages in that channel are
+// searched. A limit of zero means no limit.
+func (w *wasmModel) SearchMessages(
+	query string, channelID *id.ID, limit int) ([]byte, error) {
+	data, err := json.Marshal(SearchMessagesMessage{
+		Query:     query,
+		ChannelID: channelID,
+		Limit:     limit,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "[CH] Could not JSON marshal payload "+
+			"for %q", SearchMessagesTag)
+	}
+
+	response, err := w.wm.SendMessage(SearchMessagesTag, data)
+	if err != nil {
+		return nil, errors.Wrapf(
+			err, "[CH] Failed to send to %q", SearchMessagesTag)
+	}
+
+	var reply SearchMessagesReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return nil, errors.Wrapf(err, "[CH] Could not JSON unmarshal "+
+			"response to %q", SearchMessagesTag)
+	} else if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+
+	return reply.Results, nil
+}
+
+// Close stops the worker manager and terminates the worker. The wasmModel
+// cannot be used after it is closed.
+func (w *wasmModel) Close() error {
+	return w.wm.Stop()
+}
@@ -18,6 +18,7 @@ import (
 	"gitlab.com/elixxir/client/v4/bindings"
 	"gitlab.com/elixxir/client/v4/channels"
 	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
 	"gitlab.com/elixxir/xxdk-wasm/logging"
 	"gitlab.com/elixxir/xxdk-wasm/storage"
 	"gitlab.com/elixxir/xxdk-wasm/worker"
@@ -30,9 +31,10 @@ const databaseSuffix = "_speakeasy"
 // the channel manager to define the path but the callback is the same
 // across the board.
 func NewWASMEventModelBuilder(wasmJsPath string, encryption idbCrypto.Cipher,
+	params impl.StorageParams,
 	channelCbs bindings.ChannelUICallbacks) channels.EventModelBuilder {
 	fn := func(path string) (channels.EventModel, error) {
-		return NewWASMEventModel(path, wasmJsPath, encryption,
+		return NewWASMEventModel(path, wasmJsPath, encryption, params,
 			channelCbs)
 	}
 	return fn
@@ -41,14 +43,20 @@ func NewWASMEventModelBuilder(wasmJsPath string, encryption idbCrypto.Cipher,
 // NewWASMEventModelMessage is JSON marshalled and sent to the worker for
 // [NewWASMEventModel].
 type NewWASMEventModelMessage struct {
-	DatabaseName   string `json:"databaseName"`
-	EncryptionJSON string `json:"encryptionJSON"`
+	DatabaseName       string `json:"databaseName"`
+	EncryptionJSON     string `json:"encryptionJSON"`
+	ExtendedEncryption bool   `json:"extendedEncryption"`
+
+	// StorageParams overrides the timeout and retries of the worker.
+	StorageParams impl.StorageParams `json:"storageParams"`
 }
 
 // NewWASMEventModel returns a [channels.EventModel] backed by a wasmModel.
-// The name should be a base64 encoding of the users public key.
+// The name should be a base64 encoding of the users public key. The worker
+// applies the params to its storage operations.
 func NewWASMEventModel(path, wasmJsPath string, encryption idbCrypto.Cipher,
-	cbs bindings.ChannelUICallbacks) (channels.EventModel, error) {
+	params impl.StorageParams, cbs bindings.ChannelUICallbacks) (
+	channels.EventModel, error) {
 	databaseName := path + databaseSuffix
 
 	wm, err := worker.NewManager(wasmJsPath, "channelsIndexedDb", true)
@@ -57,7 +65,7 @@ func NewWASMEventModel(path, wasmJsPath string, encryption idbCrypto.Cipher,
 	}
 
 	// Register handler to manage messages for the EventUpdate
-	wm.RegisterCallback(EventUpdateCallbackTag, eventUpdateCallbackHandler(cbs))
+	wm.RegisterCallback(EventUpdateCallbackTag, eventUpdateCallbackHandler(databaseName, cbs))
 
 	// Create MessageChannel between worker and logger so that the worker logs
 	// are saved
@@ -86,9 +94,15 @@ func NewWASMEventModel(path, wasmJsPath string, encryption idbCrypto.Cipher,
 		return nil, err
 	}
 
+	// Extended encryption is only available for encrypted databases
+	extendedEncryption :=
+		encryptionStatus && storage.ExtendedDbEncryptionEnabled()
+
 	msg := NewWASMEventModelMessage{
-		DatabaseName:   databaseName,
-		EncryptionJSON: string(encryptionJSON),
+		DatabaseName:       databaseName,
+		EncryptionJSON:     string(encryptionJSON),
+		ExtendedEncryption: extendedEncryption,
+		StorageParams:      params,
 	}
 
 	payload, err := json.Marshal(msg)
@@ -104,7 +118,18 @@ func NewWASMEventModel(path, wasmJsPath string, encryption idbCrypto.Cipher,
 		return nil, errors.New(string(response))
 	}
 
-	return &wasmModel{wm}, nil
+	model := &wasmModel{wm}
+
+	// Resume re-encryption if the database has not yet been fully re-encrypted
+	// with the current key
+	if kr, ok := encryption.(*impl.KeyRing); ok &&
+		storage.LoadIndexedDbKeyVersion(databaseName) < kr.Current() {
+		if err = model.RotateKey(kr); err != nil {
+			return nil, errors.WithMessage(err, "failed to resume key rotation")
+		}
+	}
+
+	return model, nil
 }
 
 // EventUpdateCallbackMessage is JSON marshalled and received from the worker
@@ -117,7 +142,7 @@ type EventUpdateCallbackMessage struct {
 // eventUpdateCallbackHandler returns a handler to manage messages for the
 // [bindings.ChannelUICallbacks.EventUpdate] callback.
 func eventUpdateCallbackHandler(
-	cbs bindings.ChannelUICallbacks) worker.ReceiverCallback {
+	databaseName string, cbs bindings.ChannelUICallbacks) worker.ReceiverCallback {
 	return func(message []byte, _ func([]byte)) {
 		var msg EventUpdateCallbackMessage
 		if err := json.Unmarshal(message, &msg); err != nil {
@@ -126,6 +151,10 @@ func eventUpdateCallbackHandler(
 			return
 		}
 
+		if msg.EventType == impl.KeyRotationProgress {
+			storeKeyVersion(databaseName, msg.JsonData)
+		}
+
 		cbs.EventUpdate(msg.EventType, msg.JsonData)
 	}
 }
@@ -144,6 +173,26 @@ type EncryptionStatusReply struct {
 	Error            string `json:"error"`
 }
 
+// storeKeyVersion saves the key version of the database once the worker
+// reports that it has finished re-encrypting it so that the re-encryption is
+// not resumed the next time the database is opened.
+func storeKeyVersion(databaseName string, progressJSON []byte) {
+	var progress impl.KeyRotationProgressJSON
+	if err := json.Unmarshal(progressJSON, &progress); err != nil {
+		jww.ERROR.Printf("Failed to JSON unmarshal %T from worker: %+v",
+			progress, err)
+		return
+	} else if !progress.Complete || progress.Error != "" {
+		return
+	}
+
+	err := storage.StoreIndexedDbKeyVersion(databaseName, progress.KeyVersion)
+	if err != nil {
+		jww.ERROR.Printf("Failed to store key version %d of database %s: %+v",
+			progress.KeyVersion, databaseName, err)
+	}
+}
+
 // checkDbEncryptionStatus returns an error if the encryption status provided
 // does not match the stored status for this database name.
 func checkDbEncryptionStatus(databaseName string, encryptionStatus bool) error {
@@ -27,4 +27,23 @@ const (
 	GetMessageTag          worker.Tag = "GetMessage"
 	DeleteMessageTag       worker.Tag = "DeleteMessage"
 	MuteUserTag            worker.Tag = "MuteUser"
+
+	RotateKeyTag       worker.Tag = "RotateKey"
+	ExportHistoryTag   worker.Tag = "ExportHistory"
+	CheckDatabaseTag   worker.Tag = "CheckDatabase"
+	GetStorageUsageTag worker.Tag = "GetStorageUsage"
+
+	GetChannelsTag           worker.Tag = "GetChannels"
+	UpdateChannelMetadataTag worker.Tag = "UpdateChannelMetadata"
+
+	GetMessagesBySenderTag  worker.Tag = "GetMessagesBySender"
+	HideMessagesBySenderTag worker.Tag = "HideMessagesBySender"
+
+	ReceiveEditTag worker.Tag = "ReceiveEdit"
+
+	SaveDraftTag  worker.Tag = "SaveDraft"
+	GetDraftTag   worker.Tag = "GetDraft"
+	ClearDraftTag worker.Tag = "ClearDraft"
+
+	SearchMessagesTag worker.Tag = "SearchMessages"
 )
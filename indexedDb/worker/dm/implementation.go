@@ -14,9 +14,11 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/pkg/errors"
 	jww "github.com/spf13/jwalterweatherman"
 	"gitlab.com/elixxir/client/v4/cmix/rounds"
 	"gitlab.com/elixxir/client/v4/dm"
+	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
 	"gitlab.com/elixxir/crypto/message"
 	"gitlab.com/elixxir/xxdk-wasm/worker"
 )
@@ -275,3 +277,386 @@ func (w *wasmModel) GetConversations() []dm.ModelConversation {
 
 	return result
 }
+
+// RotateKey sends the key ring to the worker, which replaces its cipher and
+// re-encrypts the database with the current key in the background. Progress is
+// reported on the EventUpdate callback with the event type
+// [impl.KeyRotationProgress].
+func (w *wasmModel) RotateKey(encryption idbCrypto.Cipher) error {
+	data, err := json.Marshal(encryption)
+	if err != nil {
+		return errors.Wrap(err, "[DM] Could not JSON marshal cipher")
+	}
+
+	response, err := w.wh.SendMessage(RotateKeyTag, data)
+	if err != nil {
+		return errors.Wrapf(err, "[DM] Failed to send to %q", RotateKeyTag)
+	} else if len(response) > 0 {
+		return errors.New(string(response))
+	}
+
+	return nil
+}
+
+// ExportHistoryMessage is JSON marshalled and sent to the worker for
+// [wasmModel.ExportHistory].
+type ExportHistoryMessage struct {
+	PartnerPubKey ed25519.PublicKey `json:"partnerPubKey"`
+	Start         time.Time         `json:"start"`
+	End           time.Time         `json:"end"`
+	Format        string            `json:"format"`
+}
+
+// ExportHistoryReply is JSON marshalled and sent to the main thread in
+// response to [ExportHistoryMessage]. If an error occurs, then Error will be
+// set with the error message. Otherwise, Data will be set.
+type ExportHistoryReply struct {
+	Data  []byte `json:"data"`
+	Error string `json:"error"`
+}
+
+// ExportHistory renders the history of the conversation with the partner in
+// the given format. Only messages with a timestamp between start (inclusive)
+// and end (exclusive) are included; a zero start or end leaves that side of the
+// range unbounded. The format is one of "json", "markdown", or "html".
+func (w *wasmModel) ExportHistory(partnerPubKey ed25519.PublicKey, start,
+	end time.Time, format string) ([]byte, error) {
+	data, err := json.Marshal(ExportHistoryMessage{
+		PartnerPubKey: partnerPubKey,
+		Start:         start,
+		End:           end,
+		Format:        format,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err,
+			"[DM] Could not JSON marshal payload for %q", ExportHistoryTag)
+	}
+
+	response, err := w.wh.SendMessage(ExportHistoryTag, data)
+	if err != nil {
+		return nil, errors.Wrapf(
+			err, "[DM] Failed to send to %q", ExportHistoryTag)
+	}
+
+	var reply ExportHistoryReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return nil, errors.Wrapf(err,
+			"[DM] Could not JSON unmarshal response to %q", ExportHistoryTag)
+	} else if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+
+	return reply.Data, nil
+}
+
+// CheckDatabaseMessage is JSON marshalled and sent to the worker for
+// [wasmModel.CheckDatabase].
+type CheckDatabaseMessage struct {
+	Repair bool `json:"repair"`
+}
+
+// CheckDatabaseReply is JSON marshalled and sent to the main thread in
+// response to [CheckDatabaseMessage]. If an error occurs, then Error will be
+// set with the error message. Otherwise, Report will be set.
+type CheckDatabaseReply struct {
+	Report []byte `json:"report"`
+	Error  string `json:"error"`
+}
+
+// CheckDatabase validates every value in the database and returns the JSON of
+// the report. If repair is true, bad values are moved to a quarantine store.
+func (w *wasmModel) CheckDatabase(repair bool) ([]byte, error) {
+	data, err := json.Marshal(CheckDatabaseMessage{Repair: repair})
+	if err != nil {
+		return nil, errors.Wrapf(err,
+			"[DM] Could not JSON marshal payload for %q", CheckDatabaseTag)
+	}
+
+	response, err := w.wh.SendMessage(CheckDatabaseTag, data)
+	if err != nil {
+		return nil, errors.Wrapf(
+			err, "[DM] Failed to send to %q", CheckDatabaseTag)
+	}
+
+	var reply CheckDatabaseReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return nil, errors.Wrapf(err,
+			"[DM] Could not JSON unmarshal response to %q", CheckDatabaseTag)
+	} else if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+
+	return reply.Report, nil
+}
+
+// GetStorageUsageReply is JSON marshalled and sent to the main thread in
+// response to [GetStorageUsageTag]. If an error occurs, then Error will be set
+// with the error message. Otherwise, Usage will be set.
+type GetStorageUsageReply struct {
+	Usage []byte `json:"usage"`
+	Error string `json:"error"`
+}
+
+// GetStorageUsage returns the JSON of the number of rows and approximate size
+// of each object store in the database and of the messages of each
+// conversation.
+func (w *wasmModel) GetStorageUsage() ([]byte, error) {
+	response, err := w.wh.SendMessage(GetStorageUsageTag, nil)
+	if err != nil {
+		return nil, errors.Wrapf(
+			err, "[DM] Failed to send to %q", GetStorageUsageTag)
+	}
+
+	var reply GetStorageUsageReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return nil, errors.Wrapf(err,
+			"[DM] Could not JSON unmarshal response to %q", GetStorageUsageTag)
+	} else if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+
+	return reply.Usage, nil
+}
+
+// GetMessagesMessage is JSON marshalled and sent to the worker for
+// [wasmModel.GetMessages].
+type GetMessagesMessage struct {
+	PartnerKey ed25519.PublicKey `json:"partnerKey"`
+	Cursor     []byte            `json:"cursor"`
+	Limit      int               `json:"limit"`
+}
+
+// GetMessagesReply is JSON marshalled and sent to the main thread in response
+// to [GetMessagesMessage]. If an error occurs, then Error will be set with the
+// error message. Otherwise, Page will be set to the JSON of the page.
+type GetMessagesReply struct {
+	Page  []byte `json:"page"`
+	Error string `json:"error"`
+}
+
+// GetMessages returns the JSON of a page of at most limit messages of the
+// conversation with the partner, newest first, starting after the cursor of
+// the previous page. An empty cursor returns the first page.
+func (w *wasmModel) GetMessages(partnerKey ed25519.PublicKey, cursor []byte,
+	limit int) ([]byte, error) {
+	data, err := json.Marshal(GetMessagesMessage{
+		PartnerKey: partnerKey,
+		Cursor:     cursor,
+		Limit:      limit,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err,
+			"[DM] Could not JSON marshal payload for %q", GetMessagesTag)
+	}
+
+	response, err := w.wh.SendMessage(GetMessagesTag, data)
+	if err != nil {
+		return nil, errors.Wrapf(
+			err, "[DM] Failed to send to %q", GetMessagesTag)
+	}
+
+	var reply GetMessagesReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return nil, errors.Wrapf(err,
+			"[DM] Could not JSON unmarshal response to %q", GetMessagesTag)
+	} else if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+
+	return reply.Page, nil
+}
+
+// ListConversationsMessage is JSON marshalled and sent to the worker for
+// [wasmModel.ListConversations].
+type ListConversationsMessage struct {
+	SortBy   string `json:"sortBy"`
+	Blocked  *bool  `json:"blocked"`
+	Archived *bool  `json:"archived"`
+}
+
+// ListConversationsReply is JSON marshalled and sent to the main thread in
+// response to [ListConversationsMessage]. If an error occurs, then Error will
+// be set with the error message. Otherwise, Conversations will be set to the
+// JSON of the conversations.
+type ListConversationsReply struct {
+	Conversations []byte `json:"conversations"`
+	Error         string `json:"error"`
+}
+
+// ListConversations returns the JSON of every conversation with a summary of
+// its activity, sorted by sortBy ("lastMessage", "nickname", or "unread"). If
+// blocked or archived are not nil, only conversations in that state are
+// returned.
+func (w *wasmModel) ListConversations(
+	sortBy string, blocked, archived *bool) ([]byte, error) {
+	data, err := json.Marshal(ListConversationsMessage{
+		SortBy:   sortBy,
+		Blocked:  blocked,
+		Archived: archived,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err,
+			"[DM] Could not JSON marshal payload for %q", ListConversationsTag)
+	}
+
+	response, err := w.wh.SendMessage(ListConversationsTag, data)
+	if err != nil {
+		return nil, errors.Wrapf(
+			err, "[DM] Failed to send to %q", ListConversationsTag)
+	}
+
+	var reply ListConversationsReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return nil, errors.Wrapf(err, "[DM] Could not JSON unmarshal "+
+			"response to %q", ListConversationsTag)
+	} else if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+
+	return reply.Conversations, nil
+}
+
+// MarkConversationRead sets the number of unread messages in the conversation
+// with the partner to zero.
+func (w *wasmModel) MarkConversationRead(partnerKey ed25519.PublicKey) error {
+	response, err := w.wh.SendMessage(MarkConversationReadTag, partnerKey)
+	if err != nil {
+		return errors.Wrapf(
+			err, "[DM] Failed to send to %q", MarkConversationReadTag)
+	} else if len(response) > 0 {
+		return errors.New(string(response))
+	}
+
+	return nil
+}
+
+// SetConversationStateMessage is JSON marshalled and sent to the worker for
+// [wasmModel.SetConversationState].
+type SetConversationStateMessage struct {
+	PartnerKey ed25519.PublicKey `json:"partnerKey"`
+	Archived   *bool             `json:"archived"`
+	Pinned     *bool             `json:"pinned"`
+	Muted      *bool             `json:"muted"`
+}
+
+// SetConversationState archives, pins, or mutes the conversation with the
+// partner, or undoes it. Nil values are left unchanged.
+func (w *wasmModel) SetConversationState(
+	partnerKey ed25519.PublicKey, archived, pinned, muted *bool) error {
+	data, err := json.Marshal(SetConversationStateMessage{
+		PartnerKey: partnerKey,
+		Archived:   archived,
+		Pinned:     pinned,
+		Muted:      muted,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "[DM] Could not JSON marshal payload for %q",
+			SetConversationStateTag)
+	}
+
+	response, err := w.wh.SendMessage(SetConversationStateTag, data)
+	if err != nil {
+		return errors.Wrapf(
+			err, "[DM] Failed to send to %q", SetConversationStateTag)
+	} else if len(response) > 0 {
+		return errors.New(string(response))
+	}
+
+	return nil
+}
+
+// SaveDraftMessage is JSON marshalled and sent to the worker for
+// [wasmModel.SaveDraft].
+type SaveDraftMessage struct {
+	PartnerKey ed25519.PublicKey `json:"partnerKey"`
+	Text       string            `json:"text"`
+	ReplyTo    *message.ID       `json:"replyTo"`
+}
+
+// SaveDraft stores the unsent text of the conversation with the partner and
+// the message it replies to, if any.
+func (w *wasmModel) SaveDraft(partnerKey ed25519.PublicKey, text string,
+	replyTo *message.ID) error {
+	data, err := json.Marshal(SaveDraftMessage{
+		PartnerKey: partnerKey,
+		Text:       text,
+		ReplyTo:    replyTo,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "[DM] Could not JSON marshal payload for %q",
+			SaveDraftTag)
+	}
+
+	response, err := w.wh.SendMessage(SaveDraftTag, data)
+	if err != nil {
+		return errors.Wrapf(err, "[DM] Failed to send to %q", SaveDraftTag)
+	} else if len(response) > 0 {
+		return errors.New(string(response))
+	}
+
+	return nil
+}
+
+// GetDraftReply is JSON marshalled and sent to the main thread in response to
+// [GetDraftTag]. If an error occurs, then Error will be set with the error
+// message. Otherwise, Draft will be set to the JSON of the draft or be empty
+// if there is none.
+type GetDraftReply struct {
+	Draft []byte `json:"draft"`
+	Error string `json:"error"`
+}
+
+// GetDraft returns the JSON of the draft of the conversation with the partner
+// or nil if there is none.
+func (w *wasmModel) GetDraft(partnerKey ed25519.PublicKey) ([]byte, error) {
+	response, err := w.wh.SendMessage(GetDraftTag, partnerKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[DM] Failed to send to %q", GetDraftTag)
+	}
+
+	var reply GetDraftReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return nil, errors.Wrapf(err,
+			"[DM] Could not JSON unmarshal response to %q", GetDraftTag)
+	} else if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+
+	return reply.Draft, nil
+}
+
+// ClearDraftMessage is JSON marshalled and sent to the worker for
+// [wasmModel.ClearDraft].
+type ClearDraftMessage struct {
+	PartnerKey  ed25519.PublicKey `json:"partnerKey"`
+	SavedBefore time.Time         `json:"savedBefore"`
+}
+
+// ClearDraft deletes the draft of the conversation with the partner if it was
+// last saved before savedBefore.
+func (w *wasmModel) ClearDraft(
+	partnerKey ed25519.PublicKey, savedBefore time.Time) error {
+	data, err := json.Marshal(ClearDraftMessage{
+		PartnerKey:  partnerKey,
+		SavedBefore: savedBefore,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "[DM] Could not JSON marshal payload for %q",
+			ClearDraftTag)
+	}
+
+	response, err := w.wh.SendMessage(ClearDraftTag, data)
+	if err != nil {
+		return errors.Wrapf(err, "[DM] Failed to send to %q", ClearDraftTag)
+	} else if len(response) > 0 {
+		return errors.New(string(response))
+	}
+
+	return nil
+}
+
+// Close stops the worker manager and terminates the worker. The wasmModel
+// cannot be used after it is closed.
+func (w *wasmModel) Close() error {
+	return w.wh.Stop()
+}
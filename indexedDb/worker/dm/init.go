@@ -19,6 +19,7 @@ import (
 	"gitlab.com/elixxir/client/v4/bindings"
 	"gitlab.com/elixxir/client/v4/dm"
 	idbCrypto "gitlab.com/elixxir/crypto/indexedDb"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
 	"gitlab.com/elixxir/xxdk-wasm/logging"
 	"gitlab.com/elixxir/xxdk-wasm/storage"
 	"gitlab.com/elixxir/xxdk-wasm/worker"
@@ -37,14 +38,19 @@ type MessageReceivedCallback func(uuid uint64, pubKey ed25519.PublicKey,
 // NewWASMEventModelMessage is JSON marshalled and sent to the worker for
 // [NewWASMEventModel].
 type NewWASMEventModelMessage struct {
-	DatabaseName   string `json:"databaseName"`
-	EncryptionJSON string `json:"encryptionJSON"`
+	DatabaseName       string `json:"databaseName"`
+	EncryptionJSON     string `json:"encryptionJSON"`
+	ExtendedEncryption bool   `json:"extendedEncryption"`
+
+	// StorageParams overrides the timeout and retries of the worker.
+	StorageParams impl.StorageParams `json:"storageParams"`
 }
 
 // NewWASMEventModel returns a [channels.EventModel] backed by a wasmModel.
-// The name should be a base64 encoding of the users public key.
+// The name should be a base64 encoding of the users public key. The worker
+// applies the params to its storage operations.
 func NewWASMEventModel(path, wasmJsPath string, encryption idbCrypto.Cipher,
-	cbs bindings.DmCallbacks) (dm.EventModel, error) {
+	params impl.StorageParams, cbs bindings.DmCallbacks) (dm.EventModel, error) {
 	databaseName := path + databaseSuffix
 
 	wh, err := worker.NewManager(wasmJsPath, "dmIndexedDb", true)
@@ -53,7 +59,7 @@ func NewWASMEventModel(path, wasmJsPath string, encryption idbCrypto.Cipher,
 	}
 
 	// Register handler to manage messages for the MessageReceivedCallback
-	wh.RegisterCallback(EventUpdateCallbackTag, eventUpdateCallbackHandler(cbs))
+	wh.RegisterCallback(EventUpdateCallbackTag, eventUpdateCallbackHandler(databaseName, cbs))
 
 	// Create MessageChannel between worker and logger so that the worker logs
 	// are saved
@@ -82,9 +88,15 @@ func NewWASMEventModel(path, wasmJsPath string, encryption idbCrypto.Cipher,
 		return nil, err
 	}
 
+	// Extended encryption is only available for encrypted databases
+	extendedEncryption :=
+		encryptionStatus && storage.ExtendedDbEncryptionEnabled()
+
 	msg := NewWASMEventModelMessage{
-		DatabaseName:   databaseName,
-		EncryptionJSON: string(encryptionJSON),
+		DatabaseName:       databaseName,
+		EncryptionJSON:     string(encryptionJSON),
+		ExtendedEncryption: extendedEncryption,
+		StorageParams:      params,
 	}
 
 	payload, err := json.Marshal(msg)
@@ -100,7 +112,18 @@ func NewWASMEventModel(path, wasmJsPath string, encryption idbCrypto.Cipher,
 		return nil, errors.New(string(response))
 	}
 
-	return &wasmModel{wh}, nil
+	model := &wasmModel{wh}
+
+	// Resume re-encryption if the database has not yet been fully re-encrypted
+	// with the current key
+	if kr, ok := encryption.(*impl.KeyRing); ok &&
+		storage.LoadIndexedDbKeyVersion(databaseName) < kr.Current() {
+		if err = model.RotateKey(kr); err != nil {
+			return nil, errors.WithMessage(err, "failed to resume key rotation")
+		}
+	}
+
+	return model, nil
 }
 
 // EventUpdateCallbackMessage is JSON marshalled and received from the worker
@@ -113,7 +136,7 @@ type EventUpdateCallbackMessage struct {
 // eventUpdateCallbackHandler returns a handler to manage messages for the
 // [bindings.DmCallbacks.EventUpdate] callback.
 func eventUpdateCallbackHandler(
-	cbs bindings.DmCallbacks) worker.ReceiverCallback {
+	databaseName string, cbs bindings.DmCallbacks) worker.ReceiverCallback {
 	return func(message []byte, _ func([]byte)) {
 		var msg EventUpdateCallbackMessage
 		if err := json.Unmarshal(message, &msg); err != nil {
@@ -122,10 +145,34 @@ func eventUpdateCallbackHandler(
 			return
 		}
 
+		if msg.EventType == impl.KeyRotationProgress {
+			storeKeyVersion(databaseName, msg.JsonData)
+		}
+
 		cbs.EventUpdate(msg.EventType, msg.JsonData)
 	}
 }
 
+// storeKeyVersion saves the key version of the database once the worker
+// reports that it has finished re-encrypting it so that the re-encryption is
+// not resumed the next time the database is opened.
+func storeKeyVersion(databaseName string, progressJSON []byte) {
+	var progress impl.KeyRotationProgressJSON
+	if err := json.Unmarshal(progressJSON, &progress); err != nil {
+		jww.ERROR.Printf("Failed to JSON unmarshal %T from worker: %+v",
+			progress, err)
+		return
+	} else if !progress.Complete || progress.Error != "" {
+		return
+	}
+
+	err := storage.StoreIndexedDbKeyVersion(databaseName, progress.KeyVersion)
+	if err != nil {
+		jww.ERROR.Printf("Failed to store key version %d of database %s: %+v",
+			progress.KeyVersion, databaseName, err)
+	}
+}
+
 // checkDbEncryptionStatus returns an error if the encryption status provided
 // does not match the stored status for this database name.
 func checkDbEncryptionStatus(databaseName string, encryptionStatus bool) error {
@@ -26,4 +26,18 @@ const (
 
 	GetConversationTag  worker.Tag = "GetConversation"
 	GetConversationsTag worker.Tag = "GetConversations"
+	GetMessagesTag      worker.Tag = "GetMessages"
+
+	ListConversationsTag    worker.Tag = "ListConversations"
+	MarkConversationReadTag worker.Tag = "MarkConversationRead"
+	SetConversationStateTag worker.Tag = "SetConversationState"
+
+	RotateKeyTag       worker.Tag = "RotateKey"
+	ExportHistoryTag   worker.Tag = "ExportHistory"
+	CheckDatabaseTag   worker.Tag = "CheckDatabase"
+	GetStorageUsageTag worker.Tag = "GetStorageUsage"
+
+	SaveDraftTag  worker.Tag = "SaveDraft"
+	GetDraftTag   worker.Tag = "GetDraft"
+	ClearDraftTag worker.Tag = "ClearDraft"
 )
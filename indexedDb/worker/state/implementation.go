@@ -15,11 +15,16 @@ import (
 	"github.com/pkg/errors"
 	jww "github.com/spf13/jwalterweatherman"
 
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
 	"gitlab.com/elixxir/xxdk-wasm/worker"
 )
 
 type wasmModel struct {
 	wh *worker.Manager
+
+	// watchers are called with the changes the worker sends with
+	// StateChangeTag
+	watchers *impl.StateWatchers
 }
 
 // TransferMessage is JSON marshalled and sent to the worker.
@@ -70,3 +75,173 @@ func (w *wasmModel) Get(key string) ([]byte, error) {
 
 	return msg.Value, nil
 }
+
+func (w *wasmModel) Delete(key string) error {
+	response, err := w.wh.SendMessage(DeleteTag, []byte(key))
+	if err != nil {
+		jww.FATAL.Panicf("Failed to send message to %q: %+v", DeleteTag, err)
+	} else if len(response) > 0 {
+		return errors.New(string(response))
+	}
+
+	return nil
+}
+
+// KeysReply is JSON marshalled and sent to the main thread in reply to a
+// [KeysTag] message.
+type KeysReply struct {
+	Keys  []string `json:"keys"`
+	Error string   `json:"error"`
+}
+
+func (w *wasmModel) Keys(prefix string) ([]string, error) {
+	response, err := w.wh.SendMessage(KeysTag, []byte(prefix))
+	if err != nil {
+		jww.FATAL.Panicf("Failed to send message to %q: %+v", KeysTag, err)
+	}
+
+	var reply KeysReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return nil, errors.Errorf(
+			"failed to JSON unmarshal %T from worker: %+v", reply, err)
+	}
+
+	if len(reply.Error) > 0 {
+		return nil, errors.New(reply.Error)
+	}
+
+	return reply.Keys, nil
+}
+
+func (w *wasmModel) Clear() error {
+	response, err := w.wh.SendMessage(ClearTag, nil)
+	if err != nil {
+		jww.FATAL.Panicf("Failed to send message to %q: %+v", ClearTag, err)
+	} else if len(response) > 0 {
+		return errors.New(string(response))
+	}
+
+	return nil
+}
+
+// GetManyReply is JSON marshalled and sent to the main thread in reply to a
+// [GetManyTag] message.
+type GetManyReply struct {
+	Values map[string][]byte `json:"values"`
+	Error  string            `json:"error"`
+}
+
+func (w *wasmModel) GetMany(keys []string) (map[string][]byte, error) {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return nil, errors.Errorf(
+			"Could not JSON marshal payload for GetMany: %+v", err)
+	}
+
+	response, err := w.wh.SendMessage(GetManyTag, data)
+	if err != nil {
+		jww.FATAL.Panicf("Failed to send message to %q: %+v", GetManyTag, err)
+	}
+
+	var reply GetManyReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return nil, errors.Errorf(
+			"failed to JSON unmarshal %T from worker: %+v", reply, err)
+	}
+
+	if len(reply.Error) > 0 {
+		return nil, errors.New(reply.Error)
+	}
+
+	return reply.Values, nil
+}
+
+func (w *wasmModel) SetMany(values map[string][]byte) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return errors.Errorf(
+			"Could not JSON marshal payload for SetMany: %+v", err)
+	}
+
+	response, err := w.wh.SendMessage(SetManyTag, data)
+	if err != nil {
+		jww.FATAL.Panicf("Failed to send message to %q: %+v", SetManyTag, err)
+	} else if len(response) > 0 {
+		return errors.New(string(response))
+	}
+
+	return nil
+}
+
+// CompareAndSwapMessage is JSON marshalled and sent to the worker for
+// [CompareAndSwapTag]. A nil Old or New is marshalled as null so that it stays
+// distinct from an empty value.
+type CompareAndSwapMessage struct {
+	Key string `json:"key"`
+	Old []byte `json:"old"`
+	New []byte `json:"new"`
+}
+
+// CompareAndSwapReply is JSON marshalled and sent to the main thread in reply
+// to a [CompareAndSwapTag] message.
+type CompareAndSwapReply struct {
+	Swapped bool   `json:"swapped"`
+	Error   string `json:"error"`
+}
+
+func (w *wasmModel) CompareAndSwap(
+	key string, oldValue, newValue []byte) (bool, error) {
+	msg := CompareAndSwapMessage{
+		Key: key,
+		Old: oldValue,
+		New: newValue,
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return false, errors.Errorf(
+			"Could not JSON marshal payload for CompareAndSwapMessage: %+v", err)
+	}
+
+	response, err := w.wh.SendMessage(CompareAndSwapTag, data)
+	if err != nil {
+		jww.FATAL.Panicf(
+			"Failed to send message to %q: %+v", CompareAndSwapTag, err)
+	}
+
+	var reply CompareAndSwapReply
+	if err = json.Unmarshal(response, &reply); err != nil {
+		return false, errors.Errorf(
+			"failed to JSON unmarshal %T from worker: %+v", reply, err)
+	}
+
+	if len(reply.Error) > 0 {
+		return false, errors.New(reply.Error)
+	}
+
+	return reply.Swapped, nil
+}
+
+func (w *wasmModel) Watch(
+	prefix string, cb func(changes []impl.StateChange)) (stop func()) {
+	return w.watchers.Add(prefix, cb)
+}
+
+// stateChangeCB is the callback for StateChangeTag messages from the worker. It
+// notifies the watchers of the changes.
+func (w *wasmModel) stateChangeCB(message []byte, _ func([]byte)) {
+	var changes []impl.StateChange
+	if err := json.Unmarshal(message, &changes); err != nil {
+		jww.ERROR.Printf("Failed to JSON unmarshal %T from worker: %+v",
+			changes, err)
+		return
+	}
+
+	w.watchers.Notify(changes)
+}
+
+// Close stops the worker manager and terminates the worker. The wasmModel
+// cannot be used after it is closed.
+func (w *wasmModel) Close() error {
+	return w.wh.Stop()
+}
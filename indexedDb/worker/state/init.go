@@ -35,6 +35,13 @@ type NewStateMessage struct {
 type WebState interface {
 	Get(key string) ([]byte, error)
 	Set(key string, value []byte) error
+	Delete(key string) error
+	Keys(prefix string) ([]string, error)
+	Clear() error
+	GetMany(keys []string) (map[string][]byte, error)
+	SetMany(values map[string][]byte) error
+	CompareAndSwap(key string, oldValue, newValue []byte) (bool, error)
+	Watch(prefix string, cb func(changes []impl.StateChange)) (stop func())
 }
 
 // NewState returns a [utility.WebState] backed by indexeddb.
@@ -78,5 +85,8 @@ func NewState(path, wasmJsPath string) (impl.WebState, error) {
 		return nil, errors.New(string(response))
 	}
 
-	return &wasmModel{wh}, nil
+	w := &wasmModel{wh: wh, watchers: impl.NewStateWatchers()}
+	wh.RegisterCallback(StateChangeTag, w.stateChangeCB)
+
+	return w, nil
 }
@@ -17,4 +17,15 @@ const (
 	NewStateTag worker.Tag = "NewState"
 	SetTag      worker.Tag = "Set"
 	GetTag      worker.Tag = "Get"
+	DeleteTag   worker.Tag = "Delete"
+	KeysTag     worker.Tag = "Keys"
+	ClearTag    worker.Tag = "Clear"
+
+	GetManyTag        worker.Tag = "GetMany"
+	SetManyTag        worker.Tag = "SetMany"
+	CompareAndSwapTag worker.Tag = "CompareAndSwap"
+
+	// StateChangeTag is sent by the worker with the JSON of the
+	// []impl.StateChange for every change to the state.
+	StateChangeTag worker.Tag = "StateChange"
 )
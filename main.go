@@ -87,6 +87,27 @@ func setGlobals() {
 		js.FuncOf(storage.ChangeExternalPassword))
 	js.Global().Set("VerifyPassword", js.FuncOf(storage.VerifyPassword))
 
+	// storage/passwordAttempts.go
+	js.Global().Set("GetPasswordAttemptStatus",
+		js.FuncOf(storage.GetPasswordAttemptStatus))
+	js.Global().Set("GetPasswordAttemptPolicy",
+		js.FuncOf(storage.GetPasswordAttemptPolicy))
+	js.Global().Set("SetPasswordAttemptPolicy",
+		js.FuncOf(storage.SetPasswordAttemptPolicy))
+
+	// storage/duress.go
+	js.Global().Set("SetDuressPassword", js.FuncOf(storage.SetDuressPassword))
+	js.Global().Set("VerifyDuressPassword",
+		js.FuncOf(storage.VerifyDuressPassword))
+	js.Global().Set("RemoveDuressPassword",
+		js.FuncOf(storage.RemoveDuressPassword))
+
+	// storage/extendedEncryption.go
+	js.Global().Set("EnableExtendedDbEncryption",
+		js.FuncOf(storage.EnableExtendedDbEncryption))
+	js.Global().Set("IsExtendedDbEncryptionEnabled",
+		js.FuncOf(storage.IsExtendedDbEncryptionEnabled))
+
 	// storage/purge.go
 	js.Global().Set("Purge", js.FuncOf(storage.Purge))
 
@@ -140,10 +161,24 @@ func setGlobals() {
 	js.Global().Set("GetNotificationReportsForMe",
 		js.FuncOf(wasm.GetChannelNotificationReportsForMe))
 
+	// wasm/session.go
+	js.Global().Set("StartSession", js.FuncOf(wasm.StartSession))
+	js.Global().Set("SessionActivity", js.FuncOf(wasm.SessionActivity))
+	js.Global().Set("LockSession", js.FuncOf(wasm.LockSession))
+	js.Global().Set("IsSessionLocked", js.FuncOf(wasm.IsSessionLocked))
+
 	// wasm/cipher.go
 	js.Global().Set("NewDatabaseCipher",
 		js.FuncOf(wasm.NewDatabaseCipher))
 
+	// wasm/state.go
+	js.Global().Set("NewStateStore", js.FuncOf(wasm.NewStateStore))
+
+	// wasm/database.go
+	js.Global().Set("ExportDatabase", js.FuncOf(wasm.ExportDatabase))
+	js.Global().Set("NewDatabaseImporter",
+		js.FuncOf(wasm.NewDatabaseImporter))
+
 	// wasm/channelsFileTransfer.go
 	js.Global().Set("InitChannelsFileTransfer",
 		js.FuncOf(wasm.InitChannelsFileTransfer))
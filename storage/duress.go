@@ -0,0 +1,287 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package storage
+
+import (
+	"encoding/json"
+	"io"
+	"syscall/js"
+
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/elixxir/wasm-utils/exception"
+	"gitlab.com/elixxir/wasm-utils/storage"
+	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/xx_network/crypto/csprng"
+)
+
+// The duress password is stored as a salt and a random token encrypted with a
+// key derived from the duress password, in the same format as the internal
+// password. When no duress password is configured, random data of the same
+// length is stored in its place so that the stored state does not reveal
+// whether a duress password has been set.
+
+// Storage keys.
+const (
+	// Key used to store the duress password salt in local storage.
+	duressSaltKey = "xxInternalPasswordBackupSalt"
+
+	// Key used to store the encrypted duress token in local storage.
+	duressKey = "xxEncryptedInternalPasswordBackup"
+)
+
+// Error messages.
+const (
+	// SetDuressPassword
+	duressMatchesPasswordErr = "duress password cannot be the same as the " +
+		"password"
+
+	// storeDuress
+	readDuressTokenErr     = "could not generate duress token: %+v"
+	duressTokenNumBytesErr = "expected %d bytes for duress token, found %d bytes"
+)
+
+// SetDuressPassword configures or changes the duress password. When the duress
+// password is entered into [GetOrInitPassword], all local data is silently
+// purged, as if by [Purge], and a new internal password is initialised for it
+// as on a fresh install. The duress password is accepted even while password
+// attempts are locked by the [PasswordAttemptPolicy].
+//
+// The user's password is required and checking it counts as a password
+// attempt.
+//
+// Parameters:
+//   - args[0] - The user-supplied password (string).
+//   - args[1] - The new duress password (string).
+//
+// Returns a promise:
+//   - Resolves on success.
+//   - Rejected with an error if the password is incorrect or the duress
+//     password cannot be saved.
+func SetDuressPassword(_ js.Value, args []js.Value) any {
+	externalPassword := args[0].String()
+	duressPassword := args[1].String()
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		err := setDuressPassword(externalPassword, duressPassword)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve()
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// VerifyDuressPassword determines if the given duress password is the
+// configured duress password. No data is purged.
+//
+// The user's password is required and checking it counts as a password
+// attempt.
+//
+// Parameters:
+//   - args[0] - The user-supplied password (string).
+//   - args[1] - The duress password to check (string).
+//
+// Returns a promise:
+//   - Resolves to true if the duress password is correct and false if it is
+//     incorrect (boolean).
+//   - Rejected with an error if the password is incorrect.
+func VerifyDuressPassword(_ js.Value, args []js.Value) any {
+	externalPassword := args[0].String()
+	duressPassword := args[1].String()
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		ls := storage.GetLocalStorage()
+		err := withAttemptLimit(ls, func() error {
+			_, err := getInternalPassword(externalPassword, ls)
+			return err
+		})
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve(isDuressPassword(duressPassword, ls))
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// RemoveDuressPassword removes the configured duress password.
+//
+// The user's password is required and checking it counts as a password
+// attempt.
+//
+// Parameters:
+//   - args[0] - The user-supplied password (string).
+//
+// Returns a promise:
+//   - Resolves on success.
+//   - Rejected with an error if the password is incorrect.
+func RemoveDuressPassword(_ js.Value, args []js.Value) any {
+	externalPassword := args[0].String()
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		ls := storage.GetLocalStorage()
+		err := withAttemptLimit(ls, func() error {
+			_, err := getInternalPassword(externalPassword, ls)
+			return err
+		})
+		if err == nil {
+			err = storeDuressDecoy(ls, csprng.NewSystemRNG())
+		}
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve()
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// setDuressPassword is the private function for SetDuressPassword that is
+// used for testing.
+func setDuressPassword(externalPassword, duressPassword string) error {
+	if externalPassword == duressPassword {
+		return errors.New(duressMatchesPasswordErr)
+	}
+
+	ls := storage.GetLocalStorage()
+	err := withAttemptLimit(ls, func() error {
+		_, err := getInternalPassword(externalPassword, ls)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	params, err := loadArgonParams(ls)
+	if err != nil {
+		return err
+	}
+
+	return storeDuress(duressPassword, ls, csprng.NewSystemRNG(), params)
+}
+
+// purgeOnDuress checks if the password is the duress password. If it is, all
+// local data is purged and a new internal password is initialised for it.
+// Returns false if it is not the duress password or if the purge fails.
+func purgeOnDuress(externalPassword string,
+	ls storage.LocalStorage) (internalPassword []byte, purged bool) {
+	if !isDuressPassword(externalPassword, ls) {
+		return nil, false
+	}
+
+	if err := purgeLocalData(); err != nil {
+		return nil, false
+	}
+
+	internalPassword, err := initInternalPassword(
+		externalPassword, ls, csprng.NewSystemRNG(), defaultParams())
+	if err != nil {
+		return nil, false
+	}
+
+	return internalPassword, true
+}
+
+// isDuressPassword returns true if the password decrypts the stored duress
+// token.
+func isDuressPassword(duressPassword string, ls storage.LocalStorage) bool {
+	encryptedToken, err := ls.Get(duressKey)
+	if err != nil {
+		return false
+	}
+
+	salt, err := ls.Get(duressSaltKey)
+	if err != nil {
+		return false
+	}
+
+	params, err := loadArgonParams(ls)
+	if err != nil {
+		return false
+	}
+
+	key := deriveKey(duressPassword, salt, params)
+	_, err = decryptPassword(encryptedToken, key)
+	return err == nil
+}
+
+// storeDuress generates a random token, encrypts it with a key derived from
+// the duress password, and saves it and its salt to local storage.
+func storeDuress(duressPassword string, ls storage.LocalStorage,
+	csprng io.Reader, params argonParams) error {
+	token := make([]byte, internalPasswordLen)
+	if n, err := csprng.Read(token); err != nil {
+		return errors.Errorf(readDuressTokenErr, err)
+	} else if n != internalPasswordLen {
+		return errors.Errorf(
+			duressTokenNumBytesErr, internalPasswordLen, n)
+	}
+
+	salt, err := makeSalt(csprng)
+	if err != nil {
+		return err
+	}
+
+	key := deriveKey(duressPassword, salt, params)
+	return saveDuress(salt, encryptPassword(token, key, csprng), ls)
+}
+
+// storeDuressDecoy saves random data of the same length as a duress record to
+// local storage so that it is indistinguishable from a configured duress
+// password.
+func storeDuressDecoy(ls storage.LocalStorage, csprng io.Reader) error {
+	salt, err := makeSalt(csprng)
+	if err != nil {
+		return err
+	}
+
+	decoy := make([]byte, chacha20poly1305.NonceSizeX+internalPasswordLen+
+		chacha20poly1305.Overhead)
+	if _, err = io.ReadFull(csprng, decoy); err != nil {
+		return errors.Errorf(readDuressTokenErr, err)
+	}
+
+	return saveDuress(salt, decoy, ls)
+}
+
+// saveDuress saves the duress salt and encrypted token to local storage.
+func saveDuress(salt, encryptedToken []byte, ls storage.LocalStorage) error {
+	if err := ls.Set(duressSaltKey, salt); err != nil {
+		return errors.Wrapf(err, "localStorage: failed to set %q", duressSaltKey)
+	}
+	if err := ls.Set(duressKey, encryptedToken); err != nil {
+		return errors.Wrapf(err, "localStorage: failed to set %q", duressKey)
+	}
+
+	return nil
+}
+
+// loadArgonParams loads the argon2 parameters used for the internal password
+// from local storage.
+func loadArgonParams(ls storage.LocalStorage) (argonParams, error) {
+	paramsData, err := ls.Get(argonParamsKey)
+	if err != nil {
+		return argonParams{}, errors.WithMessage(err, getParamsStorageErr)
+	}
+
+	var params argonParams
+	if err = json.Unmarshal(paramsData, &params); err != nil {
+		return argonParams{}, errors.Errorf(paramsUnmarshalErr, err)
+	}
+
+	return params, nil
+}
@@ -0,0 +1,336 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall/js"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+
+	"gitlab.com/elixxir/wasm-utils/storage"
+	"gitlab.com/xx_network/crypto/csprng"
+)
+
+// Tests that setDuressPassword configures a duress password that is verified
+// by isDuressPassword and that other passwords are not.
+func Test_setDuressPassword_isDuressPassword(t *testing.T) {
+	ls := storage.GetLocalStorage()
+	ls.Clear()
+	externalPassword, duressPassword := "myPassword", "duressPassword"
+
+	if _, err := getOrInit(externalPassword); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if isDuressPassword(duressPassword, ls) {
+		t.Errorf("Duress password found before it was set.")
+	}
+
+	if err := setDuressPassword(externalPassword, duressPassword); err != nil {
+		t.Fatalf("Failed to set duress password: %+v", err)
+	}
+
+	if !isDuressPassword(duressPassword, ls) {
+		t.Errorf("Duress password %q not verified.", duressPassword)
+	}
+	if isDuressPassword(externalPassword, ls) {
+		t.Errorf("Password %q verified as the duress password.",
+			externalPassword)
+	}
+	if isDuressPassword("wrong password", ls) {
+		t.Errorf("Incorrect duress password verified.")
+	}
+
+	// Change the duress password
+	newDuressPassword := "newDuressPassword"
+	if err := setDuressPassword(externalPassword, newDuressPassword); err != nil {
+		t.Fatalf("Failed to change duress password: %+v", err)
+	}
+	if !isDuressPassword(newDuressPassword, ls) {
+		t.Errorf("New duress password %q not verified.", newDuressPassword)
+	}
+	if isDuressPassword(duressPassword, ls) {
+		t.Errorf("Old duress password %q still verified.", duressPassword)
+	}
+}
+
+// Error path: Tests that setDuressPassword returns an error when the duress
+// password matches the password or when the password is incorrect.
+func Test_setDuressPassword_Error(t *testing.T) {
+	storage.GetLocalStorage().Clear()
+	externalPassword := "myPassword"
+
+	if _, err := getOrInit(externalPassword); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	err := setDuressPassword(externalPassword, externalPassword)
+	if err == nil || err.Error() != duressMatchesPasswordErr {
+		t.Errorf("Unexpected error when duress password matches password."+
+			"\nexpected: %s\nreceived: %+v", duressMatchesPasswordErr, err)
+	}
+
+	err = setDuressPassword("wrong password", "duressPassword")
+	if err == nil {
+		t.Errorf("No error setting duress password with wrong password.")
+	}
+	resetAttempts(storage.GetLocalStorage())
+}
+
+// Tests that the stored duress state has the same keys and lengths whether or
+// not a duress password has been configured.
+func Test_storeDuressDecoy_Indistinguishable(t *testing.T) {
+	ls := storage.GetLocalStorage()
+	ls.Clear()
+
+	if err := storeDuressDecoy(ls, csprng.NewSystemRNG()); err != nil {
+		t.Fatalf("Failed to store decoy: %+v", err)
+	}
+	decoySalt, _ := ls.Get(duressSaltKey)
+	decoy, _ := ls.Get(duressKey)
+
+	err := storeDuress(
+		"duressPassword", ls, csprng.NewSystemRNG(), testParams())
+	if err != nil {
+		t.Fatalf("Failed to store duress: %+v", err)
+	}
+	salt, _ := ls.Get(duressSaltKey)
+	encryptedToken, _ := ls.Get(duressKey)
+
+	if len(decoySalt) != len(salt) {
+		t.Errorf("Decoy salt length differs.\nexpected: %d\nreceived: %d",
+			len(salt), len(decoySalt))
+	}
+	if len(decoy) != len(encryptedToken) {
+		t.Errorf("Decoy length differs.\nexpected: %d\nreceived: %d",
+			len(encryptedToken), len(decoy))
+	}
+}
+
+// Tests that initInternalPassword stores a duress decoy that no password
+// verifies.
+func Test_initInternalPassword_DuressDecoy(t *testing.T) {
+	ls := storage.GetLocalStorage()
+	ls.Clear()
+	externalPassword := "myPassword"
+
+	_, err := initInternalPassword(
+		externalPassword, ls, csprng.NewSystemRNG(), testParams())
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	for _, key := range []string{duressSaltKey, duressKey} {
+		if _, err = ls.Get(key); err != nil {
+			t.Errorf("Failed to get %q from storage: %+v", key, err)
+		}
+	}
+
+	if isDuressPassword(externalPassword, ls) {
+		t.Errorf("Password verified against decoy.")
+	}
+}
+
+// Tests that entering the duress password into getOrInit deletes every tracked
+// indexedDb database, clears every local storage key, and initialises a new
+// internal password for the duress password as on a fresh install.
+func Test_getOrInit_DuressPurge(t *testing.T) {
+	ls := storage.GetLocalStorage()
+	ls.Clear()
+	externalPassword, duressPassword := "myPassword", "duressPassword"
+
+	internalPassword, err := getOrInit(externalPassword)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err = setDuressPassword(externalPassword, duressPassword); err != nil {
+		t.Fatalf("Failed to set duress password: %+v", err)
+	}
+
+	// Create and track databases
+	dbNames := []string{"duressDb1", "duressDb2", "duressDb3"}
+	for _, dbName := range dbNames {
+		newTestDatabase(dbName, t)
+		if err = StoreIndexedDb(dbName); err != nil {
+			t.Fatalf("Failed to store database name %q: %+v", dbName, err)
+		}
+	}
+
+	// Add other local storage keys
+	for i := 0; i < 10; i++ {
+		err = ls.Set("duressTestKey"+strconv.Itoa(i), []byte("value"))
+		if err != nil {
+			t.Fatalf("Failed to set key %d: %+v", i, err)
+		}
+	}
+	if _, err = StoreIndexedDbEncryptionStatus(dbNames[0], true); err != nil {
+		t.Fatalf("Failed to store encryption status: %+v", err)
+	}
+	if err = storeAttemptPolicy(defaultAttemptPolicy(), ls); err != nil {
+		t.Fatalf("Failed to store attempt policy: %+v", err)
+	}
+
+	duressInternalPassword, err := getOrInit(duressPassword)
+	if err != nil {
+		t.Fatalf("Failed to get internal password for duress: %+v", err)
+	}
+	if bytes.Equal(internalPassword, duressInternalPassword) {
+		t.Errorf("Duress returned the original internal password.")
+	}
+
+	// Check that all databases were deleted
+	list, err := GetIndexedDbList()
+	if err != nil {
+		t.Fatalf("Failed to get database list: %+v", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("Database list not cleared: %v", list)
+	}
+	for _, dbName := range dbNames {
+		if !databaseDeleted(dbName, t) {
+			t.Errorf("Database %q was not deleted.", dbName)
+		}
+	}
+
+	// Check that only the keys of a fresh install remain
+	expectedKeys := []string{saltKey, passwordKey, argonParamsKey,
+		duressSaltKey, duressKey, attemptRecordKey}
+	keys := ls.Keys()
+	sort.Strings(expectedKeys)
+	sort.Strings(keys)
+	if len(keys) != len(expectedKeys) {
+		t.Fatalf("Unexpected keys in local storage."+
+			"\nexpected: %s\nreceived: %s", expectedKeys, keys)
+	}
+	for i := range keys {
+		if keys[i] != expectedKeys[i] {
+			t.Errorf("Unexpected keys in local storage."+
+				"\nexpected: %s\nreceived: %s", expectedKeys, keys)
+			break
+		}
+	}
+
+	// Check that the old password no longer unlocks and the duress password
+	// behaves as the new password
+	if verifyPassword(externalPassword) {
+		t.Errorf("Original password still valid after duress purge.")
+	}
+	if isDuressPassword(duressPassword, ls) {
+		t.Errorf("Duress password still configured after duress purge.")
+	}
+	loaded, err := getOrInit(duressPassword)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if !bytes.Equal(duressInternalPassword, loaded) {
+		t.Errorf("Internal password from storage does not match."+
+			"\nexpected: %v\nreceived: %v", duressInternalPassword, loaded)
+	}
+}
+
+// newTestDatabase creates a new indexedDb database with a single object store
+// and closes it.
+func newTestDatabase(name string, t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	openRequest, err := idb.Global().Open(ctx, name, 1,
+		func(db *idb.Database, _, _ uint) error {
+			_, err := db.CreateObjectStore("store", idb.ObjectStoreOptions{
+				KeyPath: js.ValueOf("id"),
+			})
+			return err
+		})
+	if err != nil {
+		t.Fatalf("Failed to open database %q: %+v", name, err)
+	}
+	db, err := openRequest.Await(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open database %q: %+v", name, err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatalf("Failed to close database %q: %+v", name, err)
+	}
+}
+
+// databaseDeleted returns true if the indexedDb database does not exist. The
+// database is opened and the upgrade is checked for an old version of 0.
+func databaseDeleted(name string, t *testing.T) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var deleted bool
+	openRequest, err := idb.Global().Open(ctx, name, 1,
+		func(_ *idb.Database, oldVersion, _ uint) error {
+			deleted = oldVersion == 0
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("Failed to open database %q: %+v", name, err)
+	}
+	db, err := openRequest.Await(ctx)
+	if err != nil {
+		t.Fatalf("Failed to open database %q: %+v", name, err)
+	}
+	_ = db.Close()
+	_, _ = idb.Global().DeleteDatabase(name)
+	return deleted
+}
+
+// Tests that the duress password purges local data through
+// getOrInitWithAttemptLimit while password attempts are locked, even though the
+// correct password is rejected.
+func Test_getOrInitWithAttemptLimit_DuressWhileLocked(t *testing.T) {
+	ls := storage.GetLocalStorage()
+	ls.Clear()
+	externalPassword, duressPassword := "myPassword", "duressPassword"
+
+	internalPassword, err := getOrInitWithAttemptLimit(externalPassword)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err = setDuressPassword(externalPassword, duressPassword); err != nil {
+		t.Fatalf("Failed to set duress password: %+v", err)
+	}
+	policy := PasswordAttemptPolicy{
+		MaxAttempts:   1,
+		BaseBackoffMS: 1000,
+		MaxBackoffMS:  1000,
+		LockoutMS:     60000,
+	}
+	if err = storeAttemptPolicy(policy, ls); err != nil {
+		t.Fatalf("Failed to store policy: %+v", err)
+	}
+
+	if _, err = getOrInitWithAttemptLimit("wrong password"); err == nil {
+		t.Fatalf("No error for incorrect password.")
+	}
+	expectedErr := strings.Split(lockedOutErr, "%")[0]
+	_, err = getOrInitWithAttemptLimit(externalPassword)
+	if err == nil || !strings.Contains(err.Error(), expectedErr) {
+		t.Fatalf("Correct password not rejected while locked."+
+			"\nexpected: %s\nreceived: %+v", expectedErr, err)
+	}
+
+	duressInternalPassword, err := getOrInitWithAttemptLimit(duressPassword)
+	if err != nil {
+		t.Fatalf("Duress password rejected while locked: %+v", err)
+	}
+	if bytes.Equal(internalPassword, duressInternalPassword) {
+		t.Errorf("Duress returned the original internal password.")
+	}
+	if verifyPassword(externalPassword) {
+		t.Errorf("Original password still valid after duress purge.")
+	}
+}
@@ -0,0 +1,73 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package storage
+
+import (
+	"syscall/js"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/elixxir/wasm-utils/exception"
+	"gitlab.com/elixxir/wasm-utils/storage"
+)
+
+// Key used to store if extended database encryption is enabled.
+const extendedEncryptionKey = "xxdkWasmExtendedDatabaseEncryption"
+
+// EnableExtendedDbEncryption enables extended encryption for all encrypted
+// event model databases. In addition to message text, the nickname, public key,
+// and DM token of each message and the nickname and DM token of each DM
+// conversation are encrypted with the database cipher. Fields that must remain
+// queryable, such as the sender public key, are replaced with a keyed blind
+// index.
+//
+// The public key of DM partners is not covered. It is stored in plaintext as
+// the key of each DM conversation and draft and with each DM message, so the
+// DM contacts of the user and the number of messages exchanged with each can
+// be read from the database without the password.
+//
+// Extended encryption applies to event models created after it is enabled.
+// Existing messages are encrypted the next time the database is opened. Once
+// enabled, it cannot be disabled. Databases without a cipher are unaffected.
+//
+// Throws an error if the setting cannot be saved.
+func EnableExtendedDbEncryption(js.Value, []js.Value) any {
+	if err := enableExtendedDbEncryption(); err != nil {
+		exception.ThrowTrace(err)
+	}
+	return nil
+}
+
+// IsExtendedDbEncryptionEnabled returns true if extended database encryption
+// has been enabled with [EnableExtendedDbEncryption].
+//
+// Returns:
+//   - Boolean.
+func IsExtendedDbEncryptionEnabled(js.Value, []js.Value) any {
+	return ExtendedDbEncryptionEnabled()
+}
+
+// ExtendedDbEncryptionEnabled returns true if extended database encryption has
+// been enabled.
+func ExtendedDbEncryptionEnabled() bool {
+	data, err := storage.GetLocalStorage().Get(extendedEncryptionKey)
+	return err == nil && len(data) == 1 && data[0] == 1
+}
+
+// enableExtendedDbEncryption saves the extended encryption setting to local
+// storage.
+func enableExtendedDbEncryption() error {
+	err := storage.GetLocalStorage().Set(extendedEncryptionKey, []byte{1})
+	if err != nil {
+		return errors.Wrapf(
+			err, "localStorage: failed to set %q", extendedEncryptionKey)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package storage
+
+import (
+	"testing"
+
+	"gitlab.com/elixxir/wasm-utils/storage"
+)
+
+// Tests that ExtendedDbEncryptionEnabled returns false until
+// enableExtendedDbEncryption is called.
+func Test_enableExtendedDbEncryption(t *testing.T) {
+	storage.GetLocalStorage().Clear()
+
+	if ExtendedDbEncryptionEnabled() {
+		t.Errorf("Extended encryption enabled by default.")
+	}
+
+	if err := enableExtendedDbEncryption(); err != nil {
+		t.Fatalf("Failed to enable extended encryption: %+v", err)
+	}
+
+	if !ExtendedDbEncryptionEnabled() {
+		t.Errorf("Extended encryption not enabled.")
+	}
+}
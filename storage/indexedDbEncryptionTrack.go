@@ -10,6 +10,7 @@
 package storage
 
 import (
+	"encoding/binary"
 	"github.com/pkg/errors"
 	"os"
 
@@ -41,3 +42,30 @@ func StoreIndexedDbEncryptionStatus(
 
 	return data[0] == 1, nil
 }
+
+// Key to store the version of the key a database is fully encrypted with
+const databaseKeyVersionKey = "xxdkWasmDatabaseKeyVersion/"
+
+// StoreIndexedDbKeyVersion stores the version of the key that every value in
+// the database has been re-encrypted with.
+func StoreIndexedDbKeyVersion(databaseName string, version uint32) error {
+	keyName := databaseKeyVersionKey + databaseName
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, version)
+	err := storage.GetLocalStorage().Set(keyName, data)
+	if err != nil {
+		return errors.Wrapf(err, "localStorage: failed to set %q", keyName)
+	}
+	return nil
+}
+
+// LoadIndexedDbKeyVersion returns the version of the key that every value in
+// the database has been re-encrypted with. Returns 0 if no version has been
+// stored.
+func LoadIndexedDbKeyVersion(databaseName string) uint32 {
+	data, err := storage.GetLocalStorage().Get(databaseKeyVersionKey + databaseName)
+	if err != nil || len(data) != 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(data)
+}
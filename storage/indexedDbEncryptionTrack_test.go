@@ -38,3 +38,26 @@ func TestStoreIndexedDbEncryptionStatus(t *testing.T) {
 			true, encryptionStatus)
 	}
 }
+
+// Tests that LoadIndexedDbKeyVersion returns 0 for a database without a stored
+// key version and the version saved by StoreIndexedDbKeyVersion otherwise.
+func TestStoreIndexedDbKeyVersion(t *testing.T) {
+	databaseName := "databaseB"
+
+	if version := LoadIndexedDbKeyVersion(databaseName); version != 0 {
+		t.Errorf("Incorrect default key version.\nexpected: %d\nreceived: %d",
+			0, version)
+	}
+
+	for _, expected := range []uint32{1, 2, 5000} {
+		if err := StoreIndexedDbKeyVersion(databaseName, expected); err != nil {
+			t.Fatalf("Failed to store key version %d: %+v", expected, err)
+		}
+
+		version := LoadIndexedDbKeyVersion(databaseName)
+		if version != expected {
+			t.Errorf("Incorrect key version.\nexpected: %d\nreceived: %d",
+				expected, version)
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"io"
 	"os"
 	"syscall/js"
+	"time"
 
 	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/blake2b"
@@ -90,6 +91,17 @@ const (
 // Any password saved to local storage is encrypted using the user-provided
 // password.
 //
+// If the duress password configured with [SetDuressPassword] is provided, all
+// local data is purged and a new internal password is generated for it, as on
+// a fresh install.
+//
+// Incorrect passwords are limited according to the [PasswordAttemptPolicy].
+// While attempts are locked, the promise is rejected without checking the
+// password, unless it is the duress password.
+//
+// A session locked with [LockSession] is unlocked once the correct password is
+// provided.
+//
 // Parameters:
 //   - args[0] - The user supplied password (string).
 //
@@ -99,10 +111,11 @@ const (
 func GetOrInitPassword(_ js.Value, args []js.Value) any {
 	externalPassword := args[0].String()
 	promiseFn := func(resolve, reject func(args ...any) js.Value) {
-		internalPassword, err := getOrInit(externalPassword)
+		internalPassword, err := getOrInitWithAttemptLimit(externalPassword)
 		if err != nil {
 			reject(exception.NewTrace(err))
 		} else {
+			unlockSession()
 			resolve(utils.CopyBytesToJS(internalPassword))
 		}
 	}
@@ -130,13 +143,49 @@ func ChangeExternalPassword(_ js.Value, args []js.Value) any {
 
 // VerifyPassword determines if the user-provided password is correct.
 //
+// Incorrect passwords are limited according to the [PasswordAttemptPolicy].
+// While attempts are locked, false is returned without checking the password.
+// Use [GetPasswordAttemptStatus] to get the lockout state.
+//
 // Parameters:
 //   - args[0] - The user supplied password (string).
 //
 // Returns:
 //   - True if the password is correct and false if it is incorrect (boolean).
 func VerifyPassword(_ js.Value, args []js.Value) any {
-	return verifyPassword(args[0].String())
+	externalPassword := args[0].String()
+	err := withAttemptLimit(storage.GetLocalStorage(), func() error {
+		if !verifyPassword(externalPassword) {
+			return errIncorrectPassword
+		}
+		return nil
+	})
+	return err == nil
+}
+
+// getOrInitWithAttemptLimit calls getOrInit within the password attempt limit.
+// While attempts are locked, the password is still checked against the duress
+// password so that a coerced user can purge local data during a lockout.
+func getOrInitWithAttemptLimit(externalPassword string) ([]byte, error) {
+	ls := storage.GetLocalStorage()
+
+	var internalPassword []byte
+	var purged bool
+	attemptMux.Lock()
+	if checkAttempt(ls, time.Now()) != nil {
+		internalPassword, purged = purgeOnDuress(externalPassword, ls)
+	}
+	attemptMux.Unlock()
+	if purged {
+		return internalPassword, nil
+	}
+
+	err := withAttemptLimit(ls, func() error {
+		var err error
+		internalPassword, err = getOrInit(externalPassword)
+		return err
+	})
+	return internalPassword, err
 }
 
 // getOrInit is the private function for GetOrInitPassword that is used for
@@ -149,11 +198,23 @@ func getOrInit(externalPassword string) ([]byte, error) {
 			rng := csprng.NewSystemRNG()
 			return initInternalPassword(
 				externalPassword, localStorage, rng, defaultParams())
+		} else if errors.Is(err, errIncorrectPassword) {
+			duressPassword, purged := purgeOnDuress(externalPassword, localStorage)
+			if purged {
+				return duressPassword, nil
+			}
 		}
 
 		return nil, err
 	}
 
+	// Add a duress decoy to storage created before duress passwords existed
+	if _, err = localStorage.Get(duressKey); errors.Is(err, os.ErrNotExist) {
+		if err = storeDuressDecoy(localStorage, csprng.NewSystemRNG()); err != nil {
+			jww.WARN.Printf("Failed to store duress decoy: %+v", err)
+		}
+	}
+
 	return internalPassword, nil
 }
 
@@ -170,6 +231,13 @@ func changeExternalPassword(oldExternalPassword, newExternalPassword string) err
 		return err
 	}
 
+	// The attempt record checksum covers the password record, so it is
+	// stored again once the password is changed
+	record, err := loadAttemptRecord(localStorage)
+	if err != nil {
+		return err
+	}
+
 	salt, err := makeSalt(csprng.NewSystemRNG())
 	if err != nil {
 		return err
@@ -186,7 +254,7 @@ func changeExternalPassword(oldExternalPassword, newExternalPassword string) err
 		return errors.Wrapf(err, "localStorage: failed to set %q", passwordKey)
 	}
 
-	return nil
+	return storeAttemptRecord(record, localStorage)
 }
 
 // verifyPassword is the private function for VerifyPassword that is used for
@@ -248,6 +316,17 @@ func initInternalPassword(externalPassword string,
 			errors.Wrapf(err, "localStorage: failed to set %q", passwordKey)
 	}
 
+	// Store a duress decoy so that storage looks the same whether or not a
+	// duress password is later configured
+	if err = storeDuressDecoy(localStorage, csprng); err != nil {
+		return nil, err
+	}
+
+	// Store the password attempt record with a checksum for this password
+	if err = initAttemptRecord(localStorage); err != nil {
+		return nil, err
+	}
+
 	return internalPassword, nil
 }
 
@@ -281,7 +360,8 @@ func getInternalPassword(
 	decryptedInternalPassword, err :=
 		decryptPassword(encryptedInternalPassword, key)
 	if err != nil {
-		return nil, errors.Errorf(decryptPasswordErr, err)
+		return nil, errors.WithMessagef(
+			errIncorrectPassword, decryptPasswordErr, err)
 	}
 
 	return decryptedInternalPassword, nil
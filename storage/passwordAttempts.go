@@ -0,0 +1,554 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package storage
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"os"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/wasm-utils/exception"
+	"gitlab.com/elixxir/wasm-utils/storage"
+	"gitlab.com/elixxir/wasm-utils/utils"
+)
+
+// The PasswordAttemptPolicy and attemptState are stored together in a single
+// attemptRecord with a checksum over the record and the stored internal
+// password record. A record that was changed, copied from another password
+// record, or removed while an internal password is stored fails the check and
+// is replaced with one that has reached MaxAttempts.
+//
+// The checksum is only tamper-evident. It is computed from values in local
+// storage, so a script with access to local storage can recompute it and reset
+// or forge the record.
+
+// Storage keys.
+const (
+	// Key used to store the attemptRecord and its checksum in local storage.
+	attemptRecordKey = "xxPasswordAttempts"
+)
+
+// attemptChecksumConstant is used to derive the key of the attemptRecord
+// checksum.
+const attemptChecksumConstant = "XXPasswordAttemptChecksum"
+
+// Error messages.
+const (
+	// checkAttempt
+	lockedOutErr = "too many incorrect password attempts; locked for %s"
+
+	// withAttemptLimit
+	purgedOnLockoutErr = "too many incorrect password attempts; all local " +
+		"data has been purged"
+
+	// loadAttemptRecord
+	recordUnmarshalErr = "failed to unmarshal password attempt record: %+v"
+
+	// SetPasswordAttemptPolicy
+	invalidPolicyErr = "invalid password attempt policy: %s"
+)
+
+// errIncorrectPassword is returned by getInternalPassword when the external
+// password cannot decrypt the internal password. Only errors wrapping it are
+// counted as failed attempts.
+var errIncorrectPassword = errors.New("incorrect password")
+
+// attemptMux is held while attempts are checked and recorded so that
+// concurrent password checks cannot each be allowed the last attempt.
+var attemptMux sync.Mutex
+
+// PasswordAttemptPolicy describes how failed password attempts are limited.
+//
+// Every failed attempt locks out further attempts for a period that starts at
+// BaseBackoffMS and doubles on each consecutive failure, up to MaxBackoffMS.
+// Once MaxAttempts consecutive failures are reached, attempts are locked for
+// LockoutMS, or, if Purge is set, all local data is purged as if
+// [Purge] was called. A correct password resets the count.
+//
+// Example JSON:
+//
+//	{
+//	  "MaxAttempts": 10,
+//	  "BaseBackoffMS": 1000,
+//	  "MaxBackoffMS": 300000,
+//	  "LockoutMS": 3600000,
+//	  "Purge": false
+//	}
+type PasswordAttemptPolicy struct {
+	// MaxAttempts is the number of consecutive failures allowed before
+	// locking or purging. Set to 0 to only apply the backoff.
+	MaxAttempts uint32 `json:"MaxAttempts"`
+
+	// BaseBackoffMS is the lockout, in milliseconds, after the first failure.
+	BaseBackoffMS int64 `json:"BaseBackoffMS"`
+
+	// MaxBackoffMS is the upper bound, in milliseconds, of the backoff.
+	MaxBackoffMS int64 `json:"MaxBackoffMS"`
+
+	// LockoutMS is the lockout, in milliseconds, applied on every failure
+	// once MaxAttempts has been reached.
+	LockoutMS int64 `json:"LockoutMS"`
+
+	// Purge, if true, purges all local data once MaxAttempts is reached
+	// instead of locking.
+	Purge bool `json:"Purge"`
+}
+
+// PasswordAttemptStatus describes the current state of password attempt
+// limiting.
+//
+// Example JSON:
+//
+//	{
+//	  "Failures": 3,
+//	  "RemainingAttempts": 7,
+//	  "Locked": true,
+//	  "RetryInMS": 3850
+//	}
+type PasswordAttemptStatus struct {
+	// Failures is the number of consecutive failed attempts.
+	Failures uint32 `json:"Failures"`
+
+	// RemainingAttempts is the number of failures left before the policy
+	// locks or purges. It is -1 when MaxAttempts is 0.
+	RemainingAttempts int64 `json:"RemainingAttempts"`
+
+	// Locked is true if attempts are currently rejected.
+	Locked bool `json:"Locked"`
+
+	// RetryInMS is the time, in milliseconds, until the next attempt is
+	// accepted.
+	RetryInMS int64 `json:"RetryInMS"`
+}
+
+// attemptState is the record of failed attempts.
+type attemptState struct {
+	Failures    uint32 `json:"failures"`
+	LockedUntil int64  `json:"lockedUntil"` // Unix nanoseconds
+}
+
+// attemptRecord is the policy and state saved to local storage.
+type attemptRecord struct {
+	Policy PasswordAttemptPolicy `json:"policy"`
+	State  attemptState          `json:"state"`
+}
+
+// defaultAttemptPolicy returns the policy used when none has been set.
+func defaultAttemptPolicy() PasswordAttemptPolicy {
+	return PasswordAttemptPolicy{
+		MaxAttempts:   10,
+		BaseBackoffMS: 1000,
+		MaxBackoffMS:  5 * 60 * 1000,
+		LockoutMS:     60 * 60 * 1000,
+		Purge:         false,
+	}
+}
+
+// GetPasswordAttemptStatus returns the current lockout state and the number of
+// remaining password attempts.
+//
+// Returns:
+//   - JSON of [PasswordAttemptStatus] (Uint8Array).
+//   - Throws an error if the state cannot be loaded.
+func GetPasswordAttemptStatus(js.Value, []js.Value) any {
+	attemptMux.Lock()
+	status, err := getAttemptStatus(storage.GetLocalStorage(), time.Now())
+	attemptMux.Unlock()
+	if err != nil {
+		exception.ThrowTrace(err)
+		return nil
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		exception.ThrowTrace(err)
+		return nil
+	}
+
+	return utils.CopyBytesToJS(data)
+}
+
+// GetPasswordAttemptPolicy returns the current password attempt policy.
+//
+// Returns:
+//   - JSON of [PasswordAttemptPolicy] (Uint8Array).
+//   - Throws an error if the policy cannot be loaded.
+func GetPasswordAttemptPolicy(js.Value, []js.Value) any {
+	attemptMux.Lock()
+	policy, err := loadAttemptPolicy(storage.GetLocalStorage())
+	attemptMux.Unlock()
+	if err != nil {
+		exception.ThrowTrace(err)
+		return nil
+	}
+
+	data, err := json.Marshal(policy)
+	if err != nil {
+		exception.ThrowTrace(err)
+		return nil
+	}
+
+	return utils.CopyBytesToJS(data)
+}
+
+// SetPasswordAttemptPolicy sets the password attempt policy. The user's
+// password is required and checking it counts as an attempt.
+//
+// The policy is cleared along with all other local storage on [Purge].
+//
+// Parameters:
+//   - args[0] - The user-supplied password (string).
+//   - args[1] - JSON of [PasswordAttemptPolicy] (Uint8Array).
+//
+// Returns:
+//   - Throws an error if the password is incorrect, attempts are locked, or
+//     the policy is invalid.
+func SetPasswordAttemptPolicy(_ js.Value, args []js.Value) any {
+	externalPassword := args[0].String()
+	policyJSON := utils.CopyBytesToGo(args[1])
+
+	var policy PasswordAttemptPolicy
+	if err := json.Unmarshal(policyJSON, &policy); err != nil {
+		exception.ThrowTrace(err)
+		return nil
+	} else if err = policy.verify(); err != nil {
+		exception.ThrowTrace(err)
+		return nil
+	}
+
+	ls := storage.GetLocalStorage()
+	err := withAttemptLimit(ls, func() error {
+		if _, err := getInternalPassword(externalPassword, ls); err != nil {
+			return err
+		}
+		return storeAttemptPolicy(policy, ls)
+	})
+	if err != nil {
+		exception.ThrowTrace(err)
+		return nil
+	}
+
+	return nil
+}
+
+// withAttemptLimit runs the password check try if attempts are not currently
+// locked. If try returns an error wrapping errIncorrectPassword, the failure
+// is recorded according to the policy; if it succeeds, the failure count is
+// reset.
+//
+// Only one password check runs at a time. The check, try, and the recording of
+// its result are done under attemptMux so that concurrent checks see each
+// other's failures.
+func withAttemptLimit(ls storage.LocalStorage, try func() error) error {
+	attemptMux.Lock()
+	defer attemptMux.Unlock()
+
+	now := time.Now()
+	if err := checkAttempt(ls, now); err != nil {
+		return err
+	}
+
+	err := try()
+	if err == nil {
+		if resetErr := resetAttempts(ls); resetErr != nil {
+			jww.ERROR.Printf("[PASSWORD] Failed to reset password attempts: "+
+				"%+v", resetErr)
+		}
+		return nil
+	} else if !errors.Is(err, errIncorrectPassword) {
+		return err
+	}
+
+	purge, recordErr := recordFailedAttempt(ls, now)
+	if recordErr != nil {
+		jww.ERROR.Printf("[PASSWORD] Failed to record failed password "+
+			"attempt: %+v", recordErr)
+	}
+
+	if purge {
+		jww.WARN.Printf("[PASSWORD] Maximum password attempts reached; " +
+			"purging all local data")
+		if purgeErr := purgeLocalData(); purgeErr != nil {
+			return errors.WithMessage(purgeErr, purgedOnLockoutErr)
+		}
+		return errors.New(purgedOnLockoutErr)
+	}
+
+	return err
+}
+
+// checkAttempt returns an error if attempts are locked at the given time.
+func checkAttempt(ls storage.LocalStorage, now time.Time) error {
+	record, err := loadAttemptRecord(ls)
+	if err != nil {
+		return err
+	}
+
+	if retryIn := time.Unix(0, record.State.LockedUntil).Sub(now); retryIn > 0 {
+		return errors.Errorf(lockedOutErr, retryIn.Round(time.Second))
+	}
+
+	return nil
+}
+
+// recordFailedAttempt increments the failure count and sets the next lockout
+// according to the policy. Returns true if the policy requires a purge.
+func recordFailedAttempt(
+	ls storage.LocalStorage, now time.Time) (purge bool, err error) {
+	record, err := loadAttemptRecord(ls)
+	if err != nil {
+		return false, err
+	}
+	policy, state := record.Policy, &record.State
+
+	state.Failures++
+	lockout := policy.backoff(state.Failures)
+	if policy.MaxAttempts > 0 && state.Failures >= policy.MaxAttempts {
+		if policy.Purge {
+			return true, nil
+		}
+		lockout = time.Duration(policy.LockoutMS) * time.Millisecond
+	}
+	state.LockedUntil = now.Add(lockout).UnixNano()
+
+	jww.WARN.Printf("[PASSWORD] Incorrect password attempt %d; locked for %s",
+		state.Failures, lockout)
+
+	return false, storeAttemptRecord(record, ls)
+}
+
+// resetAttempts clears all recorded failures.
+func resetAttempts(ls storage.LocalStorage) error {
+	return storeAttemptState(attemptState{}, ls)
+}
+
+// getAttemptStatus builds the PasswordAttemptStatus at the given time.
+func getAttemptStatus(
+	ls storage.LocalStorage, now time.Time) (PasswordAttemptStatus, error) {
+	record, err := loadAttemptRecord(ls)
+	if err != nil {
+		return PasswordAttemptStatus{}, err
+	}
+	policy, state := record.Policy, record.State
+
+	status := PasswordAttemptStatus{
+		Failures:          state.Failures,
+		RemainingAttempts: -1,
+	}
+	if policy.MaxAttempts > 0 {
+		status.RemainingAttempts = int64(policy.MaxAttempts) -
+			int64(state.Failures)
+		if status.RemainingAttempts < 0 {
+			status.RemainingAttempts = 0
+		}
+	}
+	if retryIn := time.Unix(0, state.LockedUntil).Sub(now); retryIn > 0 {
+		status.Locked = true
+		status.RetryInMS = retryIn.Milliseconds()
+	}
+
+	return status, nil
+}
+
+// backoff returns the lockout after the given number of consecutive failures.
+// It doubles BaseBackoffMS for each failure after the first, capped at
+// MaxBackoffMS.
+func (p PasswordAttemptPolicy) backoff(failures uint32) time.Duration {
+	if failures == 0 || p.BaseBackoffMS <= 0 {
+		return 0
+	}
+
+	backoff := p.BaseBackoffMS
+	for i := uint32(1); i < failures && backoff < p.MaxBackoffMS; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoffMS {
+		backoff = p.MaxBackoffMS
+	}
+
+	return time.Duration(backoff) * time.Millisecond
+}
+
+// verify returns an error if the policy contains invalid values.
+func (p PasswordAttemptPolicy) verify() error {
+	switch {
+	case p.BaseBackoffMS < 0:
+		return errors.Errorf(invalidPolicyErr, "negative BaseBackoffMS")
+	case p.MaxBackoffMS < p.BaseBackoffMS:
+		return errors.Errorf(invalidPolicyErr,
+			"MaxBackoffMS smaller than BaseBackoffMS")
+	case p.LockoutMS < 0:
+		return errors.Errorf(invalidPolicyErr, "negative LockoutMS")
+	}
+	return nil
+}
+
+// loadAttemptPolicy loads the PasswordAttemptPolicy from local storage. If
+// none has been stored, the default policy is returned.
+func loadAttemptPolicy(
+	ls storage.LocalStorage) (PasswordAttemptPolicy, error) {
+	record, err := loadAttemptRecord(ls)
+	if err != nil {
+		return PasswordAttemptPolicy{}, err
+	}
+	return record.Policy, nil
+}
+
+// storeAttemptPolicy saves the PasswordAttemptPolicy to local storage.
+func storeAttemptPolicy(
+	policy PasswordAttemptPolicy, ls storage.LocalStorage) error {
+	if err := policy.verify(); err != nil {
+		return err
+	}
+
+	record, err := loadAttemptRecord(ls)
+	if err != nil {
+		return err
+	}
+	record.Policy = policy
+	return storeAttemptRecord(record, ls)
+}
+
+// storeAttemptState saves the attemptState to local storage.
+func storeAttemptState(state attemptState, ls storage.LocalStorage) error {
+	record, err := loadAttemptRecord(ls)
+	if err != nil {
+		return err
+	}
+	record.State = state
+	return storeAttemptRecord(record, ls)
+}
+
+// initAttemptRecord stores a record with the default policy if an internal
+// password is stored without one. It is used when the internal password is
+// created and to add the record to storage created before it existed.
+func initAttemptRecord(ls storage.LocalStorage) error {
+	if _, err := attemptChecksumKey(ls); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	if _, err := ls.Get(attemptRecordKey); err == nil {
+		return nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return storeAttemptRecord(attemptRecord{Policy: defaultAttemptPolicy()}, ls)
+}
+
+// loadAttemptRecord loads the attemptRecord from local storage and checks its
+// checksum. If no internal password is stored, the default policy with no
+// failures is returned. If the record is missing or its checksum does not
+// match, it is replaced with a record with the default policy that has reached
+// MaxAttempts and is locked for LockoutMS.
+func loadAttemptRecord(ls storage.LocalStorage) (attemptRecord, error) {
+	key, err := attemptChecksumKey(ls)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return attemptRecord{Policy: defaultAttemptPolicy()}, nil
+		}
+		return attemptRecord{}, err
+	}
+
+	data, err := ls.Get(attemptRecordKey)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return attemptRecord{}, err
+	}
+
+	if err != nil || len(data) < blake2b.Size256 || !hmac.Equal(
+		data[:blake2b.Size256], attemptChecksum(key, data[blake2b.Size256:])) {
+		jww.WARN.Printf("[PASSWORD] Password attempt record is missing or " +
+			"has been modified; locking password attempts")
+		policy := defaultAttemptPolicy()
+		record := attemptRecord{
+			Policy: policy,
+			State: attemptState{
+				Failures: policy.MaxAttempts,
+				LockedUntil: time.Now().Add(
+					time.Duration(policy.LockoutMS) * time.Millisecond).
+					UnixNano(),
+			},
+		}
+		return record, storeAttemptRecord(record, ls)
+	}
+
+	var record attemptRecord
+	if err = json.Unmarshal(data[blake2b.Size256:], &record); err != nil {
+		return attemptRecord{}, errors.Errorf(recordUnmarshalErr, err)
+	}
+
+	return record, nil
+}
+
+// storeAttemptRecord saves the attemptRecord with its checksum to local storage.
+func storeAttemptRecord(record attemptRecord, ls storage.LocalStorage) error {
+	key, err := attemptChecksumKey(ls)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	data = append(attemptChecksum(key, data), data...)
+	if err = ls.Set(attemptRecordKey, data); err != nil {
+		return errors.Wrapf(err, "localStorage: failed to set %q",
+			attemptRecordKey)
+	}
+
+	return nil
+}
+
+// attemptChecksumKey returns the key of the attemptRecord checksum. It is
+// derived from the stored salt and encrypted internal password so that the
+// record is tied to the current internal password record. It is not secret. Returns an error wrapping
+// [os.ErrNotExist] if no internal password is stored.
+func attemptChecksumKey(ls storage.LocalStorage) ([]byte, error) {
+	salt, err := ls.Get(saltKey)
+	if err != nil {
+		return nil, err
+	}
+	encryptedInternalPassword, err := ls.Get(passwordKey)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := blake2b.New256(nil)
+	if err != nil {
+		return nil, err
+	}
+	h.Write([]byte(attemptChecksumConstant))
+	h.Write(salt)
+	h.Write(encryptedInternalPassword)
+	return h.Sum(nil), nil
+}
+
+// attemptChecksum returns the checksum of the marshalled attemptRecord.
+func attemptChecksum(key, data []byte) []byte {
+	h, err := blake2b.New256(key)
+	if err != nil {
+		jww.FATAL.Panicf("Failed to create attempt record checksum: %+v", err)
+	}
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,307 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package storage
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/elixxir/wasm-utils/storage"
+	"gitlab.com/xx_network/crypto/csprng"
+)
+
+// newAttemptTestStorage clears local storage and stores a random internal
+// password record and a new attempt record.
+func newAttemptTestStorage(t *testing.T) storage.LocalStorage {
+	ls := storage.GetLocalStorage()
+	ls.Clear()
+
+	rng := csprng.NewSystemRNG()
+	for _, key := range []string{saltKey, passwordKey} {
+		data := make([]byte, 32)
+		if _, err := rng.Read(data); err != nil {
+			t.Fatalf("Failed to generate %s: %+v", key, err)
+		}
+		if err := ls.Set(key, data); err != nil {
+			t.Fatalf("Failed to store %s: %+v", key, err)
+		}
+	}
+	if err := initAttemptRecord(ls); err != nil {
+		t.Fatalf("Failed to initialise attempt record: %+v", err)
+	}
+
+	return ls
+}
+
+// Tests that PasswordAttemptPolicy.backoff doubles on each failure and is
+// capped at MaxBackoffMS.
+func TestPasswordAttemptPolicy_backoff(t *testing.T) {
+	p := PasswordAttemptPolicy{BaseBackoffMS: 1000, MaxBackoffMS: 10000}
+	expected := []time.Duration{0, 1 * time.Second, 2 * time.Second,
+		4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+
+	for i, exp := range expected {
+		if backoff := p.backoff(uint32(i)); backoff != exp {
+			t.Errorf("Incorrect backoff for %d failures."+
+				"\nexpected: %s\nreceived: %s", i, exp, backoff)
+		}
+	}
+
+	// Ensure a large number of failures does not overflow
+	if backoff := p.backoff(1 << 31); backoff != 10*time.Second {
+		t.Errorf("Incorrect backoff for large number of failures."+
+			"\nexpected: %s\nreceived: %s", 10*time.Second, backoff)
+	}
+}
+
+// Tests that a stored PasswordAttemptPolicy can be loaded and that the default
+// policy is returned when none is stored.
+func Test_storeAttemptPolicy_loadAttemptPolicy(t *testing.T) {
+	ls := newAttemptTestStorage(t)
+
+	policy, err := loadAttemptPolicy(ls)
+	if err != nil {
+		t.Fatalf("Failed to load default policy: %+v", err)
+	}
+	if !reflect.DeepEqual(defaultAttemptPolicy(), policy) {
+		t.Errorf("Unexpected default policy.\nexpected: %+v\nreceived: %+v",
+			defaultAttemptPolicy(), policy)
+	}
+
+	expected := PasswordAttemptPolicy{
+		MaxAttempts:   3,
+		BaseBackoffMS: 5,
+		MaxBackoffMS:  50,
+		LockoutMS:     500,
+		Purge:         true,
+	}
+	if err = storeAttemptPolicy(expected, ls); err != nil {
+		t.Fatalf("Failed to store policy: %+v", err)
+	}
+
+	policy, err = loadAttemptPolicy(ls)
+	if err != nil {
+		t.Fatalf("Failed to load policy: %+v", err)
+	}
+	if !reflect.DeepEqual(expected, policy) {
+		t.Errorf("Loaded policy does not match stored."+
+			"\nexpected: %+v\nreceived: %+v", expected, policy)
+	}
+}
+
+// Error path: Tests that storeAttemptPolicy rejects an invalid policy.
+func Test_storeAttemptPolicy_InvalidPolicyError(t *testing.T) {
+	ls := newAttemptTestStorage(t)
+
+	policy := PasswordAttemptPolicy{BaseBackoffMS: 10, MaxBackoffMS: 5}
+	expectedErr := strings.Split(invalidPolicyErr, "%")[0]
+	err := storeAttemptPolicy(policy, ls)
+	if err == nil || !strings.Contains(err.Error(), expectedErr) {
+		t.Errorf("Unexpected error for invalid policy."+
+			"\nexpected: %s\nreceived: %+v", expectedErr, err)
+	}
+}
+
+// Tests that recordFailedAttempt locks attempts with backoff, that
+// checkAttempt returns an error until the lockout expires, and that the
+// lockout is applied once MaxAttempts is reached.
+func Test_recordFailedAttempt_checkAttempt(t *testing.T) {
+	ls := newAttemptTestStorage(t)
+	policy := PasswordAttemptPolicy{
+		MaxAttempts:   3,
+		BaseBackoffMS: 1000,
+		MaxBackoffMS:  4000,
+		LockoutMS:     60000,
+	}
+	if err := storeAttemptPolicy(policy, ls); err != nil {
+		t.Fatalf("Failed to store policy: %+v", err)
+	}
+
+	now := time.Unix(1000, 0)
+	if err := checkAttempt(ls, now); err != nil {
+		t.Fatalf("Attempts locked before any failures: %+v", err)
+	}
+
+	expectedLockouts := []time.Duration{
+		1 * time.Second, 2 * time.Second, 60 * time.Second}
+	for i, lockout := range expectedLockouts {
+		purge, err := recordFailedAttempt(ls, now)
+		if err != nil {
+			t.Fatalf("Failed to record attempt %d: %+v", i, err)
+		} else if purge {
+			t.Errorf("Purge requested for attempt %d when disabled.", i)
+		}
+
+		expectedErr := strings.Split(lockedOutErr, "%")[0]
+		err = checkAttempt(ls, now.Add(lockout-time.Millisecond))
+		if err == nil || !strings.Contains(err.Error(), expectedErr) {
+			t.Errorf("Attempt %d not locked before lockout expired."+
+				"\nexpected: %s\nreceived: %+v", i, expectedErr, err)
+		}
+
+		now = now.Add(lockout)
+		if err = checkAttempt(ls, now); err != nil {
+			t.Errorf("Attempt %d locked after lockout expired: %+v", i, err)
+		}
+	}
+
+	status, err := getAttemptStatus(ls, now)
+	if err != nil {
+		t.Fatalf("Failed to get status: %+v", err)
+	}
+	expected := PasswordAttemptStatus{Failures: 3, RemainingAttempts: 0}
+	if !reflect.DeepEqual(expected, status) {
+		t.Errorf("Unexpected status.\nexpected: %+v\nreceived: %+v",
+			expected, status)
+	}
+}
+
+// Tests that recordFailedAttempt requests a purge once MaxAttempts is reached
+// when Purge is set.
+func Test_recordFailedAttempt_Purge(t *testing.T) {
+	ls := newAttemptTestStorage(t)
+	policy := PasswordAttemptPolicy{MaxAttempts: 2, Purge: true}
+	if err := storeAttemptPolicy(policy, ls); err != nil {
+		t.Fatalf("Failed to store policy: %+v", err)
+	}
+
+	now := time.Unix(1000, 0)
+	for i := uint32(1); i <= policy.MaxAttempts; i++ {
+		purge, err := recordFailedAttempt(ls, now)
+		if err != nil {
+			t.Fatalf("Failed to record attempt %d: %+v", i, err)
+		}
+		if expected := i == policy.MaxAttempts; purge != expected {
+			t.Errorf("Unexpected purge for attempt %d."+
+				"\nexpected: %t\nreceived: %t", i, expected, purge)
+		}
+	}
+}
+
+// Tests that withAttemptLimit only counts errIncorrectPassword as a failure,
+// rejects attempts while locked without calling try, and resets the count on
+// success.
+func Test_withAttemptLimit(t *testing.T) {
+	ls := newAttemptTestStorage(t)
+	policy := PasswordAttemptPolicy{MaxAttempts: 5, LockoutMS: 60000}
+	if err := storeAttemptPolicy(policy, ls); err != nil {
+		t.Fatalf("Failed to store policy: %+v", err)
+	}
+
+	// Errors other than an incorrect password are not counted
+	otherErr := errors.New("other error")
+	err := withAttemptLimit(ls, func() error { return otherErr })
+	if !errors.Is(err, otherErr) {
+		t.Errorf("Unexpected error.\nexpected: %v\nreceived: %+v", otherErr, err)
+	}
+
+	// Incorrect passwords are counted
+	for i := 0; i < 2; i++ {
+		err = withAttemptLimit(ls, func() error { return errIncorrectPassword })
+		if !errors.Is(err, errIncorrectPassword) {
+			t.Errorf("Unexpected error.\nexpected: %v\nreceived: %+v",
+				errIncorrectPassword, err)
+		}
+	}
+
+	status, err := getAttemptStatus(ls, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to get status: %+v", err)
+	}
+	if status.Failures != 2 || status.RemainingAttempts != 3 {
+		t.Errorf("Unexpected status.\nexpected: %+v\nreceived: %+v",
+			PasswordAttemptStatus{Failures: 2, RemainingAttempts: 3}, status)
+	}
+
+	// A correct password resets the count
+	err = withAttemptLimit(ls, func() error { return nil })
+	if err != nil {
+		t.Errorf("Unexpected error for correct password: %+v", err)
+	}
+
+	status, err = getAttemptStatus(ls, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to get status: %+v", err)
+	}
+	if status.Failures != 0 || status.Locked {
+		t.Errorf("Failures not reset on success: %+v", status)
+	}
+
+	// Attempts are rejected while locked
+	err = storeAttemptState(attemptState{
+		Failures:    1,
+		LockedUntil: time.Now().Add(time.Hour).UnixNano(),
+	}, ls)
+	if err != nil {
+		t.Fatalf("Failed to store state: %+v", err)
+	}
+	var called bool
+	err = withAttemptLimit(ls, func() error { called = true; return nil })
+	expectedErr := strings.Split(lockedOutErr, "%")[0]
+	if err == nil || !strings.Contains(err.Error(), expectedErr) {
+		t.Errorf("Unexpected error while locked."+
+			"\nexpected: %s\nreceived: %+v", expectedErr, err)
+	}
+	if called {
+		t.Errorf("Password checked while locked.")
+	}
+}
+
+// Tests that getInternalPassword returns an error wrapping
+// errIncorrectPassword for an incorrect password.
+func Test_getInternalPassword_IncorrectPassword(t *testing.T) {
+	ls := storage.GetLocalStorage()
+	ls.Clear()
+
+	if _, err := getOrInit("myPassword"); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	_, err := getInternalPassword("wrong password", ls)
+	if !errors.Is(err, errIncorrectPassword) {
+		t.Errorf("Unexpected error for incorrect password."+
+			"\nexpected: %v\nreceived: %+v", errIncorrectPassword, err)
+	}
+}
+
+// Tests that concurrent calls to withAttemptLimit cannot each be allowed the
+// last attempt.
+func Test_withAttemptLimit_Concurrent(t *testing.T) {
+	ls := newAttemptTestStorage(t)
+	policy := PasswordAttemptPolicy{MaxAttempts: 1, LockoutMS: 60000}
+	if err := storeAttemptPolicy(policy, ls); err != nil {
+		t.Fatalf("Failed to store policy: %+v", err)
+	}
+
+	var tries int
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = withAttemptLimit(ls, func() error {
+				tries++
+				time.Sleep(time.Millisecond)
+				return errIncorrectPassword
+			})
+		}()
+	}
+	wg.Wait()
+
+	if tries != 1 {
+		t.Errorf("Unexpected number of password checks."+
+			"\nexpected: %d\nreceived: %d", 1, tries)
+	}
+}
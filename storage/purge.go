@@ -10,16 +10,23 @@
 package storage
 
 import (
+	"context"
 	"sync/atomic"
 	"syscall/js"
+	"time"
 
 	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/pkg/errors"
 	jww "github.com/spf13/jwalterweatherman"
 
 	"gitlab.com/elixxir/wasm-utils/exception"
 	"gitlab.com/elixxir/wasm-utils/storage"
 )
 
+// deleteDatabaseTimeout is the time to wait for an indexedDb database to be
+// deleted.
+const deleteDatabaseTimeout = 5 * time.Second
+
 // numClientsRunning is an atomic that tracks the current number of Cmix
 // followers that have been started. Every time one is started, this counter
 // must be incremented and every time one is stopped, it must be decremented.
@@ -54,8 +61,14 @@ func Purge(_ js.Value, args []js.Value) any {
 	userPassword := args[0].String()
 
 	// Check the password
-	if !verifyPassword(userPassword) {
-		exception.Throwf("invalid password")
+	err := withAttemptLimit(storage.GetLocalStorage(), func() error {
+		if !verifyPassword(userPassword) {
+			return errIncorrectPassword
+		}
+		return nil
+	})
+	if err != nil {
+		exception.Throwf("invalid password: %+v", err)
 		return nil
 	}
 
@@ -65,23 +78,44 @@ func Purge(_ js.Value, args []js.Value) any {
 		return nil
 	}
 
+	if err = purgeLocalData(); err != nil {
+		exception.ThrowTrace(err)
+		return nil
+	}
+
+	return nil
+}
+
+// purgeLocalData deletes all indexedDb databases and clears all local storage
+// saved by this WASM binary.
+func purgeLocalData() error {
 	// Get all indexedDb database names
 	databaseList, err := GetIndexedDbList()
 	if err != nil {
-		exception.Throwf(
+		return errors.Errorf(
 			"failed to get list of indexedDb database names: %+v", err)
-		return nil
 	}
 	jww.DEBUG.Printf("[PURGE] Found %d databases to delete: %s",
 		len(databaseList), databaseList)
 
 	// Delete each database
 	for dbName := range databaseList {
-		_, err = idb.Global().DeleteDatabase(dbName)
+		req, err := idb.Global().DeleteDatabase(dbName)
 		if err != nil {
-			exception.Throwf(
+			return errors.Errorf(
 				"failed to delete indexedDb database %q: %+v", dbName, err)
-			return nil
+		}
+
+		// Wait for the deletion to complete. Deletion is blocked while
+		// connections to the database remain open, so on timeout, the
+		// deletion is left to complete once they are closed.
+		ctx, cancel := context.WithTimeout(
+			context.Background(), deleteDatabaseTimeout)
+		err = req.Await(ctx)
+		cancel()
+		if err != nil {
+			jww.WARN.Printf("[PURGE] Deletion of database %q not yet "+
+				"complete: %+v", dbName, err)
 		}
 	}
 
@@ -0,0 +1,38 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package storage
+
+import (
+	"sync/atomic"
+)
+
+// sessionLocked is true when the session has been locked (e.g., after an idle
+// timeout) and the user must unlock it again with GetOrInitPassword.
+//
+// This variable is an atomic. Only access it with atomic functions
+var sessionLocked atomic.Bool
+
+// LockSession marks the session as locked. It remains locked until the correct
+// password is passed into GetOrInitPassword.
+func LockSession() {
+	sessionLocked.Store(true)
+}
+
+// IsSessionLocked returns true if the session has been locked and not yet
+// unlocked with GetOrInitPassword.
+func IsSessionLocked() bool {
+	return sessionLocked.Load()
+}
+
+// unlockSession marks the session as unlocked. It is called once the correct
+// password has been provided.
+func unlockSession() {
+	sessionLocked.Store(false)
+}
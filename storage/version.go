@@ -21,7 +21,7 @@ import (
 )
 
 // SEMVER is the current semantic version of xxDK WASM.
-const SEMVER = "0.3.3"
+const SEMVER = "0.3.4"
 
 // Storage keys.
 const (
@@ -76,6 +76,14 @@ func checkAndStoreVersions(
 
 	// Upgrade path code goes here
 
+	// Add the password attempt record to storage created before v0.3.4
+	if storedWasmVer != currentWasmVer {
+		if err = initAttemptRecord(ls); err != nil {
+			return errors.WithMessage(err,
+				"failed to initialise password attempt record")
+		}
+	}
+
 	// Save current versions
 	if err = ls.Set(clientVerKey, []byte(currentClientVer)); err != nil {
 		return errors.Wrapf(err, "localStorage: failed to set %q", clientVerKey)
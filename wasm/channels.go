@@ -10,17 +10,24 @@
 package wasm
 
 import (
+	"crypto/ed25519"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"sync"
 	"syscall/js"
+	"time"
+
+	jww "github.com/spf13/jwalterweatherman"
 
 	"gitlab.com/elixxir/client/v4/bindings"
 	"gitlab.com/elixxir/client/v4/channels"
+	"gitlab.com/elixxir/crypto/message"
 	"gitlab.com/elixxir/wasm-utils/exception"
 	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
 	channelsDb "gitlab.com/elixxir/xxdk-wasm/indexedDb/worker/channels"
+	"gitlab.com/xx_network/primitives/id"
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -31,59 +38,89 @@ import (
 // can be wrapped to be Javascript compatible.
 type ChannelsManager struct {
 	api *bindings.ChannelsManager
+
+	// model is the event model of the manager, if it was built in Go.
+	model channels.EventModel
 }
 
 // newChannelsManagerJS creates a new Javascript compatible object
-// (map[string]any) that matches the [ChannelsManager] structure.
-func newChannelsManagerJS(api *bindings.ChannelsManager) map[string]any {
-	cm := ChannelsManager{api}
+// (map[string]any) that matches the [ChannelsManager] structure. The model may
+// be nil if the event model is not built in Go.
+func newChannelsManagerJS(api *bindings.ChannelsManager,
+	model channels.EventModel) map[string]any {
+	cm := ChannelsManager{api, model}
+
+	// Record the admin status and notification level of channels joined
+	// before they were stored in the event model
+	go cm.syncAllChannelMetadata()
+
+	// Pass received edits to event models that can apply them
+	if editor, ok := model.(messageEditor); ok {
+		err := api.RegisterReceiveHandler(impl.EditMessageType,
+			&editReceptionCallback{editor}, "MessageEdit", true, true, false)
+		if err != nil {
+			jww.ERROR.Printf("[CH] Failed to register edit handler: %+v", err)
+		}
+	}
+
 	channelsManagerMap := map[string]any{
 		// Basic Channel API
-		"GetID":                 js.FuncOf(cm.GetID),
-		"GenerateChannel":       js.FuncOf(cm.GenerateChannel),
-		"JoinChannel":           js.FuncOf(cm.JoinChannel),
-		"GetChannels":           js.FuncOf(cm.GetChannels),
-		"LeaveChannel":          js.FuncOf(cm.LeaveChannel),
-		"ReplayChannel":         js.FuncOf(cm.ReplayChannel),
-		"EnableDirectMessages":  js.FuncOf(cm.EnableDirectMessages),
-		"DisableDirectMessages": js.FuncOf(cm.DisableDirectMessages),
-		"AreDMsEnabled":         js.FuncOf(cm.AreDMsEnabled),
+		"GetID":                 sessionFuncOf(cm.GetID),
+		"GenerateChannel":       sessionFuncOf(cm.GenerateChannel),
+		"JoinChannel":           sessionFuncOf(cm.JoinChannel),
+		"GetChannels":           sessionFuncOf(cm.GetChannels),
+		"GetChannelRecords":     sessionFuncOf(cm.GetChannelRecords),
+		"LeaveChannel":          sessionFuncOf(cm.LeaveChannel),
+		"ReplayChannel":         sessionFuncOf(cm.ReplayChannel),
+		"EnableDirectMessages":  sessionFuncOf(cm.EnableDirectMessages),
+		"DisableDirectMessages": sessionFuncOf(cm.DisableDirectMessages),
+		"AreDMsEnabled":         sessionFuncOf(cm.AreDMsEnabled),
 
 		// Share URL
-		"GetShareURL": js.FuncOf(cm.GetShareURL),
+		"GetShareURL": sessionFuncOf(cm.GetShareURL),
 
 		// Channel Sending Methods and Reports
-		"SendGeneric":           js.FuncOf(cm.SendGeneric),
-		"SendAdminGeneric":      js.FuncOf(cm.SendAdminGeneric),
-		"SendMessage":           js.FuncOf(cm.SendMessage),
-		"SendReply":             js.FuncOf(cm.SendReply),
-		"SendReaction":          js.FuncOf(cm.SendReaction),
-		"SendSilent":            js.FuncOf(cm.SendSilent),
-		"SendInvite":            js.FuncOf(cm.SendInvite),
-		"DeleteMessage":         js.FuncOf(cm.DeleteMessage),
-		"PinMessage":            js.FuncOf(cm.PinMessage),
-		"MuteUser":              js.FuncOf(cm.MuteUser),
-		"GetIdentity":           js.FuncOf(cm.GetIdentity),
-		"ExportPrivateIdentity": js.FuncOf(cm.ExportPrivateIdentity),
-		"GetStorageTag":         js.FuncOf(cm.GetStorageTag),
-		"SetNickname":           js.FuncOf(cm.SetNickname),
-		"DeleteNickname":        js.FuncOf(cm.DeleteNickname),
-		"GetNickname":           js.FuncOf(cm.GetNickname),
-		"Muted":                 js.FuncOf(cm.Muted),
-		"GetMutedUsers":         js.FuncOf(cm.GetMutedUsers),
-		"IsChannelAdmin":        js.FuncOf(cm.IsChannelAdmin),
-		"ExportChannelAdminKey": js.FuncOf(cm.ExportChannelAdminKey),
-		"VerifyChannelAdminKey": js.FuncOf(cm.VerifyChannelAdminKey),
-		"ImportChannelAdminKey": js.FuncOf(cm.ImportChannelAdminKey),
-		"DeleteChannelAdminKey": js.FuncOf(cm.DeleteChannelAdminKey),
+		"SendGeneric":           sessionFuncOf(cm.SendGeneric),
+		"SendAdminGeneric":      sessionFuncOf(cm.SendAdminGeneric),
+		"SendMessage":           sessionFuncOf(cm.SendMessage),
+		"SendReply":             sessionFuncOf(cm.SendReply),
+		"SendReaction":          sessionFuncOf(cm.SendReaction),
+		"SendSilent":            sessionFuncOf(cm.SendSilent),
+		"SendInvite":            sessionFuncOf(cm.SendInvite),
+		"EditMessage":           sessionFuncOf(cm.EditMessage),
+		"DeleteMessage":         sessionFuncOf(cm.DeleteMessage),
+		"PinMessage":            sessionFuncOf(cm.PinMessage),
+		"MuteUser":              sessionFuncOf(cm.MuteUser),
+		"GetIdentity":           sessionFuncOf(cm.GetIdentity),
+		"ExportPrivateIdentity": sessionFuncOf(cm.ExportPrivateIdentity),
+		"GetStorageTag":         sessionFuncOf(cm.GetStorageTag),
+		"SetNickname":           sessionFuncOf(cm.SetNickname),
+		"DeleteNickname":        sessionFuncOf(cm.DeleteNickname),
+		"GetNickname":           sessionFuncOf(cm.GetNickname),
+		"Muted":                 sessionFuncOf(cm.Muted),
+		"GetMutedUsers":         sessionFuncOf(cm.GetMutedUsers),
+		"GetMessagesBySender":   sessionFuncOf(cm.GetMessagesBySender),
+		"HideMessagesBySender":  sessionFuncOf(cm.HideMessagesBySender),
+		"IsChannelAdmin":        sessionFuncOf(cm.IsChannelAdmin),
+		"ExportChannelAdminKey": sessionFuncOf(cm.ExportChannelAdminKey),
+		"VerifyChannelAdminKey": sessionFuncOf(cm.VerifyChannelAdminKey),
+		"ImportChannelAdminKey": sessionFuncOf(cm.ImportChannelAdminKey),
+		"DeleteChannelAdminKey": sessionFuncOf(cm.DeleteChannelAdminKey),
+		"ExportChannelHistory":  sessionFuncOf(cm.ExportChannelHistory),
+		"CheckDatabase":         sessionFuncOf(cm.CheckDatabase),
+		"GetStorageUsage":       sessionFuncOf(cm.GetStorageUsage),
+		"SaveDraft":             sessionFuncOf(cm.SaveDraft),
+		"GetDraft":              sessionFuncOf(cm.GetDraft),
+		"ClearDraft":            sessionFuncOf(cm.ClearDraft),
+		"SearchMessages":        sessionFuncOf(cm.SearchMessages),
 
 		// Channel Receiving Logic and Callback Registration
-		"RegisterReceiveHandler": js.FuncOf(cm.RegisterReceiveHandler),
+		"RegisterReceiveHandler": sessionFuncOf(cm.RegisterReceiveHandler),
 
 		// Notifications
-		"GetNotificationLevel":  js.FuncOf(cm.GetNotificationLevel),
-		"GetNotificationStatus": js.FuncOf(cm.GetNotificationStatus),
-		"SetMobileNotificationsLevel": js.FuncOf(
+		"GetNotificationLevel":  sessionFuncOf(cm.GetNotificationLevel),
+		"GetNotificationStatus": sessionFuncOf(cm.GetNotificationStatus),
+		"SetMobileNotificationsLevel": sessionFuncOf(
 			cm.SetMobileNotificationsLevel),
 	}
 
@@ -294,7 +331,7 @@ func NewChannelsManager(_ js.Value, args []js.Value) any {
 		return nil
 	}
 
-	return newChannelsManagerJS(cm)
+	return newChannelsManagerJS(cm, nil)
 }
 
 // LoadChannelsManager loads an existing [ChannelsManager] for the given storage
@@ -341,7 +378,7 @@ func LoadChannelsManager(_ js.Value, args []js.Value) any {
 		return nil
 	}
 
-	return newChannelsManagerJS(cm)
+	return newChannelsManagerJS(cm, nil)
 }
 
 // NewChannelsManagerWithIndexedDb creates a new [ChannelsManager] from a new
@@ -374,6 +411,9 @@ func LoadChannelsManager(_ js.Value, args []js.Value) any {
 //   - args[6] - ID of [DbCipher] object in tracker (int). Create this
 //     object with [NewDatabaseCipher] and get its id with
 //     [DbCipher.GetID].
+//   - args[7] - JSON of [impl.StorageParams] (Uint8Array). It sets the timeout
+//     and retries of the storage operations of the database worker. This
+//     argument is optional; the defaults are used if it is omitted.
 //
 // Returns a promise:
 //   - Resolves to a Javascript representation of the [ChannelsManager] object.
@@ -387,6 +427,7 @@ func NewChannelsManagerWithIndexedDb(_ js.Value, args []js.Value) any {
 	notificationsID := args[4].Int()
 	cUI := newChannelUI(args[5])
 	cipherID := args[6].Int()
+	params := storageParamsArg(args, 7)
 
 	cipher, err := dbCipherTrackerSingleton.get(cipherID)
 	if err != nil {
@@ -394,7 +435,7 @@ func NewChannelsManagerWithIndexedDb(_ js.Value, args []js.Value) any {
 	}
 
 	return newChannelsManagerWithIndexedDb(cmixID, wasmJsPath, privateIdentity,
-		extensionBuilderIDsJSON, notificationsID, cUI, cipher)
+		extensionBuilderIDsJSON, notificationsID, cUI, cipher, params)
 }
 
 // NewChannelsManagerWithIndexedDbUnsafe creates a new [ChannelsManager] from a
@@ -425,6 +466,9 @@ func NewChannelsManagerWithIndexedDb(_ js.Value, args []js.Value) any {
 //     [bindings.ChannelUICallbacks]. It is a callback that informs the UI about
 //     various events. The entire interface can be nil, but if defined, each
 //     method must be implemented.
+//   - args[6] - JSON of [impl.StorageParams] (Uint8Array). It sets the timeout
+//     and retries of the storage operations of the database worker. This
+//     argument is optional; the defaults are used if it is omitted.
 //
 // Returns a promise:
 //   - Resolves to a Javascript representation of the [ChannelsManager] object.
@@ -438,17 +482,22 @@ func NewChannelsManagerWithIndexedDbUnsafe(_ js.Value, args []js.Value) any {
 	extensionBuilderIDsJSON := utils.CopyBytesToGo(args[3])
 	notificationsID := args[4].Int()
 	cUI := newChannelUI(args[5])
+	params := storageParamsArg(args, 6)
 
 	return newChannelsManagerWithIndexedDb(cmixID, wasmJsPath, privateIdentity,
-		extensionBuilderIDsJSON, notificationsID, cUI, nil)
+		extensionBuilderIDsJSON, notificationsID, cUI, nil, params)
 }
 
 func newChannelsManagerWithIndexedDb(cmixID int, wasmJsPath string,
 	privateIdentity, extensionBuilderIDsJSON []byte, notificationsID int,
-	channelsCbs bindings.ChannelUICallbacks, cipher *DbCipher) any {
+	channelsCbs bindings.ChannelUICallbacks, cipher *DbCipher,
+	params impl.StorageParams) any {
 
-	model := channelsDb.NewWASMEventModelBuilder(
-		wasmJsPath, cipher.api, channelsCbs)
+	var eventModel channels.EventModel
+	model := captureModelBuilder(&eventModel,
+		sessionTrackerSingleton.trackModelBuilder(
+			cipher.trackModelBuilder(channelsDb.NewWASMEventModelBuilder(
+				wasmJsPath, cipher.api, params, channelsCbs))))
 
 	promiseFn := func(resolve, reject func(args ...any) js.Value) {
 		cm, err := bindings.NewChannelsManagerGoEventModel(cmixID,
@@ -457,7 +506,7 @@ func newChannelsManagerWithIndexedDb(cmixID int, wasmJsPath string,
 		if err != nil {
 			reject(exception.NewTrace(err))
 		} else {
-			resolve(newChannelsManagerJS(cm))
+			resolve(newChannelsManagerJS(cm, eventModel))
 		}
 	}
 
@@ -491,6 +540,9 @@ func newChannelsManagerWithIndexedDb(cmixID int, wasmJsPath string,
 //   - args[6] - ID of [DbCipher] object in tracker (int). Create this
 //     object with [NewDatabaseCipher] and get its id with
 //     [DbCipher.GetID].
+//   - args[7] - JSON of [impl.StorageParams] (Uint8Array). It sets the timeout
+//     and retries of the storage operations of the database worker. This
+//     argument is optional; the defaults are used if it is omitted.
 //
 // Returns a promise:
 //   - Resolves to a Javascript representation of the [ChannelsManager] object.
@@ -504,6 +556,7 @@ func LoadChannelsManagerWithIndexedDb(_ js.Value, args []js.Value) any {
 	notificationsID := args[4].Int()
 	channelsCbs := newChannelUI(args[5])
 	cipherID := args[6].Int()
+	params := storageParamsArg(args, 7)
 
 	cipher, err := dbCipherTrackerSingleton.get(cipherID)
 	if err != nil {
@@ -511,7 +564,7 @@ func LoadChannelsManagerWithIndexedDb(_ js.Value, args []js.Value) any {
 	}
 
 	return loadChannelsManagerWithIndexedDb(cmixID, wasmJsPath, storageTag,
-		extensionBuilderIDsJSON, notificationsID, channelsCbs, cipher)
+		extensionBuilderIDsJSON, notificationsID, channelsCbs, cipher, params)
 }
 
 // LoadChannelsManagerWithIndexedDbUnsafe loads an existing [ChannelsManager]
@@ -540,6 +593,9 @@ func LoadChannelsManagerWithIndexedDb(_ js.Value, args []js.Value) any {
 //     [bindings.ChannelUICallbacks]. It is a callback that informs the UI about
 //     various events. The entire interface can be nil, but if defined, each
 //     method must be implemented.
+//   - args[6] - JSON of [impl.StorageParams] (Uint8Array). It sets the timeout
+//     and retries of the storage operations of the database worker. This
+//     argument is optional; the defaults are used if it is omitted.
 //
 // Returns a promise:
 //   - Resolves to a Javascript representation of the [ChannelsManager] object.
@@ -551,17 +607,22 @@ func LoadChannelsManagerWithIndexedDbUnsafe(_ js.Value, args []js.Value) any {
 	extensionBuilderIDsJSON := utils.CopyBytesToGo(args[3])
 	notificationsID := args[4].Int()
 	cUI := newChannelUI(args[5])
+	params := storageParamsArg(args, 6)
 
 	return loadChannelsManagerWithIndexedDb(cmixID, wasmJsPath, storageTag,
-		extensionBuilderIDsJSON, notificationsID, cUI, nil)
+		extensionBuilderIDsJSON, notificationsID, cUI, nil, params)
 }
 
 func loadChannelsManagerWithIndexedDb(cmixID int, wasmJsPath, storageTag string,
 	extensionBuilderIDsJSON []byte, notificationsID int,
-	channelsCbs bindings.ChannelUICallbacks, cipher *DbCipher) any {
+	channelsCbs bindings.ChannelUICallbacks, cipher *DbCipher,
+	params impl.StorageParams) any {
 
-	model := channelsDb.NewWASMEventModelBuilder(
-		wasmJsPath, cipher.api, channelsCbs)
+	var eventModel channels.EventModel
+	model := captureModelBuilder(&eventModel,
+		sessionTrackerSingleton.trackModelBuilder(
+			cipher.trackModelBuilder(channelsDb.NewWASMEventModelBuilder(
+				wasmJsPath, cipher.api, params, channelsCbs))))
 
 	promiseFn := func(resolve, reject func(args ...any) js.Value) {
 		cm, err := bindings.LoadChannelsManagerGoEventModel(
@@ -570,7 +631,7 @@ func loadChannelsManagerWithIndexedDb(cmixID int, wasmJsPath, storageTag string,
 		if err != nil {
 			reject(exception.NewTrace(err))
 		} else {
-			resolve(newChannelsManagerJS(cm))
+			resolve(newChannelsManagerJS(cm, eventModel))
 		}
 	}
 
@@ -775,9 +836,25 @@ func (cm *ChannelsManager) JoinChannel(_ js.Value, args []js.Value) any {
 		ci, err := cm.api.JoinChannel(channelPretty)
 		if err != nil {
 			reject(exception.NewTrace(err))
+			return
+		}
+
+		// Record the admin status and notification level of the channel
+		var info bindings.ChannelInfo
+		if err = json.Unmarshal(ci, &info); err != nil {
+			jww.WARN.Printf("Failed to unmarshal %T of joined channel: %+v",
+				info, err)
 		} else {
-			resolve(utils.CopyBytesToJS(ci))
+			channelID, err := base64.StdEncoding.DecodeString(info.ChannelID)
+			if err != nil {
+				jww.WARN.Printf(
+					"Failed to decode ID of joined channel: %+v", err)
+			} else {
+				cm.syncChannelMetadata(channelID)
+			}
 		}
+
+		resolve(utils.CopyBytesToJS(ci))
 	}
 
 	return utils.CreatePromise(promiseFn)
@@ -852,6 +929,116 @@ func (cm *ChannelsManager) GetChannels(js.Value, []js.Value) any {
 	return utils.CopyBytesToJS(channelList)
 }
 
+// GetChannelRecords returns every joined channel stored in the event model
+// along with its privacy level, join time, admin status, last activity, and
+// notification level, sorted by last activity, newest first.
+//
+// Only available for channels managers created with
+// [NewChannelsManagerWithIndexedDb] (or its unsafe variant).
+//
+// Returns a promise:
+//   - Resolves to the JSON of an array of channels (Uint8Array).
+//   - Rejected with an error if the event model does not store channel
+//     metadata or the channels cannot be read.
+//
+// JSON Example:
+//
+//	[
+//	  {
+//	    "id": "U4x/lrFkvxuXu59LtHLon1sUhPJSCcnZND6SugndnVID",
+//	    "name": "xxGeneralChat",
+//	    "description": "Talking about the xx network",
+//	    "privacy_level": 0,
+//	    "joined_at": "2023-07-25T14:53:24.174Z",
+//	    "is_admin": false,
+//	    "last_activity": "2023-08-02T09:12:51.902Z",
+//	    "notification_level": 20
+//	  }
+//	]
+func (cm *ChannelsManager) GetChannelRecords(js.Value, []js.Value) any {
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		store, ok := cm.model.(channelMetadataStore)
+		if !ok {
+			reject(exception.NewTrace(errors.New(
+				"event model does not store channel metadata")))
+			return
+		}
+
+		channelList, err := store.GetChannels()
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve(utils.CopyBytesToJS(channelList))
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// channelMetadataStore is an event model that stores the metadata of each
+// channel (e.g., the indexedDb worker model).
+type channelMetadataStore interface {
+	GetChannels() ([]byte, error)
+	UpdateChannelMetadata(channelID *id.ID, isAdmin *bool,
+		level *channels.NotificationLevel) error
+}
+
+// syncChannelMetadata stores the admin status and notification level of the
+// channel, as known by the channels manager, in the event model. Errors are
+// only logged because the event model only mirrors the manager.
+func (cm *ChannelsManager) syncChannelMetadata(channelIDBytes []byte) {
+	store, ok := cm.model.(channelMetadataStore)
+	if !ok {
+		return
+	}
+
+	channelID, err := id.Unmarshal(channelIDBytes)
+	if err != nil {
+		jww.WARN.Printf("Failed to unmarshal channel ID: %+v", err)
+		return
+	}
+
+	var isAdmin *bool
+	if admin, err := cm.api.IsChannelAdmin(channelIDBytes); err == nil {
+		isAdmin = &admin
+	}
+
+	// Notifications may not be set up for the manager
+	var level *channels.NotificationLevel
+	if l, err := cm.api.GetNotificationLevel(channelIDBytes); err == nil {
+		nl := channels.NotificationLevel(l)
+		level = &nl
+	}
+
+	err = store.UpdateChannelMetadata(channelID, isAdmin, level)
+	if err != nil {
+		jww.WARN.Printf("Failed to update metadata of channel %s: %+v",
+			channelID, err)
+	}
+}
+
+// syncAllChannelMetadata calls syncChannelMetadata for every joined channel.
+func (cm *ChannelsManager) syncAllChannelMetadata() {
+	if _, ok := cm.model.(channelMetadataStore); !ok {
+		return
+	}
+
+	channelListJson, err := cm.api.GetChannels()
+	if err != nil {
+		jww.WARN.Printf("Failed to get channels: %+v", err)
+		return
+	}
+	var channelIDs []*id.ID
+	if err = json.Unmarshal(channelListJson, &channelIDs); err != nil {
+		jww.WARN.Printf("Failed to unmarshal channels: %+v", err)
+		return
+	}
+
+	for _, channelID := range channelIDs {
+		cm.syncChannelMetadata(channelID.Marshal())
+	}
+}
+
 // EnableDirectMessages enables the token for direct messaging for this
 // channel.
 //
@@ -1073,6 +1260,9 @@ func (cm *ChannelsManager) SendGeneric(_ js.Value, args []js.Value) any {
 // The message will auto delete validUntil after the round it is sent in,
 // lasting forever if [channels.ValidForever] is used.
 //
+// Once sent, the draft of the channel is cleared unless it was saved after
+// sending started.
+//
 // Parameters:
 //   - args[0] - Marshalled bytes of the channel [id.ID] (Uint8Array).
 //   - args[1] - The contents of the message (string).
@@ -1105,11 +1295,13 @@ func (cm *ChannelsManager) SendMessage(_ js.Value, args []js.Value) any {
 	pingsJSON := utils.CopyBytesToGo(args[4])
 
 	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		sentAt := time.Now()
 		sendReport, err := cm.api.SendMessage(
 			marshalledChanId, msg, leaseTimeMS, cmixParamsJSON, pingsJSON)
 		if err != nil {
 			reject(exception.NewTrace(err))
 		} else {
+			cm.clearDraft(marshalledChanId, sentAt)
 			resolve(utils.CopyBytesToJS(sendReport))
 		}
 	}
@@ -1126,6 +1318,9 @@ func (cm *ChannelsManager) SendMessage(_ js.Value, args []js.Value) any {
 // If the message ID that the reply is sent to does not exist, then the other
 // side will post the message as a normal message and not as a reply.
 //
+// Once sent, the draft of the channel is cleared unless it was saved after
+// sending started.
+//
 // Parameters:
 //   - args[0] - Marshalled bytes of the channel [id.ID] (Uint8Array).
 //   - args[1] - The contents of the message. The message should be at most 510
@@ -1166,11 +1361,13 @@ func (cm *ChannelsManager) SendReply(_ js.Value, args []js.Value) any {
 	pingsJSON := utils.CopyBytesToGo(args[5])
 
 	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		sentAt := time.Now()
 		sendReport, err := cm.api.SendReply(marshalledChanId, msg,
 			messageToReactTo, leaseTimeMS, cmixParamsJSON, pingsJSON)
 		if err != nil {
 			reject(exception.NewTrace(err))
 		} else {
+			cm.clearDraft(marshalledChanId, sentAt)
 			resolve(utils.CopyBytesToJS(sendReport))
 		}
 	}
@@ -1323,6 +1520,96 @@ func (cm *ChannelsManager) SendInvite(_ js.Value, args []js.Value) any {
 	return utils.CreatePromise(promiseFn)
 }
 
+// EditMessage replaces the text of one of the user's own messages. The edit is
+// sent as a generic message of type [impl.EditMessageType] that references the
+// original message and its author. Event models that support edits keep the
+// edit history and show the latest text; edits from anyone other than the
+// original sender are rejected by every receiver.
+//
+// Only the user's own messages in the event model can be edited. Edits of
+// messages not in the event model (e.g., for channels managers without an
+// event model) are checked by receivers only.
+//
+// Parameters:
+//   - args[0] - Marshalled bytes of the channel [id.ID] (Uint8Array).
+//   - args[1] - The marshalled [channel.MessageID] of the message to edit
+//     (Uint8Array).
+//   - args[2] - The new text of the message (string).
+//   - args[3] - The lease of the edit. This will be how long the message is
+//     available from the network, in milliseconds (int). Use [ValidForever]
+//     to last the max message life.
+//   - args[4] - JSON of [xxdk.CMIXParams]. If left empty
+//     [bindings.GetDefaultCMixParams] will be used internally (Uint8Array).
+//
+// Returns a promise:
+//   - Resolves to the JSON of [bindings.ChannelSendReport] (Uint8Array).
+//   - Rejected with an error if the message is not the user's own or sending
+//     fails.
+func (cm *ChannelsManager) EditMessage(_ js.Value, args []js.Value) any {
+	var (
+		marshalledChanId = utils.CopyBytesToGo(args[0])
+		targetMessageId  = utils.CopyBytesToGo(args[1])
+		text             = args[2].String()
+		leaseTimeMS      = int64(args[3].Int())
+		cmixParamsJSON   = utils.CopyBytesToGo(args[4])
+	)
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		payload, err := cm.newMessageEdit(targetMessageId, text)
+		if err != nil {
+			reject(exception.NewTrace(err))
+			return
+		}
+
+		// Edits are actions, so they are not tracked
+		sendReport, err := cm.api.SendGeneric(marshalledChanId,
+			impl.EditMessageType, payload, leaseTimeMS, false, cmixParamsJSON,
+			nil)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve(utils.CopyBytesToJS(sendReport))
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// newMessageEdit returns the JSON of the [impl.MessageEdit] of the message
+// authored by the user of the channels manager. Returns an error if the
+// message is in the event model and was sent by someone else.
+func (cm *ChannelsManager) newMessageEdit(
+	targetMessageId []byte, text string) ([]byte, error) {
+	messageID, err := message.UnmarshalID(targetMessageId)
+	if err != nil {
+		return nil, err
+	}
+
+	identityJson, err := cm.api.GetIdentity()
+	if err != nil {
+		return nil, err
+	}
+	var identity struct{ PubKey ed25519.PublicKey }
+	if err = json.Unmarshal(identityJson, &identity); err != nil {
+		return nil, err
+	}
+
+	if cm.model != nil {
+		msg, err := cm.model.GetMessage(messageID)
+		if err != nil {
+			return nil, err
+		} else if !identity.PubKey.Equal(msg.PubKey) {
+			return nil, errors.New("only the sender of a message may edit it")
+		}
+	}
+
+	return json.Marshal(impl.MessageEdit{
+		MessageID: messageID,
+		AuthorKey: identity.PubKey,
+		Text:      text,
+	})
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // Admin Sending                                                              //
 ////////////////////////////////////////////////////////////////////////////////
@@ -1675,6 +1962,202 @@ func (cm *ChannelsManager) GetMutedUsers(_ js.Value, args []js.Value) any {
 	return utils.CopyBytesToJS(mutedUsers)
 }
 
+// senderModerator is an event model that can look up and hide the messages of
+// a sender (e.g., the indexedDb worker model).
+type senderModerator interface {
+	GetMessagesBySender(channelID *id.ID,
+		pubKey ed25519.PublicKey) ([]channels.ModelMessage, error)
+	HideMessagesBySender(channelID *id.ID,
+		pubKey ed25519.PublicKey, hide bool) (int, error)
+}
+
+// GetMessagesBySender returns every message sent by the user in the channel,
+// oldest first, from the event model.
+//
+// Only available for channels managers created with
+// [NewChannelsManagerWithIndexedDb] (or its unsafe variant).
+//
+// Parameters:
+//   - args[0] - The marshalled bytes of the channel's [id.ID] (Uint8Array).
+//   - args[1] - The [ed25519.PublicKey] of the sender (Uint8Array).
+//
+// Returns a promise:
+//   - Resolves to the JSON of an array of [channels.ModelMessage]
+//     (Uint8Array).
+//   - Rejected with an error if the event model does not support it or the
+//     messages cannot be read.
+func (cm *ChannelsManager) GetMessagesBySender(_ js.Value, args []js.Value) any {
+	channelIDBytes := utils.CopyBytesToGo(args[0])
+	pubKey := ed25519.PublicKey(utils.CopyBytesToGo(args[1]))
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		moderator, ok := cm.model.(senderModerator)
+		if !ok {
+			reject(exception.NewTrace(errors.New(
+				"event model does not support looking up messages by sender")))
+			return
+		}
+		channelID, err := id.Unmarshal(channelIDBytes)
+		if err != nil {
+			reject(exception.NewTrace(err))
+			return
+		}
+
+		messages, err := moderator.GetMessagesBySender(channelID, pubKey)
+		if err != nil {
+			reject(exception.NewTrace(err))
+			return
+		}
+		messagesJson, err := json.Marshal(messages)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve(utils.CopyBytesToJS(messagesJson))
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// HideMessagesBySender hides every message sent by the user in the channel or,
+// if hide is false, shows the messages it previously hid. Messages hidden for
+// other reasons stay hidden. Each changed message is reported on the event
+// model MessageReceived callback as an update.
+//
+// This is done automatically when a user is muted or unmuted with
+// [ChannelsManager.MuteUser].
+//
+// Only available for channels managers created with
+// [NewChannelsManagerWithIndexedDb] (or its unsafe variant).
+//
+// Parameters:
+//   - args[0] - The marshalled bytes of the channel's [id.ID] (Uint8Array).
+//   - args[1] - The [ed25519.PublicKey] of the sender (Uint8Array).
+//   - args[2] - Set to true to hide the messages and false to show them
+//     (boolean).
+//
+// Returns a promise:
+//   - Resolves to the number of messages changed (int).
+//   - Rejected with an error if the event model does not support it or the
+//     messages cannot be updated.
+func (cm *ChannelsManager) HideMessagesBySender(_ js.Value, args []js.Value) any {
+	channelIDBytes := utils.CopyBytesToGo(args[0])
+	pubKey := ed25519.PublicKey(utils.CopyBytesToGo(args[1]))
+	hide := args[2].Bool()
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		moderator, ok := cm.model.(senderModerator)
+		if !ok {
+			reject(exception.NewTrace(errors.New(
+				"event model does not support hiding messages by sender")))
+			return
+		}
+		channelID, err := id.Unmarshal(channelIDBytes)
+		if err != nil {
+			reject(exception.NewTrace(err))
+			return
+		}
+
+		count, err := moderator.HideMessagesBySender(channelID, pubKey, hide)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve(count)
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// ExportChannelHistory renders the history of the channel, with nicknames,
+// timestamps, replies, reactions, and file references, to JSON, Markdown, or a
+// standalone HTML page. The text is decrypted from the event model database.
+//
+// Only available for managers created with [NewChannelsManagerWithIndexedDb]
+// or [LoadChannelsManagerWithIndexedDb] (or their unsafe variants).
+//
+// Parameters:
+//   - args[0] - Marshalled bytes of the channel's [id.ID] (Uint8Array).
+//   - args[1] - The format: "json", "markdown", or "html" (string).
+//   - args[2] - Only messages sent at or after this time are included, in
+//     Unix milliseconds. Set to 0 to include messages from the beginning of
+//     the channel (int).
+//   - args[3] - Only messages sent before this time are included, in Unix
+//     milliseconds. Set to 0 to include messages up to now (int).
+//
+// Returns a promise:
+//   - Resolves to the rendered history (Uint8Array).
+//   - Rejected with an error if the channel ID is invalid, the format is
+//     unknown, the event model does not support exporting, or the export
+//     fails.
+func (cm *ChannelsManager) ExportChannelHistory(
+	_ js.Value, args []js.Value) any {
+	channelIDBytes := utils.CopyBytesToGo(args[0])
+	format := args[1].String()
+	start, end := historyRange(int64(args[2].Int()), int64(args[3].Int()))
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		exporter, ok := cm.model.(channelHistoryExporter)
+		if !ok {
+			reject(exception.NewTrace(errors.New(
+				"event model does not support exporting history")))
+			return
+		}
+
+		channelID, err := id.Unmarshal(channelIDBytes)
+		if err != nil {
+			reject(exception.NewTrace(err))
+			return
+		}
+
+		history, err := exporter.ExportHistory(channelID, start, end, format)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve(utils.CopyBytesToJS(history))
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// CheckDatabase validates every value in the event model database of the
+// channels manager. Values that fail to unmarshal or decrypt, are missing required
+// fields, refer to a channel that does not exist, or duplicate the message ID
+// of another message are reported.
+//
+// Only available for channels managers created with
+// [NewChannelsManagerWithIndexedDb] (or its unsafe variant).
+//
+// Parameters:
+//   - args[0] - Set to true to move bad values to a quarantine store in the
+//     database. Set to false to only report them (boolean).
+//
+// Returns a promise:
+//   - Resolves to the JSON of the [impl.IntegrityReport] (Uint8Array).
+//   - Rejected with an error if the event model does not support checking or
+//     the database cannot be read.
+func (cm *ChannelsManager) CheckDatabase(_ js.Value, args []js.Value) any {
+	return checkDatabase(cm.model, args[0].Bool())
+}
+
+// GetStorageUsage returns the number of rows and approximate size of each
+// object store in the event model database and of the messages of each
+// channel, along with the storage usage, quota, and persistence of the origin.
+// Use it to show the user how much space is used and to warn them before
+// writes start failing.
+//
+// Only available for channels managers created with
+// [NewChannelsManagerWithIndexedDb] (or its unsafe variant).
+//
+// Returns a promise:
+//   - Resolves to the JSON of the [StorageUsage] (Uint8Array).
+//   - Rejected with an error if the event model does not support measuring
+//     storage or the database cannot be read.
+func (cm *ChannelsManager) GetStorageUsage(js.Value, []js.Value) any {
+	return getStorageUsage(cm.model)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // Notifications                                                              //
 ////////////////////////////////////////////////////////////////////////////////
@@ -1752,6 +2235,7 @@ func (cm *ChannelsManager) SetMobileNotificationsLevel(_ js.Value,
 		if err != nil {
 			reject(exception.NewTrace(err))
 		} else {
+			cm.syncChannelMetadata(channelIDBytes)
 			resolve()
 		}
 	}
@@ -1962,6 +2446,7 @@ func (cm *ChannelsManager) ImportChannelAdminKey(_ js.Value, args []js.Value) an
 		exception.ThrowTrace(err)
 		return nil
 	}
+	go cm.syncChannelMetadata(channelID)
 
 	return nil
 }
@@ -1978,11 +2463,13 @@ func (cm *ChannelsManager) ImportChannelAdminKey(_ js.Value, args []js.Value) an
 // Returns:
 //   - Throws an error if the deletion fails.
 func (cm *ChannelsManager) DeleteChannelAdminKey(_ js.Value, args []js.Value) any {
-	err := cm.api.DeleteChannelAdminKey(utils.CopyBytesToGo(args[0]))
+	channelID := utils.CopyBytesToGo(args[0])
+	err := cm.api.DeleteChannelAdminKey(channelID)
 	if err != nil {
 		exception.ThrowTrace(err)
 		return nil
 	}
+	go cm.syncChannelMetadata(channelID)
 
 	return nil
 }
@@ -2058,6 +2545,328 @@ func (cm *ChannelsManager) RegisterReceiveHandler(_ js.Value, args []js.Value) a
 	return nil
 }
 
+////////////////////////////////////////////////////////////////////////////////
+// Drafts                                                                     //
+////////////////////////////////////////////////////////////////////////////////
+
+// draftStore is an event model that stores the draft of each channel (e.g.,
+// the indexedDb worker model).
+type draftStore interface {
+	SaveDraft(channelID *id.ID, text string, replyTo *message.ID) error
+	GetDraft(channelID *id.ID) ([]byte, error)
+	ClearDraft(channelID *id.ID, savedBefore time.Time) error
+}
+
+// SaveDraft stores the unsent text of the channel in the event model,
+// encrypted like message text, replacing any existing draft. Saving empty text
+// that is not a reply deletes the draft.
+//
+// The draft is cleared automatically once a message or reply is sent to the
+// channel with [ChannelsManager.SendMessage] or [ChannelsManager.SendReply].
+//
+// Only available for channels managers created with
+// [NewChannelsManagerWithIndexedDb] (or its unsafe variant).
+//
+// Parameters:
+//   - args[0] - Marshalled bytes of the channel [id.ID] (Uint8Array).
+//   - args[1] - The unsent text (string).
+//   - args[2] - The marshalled [channel.MessageID] of the message being
+//     replied to or null if the draft is not a reply (Uint8Array).
+//
+// Returns a promise:
+//   - Resolves on success.
+//   - Rejected with an error if the event model does not support drafts or
+//     the draft cannot be saved.
+func (cm *ChannelsManager) SaveDraft(_ js.Value, args []js.Value) any {
+	channelIDBytes := utils.CopyBytesToGo(args[0])
+	text := args[1].String()
+	var replyToBytes []byte
+	if !args[2].IsNull() && !args[2].IsUndefined() {
+		replyToBytes = utils.CopyBytesToGo(args[2])
+	}
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		store, ok := cm.model.(draftStore)
+		if !ok {
+			reject(exception.NewTrace(
+				errors.New("event model does not support drafts")))
+			return
+		}
+		channelID, err := id.Unmarshal(channelIDBytes)
+		if err != nil {
+			reject(exception.NewTrace(err))
+			return
+		}
+		var replyTo *message.ID
+		if len(replyToBytes) > 0 {
+			messageID, err := message.UnmarshalID(replyToBytes)
+			if err != nil {
+				reject(exception.NewTrace(err))
+				return
+			}
+			replyTo = &messageID
+		}
+
+		if err = store.SaveDraft(channelID, text, replyTo); err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve()
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// GetDraft returns the draft of the channel from the event model.
+//
+// Only available for channels managers created with
+// [NewChannelsManagerWithIndexedDb] (or its unsafe variant).
+//
+// Parameters:
+//   - args[0] - Marshalled bytes of the channel [id.ID] (Uint8Array).
+//
+// Returns a promise:
+//   - Resolves to the JSON of the draft (Uint8Array) or null if the channel
+//     has no draft.
+//   - Rejected with an error if the event model does not support drafts or
+//     the draft cannot be read.
+//
+// Example JSON:
+//
+//	{
+//	  "id": "ZUVtBkZ8RYPXdYbL8Df7ZyJPrTDdyCZLhPfrBW8vvhcD",
+//	  "text": "Hello, wor",
+//	  "reply_to": "Tg6VB8UVL+jn3GlWIvCXASsNHinxbqXTr/FF6z6q9lU=",
+//	  "timestamp": "2023-07-21T11:42:09.512-07:00"
+//	}
+func (cm *ChannelsManager) GetDraft(_ js.Value, args []js.Value) any {
+	channelIDBytes := utils.CopyBytesToGo(args[0])
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		store, ok := cm.model.(draftStore)
+		if !ok {
+			reject(exception.NewTrace(
+				errors.New("event model does not support drafts")))
+			return
+		}
+		channelID, err := id.Unmarshal(channelIDBytes)
+		if err != nil {
+			reject(exception.NewTrace(err))
+			return
+		}
+
+		draft, err := store.GetDraft(channelID)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else if len(draft) == 0 {
+			resolve(js.Null())
+		} else {
+			resolve(utils.CopyBytesToJS(draft))
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// ClearDraft deletes the draft of the channel from the event model.
+//
+// Only available for channels managers created with
+// [NewChannelsManagerWithIndexedDb] (or its unsafe variant).
+//
+// Parameters:
+//   - args[0] - Marshalled bytes of the channel [id.ID] (Uint8Array).
+//
+// Returns a promise:
+//   - Resolves on success.
+//   - Rejected with an error if the event model does not support drafts or
+//     the draft cannot be deleted.
+func (cm *ChannelsManager) ClearDraft(_ js.Value, args []js.Value) any {
+	channelIDBytes := utils.CopyBytesToGo(args[0])
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		store, ok := cm.model.(draftStore)
+		if !ok {
+			reject(exception.NewTrace(
+				errors.New("event model does not support drafts")))
+			return
+		}
+		channelID, err := id.Unmarshal(channelIDBytes)
+		if err != nil {
+			reject(exception.NewTrace(err))
+			return
+		}
+
+		if err = store.ClearDraft(channelID, time.Now()); err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve()
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// clearDraft deletes the draft of the channel if the event model stores drafts
+// and the draft was saved before the message sent at sentAt. Errors are logged
+// because the message has already been sent.
+func (cm *ChannelsManager) clearDraft(channelIDBytes []byte, sentAt time.Time) {
+	store, ok := cm.model.(draftStore)
+	if !ok {
+		return
+	}
+	channelID, err := id.Unmarshal(channelIDBytes)
+	if err == nil {
+		err = store.ClearDraft(channelID, sentAt)
+	}
+	if err != nil {
+		jww.ERROR.Printf("[CH] Failed to clear draft after send: %+v", err)
+	}
+}
+
+// messageEditor is an event model that can apply edit messages (e.g., the
+// indexedDb worker model).
+type messageEditor interface {
+	ReceiveEdit(channelID *id.ID, editID message.ID, pubKey ed25519.PublicKey,
+		content []byte, timestamp time.Time) (uint64, error)
+}
+
+// editReceptionCallback passes edit messages to the event model. It adheres to
+// the [bindings.ChannelMessageReceptionCallback] interface.
+type editReceptionCallback struct {
+	editor messageEditor
+}
+
+// Callback applies the edit in the JSON of the
+// [bindings.ReceivedChannelMessageReport] to the message it references. It
+// always returns zero because edits are not stored as messages of their own.
+func (erCB *editReceptionCallback) Callback(
+	receivedChannelMessageReport []byte, err error) int {
+	if err != nil {
+		jww.ERROR.Printf("[CH] Failed to receive edit: %+v", err)
+		return 0
+	}
+
+	var report bindings.ReceivedChannelMessageReport
+	err = json.Unmarshal(receivedChannelMessageReport, &report)
+	if err != nil {
+		jww.ERROR.Printf("[CH] Failed to unmarshal edit: %+v", err)
+		return 0
+	}
+	channelID, err := id.Unmarshal(report.ChannelId)
+	if err != nil {
+		jww.ERROR.Printf("[CH] Failed to unmarshal edit channel: %+v", err)
+		return 0
+	}
+	editID, err := message.UnmarshalID(report.MessageId)
+	if err != nil {
+		jww.ERROR.Printf("[CH] Failed to unmarshal edit ID: %+v", err)
+		return 0
+	}
+
+	_, err = erCB.editor.ReceiveEdit(channelID, editID, report.PubKey,
+		report.Content, time.Unix(0, report.Timestamp))
+	if err != nil {
+		jww.WARN.Printf("[CH] Rejected edit %s in channel %s: %+v",
+			editID, channelID, err)
+	}
+	return 0
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Search                                                                     //
+////////////////////////////////////////////////////////////////////////////////
+
+// messageSearcher is an event model that can search the text of messages
+// (e.g., the indexedDb worker model).
+type messageSearcher interface {
+	SearchMessages(query string, channelID *id.ID, limit int) ([]byte, error)
+}
+
+// SearchMessages searches the text of the messages in the event model. A
+// message matches if, for every word of the query, its text contains a word
+// starting with it, ignoring case. Words shorter than two characters are
+// ignored. Hidden messages are never returned.
+//
+// When the database is encrypted, the search index only holds keyed blind
+// tokens of the words, so message text stays private at rest.
+//
+// Only available for channels managers created with
+// [NewChannelsManagerWithIndexedDb] (or its unsafe variant).
+//
+// Parameters:
+//   - args[0] - The search query (string).
+//   - args[1] - Marshalled bytes of the [id.ID] of the channel to search or
+//     null to search all channels (Uint8Array).
+//   - args[2] - The maximum number of results or 0 for no limit (int).
+//
+// Returns a promise:
+//   - Resolves to the JSON of an array of results, newest first (Uint8Array).
+//     Each is a [channels.ModelMessage] with its decrypted text in "content"
+//     and the ranges of the matched words in "highlights". The ranges are
+//     offsets in UTF-16 code units of the text, so they can be used with
+//     String.prototype.slice; "end" is exclusive.
+//   - Rejected with an error if the event model does not support search, the
+//     query has no words, or the messages cannot be read.
+//
+// Example JSON:
+//
+//	[
+//	  {
+//	    "uuid": 42,
+//	    "nickname": "alice",
+//	    "messageID": "Tg6VB8UVL+jn3GlWIvCXASsNHinxbqXTr/FF6z6q9lU=",
+//	    "channelID": "ZUVtBkZ8RYPXdYbL8Df7ZyJPrTDdyCZLhPfrBW8vvhcD",
+//	    "parentMessageID": null,
+//	    "timestamp": "2023-07-21T11:42:09.512-07:00",
+//	    "lease": 0,
+//	    "status": 2,
+//	    "hidden": false,
+//	    "pinned": false,
+//	    "content": "SGVsbG8sIHdvcmxkIQ==",
+//	    "type": 1,
+//	    "round": 1024,
+//	    "pubKey": "z8VYHxDJG6b+bJQ3u1Ok0RGprg+z0a9U2aU6T8ZqZPA=",
+//	    "codesetVersion": 0,
+//	    "dmToken": 0,
+//	    "highlights": [{"start": 7, "end": 10}]
+//	  }
+//	]
+func (cm *ChannelsManager) SearchMessages(_ js.Value, args []js.Value) any {
+	query := args[0].String()
+	var channelIDBytes []byte
+	if !args[1].IsNull() && !args[1].IsUndefined() {
+		channelIDBytes = utils.CopyBytesToGo(args[1])
+	}
+	limit := args[2].Int()
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		searcher, ok := cm.model.(messageSearcher)
+		if !ok {
+			reject(exception.NewTrace(
+				errors.New("event model does not support search")))
+			return
+		}
+		var channelID *id.ID
+		if len(channelIDBytes) > 0 {
+			var err error
+			channelID, err = id.Unmarshal(channelIDBytes)
+			if err != nil {
+				reject(exception.NewTrace(err))
+				return
+			}
+		}
+
+		results, err := searcher.SearchMessages(query, channelID, limit)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve(utils.CopyBytesToJS(results))
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // Event Model Logic                                                          //
 ////////////////////////////////////////////////////////////////////////////////
@@ -24,7 +24,7 @@ import (
 func Test_newChannelsManagerJS(t *testing.T) {
 	cmType := reflect.TypeOf(&ChannelsManager{})
 
-	cm := newChannelsManagerJS(&bindings.ChannelsManager{})
+	cm := newChannelsManagerJS(&bindings.ChannelsManager{}, nil)
 	if len(cm) != cmType.NumMethod() {
 		t.Errorf("ChannelsManager JS object does not have all methods."+
 			"\nexpected: %d\nreceived: %d", cmType.NumMethod(), len(cm))
@@ -45,10 +45,67 @@ func Test_ChannelsManagerMethods(t *testing.T) {
 	cmType := reflect.TypeOf(&ChannelsManager{})
 	binCmType := reflect.TypeOf(&bindings.ChannelsManager{})
 
-	if binCmType.NumMethod() != cmType.NumMethod() {
+	var numOfExcludedFields int
+	if _, exists := cmType.MethodByName("ExportChannelHistory"); !exists {
+		t.Errorf("ExportChannelHistory was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := cmType.MethodByName("CheckDatabase"); !exists {
+		t.Errorf("CheckDatabase was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := cmType.MethodByName("GetStorageUsage"); !exists {
+		t.Errorf("GetStorageUsage was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := cmType.MethodByName("GetChannelRecords"); !exists {
+		t.Errorf("GetChannelRecords was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := cmType.MethodByName("GetMessagesBySender"); !exists {
+		t.Errorf("GetMessagesBySender was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := cmType.MethodByName("HideMessagesBySender"); !exists {
+		t.Errorf("HideMessagesBySender was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := cmType.MethodByName("EditMessage"); !exists {
+		t.Errorf("EditMessage was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := cmType.MethodByName("SaveDraft"); !exists {
+		t.Errorf("SaveDraft was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := cmType.MethodByName("GetDraft"); !exists {
+		t.Errorf("GetDraft was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := cmType.MethodByName("ClearDraft"); !exists {
+		t.Errorf("ClearDraft was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := cmType.MethodByName("SearchMessages"); !exists {
+		t.Errorf("SearchMessages was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+
+	nm := cmType.NumMethod() - numOfExcludedFields
+	if binCmType.NumMethod() != nm {
 		t.Errorf("WASM ChannelsManager object does not have all methods from "+
-			"bindings.\nexpected: %d\nreceived: %d",
-			binCmType.NumMethod(), cmType.NumMethod())
+			"bindings.\nexpected: %d\nreceived: %d", binCmType.NumMethod(), nm)
 	}
 
 	for i := 0; i < binCmType.NumMethod(); i++ {
@@ -10,16 +10,52 @@
 package wasm
 
 import (
+	"bytes"
+	"encoding/json"
 	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
 	"gitlab.com/elixxir/client/v4/bindings"
+	"gitlab.com/elixxir/client/v4/channels"
+	"gitlab.com/elixxir/client/v4/collective/versioned"
 	"gitlab.com/elixxir/client/v4/storage/utility"
 	"gitlab.com/elixxir/crypto/indexedDb"
+	"gitlab.com/elixxir/ekv"
 	"gitlab.com/elixxir/wasm-utils/exception"
 	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+	"gitlab.com/elixxir/xxdk-wasm/storage"
+	"gitlab.com/xx_network/crypto/csprng"
+	"gitlab.com/xx_network/primitives/netTime"
+	"io"
 	"sync"
 	"syscall/js"
 )
 
+// Storage values for the salts of the rotated database keys.
+const (
+	keyRingPrefix  = "dbCipherKeyRing"
+	keyRingKey     = "keyRingSalts"
+	keyRingVersion = 0
+
+	// keyRingSaltSize is the size, in bytes, of the salt generated for each
+	// rotated key.
+	keyRingSaltSize = 32
+)
+
+// keyRingSalts is stored in the KV and contains the salt used to derive each
+// key version created by [DbCipher.RotateKey]. The salt for key version 0 is
+// stored separately by [utility.NewOrLoadSalt].
+type keyRingSalts struct {
+	Current uint32            `json:"current"`
+	Salts   map[uint32][]byte `json:"salts"`
+}
+
+// keyRotator is an event model whose database can be re-encrypted with a new
+// key.
+type keyRotator interface {
+	RotateKey(encryption indexedDb.Cipher) error
+}
+
 // dbCipherTrackerSingleton is used to track DbCipher objects
 // so that they can be referenced by ID back over the bindings.
 var dbCipherTrackerSingleton = &DbCipherTracker{
@@ -75,23 +111,46 @@ func (ct *DbCipherTracker) delete(id int) {
 	delete(ct.tracked, id)
 }
 
+// terminateAll terminates every tracked DbCipher and removes it from the
+// DbCipherTracker.
+func (ct *DbCipherTracker) terminateAll() {
+	ct.mux.Lock()
+	defer ct.mux.Unlock()
+
+	for id, c := range ct.tracked {
+		c.terminate()
+		delete(ct.tracked, id)
+	}
+}
+
 // DbCipher wraps the [indexedDb.Cipher] object so its methods
 // can be wrapped to be Javascript compatible.
 type DbCipher struct {
 	api  indexedDb.Cipher
 	salt []byte
 	id   int
+
+	// Values used to derive and store new keys on rotation
+	kv        versioned.KV
+	rng       io.Reader
+	blockSize int
+
+	// Event models using this cipher that are re-encrypted on rotation
+	models map[keyRotator]struct{}
+	mux    sync.Mutex
 }
 
 // newDbCipherJS creates a new Javascript compatible object
 // (map[string]any) that matches the [DbCipher] structure.
 func newDbCipherJS(c *DbCipher) map[string]any {
 	DbCipherMap := map[string]any{
-		"GetID":         js.FuncOf(c.GetID),
-		"Encrypt":       js.FuncOf(c.Encrypt),
-		"Decrypt":       js.FuncOf(c.Decrypt),
-		"MarshalJSON":   js.FuncOf(c.MarshalJSON),
-		"UnmarshalJSON": js.FuncOf(c.UnmarshalJSON),
+		"GetID":         sessionFuncOf(c.GetID),
+		"Encrypt":       sessionFuncOf(c.Encrypt),
+		"Decrypt":       sessionFuncOf(c.Decrypt),
+		"MarshalJSON":   sessionFuncOf(c.MarshalJSON),
+		"UnmarshalJSON": sessionFuncOf(c.UnmarshalJSON),
+		"RotateKey":     sessionFuncOf(c.RotateKey),
+		"GetKeyVersion": sessionFuncOf(c.GetKeyVersion),
 	}
 
 	return DbCipherMap
@@ -114,6 +173,12 @@ func NewDatabaseCipher(_ js.Value, args []js.Value) any {
 	cmixId := args[0].Int()
 	password := utils.CopyBytesToGo(args[1])
 	plaintTextBlockSize := args[2].Int()
+	defer zeroBytes(password)
+
+	if storage.IsSessionLocked() {
+		exception.ThrowTrace(errSessionLocked)
+		return nil
+	}
 
 	// Get user from singleton
 	user, err := bindings.GetCMixInstance(cmixId)
@@ -126,8 +191,8 @@ func NewDatabaseCipher(_ js.Value, args []js.Value) any {
 	stream := user.GetRng().GetStream()
 
 	// Load or generate a salt
-	salt, err := utility.NewOrLoadSalt(
-		user.GetStorage().GetKV(), stream)
+	kv := user.GetStorage().GetKV()
+	salt, err := utility.NewOrLoadSalt(kv, stream)
 	if err != nil {
 		exception.ThrowTrace(err)
 		return nil
@@ -141,8 +206,175 @@ func NewDatabaseCipher(_ js.Value, args []js.Value) any {
 		return nil
 	}
 
+	// Load the keys created by previous key rotations
+	kr, err := loadKeyRing(kv, c, password, plaintTextBlockSize, stream)
+	if err != nil {
+		exception.ThrowTrace(err)
+		return nil
+	}
+
 	// Add to singleton and return
-	return newDbCipherJS(dbCipherTrackerSingleton.create(c))
+	dbCipher := dbCipherTrackerSingleton.create(kr)
+	dbCipher.salt = salt
+	dbCipher.kv = kv
+	dbCipher.rng = stream
+	dbCipher.blockSize = plaintTextBlockSize
+	return newDbCipherJS(dbCipher)
+}
+
+// loadKeyRing returns an [impl.KeyRing] containing the cipher for key version
+// 0 and a cipher for each key version created by [DbCipher.RotateKey].
+func loadKeyRing(kv versioned.KV, c indexedDb.Cipher, password []byte,
+	blockSize int, csprng io.Reader) (*impl.KeyRing, error) {
+	salts, err := loadKeyRingSalts(kv)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphers := map[uint32]indexedDb.Cipher{0: c}
+	for version, salt := range salts.Salts {
+		ciphers[version], err =
+			indexedDb.NewCipher(password, salt, blockSize, csprng)
+		if err != nil {
+			return nil, errors.Wrapf(
+				err, "failed to create cipher for key version %d", version)
+		}
+	}
+
+	return impl.NewKeyRing(salts.Current, ciphers)
+}
+
+// loadKeyRingSalts loads the salts of the rotated keys from storage. If no key
+// has been rotated, an empty keyRingSalts is returned.
+func loadKeyRingSalts(kv versioned.KV) (*keyRingSalts, error) {
+	kv, err := kv.Prefix(keyRingPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	salts := &keyRingSalts{Salts: make(map[uint32][]byte)}
+	obj, err := kv.Get(keyRingKey, keyRingVersion)
+	if err != nil {
+		if !ekv.Exists(err) {
+			return salts, nil
+		}
+		return nil, errors.Wrap(err, "failed to load key ring salts")
+	}
+
+	if err = json.Unmarshal(obj.Data, salts); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal key ring salts")
+	}
+	return salts, nil
+}
+
+// saveKeyRingSalts saves the salts of the rotated keys to storage.
+func saveKeyRingSalts(kv versioned.KV, salts *keyRingSalts) error {
+	kv, err := kv.Prefix(keyRingPrefix)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(salts)
+	if err != nil {
+		return err
+	}
+
+	return kv.Set(keyRingKey, &versioned.Object{
+		Version:   keyRingVersion,
+		Timestamp: netTime.Now(),
+		Data:      data,
+	})
+}
+
+// trackModelBuilder wraps the [channels.EventModelBuilder] so that every event
+// model it builds is re-encrypted when the key is rotated.
+func (c *DbCipher) trackModelBuilder(
+	builder channels.EventModelBuilder) channels.EventModelBuilder {
+	if c == nil {
+		return builder
+	}
+	return func(path string) (channels.EventModel, error) {
+		model, err := builder(path)
+		if err == nil {
+			c.addModel(model)
+		}
+		return model, err
+	}
+}
+
+// addModel tracks an event model so that its database is re-encrypted when the
+// key is rotated. Models that do not support key rotation are ignored.
+func (c *DbCipher) addModel(model any) {
+	rotator, ok := model.(keyRotator)
+	if c == nil || !ok {
+		return
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.models == nil {
+		c.models = make(map[keyRotator]struct{})
+	}
+	c.models[rotator] = struct{}{}
+}
+
+// terminate drops every reference to the keys of the underlying cipher,
+// overwrites the salt with zeros, and replaces the cipher so that all further
+// operations fail.
+//
+// The keys themselves are not wiped. They are held unexported by the
+// [indexedDb.Cipher] of each key version, which provides no way to wipe them,
+// so closing the key ring shared with the event models only drops its
+// references and the keys remain in memory until they are garbage collected.
+// The copies of the keys sent to the event model workers are released when the
+// workers are terminated.
+func (c *DbCipher) terminate() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if kr, ok := c.api.(*impl.KeyRing); ok {
+		kr.Close()
+	}
+
+	zeroBytes(c.salt)
+	c.api = terminatedCipher{}
+	c.models = nil
+}
+
+// zeroBytes overwrites the bytes with zeros.
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// getAPI returns the underlying cipher. The cipher is read under the lock since
+// it is replaced when the DbCipher is terminated.
+func (c *DbCipher) getAPI() indexedDb.Cipher {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.api
+}
+
+// terminatedCipher adheres to the [indexedDb.Cipher] interface. It replaces
+// the cipher of a terminated DbCipher and returns an error on every
+// operation.
+type terminatedCipher struct{}
+
+// errCipherTerminated is returned by all terminatedCipher methods.
+var errCipherTerminated = errors.New("DbCipher has been terminated")
+
+func (terminatedCipher) Encrypt([]byte) (string, error) {
+	return "", errCipherTerminated
+}
+func (terminatedCipher) Decrypt(string) ([]byte, error) {
+	return nil, errCipherTerminated
+}
+func (terminatedCipher) MarshalJSON() ([]byte, error) {
+	return nil, errCipherTerminated
+}
+func (terminatedCipher) UnmarshalJSON([]byte) error {
+	return errCipherTerminated
 }
 
 // GetID returns the ID for this [DbCipher] in the
@@ -166,7 +398,7 @@ func (c *DbCipher) GetID(js.Value, []js.Value) any {
 //   - The ciphertext of the plaintext passed in (String).
 //   - Throws an error if it fails to encrypt the plaintext.
 func (c *DbCipher) Encrypt(_ js.Value, args []js.Value) any {
-	ciphertext, err := c.api.Encrypt(utils.CopyBytesToGo(args[0]))
+	ciphertext, err := c.getAPI().Encrypt(utils.CopyBytesToGo(args[0]))
 	if err != nil {
 		exception.ThrowTrace(err)
 		return nil
@@ -187,7 +419,7 @@ func (c *DbCipher) Encrypt(_ js.Value, args []js.Value) any {
 //   - The plaintext of the ciphertext passed in (Uint8Array).
 //   - Throws an error if it fails to encrypt the plaintext.
 func (c *DbCipher) Decrypt(_ js.Value, args []js.Value) any {
-	plaintext, err := c.api.Decrypt(args[0].String())
+	plaintext, err := c.getAPI().Decrypt(args[0].String())
 	if err != nil {
 		exception.ThrowTrace(err)
 		return nil
@@ -202,7 +434,7 @@ func (c *DbCipher) Decrypt(_ js.Value, args []js.Value) any {
 //   - JSON of the cipher (Uint8Array).
 //   - Throws an error if marshalling fails.
 func (c *DbCipher) MarshalJSON(js.Value, []js.Value) any {
-	data, err := c.api.MarshalJSON()
+	data, err := c.getAPI().MarshalJSON()
 	if err != nil {
 		exception.ThrowTrace(err)
 		return nil
@@ -223,10 +455,144 @@ func (c *DbCipher) MarshalJSON(js.Value, []js.Value) any {
 //   - JSON of the cipher (Uint8Array).
 //   - Throws an error if marshalling fails.
 func (c *DbCipher) UnmarshalJSON(_ js.Value, args []js.Value) any {
-	err := c.api.UnmarshalJSON(utils.CopyBytesToGo(args[0]))
+	err := c.getAPI().UnmarshalJSON(utils.CopyBytesToGo(args[0]))
 	if err != nil {
 		exception.ThrowTrace(err)
 		return nil
 	}
 	return nil
 }
+
+// RotateKey derives a new key from the password and a new salt and makes it
+// the current key. Values encrypted with older keys can still be decrypted.
+//
+// The database of every event model opened with this cipher is re-encrypted
+// with the new key in the background. Progress is reported on the EventUpdate
+// callback of each model with the event type KeyRotationProgress (100000) and
+// the JSON of [impl.KeyRotationProgressJSON]. If re-encryption is interrupted,
+// it is resumed the next time the database is opened.
+//
+// The keyed blind indexes used to look up messages by sender and to search
+// message text are derived from the first key and are not rotated, so lookups
+// find every message during and after re-encryption.
+//
+// Parameters:
+//   - args[0] - The password for storage. This must be the same password
+//     passed into [NewDatabaseCipher] (Uint8Array).
+//
+// Returns a promise:
+//   - Resolves to the version of the new key (int).
+//   - Rejected with an error if the password is incorrect or the new key
+//     cannot be created or stored.
+func (c *DbCipher) RotateKey(_ js.Value, args []js.Value) any {
+	password := utils.CopyBytesToGo(args[0])
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		version, err := c.rotateKey(password)
+		zeroBytes(password)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve(int(version))
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// rotateKey saves the salt of a new key, adds the key to the key ring, and
+// starts re-encrypting each tracked model. Returns the new key version.
+func (c *DbCipher) rotateKey(password []byte) (uint32, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	kr, ok := c.api.(*impl.KeyRing)
+	if !ok || c.kv == nil {
+		return 0, errors.New("DbCipher does not support key rotation")
+	}
+
+	salts, err := loadKeyRingSalts(c.kv)
+	if err != nil {
+		return 0, err
+	}
+
+	// Verify the password by deriving the current key
+	salt, exists := salts.Salts[kr.Current()]
+	if !exists {
+		salt = c.salt
+	}
+	if err = c.checkPassword(kr, password, salt); err != nil {
+		return 0, err
+	}
+
+	// Derive the new key from a new salt
+	newSalt, err := csprng.Generate(keyRingSaltSize, c.rng)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to generate salt")
+	}
+	newCipher, err := indexedDb.NewCipher(password, newSalt, c.blockSize, c.rng)
+	if err != nil {
+		return 0, err
+	}
+
+	// Save the salt before adding the new key so that nothing is ever
+	// encrypted with a key whose salt is lost
+	version := kr.Next()
+	salts.Current = version
+	salts.Salts[version] = newSalt
+	if err = saveKeyRingSalts(c.kv, salts); err != nil {
+		return 0, errors.Wrap(err, "failed to save key ring salts")
+	}
+	kr.Add(newCipher)
+
+	for model := range c.models {
+		if err = model.RotateKey(kr); err != nil {
+			jww.ERROR.Printf("Failed to rotate key of event model to "+
+				"version %d: %+v", version, err)
+		}
+	}
+
+	jww.INFO.Printf("Rotated DbCipher %d to key version %d", c.id, version)
+	return version, nil
+}
+
+// checkPassword returns an error if the password and salt do not derive the
+// current key of the key ring.
+func (c *DbCipher) checkPassword(
+	kr *impl.KeyRing, password, salt []byte) error {
+	current, err := kr.Cipher(kr.Current())
+	if err != nil {
+		return err
+	}
+	derived, err := indexedDb.NewCipher(password, salt, c.blockSize, c.rng)
+	if err != nil {
+		return err
+	}
+
+	currentJSON, err := current.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	derivedJSON, err := derived.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(currentJSON, derivedJSON) {
+		return errors.New("invalid password")
+	}
+	return nil
+}
+
+// GetKeyVersion returns the version of the key used to encrypt new values.
+// The version starts at 0 and is incremented by each call to
+// [DbCipher.RotateKey].
+//
+// Returns:
+//   - Key version (int).
+func (c *DbCipher) GetKeyVersion(js.Value, []js.Value) any {
+	if kr, ok := c.getAPI().(*impl.KeyRing); ok {
+		return int(kr.Current())
+	}
+	return 0
+}
@@ -12,6 +12,15 @@ package wasm
 import (
 	"reflect"
 	"testing"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/elixxir/client/v4/collective/versioned"
+	"gitlab.com/elixxir/crypto/indexedDb"
+	"gitlab.com/elixxir/ekv"
+	"gitlab.com/xx_network/crypto/csprng"
+
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
 )
 
 // Tests that the map representing DbCipher returned by
@@ -33,3 +42,84 @@ func Test_newChannelDbCipherJS(t *testing.T) {
 		}
 	}
 }
+
+// newTestRotatingCipher returns a DbCipher that supports key rotation with the
+// password and KV.
+func newTestRotatingCipher(
+	password []byte, kv versioned.KV, t *testing.T) (*DbCipher, *impl.KeyRing) {
+	rng := csprng.NewSystemRNG()
+	salt := []byte("testSalt")
+	c, err := indexedDb.NewCipher(password, salt, 256, rng)
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+	kr, err := impl.NewKeyRing(0, map[uint32]indexedDb.Cipher{0: c})
+	if err != nil {
+		t.Fatalf("Failed to create key ring: %+v", err)
+	}
+
+	return &DbCipher{
+		api: kr, salt: salt, kv: kv, rng: rng, blockSize: 256}, kr
+}
+
+// Tests that DbCipher.rotateKey saves the salt of the new key and makes it the
+// current key.
+func TestDbCipher_rotateKey(t *testing.T) {
+	password := []byte("password")
+	kv := versioned.NewKV(ekv.MakeMemstore())
+	c, kr := newTestRotatingCipher(password, kv, t)
+
+	version, err := c.rotateKey(password)
+	if err != nil {
+		t.Fatalf("Failed to rotate key: %+v", err)
+	}
+	if version != 1 || kr.Current() != 1 {
+		t.Errorf("Unexpected key version.\nexpected: %d\nreceived: %d "+
+			"(current %d)", 1, version, kr.Current())
+	}
+
+	salts, err := loadKeyRingSalts(kv)
+	if err != nil {
+		t.Fatalf("Failed to load key ring salts: %+v", err)
+	}
+	if _, exists := salts.Salts[version]; !exists || salts.Current != version {
+		t.Errorf("Salt of key version %d not saved: %+v", version, salts)
+	}
+}
+
+// Error path: Tests that DbCipher.rotateKey does not add the new key to the key
+// ring when saving its salt fails.
+func TestDbCipher_rotateKey_SaveError(t *testing.T) {
+	password := []byte("password")
+	kv := &failingSetKV{versioned.NewKV(ekv.MakeMemstore())}
+	c, kr := newTestRotatingCipher(password, kv, t)
+
+	if _, err := c.rotateKey(password); err == nil {
+		t.Fatalf("Rotating key did not fail when saving the salt failed.")
+	}
+	if kr.Current() != 0 {
+		t.Errorf("Current key version changed after failed rotation."+
+			"\nexpected: %d\nreceived: %d", 0, kr.Current())
+	}
+	if n := len(kr.Ciphers()); n != 1 {
+		t.Errorf("Key added to key ring after failed rotation."+
+			"\nexpected: %d keys\nreceived: %d keys", 1, n)
+	}
+}
+
+// failingSetKV is a versioned.KV that fails to set any value.
+type failingSetKV struct {
+	versioned.KV
+}
+
+func (kv *failingSetKV) Prefix(prefix string) (versioned.KV, error) {
+	prefixed, err := kv.KV.Prefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &failingSetKV{prefixed}, nil
+}
+
+func (*failingSetKV) Set(string, *versioned.Object) error {
+	return errors.New("set failed")
+}
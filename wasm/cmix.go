@@ -17,6 +17,7 @@ import (
 	"gitlab.com/elixxir/client/v4/bindings"
 	"gitlab.com/elixxir/wasm-utils/exception"
 	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/elixxir/xxdk-wasm/storage"
 )
 
 // initializing prevents a synchronized Cmix object from being loaded while one
@@ -35,48 +36,48 @@ func newCmixJS(api *bindings.Cmix) map[string]any {
 	c := Cmix{api}
 	cmix := map[string]any{
 		// cmix.go
-		"GetID":          js.FuncOf(c.GetID),
-		"GetReceptionID": js.FuncOf(c.GetReceptionID),
-		"GetRemoteKV":    js.FuncOf(c.GetRemoteKV),
-		"EKVGet":         js.FuncOf(c.EKVGet),
-		"EKVSet":         js.FuncOf(c.EKVSet),
+		"GetID":          sessionFuncOf(c.GetID),
+		"GetReceptionID": sessionFuncOf(c.GetReceptionID),
+		"GetRemoteKV":    sessionFuncOf(c.GetRemoteKV),
+		"EKVGet":         sessionFuncOf(c.EKVGet),
+		"EKVSet":         sessionFuncOf(c.EKVSet),
 
 		// identity.go
-		"MakeReceptionIdentity": js.FuncOf(
+		"MakeReceptionIdentity": sessionFuncOf(
 			c.MakeReceptionIdentity),
-		"MakeLegacyReceptionIdentity": js.FuncOf(
+		"MakeLegacyReceptionIdentity": sessionFuncOf(
 			c.MakeLegacyReceptionIdentity),
-		"GetReceptionRegistrationValidationSignature": js.FuncOf(
+		"GetReceptionRegistrationValidationSignature": sessionFuncOf(
 			c.GetReceptionRegistrationValidationSignature),
 
 		// follow.go
-		"StartNetworkFollower":            js.FuncOf(c.StartNetworkFollower),
-		"StopNetworkFollower":             js.FuncOf(c.StopNetworkFollower),
-		"SetTrackNetworkPeriod":           js.FuncOf(c.SetTrackNetworkPeriod),
-		"WaitForNetwork":                  js.FuncOf(c.WaitForNetwork),
-		"ReadyToSend":                     js.FuncOf(c.ReadyToSend),
-		"NetworkFollowerStatus":           js.FuncOf(c.NetworkFollowerStatus),
-		"GetNodeRegistrationStatus":       js.FuncOf(c.GetNodeRegistrationStatus),
-		"IsReady":                         js.FuncOf(c.IsReady),
-		"PauseNodeRegistrations":          js.FuncOf(c.PauseNodeRegistrations),
-		"ChangeNumberOfNodeRegistrations": js.FuncOf(c.ChangeNumberOfNodeRegistrations),
-		"HasRunningProcessies":            js.FuncOf(c.HasRunningProcessies),
-		"IsHealthy":                       js.FuncOf(c.IsHealthy),
-		"GetRunningProcesses":             js.FuncOf(c.GetRunningProcesses),
-		"AddHealthCallback":               js.FuncOf(c.AddHealthCallback),
-		"RemoveHealthCallback":            js.FuncOf(c.RemoveHealthCallback),
-		"RegisterClientErrorCallback":     js.FuncOf(c.RegisterClientErrorCallback),
-		"TrackServicesWithIdentity":       js.FuncOf(c.TrackServicesWithIdentity),
-		"TrackServices":                   js.FuncOf(c.TrackServices),
+		"StartNetworkFollower":            sessionFuncOf(c.StartNetworkFollower),
+		"StopNetworkFollower":             sessionFuncOf(c.StopNetworkFollower),
+		"SetTrackNetworkPeriod":           sessionFuncOf(c.SetTrackNetworkPeriod),
+		"WaitForNetwork":                  sessionFuncOf(c.WaitForNetwork),
+		"ReadyToSend":                     sessionFuncOf(c.ReadyToSend),
+		"NetworkFollowerStatus":           sessionFuncOf(c.NetworkFollowerStatus),
+		"GetNodeRegistrationStatus":       sessionFuncOf(c.GetNodeRegistrationStatus),
+		"IsReady":                         sessionFuncOf(c.IsReady),
+		"PauseNodeRegistrations":          sessionFuncOf(c.PauseNodeRegistrations),
+		"ChangeNumberOfNodeRegistrations": sessionFuncOf(c.ChangeNumberOfNodeRegistrations),
+		"HasRunningProcessies":            sessionFuncOf(c.HasRunningProcessies),
+		"IsHealthy":                       sessionFuncOf(c.IsHealthy),
+		"GetRunningProcesses":             sessionFuncOf(c.GetRunningProcesses),
+		"AddHealthCallback":               sessionFuncOf(c.AddHealthCallback),
+		"RemoveHealthCallback":            sessionFuncOf(c.RemoveHealthCallback),
+		"RegisterClientErrorCallback":     sessionFuncOf(c.RegisterClientErrorCallback),
+		"TrackServicesWithIdentity":       sessionFuncOf(c.TrackServicesWithIdentity),
+		"TrackServices":                   sessionFuncOf(c.TrackServices),
 
 		// connect.go
-		"Connect": js.FuncOf(c.Connect),
+		"Connect": sessionFuncOf(c.Connect),
 
 		// delivery.go
-		"WaitForRoundResult": js.FuncOf(c.WaitForRoundResult),
+		"WaitForRoundResult": sessionFuncOf(c.WaitForRoundResult),
 
 		// authenticatedConnection.go
-		"ConnectWithAuthentication": js.FuncOf(c.ConnectWithAuthentication),
+		"ConnectWithAuthentication": sessionFuncOf(c.ConnectWithAuthentication),
 	}
 
 	return cmix
@@ -105,6 +106,10 @@ func NewCmix(_ js.Value, args []js.Value) any {
 	registrationCode := args[3].String()
 
 	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		if storage.IsSessionLocked() {
+			reject(exception.NewTrace(errSessionLocked))
+			return
+		}
 		err := bindings.NewCmix(ndfJSON, storageDir, password, registrationCode)
 		if err != nil {
 			reject(exception.NewTrace(err))
@@ -139,6 +144,11 @@ func NewSynchronizedCmix(_ js.Value, args []js.Value) any {
 	rs := newRemoteStore(args[4])
 
 	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		if storage.IsSessionLocked() {
+			reject(exception.NewTrace(errSessionLocked))
+			return
+		}
+
 		// Block loading of synchronized Cmix during initialisation
 		initializing.Store(true)
 
@@ -182,6 +192,10 @@ func LoadCmix(_ js.Value, args []js.Value) any {
 	cmixParamsJSON := utils.CopyBytesToGo(args[2])
 
 	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		if storage.IsSessionLocked() {
+			reject(exception.NewTrace(errSessionLocked))
+			return
+		}
 		net, err := bindings.LoadCmix(storageDir, password,
 			cmixParamsJSON)
 		if err != nil {
@@ -216,7 +230,10 @@ func LoadSynchronizedCmix(_ js.Value, args []js.Value) any {
 	cmixParamsJSON := utils.CopyBytesToGo(args[4])
 
 	promiseFn := func(resolve, reject func(args ...any) js.Value) {
-		if initializing.Load() {
+		if storage.IsSessionLocked() {
+			reject(exception.NewTrace(errSessionLocked))
+			return
+		} else if initializing.Load() {
 			reject(exception.NewTrace(fmt.Errorf(
 				"cannot Load when New is running")))
 		}
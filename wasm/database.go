@@ -0,0 +1,336 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package wasm
+
+import (
+	"encoding/json"
+	"sync"
+	"syscall/js"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/wasm-utils/exception"
+	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+	"gitlab.com/elixxir/xxdk-wasm/storage"
+)
+
+// ExportDatabase exports every object store of a channels or DM event model
+// database into a single versioned archive encrypted with a key derived from
+// the password. The archive can be imported into another browser with
+// [NewDatabaseImporter] and the same password.
+//
+// The archive is passed to the writer in chunks as it is created, so the
+// database is never held in memory as a whole.
+//
+// Values are exported as they are stored. The archive password only protects
+// the archive; values encrypted by the event model stay encrypted with the
+// [DbCipher] of the database, whose salt is kept in the storage of the cMix
+// client that created it. An archive of an encrypted database can therefore
+// only be restored into the same cMix identity with that storage. It cannot be
+// read on another device or once that storage has been purged.
+//
+// Parameters:
+//   - args[0] - Name of the database (string). For a channels database, this
+//     is the storage tag returned by [ChannelsManager.GetStorageTag] followed
+//     by "_speakeasy". For a DM database, this is the base64 encoded public
+//     key of the identity followed by "_speakeasy_dm".
+//   - args[1] - The password used to encrypt the archive (Uint8Array).
+//   - args[2] - Javascript object that has functions that implement the
+//     [ArchiveWriter] interface.
+//
+// Returns a promise:
+//   - Resolves once the whole archive has been written.
+//   - Rejected with an error if the database does not exist or cannot be read.
+func ExportDatabase(_ js.Value, args []js.Value) any {
+	databaseName := args[0].String()
+	password := utils.CopyBytesToGo(args[1])
+	w := &archiveWriter{utils.WrapCB(args[2], "Write")}
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		if storage.IsSessionLocked() {
+			reject(exception.NewTrace(errSessionLocked))
+			return
+		}
+
+		err := impl.ExportDatabase(databaseName, password, w)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve()
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// ArchiveWriter receives the chunks of an archive created by
+// [ExportDatabase].
+type ArchiveWriter interface {
+	// Write is called with each chunk of the archive, in order. The chunks
+	// must be joined to get the archive.
+	//
+	// Parameters:
+	//   - chunk - The next chunk of the archive (Uint8Array).
+	Write(chunk []byte)
+}
+
+// archiveWriter wraps Javascript callbacks to adhere to the [ArchiveWriter]
+// interface and implements io.Writer.
+type archiveWriter struct {
+	write func(args ...any) js.Value
+}
+
+// Write passes a copy of the chunk to the Javascript writer.
+func (w *archiveWriter) Write(chunk []byte) (int, error) {
+	w.write(utils.CopyBytesToJS(chunk))
+	return len(chunk), nil
+}
+
+// DatabaseImporter wraps the [impl.ArchiveImporter] that recreates the
+// database in an archive created by [ExportDatabase].
+type DatabaseImporter struct {
+	api *impl.ArchiveImporter
+
+	// last is closed once the last call to Write or Close has finished. Each
+	// call waits on the previous one so that chunks are imported in the order
+	// they are written, even though each call runs in its own promise.
+	last chan struct{}
+	mux  sync.Mutex
+}
+
+// newDatabaseImporterJS creates a new Javascript compatible object
+// (map[string]any) that matches the [DatabaseImporter] structure.
+func newDatabaseImporterJS(di *DatabaseImporter) map[string]any {
+	return map[string]any{
+		"Write": sessionFuncOf(di.Write),
+		"Close": sessionFuncOf(di.Close),
+		"Abort": sessionFuncOf(di.Abort),
+	}
+}
+
+// NewDatabaseImporter returns a [DatabaseImporter] that imports an archive
+// created by [ExportDatabase] as it is written in chunks. Each chunk is
+// authenticated before it is written to the database, and the database is
+// deleted again if the import fails, is aborted, or the archive is
+// incomplete. A database with the same name must not already exist. Data that
+// is not an archive is rejected by the first call to Write.
+//
+// The database is tracked before any values are written to it, so a database
+// left behind by an import that is interrupted, such as by closing the page,
+// is deleted when local data is purged.
+//
+// Archives of older database versions are migrated when the database is next
+// opened by its channels manager or DM client. Values encrypted by the event
+// model can only be read with the [DbCipher] of the exporting cMix identity
+// (see [ExportDatabase]).
+//
+// Parameters:
+//   - args[0] - The password the archive was encrypted with (Uint8Array).
+//
+// Returns:
+//   - Javascript representation of the [DatabaseImporter] object.
+func NewDatabaseImporter(_ js.Value, args []js.Value) any {
+	password := utils.CopyBytesToGo(args[0])
+
+	di := &DatabaseImporter{
+		api:  impl.NewArchiveImporter(password, storage.StoreIndexedDb),
+		last: make(chan struct{}),
+	}
+	close(di.last)
+	return newDatabaseImporterJS(di)
+}
+
+// next returns a channel that is closed once the previous call has finished
+// and a channel that the caller must close once it has finished.
+func (di *DatabaseImporter) next() (previous <-chan struct{}, done chan struct{}) {
+	di.mux.Lock()
+	defer di.mux.Unlock()
+	previous, done = di.last, make(chan struct{})
+	di.last = done
+	return previous, done
+}
+
+// Write imports the next chunk of the archive.
+//
+// Parameters:
+//   - args[0] - The next chunk of the archive (Uint8Array).
+//
+// Returns a promise:
+//   - Resolves once every complete frame in the chunk has been imported.
+//   - Rejected with an error if the chunk cannot be decrypted or imported.
+//     The import has failed and the database is deleted.
+func (di *DatabaseImporter) Write(_ js.Value, args []js.Value) any {
+	chunk := utils.CopyBytesToGo(args[0])
+	previous, done := di.next()
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		<-previous
+		defer close(done)
+
+		if _, err := di.api.Write(chunk); err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve()
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// Close finishes the import once every chunk has been written.
+//
+// Returns a promise:
+//   - Resolves to the name of the imported database (string).
+//   - Rejected with an error if the archive is incomplete or the import
+//     failed.
+func (di *DatabaseImporter) Close(js.Value, []js.Value) any {
+	previous, done := di.next()
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		<-previous
+		defer close(done)
+
+		databaseName, err := di.api.Close()
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve(databaseName)
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// Abort stops the import and deletes the partially imported database. If a
+// chunk is being imported, the database is deleted once it has finished, and
+// every later call to Write or Close is rejected.
+func (di *DatabaseImporter) Abort(js.Value, []js.Value) any {
+	go di.api.Abort()
+	return nil
+}
+
+// databaseChecker is an event model that can check the integrity of its
+// database (e.g., the indexedDb worker model).
+type databaseChecker interface {
+	CheckDatabase(repair bool) ([]byte, error)
+}
+
+// checkDatabase returns a promise that resolves to the JSON of the integrity
+// report of the event model database.
+func checkDatabase(model any, repair bool) any {
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		checker, ok := model.(databaseChecker)
+		if !ok {
+			reject(exception.NewTrace(errors.New(
+				"event model does not support checking the database")))
+			return
+		}
+
+		report, err := checker.CheckDatabase(repair)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve(utils.CopyBytesToJS(report))
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// StorageUsage is the storage used by an event model database and by the
+// origin as a whole. It is returned by GetStorageUsage on [ChannelsManager]
+// and [DMClient].
+//
+// Example JSON:
+//
+//	{
+//	  "estimate": {"usage": 5242880, "quota": 2147483648, "persisted": false},
+//	  "database": {
+//	    "databaseName": "AW7fj+J8Ku/6o2gs+ky6R6KTpaiYIJYDZkmS+ZqeXYED_speakeasy",
+//	    "total": {"rows": 1045, "bytes": 281768},
+//	    "stores": {
+//	      "channels": {"rows": 3, "bytes": 412},
+//	      "files": {"rows": 0, "bytes": 0},
+//	      "messages": {"rows": 1042, "bytes": 281356},
+//	      "quarantine": {"rows": 0, "bytes": 0}
+//	    },
+//	    "groups": {
+//	      "ZUVtBkZ8RYPXdYbL8Df7ZyJPrTDdyCZLhPfrBW8vvhcD": {"rows": 1000, "bytes": 270120},
+//	      "Aw5Gq7X1n2uCk2EM/4WMcPfzRQk5uKs9FuMNfXnKOo0D": {"rows": 42, "bytes": 11236}
+//	    }
+//	  }
+//	}
+type StorageUsage struct {
+	// Estimate is the usage and quota of the origin. It is empty if the browser
+	// does not support navigator.storage.estimate.
+	Estimate impl.StorageEstimate `json:"estimate"`
+
+	// Database is the JSON of the [impl.DatabaseUsage] of the event model.
+	Database json.RawMessage `json:"database"`
+}
+
+// storageUsageGetter is an event model that can measure the storage used by
+// its database (e.g., the indexedDb worker model).
+type storageUsageGetter interface {
+	GetStorageUsage() ([]byte, error)
+}
+
+// getStorageUsage returns a promise that resolves to the JSON of the
+// StorageUsage of the event model database.
+func getStorageUsage(model any) any {
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		getter, ok := model.(storageUsageGetter)
+		if !ok {
+			reject(exception.NewTrace(errors.New(
+				"event model does not support getting storage usage")))
+			return
+		}
+
+		database, err := getter.GetStorageUsage()
+		if err != nil {
+			reject(exception.NewTrace(err))
+			return
+		}
+
+		estimate, err := impl.EstimateStorage()
+		if err != nil {
+			jww.WARN.Printf("Failed to estimate storage: %+v", err)
+		}
+
+		usage, err := json.Marshal(StorageUsage{estimate, database})
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve(utils.CopyBytesToJS(usage))
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// storageParamsArg returns the [impl.StorageParams] in the optional argument
+// at index i, which is the JSON of the params (Uint8Array). Returns the zero
+// params, which keep the defaults of the worker, if the argument is not
+// provided. Throws an error if the JSON is invalid.
+func storageParamsArg(args []js.Value, i int) impl.StorageParams {
+	var params impl.StorageParams
+	if len(args) <= i || args[i].IsUndefined() || args[i].IsNull() {
+		return params
+	}
+
+	err := json.Unmarshal(utils.CopyBytesToGo(args[i]), &params)
+	if err != nil {
+		exception.ThrowTrace(
+			errors.Wrap(err, "failed to unmarshal storage params"))
+	}
+	return params
+}
@@ -10,17 +10,22 @@
 package wasm
 
 import (
+	"crypto/ed25519"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"syscall/js"
+	"time"
 
 	jww "github.com/spf13/jwalterweatherman"
 
 	"gitlab.com/elixxir/client/v4/bindings"
 	"gitlab.com/elixxir/client/v4/dm"
 	"gitlab.com/elixxir/crypto/codename"
+	"gitlab.com/elixxir/crypto/message"
 	"gitlab.com/elixxir/wasm-utils/exception"
 	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
 	indexDB "gitlab.com/elixxir/xxdk-wasm/indexedDb/worker/dm"
 )
 
@@ -32,44 +37,64 @@ import (
 // to be Javascript compatible.
 type DMClient struct {
 	api *bindings.DMClient
+
+	// model is the event model of the client, if it was built in Go.
+	model dm.EventModel
 }
 
 // newDMClientJS creates a new Javascript compatible object (map[string]any)
-// that matches the [DMClient] structure.
-func newDMClientJS(api *bindings.DMClient) map[string]any {
-	cm := DMClient{api}
+// that matches the [DMClient] structure. The model may be nil if the event
+// model is not built in Go.
+func newDMClientJS(api *bindings.DMClient, model dm.EventModel) map[string]any {
+	cm := DMClient{api, model}
 	dmClientMap := map[string]any{
 		// Basic Channel API
-		"GetID": js.FuncOf(cm.GetID),
+		"GetID": sessionFuncOf(cm.GetID),
 
 		// Identity and Nickname Controls
-		"GetPublicKey":          js.FuncOf(cm.GetPublicKey),
-		"GetToken":              js.FuncOf(cm.GetToken),
-		"GetIdentity":           js.FuncOf(cm.GetIdentity),
-		"ExportPrivateIdentity": js.FuncOf(cm.ExportPrivateIdentity),
-		"GetNickname":           js.FuncOf(cm.GetNickname),
-		"SetNickname":           js.FuncOf(cm.SetNickname),
-		"BlockPartner":          js.FuncOf(cm.BlockPartner),
-		"UnblockPartner":        js.FuncOf(cm.UnblockPartner),
-		"IsBlocked":             js.FuncOf(cm.IsBlocked),
-		"GetBlockedPartners":    js.FuncOf(cm.GetBlockedPartners),
-		"GetDatabaseName":       js.FuncOf(cm.GetDatabaseName),
+		"GetPublicKey":          sessionFuncOf(cm.GetPublicKey),
+		"GetToken":              sessionFuncOf(cm.GetToken),
+		"GetIdentity":           sessionFuncOf(cm.GetIdentity),
+		"ExportPrivateIdentity": sessionFuncOf(cm.ExportPrivateIdentity),
+		"GetNickname":           sessionFuncOf(cm.GetNickname),
+		"SetNickname":           sessionFuncOf(cm.SetNickname),
+		"BlockPartner":          sessionFuncOf(cm.BlockPartner),
+		"UnblockPartner":        sessionFuncOf(cm.UnblockPartner),
+		"IsBlocked":             sessionFuncOf(cm.IsBlocked),
+		"GetBlockedPartners":    sessionFuncOf(cm.GetBlockedPartners),
+		"GetDatabaseName":       sessionFuncOf(cm.GetDatabaseName),
+		"ExportConversationHistory": sessionFuncOf(
+			cm.ExportConversationHistory),
+		"GetDMMessages":           sessionFuncOf(cm.GetDMMessages),
+		"GetConversations":        sessionFuncOf(cm.GetConversations),
+		"MarkConversationRead":    sessionFuncOf(cm.MarkConversationRead),
+		"SetConversationArchived": sessionFuncOf(cm.SetConversationArchived),
+		"SetConversationPinned":   sessionFuncOf(cm.SetConversationPinned),
+		"SetConversationMuted":    sessionFuncOf(cm.SetConversationMuted),
+		"CheckDatabase":           sessionFuncOf(cm.CheckDatabase),
+		"GetStorageUsage":         sessionFuncOf(cm.GetStorageUsage),
+
+		// Drafts
+		"SaveDraft":  sessionFuncOf(cm.SaveDraft),
+		"GetDraft":   sessionFuncOf(cm.GetDraft),
+		"ClearDraft": sessionFuncOf(cm.ClearDraft),
 
 		// Share URL
-		"GetShareURL": js.FuncOf(cm.GetShareURL),
+		"GetShareURL": sessionFuncOf(cm.GetShareURL),
 
 		// DM Sending Methods and Reports
-		"SendText":      js.FuncOf(cm.SendText),
-		"SendReply":     js.FuncOf(cm.SendReply),
-		"SendReaction":  js.FuncOf(cm.SendReaction),
-		"SendSilent":    js.FuncOf(cm.SendSilent),
-		"SendInvite":    js.FuncOf(cm.SendInvite),
-		"DeleteMessage": js.FuncOf(cm.DeleteMessage),
-		"Send":          js.FuncOf(cm.Send),
+		"SendText":      sessionFuncOf(cm.SendText),
+		"SendReply":     sessionFuncOf(cm.SendReply),
+		"SendReaction":  sessionFuncOf(cm.SendReaction),
+		"SendSilent":    sessionFuncOf(cm.SendSilent),
+		"SendInvite":    sessionFuncOf(cm.SendInvite),
+		"DeleteMessage": sessionFuncOf(cm.DeleteMessage),
+		"Send":          sessionFuncOf(cm.Send),
+		"EditMessage":   sessionFuncOf(cm.EditMessage),
 
 		// Notifications
-		"GetNotificationLevel": js.FuncOf(cm.GetNotificationLevel),
-		"SetMobileNotificationsLevel": js.FuncOf(
+		"GetNotificationLevel": sessionFuncOf(cm.GetNotificationLevel),
+		"SetMobileNotificationsLevel": sessionFuncOf(
 			cm.SetMobileNotificationsLevel),
 	}
 
@@ -114,6 +139,9 @@ func (dmCBS *dmCallbacks) EventUpdate(eventType int64, jsonData []byte) {
 //     [bindings.DmCallbacks]. It is a callback that informs the UI about
 //     updates relating to DM conversations. The interface may be null, but if
 //     one is provided, each method must be implemented.
+//   - args[5] - JSON of [impl.StorageParams] (Uint8Array). It sets the timeout
+//     and retries of the storage operations of the database worker. This
+//     argument is optional; the defaults are used if it is omitted.
 //
 // Returns:
 //   - Javascript representation of the [DMClient] object.
@@ -132,7 +160,7 @@ func NewDMClient(_ js.Value, args []js.Value) any {
 		return nil
 	}
 
-	return newDMClientJS(cm)
+	return newDMClientJS(cm, nil)
 }
 
 // NewDMClientWithIndexedDb creates a new [DMClient] from a private identity
@@ -160,6 +188,9 @@ func NewDMClient(_ js.Value, args []js.Value) any {
 //     [bindings.DmCallbacks]. It is a callback that informs the UI about
 //     updates relating to DM conversations. The interface may be null, but if
 //     one is provided, each method must be implemented.
+//   - args[6] - JSON of [impl.StorageParams] (Uint8Array). It sets the timeout
+//     and retries of the storage operations of the database worker. This
+//     argument is optional; the defaults are used if it is omitted.
 //
 // Returns:
 //   - Resolves to a Javascript representation of the [DMClient] object.
@@ -172,14 +203,15 @@ func NewDMClientWithIndexedDb(_ js.Value, args []js.Value) any {
 	wasmJsPath := args[3].String()
 	privateIdentity := utils.CopyBytesToGo(args[4])
 	cbs := newDmCallbacks(args[5])
+	params := storageParamsArg(args, 6)
 
 	cipher, err := dbCipherTrackerSingleton.get(cipherID)
 	if err != nil {
 		exception.ThrowTrace(err)
 	}
 
-	return newDMClientWithIndexedDb(
-		cmixID, notificationsID, wasmJsPath, privateIdentity, cipher, cbs)
+	return newDMClientWithIndexedDb(cmixID, notificationsID, wasmJsPath,
+		privateIdentity, cipher, params, cbs)
 }
 
 // NewDMClientWithIndexedDbUnsafe creates a new [DMClient] from a private
@@ -216,13 +248,15 @@ func NewDMClientWithIndexedDbUnsafe(_ js.Value, args []js.Value) any {
 	wasmJsPath := args[2].String()
 	privateIdentity := utils.CopyBytesToGo(args[3])
 	cbs := newDmCallbacks(args[4])
+	params := storageParamsArg(args, 5)
 
-	return newDMClientWithIndexedDb(
-		cmixID, notificationsID, wasmJsPath, privateIdentity, nil, cbs)
+	return newDMClientWithIndexedDb(cmixID, notificationsID, wasmJsPath,
+		privateIdentity, nil, params, cbs)
 }
 
 func newDMClientWithIndexedDb(cmixID, notificationsID int, wasmJsPath string,
-	privateIdentity []byte, cipher *DbCipher, cbs *dmCallbacks) any {
+	privateIdentity []byte, cipher *DbCipher, params impl.StorageParams,
+	cbs *dmCallbacks) any {
 
 	promiseFn := func(resolve, reject func(args ...any) js.Value) {
 		pi, err := codename.UnmarshalPrivateIdentity(privateIdentity)
@@ -231,17 +265,20 @@ func newDMClientWithIndexedDb(cmixID, notificationsID int, wasmJsPath string,
 		}
 		dmPath := base64.RawStdEncoding.EncodeToString(pi.PubKey[:])
 		model, err :=
-			indexDB.NewWASMEventModel(dmPath, wasmJsPath, cipher.api, cbs)
+			indexDB.NewWASMEventModel(
+				dmPath, wasmJsPath, cipher.api, params, cbs)
 		if err != nil {
 			reject(exception.NewTrace(err))
 		}
+		sessionTrackerSingleton.addModel(model)
+		cipher.addModel(model)
 
 		cm, err := bindings.NewDMClientWithGoEventModel(
 			cmixID, notificationsID, privateIdentity, model, cbs)
 		if err != nil {
 			reject(exception.NewTrace(err))
 		} else {
-			resolve(newDMClientJS(cm))
+			resolve(newDMClientJS(cm, model))
 		}
 	}
 
@@ -401,6 +438,9 @@ func (dmc *DMClient) GetBlockedPartners(js.Value, []js.Value) any {
 
 // SendText is used to send a formatted direct message to a user.
 //
+// Once sent, the draft of the conversation is cleared unless it was saved
+// after sending started.
+//
 // Parameters:
 //   - args[0] - The bytes of the public key of the partner's ED25519 signing
 //     key (Uint8Array).
@@ -431,11 +471,13 @@ func (dmc *DMClient) SendText(_ js.Value, args []js.Value) any {
 		partnerToken, truncate(message, 10))
 
 	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		sentAt := time.Now()
 		sendReport, err := dmc.api.SendText(partnerPubKeyBytes, partnerToken,
 			message, leaseTimeMS, cmixParamsJSON)
 		if err != nil {
 			reject(exception.NewTrace(err))
 		} else {
+			dmc.clearDraft(partnerPubKeyBytes, sentAt)
 			resolve(utils.CopyBytesToJS(sendReport))
 		}
 	}
@@ -451,6 +493,9 @@ func (dmc *DMClient) SendText(_ js.Value, args []js.Value) any {
 // The message will auto delete leaseTime after the round it is sent in, lasting
 // forever if [bindings.ValidForever] is used.
 //
+// Once sent, the draft of the conversation is cleared unless it was saved
+// after sending started.
+//
 // Parameters:
 //   - args[0] - The bytes of the public key of the partner's ED25519 signing
 //     key (Uint8Array).
@@ -489,11 +534,13 @@ func (dmc *DMClient) SendReply(_ js.Value, args []js.Value) any {
 		truncate(replyMessage, 10))
 
 	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		sentAt := time.Now()
 		sendReport, err := dmc.api.SendReply(partnerPubKeyBytes, partnerToken,
 			replyMessage, replyToBytes, leaseTimeMS, cmixParamsJSON)
 		if err != nil {
 			reject(exception.NewTrace(err))
 		} else {
+			dmc.clearDraft(partnerPubKeyBytes, sentAt)
 			resolve(utils.CopyBytesToJS(sendReport))
 		}
 	}
@@ -721,6 +768,64 @@ func (dmc *DMClient) Send(_ js.Value, args []js.Value) any {
 	return utils.CreatePromise(promiseFn)
 }
 
+// EditMessage replaces the text of one of the user's own messages in the
+// conversation with the partner. The edit is sent as a message of type
+// [impl.EditMessageType] that references the original message and its author.
+// Event models that support edits keep the edit history and show the latest
+// text; edits from anyone other than the original sender are rejected by every
+// receiver, including the user's own event model.
+//
+// Parameters:
+//   - args[0] - The bytes of the public key of the partner's ED25519 signing
+//     key (Uint8Array).
+//   - args[1] - The token used to derive the reception ID for the partner
+//     (int).
+//   - args[2] - The bytes of the [message.ID] of the message to edit
+//     (Uint8Array).
+//   - args[3] - The new text of the message (string).
+//   - args[4] - The lease of the edit, in milliseconds (int).
+//   - args[5] - JSON of [xxdk.CMIXParams]. If left empty
+//     [bindings.GetDefaultCMixParams] will be used internally (Uint8Array).
+//
+// Returns a promise:
+//   - Resolves to the JSON of [bindings.ChannelSendReport] (Uint8Array).
+//   - Rejected with an error if sending fails.
+func (dmc *DMClient) EditMessage(_ js.Value, args []js.Value) any {
+	partnerPubKeyBytes := utils.CopyBytesToGo(args[0])
+	partnerToken := int32(args[1].Int())
+	targetMessageIdBytes := utils.CopyBytesToGo(args[2])
+	text := args[3].String()
+	leaseTimeMS := int64(args[4].Int())
+	cmixParamsJSON := utils.CopyBytesToGo(args[5])
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		messageID, err := message.UnmarshalID(targetMessageIdBytes)
+		if err != nil {
+			reject(exception.NewTrace(err))
+			return
+		}
+		payload, err := json.Marshal(impl.MessageEdit{
+			MessageID: messageID,
+			AuthorKey: dmc.api.GetPublicKey(),
+			Text:      text,
+		})
+		if err != nil {
+			reject(exception.NewTrace(err))
+			return
+		}
+
+		sendReport, err := dmc.api.Send(partnerPubKeyBytes, partnerToken,
+			impl.EditMessageType, payload, leaseTimeMS, cmixParamsJSON)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve(utils.CopyBytesToJS(sendReport))
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
 // GetDatabaseName returns the storage tag, so users listening to the database
 // can separately listen and read updates there.
 //
@@ -731,6 +836,565 @@ func (dmc *DMClient) GetDatabaseName(js.Value, []js.Value) any {
 		"_speakeasy_dm"
 }
 
+// ExportConversationHistory renders the history of the conversation with the
+// partner, with nicknames, timestamps, replies, and reactions, to JSON,
+// Markdown, or a standalone HTML page. The text is decrypted from the event
+// model database.
+//
+// Only available for clients created with [NewDMClientWithIndexedDb] (or its
+// unsafe variant).
+//
+// Parameters:
+//   - args[0] - The partner's [ed25519.PublicKey] (Uint8Array).
+//   - args[1] - The format: "json", "markdown", or "html" (string).
+//   - args[2] - Only messages sent at or after this time are included, in
+//     Unix milliseconds. Set to 0 to include messages from the beginning of
+//     the conversation (int).
+//   - args[3] - Only messages sent before this time are included, in Unix
+//     milliseconds. Set to 0 to include messages up to now (int).
+//
+// Returns a promise:
+//   - Resolves to the rendered history (Uint8Array).
+//   - Rejected with an error if the format is unknown, the conversation does
+//     not exist, the event model does not support exporting, or the export
+//     fails.
+func (dmc *DMClient) ExportConversationHistory(
+	_ js.Value, args []js.Value) any {
+	partnerPubKey := ed25519.PublicKey(utils.CopyBytesToGo(args[0]))
+	format := args[1].String()
+	start, end := historyRange(int64(args[2].Int()), int64(args[3].Int()))
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		exporter, ok := dmc.model.(dmHistoryExporter)
+		if !ok {
+			reject(exception.NewTrace(errors.New(
+				"event model does not support exporting history")))
+			return
+		}
+
+		history, err := exporter.ExportHistory(partnerPubKey, start, end, format)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve(utils.CopyBytesToJS(history))
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// dmMessageGetter is an event model that can page through the messages of a
+// conversation (e.g., the indexedDb worker model).
+type dmMessageGetter interface {
+	GetMessages(partnerPubKey ed25519.PublicKey, cursor []byte,
+		limit int) ([]byte, error)
+}
+
+// GetDMMessages returns a page of the message history of the conversation with
+// the partner from the event model, newest first, with the text decrypted and
+// the delivery status of each message. To get older messages, call it again
+// with the cursor returned with the page until no cursor is returned.
+//
+// Only available for clients created with [NewDMClientWithIndexedDb] (or its
+// unsafe variant).
+//
+// Parameters:
+//   - args[0] - The partner's [ed25519.PublicKey] (Uint8Array).
+//   - args[1] - The cursor returned with the previous page or null to get the
+//     newest messages (Uint8Array).
+//   - args[2] - The maximum number of messages in the page. Set to 0 to get
+//     every remaining message (int).
+//
+// Returns a promise:
+//   - Resolves to the JSON of the page (Uint8Array).
+//   - Rejected with an error if the event model does not support getting
+//     messages, the cursor is invalid, or the database cannot be read.
+//
+// Example JSON:
+//
+//	{
+//	  "messages": [
+//	    {
+//	      "uuid": 42,
+//	      "messageID": "Tg6VB8UVL+jn3GlWIvCXASsNHinxbqXTr/FF6z6q9lU=",
+//	      "senderPubKey": "z8VYHxDJG6b+bJQ3u1Ok0RGprg+z0a9U2aU6T8ZqZPA=",
+//	      "timestamp": "2023-07-21T11:42:09.512-07:00",
+//	      "status": 2,
+//	      "type": 1,
+//	      "text": "Hello, World!",
+//	      "codesetVersion": 0,
+//	      "round": 1234,
+//	      "edited": false
+//	    }
+//	  ],
+//	  "nextCursor": "eyJ0aW1lc3RhbXAiOiIyMDIzLTA3LTIxVDExOjQyOjA5LjUxMi0wNzowMCIsInV1aWQiOjQyfQ=="
+//	}
+func (dmc *DMClient) GetDMMessages(_ js.Value, args []js.Value) any {
+	partnerPubKey := ed25519.PublicKey(utils.CopyBytesToGo(args[0]))
+	var cursor []byte
+	if !args[1].IsNull() && !args[1].IsUndefined() {
+		cursor = utils.CopyBytesToGo(args[1])
+	}
+	limit := args[2].Int()
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		getter, ok := dmc.model.(dmMessageGetter)
+		if !ok {
+			reject(exception.NewTrace(errors.New(
+				"event model does not support getting messages")))
+			return
+		}
+
+		page, err := getter.GetMessages(partnerPubKey, cursor, limit)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve(utils.CopyBytesToJS(page))
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// dmConversationLister is an event model that keeps a summary of the activity
+// of each conversation (e.g., the indexedDb worker model).
+type dmConversationLister interface {
+	ListConversations(sortBy string, blocked, archived *bool) ([]byte, error)
+	MarkConversationRead(partnerPubKey ed25519.PublicKey) error
+}
+
+// ConversationListOptions are the options of [DMClient.GetConversations].
+//
+// Example JSON:
+//
+//	{
+//	  "sortBy": "unread",
+//	  "blocked": false,
+//	  "archived": null
+//	}
+type ConversationListOptions struct {
+	// SortBy is "lastMessage" to sort by the time of the newest message,
+	// newest first, "nickname" to sort alphabetically by nickname, or
+	// "unread" to sort by the number of unread messages, most first. Defaults
+	// to "lastMessage".
+	SortBy string `json:"sortBy"`
+
+	// Blocked, if not null, limits the conversations to those that are
+	// blocked (true) or not blocked (false).
+	Blocked *bool `json:"blocked"`
+
+	// Archived, if not null, limits the conversations to those that are
+	// archived (true) or not archived (false).
+	Archived *bool `json:"archived"`
+}
+
+// GetConversations returns every conversation in the event model with the time,
+// decrypted preview, and sender of its newest message and its number of unread
+// messages, so that a conversation list can be built without loading the
+// history of each conversation.
+//
+// Pinned conversations are listed first, each part in the order of
+// [ConversationListOptions.SortBy].
+//
+// Only available for clients created with [NewDMClientWithIndexedDb] (or its
+// unsafe variant).
+//
+// Parameters:
+//   - args[0] - JSON of [ConversationListOptions] or null to list every
+//     conversation sorted by newest message (Uint8Array).
+//
+// Returns a promise:
+//   - Resolves to the JSON of the list of conversations (Uint8Array).
+//   - Rejected with an error if the options are invalid, the event model does
+//     not support listing conversations, or the database cannot be read.
+//
+// Example JSON:
+//
+//	[
+//	  {
+//	    "pub_key": "z8VYHxDJG6b+bJQ3u1Ok0RGprg+z0a9U2aU6T8ZqZPA=",
+//	    "nickname": "Alice",
+//	    "token": 4231817746,
+//	    "codeset_version": 0,
+//	    "blocked_timestamp": null,
+//	    "last_message_timestamp": "2023-07-21T11:42:09.512-07:00",
+//	    "last_message_preview": "Hello, World!",
+//	    "last_sender_pub_key": "z8VYHxDJG6b+bJQ3u1Ok0RGprg+z0a9U2aU6T8ZqZPA=",
+//	    "unread_count": 2,
+//	    "archived_timestamp": null,
+//	    "pinned_timestamp": "2023-07-20T09:12:44.108-07:00",
+//	    "muted": false
+//	  }
+//	]
+func (dmc *DMClient) GetConversations(_ js.Value, args []js.Value) any {
+	var optionsJSON []byte
+	if len(args) > 0 && !args[0].IsNull() && !args[0].IsUndefined() {
+		optionsJSON = utils.CopyBytesToGo(args[0])
+	}
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		lister, ok := dmc.model.(dmConversationLister)
+		if !ok {
+			reject(exception.NewTrace(errors.New(
+				"event model does not support listing conversations")))
+			return
+		}
+		var options ConversationListOptions
+		if len(optionsJSON) > 0 {
+			if err := json.Unmarshal(optionsJSON, &options); err != nil {
+				reject(exception.NewTrace(err))
+				return
+			}
+		}
+
+		convos, err := lister.ListConversations(
+			options.SortBy, options.Blocked, options.Archived)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve(utils.CopyBytesToJS(convos))
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// MarkConversationRead sets the number of unread messages in the conversation
+// with the partner to zero.
+//
+// Only available for clients created with [NewDMClientWithIndexedDb] (or its
+// unsafe variant).
+//
+// Parameters:
+//   - args[0] - The partner's [ed25519.PublicKey] (Uint8Array).
+//
+// Returns a promise:
+//   - Resolves on success.
+//   - Rejected with an error if the event model does not support listing
+//     conversations or there is no conversation with the partner.
+func (dmc *DMClient) MarkConversationRead(_ js.Value, args []js.Value) any {
+	partnerPubKey := ed25519.PublicKey(utils.CopyBytesToGo(args[0]))
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		lister, ok := dmc.model.(dmConversationLister)
+		if !ok {
+			reject(exception.NewTrace(errors.New(
+				"event model does not support listing conversations")))
+			return
+		}
+
+		if err := lister.MarkConversationRead(partnerPubKey); err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve()
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// dmConversationStateSetter is an event model that stores whether each
+// conversation is archived, pinned, or muted (e.g., the indexedDb worker
+// model).
+type dmConversationStateSetter interface {
+	SetConversationState(partnerPubKey ed25519.PublicKey,
+		archived, pinned, muted *bool) error
+}
+
+// SetConversationArchived archives the conversation with the partner or moves
+// it out of the archive. Archived conversations can be filtered out of
+// [DMClient.GetConversations]. The partner is not blocked.
+//
+// On change, an [impl.ConversationStateUpdate] event is sent on the
+// EventUpdate callback with the JSON of [impl.ConversationStateUpdateJSON].
+//
+// Only available for clients created with [NewDMClientWithIndexedDb] (or its
+// unsafe variant).
+//
+// Parameters:
+//   - args[0] - The partner's [ed25519.PublicKey] (Uint8Array).
+//   - args[1] - Set to true to archive and false to unarchive (boolean).
+//
+// Returns a promise:
+//   - Resolves on success.
+//   - Rejected with an error if the event model does not support conversation
+//     states or there is no conversation with the partner.
+func (dmc *DMClient) SetConversationArchived(_ js.Value, args []js.Value) any {
+	archived := args[1].Bool()
+	return dmc.setConversationState(args[0], &archived, nil, nil)
+}
+
+// SetConversationPinned pins the conversation with the partner to the top of
+// [DMClient.GetConversations] or unpins it.
+//
+// On change, an [impl.ConversationStateUpdate] event is sent on the
+// EventUpdate callback with the JSON of [impl.ConversationStateUpdateJSON].
+//
+// Only available for clients created with [NewDMClientWithIndexedDb] (or its
+// unsafe variant).
+//
+// Parameters:
+//   - args[0] - The partner's [ed25519.PublicKey] (Uint8Array).
+//   - args[1] - Set to true to pin and false to unpin (boolean).
+//
+// Returns a promise:
+//   - Resolves on success.
+//   - Rejected with an error if the event model does not support conversation
+//     states or there is no conversation with the partner.
+func (dmc *DMClient) SetConversationPinned(_ js.Value, args []js.Value) any {
+	pinned := args[1].Bool()
+	return dmc.setConversationState(args[0], nil, &pinned, nil)
+}
+
+// SetConversationMuted mutes or unmutes the conversation with the partner on
+// this device. Muting only marks the conversation so that the UI can silence
+// it; unlike [DMClient.BlockPartner], messages are still received and shown.
+//
+// On change, an [impl.ConversationStateUpdate] event is sent on the
+// EventUpdate callback with the JSON of [impl.ConversationStateUpdateJSON].
+//
+// Only available for clients created with [NewDMClientWithIndexedDb] (or its
+// unsafe variant).
+//
+// Parameters:
+//   - args[0] - The partner's [ed25519.PublicKey] (Uint8Array).
+//   - args[1] - Set to true to mute and false to unmute (boolean).
+//
+// Returns a promise:
+//   - Resolves on success.
+//   - Rejected with an error if the event model does not support conversation
+//     states or there is no conversation with the partner.
+func (dmc *DMClient) SetConversationMuted(_ js.Value, args []js.Value) any {
+	muted := args[1].Bool()
+	return dmc.setConversationState(args[0], nil, nil, &muted)
+}
+
+// setConversationState sets the state of the conversation with the partner in
+// the event model. Nil values are left unchanged.
+func (dmc *DMClient) setConversationState(
+	partnerPubKeyArg js.Value, archived, pinned, muted *bool) any {
+	partnerPubKey := ed25519.PublicKey(utils.CopyBytesToGo(partnerPubKeyArg))
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		setter, ok := dmc.model.(dmConversationStateSetter)
+		if !ok {
+			reject(exception.NewTrace(errors.New(
+				"event model does not support conversation states")))
+			return
+		}
+
+		err := setter.SetConversationState(
+			partnerPubKey, archived, pinned, muted)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve()
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// CheckDatabase validates every value in the event model database of the
+// DM client. Values that fail to unmarshal or decrypt, are missing required
+// fields, refer to a conversation that does not exist, or duplicate the message ID
+// of another message are reported.
+//
+// Only available for clients created with [NewDMClientWithIndexedDb] (or its
+// unsafe variant).
+//
+// Parameters:
+//   - args[0] - Set to true to move bad values to a quarantine store in the
+//     database. Set to false to only report them (boolean).
+//
+// Returns a promise:
+//   - Resolves to the JSON of the [impl.IntegrityReport] (Uint8Array).
+//   - Rejected with an error if the event model does not support checking or
+//     the database cannot be read.
+func (dmc *DMClient) CheckDatabase(_ js.Value, args []js.Value) any {
+	return checkDatabase(dmc.model, args[0].Bool())
+}
+
+// GetStorageUsage returns the number of rows and approximate size of each
+// object store in the event model database and of the messages of each
+// conversation, along with the storage usage, quota, and persistence of the origin.
+// Use it to show the user how much space is used and to warn them before
+// writes start failing.
+//
+// Only available for clients created with [NewDMClientWithIndexedDb] (or its
+// unsafe variant).
+//
+// Returns a promise:
+//   - Resolves to the JSON of the [StorageUsage] (Uint8Array).
+//   - Rejected with an error if the event model does not support measuring
+//     storage or the database cannot be read.
+func (dmc *DMClient) GetStorageUsage(js.Value, []js.Value) any {
+	return getStorageUsage(dmc.model)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Drafts                                                                     //
+////////////////////////////////////////////////////////////////////////////////
+
+// dmDraftStore is an event model that stores the draft of each conversation
+// (e.g., the indexedDb worker model).
+type dmDraftStore interface {
+	SaveDraft(
+		partnerKey ed25519.PublicKey, text string, replyTo *message.ID) error
+	GetDraft(partnerKey ed25519.PublicKey) ([]byte, error)
+	ClearDraft(partnerKey ed25519.PublicKey, savedBefore time.Time) error
+}
+
+// SaveDraft stores the unsent text of the conversation in the event model,
+// encrypted like message text, replacing any existing draft. Saving empty text
+// that is not a reply deletes the draft.
+//
+// The draft is cleared automatically once a message or reply is sent to the
+// partner with [DMClient.SendText] or [DMClient.SendReply].
+//
+// Only available for clients created with [NewDMClientWithIndexedDb] (or its
+// unsafe variant).
+//
+// Parameters:
+//   - args[0] - The partner's [ed25519.PublicKey] (Uint8Array).
+//   - args[1] - The unsent text (string).
+//   - args[2] - The marshalled [message.ID] of the message being replied to or
+//     null if the draft is not a reply (Uint8Array).
+//
+// Returns a promise:
+//   - Resolves on success.
+//   - Rejected with an error if the event model does not support drafts or
+//     the draft cannot be saved.
+func (dmc *DMClient) SaveDraft(_ js.Value, args []js.Value) any {
+	partnerPubKey := ed25519.PublicKey(utils.CopyBytesToGo(args[0]))
+	text := args[1].String()
+	var replyToBytes []byte
+	if !args[2].IsNull() && !args[2].IsUndefined() {
+		replyToBytes = utils.CopyBytesToGo(args[2])
+	}
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		store, ok := dmc.model.(dmDraftStore)
+		if !ok {
+			reject(exception.NewTrace(
+				errors.New("event model does not support drafts")))
+			return
+		}
+		var replyTo *message.ID
+		if len(replyToBytes) > 0 {
+			messageID, err := message.UnmarshalID(replyToBytes)
+			if err != nil {
+				reject(exception.NewTrace(err))
+				return
+			}
+			replyTo = &messageID
+		}
+
+		err := store.SaveDraft(partnerPubKey, text, replyTo)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve()
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// GetDraft returns the draft of the conversation from the event model.
+//
+// Only available for clients created with [NewDMClientWithIndexedDb] (or its
+// unsafe variant).
+//
+// Parameters:
+//   - args[0] - The partner's [ed25519.PublicKey] (Uint8Array).
+//
+// Returns a promise:
+//   - Resolves to the JSON of the draft (Uint8Array) or null if the
+//     conversation has no draft.
+//   - Rejected with an error if the event model does not support drafts or
+//     the draft cannot be read.
+//
+// Example JSON:
+//
+//	{
+//	  "pub_key": "z8VYHxDJG6b+bJQ3u1Ok0RGprg+z0a9U2aU6T8ZqZPA=",
+//	  "text": "Hello, wor",
+//	  "reply_to": "Tg6VB8UVL+jn3GlWIvCXASsNHinxbqXTr/FF6z6q9lU=",
+//	  "timestamp": "2023-07-21T11:42:09.512-07:00"
+//	}
+func (dmc *DMClient) GetDraft(_ js.Value, args []js.Value) any {
+	partnerPubKey := ed25519.PublicKey(utils.CopyBytesToGo(args[0]))
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		store, ok := dmc.model.(dmDraftStore)
+		if !ok {
+			reject(exception.NewTrace(
+				errors.New("event model does not support drafts")))
+			return
+		}
+
+		draft, err := store.GetDraft(partnerPubKey)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else if len(draft) == 0 {
+			resolve(js.Null())
+		} else {
+			resolve(utils.CopyBytesToJS(draft))
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// ClearDraft deletes the draft of the conversation from the event model.
+//
+// Only available for clients created with [NewDMClientWithIndexedDb] (or its
+// unsafe variant).
+//
+// Parameters:
+//   - args[0] - The partner's [ed25519.PublicKey] (Uint8Array).
+//
+// Returns a promise:
+//   - Resolves on success.
+//   - Rejected with an error if the event model does not support drafts or
+//     the draft cannot be deleted.
+func (dmc *DMClient) ClearDraft(_ js.Value, args []js.Value) any {
+	partnerPubKey := ed25519.PublicKey(utils.CopyBytesToGo(args[0]))
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		store, ok := dmc.model.(dmDraftStore)
+		if !ok {
+			reject(exception.NewTrace(
+				errors.New("event model does not support drafts")))
+			return
+		}
+
+		err := store.ClearDraft(partnerPubKey, time.Now())
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve()
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// clearDraft deletes the draft of the conversation if the event model stores
+// drafts and the draft was saved before the message sent at sentAt. Errors are
+// logged because the message has already been sent.
+func (dmc *DMClient) clearDraft(partnerPubKey []byte, sentAt time.Time) {
+	store, ok := dmc.model.(dmDraftStore)
+	if !ok {
+		return
+	}
+	err := store.ClearDraft(partnerPubKey, sentAt)
+	if err != nil {
+		jww.ERROR.Printf("[DM] Failed to clear draft after send: %+v", err)
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // DM Share URL                                                          //
 ////////////////////////////////////////////////////////////////////////////////
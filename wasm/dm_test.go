@@ -20,7 +20,7 @@ import (
 func Test_newDMClientJS(t *testing.T) {
 	dmcType := reflect.TypeOf(&DMClient{})
 
-	dmc := newDMClientJS(&bindings.DMClient{})
+	dmc := newDMClientJS(&bindings.DMClient{}, nil)
 	if len(dmc) != dmcType.NumMethod() {
 		t.Errorf("DMClient JS object does not have all methods."+
 			"\nexpected: %d\nreceived: %d", dmcType.NumMethod(), len(dmc))
@@ -47,6 +47,71 @@ func Test_DMClientMethods(t *testing.T) {
 	} else {
 		numOfExcludedFields++
 	}
+	if _, exists := dmcType.MethodByName("ExportConversationHistory"); !exists {
+		t.Errorf("ExportConversationHistory was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := dmcType.MethodByName("CheckDatabase"); !exists {
+		t.Errorf("CheckDatabase was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := dmcType.MethodByName("GetStorageUsage"); !exists {
+		t.Errorf("GetStorageUsage was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := dmcType.MethodByName("EditMessage"); !exists {
+		t.Errorf("EditMessage was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := dmcType.MethodByName("SaveDraft"); !exists {
+		t.Errorf("SaveDraft was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := dmcType.MethodByName("GetDraft"); !exists {
+		t.Errorf("GetDraft was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := dmcType.MethodByName("ClearDraft"); !exists {
+		t.Errorf("ClearDraft was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := dmcType.MethodByName("GetDMMessages"); !exists {
+		t.Errorf("GetDMMessages was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := dmcType.MethodByName("GetConversations"); !exists {
+		t.Errorf("GetConversations was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := dmcType.MethodByName("MarkConversationRead"); !exists {
+		t.Errorf("MarkConversationRead was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := dmcType.MethodByName("SetConversationArchived"); !exists {
+		t.Errorf("SetConversationArchived was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := dmcType.MethodByName("SetConversationPinned"); !exists {
+		t.Errorf("SetConversationPinned was not found.")
+	} else {
+		numOfExcludedFields++
+	}
+	if _, exists := dmcType.MethodByName("SetConversationMuted"); !exists {
+		t.Errorf("SetConversationMuted was not found.")
+	} else {
+		numOfExcludedFields++
+	}
 
 	nm := dmcType.NumMethod() - numOfExcludedFields
 	if binDmcType.NumMethod() != nm {
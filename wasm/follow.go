@@ -64,6 +64,7 @@ func (c *Cmix) StartNetworkFollower(_ js.Value, args []js.Value) any {
 	}
 
 	storage.IncrementNumClientsRunning()
+	sessionTrackerSingleton.addFollower(c.api)
 	return nil
 }
 
@@ -83,6 +84,7 @@ func (c *Cmix) StopNetworkFollower(js.Value, []js.Value) any {
 	}
 
 	storage.DecrementNumClientsRunning()
+	sessionTrackerSingleton.removeFollower(c.api)
 	return nil
 }
 
@@ -0,0 +1,58 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package wasm
+
+import (
+	"crypto/ed25519"
+	"time"
+
+	"gitlab.com/elixxir/client/v4/channels"
+	"gitlab.com/xx_network/primitives/id"
+)
+
+// channelHistoryExporter is an event model that can export the history of a
+// channel (e.g., the indexedDb worker model).
+type channelHistoryExporter interface {
+	ExportHistory(channelID *id.ID, start, end time.Time,
+		format string) ([]byte, error)
+}
+
+// dmHistoryExporter is an event model that can export the history of a DM
+// conversation (e.g., the indexedDb worker model).
+type dmHistoryExporter interface {
+	ExportHistory(partnerPubKey ed25519.PublicKey, start, end time.Time,
+		format string) ([]byte, error)
+}
+
+// captureModelBuilder wraps the [channels.EventModelBuilder] so that the event
+// model it builds is saved to model.
+func captureModelBuilder(model *channels.EventModel,
+	builder channels.EventModelBuilder) channels.EventModelBuilder {
+	return func(path string) (channels.EventModel, error) {
+		m, err := builder(path)
+		if err == nil {
+			*model = m
+		}
+		return m, err
+	}
+}
+
+// historyRange converts the start and end of a date range from Unix
+// milliseconds to times. Zero is converted to the zero time, which leaves that
+// side of the range unbounded.
+func historyRange(startMS, endMS int64) (start, end time.Time) {
+	if startMS != 0 {
+		start = time.UnixMilli(startMS)
+	}
+	if endMS != 0 {
+		end = time.UnixMilli(endMS)
+	}
+	return start, end
+}
@@ -0,0 +1,340 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package wasm
+
+import (
+	"io"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+
+	"gitlab.com/elixxir/client/v4/bindings"
+	"gitlab.com/elixxir/client/v4/channels"
+	"gitlab.com/elixxir/wasm-utils/exception"
+	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/elixxir/xxdk-wasm/storage"
+)
+
+// errSessionLocked is returned when attempting to load a client or cipher
+// while the session is locked.
+var errSessionLocked = errors.New(
+	"session is locked; unlock with GetOrInitPassword")
+
+// errSessionExpired is returned when using an object that was created before
+// the session was last locked.
+var errSessionExpired = errors.New(
+	"object belongs to a locked session; it must be reloaded")
+
+// sessionTrackerSingleton tracks user activity and the resources that must be
+// released when the session is locked.
+var sessionTrackerSingleton = newSessionTracker()
+
+// sessionTracker locks the session after a period without user activity.
+// Locking stops all running network followers, closes all event model workers,
+// terminates all DbCipher objects, and marks the session as locked in storage
+// so that it must be unlocked again with the password.
+type sessionTracker struct {
+	// idleTimeout is the time without activity after which the session is
+	// locked. If it is zero, the session is not being timed.
+	idleTimeout  time.Duration
+	lastActivity time.Time
+	timer        *time.Timer
+
+	// onLock is called after the session is locked. It may be nil.
+	onLock func()
+
+	// lockFn releases all session resources. It is replaced in tests.
+	lockFn func()
+
+	// epoch is incremented each time the session is locked. Objects record
+	// the epoch they were created in so that they cannot be used after a lock.
+	epoch uint64
+
+	followers map[int]*bindings.Cmix
+	models    map[io.Closer]struct{}
+	mux       sync.Mutex
+}
+
+// newSessionTracker returns a new sessionTracker that is not timing the
+// session.
+func newSessionTracker() *sessionTracker {
+	st := &sessionTracker{
+		followers: make(map[int]*bindings.Cmix),
+		models:    make(map[io.Closer]struct{}),
+	}
+	st.lockFn = st.releaseAll
+	return st
+}
+
+// sessionLockCallback wraps a Javascript object so that it can be called when
+// the session is locked.
+type sessionLockCallback struct {
+	callback func(args ...any) js.Value
+}
+
+// Callback is called when the session is locked.
+func (slc *sessionLockCallback) Callback() { slc.callback() }
+
+// StartSession starts tracking user activity. If no activity is reported with
+// [SessionActivity] within the idle timeout, the session is locked.
+//
+// Locking stops all running network followers, closes all event model and
+// [StateStore] workers, and terminates all [DbCipher] objects, dropping their
+// references to the database keys. The keys are not wiped from memory, since
+// the underlying ciphers provide no way to do so; they are released once
+// garbage collected. Once locked, no [Cmix], [DbCipher] or [StateStore] can be
+// loaded until the session is unlocked by providing the correct password to
+// [GetOrInitPassword]. Every method of a [Cmix], [ChannelsManager], [DMClient],
+// [DbCipher] or [StateStore] object created before the lock throws an error,
+// even after the session is unlocked, so all Javascript objects from the locked
+// session must be discarded and reloaded.
+//
+// Calling StartSession again resets the timer with the new timeout.
+//
+// Parameters:
+//   - args[0] - The idle timeout, in milliseconds (int). Must be larger than 0.
+//   - args[1] - Javascript object that has a function Callback(), which is
+//     called after the session has been locked. It may be null.
+//
+// Returns:
+//   - Throws an error if the session is locked or the timeout is invalid.
+func StartSession(_ js.Value, args []js.Value) any {
+	idleTimeout := time.Duration(args[0].Int()) * time.Millisecond
+	var onLock func()
+	if !args[1].IsNull() && !args[1].IsUndefined() {
+		onLock = (&sessionLockCallback{
+			utils.WrapCB(args[1], "Callback")}).Callback
+	}
+
+	err := sessionTrackerSingleton.start(idleTimeout, onLock)
+	if err != nil {
+		exception.ThrowTrace(err)
+		return nil
+	}
+
+	return nil
+}
+
+// SessionActivity reports user activity and resets the idle timer. It should
+// be called by Javascript on user input.
+//
+// Returns:
+//   - True if the session is active and false if it is locked or has not been
+//     started (boolean).
+func SessionActivity(js.Value, []js.Value) any {
+	return sessionTrackerSingleton.activity()
+}
+
+// LockSession immediately locks the session, as if the idle timeout had been
+// reached.
+func LockSession(js.Value, []js.Value) any {
+	sessionTrackerSingleton.lock()
+	return nil
+}
+
+// IsSessionLocked returns true if the session has been locked and not yet
+// unlocked with [GetOrInitPassword].
+//
+// Returns:
+//   - True if locked (boolean).
+func IsSessionLocked(js.Value, []js.Value) any {
+	return storage.IsSessionLocked()
+}
+
+// start starts or restarts the idle timer.
+func (st *sessionTracker) start(idleTimeout time.Duration, onLock func()) error {
+	if storage.IsSessionLocked() {
+		return errSessionLocked
+	} else if idleTimeout <= 0 {
+		return errors.Errorf("invalid idle timeout %s", idleTimeout)
+	}
+
+	st.mux.Lock()
+	defer st.mux.Unlock()
+
+	st.idleTimeout = idleTimeout
+	st.lastActivity = time.Now()
+	st.onLock = onLock
+	if st.timer != nil {
+		st.timer.Stop()
+	}
+	st.timer = time.AfterFunc(idleTimeout, st.checkIdle)
+
+	jww.INFO.Printf("[SESSION] Started session with idle timeout of %s",
+		idleTimeout)
+	return nil
+}
+
+// activity records user activity. Returns false if the session is not active.
+func (st *sessionTracker) activity() bool {
+	st.mux.Lock()
+	defer st.mux.Unlock()
+
+	if st.idleTimeout == 0 || storage.IsSessionLocked() {
+		return false
+	}
+	st.lastActivity = time.Now()
+	return true
+}
+
+// checkIdle is called by the timer. It locks the session if the idle timeout
+// has passed since the last activity or otherwise reschedules the timer.
+func (st *sessionTracker) checkIdle() {
+	st.mux.Lock()
+	if st.idleTimeout == 0 {
+		st.mux.Unlock()
+		return
+	}
+
+	remaining := st.idleTimeout - time.Since(st.lastActivity)
+	if remaining > 0 {
+		st.timer = time.AfterFunc(remaining, st.checkIdle)
+		st.mux.Unlock()
+		return
+	}
+	st.mux.Unlock()
+
+	jww.INFO.Printf("[SESSION] No activity for %s; locking session",
+		st.idleTimeout)
+	st.lock()
+}
+
+// lock locks the session, releases all resources, and calls the lock
+// callback.
+func (st *sessionTracker) lock() {
+	storage.LockSession()
+
+	st.mux.Lock()
+	st.epoch++
+	st.idleTimeout = 0
+	if st.timer != nil {
+		st.timer.Stop()
+		st.timer = nil
+	}
+	onLock := st.onLock
+	st.onLock = nil
+	st.mux.Unlock()
+
+	st.lockFn()
+
+	if onLock != nil {
+		go onLock()
+	}
+}
+
+// releaseAll stops all tracked network followers, closes all tracked event
+// models, and terminates all DbCipher objects.
+func (st *sessionTracker) releaseAll() {
+	st.mux.Lock()
+	followers := st.followers
+	models := st.models
+	st.followers = make(map[int]*bindings.Cmix)
+	st.models = make(map[io.Closer]struct{})
+	st.mux.Unlock()
+
+	for cmixID, c := range followers {
+		if err := c.StopNetworkFollower(); err != nil {
+			jww.ERROR.Printf("[SESSION] Failed to stop network follower "+
+				"for Cmix %d: %+v", cmixID, err)
+			continue
+		}
+		storage.DecrementNumClientsRunning()
+	}
+
+	for model := range models {
+		if err := model.Close(); err != nil {
+			jww.ERROR.Printf("[SESSION] Failed to close event model: %+v", err)
+		}
+	}
+
+	dbCipherTrackerSingleton.terminateAll()
+
+	jww.INFO.Printf("[SESSION] Locked session: stopped %d network followers "+
+		"and closed %d event models", len(followers), len(models))
+}
+
+// addFollower tracks a Cmix with a running network follower.
+func (st *sessionTracker) addFollower(c *bindings.Cmix) {
+	st.mux.Lock()
+	defer st.mux.Unlock()
+	st.followers[c.GetID()] = c
+}
+
+// removeFollower stops tracking the network follower of the Cmix.
+func (st *sessionTracker) removeFollower(c *bindings.Cmix) {
+	st.mux.Lock()
+	defer st.mux.Unlock()
+	delete(st.followers, c.GetID())
+}
+
+// addModel tracks an event model so that it is closed when the session is
+// locked. Models that cannot be closed are ignored.
+func (st *sessionTracker) addModel(model any) {
+	closer, ok := model.(io.Closer)
+	if !ok {
+		return
+	}
+
+	st.mux.Lock()
+	defer st.mux.Unlock()
+	st.models[closer] = struct{}{}
+}
+
+// trackModelBuilder wraps the [channels.EventModelBuilder] so that every event
+// model it builds is tracked with addModel.
+func (st *sessionTracker) trackModelBuilder(
+	builder channels.EventModelBuilder) channels.EventModelBuilder {
+	return func(path string) (channels.EventModel, error) {
+		model, err := builder(path)
+		if err == nil {
+			st.addModel(model)
+		}
+		return model, err
+	}
+}
+
+// currentEpoch returns the epoch of the current session.
+func (st *sessionTracker) currentEpoch() uint64 {
+	st.mux.Lock()
+	defer st.mux.Unlock()
+	return st.epoch
+}
+
+// check returns an error if the session is locked or has been locked since the
+// epoch.
+func (st *sessionTracker) check(epoch uint64) error {
+	if storage.IsSessionLocked() {
+		return errSessionLocked
+	}
+
+	st.mux.Lock()
+	defer st.mux.Unlock()
+	if st.epoch != epoch {
+		return errSessionExpired
+	}
+	return nil
+}
+
+// sessionFuncOf returns a [js.Func] for a method of an object that belongs to
+// the current session. Once the session is locked, the function throws an
+// error instead of calling the method.
+func sessionFuncOf(fn func(this js.Value, args []js.Value) any) js.Func {
+	epoch := sessionTrackerSingleton.currentEpoch()
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		if err := sessionTrackerSingleton.check(epoch); err != nil {
+			exception.ThrowTrace(err)
+			return nil
+		}
+		return fn(this, args)
+	})
+}
@@ -0,0 +1,168 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package wasm
+
+import (
+	"bytes"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitlab.com/elixxir/crypto/indexedDb"
+	"gitlab.com/xx_network/crypto/csprng"
+
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+	"gitlab.com/elixxir/xxdk-wasm/storage"
+)
+
+// Tests that the sessionTracker does not lock while activity is reported and
+// locks once activity stops for longer than the idle timeout.
+func Test_sessionTracker_IdleTimeout(t *testing.T) {
+	st := newSessionTracker()
+	var locked, onLocked atomic.Int32
+	st.lockFn = func() { locked.Add(1) }
+
+	idleTimeout := 50 * time.Millisecond
+	err := st.start(idleTimeout, func() { onLocked.Add(1) })
+	if err != nil {
+		t.Fatalf("Failed to start session: %+v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		time.Sleep(idleTimeout / 4)
+		if !st.activity() {
+			t.Fatalf("Session not active after %d pings.", i)
+		}
+	}
+	if n := locked.Load(); n != 0 {
+		t.Fatalf("Session locked %d times while active.", n)
+	}
+
+	time.Sleep(3 * idleTimeout)
+	if n := locked.Load(); n != 1 {
+		t.Errorf("Session not locked after idle timeout."+
+			"\nexpected: %d\nreceived: %d", 1, n)
+	}
+	if !storage.IsSessionLocked() {
+		t.Errorf("Session not locked in storage.")
+	}
+	if st.activity() {
+		t.Errorf("Activity accepted on locked session.")
+	}
+
+	time.Sleep(idleTimeout / 4)
+	if n := onLocked.Load(); n != 1 {
+		t.Errorf("Lock callback not called."+
+			"\nexpected: %d\nreceived: %d", 1, n)
+	}
+
+	if err = st.start(idleTimeout, nil); err != errSessionLocked {
+		t.Errorf("Unexpected error starting locked session."+
+			"\nexpected: %v\nreceived: %+v", errSessionLocked, err)
+	}
+}
+
+// Tests that sessionTracker.check returns an error for an epoch from before
+// the session was locked.
+func Test_sessionTracker_check(t *testing.T) {
+	st := newSessionTracker()
+	st.lockFn = func() {}
+
+	epoch := st.currentEpoch()
+	st.lock()
+
+	if st.currentEpoch() == epoch {
+		t.Errorf("Epoch not changed after lock.")
+	}
+	if err := st.check(epoch); err == nil {
+		t.Errorf("No error checking epoch %d from before the lock.", epoch)
+	}
+}
+
+// Tests that sessionTracker.releaseAll closes all tracked models and
+// terminates all DbCipher objects.
+func Test_sessionTracker_releaseAll(t *testing.T) {
+	st := newSessionTracker()
+
+	models := []*mockCloser{{}, {}, {}}
+	for _, m := range models {
+		st.addModel(m)
+	}
+	st.addModel("not a closer")
+
+	c, err := indexedDb.NewCipher(
+		[]byte("password"), []byte("salt"), 32, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+	dbCipher := dbCipherTrackerSingleton.create(c)
+
+	st.releaseAll()
+
+	for i, m := range models {
+		if m.closed != 1 {
+			t.Errorf("Model #%d closed %d times.", i, m.closed)
+		}
+	}
+	if len(st.models) != 0 {
+		t.Errorf("%d models still tracked.", len(st.models))
+	}
+
+	if _, err = dbCipherTrackerSingleton.get(dbCipher.id); err == nil {
+		t.Errorf("DbCipher %d still tracked after release.", dbCipher.id)
+	}
+	if _, err = dbCipher.api.Encrypt([]byte("data")); err != errCipherTerminated {
+		t.Errorf("Unexpected error encrypting with terminated cipher."+
+			"\nexpected: %v\nreceived: %+v", errCipherTerminated, err)
+	}
+}
+
+// Tests that DbCipher.terminate closes the key ring shared with the event
+// models, replaces the cipher, and zeros the salt.
+func TestDbCipher_terminate(t *testing.T) {
+	c, err := indexedDb.NewCipher(
+		[]byte("password"), []byte("salt"), 32, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+	kr, err := impl.NewKeyRing(0, map[uint32]indexedDb.Cipher{0: c})
+	if err != nil {
+		t.Fatalf("Failed to create key ring: %+v", err)
+	}
+	salt := []byte("salt")
+	dbCipher := &DbCipher{api: kr, salt: salt}
+
+	dbCipher.terminate()
+
+	if _, err = dbCipher.api.Encrypt([]byte("data")); err != errCipherTerminated {
+		t.Errorf("Unexpected error encrypting with terminated cipher."+
+			"\nexpected: %v\nreceived: %+v", errCipherTerminated, err)
+	}
+	if _, err = kr.Encrypt([]byte("data")); err != impl.ErrKeyRingClosed {
+		t.Errorf("Unexpected error encrypting with closed key ring."+
+			"\nexpected: %v\nreceived: %+v", impl.ErrKeyRingClosed, err)
+	}
+	if n := len(kr.Ciphers()); n != 0 {
+		t.Errorf("Key ring still references %d keys.", n)
+	}
+	if !bytes.Equal(salt, make([]byte, len(salt))) {
+		t.Errorf("Salt not zeroed: %v", salt)
+	}
+}
+
+// mockCloser adheres to the io.Closer interface.
+type mockCloser struct {
+	closed int
+}
+
+func (m *mockCloser) Close() error {
+	m.closed++
+	return nil
+}
@@ -0,0 +1,521 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"sync"
+	"syscall/js"
+
+	"github.com/pkg/errors"
+
+	"gitlab.com/elixxir/wasm-utils/exception"
+	"gitlab.com/elixxir/wasm-utils/utils"
+	"gitlab.com/elixxir/xxdk-wasm/indexedDb/impl"
+	stateDb "gitlab.com/elixxir/xxdk-wasm/indexedDb/worker/state"
+	"gitlab.com/elixxir/xxdk-wasm/storage"
+)
+
+// StateStore wraps the [impl.WebState] of the state indexedDb worker so its
+// methods can be wrapped to be Javascript compatible. It is a persistent
+// key-value store for app settings and other small values.
+type StateStore struct {
+	api    impl.WebState
+	cipher *DbCipher
+
+	// watches are the functions that stop each watch, keyed on the ID returned
+	// by Watch
+	watches     map[int]func()
+	nextWatchID int
+	mux         sync.Mutex
+}
+
+// newStateStoreJS creates a new Javascript compatible object (map[string]any)
+// that matches the [StateStore] structure.
+func newStateStoreJS(s *StateStore) map[string]any {
+	stateStoreMap := map[string]any{
+		"Get":    sessionFuncOf(s.Get),
+		"Set":    sessionFuncOf(s.Set),
+		"Delete": sessionFuncOf(s.Delete),
+		"Keys":   sessionFuncOf(s.Keys),
+		"Clear":  sessionFuncOf(s.Clear),
+
+		"GetMany":        sessionFuncOf(s.GetMany),
+		"SetMany":        sessionFuncOf(s.SetMany),
+		"CompareAndSwap": sessionFuncOf(s.CompareAndSwap),
+		"Watch":          sessionFuncOf(s.Watch),
+		"Unwatch":        sessionFuncOf(s.Unwatch),
+	}
+
+	return stateStoreMap
+}
+
+// NewStateStore opens the state indexedDb database with the given name in a
+// new worker and returns a [StateStore] for it. The database is recorded so
+// that it is deleted on purge.
+//
+// If a cipher is given, every value is encrypted with it before it leaves the
+// main thread. Keys are not encrypted so that they can be listed by prefix, but
+// each value is encrypted together with its key so that a value moved to
+// another key fails to decrypt. The key counts towards the maximum payload size
+// of the cipher. The same cipher must be used every time the database is
+// opened.
+//
+// The store belongs to the current session. Once the session is locked, its
+// worker is closed and every method throws an error, so the store must be
+// opened again after the session is unlocked.
+//
+// Parameters:
+//   - args[0] - Path to Javascript file that starts the worker (string).
+//   - args[1] - Name of the database (string). It should be unique to the
+//     user, such as a base64 encoding of their public key.
+//   - args[2] - ID of [DbCipher] object in tracker (int). Create this object
+//     with [NewDatabaseCipher] and get its id with [DbCipher.GetID]. Pass null
+//     or undefined to store values unencrypted.
+//
+// Returns a promise:
+//   - Resolves to a Javascript representation of the [StateStore] object.
+//   - Rejected with an error if starting the worker or opening the database
+//     fails.
+//   - Throws an error if the session is locked or the cipher ID does not
+//     correspond to a cipher.
+func NewStateStore(_ js.Value, args []js.Value) any {
+	wasmJsPath := args[0].String()
+	name := args[1].String()
+
+	if storage.IsSessionLocked() {
+		exception.ThrowTrace(errSessionLocked)
+		return nil
+	}
+
+	var cipher *DbCipher
+	if !args[2].IsNull() && !args[2].IsUndefined() {
+		var err error
+		cipher, err = dbCipherTrackerSingleton.get(args[2].Int())
+		if err != nil {
+			exception.ThrowTrace(err)
+			return nil
+		}
+	}
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		api, err := stateDb.NewState(name, wasmJsPath)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			sessionTrackerSingleton.addModel(api)
+			resolve(newStateStoreJS(&StateStore{
+				api:     api,
+				cipher:  cipher,
+				watches: make(map[int]func()),
+			}))
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// Get returns the value stored with the key.
+//
+// Parameters:
+//   - args[0] - Key (string).
+//
+// Returns a promise:
+//   - Resolves to the value (Uint8Array) or null if there is no value with the
+//     key.
+//   - Rejected with an error if reading or decrypting the value fails.
+func (s *StateStore) Get(_ js.Value, args []js.Value) any {
+	key := args[0].String()
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		value, err := s.api.Get(key)
+		if err != nil {
+			if strings.Contains(err.Error(), impl.ErrDoesNotExist) {
+				resolve(js.Null())
+			} else {
+				reject(exception.NewTrace(err))
+			}
+			return
+		}
+
+		value, err = s.decrypt(key, value)
+		if err != nil {
+			reject(exception.NewTrace(err))
+			return
+		}
+		resolve(utils.CopyBytesToJS(value))
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// Set stores the value with the key, replacing any existing value.
+//
+// Parameters:
+//   - args[0] - Key (string).
+//   - args[1] - Value (Uint8Array).
+//
+// Returns a promise:
+//   - Resolves on success.
+//   - Rejected with an error if encrypting or storing the value fails.
+func (s *StateStore) Set(_ js.Value, args []js.Value) any {
+	key := args[0].String()
+	value := utils.CopyBytesToGo(args[1])
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		value, err := s.encrypt(key, value)
+		if err != nil {
+			reject(exception.NewTrace(err))
+			return
+		}
+
+		if err = s.api.Set(key, value); err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve()
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// Delete removes the value stored with the key. It is not an error if there is
+// none.
+//
+// Parameters:
+//   - args[0] - Key (string).
+//
+// Returns a promise:
+//   - Resolves on success.
+//   - Rejected with an error if deleting the value fails.
+func (s *StateStore) Delete(_ js.Value, args []js.Value) any {
+	key := args[0].String()
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		if err := s.api.Delete(key); err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve()
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// Keys returns every key that starts with the prefix in ascending order.
+//
+// Parameters:
+//   - args[0] - Prefix (string). Pass an empty string to list every key.
+//
+// Returns a promise:
+//   - Resolves to the keys (Array of strings).
+//   - Rejected with an error if reading the keys fails.
+func (s *StateStore) Keys(_ js.Value, args []js.Value) any {
+	prefix := args[0].String()
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		keys, err := s.api.Keys(prefix)
+		if err != nil {
+			reject(exception.NewTrace(err))
+			return
+		}
+
+		keysJS := make([]any, len(keys))
+		for i, key := range keys {
+			keysJS[i] = key
+		}
+		resolve(keysJS)
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// Clear removes every key and value.
+//
+// Returns a promise:
+//   - Resolves on success.
+//   - Rejected with an error if clearing the database fails.
+func (s *StateStore) Clear(js.Value, []js.Value) any {
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		if err := s.api.Clear(); err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve()
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// GetMany returns the values stored with the keys. The values are read in one
+// transaction.
+//
+// Parameters:
+//   - args[0] - Keys (Array of strings).
+//
+// Returns a promise:
+//   - Resolves to an object that maps each key to its value (Uint8Array). Keys
+//     without a value are omitted.
+//   - Rejected with an error if reading or decrypting the values fails.
+func (s *StateStore) GetMany(_ js.Value, args []js.Value) any {
+	keys := make([]string, args[0].Length())
+	for i := range keys {
+		keys[i] = args[0].Index(i).String()
+	}
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		values, err := s.api.GetMany(keys)
+		if err != nil {
+			reject(exception.NewTrace(err))
+			return
+		}
+
+		valuesJS := make(map[string]any, len(values))
+		for key, value := range values {
+			if value, err = s.decrypt(key, value); err != nil {
+				reject(exception.NewTrace(err))
+				return
+			}
+			valuesJS[key] = utils.CopyBytesToJS(value)
+		}
+		resolve(valuesJS)
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// SetMany stores each value with its key in one transaction. Either every value
+// is stored or none are.
+//
+// Parameters:
+//   - args[0] - Object that maps each key to its value (Uint8Array).
+//
+// Returns a promise:
+//   - Resolves on success.
+//   - Rejected with an error if encrypting or storing the values fails.
+func (s *StateStore) SetMany(_ js.Value, args []js.Value) any {
+	keys := js.Global().Get("Object").Call("keys", args[0])
+	values := make(map[string][]byte, keys.Length())
+	for i := 0; i < keys.Length(); i++ {
+		key := keys.Index(i).String()
+		values[key] = utils.CopyBytesToGo(args[0].Get(key))
+	}
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		for key, value := range values {
+			value, err := s.encrypt(key, value)
+			if err != nil {
+				reject(exception.NewTrace(err))
+				return
+			}
+			values[key] = value
+		}
+
+		if err := s.api.SetMany(values); err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve()
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// CompareAndSwap stores the new value with the key only if the current value
+// equals the old value.
+//
+// Parameters:
+//   - args[0] - Key (string).
+//   - args[1] - Old value (Uint8Array). Pass null to require that there is no
+//     value with the key.
+//   - args[2] - New value (Uint8Array). Pass null to delete the value.
+//
+// Returns a promise:
+//   - Resolves to true if the value was swapped and false otherwise.
+//   - Rejected with an error if reading, decrypting or storing the value fails.
+func (s *StateStore) CompareAndSwap(_ js.Value, args []js.Value) any {
+	key := args[0].String()
+	var oldValue, newValue []byte
+	if !args[1].IsNull() {
+		oldValue = utils.CopyBytesToGo(args[1])
+	}
+	if !args[2].IsNull() {
+		newValue = utils.CopyBytesToGo(args[2])
+	}
+
+	promiseFn := func(resolve, reject func(args ...any) js.Value) {
+		swapped, err := s.compareAndSwap(key, oldValue, newValue)
+		if err != nil {
+			reject(exception.NewTrace(err))
+		} else {
+			resolve(swapped)
+		}
+	}
+
+	return utils.CreatePromise(promiseFn)
+}
+
+// compareAndSwap swaps the value with the key if it equals oldValue.
+//
+// Encrypting the same value twice gives different ciphertexts, so with a cipher
+// the stored ciphertext is read and decrypted to compare it with oldValue and
+// is then swapped only if it has not changed since.
+func (s *StateStore) compareAndSwap(
+	key string, oldValue, newValue []byte) (bool, error) {
+	oldStored := oldValue
+	if s.cipher != nil && oldValue != nil {
+		values, err := s.api.GetMany([]string{key})
+		if err != nil {
+			return false, err
+		}
+
+		var exists bool
+		oldStored, exists = values[key]
+		if !exists {
+			return false, nil
+		}
+		value, err := s.decrypt(key, oldStored)
+		if err != nil {
+			return false, err
+		} else if !bytes.Equal(value, oldValue) {
+			return false, nil
+		}
+	}
+
+	var newStored []byte
+	if newValue != nil {
+		var err error
+		if newStored, err = s.encrypt(key, newValue); err != nil {
+			return false, err
+		}
+	}
+
+	return s.api.CompareAndSwap(key, oldStored, newStored)
+}
+
+// Watch registers a callback that is called when the values of keys that start
+// with the prefix are set or deleted, either by this store or by another tab
+// with the same database open.
+//
+// Parameters:
+//   - args[0] - Prefix (string). Pass an empty string to watch every key.
+//   - args[1] - Javascript object that has functions that implement the
+//     [stateWatchCallback] interface.
+//
+// Returns:
+//   - ID of the watch (int). Pass it to [StateStore.Unwatch] to stop it.
+func (s *StateStore) Watch(_ js.Value, args []js.Value) any {
+	cb := &stateWatchCallback{utils.WrapCB(args[1], "Callback")}
+	stop := s.api.Watch(args[0].String(), cb.Callback)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	watchID := s.nextWatchID
+	s.nextWatchID++
+	s.watches[watchID] = stop
+
+	return watchID
+}
+
+// Unwatch stops the watch with the ID. It is not an error if there is none.
+//
+// Parameters:
+//   - args[0] - ID of the watch returned by [StateStore.Watch] (int).
+func (s *StateStore) Unwatch(_ js.Value, args []js.Value) any {
+	watchID := args[0].Int()
+
+	s.mux.Lock()
+	stop, exists := s.watches[watchID]
+	delete(s.watches, watchID)
+	s.mux.Unlock()
+
+	if exists {
+		stop()
+	}
+	return nil
+}
+
+// stateWatchCallback wraps Javascript callbacks to adhere to the callback of
+// [impl.WebState.Watch].
+type stateWatchCallback struct {
+	callback func(args ...any) js.Value
+}
+
+// Callback is called with the changes to the watched keys.
+//
+// Parameters:
+//   - changes - JSON of an array of [impl.StateChange] (Uint8Array).
+//
+// Example JSON:
+//
+//	[
+//	  {"key": "theme", "deleted": false},
+//	  {"key": "draft/abc", "deleted": true}
+//	]
+func (swc *stateWatchCallback) Callback(changes []impl.StateChange) {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		exception.ThrowTrace(err)
+		return
+	}
+	swc.callback(utils.CopyBytesToJS(data))
+}
+
+// encrypt returns the value encrypted with the cipher together with its key or
+// the value itself if there is no cipher.
+func (s *StateStore) encrypt(key string, value []byte) ([]byte, error) {
+	if s.cipher == nil {
+		return value, nil
+	}
+	ciphertext, err := s.cipher.getAPI().Encrypt(bindStateKey(key, value))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(ciphertext), nil
+}
+
+// decrypt returns the value decrypted with the cipher or the value itself if
+// there is no cipher. Returns an error if the value was not encrypted with the
+// key.
+func (s *StateStore) decrypt(key string, value []byte) ([]byte, error) {
+	if s.cipher == nil {
+		return value, nil
+	}
+	plaintext, err := s.cipher.getAPI().Decrypt(string(value))
+	if err != nil {
+		return nil, err
+	}
+	return unbindStateKey(key, plaintext)
+}
+
+// bindStateKey returns the plaintext that is encrypted for the value stored
+// with the key. It is the length of the key as a uvarint, the key, and the
+// value.
+func bindStateKey(key string, value []byte) []byte {
+	plaintext := make([]byte, 0, binary.MaxVarintLen64+len(key)+len(value))
+	plaintext = binary.AppendUvarint(plaintext, uint64(len(key)))
+	plaintext = append(plaintext, key...)
+	return append(plaintext, value...)
+}
+
+// unbindStateKey returns the value in the plaintext created by bindStateKey.
+// Returns an error if the plaintext was not created for the key.
+func unbindStateKey(key string, plaintext []byte) ([]byte, error) {
+	keyLen, n := binary.Uvarint(plaintext)
+	if n <= 0 || keyLen != uint64(len(key)) ||
+		len(plaintext)-n < len(key) ||
+		string(plaintext[n:n+len(key)]) != key {
+		return nil, errors.Errorf("value was not stored with key %q", key)
+	}
+	return plaintext[n+len(key):], nil
+}
@@ -0,0 +1,124 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+//go:build js && wasm
+
+package wasm
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"gitlab.com/elixxir/crypto/indexedDb"
+	"gitlab.com/xx_network/crypto/csprng"
+)
+
+// Tests that the map representing StateStore returned by newStateStoreJS
+// contains all the methods on StateStore.
+func Test_newStateStoreJS(t *testing.T) {
+	stateStoreType := reflect.TypeOf(&StateStore{})
+
+	s := newStateStoreJS(&StateStore{})
+	if len(s) != stateStoreType.NumMethod() {
+		t.Errorf("StateStore JS object does not have all methods."+
+			"\nexpected: %d\nreceived: %d", stateStoreType.NumMethod(), len(s))
+	}
+
+	for i := 0; i < stateStoreType.NumMethod(); i++ {
+		method := stateStoreType.Method(i)
+
+		if _, exists := s[method.Name]; !exists {
+			t.Errorf("Method %s does not exist.", method.Name)
+		}
+	}
+}
+
+// Tests that a value encrypted by StateStore.encrypt is decrypted by
+// StateStore.decrypt with the same key and is rejected with any other key.
+func TestStateStore_encrypt(t *testing.T) {
+	c, err := indexedDb.NewCipher(
+		[]byte("password"), []byte("salt"), 64, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+	s := &StateStore{cipher: &DbCipher{api: c}}
+
+	value := []byte("value")
+	stored, err := s.encrypt("key", value)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %+v", err)
+	}
+	if bytes.Contains(stored, value) {
+		t.Errorf("Stored value contains the plaintext: %q", stored)
+	}
+
+	decrypted, err := s.decrypt("key", stored)
+	if err != nil {
+		t.Errorf("Failed to decrypt: %+v", err)
+	} else if !bytes.Equal(value, decrypted) {
+		t.Errorf("Unexpected value.\nexpected: %q\nreceived: %q",
+			value, decrypted)
+	}
+
+	for _, key := range []string{"", "ke", "key2", "other"} {
+		if _, err = s.decrypt(key, stored); err == nil {
+			t.Errorf("Value stored with %q decrypted with %q.", "key", key)
+		}
+	}
+}
+
+// Tests that StateStore.encrypt and StateStore.decrypt return the value
+// unchanged when there is no cipher.
+func TestStateStore_encrypt_NoCipher(t *testing.T) {
+	s := &StateStore{}
+	value := []byte("value")
+
+	stored, err := s.encrypt("key", value)
+	if err != nil || !bytes.Equal(value, stored) {
+		t.Errorf("Value changed without a cipher: %q, %+v", stored, err)
+	}
+	decrypted, err := s.decrypt("other", stored)
+	if err != nil || !bytes.Equal(value, decrypted) {
+		t.Errorf("Value changed without a cipher: %q, %+v", decrypted, err)
+	}
+}
+
+// Error path: Tests that StateStore.encrypt fails once the DbCipher is
+// terminated.
+func TestStateStore_encrypt_Terminated(t *testing.T) {
+	c, err := indexedDb.NewCipher(
+		[]byte("password"), []byte("salt"), 64, csprng.NewSystemRNG())
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %+v", err)
+	}
+	s := &StateStore{cipher: &DbCipher{api: c}}
+	s.cipher.terminate()
+
+	if _, err = s.encrypt("key", []byte("value")); err != errCipherTerminated {
+		t.Errorf("Unexpected error.\nexpected: %v\nreceived: %+v",
+			errCipherTerminated, err)
+	}
+}
+
+// Error path: Tests that unbindStateKey rejects plaintexts that were not
+// created by bindStateKey for the key.
+func Test_unbindStateKey_Error(t *testing.T) {
+	plaintexts := [][]byte{
+		nil,
+		{},
+		{0x80},
+		{5, 'k', 'e'},
+		bindStateKey("key", nil)[:3],
+	}
+
+	for i, plaintext := range plaintexts {
+		if _, err := unbindStateKey("key", plaintext); err == nil {
+			t.Errorf("No error for invalid plaintext %d: %v", i, plaintext)
+		}
+	}
+}